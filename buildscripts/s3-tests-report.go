@@ -0,0 +1,191 @@
+// +build ignore
+
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// s3-tests-report compares the JUnit results of an s3-tests run with the allowlist of known gaps.
+// It fails if a test that is not allowlisted failed, and reports the allowlist entries that no longer
+// match a failing test, so they can be removed.
+//
+//	go run buildscripts/s3-tests-report.go [-strict] <allowlist> <junit.xml>
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type testCase struct {
+	Name    string    `xml:"name,attr"`
+	Failure *struct{} `xml:"failure"`
+	Error   *struct{} `xml:"error"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+type testSuite struct {
+	Cases  []testCase  `xml:"testcase"`
+	Suites []testSuite `xml:"testsuite"`
+}
+
+// allowEntry is a test name or a path.Match pattern of test names from the allowlist
+type allowEntry struct {
+	pattern      string
+	line         int
+	failed, pass int
+}
+
+func readAllowlist(name string) ([]*allowEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []*allowEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := scanner.Text()
+		if i := strings.Index(pattern, "#"); i >= 0 {
+			pattern = pattern[:i]
+		}
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %v", name, line, pattern, err)
+		}
+		entries = append(entries, &allowEntry{pattern: pattern, line: line})
+	}
+	return entries, scanner.Err()
+}
+
+func readResults(name string) ([]testCase, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// the root is a testsuites element with testsuite elements, or a single testsuite
+	var root testSuite
+	if err := xml.NewDecoder(f).Decode(&root); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	var cases []testCase
+	var walk func(s testSuite)
+	walk = func(s testSuite) {
+		cases = append(cases, s.Cases...)
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+	return cases, nil
+}
+
+// testName returns the name of a test without the parameters of parametrized tests
+func testName(name string) string {
+	if i := strings.Index(name, "["); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func main() {
+	strict := flag.Bool("strict", false, "fail if allowlist entries no longer match a failing test")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: s3-tests-report [-strict] <allowlist> <junit.xml>")
+		os.Exit(2)
+	}
+	entries, err := readAllowlist(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cases, err := readResults(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(cases) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no tests were run\n", flag.Arg(1))
+		os.Exit(2)
+	}
+
+	var passed, skipped, allowed int
+	var regressions []string
+	for _, c := range cases {
+		if c.Skipped != nil {
+			skipped++
+			continue
+		}
+		failed := c.Failure != nil || c.Error != nil
+		name := testName(c.Name)
+		matched := false
+		for _, e := range entries {
+			if ok, _ := path.Match(e.pattern, name); !ok {
+				continue
+			}
+			matched = true
+			if failed {
+				e.failed++
+			} else {
+				e.pass++
+			}
+		}
+		switch {
+		case !failed:
+			passed++
+		case matched:
+			allowed++
+		default:
+			regressions = append(regressions, c.Name)
+		}
+	}
+
+	var stale []string
+	for _, e := range entries {
+		if e.failed == 0 {
+			stale = append(stale, fmt.Sprintf("%s:%d: %s (%d passed)", flag.Arg(0), e.line, e.pattern, e.pass))
+		}
+	}
+	sort.Strings(regressions)
+
+	fmt.Printf("s3-tests: %d passed, %d failed, %d of them allowlisted, %d skipped\n",
+		passed, allowed+len(regressions), allowed, skipped)
+	if len(stale) > 0 {
+		fmt.Println("allowlist entries without failing tests, remove them:")
+		for _, s := range stale {
+			fmt.Println("    " + s)
+		}
+	}
+	if len(regressions) > 0 {
+		fmt.Println("failed tests that are not allowlisted:")
+		for _, r := range regressions {
+			fmt.Println("    " + r)
+		}
+		os.Exit(1)
+	}
+	if *strict && len(stale) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,164 @@
+#!/bin/bash
+#
+# MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+
+# Runs the s3-tests conformance suite against an s3x gateway, and fails on
+# failed tests that are not in buildscripts/s3-tests-allowlist.txt.
+
+set -e
+set -E
+set -o pipefail
+
+if [ ! -x "$PWD/minio" ]; then
+    echo "minio executable binary not found in current directory"
+    exit 1
+fi
+
+WORK_DIR="$PWD/.s3-tests-$RANDOM"
+
+# the revision of the suite the allowlist is kept for, set it to a commit to pin the suite
+S3TESTS_REPO="${S3TESTS_REPO:-https://github.com/ceph/s3-tests.git}"
+S3TESTS_REF="${S3TESTS_REF:-master}"
+S3TESTS_DIR="${S3TESTS_DIR:-$WORK_DIR/s3-tests}"
+S3TESTS_ALLOWLIST="${S3TESTS_ALLOWLIST:-$PWD/buildscripts/s3-tests-allowlist.txt}"
+# tests of radosgw extensions that no other S3 server implements
+S3TESTS_MARKERS="${S3TESTS_MARKERS:-not fails_on_aws and not appendobject and not bucket_logging and not cloud_transition and not lifecycle_expiration and not s3select and not sts_test and not webidentity_test and not iam_account and not iam_user and not iam_role and not iam_tenant}"
+S3TESTS_STRICT="${S3TESTS_STRICT:-0}"
+
+TEMPORALX_ENDPOINT="${TEMPORALX_ENDPOINT:-xapi.temporal.cloud:9090}"
+TEMPORALX_INSECURE="${TEMPORALX_INSECURE:-0}"
+SERVER_ADDRESS="127.0.0.1:24250"
+ACCESS_KEY="minio"
+SECRET_KEY="minio123"
+
+function purge()
+{
+    rm -rf "$1"
+}
+
+function start_s3x_gateway()
+{
+    args=( gateway s3x --address "$SERVER_ADDRESS" --ds.path "$WORK_DIR/ds" --temporalx.endpoint "$TEMPORALX_ENDPOINT" )
+    if [ "$TEMPORALX_INSECURE" -eq 1 ]; then
+        args+=( --temporalx.insecure )
+    fi
+
+    MINIO_ACCESS_KEY=$ACCESS_KEY MINIO_SECRET_KEY=$SECRET_KEY \
+                    "$PWD/minio" --config-dir "$WORK_DIR/.minio" "${args[@]}" >"$WORK_DIR/gateway.log" 2>&1 &
+    gw_pid=$!
+
+    # the gateway is ready once its ledger datastore and TemporalX are usable
+    for _ in $(seq 1 60); do
+        if curl -sf "http://$SERVER_ADDRESS/minio/health/ready" >/dev/null; then
+            echo "$gw_pid"
+            return 0
+        fi
+        if ! kill -0 "$gw_pid" 2>/dev/null; then
+            break
+        fi
+        sleep 1
+    done
+
+    kill "$gw_pid" 2>/dev/null || true
+    return 1
+}
+
+function install_s3_tests()
+{
+    if [ ! -d "$S3TESTS_DIR/.git" ]; then
+        git clone --quiet "$S3TESTS_REPO" "$S3TESTS_DIR"
+    fi
+    (cd "$S3TESTS_DIR" && git fetch --quiet origin "$S3TESTS_REF" && git checkout --quiet FETCH_HEAD)
+
+    python3 -m venv "$WORK_DIR/venv"
+    "$WORK_DIR/venv/bin/pip" install --quiet -r "$S3TESTS_DIR/requirements.txt" pytest
+}
+
+function write_s3_tests_config()
+{
+    # the gateway has a single user, so every user of the suite uses its credentials
+    {
+        echo "[DEFAULT]"
+        echo "host = ${SERVER_ADDRESS%:*}"
+        echo "port = ${SERVER_ADDRESS#*:}"
+        echo "is_secure = False"
+        echo "ssl_verify = False"
+        echo
+        echo "[fixtures]"
+        echo "bucket prefix = s3x-{random}-"
+        for section in "s3 main" "s3 alt" "s3 tenant" "iam" "iam root" "iam alt root"; do
+            echo
+            echo "[$section]"
+            echo "display_name = $ACCESS_KEY"
+            echo "user_id = $ACCESS_KEY"
+            echo "email = $ACCESS_KEY@example.com"
+            echo "access_key = $ACCESS_KEY"
+            echo "secret_key = $SECRET_KEY"
+            echo "api_name = us-east-1"
+        done
+    } > "$WORK_DIR/s3tests.conf"
+}
+
+function run_s3_tests()
+{
+    # the result of the run is decided by the report, not by the failed tests
+    (cd "$S3TESTS_DIR" && S3TEST_CONF="$WORK_DIR/s3tests.conf" "$WORK_DIR/venv/bin/pytest" \
+        -q -p no:cacheprovider -m "$S3TESTS_MARKERS" --junitxml "$WORK_DIR/results.xml" \
+        s3tests_boto3/functional/test_s3.py >"$WORK_DIR/s3-tests.log" 2>&1) || true
+
+    report_args=()
+    if [ "$S3TESTS_STRICT" -eq 1 ]; then
+        report_args+=( -strict )
+    fi
+    go run buildscripts/s3-tests-report.go "${report_args[@]}" "$S3TESTS_ALLOWLIST" "$WORK_DIR/results.xml"
+}
+
+function main()
+{
+    mkdir -p "$WORK_DIR"
+
+    echo "Installing s3-tests $S3TESTS_REF"
+    install_s3_tests
+    write_s3_tests_config
+
+    echo "Starting the s3x gateway with TemporalX at $TEMPORALX_ENDPOINT"
+    if ! gw_pid="$(start_s3x_gateway)"; then
+        echo "FAILED to start the gateway"
+        cat "$WORK_DIR/gateway.log"
+        return 1
+    fi
+
+    echo "Running s3-tests"
+    rv=0
+    run_s3_tests || rv=$?
+
+    kill "$gw_pid"
+    sleep 3
+
+    if [ "$rv" -ne 0 ]; then
+        echo "=========== s3-tests ==========="
+        tail -n 200 "$WORK_DIR/s3-tests.log"
+        echo "=========== Gateway ==========="
+        tail -n 200 "$WORK_DIR/gateway.log"
+    fi
+
+    return "$rv"
+}
+
+( main "$@" )
+rv=$?
+purge "$WORK_DIR"
+exit "$rv"
@@ -86,6 +86,12 @@ func (api objectAPIHandlers) PutBucketACLHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objAPI, bucket, r); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	aclHeader := r.Header.Get(xhttp.AmzACL)
 	if aclHeader == "" {
 		acl := &accessControlPolicy{}
@@ -205,6 +211,12 @@ func (api objectAPIHandlers) PutObjectACLHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objAPI, bucket, r); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	aclHeader := r.Header.Get(xhttp.AmzACL)
 	if aclHeader == "" {
 		acl := &accessControlPolicy{}
@@ -0,0 +1,214 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
+	"github.com/RTradeLtd/s3x/pkg/madmin"
+	"go.uber.org/atomic"
+)
+
+const (
+	// defaultProfileDuration is how long CPU and block profiles are sampled if no duration is requested
+	defaultProfileDuration = 30 * time.Second
+	// maxProfileDuration is the longest CPU and block profile
+	maxProfileDuration = 5 * time.Minute
+)
+
+// errProfileInProgress is returned when a CPU or block profile is captured while another one is
+var errProfileInProgress = errors.New("a profile is already being captured")
+
+// profileCapturing is set while a CPU or block profile is captured, the runtime can only sample one at a time
+var profileCapturing atomic.Bool
+
+// profileFileNames are the names of the profiles in a profile bundle
+var profileFileNames = map[madmin.ProfilerType]string{
+	madmin.ProfilerCPU:        "cpu.pprof",
+	madmin.ProfilerBlock:      "block.pprof",
+	madmin.ProfilerMEM:        "heap.pprof",
+	madmin.ProfilerGoroutines: "goroutines.txt",
+}
+
+// validateProfilingReq checks that the request is an admin request allowed to profile the server, and that the
+// object layer exposes profiles, and writes the error response otherwise.
+func validateProfilingReq(ctx context.Context, w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	objectAPI, _ := validateAdminReq(ctx, w, r, iampolicy.ProfilingAdminAction)
+	if objectAPI == nil {
+		return 0, false
+	}
+	if p, ok := unwrapObjectLayer(objectAPI).(ProfilingEnabler); !ok || !p.ProfilingEnabled() {
+		writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrAdminProfilerNotEnabled), r.URL)
+		return 0, false
+	}
+	duration := defaultProfileDuration
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxProfileDuration {
+			writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrAdminInvalidArgument), r.URL)
+			return 0, false
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	return duration, true
+}
+
+// captureProfiles writes the profiles to the writers of their type. CPU and block profiles are sampled
+// together for duration, or until ctx is done, the other profiles are snapshots taken afterwards.
+func captureProfiles(ctx context.Context, duration time.Duration, writers map[madmin.ProfilerType]io.Writer) error {
+	sampled := writers[madmin.ProfilerCPU] != nil || writers[madmin.ProfilerBlock] != nil
+	if sampled {
+		if !profileCapturing.CAS(false, true) {
+			return errProfileInProgress
+		}
+		defer profileCapturing.Store(false)
+	}
+	if w, ok := writers[madmin.ProfilerCPU]; ok {
+		if err := pprof.StartCPUProfile(w); err != nil {
+			// profiling started with the profiling/start api is running
+			return errProfileInProgress
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if _, ok := writers[madmin.ProfilerBlock]; ok {
+		runtime.SetBlockProfileRate(1)
+		defer runtime.SetBlockProfileRate(0)
+	}
+	if sampled {
+		timer := time.NewTimer(duration)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if w, ok := writers[madmin.ProfilerBlock]; ok {
+		if err := pprof.Lookup("block").WriteTo(w, 0); err != nil {
+			return err
+		}
+	}
+	if w, ok := writers[madmin.ProfilerMEM]; ok {
+		runtime.GC()
+		if err := pprof.Lookup("heap").WriteTo(w, 0); err != nil {
+			return err
+		}
+	}
+	if w, ok := writers[madmin.ProfilerGoroutines]; ok {
+		if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProfileErr writes the error response of a profile that could not be captured
+func writeProfileErr(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if err == errProfileInProgress {
+		writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrAdminProfileInProgress), r.URL)
+		return
+	}
+	if err == context.Canceled {
+		// the client is gone
+		return
+	}
+	writeErrorResponseJSON(ctx, w, toAdminAPIErr(ctx, err), r.URL)
+}
+
+// ProfileHandler - GET /minio/admin/v3/profiling/profile?profilerType={profilerType}&seconds={seconds}
+// ----------
+// Returns one runtime profile of this server, CPU and block profiles are sampled for the
+// requested seconds, 30 by default, heap profiles and goroutine dumps are snapshots.
+func (a adminAPIHandlers) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "Profile")
+
+	duration, ok := validateProfilingReq(ctx, w, r)
+	if !ok {
+		return
+	}
+	profiler := madmin.ProfilerType(r.URL.Query().Get("profilerType"))
+	if _, ok := profileFileNames[profiler]; !ok {
+		writeErrorResponseJSON(ctx, w, errorCodes.ToAPIErr(ErrAdminInvalidArgument), r.URL)
+		return
+	}
+
+	// profiles are buffered, so a failed capture can still return an error response
+	var buf bytes.Buffer
+	if err := captureProfiles(ctx, duration, map[madmin.ProfilerType]io.Writer{profiler: &buf}); err != nil {
+		writeProfileErr(ctx, w, r, err)
+		return
+	}
+	mime := mimeType("application/octet-stream")
+	if profiler == madmin.ProfilerGoroutines {
+		mime = "text/plain"
+	}
+	writeResponse(w, http.StatusOK, buf.Bytes(), mime)
+}
+
+// ProfileBundleHandler - GET /minio/admin/v3/profiling/bundle?seconds={seconds}
+// ----------
+// Returns a zip of the CPU and block profiles of this server sampled for the requested
+// seconds, 30 by default, followed by its heap profile and goroutine dump, for support.
+func (a adminAPIHandlers) ProfileBundleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "ProfileBundle")
+
+	duration, ok := validateProfilingReq(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	buffers := make(map[madmin.ProfilerType]*bytes.Buffer, len(profileFileNames))
+	writers := make(map[madmin.ProfilerType]io.Writer, len(profileFileNames))
+	for profiler := range profileFileNames {
+		buffers[profiler] = &bytes.Buffer{}
+		writers[profiler] = buffers[profiler]
+	}
+	if err := captureProfiles(ctx, duration, writers); err != nil {
+		writeProfileErr(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Set(xhttp.ContentDisposition, `attachment; filename="profile-bundle.zip"`)
+	w.Header().Set(xhttp.ContentType, "application/zip")
+	zipWriter := zip.NewWriter(w)
+	for _, profiler := range []madmin.ProfilerType{madmin.ProfilerCPU, madmin.ProfilerBlock, madmin.ProfilerMEM, madmin.ProfilerGoroutines} {
+		header := &zip.FileHeader{Name: profileFileNames[profiler], Method: zip.Deflate}
+		header.SetModTime(UTCNow())
+		zwriter, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			logger.LogIf(ctx, err)
+			return
+		}
+		if _, err = io.Copy(zwriter, buffers[profiler]); err != nil {
+			logger.LogIf(ctx, err)
+			return
+		}
+	}
+	logger.LogIf(ctx, zipWriter.Close())
+}
@@ -0,0 +1,102 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/madmin"
+)
+
+func TestCaptureProfiles(t *testing.T) {
+	ctx := context.Background()
+	buffers := make(map[madmin.ProfilerType]*bytes.Buffer)
+	writers := make(map[madmin.ProfilerType]io.Writer)
+	for profiler := range profileFileNames {
+		buffers[profiler] = &bytes.Buffer{}
+		writers[profiler] = buffers[profiler]
+	}
+	if err := captureProfiles(ctx, 100*time.Millisecond, writers); err != nil {
+		t.Fatal(err)
+	}
+	for profiler, buf := range buffers {
+		if buf.Len() == 0 {
+			t.Fatalf("expected a %v profile", profiler)
+		}
+	}
+	if !strings.Contains(buffers[madmin.ProfilerGoroutines].String(), "TestCaptureProfiles") {
+		t.Fatal("expected the goroutine dump to contain the stack of the test")
+	}
+
+	// only one CPU or block profile is sampled at a time
+	profileCapturing.Store(true)
+	err := captureProfiles(ctx, time.Millisecond, map[madmin.ProfilerType]io.Writer{madmin.ProfilerBlock: &bytes.Buffer{}})
+	profileCapturing.Store(false)
+	if err != errProfileInProgress {
+		t.Fatalf("expected %v, got %v", errProfileInProgress, err)
+	}
+	// snapshots are taken while a profile is sampled
+	if err := captureProfiles(ctx, 0, map[madmin.ProfilerType]io.Writer{madmin.ProfilerMEM: &bytes.Buffer{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := captureProfiles(canceled, time.Minute, map[madmin.ProfilerType]io.Writer{madmin.ProfilerCPU: &bytes.Buffer{}}); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+// profilingObjects exposes the runtime profiles of the server if enabled
+type profilingObjects struct {
+	ObjectLayer
+	enabled bool
+}
+
+func (o profilingObjects) ProfilingEnabled() bool {
+	return o.enabled
+}
+
+func TestProfileHandler(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+			return profilingObjects{ObjectLayer: objLayer, enabled: enabled}
+		})
+		req, err := buildAdminRequest(url.Values{"profilerType": {string(madmin.ProfilerGoroutines)}}, http.MethodGet, "/profiling/profile", 0, nil)
+		if err != nil {
+			tb.TearDown()
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		tb.router.ServeHTTP(rec, req)
+		tb.TearDown()
+		if enabled && (rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine")) {
+			t.Fatalf("expected the goroutine dump of a gateway with profiling enabled behind its locker, but got %d: %s", rec.Code, rec.Body.String())
+		}
+		if !enabled && !strings.Contains(rec.Body.String(), errorCodes.ToAPIErr(ErrAdminProfilerNotEnabled).Code) {
+			t.Fatalf("expected profiling to be disabled, but got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
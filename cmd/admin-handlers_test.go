@@ -383,3 +383,37 @@ func TestExtractHealInitParams(t *testing.T) {
 	}
 
 }
+
+// dataUsageObjects reports the data usage it accounts for itself
+type dataUsageObjects struct {
+	ObjectLayer
+}
+
+func (o dataUsageObjects) DataUsageInfo(ctx context.Context) (DataUsageInfo, error) {
+	return DataUsageInfo{ObjectsCount: 42, ObjectsTotalSize: 4200}, nil
+}
+
+// Test that the data usage reported by a gateway is returned through its locker.
+func TestDataUsageInfoHandlerReporter(t *testing.T) {
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return dataUsageObjects{ObjectLayer: objLayer}
+	})
+	defer tb.TearDown()
+
+	req, err := buildAdminRequest(url.Values{}, http.MethodGet, "/datausageinfo", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var info DataUsageInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ObjectsCount != 42 || info.ObjectsTotalSize != 4200 {
+		t.Fatalf("expected the data usage of the gateway, but got %+v", info)
+	}
+}
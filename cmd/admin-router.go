@@ -78,6 +78,9 @@ func registerAdminRouter(router *mux.Router, enableConfigOps, enableIAMOps bool)
 	adminRouter.Methods(http.MethodPost).Path(adminAPIVersionPrefix+"/profiling/start").HandlerFunc(httpTraceAll(adminAPI.StartProfilingHandler)).
 		Queries("profilerType", "{profilerType:.*}")
 	adminRouter.Methods(http.MethodGet).Path(adminAPIVersionPrefix + "/profiling/download").HandlerFunc(httpTraceAll(adminAPI.DownloadProfilingHandler))
+	adminRouter.Methods(http.MethodGet).Path(adminAPIVersionPrefix+"/profiling/profile").HandlerFunc(httpTraceAll(adminAPI.ProfileHandler)).
+		Queries("profilerType", "{profilerType:.*}")
+	adminRouter.Methods(http.MethodGet).Path(adminAPIVersionPrefix + "/profiling/bundle").HandlerFunc(httpTraceAll(adminAPI.ProfileBundleHandler))
 
 	// Config KV operations.
 	if enableConfigOps {
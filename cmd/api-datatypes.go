@@ -20,9 +20,10 @@ import (
 	"encoding/xml"
 )
 
-// ObjectIdentifier carries key name for the object to delete.
+// ObjectIdentifier carries key name and optionally the version id for the object to delete.
 type ObjectIdentifier struct {
 	ObjectName string `xml:"Key"`
+	VersionID  string `xml:"VersionId,omitempty"`
 }
 
 // createBucketConfiguration container for bucket configuration request from client.
@@ -57,6 +57,7 @@ type APIErrorResponse struct {
 	BucketName string `xml:"BucketName,omitempty" json:"BucketName,omitempty"`
 	Resource   string
 	Region     string `xml:"Region,omitempty" json:"Region,omitempty"`
+	Endpoint   string `xml:"Endpoint,omitempty" json:"Endpoint,omitempty"`
 	RequestID  string `xml:"RequestId" json:"RequestId"`
 	HostID     string `xml:"HostId" json:"HostId"`
 }
@@ -77,14 +78,27 @@ const (
 	ErrInvalidAccessKeyID
 	ErrInvalidBucketName
 	ErrInvalidDigest
+	ErrBadChecksum
+	ErrInvalidChecksum
+	ErrInvalidChecksumAlgorithm
+	ErrInvalidChecksumType
+	ErrMissingPartChecksum
+	ErrInvalidListingFilter
+	ErrInvalidObjectAttributes
 	ErrInvalidRange
 	ErrInvalidCopyPartRange
 	ErrInvalidCopyPartRangeSource
 	ErrInvalidMaxKeys
+	ErrInvalidMaxBuckets
+	ErrInvalidBucketSort
 	ErrInvalidEncodingMethod
 	ErrInvalidMaxUploads
 	ErrInvalidMaxParts
 	ErrInvalidPartNumberMarker
+	ErrInvalidPartNumber
+	ErrAnonymousResponseHeaders
+	ErrMissingRequiredHeader
+	ErrPermanentRedirect
 	ErrInvalidRequestBody
 	ErrInvalidCopySource
 	ErrInvalidMetadataDirective
@@ -101,6 +115,9 @@ const (
 	ErrNoSuchBucketLifecycle
 	ErrNoSuchLifecycleConfiguration
 	ErrNoSuchBucketSSEConfig
+	ErrNoSuchPublicAccessBlockConfiguration
+	ErrNoSuchMetricsConfiguration
+	ErrTooManyMetricsConfigurations
 	ErrNoSuchKey
 	ErrNoSuchUpload
 	ErrNoSuchVersion
@@ -331,6 +348,7 @@ const (
 
 	ErrAdminConfigNotificationTargetsFailed
 	ErrAdminProfilerNotEnabled
+	ErrAdminProfileInProgress
 	ErrInvalidDecompressedSize
 	ErrAddUserInvalidArgument
 	ErrAddServiceAccountInvalidArgument
@@ -385,6 +403,16 @@ var errorCodes = errorCodeMap{
 		Description:    "Argument maxKeys must be an integer between 0 and 2147483647",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrInvalidMaxBuckets: {
+		Code:           "InvalidArgument",
+		Description:    "Argument max-buckets must be an integer between 1 and 10000",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidBucketSort: {
+		Code:           "InvalidArgument",
+		Description:    "Argument sort-by must be name or creation-date",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrInvalidEncodingMethod: {
 		Code:           "InvalidArgument",
 		Description:    "Invalid Encoding Method specified in Request",
@@ -400,6 +428,26 @@ var errorCodes = errorCodeMap{
 		Description:    "Argument partNumberMarker must be an integer.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrInvalidPartNumber: {
+		Code:           "InvalidArgument",
+		Description:    "Part number must be an integer between 1 and the maximum number of parts.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrAnonymousResponseHeaders: {
+		Code:           "InvalidRequest",
+		Description:    "Request specific response headers cannot be used for anonymous GET requests.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingRequiredHeader: {
+		Code:           "AccessDenied",
+		Description:    "The request is missing a header, or a header value, required by the bucket.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ErrPermanentRedirect: {
+		Code:           "PermanentRedirect",
+		Description:    "The bucket you are attempting to access must be addressed using the specified endpoint. Please send all future requests to this endpoint.",
+		HTTPStatusCode: http.StatusMovedPermanently,
+	},
 	ErrInvalidPolicyDocument: {
 		Code:           "InvalidPolicyDocument",
 		Description:    "The content of the form does not meet the conditions specified in the policy document.",
@@ -455,6 +503,41 @@ var errorCodes = errorCodeMap{
 		Description:    "The Content-Md5 you specified is not valid.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrBadChecksum: {
+		Code:           "BadDigest",
+		Description:    "The checksum you specified did not match the calculated checksum.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksum: {
+		Code:           "InvalidRequest",
+		Description:    "Value for an x-amz-checksum header is invalid, or more than one checksum was specified.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksumAlgorithm: {
+		Code:           "InvalidRequest",
+		Description:    "Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, CRC64NVME, SHA1, SHA256]",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidChecksumType: {
+		Code:           "InvalidRequest",
+		Description:    "Only the COMPOSITE checksum type is supported for multipart uploads.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrMissingPartChecksum: {
+		Code:           "InvalidRequest",
+		Description:    "The upload was created using a checksum algorithm, each part must include a checksum of that algorithm.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidListingFilter: {
+		Code:           "InvalidArgument",
+		Description:    "The listing filter is invalid, sizes must be non-negative integers, modified-after an RFC3339 time and tags key=value pairs.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidObjectAttributes: {
+		Code:           "InvalidArgument",
+		Description:    "Invalid attribute name specified.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrInvalidRange: {
 		Code:           "InvalidRange",
 		Description:    "The requested range is not satisfiable",
@@ -510,6 +593,21 @@ var errorCodes = errorCodeMap{
 		Description:    "The server side encryption configuration was not found",
 		HTTPStatusCode: http.StatusNotFound,
 	},
+	ErrNoSuchPublicAccessBlockConfiguration: {
+		Code:           "NoSuchPublicAccessBlockConfiguration",
+		Description:    "The public access block configuration was not found",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrNoSuchMetricsConfiguration: {
+		Code:           "NoSuchConfiguration",
+		Description:    "The specified configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	ErrTooManyMetricsConfigurations: {
+		Code:           "TooManyConfigurations",
+		Description:    "You are attempting to create a new configuration but have already reached the 1,000-configuration limit.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	ErrNoSuchKey: {
 		Code:           "NoSuchKey",
 		Description:    "The specified key does not exist.",
@@ -1070,6 +1168,11 @@ var errorCodes = errorCodeMap{
 		Description:    "Unable to perform the requested operation because profiling is not enabled",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	ErrAdminProfileInProgress: {
+		Code:           "XMinioAdminProfileInProgress",
+		Description:    "A CPU or block profile is already being captured, please retry once it is done",
+		HTTPStatusCode: http.StatusConflict,
+	},
 	ErrAdminCredentialsMismatch: {
 		Code:           "XMinioAdminCredentialsMismatch",
 		Description:    "Credentials in config mismatch with server environment variables",
@@ -1692,6 +1795,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrStorageFull
 	case hash.BadDigest:
 		apiErr = ErrBadDigest
+	case ChecksumMismatch:
+		apiErr = ErrBadChecksum
 	case AllAccessDisabled:
 		apiErr = ErrAllAccessDisabled
 	case IncompleteBody:
@@ -1716,6 +1821,8 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrBucketAlreadyOwnedByYou
 	case ObjectNotFound:
 		apiErr = ErrNoSuchKey
+	case VersionNotFound:
+		apiErr = ErrNoSuchVersion
 	case ObjectAlreadyExists:
 		apiErr = ErrMethodNotAllowed
 	case ObjectNameInvalid:
@@ -1752,6 +1859,12 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrNotImplemented
 	case PartTooBig:
 		apiErr = ErrEntityTooLarge
+	case InvalidPartNumber:
+		apiErr = ErrInvalidPartNumber
+	case MetadataTooLarge:
+		apiErr = ErrMetadataTooLarge
+	case InvalidContinuationToken:
+		apiErr = ErrIncorrectContinuationToken
 	case UnsupportedMetadata:
 		apiErr = ErrUnsupportedMetadata
 	case BucketPolicyNotFound:
@@ -1760,6 +1873,10 @@ func toAPIErrorCode(ctx context.Context, err error) (apiErr APIErrorCode) {
 		apiErr = ErrNoSuchLifecycleConfiguration
 	case BucketSSEConfigNotFound:
 		apiErr = ErrNoSuchBucketSSEConfig
+	case PublicAccessBlockNotFound:
+		apiErr = ErrNoSuchPublicAccessBlockConfiguration
+	case BucketMetricsConfigNotFound:
+		apiErr = ErrNoSuchMetricsConfiguration
 	case *event.ErrInvalidEventName:
 		apiErr = ErrEventNotification
 	case *event.ErrInvalidARN:
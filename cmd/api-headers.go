@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -25,24 +27,47 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/RTradeLtd/s3x/cmd/crypto"
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 )
 
+// lastRequestID is the time in nanoseconds of the last request id.
+var lastRequestID int64
+
 // Returns a hexadecimal representation of time at the
-// time response is sent to the client.
+// time response is sent to the client. Request ids of
+// the same nanosecond are incremented to keep them unique.
 func mustGetRequestID(t time.Time) string {
-	return fmt.Sprintf("%X", t.UnixNano())
+	for {
+		last := atomic.LoadInt64(&lastRequestID)
+		id := t.UnixNano()
+		if id <= last {
+			id = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastRequestID, last, id) {
+			return fmt.Sprintf("%X", id)
+		}
+	}
+}
+
+// Returns the extended request id of a request, an opaque
+// base64 string unique to the request id, the deployment
+// and the node that served the request.
+func getRequestHostID(requestID string) string {
+	sum := sha256.Sum256([]byte(globalDeploymentID + "/" + GetLocalPeer(globalEndpoints) + "/" + requestID))
+	return base64.StdEncoding.EncodeToString(sum[:])
 }
 
 // Write http common headers
 func setCommonHeaders(w http.ResponseWriter) {
 	w.Header().Set(xhttp.ServerInfo, "MinIO/"+ReleaseTag)
 	// Set `x-amz-bucket-region` only if region is set on the server
-	// by default minio uses an empty region.
-	if region := globalServerRegion; region != "" {
+	// by default minio uses an empty region, the region of a bucket
+	// set by the bucket region handler is kept.
+	if region := globalServerRegion; region != "" && w.Header().Get(xhttp.AmzBucketRegion) == "" {
 		w.Header().Set(xhttp.AmzBucketRegion, region)
 	}
 	w.Header().Set(xhttp.AcceptRanges, "bytes")
@@ -116,23 +141,17 @@ func setObjectHeaders(w http.ResponseWriter, objInfo ObjectInfo, rs *HTTPRangeSp
 			// values to client.
 			continue
 		}
+		if rs != nil && isChecksumKey(k) {
+			// The checksum of the object does not match the data
+			// of a range or part.
+			continue
+		}
 		w.Header().Set(k, v)
 	}
 
-	var totalObjectSize int64
-	switch {
-	case crypto.IsEncrypted(objInfo.UserDefined):
-		totalObjectSize, err = objInfo.DecryptedSize()
-		if err != nil {
-			return err
-		}
-	case objInfo.IsCompressed():
-		totalObjectSize = objInfo.GetActualSize()
-		if totalObjectSize < 0 {
-			return errInvalidDecompressedSize
-		}
-	default:
-		totalObjectSize = objInfo.Size
+	totalObjectSize, err := getTotalObjectSize(objInfo)
+	if err != nil {
+		return err
 	}
 
 	// for providing ranged content
@@ -150,3 +169,21 @@ func setObjectHeaders(w http.ResponseWriter, objInfo ObjectInfo, rs *HTTPRangeSp
 
 	return nil
 }
+
+// getTotalObjectSize returns the size of the object as it is returned to
+// clients, the decrypted or decompressed size if it is stored encrypted or
+// compressed. Ranges of the object are resolved against this size.
+func getTotalObjectSize(objInfo ObjectInfo) (int64, error) {
+	switch {
+	case crypto.IsEncrypted(objInfo.UserDefined):
+		return objInfo.DecryptedSize()
+	case objInfo.IsCompressed():
+		totalObjectSize := objInfo.GetActualSize()
+		if totalObjectSize < 0 {
+			return 0, errInvalidDecompressedSize
+		}
+		return totalObjectSize, nil
+	default:
+		return objInfo.Size, nil
+	}
+}
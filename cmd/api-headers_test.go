@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"testing"
 )
 
@@ -38,3 +39,35 @@ func TestNewRequestID(t *testing.T) {
 		}
 	}
 }
+
+func TestNewRequestIDUnique(t *testing.T) {
+	// Request ids of the same time must still be unique and increasing.
+	now := UTCNow()
+	ids := make(map[string]struct{})
+	var last string
+	for i := 0; i < 100; i++ {
+		id := mustGetRequestID(now)
+		if _, ok := ids[id]; ok {
+			t.Fatalf("Request id %s was returned twice", id)
+		}
+		if id <= last {
+			t.Fatalf("Request id %s is not greater than the previous request id %s", id, last)
+		}
+		ids[id] = struct{}{}
+		last = id
+	}
+}
+
+func TestGetRequestHostID(t *testing.T) {
+	id1, id2 := mustGetRequestID(UTCNow()), mustGetRequestID(UTCNow())
+	hostID := getRequestHostID(id1)
+	if _, err := base64.StdEncoding.DecodeString(hostID); err != nil {
+		t.Fatalf("Host id %s is not base64 encoded: %v", hostID, err)
+	}
+	if hostID != getRequestHostID(id1) {
+		t.Fatal("Host id of the same request id changed")
+	}
+	if hostID == getRequestHostID(id2) {
+		t.Fatal("Host ids of different request ids are equal")
+	}
+}
@@ -103,6 +103,49 @@ func getListObjectsV2Args(values url.Values) (prefix, token, startAfter, delimit
 	return
 }
 
+// Parse service url queries for ListBuckets.
+func getListBucketsArgs(values url.Values) (opts ListBucketsOptions, errCode APIErrorCode) {
+	errCode = ErrNone
+
+	// The continuation-token cannot be empty.
+	if val, ok := values["continuation-token"]; ok {
+		if len(val[0]) == 0 {
+			errCode = ErrIncorrectContinuationToken
+			return
+		}
+	}
+
+	if values.Get("max-buckets") != "" {
+		var err error
+		if opts.MaxBuckets, err = strconv.Atoi(values.Get("max-buckets")); err != nil ||
+			opts.MaxBuckets < 1 || opts.MaxBuckets > maxBucketsList {
+			errCode = ErrInvalidMaxBuckets
+			return
+		}
+	}
+
+	switch values.Get("sort-by") {
+	case "", "name":
+	case "creation-date":
+		opts.SortByCreated = true
+	default:
+		errCode = ErrInvalidBucketSort
+		return
+	}
+
+	opts.Prefix = values.Get("prefix")
+
+	if token := values.Get("continuation-token"); token != "" {
+		decodedToken, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			errCode = ErrIncorrectContinuationToken
+			return
+		}
+		opts.ContinuationToken = string(decodedToken)
+	}
+	return
+}
+
 // Parse bucket url queries for ?uploads
 func getBucketMultipartResources(values url.Values) (prefix, keyMarker, uploadIDMarker, delimiter string, maxUploads int, encodingType string, errCode APIErrorCode) {
 	errCode = ErrNone
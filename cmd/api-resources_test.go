@@ -219,3 +219,64 @@ func TestGetObjectsResources(t *testing.T) {
 		}
 	}
 }
+
+// Test list buckets resources.
+func TestListBucketsResources(t *testing.T) {
+	testCases := []struct {
+		values  url.Values
+		opts    ListBucketsOptions
+		errCode APIErrorCode
+	}{
+		{
+			values: url.Values{},
+			opts:   ListBucketsOptions{},
+		},
+		{
+			values: url.Values{
+				"prefix":             []string{"photos"},
+				"continuation-token": []string{"dG9rZW4="},
+				"max-buckets":        []string{"100"},
+				"sort-by":            []string{"creation-date"},
+			},
+			opts: ListBucketsOptions{Prefix: "photos", ContinuationToken: "token", MaxBuckets: 100, SortByCreated: true},
+		},
+		{
+			values: url.Values{"sort-by": []string{"name"}},
+			opts:   ListBucketsOptions{},
+		},
+		{
+			values:  url.Values{"continuation-token": []string{""}},
+			errCode: ErrIncorrectContinuationToken,
+		},
+		{
+			values:  url.Values{"continuation-token": []string{"%%%"}},
+			errCode: ErrIncorrectContinuationToken,
+		},
+		{
+			values:  url.Values{"max-buckets": []string{"0"}},
+			errCode: ErrInvalidMaxBuckets,
+		},
+		{
+			values:  url.Values{"max-buckets": []string{"10001"}},
+			errCode: ErrInvalidMaxBuckets,
+		},
+		{
+			values:  url.Values{"max-buckets": []string{"ten"}},
+			errCode: ErrInvalidMaxBuckets,
+		},
+		{
+			values:  url.Values{"sort-by": []string{"size"}},
+			errCode: ErrInvalidBucketSort,
+		},
+	}
+
+	for i, testCase := range testCases {
+		opts, errCode := getListBucketsArgs(testCase.values)
+		if errCode != testCase.errCode {
+			t.Errorf("Test %d: Expected error code:%d, got %d", i+1, testCase.errCode, errCode)
+		}
+		if errCode == ErrNone && opts != testCase.opts {
+			t.Errorf("Test %d: Expected %+v, got %+v", i+1, testCase.opts, opts)
+		}
+	}
+}
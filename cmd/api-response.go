@@ -36,7 +36,9 @@ const (
 	timeFormatAMZLong = "2006-01-02T15:04:05.000Z" // Reply date format with nanosecond precision.
 	maxObjectList     = 10000                      // Limit number of objects in a listObjectsResponse.
 	maxUploadsList    = 10000                      // Limit number of uploads in a listUploadsResponse.
+	maxBucketsList    = 10000                      // Limit number of buckets in a listBucketsResponse.
 	maxPartsList      = 10000                      // Limit number of parts in a listPartsResponse.
+	maxDeleteList     = 1000                       // Limit number of objects deleted by a DeleteObjects request.
 )
 
 // LocationResponse - format for location response.
@@ -151,6 +153,7 @@ type Part struct {
 	LastModified string
 	ETag         string
 	Size         int64
+	Checksums
 }
 
 // ListPartsResponse - format for list parts response.
@@ -208,6 +211,10 @@ type ListBucketsResponse struct {
 	Buckets struct {
 		Buckets []Bucket `xml:"Bucket"`
 	} // Buckets are nested
+
+	// Token to list the next page of buckets, only set if the listing is truncated.
+	ContinuationToken string `xml:",omitempty"`
+	Prefix            string `xml:",omitempty"`
 }
 
 // Upload container for in progress multipart upload
@@ -229,6 +236,8 @@ type CommonPrefix struct {
 type Bucket struct {
 	Name         string
 	CreationDate string // time string of format "2006-01-02T15:04:05.000Z"
+	// Hash is the CID of the current root of buckets stored on IPFS
+	Hash string `xml:",omitempty"`
 }
 
 // ObjectVersion container for object version metadata
@@ -327,13 +336,15 @@ type CompleteMultipartUploadResponse struct {
 	Bucket   string
 	Key      string
 	ETag     string
+	Checksums
 }
 
 // DeleteError structure.
 type DeleteError struct {
-	Code    string
-	Message string
-	Key     string
+	Code      string
+	Message   string
+	Key       string
+	VersionID string `xml:"VersionId,omitempty"`
 }
 
 // DeleteObjectsResponse container for multiple object deletes.
@@ -391,21 +402,27 @@ func getObjectLocation(r *http.Request, domains []string, bucket, object string)
 
 // generates ListBucketsResponse from array of BucketInfo which can be
 // serialized to match XML and JSON API spec output.
-func generateListBucketsResponse(buckets []BucketInfo) ListBucketsResponse {
+func generateListBucketsResponse(buckets []BucketInfo, ownerName, prefix, nextToken string) ListBucketsResponse {
 	var listbuckets []Bucket
 	var data = ListBucketsResponse{}
 	var owner = Owner{}
 
 	owner.ID = globalMinioDefaultOwnerID
+	owner.DisplayName = ownerName
 	for _, bucket := range buckets {
 		var listbucket = Bucket{}
 		listbucket.Name = bucket.Name
 		listbucket.CreationDate = bucket.Created.UTC().Format(timeFormatAMZLong)
+		listbucket.Hash = bucket.Hash
 		listbuckets = append(listbuckets, listbucket)
 	}
 
 	data.Owner = owner
 	data.Buckets.Buckets = listbuckets
+	data.Prefix = prefix
+	if nextToken != "" {
+		data.ContinuationToken = base64.StdEncoding.EncodeToString([]byte(nextToken))
+	}
 
 	return data
 }
@@ -582,14 +599,19 @@ func generateInitiateMultipartUploadResponse(bucket, key, uploadID string) Initi
 	}
 }
 
-// generates CompleteMultipartUploadResponse for given bucket, key, location and ETag.
-func generateCompleteMultpartUploadResponse(bucket, key, location, etag string) CompleteMultipartUploadResponse {
-	return CompleteMultipartUploadResponse{
+// generates CompleteMultipartUploadResponse for given bucket, key, location, ETag
+// and the checksum in the metadata of the completed object.
+func generateCompleteMultpartUploadResponse(bucket, key, location, etag string, userDefined map[string]string) CompleteMultipartUploadResponse {
+	response := CompleteMultipartUploadResponse{
 		Location: location,
 		Bucket:   bucket,
 		Key:      key,
 		ETag:     etag,
 	}
+	for algorithm, checksum := range objectChecksums(userDefined) {
+		response.set(algorithm, checksum)
+	}
+	return response
 }
 
 // generates ListPartsResponse from ListPartsInfo.
@@ -607,6 +629,8 @@ func generateListPartsResponse(partsInfo ListPartsInfo, encodingType string) Lis
 	listPartsResponse.IsTruncated = partsInfo.IsTruncated
 	listPartsResponse.NextPartNumberMarker = partsInfo.NextPartNumberMarker
 
+	// The checksums of the parts are of the algorithm of the upload.
+	algorithm := partsInfo.UserDefined[strings.ToLower(xhttp.AmzChecksumAlgorithm)]
 	listPartsResponse.Parts = make([]Part, len(partsInfo.Parts))
 	for index, part := range partsInfo.Parts {
 		newPart := Part{}
@@ -614,6 +638,7 @@ func generateListPartsResponse(partsInfo ListPartsInfo, encodingType string) Lis
 		newPart.ETag = "\"" + part.ETag + "\""
 		newPart.Size = part.Size
 		newPart.LastModified = part.LastModified.UTC().Format(timeFormatAMZLong)
+		newPart.set(algorithm, part.Checksum)
 		listPartsResponse.Parts[index] = newPart
 	}
 	return listPartsResponse
@@ -730,7 +755,7 @@ func writeErrorResponse(ctx context.Context, w http.ResponseWriter, err APIError
 
 	// Generate error response.
 	errorResponse := getAPIErrorResponse(ctx, err, reqURL.Path,
-		w.Header().Get(xhttp.AmzRequestID), globalDeploymentID)
+		w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID))
 	encodedErrorResponse := encodeResponse(errorResponse)
 	writeResponse(w, err.HTTPStatusCode, encodedErrorResponse, mimeXML)
 }
@@ -748,7 +773,7 @@ func writeErrorResponseString(ctx context.Context, w http.ResponseWriter, err AP
 // useful for admin APIs.
 func writeErrorResponseJSON(ctx context.Context, w http.ResponseWriter, err APIError, reqURL *url.URL) {
 	// Generate error response.
-	errorResponse := getAPIErrorResponse(ctx, err, reqURL.Path, w.Header().Get(xhttp.AmzRequestID), globalDeploymentID)
+	errorResponse := getAPIErrorResponse(ctx, err, reqURL.Path, w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID))
 	encodedErrorResponse := encodeResponseJSON(errorResponse)
 	writeResponse(w, err.HTTPStatusCode, encodedErrorResponse, mimeJSON)
 }
@@ -757,7 +782,7 @@ func writeErrorResponseJSON(ctx context.Context, w http.ResponseWriter, err APIE
 func writeVersionMismatchResponse(ctx context.Context, w http.ResponseWriter, err APIError, reqURL *url.URL, isJSON bool) {
 	if isJSON {
 		// Generate error response.
-		errorResponse := getAPIErrorResponse(ctx, err, reqURL.String(), w.Header().Get(xhttp.AmzRequestID), globalDeploymentID)
+		errorResponse := getAPIErrorResponse(ctx, err, reqURL.String(), w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID))
 		writeResponse(w, err.HTTPStatusCode, encodeResponseJSON(errorResponse), mimeJSON)
 	} else {
 		writeResponse(w, err.HTTPStatusCode, []byte(err.Description), mimeNone)
@@ -778,7 +803,7 @@ func writeCustomErrorResponseJSON(ctx context.Context, w http.ResponseWriter, er
 		BucketName: reqInfo.BucketName,
 		Key:        reqInfo.ObjectName,
 		RequestID:  w.Header().Get(xhttp.AmzRequestID),
-		HostID:     globalDeploymentID,
+		HostID:     w.Header().Get(xhttp.AmzRequestHostID),
 	}
 	encodedErrorResponse := encodeResponseJSON(errorResponse)
 	writeResponse(w, err.HTTPStatusCode, encodedErrorResponse, mimeJSON)
@@ -812,7 +837,7 @@ func writeCustomErrorResponseXML(ctx context.Context, w http.ResponseWriter, err
 		BucketName: reqInfo.BucketName,
 		Key:        reqInfo.ObjectName,
 		RequestID:  w.Header().Get(xhttp.AmzRequestID),
-		HostID:     globalDeploymentID,
+		HostID:     w.Header().Get(xhttp.AmzRequestHostID),
 	}
 
 	encodedErrorResponse := encodeResponse(errorResponse)
@@ -17,8 +17,11 @@
 package cmd
 
 import (
+	"encoding/xml"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Tests object location.
@@ -119,3 +122,24 @@ func TestGetURLScheme(t *testing.T) {
 		t.Errorf("Expected %s, got %s", httpsScheme, gotScheme)
 	}
 }
+
+// Tests the bucket hashes of ListBuckets responses.
+func TestListBucketsResponseHash(t *testing.T) {
+	created := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	buckets := []BucketInfo{
+		{Name: "ipfs-bucket", Created: created, Hash: "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"},
+		{Name: "disk-bucket", Created: created},
+	}
+	data, err := xml.Marshal(generateListBucketsResponse(buckets, "owner", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := string(data)
+	if !strings.Contains(response, "<Name>ipfs-bucket</Name><CreationDate>2020-03-01T12:00:00.000Z</CreationDate>"+
+		"<Hash>bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi</Hash>") {
+		t.Errorf("Expected the hash of ipfs-bucket, got %s", response)
+	}
+	if strings.Count(response, "<Hash>") != 1 {
+		t.Errorf("Expected no hash for disk-bucket, got %s", response)
+	}
+}
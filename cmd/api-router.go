@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"net"
 	"net/http"
 
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
@@ -82,8 +83,8 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 	apiRouter := router.PathPrefix(SlashSeparator).Subrouter()
 	var routers []*mux.Router
 	for _, domainName := range globalDomainNames {
-		routers = append(routers, apiRouter.Host("{bucket:.+}."+domainName).Subrouter())
-		routers = append(routers, apiRouter.Host("{bucket:.+}."+domainName+":{port:.*}").Subrouter())
+		routers = append(routers, apiRouter.Host("{bucket:.+}."+domainName).MatcherFunc(notDomainHost).Subrouter())
+		routers = append(routers, apiRouter.Host("{bucket:.+}."+domainName+":{port:.*}").MatcherFunc(notDomainHost).Subrouter())
 	}
 	routers = append(routers, apiRouter.PathPrefix("/{bucket}").Subrouter())
 
@@ -119,6 +120,8 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 		bucket.Methods(http.MethodGet).Path("/{object:.+}").HandlerFunc(collectAPIStats("getobjectretention", httpTraceAll(api.GetObjectRetentionHandler))).Queries("retention", "")
 		// GetObjectLegalHold
 		bucket.Methods(http.MethodGet).Path("/{object:.+}").HandlerFunc(collectAPIStats("getobjectlegalhold", httpTraceAll(api.GetObjectLegalHoldHandler))).Queries("legal-hold", "")
+		// GetObjectAttributes
+		bucket.Methods(http.MethodGet).Path("/{object:.+}").HandlerFunc(collectAPIStats("getobjectattributes", httpTraceHdrs(api.GetObjectAttributesHandler))).Queries("attributes", "")
 		// GetObject
 		bucket.Methods(http.MethodGet).Path("/{object:.+}").HandlerFunc(collectAPIStats("getobject", httpTraceHdrs(api.GetObjectHandler)))
 		// CopyObject
@@ -142,6 +145,12 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 		bucket.Methods(http.MethodGet).HandlerFunc(collectAPIStats("getbucketlifecycle", httpTraceAll(api.GetBucketLifecycleHandler))).Queries("lifecycle", "")
 		// GetBucketEncryption
 		bucket.Methods(http.MethodGet).HandlerFunc(collectAPIStats("getbucketencryption", httpTraceAll(api.GetBucketEncryptionHandler))).Queries("encryption", "")
+		// GetBucketPublicAccessBlock
+		bucket.Methods(http.MethodGet).HandlerFunc(collectAPIStats("getbucketpublicaccessblock", httpTraceAll(api.GetBucketPublicAccessBlockHandler))).Queries("publicAccessBlock", "")
+		// GetBucketMetricsConfiguration
+		bucket.Methods(http.MethodGet).HandlerFunc(collectAPIStats("getbucketmetricsconfiguration", httpTraceAll(api.GetBucketMetricsConfigurationHandler))).Queries("metrics", "", "id", "{id:.*}")
+		// ListBucketMetricsConfigurations
+		bucket.Methods(http.MethodGet).HandlerFunc(collectAPIStats("listbucketmetricsconfigurations", httpTraceAll(api.ListBucketMetricsConfigurationsHandler))).Queries("metrics", "")
 
 		// Dummy Bucket Calls
 		// GetBucketACL -- this is a dummy call.
@@ -191,6 +200,10 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 		bucket.Methods(http.MethodPut).HandlerFunc(collectAPIStats("putbucketlifecycle", httpTraceAll(api.PutBucketLifecycleHandler))).Queries("lifecycle", "")
 		// PutBucketEncryption
 		bucket.Methods(http.MethodPut).HandlerFunc(collectAPIStats("putbucketencryption", httpTraceAll(api.PutBucketEncryptionHandler))).Queries("encryption", "")
+		// PutBucketPublicAccessBlock
+		bucket.Methods(http.MethodPut).HandlerFunc(collectAPIStats("putbucketpublicaccessblock", httpTraceAll(api.PutBucketPublicAccessBlockHandler))).Queries("publicAccessBlock", "")
+		// PutBucketMetricsConfiguration
+		bucket.Methods(http.MethodPut).HandlerFunc(collectAPIStats("putbucketmetricsconfiguration", httpTraceAll(api.PutBucketMetricsConfigurationHandler))).Queries("metrics", "", "id", "{id:.*}")
 
 		// PutBucketPolicy
 		bucket.Methods(http.MethodPut).HandlerFunc(collectAPIStats("putbucketpolicy", httpTraceAll(api.PutBucketPolicyHandler))).Queries("policy", "")
@@ -215,6 +228,10 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 		bucket.Methods(http.MethodDelete).HandlerFunc(collectAPIStats("deletebucketlifecycle", httpTraceAll(api.DeleteBucketLifecycleHandler))).Queries("lifecycle", "")
 		// DeleteBucketEncryption
 		bucket.Methods(http.MethodDelete).HandlerFunc(collectAPIStats("deletebucketencryption", httpTraceAll(api.DeleteBucketEncryptionHandler))).Queries("encryption", "")
+		// DeleteBucketPublicAccessBlock
+		bucket.Methods(http.MethodDelete).HandlerFunc(collectAPIStats("deletebucketpublicaccessblock", httpTraceAll(api.DeleteBucketPublicAccessBlockHandler))).Queries("publicAccessBlock", "")
+		// DeleteBucketMetricsConfiguration
+		bucket.Methods(http.MethodDelete).HandlerFunc(collectAPIStats("deletebucketmetricsconfiguration", httpTraceAll(api.DeleteBucketMetricsConfigurationHandler))).Queries("metrics", "", "id", "{id:.*}")
 		// DeleteBucket
 		bucket.Methods(http.MethodDelete).HandlerFunc(collectAPIStats("deletebucket", httpTraceAll(api.DeleteBucketHandler)))
 	}
@@ -229,3 +246,19 @@ func registerAPIRouter(router *mux.Router, encryptionEnabled, allowSSEKMS bool)
 	apiRouter.MethodNotAllowedHandler = http.HandlerFunc(collectAPIStats("methodnotallowed", httpTraceAll(errorResponseHandler)))
 
 }
+
+// notDomainHost matches the requests to hosts other than the domains,
+// so a request to a domain that is a subdomain of another domain is
+// path style rather than a request to a bucket of the other domain.
+func notDomainHost(r *http.Request, _ *mux.RouteMatch) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, domainName := range globalDomainNames {
+		if host == domainName {
+			return false
+		}
+	}
+	return true
+}
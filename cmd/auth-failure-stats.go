@@ -0,0 +1,89 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+)
+
+var authFailuresDesc = prometheus.NewDesc(
+	prometheus.BuildFQName("s3", "auth", "failures_total"),
+	"Total number of requests that failed signature verification, by the S3 error code returned to the client",
+	[]string{"reason"}, nil)
+
+// authFailureStats - counts the requests that failed signature verification by the S3 error
+// code of the failure, such as SignatureDoesNotMatch or RequestTimeTooSkewed, which tells
+// misconfigured clients apart.
+type authFailureStats struct {
+	sync.RWMutex
+	failures map[string]*atomic.Uint64
+}
+
+func newAuthFailureStats() *authFailureStats {
+	return &authFailureStats{
+		failures: make(map[string]*atomic.Uint64),
+	}
+}
+
+// record - counts a failure with errCode, and returns errCode. ErrNone is not counted.
+func (s *authFailureStats) record(errCode APIErrorCode) APIErrorCode {
+	if errCode == ErrNone {
+		return errCode
+	}
+	reason := errorCodes.ToAPIErr(errCode).Code
+	s.RLock()
+	counter, ok := s.failures[reason]
+	s.RUnlock()
+	if !ok {
+		s.Lock()
+		if counter, ok = s.failures[reason]; !ok {
+			counter = atomic.NewUint64(0)
+			s.failures[reason] = counter
+		}
+		s.Unlock()
+	}
+	counter.Inc()
+	return errCode
+}
+
+// count - returns the number of failures with the S3 error code reason
+func (s *authFailureStats) count(reason string) uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	if counter, ok := s.failures[reason]; ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// Describe - sends the descriptor of the auth failure counters
+func (s *authFailureStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- authFailuresDesc
+}
+
+// Collect - sends the auth failure counter of every reason
+func (s *authFailureStats) Collect(ch chan<- prometheus.Metric) {
+	s.RLock()
+	defer s.RUnlock()
+	for reason, counter := range s.failures {
+		ch <- prometheus.MustNewConstMetric(authFailuresDesc, prometheus.CounterValue,
+			float64(counter.Load()), reason)
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/RTradeLtd/s3x/cmd/logger"
 	"github.com/RTradeLtd/s3x/pkg/auth"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/RTradeLtd/s3x/pkg/handlers"
 	"github.com/RTradeLtd/s3x/pkg/hash"
 	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
 )
@@ -129,7 +130,7 @@ func checkAdminRequestAuthType(ctx context.Context, r *http.Request, action iamp
 		// We only support admin credentials to access admin APIs.
 		cred, owner, s3Err = getReqAccessKeyV4(r, region, serviceS3)
 		if s3Err != ErrNone {
-			return cred, s3Err
+			return cred, globalAuthFailureStats.record(s3Err)
 		}
 
 		// we only support V4 (no presign) with auth body
@@ -147,6 +148,11 @@ func checkAdminRequestAuthType(ctx context.Context, r *http.Request, action iamp
 		return cred, s3Err
 	}
 
+	// Admin actions can not be allowed by bucket policies.
+	if owner && !ownerBypassesPolicies(r) {
+		return cred, ErrAccessDenied
+	}
+
 	if globalIAMSys.IsAllowed(iampolicy.Args{
 		AccountName:     cred.AccessKey,
 		Action:          iampolicy.Action(action),
@@ -179,9 +185,13 @@ func mustGetClaimsFromToken(r *http.Request) map[string]interface{} {
 
 // Fetch claims in the security token returned by the client.
 func getClaimsFromToken(r *http.Request) (map[string]interface{}, error) {
+	return parseClaimsFromToken(getSessionToken(r))
+}
+
+// Fetch claims in a security token.
+func parseClaimsFromToken(token string) (map[string]interface{}, error) {
 	claims := xjwt.NewMapClaims()
 
-	token := getSessionToken(r)
 	if token == "" {
 		return claims.Map(), nil
 	}
@@ -251,6 +261,20 @@ func checkClaimsFromToken(r *http.Request, cred auth.Credentials) (map[string]in
 	return claims, ErrNone
 }
 
+// ownerBypassesPolicies returns true if a request of the root credential is allowed without policies, which is
+// false if the object layer denies the root credential by default and the request does not carry its break-glass key.
+func ownerBypassesPolicies(r *http.Request) bool {
+	restricter, ok := unwrapObjectLayer(newObjectLayerFn()).(OwnerRestricter)
+	if !ok || !restricter.DenyOwnerByDefault() {
+		return true
+	}
+	if !restricter.IsBreakGlassKey(r.Header.Get(xhttp.S3xBreakGlass)) {
+		return false
+	}
+	logger.Info("Break-glass key used for %s %s from %s", r.Method, r.URL.Path, handlers.GetSourceIP(r))
+	return true
+}
+
 // Check request auth type verifies the incoming http request
 // - validates the request signature
 // - validates the policy action if anonymous tests bucket policies if any,
@@ -278,7 +302,7 @@ func checkRequestAuthTypeToAccessKey(ctx context.Context, r *http.Request, actio
 		}
 		cred, owner, s3Err = getReqAccessKeyV2(r)
 	case authTypeSigned, authTypePresigned:
-		region := globalServerRegion
+		region := authRegion()
 		switch action {
 		case policy.GetBucketLocationAction, policy.ListAllMyBucketsAction:
 			region = ""
@@ -335,6 +359,19 @@ func checkRequestAuthTypeToAccessKey(ctx context.Context, r *http.Request, actio
 		}
 		return accessKey, owner, ErrAccessDenied
 	}
+	if owner && !ownerBypassesPolicies(r) {
+		if globalPolicySys.IsAllowed(policy.Args{
+			AccountName:     cred.AccessKey,
+			Action:          action,
+			BucketName:      bucketName,
+			ConditionValues: getConditionValues(r, locationConstraint, cred.AccessKey, claims),
+			IsOwner:         false,
+			ObjectName:      objectName,
+		}) {
+			return cred.AccessKey, owner, ErrNone
+		}
+		return accessKey, owner, ErrAccessDenied
+	}
 	if globalIAMSys.IsAllowed(iampolicy.Args{
 		AccountName:     cred.AccessKey,
 		Action:          iampolicy.Action(action),
@@ -353,18 +390,18 @@ func checkRequestAuthTypeToAccessKey(ctx context.Context, r *http.Request, actio
 // Verify if request has valid AWS Signature Version '2'.
 func isReqAuthenticatedV2(r *http.Request) (s3Error APIErrorCode) {
 	if isRequestSignatureV2(r) {
-		return doesSignV2Match(r)
+		return globalAuthFailureStats.record(doesSignV2Match(r))
 	}
-	return doesPresignV2SignatureMatch(r)
+	return globalAuthFailureStats.record(doesPresignV2SignatureMatch(r))
 }
 
 func reqSignatureV4Verify(r *http.Request, region string, stype serviceType) (s3Error APIErrorCode) {
 	sha256sum := getContentSha256Cksum(r, stype)
 	switch {
 	case isRequestSignatureV4(r):
-		return doesSignatureMatch(sha256sum, r, region, stype)
+		return globalAuthFailureStats.record(doesSignatureMatch(sha256sum, r, region, stype))
 	case isRequestPresignedSignatureV4(r):
-		return doesPresignedSignatureMatch(sha256sum, r, region, stype)
+		return globalAuthFailureStats.record(doesPresignedSignatureMatch(sha256sum, r, region, stype))
 	default:
 		return ErrAccessDenied
 	}
@@ -444,6 +481,10 @@ func isSupportedS3AuthType(aType authType) bool {
 // handler for validating incoming authorization headers.
 func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	aType := getRequestAuthType(r)
+	if (aType == authTypeSignedV2 || aType == authTypePresignedV2) && !isSignatureV2Allowed() {
+		writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(globalAuthFailureStats.record(ErrSignatureVersionNotSupported)), r.URL, guessIsBrowserReq(r))
+		return
+	}
 	if isSupportedS3AuthType(aType) {
 		// Let top level caller validate for anonymous and known signed requests.
 		a.handler.ServeHTTP(w, r)
@@ -460,7 +501,7 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		a.handler.ServeHTTP(w, r)
 		return
 	}
-	writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(ErrSignatureVersionNotSupported), r.URL, guessIsBrowserReq(r))
+	writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(globalAuthFailureStats.record(ErrSignatureVersionNotSupported)), r.URL, guessIsBrowserReq(r))
 }
 
 // isPutActionAllowed - check if PUT operation is allowed on the resource, this
@@ -475,7 +516,7 @@ func isPutActionAllowed(atype authType, bucketName, objectName string, r *http.R
 	case authTypeSignedV2, authTypePresignedV2:
 		cred, owner, s3Err = getReqAccessKeyV2(r)
 	case authTypeStreamingSigned, authTypePresigned, authTypeSigned:
-		region := globalServerRegion
+		region := authRegion()
 		cred, owner, s3Err = getReqAccessKeyV4(r, region, serviceS3)
 	}
 	if s3Err != ErrNone {
@@ -501,6 +542,20 @@ func isPutActionAllowed(atype authType, bucketName, objectName string, r *http.R
 		return ErrAccessDenied
 	}
 
+	if owner && !ownerBypassesPolicies(r) {
+		if globalPolicySys.IsAllowed(policy.Args{
+			AccountName:     cred.AccessKey,
+			Action:          policy.Action(action),
+			BucketName:      bucketName,
+			ConditionValues: getConditionValues(r, "", cred.AccessKey, claims),
+			IsOwner:         false,
+			ObjectName:      objectName,
+		}) {
+			return ErrNone
+		}
+		return ErrAccessDenied
+	}
+
 	if globalIAMSys.IsAllowed(iampolicy.Args{
 		AccountName:     cred.AccessKey,
 		Action:          action,
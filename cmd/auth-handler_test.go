@@ -22,11 +22,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"testing"
 	"time"
 
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/pkg/auth"
 	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
 )
@@ -425,3 +427,137 @@ func TestCheckAdminRequestAuthType(t *testing.T) {
 		}
 	}
 }
+
+// ownerRestrictedObjects denies the requests of the owner by default
+type ownerRestrictedObjects struct {
+	ObjectLayer
+	breakGlassKey string
+}
+
+func (o ownerRestrictedObjects) DenyOwnerByDefault() bool {
+	return true
+}
+
+func (o ownerRestrictedObjects) IsBreakGlassKey(key string) bool {
+	return key != "" && key == o.breakGlassKey
+}
+
+func TestOwnerBypassesPolicies(t *testing.T) {
+	objLayer, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(fsDir)
+
+	if err = newTestConfig(globalMinioDefaultRegion, objLayer); err != nil {
+		t.Fatalf("unable initialize config file, %s", err)
+	}
+
+	setObjectLayer := func(o ObjectLayer) {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = o
+		globalObjLayerMutex.Unlock()
+	}
+	setObjectLayer(objLayer)
+	defer setObjectLayer(nil)
+
+	req := mustNewSignedRequest("GET", "http://127.0.0.1:9000", 0, nil, t)
+	if !ownerBypassesPolicies(req) {
+		t.Fatal("expected the owner to bypass policies")
+	}
+
+	setObjectLayer(ownerRestrictedObjects{ObjectLayer: objLayer, breakGlassKey: "break-glass-key"})
+	if ownerBypassesPolicies(req) {
+		t.Fatal("expected the owner to not bypass policies")
+	}
+	ctx := context.Background()
+	if _, s3Error := checkAdminRequestAuthType(ctx, req, iampolicy.AllAdminActions, globalServerRegion); s3Error != ErrAccessDenied {
+		t.Fatalf("expected admin requests of the owner to be denied, got %d", s3Error)
+	}
+
+	req.Header.Set(xhttp.S3xBreakGlass, "wrong-key")
+	if ownerBypassesPolicies(req) {
+		t.Fatal("expected the owner to not bypass policies with the wrong key")
+	}
+	req.Header.Set(xhttp.S3xBreakGlass, "break-glass-key")
+	if !ownerBypassesPolicies(req) {
+		t.Fatal("expected the owner to bypass policies with the break-glass key")
+	}
+
+	// gateways are served wrapped with a locker, which must not hide the restriction
+	setObjectLayer(NewGatewayLayerWithLocker(ownerRestrictedObjects{ObjectLayer: objLayer, breakGlassKey: "break-glass-key"}))
+	req.Header.Del(xhttp.S3xBreakGlass)
+	if ownerBypassesPolicies(req) {
+		t.Fatal("expected the owner of a gateway layer with a locker to not bypass policies")
+	}
+	if _, s3Error := checkAdminRequestAuthType(ctx, req, iampolicy.AllAdminActions, globalServerRegion); s3Error != ErrAccessDenied {
+		t.Fatalf("expected admin requests of the owner of a gateway layer with a locker to be denied, got %d", s3Error)
+	}
+}
+
+// hardenedAuthObjects hardens the verification of signed requests
+type hardenedAuthObjects struct {
+	ObjectLayer
+}
+
+func (o hardenedAuthObjects) MaxClockSkew() time.Duration {
+	return time.Minute
+}
+
+func (o hardenedAuthObjects) RequireSignatureV4() bool {
+	return true
+}
+
+func (o hardenedAuthObjects) AuthRegion() string {
+	return "eu-west-1"
+}
+
+func TestRequestAuthConfigurer(t *testing.T) {
+	objLayer, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(fsDir)
+
+	if err = newTestConfig(globalMinioDefaultRegion, objLayer); err != nil {
+		t.Fatalf("unable initialize config file, %s", err)
+	}
+
+	setObjectLayer := func(o ObjectLayer) {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = o
+		globalObjLayerMutex.Unlock()
+	}
+	setObjectLayer(objLayer)
+	defer setObjectLayer(nil)
+
+	if maxSkewTime() != globalMaxSkewTime || authRegion() != globalServerRegion || !isSignatureV2Allowed() {
+		t.Fatal("expected the default request authentication")
+	}
+
+	setObjectLayer(hardenedAuthObjects{ObjectLayer: objLayer})
+	if maxSkewTime() != time.Minute || authRegion() != "eu-west-1" || isSignatureV2Allowed() {
+		t.Fatal("expected the request authentication of the object layer")
+	}
+
+	ctx := context.Background()
+	failures := globalAuthFailureStats.count("AuthorizationHeaderMalformed")
+	req := mustNewSignedRequest("GET", "http://127.0.0.1:9000", 0, nil, t)
+	if s3Error := isReqAuthenticated(ctx, req, authRegion(), serviceS3); s3Error != ErrAuthorizationHeaderMalformed {
+		t.Fatalf("expected requests scoped to another region to be denied, got %d", s3Error)
+	}
+	if globalAuthFailureStats.count("AuthorizationHeaderMalformed") != failures+1 {
+		t.Fatal("expected the auth failure to be counted")
+	}
+
+	failures = globalAuthFailureStats.count("InvalidRequest")
+	handler := setAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, mustNewSignedV2Request("GET", "http://127.0.0.1:9000", 0, nil, t))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected requests signed with signature version 2 to be denied, got %d", rec.Code)
+	}
+	if globalAuthFailureStats.count("InvalidRequest") != failures+1 {
+		t.Fatal("expected the auth failure to be counted")
+	}
+}
@@ -0,0 +1,223 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/minio/cli"
+	minio "github.com/minio/minio-go/v6"
+)
+
+var benchFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "endpoint",
+		Value: "http://127.0.0.1:9000",
+		Usage: "url of the S3 endpoint to benchmark",
+	},
+	cli.StringFlag{
+		Name:   "access-key",
+		EnvVar: "MINIO_ACCESS_KEY",
+		Usage:  "access key of the S3 endpoint",
+	},
+	cli.StringFlag{
+		Name:   "secret-key",
+		EnvVar: "MINIO_SECRET_KEY",
+		Usage:  "secret key of the S3 endpoint",
+	},
+	cli.BoolFlag{
+		Name:  "insecure",
+		Usage: "do not verify the TLS certificate of the S3 endpoint",
+	},
+	cli.StringFlag{
+		Name:  "bucket",
+		Value: "s3x-bench",
+		Usage: "bucket of the benchmark, created and removed by the benchmark if it does not exist",
+	},
+	cli.DurationFlag{
+		Name:  "duration",
+		Value: time.Minute,
+		Usage: "duration of the benchmark",
+	},
+	cli.IntFlag{
+		Name:  "concurrency",
+		Value: 16,
+		Usage: "number of operations run at the same time",
+	},
+	cli.StringFlag{
+		Name:  "obj.size",
+		Value: "1MiB",
+		Usage: "size of the uploaded objects",
+	},
+	cli.IntFlag{
+		Name:  "objects",
+		Value: 100,
+		Usage: "number of objects uploaded before the benchmark for GET and DELETE operations",
+	},
+	cli.StringFlag{
+		Name:  "mix",
+		Value: "put=30,get=50,list=10,delete=10",
+		Usage: "relative weights of the put, get, list, and delete operations",
+	},
+	cli.BoolFlag{
+		Name:  "keep",
+		Usage: "keep the objects of the benchmark",
+	},
+	cli.Int64Flag{
+		Name:  "seed",
+		Usage: "seed of the random operations and data, the current time if 0",
+	},
+	cli.BoolFlag{
+		Name:  "json",
+		Usage: "print the report as json",
+	},
+}
+
+var benchCmd = cli.Command{
+	Name:   "bench",
+	Usage:  "benchmark a running object storage server or gateway",
+	Flags:  benchFlags,
+	Action: benchMain,
+	CustomHelpTemplate: `NAME:
+  {{.HelpName}} - {{.Usage}}
+
+USAGE:
+  {{.HelpName}} {{if .VisibleFlags}}[FLAGS]{{end}}
+{{if .VisibleFlags}}
+FLAGS:
+  {{range .VisibleFlags}}{{.}}
+  {{end}}{{end}}
+EXAMPLES:
+  1. Benchmark a local gateway for 5 minutes with 64 concurrent operations.
+     {{.Prompt}} {{.EnvVarSetCommand}} MINIO_ACCESS_KEY{{.AssignmentOperator}}minio
+     {{.Prompt}} {{.EnvVarSetCommand}} MINIO_SECRET_KEY{{.AssignmentOperator}}miniostorage
+     {{.Prompt}} {{.HelpName}} --duration 5m --concurrency 64
+
+  2. Benchmark reads of 64KiB objects, and print the report as json.
+     {{.Prompt}} {{.HelpName}} --endpoint https://s3.example.com --obj.size 64KiB --mix get=90,list=10 --json
+`,
+}
+
+// newBenchClient returns a client of the S3 endpoint of a benchmark.
+func newBenchClient(ctx *cli.Context) (*minio.Client, error) {
+	endpoint, err := url.Parse(ctx.String("endpoint"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, errors.New("endpoint must be an http or https url")
+	}
+	secure := endpoint.Scheme == "https"
+	client, err := minio.New(endpoint.Host, ctx.String("access-key"), ctx.String("secret-key"), secure)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return nil, err
+	}
+	// keep a connection for every concurrent operation
+	if tr, ok := transport.(*http.Transport); ok {
+		tr.MaxIdleConnsPerHost = ctx.Int("concurrency")
+		if ctx.Bool("insecure") {
+			tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+	}
+	client.SetCustomTransport(transport)
+	return client, nil
+}
+
+func benchMain(ctx *cli.Context) {
+	if ctx.Args().Present() {
+		cli.ShowCommandHelpAndExit(ctx, "bench", 1)
+	}
+	mix, err := parseBenchMix(ctx.String("mix"))
+	logger.FatalIf(err, "Invalid operation mix")
+	size, err := humanize.ParseBytes(ctx.String("obj.size"))
+	logger.FatalIf(err, "Invalid object size")
+	client, err := newBenchClient(ctx)
+	logger.FatalIf(err, "Invalid endpoint")
+
+	seed := ctx.Int64("seed")
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	cfg := benchConfig{
+		Bucket:      ctx.String("bucket"),
+		Duration:    ctx.Duration("duration"),
+		Concurrency: ctx.Int("concurrency"),
+		ObjectSize:  int64(size),
+		Objects:     ctx.Int("objects"),
+		Mix:         mix,
+		Keep:        ctx.Bool("keep"),
+		Seed:        seed,
+	}
+
+	// an interrupted benchmark still removes its objects
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	report, err := runBench(runCtx, client, cfg)
+	logger.FatalIf(err, "Unable to run the benchmark")
+	if ctx.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		logger.FatalIf(enc.Encode(report), "Unable to print the report")
+		return
+	}
+	printBenchReport(os.Stdout, report)
+}
+
+// printBenchReport prints the throughput and latency percentiles of each operation of a benchmark as a table.
+func printBenchReport(w io.Writer, report *benchReport) {
+	fmt.Fprintf(w, "Benchmark of %s/%s for %v with %d concurrent operations and %s objects\n\n",
+		report.Endpoint, report.Bucket, report.Duration.Round(time.Millisecond), report.Concurrency,
+		humanize.IBytes(uint64(report.ObjectSize)))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Operation\tCount\tErrors\tOps/s\tThroughput/s\tMean\tp50\tp90\tp99\tMax\t")
+	for _, r := range report.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t\n",
+			r.Operation, r.Count, r.Errors, r.OpsPerSec, humanize.IBytes(uint64(r.BytesPerSec)),
+			r.MeanMillis, r.P50Millis, r.P90Millis, r.P99Millis, r.MaxMillis)
+	}
+	tw.Flush()
+	for _, r := range report.Operations {
+		if r.FirstError != "" {
+			fmt.Fprintf(w, "\nfirst %s error: %s\n", r.Operation, r.FirstError)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	minio "github.com/minio/minio-go/v6"
+)
+
+// Operations of a benchmark.
+const (
+	benchOpPut    = "PUT"
+	benchOpGet    = "GET"
+	benchOpList   = "LIST"
+	benchOpDelete = "DELETE"
+)
+
+// benchOps are the operations of a benchmark in the order they are reported.
+var benchOps = []string{benchOpPut, benchOpGet, benchOpList, benchOpDelete}
+
+// benchMix is the relative weight of each operation of a benchmark.
+type benchMix map[string]int
+
+// parseBenchMix parses a mix such as "put=30,get=50,list=10,delete=10".
+func parseBenchMix(s string) (benchMix, error) {
+	mix := make(benchMix)
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("mix entry %q must be an operation and a weight, such as get=50", part)
+		}
+		op := strings.ToUpper(strings.TrimSpace(kv[0]))
+		known := false
+		for _, o := range benchOps {
+			known = known || o == op
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown operation %q in mix, expected put, get, list, or delete", kv[0])
+		}
+		if _, ok := mix[op]; ok {
+			return nil, fmt.Errorf("operation %q is repeated in mix", kv[0])
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("weight of %q must be a positive number, got %q", kv[0], kv[1])
+		}
+		mix[op] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("mix %q has no operation with a weight", s)
+	}
+	return mix, nil
+}
+
+// pick returns the operation for n, a random number in [0, total weight).
+func (m benchMix) pick(n int) string {
+	for _, op := range benchOps {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return benchOpPut
+}
+
+// total returns the sum of the weights of the mix.
+func (m benchMix) total() (total int) {
+	for _, w := range m {
+		total += w
+	}
+	return total
+}
+
+// benchConfig configures a benchmark.
+type benchConfig struct {
+	Bucket      string
+	Duration    time.Duration
+	Concurrency int
+	ObjectSize  int64
+	// Objects is the number of objects uploaded before the benchmark, for GET and DELETE operations.
+	Objects int
+	Mix     benchMix
+	// Keep keeps the objects of the benchmark, which are removed with the bucket if it was created by the benchmark.
+	Keep bool
+	Seed int64
+}
+
+// benchOpResult is the result of one operation type of a benchmark.
+type benchOpResult struct {
+	Operation   string  `json:"operation"`
+	Count       int     `json:"count"`
+	Errors      int     `json:"errors"`
+	OpsPerSec   float64 `json:"opsPerSec"`
+	BytesPerSec float64 `json:"bytesPerSec"`
+	MeanMillis  float64 `json:"meanMillis"`
+	P50Millis   float64 `json:"p50Millis"`
+	P90Millis   float64 `json:"p90Millis"`
+	P99Millis   float64 `json:"p99Millis"`
+	MaxMillis   float64 `json:"maxMillis"`
+	// FirstError is the first error of the operation, if any.
+	FirstError string `json:"firstError,omitempty"`
+}
+
+// benchReport is the result of a benchmark.
+type benchReport struct {
+	Endpoint    string          `json:"endpoint"`
+	Bucket      string          `json:"bucket"`
+	Duration    time.Duration   `json:"-"`
+	Seconds     float64         `json:"seconds"`
+	Concurrency int             `json:"concurrency"`
+	ObjectSize  int64           `json:"objectSize"`
+	Operations  []benchOpResult `json:"operations"`
+}
+
+// benchOpStats collects the latencies of one operation type.
+type benchOpStats struct {
+	latencies  []time.Duration
+	bytes      int64
+	errors     int
+	firstError error
+}
+
+// benchWorker runs the operations of one connection of a benchmark.
+type benchWorker struct {
+	client *minio.Client
+	cfg    benchConfig
+	prefix string
+	id     int
+	rnd    *rand.Rand
+	data   []byte
+	seq    int
+	stats  map[string]*benchOpStats
+	// objects are the objects uploaded by the worker that can be read or deleted, only the worker
+	// reads and deletes them, so no GET fails because another worker deleted its object
+	objects []string
+}
+
+// random returns a random object of the worker, and forgets it if remove is set.
+func (w *benchWorker) random(remove bool) (string, bool) {
+	if len(w.objects) == 0 {
+		return "", false
+	}
+	i := w.rnd.Intn(len(w.objects))
+	object := w.objects[i]
+	if remove {
+		last := len(w.objects) - 1
+		w.objects[i] = w.objects[last]
+		w.objects = w.objects[:last]
+	}
+	return object, true
+}
+
+// put uploads an object of random data, so TemporalX can not deduplicate the objects of a benchmark.
+func (w *benchWorker) put() (int64, error) {
+	w.rnd.Read(w.data)
+	w.seq++
+	object := fmt.Sprintf("%s%d-%d", w.prefix, w.id, w.seq)
+	n, err := w.client.PutObject(w.cfg.Bucket, object, bytes.NewReader(w.data), int64(len(w.data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		return n, err
+	}
+	w.objects = append(w.objects, object)
+	return n, nil
+}
+
+// run runs one operation, and returns the operation that was run.
+// GET and DELETE operations upload an object instead while no objects exist.
+func (w *benchWorker) run(op string) (string, int64, error) {
+	if op == benchOpGet || op == benchOpDelete {
+		object, ok := w.random(op == benchOpDelete)
+		if !ok {
+			n, err := w.put()
+			return benchOpPut, n, err
+		}
+		if op == benchOpDelete {
+			return op, 0, w.client.RemoveObject(w.cfg.Bucket, object)
+		}
+		obj, err := w.client.GetObject(w.cfg.Bucket, object, minio.GetObjectOptions{})
+		if err != nil {
+			return op, 0, err
+		}
+		defer obj.Close()
+		n, err := io.Copy(ioutil.Discard, obj)
+		return op, n, err
+	}
+	if op == benchOpList {
+		_, err := minio.Core{Client: w.client}.ListObjectsV2(w.cfg.Bucket, w.prefix, "", false, "", 1000, "")
+		return op, 0, err
+	}
+	n, err := w.put()
+	return benchOpPut, n, err
+}
+
+// record adds the result of an operation to the stats of the worker.
+func (w *benchWorker) record(op string, took time.Duration, n int64, err error) {
+	s := w.stats[op]
+	if err != nil {
+		s.errors++
+		if s.firstError == nil {
+			s.firstError = err
+		}
+		return
+	}
+	s.latencies = append(s.latencies, took)
+	s.bytes += n
+}
+
+// runBench runs a benchmark against the S3 endpoint of client.
+func runBench(ctx context.Context, client *minio.Client, cfg benchConfig) (*benchReport, error) {
+	if cfg.Concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be positive, got %v", cfg.Concurrency)
+	}
+	if cfg.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive, got %v", cfg.Duration)
+	}
+	if cfg.ObjectSize < 0 {
+		return nil, fmt.Errorf("object size can not be negative, got %v", cfg.ObjectSize)
+	}
+	exists, err := client.BucketExists(cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(cfg.Bucket, ""); err != nil {
+			return nil, err
+		}
+	}
+	// every run uses its own prefix, so runs against the same bucket do not list or delete each other's objects
+	prefix := fmt.Sprintf("s3x-bench-%d/", time.Now().UnixNano())
+	workers := make([]*benchWorker, cfg.Concurrency)
+	for i := range workers {
+		workers[i] = &benchWorker{
+			client: client,
+			cfg:    cfg,
+			prefix: prefix,
+			id:     i,
+			rnd:    rand.New(rand.NewSource(cfg.Seed + int64(i))),
+			data:   make([]byte, cfg.ObjectSize),
+			stats:  make(map[string]*benchOpStats),
+		}
+		for _, op := range benchOps {
+			workers[i].stats[op] = &benchOpStats{}
+		}
+	}
+	defer func() {
+		if !cfg.Keep {
+			benchCleanup(client, cfg.Bucket, prefix, !exists)
+		}
+	}()
+
+	// upload the objects read and deleted by the benchmark, spread across the workers
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(workers))
+	)
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *benchWorker) {
+			defer wg.Done()
+			for i := w.id; i < cfg.Objects && errs[w.id] == nil && ctx.Err() == nil; i += len(workers) {
+				_, errs[w.id] = w.put()
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("unable to upload the objects of the benchmark: %v", err)
+		}
+	}
+
+	// operations are not canceled at the end or on the cancellation of the benchmark, minio-go retries canceled
+	// requests until its retries are exhausted, so no operation is started after them, and the running ones complete
+	start := time.Now()
+	end := start.Add(cfg.Duration)
+	total := cfg.Mix.total()
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *benchWorker) {
+			defer wg.Done()
+			for ctx.Err() == nil && time.Now().Before(end) {
+				opStart := time.Now()
+				op, n, err := w.run(cfg.Mix.pick(w.rnd.Intn(total)))
+				w.record(op, time.Since(opStart), n, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	report := &benchReport{
+		Endpoint:    client.EndpointURL().String(),
+		Bucket:      cfg.Bucket,
+		Duration:    elapsed,
+		Seconds:     elapsed.Seconds(),
+		Concurrency: cfg.Concurrency,
+		ObjectSize:  cfg.ObjectSize,
+	}
+	for _, op := range benchOps {
+		merged := &benchOpStats{}
+		for _, w := range workers {
+			s := w.stats[op]
+			merged.latencies = append(merged.latencies, s.latencies...)
+			merged.bytes += s.bytes
+			merged.errors += s.errors
+			if merged.firstError == nil {
+				merged.firstError = s.firstError
+			}
+		}
+		if len(merged.latencies) == 0 && merged.errors == 0 {
+			continue
+		}
+		report.Operations = append(report.Operations, merged.result(op, elapsed))
+	}
+	return report, nil
+}
+
+// result summarizes the stats of an operation that ran for elapsed.
+func (s *benchOpStats) result(op string, elapsed time.Duration) benchOpResult {
+	r := benchOpResult{Operation: op, Count: len(s.latencies), Errors: s.errors}
+	if s.firstError != nil {
+		r.FirstError = s.firstError.Error()
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		r.OpsPerSec = float64(r.Count) / seconds
+		r.BytesPerSec = float64(s.bytes) / seconds
+	}
+	if len(s.latencies) == 0 {
+		return r
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	var sum time.Duration
+	for _, l := range s.latencies {
+		sum += l
+	}
+	millis := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	r.MeanMillis = millis(sum / time.Duration(len(s.latencies)))
+	r.P50Millis = millis(benchPercentile(s.latencies, 50))
+	r.P90Millis = millis(benchPercentile(s.latencies, 90))
+	r.P99Millis = millis(benchPercentile(s.latencies, 99))
+	r.MaxMillis = millis(s.latencies[len(s.latencies)-1])
+	return r
+}
+
+// benchPercentile returns the nearest rank percentile p of sorted latencies.
+func benchPercentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// benchCleanup removes the objects of a benchmark, and the bucket if it was created by the benchmark.
+func benchCleanup(client *minio.Client, bucket, prefix string, removeBucket bool) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	objectsCh := make(chan string)
+	go func() {
+		defer close(objectsCh)
+		for info := range client.ListObjectsV2(bucket, prefix, true, doneCh) {
+			if info.Err != nil {
+				return
+			}
+			objectsCh <- info.Key
+		}
+	}()
+	for range client.RemoveObjects(bucket, objectsCh) {
+	}
+	if removeBucket {
+		client.RemoveBucket(bucket)
+	}
+}
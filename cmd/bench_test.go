@@ -0,0 +1,118 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio-go/v6"
+)
+
+func TestParseBenchMix(t *testing.T) {
+	mix, err := parseBenchMix(" put=1, GET=2,list=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix.total() != 3 {
+		t.Fatalf("expected a total weight of 3, got %v", mix.total())
+	}
+	for n, op := range []string{benchOpPut, benchOpGet, benchOpGet} {
+		if got := mix.pick(n); got != op {
+			t.Fatalf("expected %v to pick %v, got %v", n, op, got)
+		}
+	}
+	for _, s := range []string{"", "put=0", "put", "head=1", "put=-1", "put=1,put=2"} {
+		if _, err := parseBenchMix(s); err == nil {
+			t.Fatalf("expected mix %q to be invalid", s)
+		}
+	}
+}
+
+func TestBenchPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 200; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	for _, tt := range []struct {
+		p    int
+		want time.Duration
+	}{{50, 100 * time.Millisecond}, {90, 180 * time.Millisecond}, {99, 198 * time.Millisecond}, {100, 200 * time.Millisecond}} {
+		if got := benchPercentile(latencies, tt.p); got != tt.want {
+			t.Fatalf("expected p%v to be %v, got %v", tt.p, tt.want, got)
+		}
+	}
+	if got := benchPercentile(latencies[:1], 50); got != time.Millisecond {
+		t.Fatalf("expected the only latency, got %v", got)
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	testServer := StartTestServer(t, FSTestStr)
+	defer testServer.Stop()
+	u, err := url.Parse(testServer.Server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := minio.New(u.Host, testServer.AccessKey, testServer.SecretKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mix, err := parseBenchMix("put=1,get=1,list=1,delete=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runBench(context.Background(), client, benchConfig{
+		Bucket:      "bench",
+		Duration:    500 * time.Millisecond,
+		Concurrency: 4,
+		ObjectSize:  1024,
+		Objects:     8,
+		Mix:         mix,
+		Seed:        1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Operations) != len(benchOps) {
+		t.Fatalf("expected results of all operations, got %+v", report.Operations)
+	}
+	for _, r := range report.Operations {
+		if r.Count == 0 || r.Errors != 0 || r.OpsPerSec <= 0 || r.P50Millis > r.P99Millis || r.P99Millis > r.MaxMillis {
+			t.Fatalf("unexpected result %+v", r)
+		}
+		if (r.Operation == benchOpPut || r.Operation == benchOpGet) && r.BytesPerSec <= 0 {
+			t.Fatalf("expected the %v throughput, got %+v", r.Operation, r)
+		}
+	}
+	var out bytes.Buffer
+	printBenchReport(&out, report)
+	if !strings.Contains(out.String(), "p99") || !strings.Contains(out.String(), benchOpDelete) {
+		t.Fatalf("expected a table of the operations, got %s", out.String())
+	}
+	// the bucket created by the benchmark is removed with its objects
+	if exists, err := client.BucketExists("bench"); err != nil || exists {
+		t.Fatalf("expected the bucket to be removed, got %v %v", exists, err)
+	}
+	if _, err := runBench(context.Background(), client, benchConfig{Bucket: "bench", Mix: mix}); err == nil {
+		t.Fatal("expected a benchmark without concurrency to fail")
+	}
+}
@@ -162,8 +162,27 @@ func validateBucketSSEConfig(r io.Reader) (*bucketsse.BucketSSEConfig, error) {
 		return nil, err
 	}
 
-	if len(encConfig.Rules) == 1 && encConfig.Rules[0].DefaultEncryptionAction.Algorithm == bucketsse.AES256 {
-		return encConfig, nil
+	if len(encConfig.Rules) == 1 {
+		switch encConfig.Rules[0].DefaultEncryptionAction.Algorithm {
+		case bucketsse.AES256, bucketsse.AWSKms:
+			return encConfig, nil
+		}
 	}
 	return nil, errors.New("Unsupported bucket encryption configuration")
 }
+
+// bucketSSEKeyID returns the KMS key the object keys of SSE-S3 encrypted objects in a bucket are generated with,
+// the KMS master key of an aws:kms default encryption of the bucket, or the default key of the KMS.
+func bucketSSEKeyID(bucket string) string {
+	if globalIsGateway || globalBucketSSEConfigSys != nil {
+		if config, ok := globalBucketSSEConfigSys.Get(bucket); ok {
+			for _, rule := range config.Rules {
+				action := rule.DefaultEncryptionAction
+				if action.Algorithm == bucketsse.AWSKms && action.MasterKeyID != "" {
+					return action.MasterKeyID
+				}
+			}
+		}
+	}
+	return GlobalKMS.KeyID()
+}
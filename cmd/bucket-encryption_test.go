@@ -20,6 +20,9 @@ import (
 	"bytes"
 	"errors"
 	"testing"
+
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
 )
 
 func TestValidateBucketSSEConfig(t *testing.T) {
@@ -40,7 +43,7 @@ func TestValidateBucketSSEConfig(t *testing.T) {
 			expectedErr: nil,
 			shouldPass:  true,
 		},
-		// Unsupported XML
+		// SSE-KMS XML
 		{
 			inputXML: `<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
 			<Rule>
@@ -50,7 +53,19 @@ func TestValidateBucketSSEConfig(t *testing.T) {
 			</ApplyServerSideEncryptionByDefault>
 			</Rule>
 			</ServerSideEncryptionConfiguration>`,
-			expectedErr: errors.New("Unsupported bucket encryption configuration"),
+			expectedErr: nil,
+			shouldPass:  true,
+		},
+		// SSE-KMS XML without a master key
+		{
+			inputXML: `<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+			<Rule>
+			<ApplyServerSideEncryptionByDefault>
+                        <SSEAlgorithm>aws:kms</SSEAlgorithm>
+			</ApplyServerSideEncryptionByDefault>
+			</Rule>
+			</ServerSideEncryptionConfiguration>`,
+			expectedErr: errors.New("MasterKeyID is missing"),
 			shouldPass:  false,
 		},
 	}
@@ -68,3 +83,37 @@ func TestValidateBucketSSEConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestBucketSSEKeyID(t *testing.T) {
+	defer func(kms crypto.KMS, sys *BucketSSEConfigSys) {
+		GlobalKMS, globalBucketSSEConfigSys = kms, sys
+	}(GlobalKMS, globalBucketSSEConfigSys)
+	GlobalKMS = crypto.NewMasterKey("default-key", [32]byte{})
+	globalBucketSSEConfigSys = NewBucketSSEConfigSys()
+	globalBucketSSEConfigSys.Set("sse-s3", bucketsse.BucketSSEConfig{Rules: []bucketsse.SSERule{{
+		DefaultEncryptionAction: bucketsse.EncryptionAction{Algorithm: bucketsse.AES256},
+	}}})
+	globalBucketSSEConfigSys.Set("sse-kms", bucketsse.BucketSSEConfig{Rules: []bucketsse.SSERule{{
+		DefaultEncryptionAction: bucketsse.EncryptionAction{Algorithm: bucketsse.AWSKms, MasterKeyID: "bucket-key"},
+	}}})
+	testCases := []struct {
+		bucket string
+		keyID  string
+	}{
+		{"unencrypted", "default-key"},
+		{"sse-s3", "default-key"},
+		{"sse-kms", "bucket-key"},
+	}
+	for i, tc := range testCases {
+		if keyID := bucketSSEKeyID(tc.bucket); keyID != tc.keyID {
+			t.Fatalf("Test case %d: Expected key %s but got %s", i+1, tc.keyID, keyID)
+		}
+		metadata := make(map[string]string)
+		if _, err := newEncryptMetadata(nil, tc.bucket, "object", metadata, true); err != nil {
+			t.Fatalf("Test case %d: %v", i+1, err)
+		}
+		if metadata[crypto.S3KMSKeyID] != tc.keyID {
+			t.Fatalf("Test case %d: Expected object key sealed with %s but got %s", i+1, tc.keyID, metadata[crypto.S3KMSKeyID])
+		}
+	}
+}
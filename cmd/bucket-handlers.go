@@ -26,7 +26,10 @@ import (
 	"net/url"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -34,6 +37,7 @@ import (
 	"github.com/RTradeLtd/s3x/cmd/crypto"
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/cmd/logger"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
 	objectlock "github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 	"github.com/RTradeLtd/s3x/pkg/event"
@@ -177,8 +181,18 @@ func (api objectAPIHandlers) GetBucketLocationHandler(w http.ResponseWriter, r *
 
 	// Generate response.
 	encodedSuccessResponse := encodeResponse(LocationResponse{})
-	// Get current region.
+	// Get current region, or the region of the bucket if the object layer labels buckets with regions.
 	region := globalServerRegion
+	if router, ok := unwrapObjectLayer(objectAPI).(BucketRegionRouter); ok {
+		bucketRegion, _, err := router.BucketRegion(ctx, bucket)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		if bucketRegion != "" {
+			region = bucketRegion
+		}
+	}
 	if region != globalMinioDefaultRegion {
 		encodedSuccessResponse = encodeResponse(LocationResponse{
 			Location: region,
@@ -248,10 +262,118 @@ func (api objectAPIHandlers) ListMultipartUploadsHandler(w http.ResponseWriter,
 	writeSuccessResponseXML(w, encodedSuccessResponse)
 }
 
+// bucketListedBefore returns true if bucket a is listed before bucket b
+func bucketListedBefore(a, b BucketInfo, sortByCreated bool) bool {
+	if sortByCreated && !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	return a.Name < b.Name
+}
+
+// bucketsContinuationToken returns the continuation token of a bucket listing after bucket
+func bucketsContinuationToken(bucket BucketInfo, sortByCreated bool) string {
+	if sortByCreated {
+		return strconv.FormatInt(bucket.Created.UnixNano(), 10) + SlashSeparator + bucket.Name
+	}
+	return bucket.Name
+}
+
+// parseBucketsContinuationToken returns the last listed bucket of a continuation token
+func parseBucketsContinuationToken(token string, sortByCreated bool) (BucketInfo, error) {
+	if !sortByCreated {
+		return BucketInfo{Name: token}, nil
+	}
+	parts := strings.SplitN(token, SlashSeparator, 2)
+	if len(parts) != 2 {
+		return BucketInfo{}, InvalidContinuationToken{Token: token}
+	}
+	created, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return BucketInfo{}, InvalidContinuationToken{Token: token}
+	}
+	return BucketInfo{Name: parts[1], Created: time.Unix(0, created).UTC()}, nil
+}
+
+// PaginateBuckets returns a page of a listing of buckets, for object layers that can not list buckets in pages.
+// The continuation token of a page is the name of its last bucket, which is prefixed with its creation time in
+// nanoseconds and a slash if buckets are sorted by creation time.
+func PaginateBuckets(buckets []BucketInfo, opts ListBucketsOptions) (ListBucketsInfo, error) {
+	var after BucketInfo
+	if opts.ContinuationToken != "" {
+		var err error
+		if after, err = parseBucketsContinuationToken(opts.ContinuationToken, opts.SortByCreated); err != nil {
+			return ListBucketsInfo{}, err
+		}
+	}
+	listed := make([]BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		if !strings.HasPrefix(bucket.Name, opts.Prefix) {
+			continue
+		}
+		if opts.ContinuationToken != "" && !bucketListedBefore(after, bucket, opts.SortByCreated) {
+			continue
+		}
+		listed = append(listed, bucket)
+	}
+	sort.Slice(listed, func(i, j int) bool {
+		return bucketListedBefore(listed[i], listed[j], opts.SortByCreated)
+	})
+	var info ListBucketsInfo
+	if opts.MaxBuckets > 0 && len(listed) > opts.MaxBuckets {
+		listed = listed[:opts.MaxBuckets]
+		info.NextContinuationToken = bucketsContinuationToken(listed[len(listed)-1], opts.SortByCreated)
+	}
+	info.Buckets = listed
+	return info, nil
+}
+
+// listBucketsPage returns a page of a listing of buckets, listed in pages by the object layer if it can
+func listBucketsPage(ctx context.Context, objectAPI ObjectLayer, opts ListBucketsOptions) (ListBucketsInfo, error) {
+	if lister, ok := unwrapObjectLayer(objectAPI).(BucketPageLister); ok {
+		return lister.ListBucketsPage(ctx, opts)
+	}
+	buckets, err := objectAPI.ListBuckets(ctx)
+	if err != nil {
+		return ListBucketsInfo{}, err
+	}
+	return PaginateBuckets(buckets, opts)
+}
+
+// listAllowedBucketsPage returns a page of the buckets of a listing that are allowed, pages are listed with list
+// until the page is full, so buckets that are not allowed do not shorten it. The continuation token of a page
+// that ends before the page it was cut from is the token of its last bucket.
+func listAllowedBucketsPage(list func(opts ListBucketsOptions) (ListBucketsInfo, error), opts ListBucketsOptions, allowed func(BucketInfo) bool) (ListBucketsInfo, error) {
+	var info ListBucketsInfo
+	pageOpts := opts
+	for {
+		page, err := list(pageOpts)
+		if err != nil {
+			return ListBucketsInfo{}, err
+		}
+		for i, bucket := range page.Buckets {
+			if !allowed(bucket) {
+				continue
+			}
+			info.Buckets = append(info.Buckets, bucket)
+			if opts.MaxBuckets > 0 && len(info.Buckets) == opts.MaxBuckets {
+				if i < len(page.Buckets)-1 || page.NextContinuationToken != "" {
+					info.NextContinuationToken = bucketsContinuationToken(bucket, opts.SortByCreated)
+				}
+				return info, nil
+			}
+		}
+		if page.NextContinuationToken == "" {
+			return info, nil
+		}
+		pageOpts.ContinuationToken = page.NextContinuationToken
+	}
+}
+
 // ListBucketsHandler - GET Service.
 // -----------
 // This implementation of the GET operation returns a list of all buckets
-// owned by the authenticated sender of the request.
+// owned by the authenticated sender of the request, sorted by name or
+// by creation time, in pages of up to max-buckets buckets.
 func (api objectAPIHandlers) ListBucketsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := newContext(r, w, "ListBuckets")
 
@@ -263,35 +385,37 @@ func (api objectAPIHandlers) ListBucketsHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	listBuckets := objectAPI.ListBuckets
-
 	accessKey, owner, s3Error := checkRequestAuthTypeToAccessKey(ctx, r, policy.ListAllMyBucketsAction, "", "")
 	if s3Error != ErrNone {
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
 		return
 	}
 
+	opts, s3Error := getListBucketsArgs(r.URL.Query())
+	if s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	// If etcd, dns federation configured list buckets from etcd.
-	var bucketsInfo []BucketInfo
+	list := func(opts ListBucketsOptions) (ListBucketsInfo, error) {
+		return listBucketsPage(ctx, objectAPI, opts)
+	}
 	if globalDNSConfig != nil && globalBucketFederation {
 		dnsBuckets, err := globalDNSConfig.List()
 		if err != nil && err != dns.ErrNoEntriesFound {
 			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 			return
 		}
+		var bucketsInfo []BucketInfo
 		for _, dnsRecords := range dnsBuckets {
 			bucketsInfo = append(bucketsInfo, BucketInfo{
 				Name:    dnsRecords[0].Key,
 				Created: dnsRecords[0].CreationDate,
 			})
 		}
-	} else {
-		// Invoke the list buckets.
-		var err error
-		bucketsInfo, err = listBuckets(ctx)
-		if err != nil {
-			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
-			return
+		list = func(opts ListBucketsOptions) (ListBucketsInfo, error) {
+			return PaginateBuckets(bucketsInfo, opts)
 		}
 	}
 
@@ -304,9 +428,10 @@ func (api objectAPIHandlers) ListBucketsHandler(w http.ResponseWriter, r *http.R
 	// err will be nil here as we already called this function
 	// earlier in this request.
 	claims, _ := getClaimsFromToken(r)
-	var newBucketsInfo []BucketInfo
-	for _, bucketInfo := range bucketsInfo {
-		if globalIAMSys.IsAllowed(iampolicy.Args{
+	// Buckets the caller may not list are filtered before the page
+	// is cut, so pages stay full.
+	listBucketsInfo, err := listAllowedBucketsPage(list, opts, func(bucketInfo BucketInfo) bool {
+		return globalIAMSys.IsAllowed(iampolicy.Args{
 			AccountName:     accessKey,
 			Action:          iampolicy.ListBucketAction,
 			BucketName:      bucketInfo.Name,
@@ -314,13 +439,15 @@ func (api objectAPIHandlers) ListBucketsHandler(w http.ResponseWriter, r *http.R
 			IsOwner:         owner,
 			ObjectName:      "",
 			Claims:          claims,
-		}) {
-			newBucketsInfo = append(newBucketsInfo, bucketInfo)
-		}
+		})
+	})
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
 	}
 
 	// Generate response.
-	response := generateListBucketsResponse(newBucketsInfo)
+	response := generateListBucketsResponse(listBucketsInfo.Buckets, accessKey, opts.Prefix, listBucketsInfo.NextContinuationToken)
 	encodedSuccessResponse := encodeResponse(response)
 
 	// Write response.
@@ -367,12 +494,21 @@ func (api objectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 		return
 	}
 
+	// S3 deletes at most 1000 objects per request, and rejects requests with more keys as malformed,
+	// the keys are counted before duplicates are removed.
+	if len(deleteObjects.Objects) > maxDeleteList {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrMalformedXML), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	deleteObjectsFn := objectAPI.DeleteObjects
 	if api.CacheAPI() != nil {
 		deleteObjectsFn = api.CacheAPI().DeleteObjects
 	}
 
 	var objectsToDelete = map[string]int{}
+	// Versions are deleted separately by object layers that keep them.
+	var versionsToDelete = map[ObjectIdentifier]int{}
 	getObjectInfoFn := objectAPI.GetObjectInfo
 	if api.CacheAPI() != nil {
 		getObjectInfoFn = api.CacheAPI().GetObjectInfo
@@ -393,6 +529,12 @@ func (api objectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 			dErrs[index] = err
 			continue
 		}
+		if object.VersionID != "" {
+			if _, ok := versionsToDelete[object]; !ok {
+				versionsToDelete[object] = index
+			}
+			continue
+		}
 		// Avoid duplicate objects, we use map to filter them out.
 		if _, ok := objectsToDelete[object.ObjectName]; !ok {
 			objectsToDelete[object.ObjectName] = index
@@ -421,6 +563,28 @@ func (api objectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 		dErrs[dIdx] = toAPIErrorCode(ctx, errs[i])
 	}
 
+	if len(versionsToDelete) > 0 {
+		versionDeleter, ok := unwrapObjectLayer(objectAPI).(ObjectVersionDeleter)
+		if !ok {
+			for _, dIdx := range versionsToDelete {
+				dErrs[dIdx] = ErrNotImplemented
+			}
+		} else {
+			versionList := make([]ObjectIdentifier, 0, len(versionsToDelete))
+			for object := range versionsToDelete {
+				versionList = append(versionList, object)
+			}
+			errs, err := versionDeleter.DeleteObjectVersions(ctx, bucket, versionList)
+			if err != nil {
+				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+				return
+			}
+			for i, object := range versionList {
+				dErrs[versionsToDelete[object]] = toAPIErrorCode(ctx, errs[i])
+			}
+		}
+	}
+
 	// Collect deleted objects and errors if any.
 	var deletedObjects []ObjectIdentifier
 	var deleteErrors []DeleteError
@@ -434,9 +598,10 @@ func (api objectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 		apiErr := getAPIError(errCode)
 		// Error during delete should be collected separately.
 		deleteErrors = append(deleteErrors, DeleteError{
-			Code:    apiErr.Code,
-			Message: apiErr.Description,
-			Key:     object.ObjectName,
+			Code:      apiErr.Code,
+			Message:   apiErr.Description,
+			Key:       object.ObjectName,
+			VersionID: object.VersionID,
 		})
 	}
 
@@ -480,17 +645,13 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	bucket := vars["bucket"]
 
-	objectLockEnabled := false
-	if vs, found := r.Header[http.CanonicalHeaderKey("x-amz-bucket-object-lock-enabled")]; found {
-		v := strings.ToLower(strings.Join(vs, ""))
-		if v != "true" && v != "false" {
-			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidRequest), r.URL, guessIsBrowserReq(r))
-			return
-		}
-		objectLockEnabled = v == "true"
+	if s3Error := checkRequestAuthType(ctx, r, policy.CreateBucketAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
 	}
 
-	if s3Error := checkRequestAuthType(ctx, r, policy.CreateBucketAction, bucket, ""); s3Error != ErrNone {
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objectAPI, bucket, r); s3Error != ErrNone {
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
 		return
 	}
@@ -509,17 +670,23 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	opts, s3Error := parseBucketOptions(r, location)
+	if s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	if globalDNSConfig != nil {
 		sr, err := globalDNSConfig.Get(bucket)
 		if err != nil {
 			if err == dns.ErrNoEntriesFound {
 				// Proceed to creating a bucket.
-				if err = objectAPI.MakeBucketWithLocation(ctx, bucket, location); err != nil {
+				if err = objectAPI.MakeBucketWithLocation(ctx, bucket, opts); err != nil {
 					writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 					return
 				}
 
-				if objectLockEnabled {
+				if opts.LockEnabled {
 					configFile := path.Join(bucketConfigPrefix, bucket, bucketObjectLockEnabledConfigFile)
 					if err = saveConfig(ctx, objectAPI, configFile, []byte(bucketObjectLockEnabledConfig)); err != nil {
 						writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
@@ -527,6 +694,11 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 					}
 				}
 
+				if err = setNewBucketSSEConfig(ctx, objectAPI, bucket, opts.SSEConfig); err != nil {
+					writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+					return
+				}
+
 				if err = globalDNSConfig.Put(bucket); err != nil {
 					objectAPI.DeleteBucket(ctx, bucket)
 					writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
@@ -557,13 +729,18 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// Proceed to creating a bucket.
-	err := objectAPI.MakeBucketWithLocation(ctx, bucket, location)
+	err := objectAPI.MakeBucketWithLocation(ctx, bucket, opts)
 	if err != nil {
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
 
-	if objectLockEnabled && !globalIsGateway {
+	if err = setNewBucketSSEConfig(ctx, objectAPI, bucket, opts.SSEConfig); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if opts.LockEnabled && !globalIsGateway {
 		configFile := path.Join(bucketConfigPrefix, bucket, bucketObjectLockEnabledConfigFile)
 		if err = saveConfig(ctx, objectAPI, configFile, []byte(bucketObjectLockEnabledConfig)); err != nil {
 			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
@@ -579,6 +756,54 @@ func (api objectAPIHandlers) PutBucketHandler(w http.ResponseWriter, r *http.Req
 	writeSuccessResponseHeadersOnly(w)
 }
 
+// parseBucketOptions returns the options of a new bucket from the headers of a PutBucket request.
+// Enabling object lock also enables versioning as on S3, and the x-amz-server-side-encryption
+// header sets the default encryption of new objects in the bucket.
+func parseBucketOptions(r *http.Request, location string) (BucketOptions, APIErrorCode) {
+	opts := BucketOptions{Location: location}
+	if vs, found := r.Header[http.CanonicalHeaderKey("x-amz-bucket-object-lock-enabled")]; found {
+		v := strings.ToLower(strings.Join(vs, ""))
+		if v != "true" && v != "false" {
+			return opts, ErrInvalidRequest
+		}
+		opts.LockEnabled = v == "true"
+		opts.VersioningEnabled = opts.LockEnabled
+	}
+	if _, found := r.Header[crypto.SSEHeader]; found {
+		action := bucketsse.EncryptionAction{Algorithm: bucketsse.SSEAlgorithm(r.Header.Get(crypto.SSEHeader))}
+		switch action.Algorithm {
+		case bucketsse.AES256:
+		case bucketsse.AWSKms:
+			if action.MasterKeyID = r.Header.Get(crypto.SSEKmsID); action.MasterKeyID == "" {
+				return opts, ErrInvalidEncryptionMethod
+			}
+		default:
+			return opts, ErrInvalidEncryptionMethod
+		}
+		if GlobalKMS == nil {
+			return opts, ErrKMSNotConfigured
+		}
+		opts.SSEConfig = &bucketsse.BucketSSEConfig{
+			XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/",
+			Rules: []bucketsse.SSERule{{DefaultEncryptionAction: action}},
+		}
+	}
+	return opts, ErrNone
+}
+
+// setNewBucketSSEConfig stores the default encryption of a new bucket, gateways store it with the bucket.
+func setNewBucketSSEConfig(ctx context.Context, objectAPI ObjectLayer, bucket string, config *bucketsse.BucketSSEConfig) error {
+	if config == nil || globalIsGateway {
+		return nil
+	}
+	if err := objectAPI.SetBucketSSEConfig(ctx, bucket, config); err != nil {
+		return err
+	}
+	globalBucketSSEConfigSys.Set(bucket, *config)
+	globalNotificationSys.SetBucketSSEConfig(ctx, bucket, config)
+	return nil
+}
+
 // PostPolicyBucketHandler - POST policy
 // ----------
 // This implementation of the POST operation handles object creation with a specified
@@ -858,6 +1083,39 @@ func (api objectAPIHandlers) HeadBucketHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if checker, ok := unwrapObjectLayer(objectAPI).(BucketExistenceChecker); ok {
+		exists, err := checker.BucketExists(ctx, bucket)
+		if err == nil && !exists {
+			err = BucketNotFound{Bucket: bucket}
+		}
+		if err != nil {
+			writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+			return
+		}
+		// The hash is only read for buckets that exist.
+		if hasher, ok := unwrapObjectLayer(objectAPI).(BucketHasher); ok {
+			hash, err := hasher.BucketHash(ctx, bucket)
+			if err != nil {
+				writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+				return
+			}
+			w.Header().Set(xhttp.S3xBucketHash, hash)
+		}
+		writeSuccessResponseHeadersOnly(w)
+		return
+	}
+
+	if hasher, ok := unwrapObjectLayer(objectAPI).(BucketHasher); ok {
+		hash, err := hasher.BucketHash(ctx, bucket)
+		if err != nil {
+			writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
+			return
+		}
+		w.Header().Set(xhttp.S3xBucketHash, hash)
+		writeSuccessResponseHeadersOnly(w)
+		return
+	}
+
 	getBucketInfo := objectAPI.GetBucketInfo
 
 	if _, err := getBucketInfo(ctx, bucket); err != nil {
@@ -899,13 +1157,14 @@ func (api objectAPIHandlers) DeleteBucketHandler(w http.ResponseWriter, r *http.
 	if globalDNSConfig != nil {
 		if err := globalDNSConfig.Delete(bucket); err != nil {
 			// Deleting DNS entry failed, attempt to create the bucket again.
-			objectAPI.MakeBucketWithLocation(ctx, bucket, "")
+			objectAPI.MakeBucketWithLocation(ctx, bucket, BucketOptions{})
 			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 			return
 		}
 	}
 
 	globalNotificationSys.DeleteBucket(ctx, bucket)
+	globalBucketMetricsSys.Remove(bucket)
 
 	// Write success response.
 	writeSuccessNoContent(w)
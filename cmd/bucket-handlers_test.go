@@ -24,9 +24,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
 )
 
 // Wrapper for calling GetBucketPolicy HTTP handler tests for both XL multiple disks and single node setup.
@@ -515,6 +520,108 @@ func testListMultipartUploadsHandler(obj ObjectLayer, instanceType, bucketName s
 	ExecObjectLayerAPINilTest(t, nilBucket, "", instanceType, apiRouter, nilReq)
 }
 
+// Tests the pages of bucket listings sorted by name and by creation time.
+func TestPaginateBuckets(t *testing.T) {
+	now := UTCNow()
+	buckets := []BucketInfo{
+		{Name: "photos-b", Created: now.Add(time.Hour)},
+		{Name: "docs", Created: now},
+		{Name: "photos-a", Created: now.Add(2 * time.Hour)},
+		{Name: "photos-c", Created: now.Add(time.Hour)},
+	}
+	testCases := []struct {
+		opts  ListBucketsOptions
+		pages [][]string
+	}{
+		{ListBucketsOptions{}, [][]string{{"docs", "photos-a", "photos-b", "photos-c"}}},
+		{ListBucketsOptions{MaxBuckets: 3}, [][]string{{"docs", "photos-a", "photos-b"}, {"photos-c"}}},
+		{ListBucketsOptions{MaxBuckets: 2}, [][]string{{"docs", "photos-a"}, {"photos-b", "photos-c"}}},
+		{ListBucketsOptions{Prefix: "photos", MaxBuckets: 2}, [][]string{{"photos-a", "photos-b"}, {"photos-c"}}},
+		{ListBucketsOptions{Prefix: "none"}, [][]string{{}}},
+		{ListBucketsOptions{SortByCreated: true}, [][]string{{"docs", "photos-b", "photos-c", "photos-a"}}},
+		{ListBucketsOptions{SortByCreated: true, MaxBuckets: 2}, [][]string{{"docs", "photos-b"}, {"photos-c", "photos-a"}}},
+		{ListBucketsOptions{SortByCreated: true, MaxBuckets: 1, Prefix: "photos"}, [][]string{{"photos-b"}, {"photos-c"}, {"photos-a"}}},
+	}
+	for i, testCase := range testCases {
+		opts := testCase.opts
+		for j, page := range testCase.pages {
+			info, err := PaginateBuckets(buckets, opts)
+			if err != nil {
+				t.Fatalf("Test %d: page %d: %v", i+1, j+1, err)
+			}
+			var names []string
+			for _, b := range info.Buckets {
+				names = append(names, b.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(page, ",") {
+				t.Fatalf("Test %d: page %d: Expected %v, got %v", i+1, j+1, page, names)
+			}
+			if last := j == len(testCase.pages)-1; last != (info.NextContinuationToken == "") {
+				t.Fatalf("Test %d: page %d: Unexpected continuation token %q", i+1, j+1, info.NextContinuationToken)
+			}
+			opts.ContinuationToken = info.NextContinuationToken
+		}
+	}
+	if _, err := PaginateBuckets(buckets, ListBucketsOptions{SortByCreated: true, ContinuationToken: "docs"}); err == nil {
+		t.Fatal("Expected an invalid continuation token to fail")
+	}
+}
+
+// Tests that buckets that are not allowed are filtered before the pages of bucket listings are cut.
+func TestListAllowedBucketsPage(t *testing.T) {
+	now := UTCNow()
+	buckets := []BucketInfo{
+		{Name: "denied-a", Created: now},
+		{Name: "photos-a", Created: now.Add(time.Hour)},
+		{Name: "denied-b", Created: now.Add(2 * time.Hour)},
+		{Name: "denied-c", Created: now.Add(3 * time.Hour)},
+		{Name: "photos-b", Created: now.Add(4 * time.Hour)},
+		{Name: "photos-c", Created: now.Add(5 * time.Hour)},
+		{Name: "denied-d", Created: now.Add(6 * time.Hour)},
+	}
+	allowed := func(b BucketInfo) bool { return strings.HasPrefix(b.Name, "photos") }
+	testCases := []struct {
+		opts  ListBucketsOptions
+		pages [][]string
+	}{
+		{ListBucketsOptions{}, [][]string{{"photos-a", "photos-b", "photos-c"}}},
+		{ListBucketsOptions{MaxBuckets: 2}, [][]string{{"photos-a", "photos-b"}, {"photos-c"}}},
+		{ListBucketsOptions{MaxBuckets: 3}, [][]string{{"photos-a", "photos-b", "photos-c"}}},
+		{ListBucketsOptions{MaxBuckets: 1}, [][]string{{"photos-a"}, {"photos-b"}, {"photos-c"}}},
+		{ListBucketsOptions{SortByCreated: true, MaxBuckets: 2}, [][]string{{"photos-a", "photos-b"}, {"photos-c"}}},
+		// a full page that is followed by denied buckets only still has a continuation token
+		{ListBucketsOptions{SortByCreated: true, MaxBuckets: 1}, [][]string{{"photos-a"}, {"photos-b"}, {"photos-c"}, {}}},
+	}
+	for i, testCase := range testCases {
+		opts := testCase.opts
+		var listed int
+		list := func(opts ListBucketsOptions) (ListBucketsInfo, error) {
+			listed++
+			return PaginateBuckets(buckets, opts)
+		}
+		for j, page := range testCase.pages {
+			info, err := listAllowedBucketsPage(list, opts, allowed)
+			if err != nil {
+				t.Fatalf("Test %d: page %d: %v", i+1, j+1, err)
+			}
+			var names []string
+			for _, b := range info.Buckets {
+				names = append(names, b.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(page, ",") {
+				t.Fatalf("Test %d: page %d: Expected %v, got %v", i+1, j+1, page, names)
+			}
+			if last := j == len(testCase.pages)-1; last != (info.NextContinuationToken == "") {
+				t.Fatalf("Test %d: page %d: Unexpected continuation token %q", i+1, j+1, info.NextContinuationToken)
+			}
+			opts.ContinuationToken = info.NextContinuationToken
+		}
+		if testCase.opts.MaxBuckets > 0 && listed <= len(testCase.pages) {
+			t.Fatalf("Test %d: Expected pages with denied buckets to be listed further, listed %d times", i+1, listed)
+		}
+	}
+}
+
 // Wrapper for calling TestListBucketsHandler tests for both XL multiple disks and single node setup.
 func TestListBucketsHandler(t *testing.T) {
 	ExecObjectLayerAPITest(t, testListBucketsHandler, []string{"ListBuckets"})
@@ -637,7 +744,7 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 
 	getObjectIdentifierList := func(objectNames []string) (objectIdentifierList []ObjectIdentifier) {
 		for _, objectName := range objectNames {
-			objectIdentifierList = append(objectIdentifierList, ObjectIdentifier{objectName})
+			objectIdentifierList = append(objectIdentifierList, ObjectIdentifier{ObjectName: objectName})
 		}
 
 		return objectIdentifierList
@@ -673,6 +780,13 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 	errorResponse := generateMultiDeleteResponse(requestList[1].Quiet, requestList[1].Objects, nil)
 	encodedErrorResponse := encodeResponse(errorResponse)
 
+	// a request with more keys than a DeleteObjects request can delete
+	var tooManyNames []string
+	for i := 0; i <= maxDeleteList; i++ {
+		tooManyNames = append(tooManyNames, objectNames[0]+"-"+strconv.Itoa(i))
+	}
+	tooManyRequest := encodeResponse(DeleteObjectsRequest{Objects: getObjectIdentifierList(tooManyNames)})
+
 	anonRequest := encodeResponse(requestList[0])
 	anonResponse := generateMultiDeleteResponse(requestList[0].Quiet, nil, getDeleteErrorList(requestList[0].Objects))
 	encodedAnonResponse := encodeResponse(anonResponse)
@@ -684,6 +798,7 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 		secretKey          string
 		expectedContent    []byte
 		expectedRespStatus int
+		expectedErrCode    string
 	}{
 		// Test case - 1.
 		// Delete objects with invalid access key.
@@ -736,6 +851,17 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 			expectedContent:    encodedAnonResponse,
 			expectedRespStatus: http.StatusOK,
 		},
+		// Test case - 6.
+		// Delete more objects than a request can delete
+		{
+			bucket:             bucketName,
+			objects:            tooManyRequest,
+			accessKey:          credentials.AccessKey,
+			secretKey:          credentials.SecretKey,
+			expectedContent:    nil,
+			expectedRespStatus: http.StatusBadRequest,
+			expectedErrCode:    "MalformedXML",
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -775,6 +901,12 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 		if testCase.expectedContent != nil && !bytes.Equal(testCase.expectedContent, actualContent) {
 			t.Errorf("Test %d : MinIO %s: Object content differs from expected value.", i+1, instanceType)
 		}
+		if testCase.expectedErrCode != "" {
+			errResponse := APIErrorResponse{}
+			if err = xml.Unmarshal(actualContent, &errResponse); err != nil || errResponse.Code != testCase.expectedErrCode {
+				t.Errorf("Test %d : MinIO %s: Expected the error `%s`, but got `%s` (%v)", i+1, instanceType, testCase.expectedErrCode, errResponse.Code, err)
+			}
+		}
 	}
 
 	// HTTP request to test the case of `objectLayer` being set to `nil`.
@@ -793,3 +925,210 @@ func testAPIDeleteMultipleObjectsHandler(obj ObjectLayer, instanceType, bucketNa
 	// `ExecObjectLayerAPINilTest` manages the operation.
 	ExecObjectLayerAPINilTest(t, nilBucket, nilObject, instanceType, apiRouter, nilReq)
 }
+
+func TestParseBucketOptions(t *testing.T) {
+	defer func(kms crypto.KMS) { GlobalKMS = kms }(GlobalKMS)
+	GlobalKMS = crypto.NewMasterKey("my-key", [32]byte{})
+	testCases := []struct {
+		headers    map[string]string
+		lock       bool
+		algorithm  bucketsse.SSEAlgorithm
+		keyID      string
+		expectCode APIErrorCode
+	}{
+		{map[string]string{}, false, "", "", ErrNone},
+		{map[string]string{"x-amz-bucket-object-lock-enabled": "true"}, true, "", "", ErrNone},
+		{map[string]string{"x-amz-bucket-object-lock-enabled": "false"}, false, "", "", ErrNone},
+		{map[string]string{"x-amz-bucket-object-lock-enabled": "yes"}, false, "", "", ErrInvalidRequest},
+		{map[string]string{crypto.SSEHeader: "AES256"}, false, bucketsse.AES256, "", ErrNone},
+		{map[string]string{crypto.SSEHeader: "aws:kms", crypto.SSEKmsID: "my-key"}, false, bucketsse.AWSKms, "my-key", ErrNone},
+		{map[string]string{crypto.SSEHeader: "aws:kms"}, false, "", "", ErrInvalidEncryptionMethod},
+		{map[string]string{crypto.SSEHeader: "DES"}, false, "", "", ErrInvalidEncryptionMethod},
+	}
+	for i, testCase := range testCases {
+		r := httptest.NewRequest(http.MethodPut, "/bucket", nil)
+		for k, v := range testCase.headers {
+			r.Header.Set(k, v)
+		}
+		opts, code := parseBucketOptions(r, "us-east-1")
+		if code != testCase.expectCode {
+			t.Fatalf("Test %d: expected error code %v, got %v", i+1, testCase.expectCode, code)
+		}
+		if code != ErrNone {
+			continue
+		}
+		if opts.Location != "us-east-1" || opts.LockEnabled != testCase.lock || opts.VersioningEnabled != testCase.lock {
+			t.Fatalf("Test %d: unexpected options %+v", i+1, opts)
+		}
+		if testCase.algorithm == "" {
+			if opts.SSEConfig != nil {
+				t.Fatalf("Test %d: expected no encryption, got %+v", i+1, opts.SSEConfig)
+			}
+			continue
+		}
+		action := opts.SSEConfig.Rules[0].DefaultEncryptionAction
+		if action.Algorithm != testCase.algorithm || action.MasterKeyID != testCase.keyID {
+			t.Fatalf("Test %d: unexpected encryption %+v", i+1, action)
+		}
+	}
+
+	GlobalKMS = nil
+	r := httptest.NewRequest(http.MethodPut, "/bucket", nil)
+	r.Header.Set(crypto.SSEHeader, "AES256")
+	if _, code := parseBucketOptions(r, ""); code != ErrKMSNotConfigured {
+		t.Fatalf("expected error code %v without KMS, got %v", ErrKMSNotConfigured, code)
+	}
+}
+
+// versionDeleterObjects records the object versions it deletes
+type versionDeleterObjects struct {
+	ObjectLayer
+	deleted []ObjectIdentifier
+}
+
+func (o *versionDeleterObjects) DeleteObjectVersions(ctx context.Context, bucket string, objects []ObjectIdentifier) ([]error, error) {
+	o.deleted = append(o.deleted, objects...)
+	return make([]error, len(objects)), nil
+}
+
+func TestDeleteMultipleObjectVersionsHandler(t *testing.T) {
+	deleter := &versionDeleterObjects{}
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		deleter.ObjectLayer = objLayer
+		return deleter
+	})
+	defer tb.TearDown()
+	if err := tb.objLayer.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	version := ObjectIdentifier{ObjectName: "object", VersionID: "version1"}
+	body, err := xml.Marshal(DeleteObjectsRequest{Objects: []ObjectIdentifier{version}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := globalActiveCred
+	req, err := newTestSignedRequestV4(http.MethodPost, getDeleteMultipleObjectsURL("", "bucket"),
+		int64(len(body)), bytes.NewReader(body), cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp DeleteObjectsResponse
+	if err := xml.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 0 || len(deleter.deleted) != 1 || deleter.deleted[0] != version {
+		t.Fatalf("expected the version to be deleted by the gateway behind its locker, but got %+v and %+v", resp, deleter.deleted)
+	}
+}
+
+// existenceCheckerObjects reports the existence of buckets without loading them
+type existenceCheckerObjects struct {
+	ObjectLayer
+	buckets map[string]bool
+}
+
+func (o existenceCheckerObjects) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return o.buckets[bucket], nil
+}
+
+func TestHeadBucketExistenceHandler(t *testing.T) {
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return existenceCheckerObjects{ObjectLayer: objLayer, buckets: map[string]bool{"cached": true}}
+	})
+	defer tb.TearDown()
+
+	cred := globalActiveCred
+	// the buckets only exist for the existence checker, not in the FS object layer
+	for bucket, code := range map[string]int{"cached": http.StatusOK, "missing": http.StatusNotFound} {
+		req, err := newTestSignedRequestV4(http.MethodHead, getHEADBucketURL("", bucket), 0, nil, cred.AccessKey, cred.SecretKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		tb.router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Errorf("expected status %d for %s from the gateway behind its locker, but got %d", code, bucket, rec.Code)
+		}
+	}
+}
+
+// pageListerObjects lists a page of buckets that only exist for it
+type pageListerObjects struct {
+	ObjectLayer
+	opts *ListBucketsOptions
+}
+
+func (o pageListerObjects) ListBucketsPage(ctx context.Context, opts ListBucketsOptions) (ListBucketsInfo, error) {
+	*o.opts = opts
+	return ListBucketsInfo{Buckets: []BucketInfo{{Name: "paged", Created: UTCNow()}}, NextContinuationToken: "paged"}, nil
+}
+
+func TestListBucketsPageHandler(t *testing.T) {
+	var opts ListBucketsOptions
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return pageListerObjects{ObjectLayer: objLayer, opts: &opts}
+	})
+	defer tb.TearDown()
+
+	cred := globalActiveCred
+	req, err := newTestSignedRequestV4(http.MethodGet, "http://127.0.0.1:9000/?max-buckets=1", 0, nil, cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "<Name>paged</Name>") || opts.MaxBuckets != 1 {
+		t.Fatalf("expected the page of the gateway behind its locker, listed with %+v, but got %s", opts, rec.Body.String())
+	}
+}
+
+// hasherObjects returns the hashes of the buckets of its existence checker
+type hasherObjects struct {
+	existenceCheckerObjects
+	hashed *[]string
+}
+
+func (o hasherObjects) BucketHash(ctx context.Context, bucket string) (string, error) {
+	*o.hashed = append(*o.hashed, bucket)
+	if !o.buckets[bucket] {
+		return "", BucketNotFound{Bucket: bucket}
+	}
+	return "hash-" + bucket, nil
+}
+
+func TestHeadBucketHashHandler(t *testing.T) {
+	var hashed []string
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return hasherObjects{existenceCheckerObjects{ObjectLayer: objLayer, buckets: map[string]bool{"cached": true}}, &hashed}
+	})
+	defer tb.TearDown()
+
+	cred := globalActiveCred
+	for bucket, code := range map[string]int{"cached": http.StatusOK, "missing": http.StatusNotFound} {
+		req, err := newTestSignedRequestV4(http.MethodHead, getHEADBucketURL("", bucket), 0, nil, cred.AccessKey, cred.SecretKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		tb.router.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Errorf("expected status %d for %s, but got %d", code, bucket, rec.Code)
+		}
+		if code == http.StatusOK && rec.Header().Get(xhttp.S3xBucketHash) != "hash-"+bucket {
+			t.Errorf("expected the hash of %s, but got %q", bucket, rec.Header().Get(xhttp.S3xBucketHash))
+		}
+	}
+	// missing buckets are answered by the existence checker
+	if len(hashed) != 1 || hashed[0] != "cached" {
+		t.Fatalf("expected only the hash of the existing bucket to be read, but got %v", hashed)
+	}
+}
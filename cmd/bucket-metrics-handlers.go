@@ -0,0 +1,239 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Maximum size of a bucket metrics configuration.
+	maxBucketMetricsConfigSize = 1 << 10
+)
+
+// PutBucketMetricsConfigurationHandler - Adds or replaces a bucket metrics configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketMetricsConfiguration.html
+func (api objectAPIHandlers) PutBucketMetricsConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "PutBucketMetricsConfiguration")
+
+	defer logger.AuditLog(w, r, "PutBucketMetricsConfiguration", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.PutBucketMetricsConfigurationAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configurer, ok := unwrapObjectLayer(objAPI).(BucketMetricsConfigurer)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	config, err := bucketmetrics.ParseConfig(io.LimitReader(r.Body, maxBucketMetricsConfigSize), r.URL.Query().Get("id"))
+	if err != nil {
+		apiErr := errorCodes.ToAPIErr(ErrMalformedXML)
+		apiErr.Description = err.Error()
+		writeErrorResponse(ctx, w, apiErr, r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Fetch the existing configurations, which also checks if the bucket exists
+	configs, err := configurer.ListBucketMetricsConfigs(ctx, bucket)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	replaced := false
+	for i := range configs {
+		if configs[i].ID == config.ID {
+			configs[i] = *config
+			replaced = true
+		}
+	}
+	if !replaced {
+		if len(configs) >= bucketmetrics.MaxConfigs {
+			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrTooManyMetricsConfigurations), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		configs = append(configs, *config)
+	}
+
+	if err = configurer.SetBucketMetricsConfig(ctx, bucket, config); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Update the counted configurations of the bucket
+	globalBucketMetricsSys.Set(bucket, configs)
+
+	writeSuccessResponseHeadersOnly(w)
+}
+
+// GetBucketMetricsConfigurationHandler - Returns a bucket metrics configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketMetricsConfiguration.html
+func (api objectAPIHandlers) GetBucketMetricsConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "GetBucketMetricsConfiguration")
+
+	defer logger.AuditLog(w, r, "GetBucketMetricsConfiguration", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	id := r.URL.Query().Get("id")
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.GetBucketMetricsConfigurationAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configurer, ok := unwrapObjectLayer(objAPI).(BucketMetricsConfigurer)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configs, err := configurer.ListBucketMetricsConfigs(ctx, bucket)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	for _, config := range configs {
+		if config.ID != id {
+			continue
+		}
+		configData, err := xml.Marshal(config)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		// Write bucket metrics configuration to client
+		writeSuccessResponseXML(w, configData)
+		return
+	}
+
+	writeErrorResponse(ctx, w, toAPIError(ctx, BucketMetricsConfigNotFound{Bucket: bucket, ID: id}), r.URL, guessIsBrowserReq(r))
+}
+
+// ListBucketMetricsConfigurationsHandler - Returns all metrics configurations of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListBucketMetricsConfigurations.html
+func (api objectAPIHandlers) ListBucketMetricsConfigurationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "ListBucketMetricsConfigurations")
+
+	defer logger.AuditLog(w, r, "ListBucketMetricsConfigurations", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.GetBucketMetricsConfigurationAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configurer, ok := unwrapObjectLayer(objAPI).(BucketMetricsConfigurer)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configs, err := configurer.ListBucketMetricsConfigs(ctx, bucket)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// All configurations fit in a single response, so the result is never truncated
+	listData, err := xml.Marshal(bucketmetrics.NewListConfigsResult(configs))
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	writeSuccessResponseXML(w, listData)
+}
+
+// DeleteBucketMetricsConfigurationHandler - Removes a bucket metrics configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketMetricsConfiguration.html
+func (api objectAPIHandlers) DeleteBucketMetricsConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "DeleteBucketMetricsConfiguration")
+
+	defer logger.AuditLog(w, r, "DeleteBucketMetricsConfiguration", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	id := r.URL.Query().Get("id")
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.PutBucketMetricsConfigurationAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configurer, ok := unwrapObjectLayer(objAPI).(BucketMetricsConfigurer)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if err := configurer.DeleteBucketMetricsConfig(ctx, bucket, id); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Update the counted configurations of the bucket
+	configs, err := configurer.ListBucketMetricsConfigs(ctx, bucket)
+	if err != nil {
+		globalBucketMetricsSys.Remove(bucket)
+	} else {
+		globalBucketMetricsSys.Set(bucket, configs)
+	}
+
+	writeSuccessNoContent(w)
+}
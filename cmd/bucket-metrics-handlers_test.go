@@ -0,0 +1,83 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+)
+
+// metricsConfigObjects keeps the metrics configurations of buckets in memory
+type metricsConfigObjects struct {
+	ObjectLayer
+	configs map[string][]bucketmetrics.Config
+}
+
+func (o metricsConfigObjects) SetBucketMetricsConfig(ctx context.Context, bucket string, config *bucketmetrics.Config) error {
+	o.configs[bucket] = append(o.configs[bucket], *config)
+	return nil
+}
+
+func (o metricsConfigObjects) DeleteBucketMetricsConfig(ctx context.Context, bucket, id string) error {
+	return NotImplemented{}
+}
+
+func (o metricsConfigObjects) ListBucketMetricsConfigs(ctx context.Context, bucket string) ([]bucketmetrics.Config, error) {
+	return o.configs[bucket], nil
+}
+
+func TestBucketMetricsConfigurationHandlers(t *testing.T) {
+	configs := map[string][]bucketmetrics.Config{}
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return metricsConfigObjects{ObjectLayer: objLayer, configs: configs}
+	})
+	defer tb.TearDown()
+	if err := tb.objLayer.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cred := globalActiveCred
+	body := `<MetricsConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>all</Id></MetricsConfiguration>`
+	req, err := newTestSignedRequestV4(http.MethodPut, "http://127.0.0.1:9000/bucket?metrics=&id=all", int64(len(body)),
+		strings.NewReader(body), cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if len(configs["bucket"]) != 1 || configs["bucket"][0].ID != "all" {
+		t.Fatalf("expected the configuration to be set on the gateway behind its locker, but got %+v", configs)
+	}
+
+	req, err = newTestSignedRequestV4(http.MethodGet, "http://127.0.0.1:9000/bucket?metrics=", 0, nil, cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<Id>all</Id>") {
+		t.Fatalf("expected the configuration to be listed, but got %d: %s", rec.Code, rec.Body.String())
+	}
+}
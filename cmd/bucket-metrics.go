@@ -0,0 +1,214 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+)
+
+// bucketMetricsRequestTypes are the types of requests counted by bucket metrics configurations,
+// list requests are counted separately from other GET requests.
+var bucketMetricsRequestTypes = [...]string{"get", "put", "delete", "head", "post", "list"}
+
+var (
+	bucketMetricsRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3", "bucket", "requests_total"),
+		"Total number of requests to the objects of a bucket metrics configuration",
+		[]string{"bucket", "filter", "type"}, nil)
+	bucketMetricsErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3", "bucket", "errors_total"),
+		"Total number of failed requests to the objects of a bucket metrics configuration",
+		[]string{"bucket", "filter", "class"}, nil)
+	bucketMetricsDownloadedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3", "bucket", "downloaded_bytes_total"),
+		"Total bytes sent in responses to requests to the objects of a bucket metrics configuration",
+		[]string{"bucket", "filter"}, nil)
+	bucketMetricsUploadedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName("s3", "bucket", "uploaded_bytes_total"),
+		"Total bytes received in requests to the objects of a bucket metrics configuration",
+		[]string{"bucket", "filter"}, nil)
+)
+
+// bucketMetricsCounters - the request counters of a bucket metrics configuration
+type bucketMetricsCounters struct {
+	config     bucketmetrics.Config
+	requests   [len(bucketMetricsRequestTypes)]atomic.Uint64
+	errors4xx  atomic.Uint64
+	errors5xx  atomic.Uint64
+	downloaded atomic.Uint64
+	uploaded   atomic.Uint64
+}
+
+// BucketMetricsSys - in-memory request counters of the metrics configurations of buckets
+type BucketMetricsSys struct {
+	sync.RWMutex
+	// bucketCountersMap holds the counters of each configuration of a bucket,
+	// the configurations are loaded from the object layer on the first request to the bucket
+	bucketCountersMap map[string][]*bucketMetricsCounters
+}
+
+// NewBucketMetricsSys - Creates an empty bucket metrics system
+func NewBucketMetricsSys() *BucketMetricsSys {
+	return &BucketMetricsSys{
+		bucketCountersMap: make(map[string][]*bucketMetricsCounters),
+	}
+}
+
+// Set - sets the metrics configurations of a bucket, the counters of configurations that
+// are kept with the same filter are not reset.
+func (sys *BucketMetricsSys) Set(bucket string, configs []bucketmetrics.Config) {
+	sys.Lock()
+	defer sys.Unlock()
+	sys.set(bucket, configs)
+}
+
+func (sys *BucketMetricsSys) set(bucket string, configs []bucketmetrics.Config) {
+	old := make(map[string]*bucketMetricsCounters)
+	for _, c := range sys.bucketCountersMap[bucket] {
+		old[c.config.ID] = c
+	}
+	counters := make([]*bucketMetricsCounters, 0, len(configs))
+	for _, config := range configs {
+		c, ok := old[config.ID]
+		if !ok || c.config.Prefix() != config.Prefix() {
+			c = &bucketMetricsCounters{config: config}
+		}
+		counters = append(counters, c)
+	}
+	sys.bucketCountersMap[bucket] = counters
+}
+
+// Remove - removes the metrics configurations and counters of a bucket
+func (sys *BucketMetricsSys) Remove(bucket string) {
+	sys.Lock()
+	defer sys.Unlock()
+	delete(sys.bucketCountersMap, bucket)
+}
+
+// counters returns the counters of the configurations of a bucket, loading them from the object
+// layer if they are not loaded yet. Nothing is counted if the object layer does not keep metrics configurations.
+func (sys *BucketMetricsSys) counters(ctx context.Context, bucket string) []*bucketMetricsCounters {
+	sys.RLock()
+	counters, ok := sys.bucketCountersMap[bucket]
+	sys.RUnlock()
+	if ok {
+		return counters
+	}
+	objAPI := newObjectLayerWithoutSafeModeFn()
+	if objAPI == nil {
+		return nil
+	}
+	configurer, ok := unwrapObjectLayer(objAPI).(BucketMetricsConfigurer)
+	if !ok {
+		return nil
+	}
+	configs, err := configurer.ListBucketMetricsConfigs(ctx, bucket)
+	if err != nil {
+		return nil
+	}
+	sys.Lock()
+	defer sys.Unlock()
+	// keep configurations set while they were loaded
+	if _, ok := sys.bucketCountersMap[bucket]; !ok {
+		sys.set(bucket, configs)
+	}
+	return sys.bucketCountersMap[bucket]
+}
+
+// bucketMetricsRequestType returns the index of the request type in bucketMetricsRequestTypes, -1 if it is not counted
+func bucketMetricsRequestType(api, method string) int {
+	t := strings.ToLower(method)
+	if t == "get" && strings.HasPrefix(api, "list") {
+		t = "list"
+	}
+	for i, rt := range bucketMetricsRequestTypes {
+		if rt == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record - counts a request to a bucket for the metrics configurations that match its object
+func (sys *BucketMetricsSys) Record(api string, r *http.Request, statusCode int, uploaded, downloaded int64) {
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	if bucket == "" {
+		return
+	}
+	requestType := bucketMetricsRequestType(api, r.Method)
+	if requestType < 0 {
+		return
+	}
+	object, err := url.PathUnescape(vars["object"])
+	if err != nil {
+		object = vars["object"]
+	}
+	for _, c := range sys.counters(r.Context(), bucket) {
+		if !c.config.Match(object) {
+			continue
+		}
+		c.requests[requestType].Inc()
+		switch {
+		case statusCode >= 500:
+			c.errors5xx.Inc()
+		case statusCode >= 400:
+			c.errors4xx.Inc()
+		}
+		c.uploaded.Add(uint64(uploaded))
+		c.downloaded.Add(uint64(downloaded))
+	}
+}
+
+// Describe - sends the descriptors of the bucket metrics
+func (sys *BucketMetricsSys) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bucketMetricsRequestsDesc
+	ch <- bucketMetricsErrorsDesc
+	ch <- bucketMetricsDownloadedDesc
+	ch <- bucketMetricsUploadedDesc
+}
+
+// Collect - sends the counters of all bucket metrics configurations
+func (sys *BucketMetricsSys) Collect(ch chan<- prometheus.Metric) {
+	sys.RLock()
+	defer sys.RUnlock()
+	for bucket, counters := range sys.bucketCountersMap {
+		for _, c := range counters {
+			for i, rt := range bucketMetricsRequestTypes {
+				ch <- prometheus.MustNewConstMetric(bucketMetricsRequestsDesc, prometheus.CounterValue,
+					float64(c.requests[i].Load()), bucket, c.config.ID, rt)
+			}
+			ch <- prometheus.MustNewConstMetric(bucketMetricsErrorsDesc, prometheus.CounterValue,
+				float64(c.errors4xx.Load()), bucket, c.config.ID, "4xx")
+			ch <- prometheus.MustNewConstMetric(bucketMetricsErrorsDesc, prometheus.CounterValue,
+				float64(c.errors5xx.Load()), bucket, c.config.ID, "5xx")
+			ch <- prometheus.MustNewConstMetric(bucketMetricsDownloadedDesc, prometheus.CounterValue,
+				float64(c.downloaded.Load()), bucket, c.config.ID)
+			ch <- prometheus.MustNewConstMetric(bucketMetricsUploadedDesc, prometheus.CounterValue,
+				float64(c.uploaded.Load()), bucket, c.config.ID)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBucketMetricsSys(t *testing.T) {
+	sys := NewBucketMetricsSys()
+	sys.Set("mybucket", []bucketmetrics.Config{
+		{ID: "all"},
+		{ID: "docs", Filter: &bucketmetrics.Filter{Prefix: "documents/"}},
+	})
+
+	record := func(api, method, object string, statusCode int, uploaded, downloaded int64) {
+		r, err := http.NewRequest(method, "http://localhost/mybucket/"+object, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = mux.SetURLVars(r, map[string]string{"bucket": "mybucket", "object": object})
+		sys.Record(api, r, statusCode, uploaded, downloaded)
+	}
+	record("putobject", http.MethodPut, "documents/a.txt", http.StatusOK, 100, 0)
+	record("getobject", http.MethodGet, "documents/a.txt", http.StatusOK, 0, 100)
+	record("getobject", http.MethodGet, "images/a%2Bb.png", http.StatusNotFound, 0, 10)
+	record("listobjectsv2", http.MethodGet, "", http.StatusOK, 0, 50)
+	record("headobject", http.MethodHead, "documents/b.txt", http.StatusInternalServerError, 0, 0)
+	record("options", http.MethodOptions, "documents/a.txt", http.StatusOK, 0, 0)
+
+	type expected struct {
+		requests             map[string]uint64
+		errors4xx, errors5xx uint64
+		uploaded, downloaded uint64
+	}
+	testCases := map[string]expected{
+		"all":  {map[string]uint64{"put": 1, "get": 2, "list": 1, "head": 1}, 1, 1, 100, 160},
+		"docs": {map[string]uint64{"put": 1, "get": 1, "head": 1}, 0, 1, 100, 100},
+	}
+	for _, c := range sys.bucketCountersMap["mybucket"] {
+		tc := testCases[c.config.ID]
+		for i, rt := range bucketMetricsRequestTypes {
+			if c.requests[i].Load() != tc.requests[rt] {
+				t.Fatalf("%s: Expected %d %s requests but got %d", c.config.ID, tc.requests[rt], rt, c.requests[i].Load())
+			}
+		}
+		if c.errors4xx.Load() != tc.errors4xx || c.errors5xx.Load() != tc.errors5xx {
+			t.Fatalf("%s: Expected %d 4xx and %d 5xx errors but got %d and %d", c.config.ID,
+				tc.errors4xx, tc.errors5xx, c.errors4xx.Load(), c.errors5xx.Load())
+		}
+		if c.uploaded.Load() != tc.uploaded || c.downloaded.Load() != tc.downloaded {
+			t.Fatalf("%s: Expected %d bytes uploaded and %d downloaded but got %d and %d", c.config.ID,
+				tc.uploaded, tc.downloaded, c.uploaded.Load(), c.downloaded.Load())
+		}
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	sys.Collect(ch)
+	close(ch)
+	// request types, error classes, downloaded and uploaded bytes of each configuration
+	if count, want := len(ch), 2*(len(bucketMetricsRequestTypes)+4); count != want {
+		t.Fatalf("Expected %d metrics but got %d", want, count)
+	}
+
+	// Counters are kept for configurations with the same filter, and reset otherwise
+	sys.Set("mybucket", []bucketmetrics.Config{
+		{ID: "all"},
+		{ID: "docs", Filter: &bucketmetrics.Filter{Prefix: "docs/"}},
+	})
+	for _, c := range sys.bucketCountersMap["mybucket"] {
+		if uploaded := c.uploaded.Load(); (c.config.ID == "all") != (uploaded == 100) {
+			t.Fatalf("%s: Unexpected %d bytes uploaded after the configurations changed", c.config.ID, uploaded)
+		}
+	}
+
+	sys.Remove("mybucket")
+	ch = make(chan prometheus.Metric, 100)
+	sys.Collect(ch)
+	close(ch)
+	if len(ch) != 0 {
+		t.Fatalf("Expected no metrics after the bucket is removed but got %d", len(ch))
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/RTradeLtd/s3x/pkg/bucket/publicaccess"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Maximum size of a public access block configuration.
+	maxPublicAccessBlockSize = 1 << 10
+)
+
+// publicAccessBlock returns the public access blocked for a bucket, nothing is blocked if the
+// object layer can not block public access. All public access is blocked if the configuration
+// can not be read.
+func publicAccessBlock(ctx context.Context, objAPI ObjectLayer, bucket string) publicaccess.Config {
+	blocker, ok := unwrapObjectLayer(objAPI).(PublicAccessBlocker)
+	if !ok {
+		return publicaccess.Config{}
+	}
+	config, err := blocker.PublicAccessBlock(ctx, bucket)
+	if err != nil {
+		logger.LogIf(ctx, err)
+		return publicaccess.BlockAll()
+	}
+	return config
+}
+
+// checkPublicACL returns ErrAccessDenied if the request grants public access with an ACL
+// and public ACLs are blocked for the bucket.
+func checkPublicACL(ctx context.Context, objAPI ObjectLayer, bucket string, r *http.Request) APIErrorCode {
+	if publicaccess.IsPublicACL(r.Header) && publicAccessBlock(ctx, objAPI, bucket).BlockPublicAcls {
+		return ErrAccessDenied
+	}
+	return ErrNone
+}
+
+// checkPublicPolicy returns ErrAccessDenied if the bucket policy grants public access
+// and public policies are blocked for the bucket.
+func checkPublicPolicy(ctx context.Context, objAPI ObjectLayer, bucket string, bucketPolicy *policy.Policy) APIErrorCode {
+	if publicaccess.IsPublicPolicy(bucketPolicy) && publicAccessBlock(ctx, objAPI, bucket).BlockPublicPolicy {
+		return ErrAccessDenied
+	}
+	return ErrNone
+}
+
+// PutBucketPublicAccessBlockHandler - Stores given public access block configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutPublicAccessBlock.html
+func (api objectAPIHandlers) PutBucketPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "PutBucketPublicAccessBlock")
+
+	defer logger.AuditLog(w, r, "PutBucketPublicAccessBlock", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+
+	// PutPublicAccessBlock API requires Content-Md5
+	if _, ok := r.Header[xhttp.ContentMD5]; !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrMissingContentMD5), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Allow the public access block to be changed with the bucket policy action,
+	// since it restricts what the bucket policy grants.
+	if s3Error := checkRequestAuthType(ctx, r, policy.PutBucketPolicyAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	blocker, ok := unwrapObjectLayer(objAPI).(PublicAccessBlocker)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Check if bucket exists.
+	if _, err := objAPI.GetBucketInfo(ctx, bucket); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	config, err := publicaccess.ParseConfig(io.LimitReader(r.Body, maxPublicAccessBlockSize))
+	if err != nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrMalformedXML), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if err = blocker.SetPublicAccessBlock(ctx, bucket, config); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
+}
+
+// GetBucketPublicAccessBlockHandler - Returns public access block configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetPublicAccessBlock.html
+func (api objectAPIHandlers) GetBucketPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "GetBucketPublicAccessBlock")
+
+	defer logger.AuditLog(w, r, "GetBucketPublicAccessBlock", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.GetBucketPolicyAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	blocker, ok := unwrapObjectLayer(objAPI).(PublicAccessBlocker)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Check if bucket exists
+	if _, err := objAPI.GetBucketInfo(ctx, bucket); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	config, err := blocker.GetPublicAccessBlock(ctx, bucket)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	configData, err := xml.Marshal(config)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Write public access block configuration to client
+	writeSuccessResponseXML(w, configData)
+}
+
+// DeleteBucketPublicAccessBlockHandler - Removes public access block configuration
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeletePublicAccessBlock.html
+func (api objectAPIHandlers) DeleteBucketPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "DeleteBucketPublicAccessBlock")
+
+	defer logger.AuditLog(w, r, "DeleteBucketPublicAccessBlock", mustGetClaimsFromToken(r))
+
+	objAPI := api.ObjectAPI()
+	if objAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.PutBucketPolicyAction, bucket, ""); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	blocker, ok := unwrapObjectLayer(objAPI).(PublicAccessBlocker)
+	if !ok {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNotImplemented), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	// Check if bucket exists
+	if _, err := objAPI.GetBucketInfo(ctx, bucket); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if err := blocker.DeletePublicAccessBlock(ctx, bucket); err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	writeSuccessNoContent(w)
+}
@@ -0,0 +1,121 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/RTradeLtd/s3x/pkg/bucket/publicaccess"
+)
+
+// publicAccessBlockLayer is an object layer that blocks the public access of config
+type publicAccessBlockLayer struct {
+	ObjectLayer
+	config publicaccess.Config
+	err    error
+}
+
+func (l publicAccessBlockLayer) SetPublicAccessBlock(ctx context.Context, bucket string, config *publicaccess.Config) error {
+	return NotImplemented{}
+}
+
+func (l publicAccessBlockLayer) GetPublicAccessBlock(ctx context.Context, bucket string) (*publicaccess.Config, error) {
+	return nil, PublicAccessBlockNotFound{Bucket: bucket}
+}
+
+func (l publicAccessBlockLayer) DeletePublicAccessBlock(ctx context.Context, bucket string) error {
+	return NotImplemented{}
+}
+
+func (l publicAccessBlockLayer) PublicAccessBlock(ctx context.Context, bucket string) (publicaccess.Config, error) {
+	return l.config, l.err
+}
+
+func TestCheckPublicAccess(t *testing.T) {
+	publicPolicy, err := policy.ParseConfig(strings.NewReader(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::mybucket/*"]}]}`), "mybucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicACL, err := http.NewRequest(http.MethodPut, "http://localhost/mybucket/object", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicACL.Header.Set("x-amz-acl", "public-read")
+
+	testCases := []struct {
+		objAPI         ObjectLayer
+		expectedACL    APIErrorCode
+		expectedPolicy APIErrorCode
+	}{
+		// Object layers that can not block public access
+		{publicAccessBlockLayer{}.ObjectLayer, ErrNone, ErrNone},
+		// Nothing blocked
+		{publicAccessBlockLayer{}, ErrNone, ErrNone},
+		{publicAccessBlockLayer{config: publicaccess.Config{BlockPublicAcls: true}}, ErrAccessDenied, ErrNone},
+		{publicAccessBlockLayer{config: publicaccess.Config{BlockPublicPolicy: true}}, ErrNone, ErrAccessDenied},
+		// All public access is blocked if the configuration can not be read
+		{publicAccessBlockLayer{err: errors.New("unreadable")}, ErrAccessDenied, ErrAccessDenied},
+	}
+	for i, tc := range testCases {
+		if s3Err := checkPublicACL(context.Background(), tc.objAPI, "mybucket", publicACL); s3Err != tc.expectedACL {
+			t.Fatalf("Test case %d: Expected ACL check %v but got %v", i+1, tc.expectedACL, s3Err)
+		}
+		if s3Err := checkPublicPolicy(context.Background(), tc.objAPI, "mybucket", publicPolicy); s3Err != tc.expectedPolicy {
+			t.Fatalf("Test case %d: Expected policy check %v but got %v", i+1, tc.expectedPolicy, s3Err)
+		}
+	}
+}
+
+func TestPublicAccessBlockHandlers(t *testing.T) {
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		return publicAccessBlockLayer{ObjectLayer: objLayer, config: publicaccess.Config{BlockPublicAcls: true}}
+	})
+	defer tb.TearDown()
+	if err := tb.objLayer.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cred := globalActiveCred
+	// the configuration of the gateway behind its locker is read, instead of failing with NotImplemented
+	req, err := newTestSignedRequestV4(http.MethodGet, "http://127.0.0.1:9000/bucket?publicAccessBlock=", 0, nil, cred.AccessKey, cred.SecretKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for a bucket without a configuration, but got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+
+	req, err = newTestSignedRequestV4(http.MethodPut, "http://127.0.0.1:9000/bucket/object", 4, bytes.NewReader([]byte("data")),
+		cred.AccessKey, cred.SecretKey, map[string]string{"x-amz-acl": "public-read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for a public ACL, but got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+}
@@ -21,9 +21,11 @@ import (
 	"crypto/x509"
 	"encoding/gob"
 	"errors"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -186,6 +188,11 @@ func handleCommonEnvVars() {
 			}
 			globalDomainNames = append(globalDomainNames, domainName)
 		}
+		// Match the longest domain first, so the bucket of a request to
+		// a subdomain of another domain is the name below the subdomain.
+		sort.SliceStable(globalDomainNames, func(i, j int) bool {
+			return len(globalDomainNames[i]) > len(globalDomainNames[j])
+		})
 	}
 
 	publicIPs := env.Get(config.EnvPublicIPs, "")
@@ -266,6 +273,69 @@ func getTLSConfig() (x509Certs []*x509.Certificate, c *certs.Certs, secureConn b
 		return nil, nil, false, err
 	}
 
+	// Certificates of domains are kept in subdirectories of the certs
+	// directory, and are served to clients that request a server name
+	// they are valid for, such as bucket.example.com.
+	domainCerts, err := getDomainCertDirs()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for _, dir := range domainCerts {
+		certFile, keyFile := filepath.Join(dir, publicCertFile), filepath.Join(dir, privateKeyFile)
+		domainX509Certs, err := config.ParsePublicCertFile(certFile)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if err = c.AddCertificate(certFile, keyFile); err != nil {
+			return nil, nil, false, err
+		}
+		x509Certs = append(x509Certs, domainX509Certs...)
+	}
 	secureConn = true
 	return x509Certs, c, secureConn, nil
 }
+
+// getDomainCertDirs returns the subdirectories of the certs directory
+// with a certificate and key pair, other than the directory of CAs.
+func getDomainCertDirs() ([]string, error) {
+	entries, err := ioutil.ReadDir(globalCertsDir.Get())
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == certsCADir {
+			continue
+		}
+		dir := filepath.Join(globalCertsDir.Get(), entry.Name())
+		if isFile(filepath.Join(dir, publicCertFile)) && isFile(filepath.Join(dir, privateKeyFile)) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// checkDomainCerts warns about the domains of virtual host style
+// requests that none of the TLS certificates is valid for.
+func checkDomainCerts() {
+	if !globalIsSSL {
+		return
+	}
+	for _, domainName := range globalDomainNames {
+		if !certsCoverDomain(globalPublicCerts, domainName) {
+			logger.Info("WARNING: No certificate is valid for the buckets of domain %s, add one for *.%s to %s",
+				domainName, domainName, globalCertsDir.Get())
+		}
+	}
+}
+
+// certsCoverDomain returns true if one of the certificates is valid
+// for the buckets of a domain of virtual host style requests.
+func certsCoverDomain(x509Certs []*x509.Certificate, domainName string) bool {
+	for _, cert := range x509Certs {
+		if cert.VerifyHostname("bucket."+domainName) == nil {
+			return true
+		}
+	}
+	return false
+}
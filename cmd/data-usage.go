@@ -123,6 +123,10 @@ func storeDataUsageInBackend(ctx context.Context, objAPI ObjectLayer, gui <-chan
 }
 
 func loadDataUsageFromBackend(ctx context.Context, objAPI ObjectLayer) (DataUsageInfo, error) {
+	if reporter, ok := unwrapObjectLayer(objAPI).(DataUsageReporter); ok {
+		return reporter.DataUsageInfo(ctx)
+	}
+
 	var dataUsageInfoJSON bytes.Buffer
 
 	err := objAPI.GetObject(ctx, dataUsageBucket, dataUsageObjName, 0, -1, &dataUsageInfoJSON, "", ObjectOptions{})
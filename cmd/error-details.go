@@ -0,0 +1,63 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+// ErrorDetail - one error of the chain of errors that caused a failed request,
+// from the outermost error to the root cause.
+type ErrorDetail struct {
+	// Source is where the error came from, such as ledger, datastore, or grpc
+	Source string `json:"source"`
+	// Code is the status code of the error, such as a gRPC status code, if it has one
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+type errorDetailsKey struct{}
+
+// withErrorDetails - returns a context in which the object layer can set the error details
+// of a request that asked for them, in the response headers h.
+func withErrorDetails(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, errorDetailsKey{}, h)
+}
+
+// ErrorDetailsRequested - returns true if the request of ctx asked for the details of its errors
+func ErrorDetailsRequested(ctx context.Context) bool {
+	_, ok := ctx.Value(errorDetailsKey{}).(http.Header)
+	return ok
+}
+
+// SetErrorDetails - sets the details of the error of the request of ctx in the response,
+// if the request asked for them. The details of a later error replace those of an earlier one.
+func SetErrorDetails(ctx context.Context, details []ErrorDetail) {
+	h, ok := ctx.Value(errorDetailsKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return
+	}
+	h.Set(xhttp.S3xErrorDetails, string(data))
+}
@@ -0,0 +1,55 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+func TestErrorDetails(t *testing.T) {
+	details := []ErrorDetail{
+		{Source: "gateway", Message: "dag client error: unavailable"},
+		{Source: "temporalx", Code: "Unavailable", Message: "unavailable"},
+	}
+	const want = `[{"source":"gateway","message":"dag client error: unavailable"},` +
+		`{"source":"temporalx","code":"Unavailable","message":"unavailable"}]`
+
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9000/bucket/object", nil)
+	rec := httptest.NewRecorder()
+	ctx := newContext(req, rec, "GetObject")
+	if ErrorDetailsRequested(ctx) {
+		t.Fatal("expected error details to not be requested")
+	}
+	SetErrorDetails(ctx, details)
+	if v := rec.Header().Get(xhttp.S3xErrorDetails); v != "" {
+		t.Fatalf("expected no error details, got %s", v)
+	}
+
+	req.Header.Set(xhttp.S3xDebugErrors, "true")
+	ctx = newContext(req, rec, "GetObject")
+	if !ErrorDetailsRequested(ctx) {
+		t.Fatal("expected error details to be requested")
+	}
+	SetErrorDetails(ctx, details)
+	if v := rec.Header().Get(xhttp.S3xErrorDetails); v != want {
+		t.Fatalf("expected error details %s, got %s", want, v)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	iampolicy "github.com/RTradeLtd/s3x/pkg/iam/policy"
+)
+
+// ExtensionCaller - the credential of an authenticated call to the extension apis of a gateway,
+// which are served next to the S3 api and authorized with the same credentials and policies.
+type ExtensionCaller struct {
+	cred       auth.Credentials
+	owner      bool
+	claims     map[string]interface{}
+	conditions map[string][]string
+}
+
+// AccessKey - returns the access key of the caller.
+func (c ExtensionCaller) AccessKey() string {
+	return c.cred.AccessKey
+}
+
+// IsOwner - returns true if the caller has the root credential.
+func (c ExtensionCaller) IsOwner() bool {
+	return c.owner
+}
+
+// IsAllowed - returns true if the caller may do action on a bucket or an object of it. The IAM policies of
+// the caller and the bucket policy are evaluated, the root credential is allowed everything unless the object
+// layer denies it by default, then only the bucket policy applies like for S3 requests without the break-glass key.
+func (c ExtensionCaller) IsAllowed(action policy.Action, bucket, object string) bool {
+	if c.owner {
+		restricter, ok := unwrapObjectLayer(newObjectLayerFn()).(OwnerRestricter)
+		if !ok || !restricter.DenyOwnerByDefault() {
+			return true
+		}
+	} else if globalIAMSys != nil && globalIAMSys.IsAllowed(iampolicy.Args{
+		AccountName:     c.cred.AccessKey,
+		Action:          iampolicy.Action(action),
+		BucketName:      bucket,
+		ConditionValues: c.conditions,
+		ObjectName:      object,
+		Claims:          c.claims,
+	}) {
+		return true
+	}
+	return globalPolicySys != nil && globalPolicySys.IsAllowed(policy.Args{
+		AccountName:     c.cred.AccessKey,
+		Action:          action,
+		BucketName:      bucket,
+		ConditionValues: c.conditions,
+		ObjectName:      object,
+	})
+}
+
+// AuthenticateExtensionRequest - verifies the S3 signature of an http request to the extension apis of a
+// gateway, signed like a request to the S3 api, and returns its caller.
+func AuthenticateExtensionRequest(ctx context.Context, r *http.Request) (ExtensionCaller, error) {
+	var cred auth.Credentials
+	var owner bool
+	var s3Err APIErrorCode
+	switch getRequestAuthType(r) {
+	case authTypePresignedV2, authTypeSignedV2:
+		if s3Err = isReqAuthenticatedV2(r); s3Err == ErrNone {
+			cred, owner, s3Err = getReqAccessKeyV2(r)
+		}
+	case authTypeSigned, authTypePresigned:
+		if s3Err = isReqAuthenticated(ctx, r, authRegion(), serviceS3); s3Err == ErrNone {
+			cred, owner, s3Err = getReqAccessKeyV4(r, authRegion(), serviceS3)
+		}
+	default:
+		s3Err = ErrAccessDenied
+	}
+	if s3Err != ErrNone {
+		return ExtensionCaller{}, extensionAuthErr(s3Err)
+	}
+	claims, s3Err := checkClaimsFromToken(r, cred)
+	if s3Err != ErrNone {
+		return ExtensionCaller{}, extensionAuthErr(s3Err)
+	}
+	return ExtensionCaller{
+		cred:       cred,
+		owner:      owner,
+		claims:     claims,
+		conditions: getConditionValues(r, "", cred.AccessKey, claims),
+	}, nil
+}
+
+// ExtensionCallSignature - returns the signature of a gRPC call to the extension apis of a gateway, the hex
+// encoded HMAC-SHA256 of the date of the call in the ISO8601 basic format, a random nonce, and the full method
+// name, separated by newlines, with the secret key of the caller.
+func ExtensionCallSignature(secretKey, date, nonce, method string) string {
+	return hex.EncodeToString(sumHMAC([]byte(secretKey), []byte(date+"\n"+nonce+"\n"+method)))
+}
+
+// AuthenticateExtensionCall - verifies the signature of a gRPC call to the extension apis of a gateway, see
+// ExtensionCallSignature, and returns its caller. The date of the call must be within the allowed clock skew
+// of now, its nonce must not have been used by another call of the access key within the clock skew, and the
+// session token of temporary credentials must be sent with the call.
+func AuthenticateExtensionCall(accessKey, sessionToken, date, nonce, method, signature string, now time.Time) (ExtensionCaller, error) {
+	signed, err := time.Parse(iso8601Format, date)
+	if err != nil {
+		return ExtensionCaller{}, extensionAuthErr(ErrMalformedDate)
+	}
+	if skew := now.Sub(signed); skew > globalMaxSkewTime || skew < -globalMaxSkewTime {
+		return ExtensionCaller{}, extensionAuthErr(ErrRequestTimeTooSkewed)
+	}
+	if len(nonce) < minExtensionCallNonce || len(nonce) > maxExtensionCallNonce {
+		return ExtensionCaller{}, errExtensionCallNonce
+	}
+	cred, owner, s3Err := checkKeyValid(accessKey)
+	if s3Err != ErrNone {
+		return ExtensionCaller{}, extensionAuthErr(s3Err)
+	}
+	want := ExtensionCallSignature(cred.SecretKey, date, nonce, method)
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return ExtensionCaller{}, extensionAuthErr(ErrSignatureDoesNotMatch)
+	}
+	caller, err := extensionCaller(cred, owner, sessionToken)
+	if err != nil {
+		return ExtensionCaller{}, err
+	}
+	// the nonce is only recorded for authenticated calls, so unsigned calls can not fill the nonces
+	if !globalExtensionCallNonces.use(accessKey, nonce, signed.Add(globalMaxSkewTime), now) {
+		return ExtensionCaller{}, errExtensionCallReplayed
+	}
+	return caller, nil
+}
+
+// ExtensionCallerOf - returns the caller of an access key that was already authenticated, such as by
+// AuthenticateExtensionRequest before the call was forwarded to the gRPC server of the extension apis.
+func ExtensionCallerOf(accessKey, sessionToken string) (ExtensionCaller, error) {
+	cred, owner, s3Err := checkKeyValid(accessKey)
+	if s3Err != ErrNone {
+		return ExtensionCaller{}, extensionAuthErr(s3Err)
+	}
+	return extensionCaller(cred, owner, sessionToken)
+}
+
+// extensionCaller returns the caller of a credential, after checking its session token.
+func extensionCaller(cred auth.Credentials, owner bool, sessionToken string) (ExtensionCaller, error) {
+	if subtle.ConstantTimeCompare([]byte(sessionToken), []byte(cred.SessionToken)) != 1 {
+		return ExtensionCaller{}, extensionAuthErr(ErrInvalidToken)
+	}
+	claims, err := parseClaimsFromToken(sessionToken)
+	if err != nil {
+		return ExtensionCaller{}, err
+	}
+	return ExtensionCaller{
+		cred:   cred,
+		owner:  owner,
+		claims: claims,
+		conditions: map[string][]string{
+			"userid":   {cred.AccessKey},
+			"username": {cred.AccessKey},
+		},
+	}, nil
+}
+
+const (
+	// the bounds of the length of the nonce of an extension api call
+	minExtensionCallNonce = 16
+	maxExtensionCallNonce = 128
+)
+
+var (
+	errExtensionCallNonce    = errors.New("The nonce of the call must have 16 to 128 characters.")
+	errExtensionCallReplayed = errors.New("The nonce of the call was already used.")
+)
+
+// globalExtensionCallNonces - the nonces of the extension api calls of this process.
+var globalExtensionCallNonces = &extensionCallNonces{}
+
+// extensionCallNonces - the nonces of the signed extension api calls whose dates are within the allowed clock
+// skew, so a captured call can not be replayed. Calls whose dates are no longer allowed are forgotten.
+type extensionCallNonces struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	pruneAt int
+}
+
+// use - records the nonce of a call of an access key until expires, and returns false if it was already used.
+func (n *extensionCallNonces) use(accessKey, nonce string, expires, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.expires == nil {
+		n.expires = make(map[string]time.Time)
+	}
+	key := accessKey + "\n" + nonce
+	if e, ok := n.expires[key]; ok && !now.After(e) {
+		return false
+	}
+	if len(n.expires) >= n.pruneAt {
+		for k, e := range n.expires {
+			if now.After(e) {
+				delete(n.expires, k)
+			}
+		}
+		n.pruneAt = 2*len(n.expires) + 1024
+	}
+	n.expires[key] = expires
+	return true
+}
+
+// extensionAuthErr returns the error of an extension api call that failed authentication.
+func extensionAuthErr(code APIErrorCode) error {
+	return errors.New(errorCodes.ToAPIErr(code).Description)
+}
@@ -0,0 +1,126 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/auth"
+)
+
+func TestAuthenticateExtensionCall(t *testing.T) {
+	objLayer, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(fsDir)
+	if err = newTestConfig(globalMinioDefaultRegion, objLayer); err != nil {
+		t.Fatalf("unable initialize config file, %s", err)
+	}
+	cred := globalActiveCred
+
+	const method = "/s3x.ExtensionAPI/RenameObject"
+	const nonce = "0123456789abcdef"
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Format(iso8601Format)
+	signature := ExtensionCallSignature(cred.SecretKey, date, nonce, method)
+	testCases := []struct {
+		accessKey, token, date, nonce, method, signature string
+		ok                                               bool
+	}{
+		// signed for another method
+		{cred.AccessKey, "", date, nonce, "/s3x.ExtensionAPI/CloneBucket", signature, false},
+		{cred.AccessKey, "", date, nonce, method, ExtensionCallSignature("wrong", date, nonce, method), false},
+		{"unknown", "", date, nonce, method, signature, false},
+		{cred.AccessKey, "token", date, nonce, method, signature, false},
+		{cred.AccessKey, "", "2020-04-01", nonce, method, signature, false},
+		{cred.AccessKey, "", now.Add(-time.Hour).Format(iso8601Format), nonce, method,
+			ExtensionCallSignature(cred.SecretKey, now.Add(-time.Hour).Format(iso8601Format), nonce, method), false},
+		// signed with another nonce
+		{cred.AccessKey, "", date, "fedcba9876543210", method, signature, false},
+		{cred.AccessKey, "", date, "short", method, ExtensionCallSignature(cred.SecretKey, date, "short", method), false},
+		{cred.AccessKey, "", date, nonce, method, signature, true},
+		// the same call again is a replay
+		{cred.AccessKey, "", date, nonce, method, signature, false},
+		{cred.AccessKey, "", date, "fedcba9876543210", method, ExtensionCallSignature(cred.SecretKey, date, "fedcba9876543210", method), true},
+	}
+	for i, testCase := range testCases {
+		caller, err := AuthenticateExtensionCall(testCase.accessKey, testCase.token, testCase.date,
+			testCase.nonce, testCase.method, testCase.signature, now)
+		if (err == nil) != testCase.ok {
+			t.Errorf("Test %d: expected ok %v, but got %v", i+1, testCase.ok, err)
+		}
+		if err == nil && (caller.AccessKey() != cred.AccessKey || !caller.IsOwner()) {
+			t.Errorf("Test %d: expected the owner %v, but got %+v", i+1, cred.AccessKey, caller)
+		}
+	}
+	// nonces are forgotten once the dates of their calls are no longer allowed
+	later := now.Add(2 * globalMaxSkewTime)
+	date = later.Format(iso8601Format)
+	if _, err := AuthenticateExtensionCall(cred.AccessKey, "", date, nonce, method,
+		ExtensionCallSignature(cred.SecretKey, date, nonce, method), later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExtensionCallerOf(cred.AccessKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtensionCallerOf("unknown", ""); err == nil {
+		t.Fatal("expected an unknown access key to fail")
+	}
+}
+
+func TestAuthenticateExtensionRequest(t *testing.T) {
+	objLayer, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(fsDir)
+	if err = newTestConfig(globalMinioDefaultRegion, objLayer); err != nil {
+		t.Fatalf("unable initialize config file, %s", err)
+	}
+
+	const url = "http://127.0.0.1:8889/v1/extension/rename"
+	ctx := context.Background()
+	caller, err := AuthenticateExtensionRequest(ctx, mustNewSignedRequest("POST", url, 0, nil, t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caller.AccessKey() != globalActiveCred.AccessKey || !caller.IsOwner() {
+		t.Fatalf("expected the owner, but got %+v", caller)
+	}
+	if !caller.IsAllowed("s3:GetObject", "bucket", "object") {
+		t.Fatal("expected the owner to be allowed")
+	}
+	if _, err := AuthenticateExtensionRequest(ctx, mustNewRequest("POST", url, 0, nil, t)); err == nil {
+		t.Fatal("expected an unsigned request to fail")
+	}
+	req := mustNewRequest("POST", url, 0, nil, t)
+	other, err := auth.CreateCredentials("myuser", "mypassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signRequestV4(req, other.AccessKey, other.SecretKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AuthenticateExtensionRequest(ctx, req); err == nil {
+		t.Fatal("expected a request signed with unknown credentials to fail")
+	}
+}
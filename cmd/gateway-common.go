@@ -383,6 +383,18 @@ func ComputeCompleteMultipartMD5(parts []CompletePart) string {
 	return getCompleteMultipartMD5(parts)
 }
 
+// AuthRegion returns the region signed requests must be scoped to, for gateways that sign
+// requests to themselves
+func AuthRegion() string {
+	return authRegion()
+}
+
+// ComputeCompleteMultipartChecksum calculates the composite checksum of a
+// multipart object from the base64 checksums of its parts
+func ComputeCompleteMultipartChecksum(algorithm string, partChecksums []string) (string, error) {
+	return getCompleteMultipartChecksum(algorithm, partChecksums)
+}
+
 // parse gateway sse env variable
 func parseGatewaySSE(s string) (gatewaySSE, error) {
 	l := strings.Split(s, ";")
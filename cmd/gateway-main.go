@@ -133,6 +133,9 @@ func StartGateway(ctx *cli.Context, gw Gateway) {
 	// Handle gateway specific env
 	gatewayHandleEnvVars()
 
+	// Check the certificates of the domains of virtual host style requests.
+	checkDomainCerts()
+
 	// Set system resources to maximum.
 	logger.LogIf(context.Background(), setMaxResources())
 
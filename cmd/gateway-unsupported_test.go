@@ -0,0 +1,83 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// gatewayTestBed serves the S3 and admin APIs with an object layer wrapped with a locker, the
+// way gateways are served, so the optional interfaces of gateways are tested through the locker.
+type gatewayTestBed struct {
+	fsDir    string
+	objLayer ObjectLayer
+	router   *mux.Router
+}
+
+// prepareGatewayTestBed - returns a test bed serving the object layer returned by wrap, which
+// implements the optional interfaces under test on top of a single node FS object layer.
+func prepareGatewayTestBed(t *testing.T, wrap func(ObjectLayer) ObjectLayer) *gatewayTestBed {
+	resetTestGlobals()
+	objLayer, fsDir, err := prepareFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = newTestConfig(globalMinioDefaultRegion, objLayer); err != nil {
+		os.RemoveAll(fsDir)
+		t.Fatalf("unable initialize config file, %s", err)
+	}
+	newAllSubsystems()
+	globalIAMSys.Init(objLayer)
+	buckets, err := objLayer.ListBuckets(context.Background())
+	if err != nil {
+		os.RemoveAll(fsDir)
+		t.Fatal(err)
+	}
+	globalPolicySys.Init(buckets, objLayer)
+
+	globalObjLayerMutex.Lock()
+	globalObjectAPI = NewGatewayLayerWithLocker(wrap(objLayer))
+	globalObjLayerMutex.Unlock()
+
+	router := mux.NewRouter().SkipClean(true)
+	registerAdminRouter(router, true, true)
+	registerAPIRouter(router, true, false)
+	return &gatewayTestBed{fsDir: fsDir, objLayer: objLayer, router: router}
+}
+
+// TearDown - removes the data of the test bed.
+func (tb *gatewayTestBed) TearDown() {
+	resetGlobalObjectAPI()
+	os.RemoveAll(tb.fsDir)
+}
+
+func TestUnwrapObjectLayer(t *testing.T) {
+	objLayer := publicAccessBlockLayer{}
+	if _, ok := NewGatewayLayerWithLocker(objLayer).(PublicAccessBlocker); ok {
+		t.Fatal("expected the locker to hide the optional interfaces of the gateway")
+	}
+	if _, ok := unwrapObjectLayer(NewGatewayLayerWithLocker(objLayer)).(PublicAccessBlocker); !ok {
+		t.Fatal("expected the optional interfaces of the gateway behind its locker")
+	}
+	if _, ok := unwrapObjectLayer(objLayer).(PublicAccessBlocker); !ok {
+		t.Fatal("expected object layers without a locker to be returned")
+	}
+}
@@ -228,7 +228,7 @@ func (l *b2Objects) StorageInfo(ctx context.Context, _ bool) (si minio.StorageIn
 }
 
 // MakeBucket creates a new container on B2 backend.
-func (l *b2Objects) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (l *b2Objects) MakeBucketWithLocation(ctx context.Context, bucket string, opts minio.BucketOptions) error {
 	// location is ignored for B2 backend.
 
 	// All buckets are set to private by default.
@@ -421,16 +421,16 @@ func (l *gcsGateway) StorageInfo(ctx context.Context, _ bool) (si minio.StorageI
 }
 
 // MakeBucketWithLocation - Create a new container on GCS backend.
-func (l *gcsGateway) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (l *gcsGateway) MakeBucketWithLocation(ctx context.Context, bucket string, opts minio.BucketOptions) error {
 	bkt := l.client.Bucket(bucket)
 
 	// we'll default to the us multi-region in case of us-east-1
-	if location == "us-east-1" {
-		location = "us"
+	if opts.Location == "us-east-1" {
+		opts.Location = "us"
 	}
 
 	err := bkt.Create(ctx, l.projectID, &storage.BucketAttrs{
-		Location: location,
+		Location: opts.Location,
 	})
 	logger.LogIf(ctx, err)
 	return gcsToObjectError(err, bucket)
@@ -341,7 +341,7 @@ func ossIsValidBucketName(bucket string) bool {
 }
 
 // MakeBucketWithLocation creates a new container on OSS backend.
-func (l *ossObjects) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (l *ossObjects) MakeBucketWithLocation(ctx context.Context, bucket string, opts minio.BucketOptions) error {
 	if !ossIsValidBucketName(bucket) {
 		logger.LogIf(ctx, minio.BucketNameInvalid{Bucket: bucket})
 		return minio.BucketNameInvalid{Bucket: bucket}
@@ -6,6 +6,10 @@ import (
 
 type bucketLocker struct {
 	m sync.Map
+	// cluster also locks the buckets across the gateways of a cluster, with names starting with prefix,
+	// nil if the ledger is not shared
+	cluster clusterLocker
+	prefix  string
 }
 
 //read read locks on bucket and returns the unlock function,
@@ -14,7 +18,14 @@ func (b *bucketLocker) read(bucket string) func() {
 	load, _ := b.m.LoadOrStore(bucket, &sync.RWMutex{})
 	rw := load.(*sync.RWMutex)
 	rw.RLock()
-	return rw.RUnlock
+	if b.cluster == nil {
+		return rw.RUnlock
+	}
+	release := mustLock(b.cluster, b.prefix+bucket, true)
+	return func() {
+		release()
+		rw.RUnlock()
+	}
 }
 
 //write write locks on bucket and returns the unlock function,
@@ -23,5 +34,35 @@ func (b *bucketLocker) write(bucket string) func() {
 	load, _ := b.m.LoadOrStore(bucket, &sync.RWMutex{})
 	rw := load.(*sync.RWMutex)
 	rw.Lock()
-	return rw.Unlock
+	if b.cluster == nil {
+		return rw.Unlock
+	}
+	release := mustLock(b.cluster, b.prefix+bucket, false)
+	return func() {
+		release()
+		rw.Unlock()
+	}
+}
+
+// ledgerMutex is a mutex of the ledger, which is also locked across the gateways of a cluster if cluster is set
+type ledgerMutex struct {
+	mu      sync.Mutex
+	cluster clusterLocker
+	name    string
+	release func()
+}
+
+func (m *ledgerMutex) Lock() {
+	m.mu.Lock()
+	if m.cluster != nil {
+		m.release = mustLock(m.cluster, m.name, false)
+	}
+}
+
+func (m *ledgerMutex) Unlock() {
+	if m.release != nil {
+		m.release()
+		m.release = nil
+	}
+	m.mu.Unlock()
 }
@@ -0,0 +1,231 @@
+package s3x
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Buckets can be configured with a bandwidth for the data of their uploads (ingress) and downloads (egress), and the
+gateway with a bandwidth for all buckets. Each bandwidth is a token bucket of bytes: the data streams of the
+bucket take tokens as data is read from the client or written to it, and wait while the bucket is empty, so all
+concurrent uploads or downloads of a bucket share its bandwidth, and a bulk download job of one bucket can not
+use the bandwidth of the gateway beyond the bandwidth of its bucket. A stream of a bucket with a bandwidth also
+takes tokens from the bandwidth of the gateway.
+
+The bodies of PutObject and PutObjectPart are throttled as they are read from the client, before they are
+spooled or stored, and downloads as they are written to the client, after decompression. Copies, which read and
+write data within the gateway, are not throttled.
+
+A token bucket holds the tokens of at most a second, and at most maxBandwidthBurst bytes, so an idle bucket does
+not get a burst of more than a second of its bandwidth, and the tokens of large reads and writes are taken in
+parts that fit the burst. The bandwidth of a bucket is read from its config by every stream, so a change applies
+to the streams started after it, and to the token bucket shared with the streams in progress.
+*/
+
+// maxBandwidthBurst is the most bytes a token bucket holds
+const maxBandwidthBurst = 1024 * 1024
+
+// bandwidthWaitSeconds is the time data streams waited for bandwidth
+var bandwidthWaitSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "s3x",
+	Subsystem: "bandwidth",
+	Name:      "wait_seconds_total",
+	Help:      "Total seconds uploads (ingress) and downloads (egress) waited for the bandwidth of their bucket or the gateway",
+}, []string{"direction"})
+
+func init() {
+	prometheus.MustRegister(bandwidthWaitSeconds)
+}
+
+// bandwidth directions
+const (
+	bandwidthIngress = "ingress"
+	bandwidthEgress  = "egress"
+)
+
+// bandwidthLimiter holds the token buckets of the bandwidth of buckets and of the gateway
+type bandwidthLimiter struct {
+	ingress, egress *rate.Limiter // the bandwidth of the gateway, nil if unlimited
+
+	mu      sync.Mutex
+	buckets map[bandwidthKey]*rate.Limiter
+}
+
+// bandwidthKey is a bandwidth of a bucket
+type bandwidthKey struct {
+	bucket    string
+	direction string
+}
+
+// newBandwidthLimiter returns a limiter with the ingress and egress bandwidth of the gateway in bytes per second,
+// unlimited if 0
+func newBandwidthLimiter(ingress, egress int64) *bandwidthLimiter {
+	return &bandwidthLimiter{
+		ingress: newBandwidthBucket(ingress),
+		egress:  newBandwidthBucket(egress),
+		buckets: make(map[bandwidthKey]*rate.Limiter),
+	}
+}
+
+// newBandwidthBucket returns a token bucket of bytesPerSecond, nil if unlimited
+func newBandwidthBucket(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), bandwidthBurst(bytesPerSecond))
+}
+
+// bandwidthBurst returns the tokens a bucket of bytesPerSecond holds
+func bandwidthBurst(bytesPerSecond int64) int {
+	if bytesPerSecond > maxBandwidthBurst {
+		return maxBandwidthBurst
+	}
+	return int(bytesPerSecond)
+}
+
+// limiters returns the token buckets of the streams of a bucket in a direction with bytesPerSecond,
+// the bandwidth of the bucket is unlimited if 0
+func (b *bandwidthLimiter) limiters(bucket, direction string, bytesPerSecond int64) []*rate.Limiter {
+	if b == nil {
+		return nil
+	}
+	var limiters []*rate.Limiter
+	if l := b.bucket(bucket, direction, bytesPerSecond); l != nil {
+		limiters = append(limiters, l)
+	}
+	gateway := b.ingress
+	if direction == bandwidthEgress {
+		gateway = b.egress
+	}
+	if gateway != nil {
+		limiters = append(limiters, gateway)
+	}
+	return limiters
+}
+
+// bucket returns the token bucket of a bucket, updated to bytesPerSecond, nil if unlimited
+func (b *bandwidthLimiter) bucket(bucket, direction string, bytesPerSecond int64) *rate.Limiter {
+	key := bandwidthKey{bucket: bucket, direction: direction}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.buckets[key]
+	switch {
+	case bytesPerSecond <= 0:
+		delete(b.buckets, key)
+		return nil
+	case !ok:
+		l = newBandwidthBucket(bytesPerSecond)
+		b.buckets[key] = l
+	case l.Limit() != rate.Limit(bytesPerSecond):
+		l.SetLimit(rate.Limit(bytesPerSecond))
+		l.SetBurst(bandwidthBurst(bytesPerSecond))
+	}
+	return l
+}
+
+// reader returns r throttled to the ingress bandwidth of a bucket with the config and of the gateway
+func (b *bandwidthLimiter) reader(ctx context.Context, bucket string, config *BucketConfig, r io.Reader) io.Reader {
+	limiters := b.limiters(bucket, bandwidthIngress, config.GetIngressBytesPerSecond())
+	if len(limiters) == 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limiters: limiters}
+}
+
+// writer returns w throttled to the egress bandwidth of a bucket with the config and of the gateway
+func (b *bandwidthLimiter) writer(ctx context.Context, bucket string, config *BucketConfig, w io.Writer) io.Writer {
+	limiters := b.limiters(bucket, bandwidthEgress, config.GetEgressBytesPerSecond())
+	if len(limiters) == 0 {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, w: w, limiters: limiters}
+}
+
+// waitBandwidth takes n tokens from every limiter, in parts that fit their bursts
+func waitBandwidth(ctx context.Context, limiters []*rate.Limiter, direction string, n int) error {
+	start := time.Now()
+	defer func() {
+		if waited := time.Since(start); waited > time.Millisecond {
+			bandwidthWaitSeconds.WithLabelValues(direction).Add(waited.Seconds())
+		}
+	}()
+	for _, l := range limiters {
+		for left := n; left > 0; {
+			take := left
+			if burst := l.Burst(); take > burst {
+				take = burst
+			}
+			if err := l.WaitN(ctx, take); err != nil {
+				return err
+			}
+			left -= take
+		}
+	}
+	return nil
+}
+
+// throttledReader takes tokens for the bytes read from r
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := waitBandwidth(t.ctx, t.limiters, bandwidthIngress, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter takes tokens for the bytes written to w
+type throttledWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*rate.Limiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	if err := waitBandwidth(t.ctx, t.limiters, bandwidthEgress, len(p)); err != nil {
+		return 0, err
+	}
+	return t.w.Write(p)
+}
+
+// SetBucketBandwidth configures the bandwidth of uploads to and downloads from a bucket
+func (x *xObjects) SetBucketBandwidth(ctx context.Context, req *SetBucketBandwidthRequest) (*SetBucketBandwidthResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetIngressBytesPerSecond() < 0 || req.GetEgressBytesPerSecond() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "bandwidth can not be negative")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.IngressBytesPerSecond = req.GetIngressBytesPerSecond()
+		c.EgressBytesPerSecond = req.GetEgressBytesPerSecond()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, ingress-bytes-per-second: %v, egress-bytes-per-second: %v",
+		req.GetBucket(), req.GetIngressBytesPerSecond(), req.GetEgressBytesPerSecond())
+	return &SetBucketBandwidthResponse{
+		Bucket:                req.GetBucket(),
+		IngressBytesPerSecond: req.GetIngressBytesPerSecond(),
+		EgressBytesPerSecond:  req.GetEgressBytesPerSecond(),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Bandwidth(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const bandwidth = 100 * 1024
+	if _, err := gateway.SetBucketBandwidth(ctx, &SetBucketBandwidthRequest{
+		Bucket:                testBucket1,
+		IngressBytesPerSecond: bandwidth,
+		EgressBytesPerSecond:  bandwidth,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a burst of a second of bandwidth is taken at once, the rest of the data waits for half a second
+	data := bytes.Repeat([]byte("b"), bandwidth*3/2)
+	timed := func(fn func() error) time.Duration {
+		t.Helper()
+		start := time.Now()
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	put := func(bucket string) error {
+		_, err := gateway.PutObject(ctx, bucket, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+		return err
+	}
+	get := func(bucket string) error {
+		gr, err := gateway.GetObjectNInfo(ctx, bucket, testObject1, nil, nil, 0, minio.ObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		got, err := ioutil.ReadAll(gr)
+		if err == nil && !bytes.Equal(got, data) {
+			t.Fatalf("expected %v bytes, but got %v", len(data), len(got))
+		}
+		return err
+	}
+	const throttled = 400 * time.Millisecond
+	t.Run("Ingress", func(t *testing.T) {
+		if d := timed(func() error { return put(testBucket1) }); d < throttled {
+			t.Fatalf("expected the upload to be throttled, but it took %v", d)
+		}
+		if d := timed(func() error { return put(testBucket2) }); d >= throttled {
+			t.Fatalf("expected the upload to another bucket to not be throttled, but it took %v", d)
+		}
+	})
+	t.Run("Egress", func(t *testing.T) {
+		if d := timed(func() error { return get(testBucket1) }); d < throttled {
+			t.Fatalf("expected the download to be throttled, but it took %v", d)
+		}
+		if d := timed(func() error { return get(testBucket2) }); d >= throttled {
+			t.Fatalf("expected the download from another bucket to not be throttled, but it took %v", d)
+		}
+	})
+	t.Run("Gateway", func(t *testing.T) {
+		gateway.bandwidth = newBandwidthLimiter(0, bandwidth)
+		defer func() { gateway.bandwidth = newBandwidthLimiter(0, 0) }()
+		if d := timed(func() error { return get(testBucket2) }); d < throttled {
+			t.Fatalf("expected the download to be throttled by the gateway, but it took %v", d)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := gateway.SetBucketBandwidth(ctx, &SetBucketBandwidthRequest{Bucket: testBucket1, EgressBytesPerSecond: -1})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, but got %v", err)
+		}
+	})
+	t.Run("Unlimited", func(t *testing.T) {
+		if _, err := gateway.SetBucketBandwidth(ctx, &SetBucketBandwidthRequest{Bucket: testBucket1}); err != nil {
+			t.Fatal(err)
+		}
+		if d := timed(func() error { return get(testBucket1) }); d >= throttled {
+			t.Fatalf("expected the download to not be throttled, but it took %v", d)
+		}
+	})
+}
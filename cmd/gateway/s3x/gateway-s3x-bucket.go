@@ -3,40 +3,73 @@ package s3x
 import (
 	"context"
 	"log"
-	"time"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
 )
 
-var isTest = false
-
-// MakeBucket creates a new bucket container within TemporalX.
+// MakeBucket creates a new bucket container within TemporalX,
+// object lock, versioning, and default encryption options are stored in the bucket configuration.
 func (x *xObjects) MakeBucketWithLocation(
 	ctx context.Context,
-	name, location string,
+	name string,
+	opts minio.BucketOptions,
 ) error {
-	b := &Bucket{BucketInfo: BucketInfo{
-		Location: location,
-	}}
-	if !isTest { // creates consistent hashes for testing
-		b.BucketInfo.Created = time.Now().UTC()
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	if err := x.names.checkBucketName(name); err != nil {
+		return x.toMinioErr(ctx, err, name, "", "")
+	}
+	b := &Bucket{
+		BucketInfo: BucketInfo{
+			Location: x.regions.bucketLocation(opts.Location),
+			Created:  x.clock.Now().UTC(),
+		},
+		Config: bucketOptionsConfig(opts),
 	}
 	hash, err := x.ledgerStore.CreateBucket(ctx, name, b)
 	if err != nil {
-		return x.toMinioErr(err, name, "", "")
+		return x.toMinioErr(ctx, err, name, "", "")
 	}
 	log.Printf("bucket-name: %s\tbucket-hash: %s", name, hash)
 	return nil
 }
 
+// bucketOptionsConfig returns the configuration of a new bucket with the given options, nil if no options are set
+func bucketOptionsConfig(opts minio.BucketOptions) *BucketConfig {
+	if !opts.LockEnabled && !opts.VersioningEnabled && opts.SSEConfig == nil {
+		return nil
+	}
+	c := &BucketConfig{
+		ObjectLockEnabled: opts.LockEnabled,
+		Encryption:        encryptionConfig(opts.SSEConfig),
+	}
+	if opts.VersioningEnabled {
+		c.Versioning = &VersioningConfig{Enabled: true}
+	}
+	return c
+}
+
 // GetBucketInfo gets bucket metadata..
 func (x *xObjects) GetBucketInfo(
 	ctx context.Context,
 	bucket string,
 ) (bi minio.BucketInfo, err error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return bi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	defer finish()
 	b, err := x.ledgerStore.GetBucketInfo(ctx, bucket)
 	if err != nil {
-		return bi, x.toMinioErr(err, bucket, "", "")
+		return bi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	hash, err := x.ledgerStore.GetBucketHash(bucket)
+	if err != nil {
+		return bi, x.toMinioErr(ctx, err, bucket, "", "")
 	}
 	return minio.BucketInfo{
 		Name: bucket,
@@ -45,30 +78,124 @@ func (x *xObjects) GetBucketInfo(
 		// bucket the bucket actually has a created timestamp
 		// Created: time.Unix(0, 0),
 		Created: b.Created,
+		Hash:    hash,
 	}, nil
 }
 
+// BucketExists returns true if the bucket exists, without loading the bucket from ipfs
+func (x *xObjects) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return false, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	defer finish()
+	exists, err := x.ledgerStore.BucketExists(bucket)
+	return exists, x.toMinioErr(ctx, err, bucket, "", "")
+}
+
+// BucketHash returns the CID of the current root of a bucket, without loading the bucket from ipfs
+func (x *xObjects) BucketHash(ctx context.Context, bucket string) (string, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return "", x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	defer finish()
+	hash, err := x.ledgerStore.GetBucketHash(bucket)
+	return hash, x.toMinioErr(ctx, err, bucket, "", "")
+}
+
 // ListBuckets lists all S3 buckets
 func (x *xObjects) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
-	// TODO(bonedaddy): decide if we should handle a minio error here
-	names, err := x.ledgerStore.GetBucketNames()
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, "", "", "")
+	}
+	defer finish()
+	names, _, err := x.ledgerStore.ListBucketNames("", "", 0)
 	if err != nil {
-		return nil, err
+		return nil, x.toMinioErr(ctx, err, "", "", "")
 	}
-	var infos = make([]minio.BucketInfo, len(names))
-	for i, name := range names {
-		//TODO(George): detect context cancelation here (or in GetBucketInfo), as this could be a long running process
-		info, err := x.GetBucketInfo(ctx, name)
+	return x.listingBucketInfos(ctx, names)
+}
+
+// ListBucketsPage lists a page of S3 buckets sorted by name, or by creation time
+func (x *xObjects) ListBucketsPage(ctx context.Context, opts minio.ListBucketsOptions) (minio.ListBucketsInfo, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return minio.ListBucketsInfo{}, x.toMinioErr(ctx, err, "", "", "")
+	}
+	defer finish()
+	if opts.SortByCreated {
+		// the creation time of every bucket is needed to find the page
+		names, _, err := x.ledgerStore.ListBucketNames(opts.Prefix, "", 0)
+		if err != nil {
+			return minio.ListBucketsInfo{}, x.toMinioErr(ctx, err, "", "", "")
+		}
+		infos, err := x.listingBucketInfos(ctx, names)
+		if err != nil {
+			return minio.ListBucketsInfo{}, err
+		}
+		return minio.PaginateBuckets(infos, opts)
+	}
+	names, truncated, err := x.ledgerStore.ListBucketNames(opts.Prefix, opts.ContinuationToken, opts.MaxBuckets)
+	if err != nil {
+		return minio.ListBucketsInfo{}, x.toMinioErr(ctx, err, "", "", "")
+	}
+	infos, err := x.listingBucketInfos(ctx, names)
+	if err != nil {
+		return minio.ListBucketsInfo{}, err
+	}
+	result := minio.ListBucketsInfo{Buckets: infos}
+	if truncated {
+		result.NextContinuationToken = names[len(names)-1]
+	}
+	return result, nil
+}
+
+// listingBucketInfos returns the infos of buckets from their info records, with the current hashes of the buckets
+func (x *xObjects) listingBucketInfos(ctx context.Context, names []string) ([]minio.BucketInfo, error) {
+	infos := make([]minio.BucketInfo, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := x.ledgerStore.listingBucketInfo(ctx, name)
+		if err == ErrLedgerBucketDoesNotExist {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, x.toMinioErr(ctx, err, name, "", "")
+		}
+		hash, err := x.ledgerStore.GetBucketHash(name)
+		if err == ErrLedgerBucketDoesNotExist {
+			continue
+		}
 		if err != nil {
-			return nil, err // no need to handle GetBucketInfo parses error accordingly
+			return nil, x.toMinioErr(ctx, err, name, "", "")
 		}
-		infos[i] = info
+		infos = append(infos, minio.BucketInfo{Name: name, Created: info.GetCreated(), Hash: hash})
 	}
 	return infos, nil
 }
 
 // DeleteBucket deletes a bucket on S3
 func (x *xObjects) DeleteBucket(ctx context.Context, name string) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
 	// TODO(bonedaddy): implement removal call from TemporalX
-	return x.toMinioErr(x.ledgerStore.DeleteBucket(name), name, "", "")
+	return x.toMinioErr(ctx, x.ledgerStore.DeleteBucket(ctx, name), name, "", "")
 }
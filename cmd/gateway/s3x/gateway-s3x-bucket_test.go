@@ -3,9 +3,9 @@ package s3x
 import (
 	"context"
 	"testing"
-	"time"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
 )
 
 const (
@@ -33,7 +33,6 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 	type args struct {
 		bucketName, objectName, bucketHash, objectHash string
 	}
-	then := time.Now().UTC()
 	t.Run("MakeBucketWithLocation", func(t *testing.T) {
 		tests := []struct {
 			name    string
@@ -48,7 +47,7 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 				err := gateway.MakeBucketWithLocation(
 					ctx,
 					tt.args.bucketName,
-					"us-east-1",
+					minio.BucketOptions{Location: "us-east-1"},
 				)
 				if (err != nil) != tt.wantErr {
 					t.Fatalf("MakeBucketWithLocation() err %v, wantErr %v", err, tt.wantErr)
@@ -57,14 +56,77 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 		}
 	})
 	t.Run("Bucket Created Time Test", func(t *testing.T) {
-		t.Skip("set current time is skipped for testing")
-		now := time.Now().UTC()
 		info, err := gateway.GetBucketInfo(ctx, testBucket1)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if info.Created.After(now) || info.Created.Before(then) {
-			t.Fatal("bad bucket created time")
+		if !info.Created.Equal(gateway.clock.Now()) {
+			t.Fatalf("expected bucket created time %v from the gateway clock, but got %v", gateway.clock.Now(), info.Created)
+		}
+	})
+	t.Run("Bucket Hash", func(t *testing.T) {
+		hash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetBucketInfo(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Hash != hash {
+			t.Fatalf("expected bucket hash %v, but got %v", hash, info.Hash)
+		}
+		if got, err := gateway.BucketHash(ctx, testBucket1); err != nil || got != hash {
+			t.Fatalf("expected bucket hash %v, but got %v, %v", hash, got, err)
+		}
+		if _, err := gateway.BucketHash(ctx, testBucket2); err != (minio.BucketNotFound{Bucket: testBucket2}) {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+		infos, err := gateway.ListBuckets(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 1 || infos[0].Hash != hash {
+			t.Fatalf("expected bucket hash %v in the listing, but got %+v", hash, infos)
+		}
+	})
+	t.Run("Bucket Options", func(t *testing.T) {
+		const bucket = "optionsbucket"
+		sse := &bucketsse.BucketSSEConfig{Rules: []bucketsse.SSERule{{
+			DefaultEncryptionAction: bucketsse.EncryptionAction{Algorithm: bucketsse.AES256},
+		}}}
+		opts := minio.BucketOptions{Location: "us-east-1", LockEnabled: true, VersioningEnabled: true, SSEConfig: sse}
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, opts); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := gateway.DeleteBucket(ctx, bucket); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		gateway.restart(t)
+		c, err := gateway.ledgerStore.GetBucketConfig(ctx, bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.GetObjectLockEnabled() || !c.GetVersioning().GetEnabled() {
+			t.Fatalf("expected object lock and versioning to be enabled, but got %+v", c)
+		}
+		got, err := gateway.GetBucketSSEConfig(ctx, bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Rules[0].DefaultEncryptionAction != sse.Rules[0].DefaultEncryptionAction {
+			t.Fatalf("expected encryption %+v, but got %+v", sse.Rules[0], got.Rules[0])
+		}
+		if err := gateway.DeleteBucketSSEConfig(ctx, bucket); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetBucketSSEConfig(ctx, bucket); err != (minio.BucketSSEConfigNotFound{Bucket: bucket}) {
+			t.Fatalf("expected BucketSSEConfigNotFound, but got %v", err)
+		}
+		if _, err := gateway.GetBucketSSEConfig(ctx, testBucket1); err != (minio.BucketSSEConfigNotFound{Bucket: testBucket1}) {
+			t.Fatalf("expected BucketSSEConfigNotFound for a bucket created without options, but got %v", err)
 		}
 	})
 	t.Run("GetBucketInfo", func(t *testing.T) {
@@ -88,6 +150,14 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 			})
 		}
 	})
+	t.Run("BucketExists", func(t *testing.T) {
+		if exists, err := gateway.BucketExists(ctx, testBucket1); err != nil || !exists {
+			t.Fatalf("expected %v to exist, but got %v, %v", testBucket1, exists, err)
+		}
+		if exists, err := gateway.BucketExists(ctx, testBucket2); err != nil || exists {
+			t.Fatalf("expected %v not to exist, but got %v, %v", testBucket2, exists, err)
+		}
+	})
 	t.Run("ListBuckets", func(t *testing.T) {
 		var (
 			wantNames = map[string]bool{
@@ -127,6 +197,9 @@ func testS3XBucket(t *testing.T, dsType DSType) {
 				if (err != nil) != tt.wantErr {
 					t.Fatalf("DeleteBucket() err %v, wantErr %v", err, tt.wantErr)
 				}
+				if exists, err := gateway.BucketExists(ctx, tt.args.bucketName); err != nil || exists {
+					t.Fatalf("expected %v not to exist after deletion, but got %v, %v", tt.args.bucketName, exists, err)
+				}
 			})
 		}
 	})
@@ -0,0 +1,54 @@
+package s3x
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+S3 DeleteObjects requests remove at most 1000 objects, and requests with more keys are rejected as malformed, so
+removing a large part of a bucket takes many requests, each listing its keys first. BulkDeleteObjects removes any
+number of objects of a bucket by name, or all objects with a prefix, and the gateway splits them into batches of at
+most the ledger batch size, 1000 by default like S3. Each batch holds the bucket lock and saves the bucket once,
+so S3 requests to the bucket proceed between batches, and memory is bounded by the batch size besides the search
+index entries of the removed objects.
+
+Objects under a retention or a legal hold, or retained by the WORM retention of the bucket, are kept and reported,
+like S3 DeleteObjects requests without the governance bypass, and the removed objects are trashed or versioned like
+other deletions. A bulk deletion that
+fails keeps the batches it already removed.
+*/
+
+// BulkDeleteObjects removes any number of objects of a bucket, by name or by prefix, in batches
+func (x *xObjects) BulkDeleteObjects(ctx context.Context, req *BulkDeleteObjectsRequest) (*BulkDeleteObjectsResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	switch {
+	case len(req.GetObjects()) > 0 && req.GetPrefix() != "":
+		return nil, status.Error(codes.InvalidArgument, "objects and prefix can not be set together")
+	case len(req.GetObjects()) == 0 && req.GetPrefix() == "":
+		return nil, status.Error(codes.InvalidArgument, "objects or prefix are required")
+	}
+	r, err := x.ledgerStore.BulkRemoveObjects(ctx, req.GetBucket(), req.GetObjects(), req.GetPrefix(), x.clock.Now())
+	if err != nil {
+		if r != nil {
+			log.Printf("bucket-name: %s, deleted: %v, batches: %v, error: %v", req.GetBucket(), r.removed, r.batches, err)
+		}
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, deleted: %v, missing: %v, locked: %v, batches: %v",
+		req.GetBucket(), r.removed, len(r.missing), len(r.locked), r.batches)
+	return &BulkDeleteObjectsResponse{
+		Bucket:  req.GetBucket(),
+		Deleted: int64(r.removed),
+		Missing: r.missing,
+		Locked:  r.locked,
+		Batches: int64(r.batches),
+	}, nil
+}
@@ -0,0 +1,102 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_BulkDeleteObjects(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.ledgerStore.batchSize = 2
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(object string, meta map[string]string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{UserDefined: meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		put(fmt.Sprintf("logs/%d", i), nil)
+	}
+	put("logs/held", map[string]string{"X-Amz-Object-Lock-Legal-Hold": "ON"})
+	put("a", nil)
+	put("b", nil)
+	exists := func(object string) bool {
+		t.Helper()
+		_, err := gateway.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{})
+		if _, ok := err.(minio.ObjectNotFound); ok {
+			return false
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, req := range []*BulkDeleteObjectsRequest{
+			{Objects: []string{"a"}},
+			{Bucket: testBucket1},
+			{Bucket: testBucket1, Objects: []string{"a"}, Prefix: "logs/"},
+		} {
+			if _, err := gateway.BulkDeleteObjects(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument for %v, but got %v", req, err)
+			}
+		}
+		_, err := gateway.BulkDeleteObjects(ctx, &BulkDeleteObjectsRequest{Bucket: "nosuchbucket", Prefix: "logs/"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("expected NotFound, but got %v", err)
+		}
+	})
+	t.Run("Objects", func(t *testing.T) {
+		resp, err := gateway.BulkDeleteObjects(ctx, &BulkDeleteObjectsRequest{
+			Bucket:  testBucket1,
+			Objects: []string{"a", "b", "a", "nosuchobject"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetDeleted() != 2 || resp.GetBatches() != 1 || len(resp.GetLocked()) != 0 {
+			t.Fatalf("unexpected response %v", resp)
+		}
+		if len(resp.GetMissing()) != 1 || resp.GetMissing()[0] != "nosuchobject" {
+			t.Fatalf("expected nosuchobject to be missing, but got %v", resp.GetMissing())
+		}
+		if exists("a") || exists("b") {
+			t.Fatal("expected a and b to be deleted")
+		}
+	})
+	t.Run("Prefix", func(t *testing.T) {
+		resp, err := gateway.BulkDeleteObjects(ctx, &BulkDeleteObjectsRequest{Bucket: testBucket1, Prefix: "logs/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetDeleted() != 5 || resp.GetBatches() != 3 || len(resp.GetMissing()) != 0 {
+			t.Fatalf("unexpected response %v", resp)
+		}
+		if len(resp.GetLocked()) != 1 || resp.GetLocked()[0] != "logs/held" {
+			t.Fatalf("expected logs/held to be locked, but got %v", resp.GetLocked())
+		}
+		for i := 0; i < 5; i++ {
+			if exists(fmt.Sprintf("logs/%d", i)) {
+				t.Fatalf("expected logs/%d to be deleted", i)
+			}
+		}
+		if !exists("logs/held") {
+			t.Fatal("expected the held object to be kept")
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Every call to TemporalX gets a deadline, so a node that stops responding fails the calls waiting on it instead
+of holding their goroutines, and the requests or background loops that made them, forever. The deadlines are set
+by interceptors of the connections to TemporalX, so every client of the gateway, the ledger, and the crdt
+datastore gets them, without each call site deriving its own context.
+
+Unary calls are given CallTimeout, and Persist, which fetches the data it pins, PinCallTimeout. The deadline is
+derived from the context of the call, so a request deadline that ends earlier is kept, and exceeding the request
+deadline still fails the request with OperationTimedOut. The file upload and download streams transfer objects
+of any size, so they are given StreamIdleTimeout for every message instead: a stream fails once TemporalX did not
+accept or return a message for that long, while the time the gateway spends between messages, such as waiting
+for a slow client, is not counted. Other streams, such as the pubsub stream of the crdt datastore, wait for
+messages by design and have no deadline.
+
+A call that exceeds its own deadline fails with the Unavailable gRPC code, which is returned to S3 clients as
+SlowDown (503), so they retry the request later instead of treating it as their own timeout.
+*/
+
+const (
+	// defaultCallTimeout is the deadline of unary TemporalX calls by default
+	defaultCallTimeout = 30 * time.Second
+	// defaultPinCallTimeout is the deadline of TemporalX Persist calls by default
+	defaultPinCallTimeout = 5 * time.Minute
+	// defaultStreamIdleTimeout is how long a file stream waits for TemporalX to accept or return a message by default
+	defaultStreamIdleTimeout = time.Minute
+)
+
+// persistMethod is the TemporalX call that pins data
+const persistMethod = "/pb.NodeAPI/Persist"
+
+// idleTimeoutStreams are the TemporalX streams with an idle timeout
+var idleTimeoutStreams = map[string]bool{
+	"/pb.FileAPI/UploadFile":   true,
+	"/pb.FileAPI/DownloadFile": true,
+}
+
+// callTimeoutsTotal counts the TemporalX calls that exceeded their deadline
+var callTimeoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "s3x",
+	Subsystem: "temporalx",
+	Name:      "call_timeouts_total",
+	Help:      "Total number of TemporalX calls that exceeded their deadline by method",
+}, []string{"method"})
+
+func init() {
+	prometheus.MustRegister(callTimeoutsTotal)
+}
+
+// callDeadlines are the deadlines of TemporalX calls
+type callDeadlines struct {
+	call       time.Duration
+	pin        time.Duration
+	streamIdle time.Duration
+}
+
+// newCallDeadlines returns the callDeadlines of the gateway configuration, the default of a deadline if 0
+func newCallDeadlines(call, pin, streamIdle time.Duration) (callDeadlines, error) {
+	if call < 0 || pin < 0 || streamIdle < 0 {
+		return callDeadlines{}, fmt.Errorf("TemporalX call deadlines must not be negative")
+	}
+	d := callDeadlines{call: defaultCallTimeout, pin: defaultPinCallTimeout, streamIdle: defaultStreamIdleTimeout}
+	if call > 0 {
+		d.call = call
+	}
+	if pin > 0 {
+		d.pin = pin
+	}
+	if streamIdle > 0 {
+		d.streamIdle = streamIdle
+	}
+	return d, nil
+}
+
+// dialOptions returns the interceptors that set the deadlines on the calls of a connection
+func (d callDeadlines) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(d.unary),
+		grpc.WithChainStreamInterceptor(d.stream),
+	}
+}
+
+// callTimeoutErr returns the error of a call that exceeded its deadline
+func callTimeoutErr(method string, timeout time.Duration) error {
+	return status.Errorf(codes.Unavailable, "TemporalX did not respond to %v within %v", method, timeout)
+}
+
+// unary calls a unary method with its deadline
+func (d callDeadlines) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	timeout := d.call
+	if method == persistMethod {
+		timeout = d.pin
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := invoker(callCtx, method, req, reply, cc, opts...)
+	if err != nil && ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+		callTimeoutsTotal.WithLabelValues(method).Inc()
+		return callTimeoutErr(method, timeout)
+	}
+	return err
+}
+
+// stream opens a stream, with an idle timeout for file streams
+func (d callDeadlines) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if !idleTimeoutStreams[method] {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	s := &idleTimeoutStream{method: method, timeout: d.streamIdle, serverStreams: desc.ServerStreams, cancel: cancel}
+	// opening the stream waits for TemporalX like its calls
+	s.waiting = 1
+	s.timer = time.AfterFunc(d.streamIdle, s.expire)
+	cs, err := streamer(streamCtx, desc, cc, method, opts...)
+	s.done()
+	if err != nil {
+		cancel()
+		return nil, s.err(err)
+	}
+	s.ClientStream = cs
+	return s, nil
+}
+
+// idleTimeoutStream cancels a stream when a message is not accepted or returned within the timeout
+type idleTimeoutStream struct {
+	grpc.ClientStream
+	method        string
+	timeout       time.Duration
+	serverStreams bool // set if the server returns a stream of messages, otherwise it returns one
+	cancel        context.CancelFunc
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	waiting int  // the calls waiting for TemporalX
+	expired bool // set once the stream was canceled by the timeout
+}
+
+// wait starts the timeout of a call if no other call is waiting
+func (s *idleTimeoutStream) wait() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting++; s.waiting == 1 {
+		s.timer.Reset(s.timeout)
+	}
+}
+
+// done stops the timeout once no call is waiting
+func (s *idleTimeoutStream) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting--; s.waiting <= 0 {
+		s.waiting = 0
+		s.timer.Stop()
+	}
+}
+
+// expire cancels the stream
+func (s *idleTimeoutStream) expire() {
+	s.mu.Lock()
+	s.expired = true
+	s.mu.Unlock()
+	callTimeoutsTotal.WithLabelValues(s.method).Inc()
+	s.cancel()
+}
+
+// err returns the error of a call, which failed with the timeout if the stream expired
+func (s *idleTimeoutStream) err(err error) error {
+	if err == nil {
+		return nil
+	}
+	s.mu.Lock()
+	expired := s.expired
+	s.mu.Unlock()
+	if expired {
+		return callTimeoutErr(s.method, s.timeout)
+	}
+	return err
+}
+
+func (s *idleTimeoutStream) SendMsg(m interface{}) error {
+	s.wait()
+	defer s.done()
+	return s.err(s.ClientStream.SendMsg(m))
+}
+
+func (s *idleTimeoutStream) RecvMsg(m interface{}) error {
+	s.wait()
+	defer s.done()
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		// the stream ended
+		s.cancel()
+	}
+	return s.err(err)
+}
@@ -0,0 +1,131 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hangingInvoker waits for the deadline of the call like a TemporalX node that stopped responding
+func hangingInvoker(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	<-ctx.Done()
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+// delayedStream returns a message after delay, or fails once its context is done
+type delayedStream struct {
+	grpc.ClientStream
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (s *delayedStream) RecvMsg(m interface{}) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-s.ctx.Done():
+		return status.FromContextError(s.ctx.Err()).Err()
+	}
+}
+
+func TestCallDeadlines(t *testing.T) {
+	d, err := newCallDeadlines(50*time.Millisecond, 100*time.Millisecond, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newCallDeadlines(-1, 0, 0); err == nil {
+		t.Fatal("expected negative deadlines to be rejected")
+	}
+	if defaults, _ := newCallDeadlines(0, 0, 0); defaults.call != defaultCallTimeout || defaults.pin != defaultPinCallTimeout || defaults.streamIdle != defaultStreamIdleTimeout {
+		t.Fatalf("expected the default deadlines, but got %+v", defaults)
+	}
+	ctx := context.Background()
+	x := &xObjects{}
+	t.Run("Unary", func(t *testing.T) {
+		start := time.Now()
+		err := d.unary(ctx, "/pb.NodeAPI/Dag", nil, nil, nil, hangingInvoker)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, but got %v", err)
+		}
+		if _, ok := x.toMinioErr(ctx, err, testBucket1, testObject1, "").(minio.SlowDown); !ok {
+			t.Fatalf("expected SlowDown, but got %v", x.toMinioErr(ctx, err, testBucket1, testObject1, ""))
+		}
+		if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+			t.Fatalf("expected the call deadline, but the call took %v", elapsed)
+		}
+	})
+	t.Run("Persist", func(t *testing.T) {
+		start := time.Now()
+		if err := d.unary(ctx, persistMethod, nil, nil, nil, hangingInvoker); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, but got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Fatalf("expected the pin deadline, but the call took %v", elapsed)
+		}
+	})
+	t.Run("Request-Deadline", func(t *testing.T) {
+		reqCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		err := d.unary(reqCtx, "/pb.NodeAPI/Dag", nil, nil, nil, hangingInvoker)
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("expected the request deadline to be kept, but got %v", err)
+		}
+		if _, ok := x.toMinioErr(ctx, err, testBucket1, testObject1, "").(minio.OperationTimedOut); !ok {
+			t.Fatalf("expected OperationTimedOut, but got %v", x.toMinioErr(ctx, err, testBucket1, testObject1, ""))
+		}
+	})
+	stream := func(method string, delay time.Duration) grpc.ClientStream {
+		t.Helper()
+		cs, err := d.stream(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, method,
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &delayedStream{ctx: ctx, delay: delay}, nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+	t.Run("Stream-Idle", func(t *testing.T) {
+		cs := stream("/pb.FileAPI/DownloadFile", time.Second)
+		if err := cs.RecvMsg(nil); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, but got %v", err)
+		}
+	})
+	t.Run("Stream-Slow-Reader", func(t *testing.T) {
+		// only the time waiting for TemporalX counts, not the time between messages
+		cs := stream("/pb.FileAPI/DownloadFile", 10*time.Millisecond)
+		for i := 0; i < 3; i++ {
+			if err := cs.RecvMsg(nil); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+	t.Run("Stream-Without-Deadline", func(t *testing.T) {
+		cs := stream("/pb.PubSubAPI/PubSub", 100*time.Millisecond)
+		if err := cs.RecvMsg(nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Stream-End", func(t *testing.T) {
+		cs, err := d.stream(ctx, &grpc.StreamDesc{ClientStreams: true}, nil, "/pb.FileAPI/UploadFile",
+			func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &delayedStream{ctx: ctx}, nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cs.RecvMsg(nil); err != nil {
+			t.Fatal(err)
+		}
+		// the stream context is released once the response of an upload was received
+		if err := cs.(*idleTimeoutStream).ClientStream.(*delayedStream).ctx.Err(); err != context.Canceled {
+			t.Fatalf("expected the stream context to be canceled, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+TemporalX does not report the storage available on its node, so the capacity of the node is configured, and
+the data stored by the gateway is sampled every capacity interval: the size of the objects of all buckets, of
+their trashed objects and noncurrent versions, and of the parts of multipart uploads in progress. Uploads with
+a declared size are rejected before any data is read if the sampled size, the uploads since the sample, and the
+declared size of the uploads in progress would exceed the capacity, so clients get a StorageFull error instead
+of an upload that fails when the node runs out of space. Uploads without a declared size are not checked, and
+count once they are stored.
+
+The parts of a multipart upload are checked as they are uploaded, completing an upload only links the stored
+parts. The capacity is not checked until the size of the stored data was sampled once, and other data on the
+node, snapshots, and data waiting for garbage collection are not counted, so the capacity should leave room
+for them.
+*/
+
+// defaultCapacityInterval is how often the size of the data stored by the gateway is sampled by default
+const defaultCapacityInterval = time.Minute
+
+// capacityChecker admits uploads while the data stored by the gateway fits the capacity of TemporalX
+type capacityChecker struct {
+	capacity int64 // the bytes TemporalX can store for the gateway, uploads are not checked if 0
+
+	mu       sync.Mutex
+	sampled  bool  // set once the stored size was sampled
+	used     int64 // the stored size at the last sample, and of the uploads since
+	reserved int64 // the declared size of uploads in progress
+}
+
+// reserve admits an upload of size bytes, release must be called with the stored size once the upload ended,
+// 0 if it failed, sizes below 0 are unknown and are admitted
+func (c *capacityChecker) reserve(size int64) (release func(stored int64), err error) {
+	if c == nil || c.capacity <= 0 {
+		return func(int64) {}, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size < 0 {
+		size = 0
+	}
+	if c.sampled && c.used+c.reserved+size > c.capacity {
+		return nil, ErrInsufficientCapacity
+	}
+	c.reserved += size
+	return func(stored int64) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.reserved -= size
+		c.used += stored
+	}, nil
+}
+
+// sample records the size of the data stored by the gateway
+func (c *capacityChecker) sample(used int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used = used
+	c.sampled = true
+}
+
+// storedBytes returns the size of the objects of every bucket
+func (x *xObjects) storedBytes(ctx context.Context) (map[string]int64, error) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		return nil, err
+	}
+	storage := make(map[string]int64, len(names))
+	for _, bucket := range names {
+		infos, err := x.ledgerStore.SearchObjects(ctx, bucket, func(*ObjectInfo) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			storage[bucket] += info.GetSize_()
+		}
+	}
+	return storage, nil
+}
+
+// sampleCapacity samples the size of the objects, trashed objects, noncurrent versions, and multipart parts
+// stored by the gateway
+func (x *xObjects) sampleCapacity(ctx context.Context) error {
+	usage, err := x.storageUsage(ctx, 0)
+	if err != nil {
+		return err
+	}
+	x.capacity.sample(usage.used())
+	return nil
+}
+
+// capacityLoop samples the size of the data stored by the gateway at startup and every interval,
+// until the gateway is shut down
+func (x *xObjects) capacityLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := x.sampleCapacity(x.ctx); err != nil && x.ctx.Err() == nil {
+			log.Printf("failed to sample the stored data size: %v", err)
+		}
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
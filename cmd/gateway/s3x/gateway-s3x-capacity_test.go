@@ -0,0 +1,88 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_CapacityChecker(t *testing.T) {
+	var disabled *capacityChecker
+	if _, err := disabled.reserve(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+	c := &capacityChecker{capacity: 10}
+	// uploads are admitted until the stored size is known
+	release, err := c.reserve(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release(0)
+	c.sample(4)
+	release, err = c.reserve(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the declared size of uploads in progress is reserved
+	if _, err := c.reserve(2); err != ErrInsufficientCapacity {
+		t.Fatalf("expected %v, but got %v", ErrInsufficientCapacity, err)
+	}
+	release(3)
+	if _, err := c.reserve(4); err != ErrInsufficientCapacity {
+		t.Fatalf("expected %v, but got %v", ErrInsufficientCapacity, err)
+	}
+	if _, err := c.reserve(3); err != nil {
+		t.Fatal(err)
+	}
+	// uploads without a declared size are admitted
+	if _, err := c.reserve(-1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3X_CapacityGateway(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("hello")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, "multipart", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObjectPart(ctx, testBucket1, "multipart", uploadID, 1, getTestPutObjectReader(t, []byte("part")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.capacity = &capacityChecker{capacity: 12}
+	if err := gateway.sampleCapacity(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.capacity.used != 9 {
+		t.Fatalf("expected the object and the part to be sampled, but got %v", gateway.capacity.used)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "large", getTestPutObjectReader(t, []byte("four")), minio.ObjectOptions{}); err != (minio.StorageFull{}) {
+		t.Fatalf("expected %v, but got %v", minio.StorageFull{}, err)
+	}
+	if _, err := gateway.PutObjectPart(ctx, testBucket1, "multipart", uploadID, 2, getTestPutObjectReader(t, []byte("four")), minio.ObjectOptions{}); err != (minio.StorageFull{}) {
+		t.Fatalf("expected %v, but got %v", minio.StorageFull{}, err)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "large", minio.ObjectOptions{}); err != (minio.ObjectNotFound{Bucket: testBucket1, Object: "large"}) {
+		t.Fatalf("expected the rejected object not to be stored, but got %v", err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "small", getTestPutObjectReader(t, []byte("abc")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// the stored object counts until the next sample
+	if _, err := gateway.PutObject(ctx, testBucket1, "more", getTestPutObjectReader(t, []byte("a")), minio.ObjectOptions{}); err != (minio.StorageFull{}) {
+		t.Fatalf("expected %v, but got %v", minio.StorageFull{}, err)
+	}
+}
@@ -0,0 +1,344 @@
+package s3x
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+TemporalX splits uploaded files into fixed size blocks, so inserting a byte at the start of a file changes every
+block of it, and a re-uploaded file with a small edit shares no blocks with the previous upload. Buckets can be
+configured with a chunker that splits the data of new objects in the gateway instead:
+
+* "size-<size>" cuts blocks of a fixed size, like TemporalX does.
+* "rabin" and "buzhash" cut blocks where a rolling hash of the last bytes of the data matches a mask, so block
+  boundaries depend on the content around them, and move with the content when bytes are inserted or removed.
+  Only the blocks around an edit change, the other blocks of the new upload have the hashes of the blocks of
+  the previous upload, and are stored once by IPFS. Rabin uses a Rabin-Karp polynomial hash of the last 48
+  bytes, and buzhash a cyclic polynomial hash of the last 32 bytes, both cut blocks of at least min and at most
+  max bytes, after avg bytes past min on average, where avg is rounded up to a power of two.
+
+Every chunk is uploaded as a file, at most copyWorkers chunks in parallel, and the chunks are linked in order into
+a single unixfs file node, like ComposeObject links objects. The chunker is recorded with each object, since the
+chunker of a bucket can change, and the objects stored before keep the blocks they were stored with. Erasure
+coded data is split into shards, and replicated data has to be stored as the same DAG by every node, so buckets
+with replication and gateways with erasure coding store data with the chunking of TemporalX.
+*/
+
+const (
+	// defaultChunkerAvg is the average number of bytes after the minimum size of rabin and buzhash chunks
+	defaultChunkerAvg = 256 * 1024
+	// defaultChunkerMin is the minimum size of rabin and buzhash chunks
+	defaultChunkerMin = defaultChunkerAvg / 2
+	// defaultChunkerMax is the maximum size of rabin and buzhash chunks
+	defaultChunkerMax = defaultChunkerAvg * 2
+	// minChunkerMin is the smallest minimum size of rabin and buzhash chunks, which must hold the hash window
+	minChunkerMin = 64
+)
+
+var (
+	// errChunkerReplication is returned when a bucket is configured with both a chunker and replication
+	errChunkerReplication = errors.New("buckets with replication can not have a chunker")
+	// errChunkerErasure is returned when a chunker is configured while the gateway erasure codes all data
+	errChunkerErasure = errors.New("the data of new objects is erasure coded, which can not use a chunker")
+)
+
+// chunker splits a stream into the chunks that are stored as separate blocks
+type chunker interface {
+	// nextChunk returns the next chunk of the stream, or io.EOF after the last chunk
+	nextChunk() ([]byte, error)
+}
+
+// chunkerSpec is a parsed chunker, see SetBucketChunkerRequest.chunker
+type chunkerSpec struct {
+	kind          string
+	min, avg, max int
+}
+
+// parseChunker parses and validates a chunker, see SetBucketChunkerRequest.chunker
+func parseChunker(chunker string) (chunkerSpec, error) {
+	parts := strings.Split(chunker, "-")
+	spec := chunkerSpec{kind: parts[0]}
+	sizes := make([]int, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 {
+			return spec, fmt.Errorf("invalid chunker %q, sizes must be positive numbers of bytes", chunker)
+		}
+		sizes = append(sizes, n)
+	}
+	switch {
+	case spec.kind == "size" && len(sizes) == 1:
+		spec.max = sizes[0]
+	case (spec.kind == "rabin" || spec.kind == "buzhash") && len(sizes) == 0:
+		spec.min, spec.avg, spec.max = defaultChunkerMin, defaultChunkerAvg, defaultChunkerMax
+	case (spec.kind == "rabin" || spec.kind == "buzhash") && len(sizes) == 3:
+		spec.min, spec.avg, spec.max = sizes[0], sizes[1], sizes[2]
+		if spec.min < minChunkerMin || spec.min > spec.max {
+			return spec, fmt.Errorf("invalid chunker %q, min must be at least %d and at most max", chunker, minChunkerMin)
+		}
+	default:
+		return spec, fmt.Errorf("unsupported chunker %q", chunker)
+	}
+	if spec.max > chunkSize {
+		return spec, fmt.Errorf("invalid chunker %q, chunks can not be larger than %d bytes", chunker, chunkSize)
+	}
+	return spec, nil
+}
+
+// newChunker returns a chunker of r, chunker must be valid
+func newChunker(chunker string, r io.Reader) (chunker, error) {
+	spec, err := parseChunker(chunker)
+	if err != nil {
+		return nil, err
+	}
+	switch spec.kind {
+	case "size":
+		return &fixedChunker{r: r, size: spec.max}, nil
+	case "rabin":
+		return newContentChunker(r, rabinHash, spec), nil
+	default:
+		return newContentChunker(r, buzhash, spec), nil
+	}
+}
+
+// fixedChunker cuts chunks of the same size
+type fixedChunker struct {
+	r    io.Reader
+	size int
+}
+
+func (c *fixedChunker) nextChunk() ([]byte, error) {
+	buf := make([]byte, c.size)
+	n, err := io.ReadFull(c.r, buf)
+	switch {
+	case err == io.ErrUnexpectedEOF || (err == nil && n > 0):
+		return buf[:n], nil
+	case err == nil:
+		return nil, io.EOF
+	default:
+		return nil, err
+	}
+}
+
+// rollingHash is a hash of the last window bytes of a stream
+type rollingHash struct {
+	window int
+	// add adds a byte to a hash of less than window bytes
+	add func(h uint64, in byte) uint64
+	// roll adds a byte to a hash of window bytes, and removes the byte that leaves the window
+	roll func(h uint64, in, out byte) uint64
+	// shift is the first bit of the hash that is compared to the mask
+	shift uint
+}
+
+const (
+	rabinWindow = 48
+	rabinPrime  = 0x100000001b3
+)
+
+// rabinPow is rabinPrime to the power of rabinWindow, the factor of the byte that leaves the window
+var rabinPow = func() uint64 {
+	p := uint64(1)
+	for i := 0; i < rabinWindow; i++ {
+		p *= rabinPrime
+	}
+	return p
+}()
+
+// rabinHash is a Rabin-Karp polynomial hash, the low bits only depend on the low bits of the bytes, so the mask
+// is compared to the high bits
+var rabinHash = rollingHash{
+	window: rabinWindow,
+	add:    func(h uint64, in byte) uint64 { return h*rabinPrime + uint64(in) },
+	roll:   func(h uint64, in, out byte) uint64 { return h*rabinPrime + uint64(in) - uint64(out)*rabinPow },
+	shift:  32,
+}
+
+// buzhashTable maps bytes to random values, generated with splitmix64 from a fixed seed,
+// so the same data is always chunked the same way
+var buzhashTable = func() (table [256]uint32) {
+	seed := uint64(0x5333582d62757a68)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = uint32(z ^ (z >> 31))
+	}
+	return table
+}()
+
+// buzhash is a cyclic polynomial hash of 32 bytes, the byte that leaves the window was rotated 32 times,
+// so it is removed without rotating it
+var buzhash = rollingHash{
+	window: 32,
+	add:    func(h uint64, in byte) uint64 { return uint64(bits.RotateLeft32(uint32(h), 1) ^ buzhashTable[in]) },
+	roll: func(h uint64, in, out byte) uint64 {
+		return uint64(bits.RotateLeft32(uint32(h), 1) ^ buzhashTable[out] ^ buzhashTable[in])
+	},
+}
+
+// contentChunker cuts chunks where the rolling hash of the last bytes matches a mask
+type contentChunker struct {
+	r        *bufio.Reader
+	hash     rollingHash
+	min, max int
+	mask     uint64
+}
+
+func newContentChunker(r io.Reader, hash rollingHash, spec chunkerSpec) *contentChunker {
+	return &contentChunker{
+		r:    bufio.NewReader(r),
+		hash: hash,
+		min:  spec.min,
+		max:  spec.max,
+		mask: uint64(1)<<uint(bits.Len(uint(spec.avg-1))) - 1,
+	}
+}
+
+func (c *contentChunker) nextChunk() ([]byte, error) {
+	chunk := make([]byte, 0, c.max)
+	var h uint64
+	for len(chunk) < c.max {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunk = append(chunk, b)
+		// the bytes before the last window of the minimum size are not hashed, the hash of a position
+		// only depends on the window before it, so boundaries do not depend on the previous boundary
+		switch n := len(chunk); {
+		case n <= c.min-c.hash.window:
+			continue
+		case n <= c.min:
+			h = c.hash.add(h, b)
+		default:
+			h = c.hash.roll(h, b, chunk[n-1-c.hash.window])
+		}
+		if len(chunk) >= c.min && (h>>c.hash.shift)&c.mask == 0 {
+			return chunk, nil
+		}
+	}
+	if len(chunk) == 0 {
+		return nil, io.EOF
+	}
+	return chunk, nil
+}
+
+// uploadChunks stores the chunks of c as files that are uploaded in parallel, and returns the hash of
+// a unixfs file node that links the chunks in order, or the hash of the only chunk, and the size of the data
+func (x *xObjects) uploadChunks(ctx context.Context, c chunker) (string, int, error) {
+	return x.uploadChunksWithSlots(ctx, c, make(chan struct{}, copyWorkers))
+}
+
+// uploadChunksWithSlots is uploadChunks with the uploads in parallel limited by slots, which can be shared with
+// other uploads, a chunk is held in memory while it holds a slot
+func (x *xObjects) uploadChunksWithSlots(ctx context.Context, c chunker, slots chan struct{}) (string, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type upload struct {
+		hash string
+		size int
+		err  error
+	}
+	var (
+		results []chan upload
+		readErr error
+	)
+	for {
+		slots <- struct{}{}
+		data, err := c.nextChunk()
+		if err == io.EOF && len(results) == 0 {
+			// empty data is stored as an empty file
+			data, err = nil, nil
+		}
+		if err != nil {
+			<-slots
+			readErr = err
+			break
+		}
+		result := make(chan upload, 1)
+		results = append(results, result)
+		go func(data []byte) {
+			defer func() { <-slots }()
+			hash, size, err := ipfsFileUpload(ctx, x.fileClient, bytes.NewReader(data))
+			if err != nil {
+				cancel() // stop the other uploads
+			}
+			result <- upload{hash: hash, size: size, err: err}
+		}(data)
+	}
+	hashes := make([]string, 0, len(results))
+	sizes := make([]uint64, 0, len(results))
+	var err error
+	for _, result := range results {
+		u := <-result
+		if u.err != nil && err == nil {
+			err = u.err
+		}
+		hashes = append(hashes, u.hash)
+		sizes = append(sizes, uint64(u.size))
+	}
+	if readErr != io.EOF {
+		return "", 0, readErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if len(hashes) == 1 {
+		return hashes[0], int(sizes[0]), nil
+	}
+	hash, size, err := x.saveFileLinks(ctx, hashes, sizes)
+	return hash, int(size), err
+}
+
+// SetBucketChunker configures how the data of new objects in a bucket is split into blocks
+func (x *xObjects) SetBucketChunker(ctx context.Context, req *SetBucketChunkerRequest) (*SetBucketChunkerResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetChunker() != "" {
+		if _, err := parseChunker(req.GetChunker()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if x.erasure != nil {
+			return nil, status.Error(codes.FailedPrecondition, errChunkerErasure.Error())
+		}
+	}
+	err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		if req.GetChunker() != "" && c.GetReplicationFactor() > 1 {
+			return errChunkerReplication
+		}
+		c.Chunker = req.GetChunker()
+		return nil
+	})
+	switch err {
+	case nil:
+	case errChunkerReplication:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, chunker: %q", req.GetBucket(), req.GetChunker())
+	return &SetBucketChunkerResponse{
+		Bucket:  req.GetBucket(),
+		Chunker: req.GetChunker(),
+	}, nil
+}
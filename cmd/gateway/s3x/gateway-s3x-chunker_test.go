@@ -0,0 +1,213 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseChunker(t *testing.T) {
+	testCases := []struct {
+		chunker string
+		spec    chunkerSpec
+		wantErr bool
+	}{
+		{"size-1024", chunkerSpec{kind: "size", max: 1024}, false},
+		{"rabin", chunkerSpec{"rabin", defaultChunkerMin, defaultChunkerAvg, defaultChunkerMax}, false},
+		{"buzhash", chunkerSpec{"buzhash", defaultChunkerMin, defaultChunkerAvg, defaultChunkerMax}, false},
+		{"rabin-1024-4096-16384", chunkerSpec{"rabin", 1024, 4096, 16384}, false},
+		{"size", chunkerSpec{}, true},
+		{"size-0", chunkerSpec{}, true},
+		{"size-x", chunkerSpec{}, true},
+		{"size-8388608", chunkerSpec{}, true},
+		{"rabin-1024-4096", chunkerSpec{}, true},
+		{"rabin-32-64-128", chunkerSpec{}, true},
+		{"buzhash-4096-1024-2048", chunkerSpec{}, true},
+		{"fastcdc", chunkerSpec{}, true},
+	}
+	for _, tc := range testCases {
+		spec, err := parseChunker(tc.chunker)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: expected error %v, but got %v", tc.chunker, tc.wantErr, err)
+		}
+		if err == nil && spec != tc.spec {
+			t.Fatalf("%s: expected %+v, but got %+v", tc.chunker, tc.spec, spec)
+		}
+	}
+}
+
+// chunks returns the chunks of data cut by a chunker
+func chunks(t *testing.T, chunker string, data []byte) [][]byte {
+	t.Helper()
+	c, err := newChunker(chunker, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks [][]byte
+	for {
+		chunk, err := c.nextChunk()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+// sharedChunks returns the number of chunks of b that are also chunks of a
+func sharedChunks(a, b [][]byte) int {
+	set := make(map[string]bool)
+	for _, c := range a {
+		set[string(c)] = true
+	}
+	shared := 0
+	for _, c := range b {
+		if set[string(c)] {
+			shared++
+		}
+	}
+	return shared
+}
+
+func TestContentChunker(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	// insert bytes in the middle of the data
+	edited := append(append(append([]byte{}, data[:500000]...), []byte("an edit")...), data[500000:]...)
+	for _, chunker := range []string{"rabin-1024-4096-16384", "buzhash-1024-4096-16384"} {
+		t.Run(chunker, func(t *testing.T) {
+			original := chunks(t, chunker, data)
+			if joined := bytes.Join(original, nil); !bytes.Equal(joined, data) {
+				t.Fatal("the chunks do not concatenate to the data")
+			}
+			for i, c := range original {
+				if len(c) > 16384 || (len(c) < 1024 && i != len(original)-1) {
+					t.Fatalf("chunk %d has %d bytes, which is not between min and max", i, len(c))
+				}
+			}
+			if n := len(original); n < len(data)/16384 || n > len(data)/1024 {
+				t.Fatalf("unexpected number of chunks %d", n)
+			}
+			// only the chunks around the edit change
+			changed := chunks(t, chunker, edited)
+			if shared := sharedChunks(original, changed); shared < len(changed)-3 {
+				t.Fatalf("expected all but at most 3 of %d chunks to be shared, but %d are", len(changed), shared)
+			}
+		})
+	}
+	t.Run("size-4096", func(t *testing.T) {
+		original := chunks(t, "size-4096", data)
+		if len(original) != 256 {
+			t.Fatalf("expected 256 chunks, but got %d", len(original))
+		}
+		// a fixed size chunker shares no chunks after the edit
+		if shared := sharedChunks(original, chunks(t, "size-4096", edited)); shared > 128 {
+			t.Fatalf("expected the chunks after the edit to change, but %d chunks are shared", shared)
+		}
+	})
+}
+
+func TestS3X_Chunker_Badger(t *testing.T) {
+	testS3XChunker(t, DSTypeBadger)
+}
+func TestS3X_Chunker_Crdt(t *testing.T) {
+	testS3XChunker(t, DSTypeCrdt)
+}
+func testS3XChunker(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		req  *SetBucketChunkerRequest
+		code codes.Code
+	}{
+		{&SetBucketChunkerRequest{Chunker: "rabin"}, codes.InvalidArgument},
+		{&SetBucketChunkerRequest{Bucket: testBucket1, Chunker: "fastcdc"}, codes.InvalidArgument},
+		{&SetBucketChunkerRequest{Bucket: testBucket2, Chunker: "rabin"}, codes.NotFound},
+	} {
+		if _, err := gateway.SetBucketChunker(ctx, tt.req); status.Code(err) != tt.code {
+			t.Fatalf("%v: expected code %v, but got %v", tt.req, tt.code, err)
+		}
+	}
+	const chunker = "buzhash-1024-4096-16384"
+	if _, err := gateway.SetBucketChunker(ctx, &SetBucketChunkerRequest{Bucket: testBucket1, Chunker: chunker}); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	edited := append(append(append([]byte{}, data[:100000]...), []byte("an edit")...), data[100000:]...)
+	put := func(t *testing.T, object string, data []byte) []string {
+		t.Helper()
+		info, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, data), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len(data)) {
+			t.Fatalf("expected size %d, but got %d", len(data), info.Size)
+		}
+		var buf bytes.Buffer
+		if err := gateway.GetObject(ctx, testBucket1, object, 0, int64(len(data)), &buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("the data of %s does not match", object)
+		}
+		dag, err := gateway.GetObjectDAG(ctx, &ObjectDAGRequest{Bucket: testBucket1, Object: object, MaxBlocks: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dag.GetChunker() != chunker {
+			t.Fatalf("expected the object to be recorded with chunker %s, but got %q", chunker, dag.GetChunker())
+		}
+		var links []string
+		for _, l := range dag.GetBlocks()[0].GetLinks() {
+			links = append(links, l.GetCid())
+		}
+		return links
+	}
+	original := put(t, "original", data)
+	changed := put(t, "edited", edited)
+	if len(original) < 2 {
+		t.Fatalf("expected the data to be split into several blocks, but got %d", len(original))
+	}
+	shared := 0
+	for _, c := range changed {
+		for _, o := range original {
+			if c == o {
+				shared++
+				break
+			}
+		}
+	}
+	if shared < len(changed)-3 {
+		t.Fatalf("expected all but at most 3 of %d blocks to be shared with the original upload, but %d are", len(changed), shared)
+	}
+	if _, err := gateway.SetBucketChunker(ctx, &SetBucketChunkerRequest{Bucket: testBucket1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "default", getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GetChunker() != "" {
+		t.Fatalf("expected no chunker to be recorded after the chunker is removed, but got %q", info.GetChunker())
+	}
+}
@@ -0,0 +1,155 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+)
+
+/* Design Notes
+---------------
+
+The lock table of a cluster is held by its host, so the locks of the cluster are lost whenever the host restarts.
+Deployments that already run etcd can hold the locks there instead, every gateway of the cluster, including the
+host, is then started with the same cluster.locks endpoints, and the host does not serve its lock table. The
+ledger lockers take the etcd locks after their in-process locks, like with the lock table, so the lock ordering of
+CopyObject and CloneBucket holds across all gateways.
+
+A lock is a key under the prefix of its name, attached to the session lease of the gateway, so the locks of a
+gateway that stops are deleted when the lease expires after clusterLockTTL. Keys of exclusive locks wait for every
+key of the name created before them, and keys of shared locks only for keys of exclusive locks, so locks are granted
+in the order they were requested, and waiting exclusive locks are not starved by new shared locks. A session whose
+lease expired, because etcd could not be reached, is replaced by the next lock.
+*/
+
+const (
+	// etcdLockPrefix is the prefix of the etcd keys of ledger locks
+	etcdLockPrefix = "s3x/locks/"
+	// etcdDialTimeout is how long a gateway waits for the etcd endpoints at startup
+	etcdDialTimeout = 10 * time.Second
+)
+
+// errWatchClosed is returned if the watch of a lock key ends before the key is deleted
+var errWatchClosed = errors.New("etcd watch closed")
+
+// etcdLocks locks names in etcd for the gateways of a cluster
+type etcdLocks struct {
+	client *clientv3.Client
+
+	mu      sync.Mutex
+	session *concurrency.Session
+}
+
+// newEtcdLocks connects to etcd, and starts the session the locks of the gateway are attached to
+func newEtcdLocks(endpoints []string) (*etcdLocks, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e := &etcdLocks{client: client}
+	if _, err := e.currentSession(); err != nil {
+		_ = client.Close() // the session error is more relevant
+		return nil, err
+	}
+	return e, nil
+}
+
+// currentSession returns the session of the gateway, and replaces it if its lease expired
+func (e *etcdLocks) currentSession() (*concurrency.Session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session != nil {
+		select {
+		case <-e.session.Done():
+			log.Printf("cluster-lock: etcd session expired, locks of the previous session are released")
+		default:
+			return e.session, nil
+		}
+	}
+	session, err := concurrency.NewSession(e.client, concurrency.WithTTL(int(clusterLockTTL/time.Second)))
+	if err != nil {
+		return nil, err
+	}
+	e.session = session
+	return session, nil
+}
+
+// lock adds the key of a lock, and waits until the keys created before it that block it are deleted
+func (e *etcdLocks) lock(ctx context.Context, name string, shared bool) (func(), error) {
+	session, err := e.currentSession()
+	if err != nil {
+		return nil, err
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	prefix := etcdLockPrefix + name + "/"
+	key, blocking := prefix+"write/"+token, prefix
+	if shared {
+		key, blocking = prefix+"read/"+token, prefix+"write/"
+	}
+	put, err := e.client.Put(ctx, key, "", clientv3.WithLease(session.Lease()))
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+		defer cancel()
+		if _, err := e.client.Delete(ctx, key); err != nil {
+			// the key is deleted with the session lease
+			log.Printf("cluster-lock: %s, release error: %v", name, err)
+		}
+	}
+	for {
+		last, err := e.client.Get(ctx, blocking, append(clientv3.WithLastRev(), clientv3.WithMaxModRev(put.Header.Revision-1))...)
+		if err == nil && len(last.Kvs) == 0 {
+			return release, nil
+		}
+		if err == nil {
+			err = e.waitDeleted(ctx, string(last.Kvs[0].Key), last.Header.Revision)
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+	}
+}
+
+// waitDeleted waits until a key that existed at rev is deleted
+func (e *etcdLocks) waitDeleted(ctx context.Context, key string, rev int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for resp := range e.client.Watch(ctx, key, clientv3.WithRev(rev+1)) {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range resp.Events {
+			if ev.Type == clientv3.EventTypeDelete {
+				return nil
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errWatchClosed
+}
+
+// Close ends the session of the gateway, which releases its locks, and disconnects from etcd
+func (e *etcdLocks) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session != nil {
+		_ = e.session.Close() // the locks end with the lease anyway
+	}
+	return e.client.Close()
+}
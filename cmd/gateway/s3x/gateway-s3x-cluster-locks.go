@@ -0,0 +1,293 @@
+package s3x
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+The ledger serializes the mutations of a bucket, of a multipart upload, of the data reference counts, and of the
+ledger root with in-process locks, which gateways sharing a ledger datastore must hold together. The gateway that
+hosts the shared datastore keeps the lock table of the cluster, and every ledger lock is also taken in the table,
+after the in-process lock, so a lock is only requested once per gateway at a time for exclusive locks.
+
+Locks of other gateways are leases: they end clusterLockTTL after they were acquired or last refreshed, and are
+refreshed while they are held, so the locks of a gateway that crashed or lost its connection are released. A
+gateway whose lease ended is not stopped, like with any lease based lock, so the TTL is far longer than any ledger
+mutation. Locks of the hosting gateway have no lease, they end with the table. Waiting exclusive locks keep new
+shared locks of the same name from being acquired, so writers are not starved by a stream of readers.
+
+The ledger lockers have no context and can not fail, a gateway that can not reach the lock table retries until it
+can, so its requests wait instead of mutating the ledger without the lock.
+*/
+
+const (
+	// clusterLockTTL is how long a lock of another gateway is held without being refreshed
+	clusterLockTTL = 30 * time.Second
+	// clusterLockRetry is how long a gateway waits to request a lock again after the lock table failed
+	clusterLockRetry = time.Second
+)
+
+// errLockNotHeld is returned for refreshes and releases of a lock that is not held, or whose lease ended
+var errLockNotHeld = errors.New("the lock is not held")
+
+// clusterLocker locks names across the gateways of a cluster
+type clusterLocker interface {
+	// lock waits until the lock of a name is acquired, and returns the function that releases it
+	lock(ctx context.Context, name string, shared bool) (func(), error)
+}
+
+// mustLock locks a name with the cluster locker, and retries until the lock is acquired
+func mustLock(l clusterLocker, name string, shared bool) func() {
+	for {
+		release, err := l.lock(context.Background(), name, shared)
+		if err == nil {
+			return release
+		}
+		log.Printf("cluster-lock: %s, error: %v", name, err)
+		time.Sleep(clusterLockRetry)
+	}
+}
+
+// clusterLocks is the lock table of the gateway that hosts the ledger of a cluster
+type clusterLocks struct {
+	mu       sync.Mutex
+	leases   map[string]*clusterLease // by token
+	names    map[string]*lockedName
+	released chan struct{} // closed and replaced whenever a lock is released
+}
+
+// lockedName are the holders of the lock of a name
+type lockedName struct {
+	exclusive string          // the token of the exclusive lock
+	shared    map[string]bool // the tokens of the shared locks
+	writers   int             // the number of exclusive locks waiting for the name
+}
+
+// clusterLease is a held lock
+type clusterLease struct {
+	name    string
+	expires time.Time // zero for locks of the hosting gateway
+}
+
+// newClusterLocks returns an empty lock table
+func newClusterLocks() *clusterLocks {
+	return &clusterLocks{
+		leases:   make(map[string]*clusterLease),
+		names:    make(map[string]*lockedName),
+		released: make(chan struct{}),
+	}
+}
+
+// newLockToken returns a random lock token
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// acquire waits until the lock of a name is acquired for ttl, or without a lease if ttl is 0, and returns its token
+// and the expiry of its lease
+func (l *clusterLocks) acquire(ctx context.Context, name string, shared bool, ttl time.Duration) (string, time.Time, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	waiting := false
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.expire(now)
+		n := l.names[name]
+		if n == nil {
+			n = &lockedName{shared: make(map[string]bool)}
+			l.names[name] = n
+		}
+		if waiting {
+			n.writers--
+			waiting = false
+		}
+		if n.exclusive == "" && (shared && n.writers == 0 || !shared && len(n.shared) == 0) {
+			lease := &clusterLease{name: name}
+			if ttl > 0 {
+				lease.expires = now.Add(ttl)
+			}
+			l.leases[token] = lease
+			if shared {
+				n.shared[token] = true
+			} else {
+				n.exclusive = token
+			}
+			l.mu.Unlock()
+			return token, lease.expires, nil
+		}
+		if !shared {
+			n.writers++
+			waiting = true
+		}
+		// wait for a release, or for the first lease of the name to expire
+		var expiry <-chan time.Time
+		var timer *time.Timer
+		if next := l.nextExpiry(n); !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			expiry = timer.C
+		}
+		released := l.released
+		l.mu.Unlock()
+		select {
+		case <-released:
+		case <-expiry:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			l.mu.Lock()
+			if waiting {
+				n.writers--
+				l.forget(name, n)
+			}
+			l.mu.Unlock()
+			return "", time.Time{}, ctx.Err()
+		}
+	}
+}
+
+// nextExpiry returns the first expiry of the leases of a locked name, zero if none expire
+func (l *clusterLocks) nextExpiry(n *lockedName) time.Time {
+	var next time.Time
+	tokens := make([]string, 0, len(n.shared)+1)
+	if n.exclusive != "" {
+		tokens = append(tokens, n.exclusive)
+	}
+	for t := range n.shared {
+		tokens = append(tokens, t)
+	}
+	for _, t := range tokens {
+		if e := l.leases[t].expires; !e.IsZero() && (next.IsZero() || e.Before(next)) {
+			next = e
+		}
+	}
+	return next
+}
+
+// expire ends the leases that expired at now, the caller holds mu
+func (l *clusterLocks) expire(now time.Time) {
+	for token, lease := range l.leases {
+		if !lease.expires.IsZero() && !lease.expires.After(now) {
+			log.Printf("cluster-lock: %s, lease expired", lease.name)
+			l.end(token)
+		}
+	}
+}
+
+// end releases the lock of a token, the caller holds mu
+func (l *clusterLocks) end(token string) bool {
+	lease, ok := l.leases[token]
+	if !ok {
+		return false
+	}
+	delete(l.leases, token)
+	n := l.names[lease.name]
+	if n.exclusive == token {
+		n.exclusive = ""
+	}
+	delete(n.shared, token)
+	l.forget(lease.name, n)
+	close(l.released)
+	l.released = make(chan struct{})
+	return true
+}
+
+// forget removes a name that is not locked and not waited for, the caller holds mu
+func (l *clusterLocks) forget(name string, n *lockedName) {
+	if n.exclusive == "" && len(n.shared) == 0 && n.writers == 0 {
+		delete(l.names, name)
+	}
+}
+
+// refresh extends the lease of a lock by ttl, and returns its new expiry
+func (l *clusterLocks) refresh(token string, ttl time.Duration) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.expire(now)
+	lease, ok := l.leases[token]
+	if !ok || lease.expires.IsZero() {
+		return time.Time{}, errLockNotHeld
+	}
+	lease.expires = now.Add(ttl)
+	return lease.expires, nil
+}
+
+// release releases a lock
+func (l *clusterLocks) release(token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.end(token) {
+		return errLockNotHeld
+	}
+	return nil
+}
+
+// lock locks a name for the hosting gateway
+func (l *clusterLocks) lock(ctx context.Context, name string, shared bool) (func(), error) {
+	token, _, err := l.acquire(ctx, name, shared, 0)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.release(token) }, nil // the lock of the hosting gateway can not end otherwise
+}
+
+// remoteLocks locks names in the lock table of the gateway that hosts the ledger of a cluster
+type remoteLocks struct {
+	client LedgerDatastoreAPIClient
+}
+
+// lock locks a name, and refreshes the lease of the lock until it is released
+func (r *remoteLocks) lock(ctx context.Context, name string, shared bool) (func(), error) {
+	l, err := r.client.AcquireLock(ctx, &AcquireLockRequest{Name: name, Shared: shared})
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(clusterLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), clusterLockTTL/3)
+				_, err := r.client.RefreshLock(ctx, l)
+				cancel()
+				if err != nil {
+					log.Printf("cluster-lock: %s, refresh error: %v", name, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		ctx, cancel := context.WithTimeout(context.Background(), clusterLockTTL)
+		defer cancel()
+		if _, err := r.client.ReleaseLock(ctx, l); err != nil {
+			// the lease ends on its own
+			log.Printf("cluster-lock: %s, release error: %v", name, err)
+		}
+	}, nil
+}
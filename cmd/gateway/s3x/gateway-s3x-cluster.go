@@ -0,0 +1,398 @@
+package s3x
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A cluster is a group of gateways sharing one ledger, so S3 requests can be spread over any number of gateways
+without replicating the ledger like crdt does. One gateway hosts the ledger in its badger datastore, and serves
+the datastore and the lock table of the cluster on its info grpc endpoint with cluster.serve. The other gateways
+start with the remote datastore type and the endpoint of the host, and keep no ledger state of their own, so they
+can be added and removed at any time.
+
+Remote gateways access the datastore with one call per operation, batches are sent as a single commit, which the
+host applies in one badger transaction, so the ledger commits stay atomic. Queries are streamed in key order, and
+filters and orders that badger can not apply are applied by the remote gateway.
+
+Every gateway of a cluster takes the ledger locks in the lock table of the host, see clusterLocks, and checks the
+buckets and multipart uploads it cached against the datastore before using them, since another gateway may have
+changed them. Bucket existence is not cached. The ledger maintenance loops, such as garbage collection,
+expiry, and snapshots, only run on the host, remote gateways serve requests and keep their own metering and
+capacity samples. Object events are only published by the gateway that changed the object.
+
+Requests to the host are authorized by a key derived from the gateway credentials, so the gateways of a cluster
+must share their credentials. The datastore is served without TLS like the rest of the info grpc endpoint, so the
+endpoint of a host must only be reachable by the cluster.
+*/
+
+const (
+	// clusterKeyHeader is the grpc metadata key of the cluster key
+	clusterKeyHeader = "s3x-cluster-key"
+	// clusterCallTimeout is the deadline of datastore calls of remote gateways
+	clusterCallTimeout = time.Minute
+	// clusterMaxMessageSize is the maximum size of a datastore call, which fits the commits of large batches
+	clusterMaxMessageSize = 64 << 20
+)
+
+// newClusterKey returns the key that authorizes the datastore calls of the gateways of a cluster
+func newClusterKey(creds auth.Credentials) string {
+	sum := sha256.Sum256([]byte("s3x cluster:" + creds.AccessKey + ":" + creds.SecretKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// clusterCredentials adds the cluster key to the calls of a remote gateway
+type clusterCredentials string
+
+func (c clusterCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{clusterKeyHeader: string(c)}, nil
+}
+
+func (c clusterCredentials) RequireTransportSecurity() bool { return false }
+
+// clusterServer serves the ledger datastore and the lock table of the hosting gateway to the other gateways
+type clusterServer struct {
+	ds    datastore.Batching
+	locks *clusterLocks // nil if the locks of the cluster are held in etcd
+	key   string
+}
+
+// authorize returns an error unless a call has the cluster key
+func (s *clusterServer) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range md.Get(clusterKeyHeader) {
+		if hmac.Equal([]byte(key), []byte(s.key)) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid cluster key")
+}
+
+// authorizeLocks returns an error unless a call has the cluster key, and the locks of the cluster are held by the server
+func (s *clusterServer) authorizeLocks(ctx context.Context) error {
+	if err := s.authorize(ctx); err != nil {
+		return err
+	}
+	if s.locks == nil {
+		return status.Error(codes.FailedPrecondition, "the locks of the cluster are held in etcd")
+	}
+	return nil
+}
+
+// datastoreGrpcErr converts a datastore error to a grpc error
+func datastoreGrpcErr(err error) error {
+	if err == datastore.ErrNotFound {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *clusterServer) Get(ctx context.Context, req *DatastoreKey) (*DatastoreEntry, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	value, err := s.ds.Get(datastore.NewKey(req.GetKey()))
+	if err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreEntry{Key: req.GetKey(), Value: value, Size_: int64(len(value))}, nil
+}
+
+func (s *clusterServer) Has(ctx context.Context, req *DatastoreKey) (*DatastoreHasResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	exists, err := s.ds.Has(datastore.NewKey(req.GetKey()))
+	if err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreHasResponse{Exists: exists}, nil
+}
+
+func (s *clusterServer) GetSize(ctx context.Context, req *DatastoreKey) (*DatastoreEntry, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	size, err := s.ds.GetSize(datastore.NewKey(req.GetKey()))
+	if err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreEntry{Key: req.GetKey(), Size_: int64(size)}, nil
+}
+
+func (s *clusterServer) Put(ctx context.Context, req *DatastoreEntry) (*DatastoreWriteResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.ds.Put(datastore.NewKey(req.GetKey()), req.GetValue()); err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreWriteResponse{}, nil
+}
+
+func (s *clusterServer) Delete(ctx context.Context, req *DatastoreKey) (*DatastoreWriteResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.ds.Delete(datastore.NewKey(req.GetKey())); err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreWriteResponse{}, nil
+}
+
+func (s *clusterServer) Query(req *DatastoreQuery, stream LedgerDatastoreAPI_QueryServer) error {
+	if err := s.authorize(stream.Context()); err != nil {
+		return err
+	}
+	rs, err := s.ds.Query(query.Query{
+		Prefix:   req.GetPrefix(),
+		KeysOnly: req.GetKeysOnly(),
+		Limit:    int(req.GetLimit()),
+		Offset:   int(req.GetOffset()),
+	})
+	if err != nil {
+		return datastoreGrpcErr(err)
+	}
+	defer rs.Close()
+	for r := range rs.Next() {
+		if r.Error != nil {
+			return datastoreGrpcErr(r.Error)
+		}
+		if err := stream.Send(&DatastoreEntry{Key: r.Key, Value: r.Value, Size_: int64(r.Size)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *clusterServer) Commit(ctx context.Context, req *DatastoreBatch) (*DatastoreWriteResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	batch, err := s.ds.Batch()
+	if err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	for _, op := range req.GetOperations() {
+		if op.GetDelete() {
+			err = batch.Delete(datastore.NewKey(op.GetKey()))
+		} else {
+			err = batch.Put(datastore.NewKey(op.GetKey()), op.GetValue())
+		}
+		if err != nil {
+			return nil, datastoreGrpcErr(err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, datastoreGrpcErr(err)
+	}
+	return &DatastoreWriteResponse{}, nil
+}
+
+func (s *clusterServer) AcquireLock(ctx context.Context, req *AcquireLockRequest) (*ClusterLock, error) {
+	if err := s.authorizeLocks(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "lock name is empty")
+	}
+	token, expires, err := s.locks.acquire(ctx, req.GetName(), req.GetShared(), clusterLockTTL)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &ClusterLock{Token: token, Expires: expires}, nil
+}
+
+func (s *clusterServer) RefreshLock(ctx context.Context, req *ClusterLock) (*ClusterLock, error) {
+	if err := s.authorizeLocks(ctx); err != nil {
+		return nil, err
+	}
+	expires, err := s.locks.refresh(req.GetToken(), clusterLockTTL)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &ClusterLock{Token: req.GetToken(), Expires: expires}, nil
+}
+
+func (s *clusterServer) ReleaseLock(ctx context.Context, req *ClusterLock) (*ReleaseLockResponse, error) {
+	if err := s.authorizeLocks(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.locks.release(req.GetToken()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &ReleaseLockResponse{}, nil
+}
+
+// remoteDatastore is the ledger datastore of a remote gateway, which is served by the host of its cluster
+type remoteDatastore struct {
+	conn   *grpc.ClientConn
+	client LedgerDatastoreAPIClient
+}
+
+// dialCluster connects to the info grpc endpoint of the host of a cluster
+func dialCluster(addr, key string) (*remoteDatastore, error) {
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithPerRPCCredentials(clusterCredentials(key)),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(clusterMaxMessageSize),
+			grpc.MaxCallSendMsgSize(clusterMaxMessageSize),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteDatastore{conn: conn, client: NewLedgerDatastoreAPIClient(conn)}, nil
+}
+
+// remoteErr converts the error of a datastore call to a datastore error
+func remoteErr(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return datastore.ErrNotFound
+	}
+	return err
+}
+
+func (d *remoteDatastore) Get(key datastore.Key) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	e, err := d.client.Get(ctx, &DatastoreKey{Key: key.String()})
+	if err != nil {
+		return nil, remoteErr(err)
+	}
+	return e.GetValue(), nil
+}
+
+func (d *remoteDatastore) Has(key datastore.Key) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	resp, err := d.client.Has(ctx, &DatastoreKey{Key: key.String()})
+	if err != nil {
+		return false, remoteErr(err)
+	}
+	return resp.GetExists(), nil
+}
+
+func (d *remoteDatastore) GetSize(key datastore.Key) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	e, err := d.client.GetSize(ctx, &DatastoreKey{Key: key.String()})
+	if err != nil {
+		return -1, remoteErr(err)
+	}
+	return int(e.GetSize_()), nil
+}
+
+func (d *remoteDatastore) Put(key datastore.Key, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	_, err := d.client.Put(ctx, &DatastoreEntry{Key: key.String(), Value: value})
+	return remoteErr(err)
+}
+
+func (d *remoteDatastore) Delete(key datastore.Key) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	_, err := d.client.Delete(ctx, &DatastoreKey{Key: key.String()})
+	return remoteErr(err)
+}
+
+// Query streams the entries with the prefix of q, the host applies the limit and offset of queries without filters
+// and orders, others are applied to the stream
+func (d *remoteDatastore) Query(q query.Query) (query.Results, error) {
+	req := &DatastoreQuery{Prefix: q.Prefix, KeysOnly: q.KeysOnly}
+	naive := len(q.Filters) > 0 || len(q.Orders) > 0
+	if !naive {
+		req.Limit, req.Offset = int64(q.Limit), int64(q.Offset)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := d.client.Query(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rs := query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			e, err := stream.Recv()
+			if err == io.EOF {
+				return query.Result{}, false
+			}
+			if err != nil {
+				return query.Result{Error: err}, true
+			}
+			return query.Result{Entry: query.Entry{Key: e.GetKey(), Value: e.GetValue(), Size: int(e.GetSize_())}}, true
+		},
+		Close: func() error {
+			cancel()
+			return nil
+		},
+	})
+	if !naive {
+		return rs, nil
+	}
+	q.Prefix = "" // already applied by the host
+	return query.NaiveQueryApply(q, rs), nil
+}
+
+// Sync is a no-op, the host commits every write before it returns
+func (d *remoteDatastore) Sync(datastore.Key) error {
+	return nil
+}
+
+func (d *remoteDatastore) Close() error {
+	return d.conn.Close()
+}
+
+func (d *remoteDatastore) Batch() (datastore.Batch, error) {
+	return &remoteBatch{ds: d}, nil
+}
+
+// remoteBatch collects the operations of a batch, which are sent to the host on commit
+type remoteBatch struct {
+	ds  *remoteDatastore
+	ops []*DatastoreOperation
+}
+
+func (b *remoteBatch) Put(key datastore.Key, value []byte) error {
+	b.ops = append(b.ops, &DatastoreOperation{Key: key.String(), Value: value})
+	return nil
+}
+
+func (b *remoteBatch) Delete(key datastore.Key) error {
+	b.ops = append(b.ops, &DatastoreOperation{Key: key.String(), Delete: true})
+	return nil
+}
+
+func (b *remoteBatch) Commit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterCallTimeout)
+	defer cancel()
+	_, err := b.ds.client.Commit(ctx, &DatastoreBatch{Operations: b.ops})
+	return remoteErr(err)
+}
+
+// joinCluster makes the ledger take its locks with the lock table of a cluster, and stop trusting its caches, it is
+// called before the ledger is used
+func (ls *ledgerStore) joinCluster(locks clusterLocker) {
+	ls.locker.cluster, ls.locker.prefix = locks, "bucket/"
+	ls.plocker.cluster, ls.plocker.prefix = locks, "upload/"
+	ls.rlocker.cluster, ls.rlocker.name = locks, "refs"
+	ls.rootLocker.cluster, ls.rootLocker.name = locks, "root"
+	ls.existence.disabled = true
+	ls.shared = true
+}
@@ -0,0 +1,234 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClusterLocks(t *testing.T) {
+	ctx := context.Background()
+	l := newClusterLocks()
+	// acquired returns whether a lock is acquired before a short timeout
+	acquired := func(name string, shared bool, ttl time.Duration) (string, bool) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		token, _, err := l.acquire(ctx, name, shared, ttl)
+		if err != nil && err != context.DeadlineExceeded {
+			t.Fatal(err)
+		}
+		return token, err == nil
+	}
+	t.Run("Shared", func(t *testing.T) {
+		a, ok := acquired("a", true, 0)
+		if !ok {
+			t.Fatal("expected a shared lock")
+		}
+		b, ok := acquired("a", true, 0)
+		if !ok {
+			t.Fatal("expected a second shared lock")
+		}
+		if _, ok := acquired("a", false, 0); ok {
+			t.Fatal("expected no exclusive lock while shared locks are held")
+		}
+		if _, ok := acquired("b", false, 0); !ok {
+			t.Fatal("expected locks of other names to be independent")
+		}
+		for _, token := range []string{a, b} {
+			if err := l.release(token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.release(a); err != errLockNotHeld {
+			t.Fatalf("expected %v, but got %v", errLockNotHeld, err)
+		}
+		if _, ok := acquired("a", false, 0); !ok {
+			t.Fatal("expected an exclusive lock once the shared locks are released")
+		}
+	})
+	t.Run("Writer-Preference", func(t *testing.T) {
+		reader, ok := acquired("c", true, 0)
+		if !ok {
+			t.Fatal("expected a shared lock")
+		}
+		writer := make(chan string)
+		go func() {
+			token, _, err := l.acquire(ctx, "c", false, 0)
+			if err != nil {
+				t.Error(err)
+			}
+			writer <- token
+		}()
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := acquired("c", true, 0); ok {
+			t.Fatal("expected no shared lock while an exclusive lock is waiting")
+		}
+		if err := l.release(reader); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.release(<-writer); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := acquired("c", true, 0); !ok {
+			t.Fatal("expected a shared lock once the exclusive lock is released")
+		}
+	})
+	t.Run("Lease", func(t *testing.T) {
+		token, ok := acquired("d", false, 100*time.Millisecond)
+		if !ok {
+			t.Fatal("expected an exclusive lock")
+		}
+		if _, err := l.refresh(token, 100*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := acquired("d", false, 0); ok {
+			t.Fatal("expected the refreshed lease to be held")
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if _, _, err := l.acquire(ctx, "d", false, 0); err != nil {
+			t.Fatalf("expected the lock once its lease expired, but got %v", err)
+		}
+		if _, err := l.refresh(token, time.Second); err != errLockNotHeld {
+			t.Fatalf("expected %v, but got %v", errLockNotHeld, err)
+		}
+	})
+}
+
+func TestS3X_Cluster(t *testing.T) {
+	ctx := context.Background()
+	host := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := host.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	host.temx.ClusterServe = true
+	host.restart(t)
+
+	dir, err := ioutil.TempDir("", "s3x-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	temx := &TEMX{
+		HTTPAddr:    "localhost:8887",
+		GRPCAddr:    "localhost:8886",
+		DSType:      DSTypeRemote,
+		DSPath:      dir,
+		ClusterAddr: host.temx.GRPCAddr,
+		XAddr:       host.temx.XAddr,
+		Insecure:    true,
+		Clock:       testClock{},
+	}
+	for _, misconfigured := range []TEMX{
+		{DSType: DSTypeRemote},
+		{DSType: DSTypeRemote, ClusterAddr: host.temx.GRPCAddr, ClusterServe: true},
+		{DSType: DSTypeCrdt, ClusterServe: true},
+		{DSType: DSTypeBadger, ClusterLockEndpoints: []string{"localhost:2379"}},
+	} {
+		if _, err := misconfigured.NewGatewayLayer(auth.Credentials{}); err == nil {
+			t.Fatalf("expected %+v to be rejected", misconfigured)
+		}
+	}
+	g, err := temx.NewGatewayLayer(auth.Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := g.(*xObjects)
+	defer func() {
+		if err := remote.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := host.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err == nil {
+		t.Fatal("expected the bucket made by the host to exist")
+	}
+	t.Run("Shared-Ledger", func(t *testing.T) {
+		if _, err := host.PutObject(ctx, testBucket1, "host", getTestPutObjectReader(t, []byte("host")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := remote.GetObjectInfo(ctx, testBucket1, "host", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		// the host cached the bucket before the remote gateway changed it
+		if _, err := remote.PutObject(ctx, testBucket1, "remote", getTestPutObjectReader(t, []byte("remote")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := host.GetObjectInfo(ctx, testBucket1, "remote", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len("remote")) {
+			t.Fatalf("expected size %v, but got %v", len("remote"), info.Size)
+		}
+		if err := remote.DeleteBucket(ctx, testBucket2); err == nil {
+			t.Fatal("expected a missing bucket not to be deleted")
+		}
+	})
+	t.Run("Concurrent-Writes", func(t *testing.T) {
+		const count = 10
+		var wg sync.WaitGroup
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				gateway := remote
+				if i%2 == 0 {
+					gateway = host.xObjects
+				}
+				name := fmt.Sprintf("concurrent/%v", i)
+				if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(name)), minio.ObjectOptions{}); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		for _, gateway := range []*xObjects{host.xObjects, remote} {
+			loi, err := gateway.ListObjects(ctx, testBucket1, "concurrent/", "", "", 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loi.Objects) != count {
+				t.Fatalf("expected %v objects, but got %v", count, len(loi.Objects))
+			}
+		}
+	})
+	t.Run("Cluster-Key", func(t *testing.T) {
+		ds, err := dialCluster(host.temx.GRPCAddr, newClusterKey(auth.Credentials{AccessKey: "other"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ds.Close()
+		if _, err := ds.Get(datastore.NewKey("b")); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected code %v, but got %v", codes.Unauthenticated, err)
+		}
+		if _, err := (&remoteLocks{client: ds.client}).lock(ctx, "bucket/"+testBucket1, false); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected code %v, but got %v", codes.Unauthenticated, err)
+		}
+	})
+	t.Run("Etcd-Locks", func(t *testing.T) {
+		// a host whose cluster locks are held in etcd does not serve its lock table
+		server := &clusterServer{key: newClusterKey(auth.Credentials{})}
+		ctx := metadata.NewIncomingContext(ctx, metadata.Pairs(clusterKeyHeader, server.key))
+		if _, err := server.AcquireLock(ctx, &AcquireLockRequest{Name: "bucket/" + testBucket1}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+		}
+	})
+}
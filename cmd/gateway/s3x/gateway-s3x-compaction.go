@@ -0,0 +1,214 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Badger never rewrites its value log in place, deleted and overwritten ledger entries keep their disk space
+until the value log files they are in are garbage collected, so a ledger with many mutations grows on disk
+even if the number of entries does not. The gateway schedules the compaction of the datastore itself instead
+of leaving it to the datastore, every compaction interval and on request of the CompactDatastore admin call,
+and the value log garbage collection of badger is disabled, so there is only one schedule to configure.
+
+The crdt datastore stores its entries in a badger datastore as well, which is compacted the same way.
+Compactions run one at a time, a compaction that is requested while another one runs fails, and the
+disk usage is measured from the size of the files in the datastore directory before and after each
+compaction, since badger only updates the sizes it reports once a minute. The compactions, the bytes they
+freed, and the disk usage are exported as Prometheus metrics, and returned by GetDatastoreStats.
+*/
+
+// defaultCompactionInterval is how often the ledger datastore is compacted by default
+const defaultCompactionInterval = 15 * time.Minute
+
+var (
+	// errCompactionUnsupported is returned when the ledger datastore can not be compacted
+	errCompactionUnsupported = errors.New("the ledger datastore can not be compacted")
+	// errCompactionRunning is returned when a compaction is requested while another one runs
+	errCompactionRunning = errors.New("a compaction of the ledger datastore is already running")
+)
+
+var (
+	compactionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3x",
+		Subsystem: "datastore",
+		Name:      "compactions_total",
+		Help:      "Total number of compactions of the ledger datastore by result",
+	}, []string{"result"})
+	compactionReclaimedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "s3x",
+		Subsystem: "datastore",
+		Name:      "reclaimed_bytes_total",
+		Help:      "Total bytes freed by compactions of the ledger datastore",
+	})
+	datastoreDiskUsage = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "datastore",
+		Name:      "disk_usage_bytes",
+		Help:      "Size of the ledger datastore files after the last compaction",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(compactionsTotal, compactionReclaimedBytes, datastoreDiskUsage)
+}
+
+// datastoreCompactor compacts the datastore the ledger is stored in, and keeps the stats of its compactions
+type datastoreCompactor struct {
+	gc       datastore.GCDatastore // nil if the datastore can not be compacted
+	path     string                // the directory of the datastore files
+	interval time.Duration         // the time between scheduled compactions, 0 if they are not scheduled
+
+	mu          sync.Mutex
+	running     bool
+	compactions int64
+	reclaimed   int64
+	last        *DatastoreCompaction
+}
+
+// newDatastoreCompactor returns a compactor of the datastore backend stored in path
+func newDatastoreCompactor(backend datastore.Datastore, path string, interval time.Duration) *datastoreCompactor {
+	gc, _ := backend.(datastore.GCDatastore)
+	return &datastoreCompactor{gc: gc, path: path, interval: interval}
+}
+
+// diskUsage returns the size in bytes of the files in the datastore directory
+func (c *datastoreCompactor) diskUsage() (int64, error) {
+	var size int64
+	err := filepath.Walk(c.path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// compact collects the garbage of the datastore, the compaction is returned even if it failed
+func (c *datastoreCompactor) compact(now time.Time) (*DatastoreCompaction, error) {
+	if c.gc == nil {
+		return nil, errCompactionUnsupported
+	}
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return nil, errCompactionRunning
+	}
+	c.running = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running = false
+		c.mu.Unlock()
+	}()
+	start := time.Now()
+	compaction := &DatastoreCompaction{Started: now.Unix()}
+	err := c.runCompaction(compaction)
+	compaction.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		compaction.Error = err.Error()
+		compactionsTotal.WithLabelValues("error").Inc()
+	} else {
+		compactionsTotal.WithLabelValues("ok").Inc()
+		compactionReclaimedBytes.Add(float64(compaction.ReclaimedBytes))
+		datastoreDiskUsage.Set(float64(compaction.DiskUsageAfter))
+	}
+	c.mu.Lock()
+	c.compactions++
+	c.reclaimed += compaction.ReclaimedBytes
+	c.last = compaction
+	c.mu.Unlock()
+	return compaction, err
+}
+
+func (c *datastoreCompactor) runCompaction(compaction *DatastoreCompaction) error {
+	before, err := c.diskUsage()
+	if err != nil {
+		return err
+	}
+	compaction.DiskUsageBefore = before
+	if err := c.gc.CollectGarbage(); err != nil {
+		return err
+	}
+	after, err := c.diskUsage()
+	if err != nil {
+		return err
+	}
+	compaction.DiskUsageAfter = after
+	if after < before {
+		compaction.ReclaimedBytes = before - after
+	}
+	return nil
+}
+
+// compactionLoop compacts the ledger datastore every interval until the gateway is shut down
+func (x *xObjects) compactionLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			c, err := x.compactor.compact(x.clock.Now())
+			if err != nil {
+				log.Printf("failed to compact the ledger datastore: %v", err)
+				continue
+			}
+			log.Printf("datastore-compaction-reclaimed: %v, disk-usage: %v", c.ReclaimedBytes, c.DiskUsageAfter)
+		}
+	}
+}
+
+// CompactDatastore reclaims the disk space of deleted and overwritten entries of the ledger datastore
+func (x *xObjects) CompactDatastore(ctx context.Context, req *CompactDatastoreRequest) (*DatastoreCompaction, error) {
+	c, err := x.compactor.compact(x.clock.Now())
+	switch err {
+	case nil:
+	case errCompactionUnsupported:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errCompactionRunning:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("datastore-compaction-reclaimed: %v, disk-usage: %v", c.ReclaimedBytes, c.DiskUsageAfter)
+	return c, nil
+}
+
+// GetDatastoreStats returns the disk usage of the ledger datastore, the compactions since startup, and its health
+func (x *xObjects) GetDatastoreStats(ctx context.Context, req *DatastoreStatsRequest) (*DatastoreStatsResponse, error) {
+	c := x.compactor
+	usage, err := c.diskUsage()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &DatastoreStatsResponse{
+		Type:                      string(x.dsType),
+		DiskUsage:                 usage,
+		CompactionSupported:       c.gc != nil,
+		CompactionIntervalSeconds: int64(c.interval.Seconds()),
+		Compactions:               c.compactions,
+		ReclaimedBytes:            c.reclaimed,
+		LastCompaction:            c.last,
+		Health:                    x.ledgerStore.dsHealth.summary(time.Now()),
+	}, nil
+}
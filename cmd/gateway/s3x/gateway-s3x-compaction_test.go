@@ -0,0 +1,112 @@
+package s3x
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Compaction_Badger(t *testing.T) {
+	testS3XCompaction(t, DSTypeBadger)
+}
+func TestS3X_Compaction_Crdt(t *testing.T) {
+	testS3XCompaction(t, DSTypeCrdt)
+}
+func testS3XCompaction(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("hello")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := gateway.GetDatastoreStats(ctx, &DatastoreStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.GetType() != string(dsType) || !stats.GetCompactionSupported() || stats.GetDiskUsage() <= 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.GetCompactions() != 0 || stats.GetLastCompaction() != nil {
+		t.Fatalf("expected no compactions, but got %+v", stats)
+	}
+	c, err := gateway.CompactDatastore(ctx, &CompactDatastoreRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetError() != "" || c.GetDiskUsageBefore() <= 0 || c.GetDiskUsageAfter() <= 0 || c.GetReclaimedBytes() < 0 {
+		t.Fatalf("unexpected compaction %+v", c)
+	}
+	if c.GetReclaimedBytes() != 0 && c.GetReclaimedBytes() != c.GetDiskUsageBefore()-c.GetDiskUsageAfter() {
+		t.Fatalf("expected the reclaimed bytes to be the difference of the disk usage, but got %+v", c)
+	}
+	stats, err = gateway.GetDatastoreStats(ctx, &DatastoreStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.GetCompactions() != 1 || stats.GetReclaimedBytes() != c.GetReclaimedBytes() || stats.GetLastCompaction().GetStarted() != c.GetStarted() {
+		t.Fatalf("expected the compaction to be counted, but got %+v", stats)
+	}
+	// the ledger is still usable after the compaction
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3X_CompactionUnsupported(t *testing.T) {
+	c := newDatastoreCompactor(datastore.NewMapDatastore(), t.Name(), time.Minute)
+	x := &xObjects{compactor: c, clock: testClock{}}
+	if _, err := x.CompactDatastore(context.Background(), &CompactDatastoreRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+}
+
+// blockingGC is a GCDatastore that blocks garbage collection until release is closed
+type blockingGC struct {
+	datastore.Datastore
+	started, release chan struct{}
+}
+
+func (b *blockingGC) CollectGarbage() error {
+	close(b.started)
+	<-b.release
+	return nil
+}
+
+func TestS3X_CompactionRunning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3x-compaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gc := &blockingGC{Datastore: datastore.NewMapDatastore(), started: make(chan struct{}), release: make(chan struct{})}
+	x := &xObjects{compactor: newDatastoreCompactor(gc, dir, 0), clock: testClock{}}
+	done := make(chan error)
+	go func() {
+		_, err := x.CompactDatastore(context.Background(), &CompactDatastoreRequest{})
+		done <- err
+	}()
+	<-gc.started
+	if _, err := x.CompactDatastore(context.Background(), &CompactDatastoreRequest{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected code %v, but got %v", codes.Unavailable, err)
+	}
+	close(gc.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,161 @@
+package s3x
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Buckets can be configured to compress new objects before they are uploaded to IPFS.
+The compression is recorded per object, so changing the bucket setting does not affect existing objects,
+and objects created by multipart uploads, compose or append are stored uncompressed.
+ObjectInfo.Size is always the uncompressed size, which is what the S3 api serves.
+*/
+
+const (
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+// SetBucketCompression configures the compression of new objects in a bucket
+func (x *xObjects) SetBucketCompression(ctx context.Context, req *SetBucketCompressionRequest) (*SetBucketCompressionResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	switch req.GetCompression() {
+	case "", compressionGzip, compressionZstd:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported compression %q", req.GetCompression())
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.Compression = req.GetCompression()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, compression: %q", req.GetBucket(), req.GetCompression())
+	return &SetBucketCompressionResponse{
+		Bucket:      req.GetBucket(),
+		Compression: req.GetCompression(),
+	}, nil
+}
+
+// compressReader returns a reader of the compressed data of r,
+// closing the returned reader stops the compression.
+func compressReader(compression string, r io.Reader) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	var w io.WriteCloser
+	switch compression {
+	case compressionGzip:
+		w = gzip.NewWriter(pw)
+	case compressionZstd:
+		zw, err := zstd.NewWriter(pw)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+	go func() {
+		_, err := io.Copy(w, r)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// decompressReader returns a reader of the decompressed data of r
+func decompressReader(compression string, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case compressionGzip:
+		return gzip.NewReader(r)
+	case compressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// ipfsDecompressedDownload is ipfsFileDownload for compressed data, startOffset and length apply to the decompressed data
+func ipfsDecompressedDownload(ctx context.Context, fileClient pb.FileAPIClient, w io.Writer, hash, compression string, startOffset, length int64) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := ipfsFileDownload(ctx, fileClient, pw, hash, 0, 0)
+		_ = pw.CloseWithError(err)
+	}()
+	return decompressCopy(w, pr, compression, startOffset, length)
+}
+
+// decompressCopy copies the decompressed data of r to w, starting at startOffset for length bytes,
+// or until the end if both are 0.
+func decompressCopy(w io.Writer, r io.Reader, compression string, startOffset, length int64) (int64, error) {
+	dr, err := decompressReader(compression, r)
+	if err != nil {
+		return 0, err
+	}
+	defer dr.Close()
+	if _, err := io.CopyN(ioutil.Discard, dr, startOffset); err != nil {
+		return 0, err
+	}
+	if startOffset == 0 && length == 0 {
+		return io.Copy(w, dr)
+	}
+	return io.CopyN(w, dr, length)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// acceptsEncoding returns true if the Accept-Encoding of the request headers allows the given content encoding
+func acceptsEncoding(h http.Header, encoding string) bool {
+	for _, accepted := range strings.Split(h.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(accepted, ";")
+		if strings.TrimSpace(parts[0]) != encoding {
+			continue
+		}
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Compression_Badger(t *testing.T) {
+	testS3XCompression(t, DSTypeBadger)
+}
+func TestS3X_Compression_Crdt(t *testing.T) {
+	testS3XCompression(t, DSTypeCrdt)
+}
+func testS3XCompression(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketCompression(ctx, &SetBucketCompressionRequest{Bucket: testBucket1, Compression: "lz4"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("SetBucketCompression() err %v, want code %v", err, codes.InvalidArgument)
+	}
+	data := strings.Repeat("compressible data ", 1000)
+	for _, compression := range []string{compressionGzip, compressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			if _, err := gateway.SetBucketCompression(ctx, &SetBucketCompressionRequest{Bucket: testBucket1, Compression: compression}); err != nil {
+				t.Fatal(err)
+			}
+			object := "compressed-" + compression
+			if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			oi, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if oi.GetCompression() != compression || oi.GetSize_() != int64(len(data)) || oi.GetCompressedSize() >= oi.GetSize_() {
+				t.Fatalf("unexpected compression %q, size %v, compressed size %v", oi.GetCompression(), oi.GetSize_(), oi.GetCompressedSize())
+			}
+			buf := bytes.NewBuffer(nil)
+			if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != data {
+				t.Fatal("decompressed data does not match")
+			}
+			buf.Reset()
+			if err := gateway.GetObject(ctx, testBucket1, object, 18, 10, buf, "", minio.ObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != data[18:28] {
+				t.Fatalf("expected range %q, but got %q", data[18:28], buf.String())
+			}
+			if _, err := gateway.ComposeObject(ctx, &ComposeObjectRequest{Bucket: testBucket1, Object: "composed", Sources: []string{object}}); status.Code(err) != codes.FailedPrecondition {
+				t.Fatalf("ComposeObject() err %v, want code %v", err, codes.FailedPrecondition)
+			}
+			link, err := gateway.CreateShareLink(withExtensionCaller(ctx, testCaller{owner: true}), &ShareLinkRequest{Bucket: testBucket1, Object: object})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, accept := range []string{"", compression} {
+				req := httptest.NewRequest(http.MethodGet, link.GetUrl(), nil)
+				req.Header.Set("Accept-Encoding", accept)
+				rec := httptest.NewRecorder()
+				gateway.ServeShareLink(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status %v, but got %v", http.StatusOK, rec.Code)
+				}
+				body := rec.Body.Bytes()
+				if accept != "" {
+					if rec.Header().Get("Content-Encoding") != compression {
+						t.Fatalf("expected Content-Encoding %v, but got %q", compression, rec.Header().Get("Content-Encoding"))
+					}
+					dr, err := decompressReader(compression, bytes.NewReader(body))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if body, err = ioutil.ReadAll(dr); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if string(body) != data {
+					t.Fatalf("shared data does not match with Accept-Encoding %q", accept)
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"zstd", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsEncoding(r.Header, compressionGzip); got != tt.want {
+			t.Errorf("acceptsEncoding(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
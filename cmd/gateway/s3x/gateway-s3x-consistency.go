@@ -0,0 +1,77 @@
+package s3x
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+Writes are read-after-write consistent. PutObject, CopyObject, CompleteMultipartUpload, DeleteObject(s), and the
+bucket operations only return success after the data and the protocol buffer objects and buckets they created are
+stored on TemporalX, and the new bucket hash is committed to the ledger datastore together with the listing records
+and index entries of the changed objects, see the notes of commitBucket. Every GetObject, GetObjectInfo, and listing
+that starts after a write returned, on the gateway that wrote or on any other gateway of its cluster, sees the write,
+and a deleted object is neither read nor listed. Listing sessions are the exception, every page of a session lists
+the bucket as it was when the session started.
+
+With the strict consistency, the default, the badger datastore syncs every commit to disk before it returns, so a
+write that returned survives a crash of the gateway or its machine. The relaxed consistency trades that durability
+for throughput: commits are visible to reads as soon as they return, so reads are still consistent, but are only
+synced to disk every consistencySyncInterval, and the writes of the last interval can be lost by a crash of the
+machine. Data of lost writes is unreferenced and removed by the garbage collection. The consistency of a cluster is
+the consistency of its host, which holds the datastore.
+
+The ledger caches loaded buckets, which is safe while the gateway is the only writer of its datastore. The gateways
+of a cluster and the replicas of a crdt datastore also receive the writes of other gateways, so they check the
+cached bucket hash against the datastore on every access. Replicas of a crdt datastore are only consistent with
+their own writes, the writes of other replicas become visible once they are replicated.
+
+Data uploaded with the async or false x-s3x-pin modes is stored but not yet pinned when PutObject returns, the
+ledger entry is committed either way.
+*/
+
+const (
+	// consistencyStrict syncs every ledger commit to disk before a write returns
+	consistencyStrict = "strict"
+	// consistencyRelaxed syncs ledger commits to disk every consistencySyncInterval
+	consistencyRelaxed = "relaxed"
+	// consistencySyncInterval is how often the ledger is synced to disk with the relaxed consistency
+	consistencySyncInterval = time.Second
+)
+
+// checkConsistency validates the consistency of a gateway with a datastore type
+func checkConsistency(consistency string, dsType DSType) error {
+	switch consistency {
+	case "", consistencyStrict:
+		return nil
+	case consistencyRelaxed:
+		if dsType == DSTypeRemote {
+			return fmt.Errorf("the consistency of a cluster is set on its host")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported consistency %q, supported values are [%s, %s]", consistency, consistencyStrict, consistencyRelaxed)
+	}
+}
+
+// ledgerSyncLoop syncs the ledger datastore to disk every interval until the gateway is shut down, the datastore
+// is synced when it is closed
+func (x *xObjects) ledgerSyncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := x.ledgerStore.backend.Sync(datastore.NewKey("/")); err != nil {
+				log.Printf("consistency: failed to sync the ledger datastore, error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+)
+
+func TestS3X_CheckConsistency(t *testing.T) {
+	tests := []struct {
+		consistency string
+		dsType      DSType
+		wantErr     bool
+	}{
+		{"", DSTypeBadger, false},
+		{consistencyStrict, DSTypeRemote, false},
+		{consistencyRelaxed, DSTypeBadger, false},
+		{consistencyRelaxed, DSTypeCrdt, false},
+		{consistencyRelaxed, DSTypeRemote, true},
+		{"eventual", DSTypeBadger, true},
+	}
+	for _, tt := range tests {
+		if err := checkConsistency(tt.consistency, tt.dsType); (err != nil) != tt.wantErr {
+			t.Errorf("checkConsistency(%q, %v) error = %v, wantErr %v", tt.consistency, tt.dsType, err, tt.wantErr)
+		}
+	}
+}
+
+func TestS3X_Consistency_Badger(t *testing.T) {
+	testS3XConsistency(t, DSTypeBadger, consistencyStrict)
+}
+func TestS3X_Consistency_Crdt(t *testing.T) {
+	testS3XConsistency(t, DSTypeCrdt, consistencyStrict)
+}
+func TestS3X_Consistency_Relaxed(t *testing.T) {
+	testS3XConsistency(t, DSTypeBadger, consistencyRelaxed)
+}
+func testS3XConsistency(t *testing.T, dsType DSType, consistency string) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.temx.Consistency = consistency
+	gateway.restart(t)
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// every write must be visible to the reads that start after it returned
+	const writers, rounds = 8, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs <- hammerConsistency(ctx, gateway.xObjects, fmt.Sprintf("hammer/%d", w), rounds)
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	loi, err := gateway.ListObjects(ctx, testBucket1, "hammer/", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loi.Objects) != 0 {
+		t.Fatalf("expected deleted objects not to be listed, got %v objects", len(loi.Objects))
+	}
+
+	// writes that returned survive a restart of the gateway
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("durable")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.restart(t)
+	var buf bytes.Buffer
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 7, &buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "durable" {
+		t.Fatalf("expected the object to be read after a restart, got %q", buf.String())
+	}
+}
+
+// hammerConsistency writes, overwrites and deletes an object, and checks that reads and listings see every write
+func hammerConsistency(ctx context.Context, x *xObjects, object string, rounds int) error {
+	for i := 0; i < rounds; i++ {
+		data := []byte(fmt.Sprintf("%s-%d", object, i))
+		r, err := hash.NewReader(bytes.NewReader(data), int64(len(data)), "", "", int64(len(data)), false)
+		if err != nil {
+			return err
+		}
+		if _, err := x.PutObject(ctx, testBucket1, object, minio.NewPutObjReader(r, nil, nil), minio.ObjectOptions{}); err != nil {
+			return err
+		}
+		info, err := x.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("object %s not found after the write returned: %v", object, err)
+		}
+		if info.Size != int64(len(data)) {
+			return fmt.Errorf("object %s has size %v after writing %v bytes", object, info.Size, len(data))
+		}
+		var buf bytes.Buffer
+		if err := x.GetObject(ctx, testBucket1, object, 0, int64(len(data)), &buf, "", minio.ObjectOptions{}); err != nil {
+			return err
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			return fmt.Errorf("object %s read %q after writing %q", object, buf.Bytes(), data)
+		}
+		loi, err := x.ListObjects(ctx, testBucket1, object, "", "", 10)
+		if err != nil {
+			return err
+		}
+		if len(loi.Objects) != 1 || loi.Objects[0].Name != object || loi.Objects[0].Size != int64(len(data)) {
+			return fmt.Errorf("object %s is not listed after the write returned: %+v", object, loi.Objects)
+		}
+	}
+	if err := x.DeleteObject(ctx, testBucket1, object); err != nil {
+		return err
+	}
+	if _, err := x.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{}); err == nil {
+		return fmt.Errorf("object %s found after the delete returned", object)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/ipfs/go-cid"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+)
+
+/* Design Notes
+---------------
+
+Copies reference the DAG of their source, so they do not touch object data. Copies that change the encryption
+of an object, such as copies with the REPLACE directive to an encrypted destination, need new data, the S3
+handlers stream the source through decrypting and encrypting readers into CopyObject, which stores the stream
+as a new object. These copies read and write the whole object, so for large objects:
+
+* the copied stream is cut into chunks of chunkSize bytes, or by the chunker of the destination bucket, which
+  are uploaded in parallel by uploadChunks. Buckets with erasure coding or replication store the stream like
+  any upload, since their data hashes must match across nodes.
+* copy sources are read with parallel downloads of the blocks linked from the root of their data, which are
+  written in order, if the root only links to other blocks. At most copyWorkers chunks or blocks are held.
+* the progress of each copy that stores new data is tracked in memory while it runs, and listed by the
+  ListCopies extension api, so operators can follow long running copies.
+*/
+
+// copyWorkers is the number of chunks uploaded, or blocks downloaded, in parallel by copies that store new data
+const copyWorkers = 4
+
+// copyTracker tracks the copies that store new data while they run
+type copyTracker struct {
+	mu     sync.Mutex
+	nextID uint64
+	copies map[string]*copyProgress
+}
+
+// copyProgress is the progress of a copy that stores new data
+type copyProgress struct {
+	id                   string
+	srcBucket, srcObject string
+	dstBucket, dstObject string
+	size                 int64
+	copied               int64 // accessed atomically
+	started              time.Time
+}
+
+// start tracks a new copy of size bytes, -1 if unknown
+func (t *copyTracker) start(srcBucket, srcObject, dstBucket, dstObject string, size int64, now time.Time) *copyProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	p := &copyProgress{
+		id:        strconv.FormatUint(t.nextID, 10),
+		srcBucket: srcBucket,
+		srcObject: srcObject,
+		dstBucket: dstBucket,
+		dstObject: dstObject,
+		size:      size,
+		started:   now,
+	}
+	if t.copies == nil {
+		t.copies = make(map[string]*copyProgress)
+	}
+	t.copies[p.id] = p
+	return p
+}
+
+// finish stops tracking a copy
+func (t *copyTracker) finish(p *copyProgress) {
+	t.mu.Lock()
+	delete(t.copies, p.id)
+	t.mu.Unlock()
+}
+
+// list returns the api representation of the copies to bucket at now, or of all copies if bucket is empty
+func (t *copyTracker) list(bucket string, now time.Time) []*CopyProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	copies := make([]*CopyProgress, 0, len(t.copies))
+	for _, p := range t.copies {
+		if bucket != "" && p.dstBucket != bucket {
+			continue
+		}
+		c := &CopyProgress{
+			Id:          p.id,
+			SrcBucket:   p.srcBucket,
+			SrcObject:   p.srcObject,
+			DstBucket:   p.dstBucket,
+			DstObject:   p.dstObject,
+			Bytes:       p.size,
+			CopiedBytes: atomic.LoadInt64(&p.copied),
+			Started:     p.started.Unix(),
+		}
+		if age := now.Sub(p.started); age > 0 {
+			c.AgeSeconds = int64(age / time.Second)
+		}
+		copies = append(copies, c)
+	}
+	sort.Slice(copies, func(i, j int) bool {
+		if copies[i].Started != copies[j].Started {
+			return copies[i].Started < copies[j].Started
+		}
+		return len(copies[i].Id) < len(copies[j].Id) || len(copies[i].Id) == len(copies[j].Id) && copies[i].Id < copies[j].Id
+	})
+	return copies
+}
+
+// reader returns a reader of r that counts the copied bytes
+func (p *copyProgress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *copyProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	atomic.AddInt64(&r.p.copied, int64(n))
+	return n, err
+}
+
+// ListCopies returns the copies in progress that store new data, oldest first
+func (x *xObjects) ListCopies(ctx context.Context, req *ListCopiesRequest) (*ListCopiesResponse, error) {
+	return &ListCopiesResponse{Copies: x.copies.list(req.GetBucket(), x.clock.Now())}, nil
+}
+
+// copyTouchesData returns true if a copy to an object with the given metadata has to store new data, because
+// the encryption of the destination differs from the encryption of the source
+func copyTouchesData(src *ObjectInfo, dstMetadata map[string]string) bool {
+	encryption := func(metadata map[string]string) map[string]string {
+		m := make(map[string]string)
+		for k, v := range metadata {
+			if isEncryptionMetadataKey(k) {
+				m[k] = v
+			}
+		}
+		return m
+	}
+	srcEncryption, dstEncryption := encryption(src.GetUserDefined()), encryption(dstMetadata)
+	if len(srcEncryption) != len(dstEncryption) {
+		return true
+	}
+	for k, v := range srcEncryption {
+		if dstEncryption[k] != v {
+			return true
+		}
+	}
+	return false
+}
+
+// fileLinks returns the hashes of the blocks linked from the unixfs file node of hash, nil if the node
+// has data of its own or is not a unixfs file node
+func fileLinks(ctx context.Context, dag pb.NodeAPIClient, hash string) ([]string, error) {
+	c, err := cid.Decode(hash)
+	if err != nil {
+		return nil, err
+	}
+	if c.Type() != cid.DagProtobuf {
+		return nil, nil
+	}
+	data, err := ipfsBytes(ctx, dag, hash)
+	if err != nil {
+		return nil, err
+	}
+	node, fsData, err := decodeFileNode(data)
+	if err != nil {
+		return nil, err
+	}
+	if fsData.GetType() != unixfs_pb.Data_File || len(fsData.GetData()) > 0 || len(node.Links()) < 2 {
+		return nil, nil
+	}
+	links := make([]string, 0, len(node.Links()))
+	for _, l := range node.Links() {
+		links = append(links, l.Cid.String())
+	}
+	return links, nil
+}
+
+// parallelDownload writes the data of hash to w, downloading the blocks linked from its root in parallel
+func (x *xObjects) parallelDownload(ctx context.Context, w io.Writer, hash string) (int64, error) {
+	fileClient, dagClient, err := x.dataClients(hash)
+	if err != nil {
+		return 0, err
+	}
+	links, err := fileLinks(ctx, dagClient, hash)
+	if err != nil {
+		return 0, err
+	}
+	if links == nil {
+		return ipfsFileDownload(ctx, fileClient, w, hash, 0, 0)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type block struct {
+		data []byte
+		err  error
+	}
+	results := make([]chan block, len(links))
+	for i := range results {
+		results[i] = make(chan block, 1)
+	}
+	slots := make(chan struct{}, copyWorkers)
+	go func() {
+		for i, link := range links {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(link string, result chan<- block) {
+				var buf bytes.Buffer
+				_, err := ipfsFileDownload(ctx, fileClient, &buf, link, 0, 0)
+				result <- block{data: buf.Bytes(), err: err}
+			}(link, results[i])
+		}
+	}()
+	var written int64
+	for i := range links {
+		b := <-results[i]
+		<-slots // start the next download while this block is written
+		if b.err != nil {
+			return written, b.err
+		}
+		n, err := w.Write(b.data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
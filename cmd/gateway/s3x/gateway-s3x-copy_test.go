@@ -0,0 +1,168 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+)
+
+func TestS3X_Copy_Badger(t *testing.T) {
+	testS3XCopy(t, DSTypeBadger)
+}
+func TestS3X_Copy_Crdt(t *testing.T) {
+	testS3XCopy(t, DSTypeCrdt)
+}
+func testS3XCopy(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// larger than two chunks, so the copy is uploaded as three chunks
+	data := make([]byte, 2*chunkSize+1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	if _, err := gateway.PutObject(ctx, testBucket1, "source", getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := map[string]string{crypto.SSESealAlgorithm: "DARE-SHA256", crypto.S3SealedKey: "c2VhbGVk"}
+	expectData := func(t *testing.T, object string) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gateway.GetObject(ctx, testBucket1, object, 0, int64(len(data)), &buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("the data of %s does not match the source", object)
+		}
+	}
+	t.Run("Metadata", func(t *testing.T) {
+		// copies that keep the encryption of the source reference the data of the source
+		srcInfo := minio.ObjectInfo{PutObjReader: getTestPutObjectReader(t, []byte("unread"))}
+		if _, err := gateway.CopyObject(ctx, testBucket1, "source", testBucket1, "metadata", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		src, err := gateway.ledgerStore.Object(ctx, testBucket1, "source")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := gateway.ledgerStore.Object(ctx, testBucket1, "metadata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.GetDataHash() != dst.GetDataHash() {
+			t.Fatalf("expected the copy to reference the data of the source %s, but got %s", src.GetDataHash(), dst.GetDataHash())
+		}
+		expectData(t, "metadata")
+	})
+	t.Run("Data", func(t *testing.T) {
+		srcInfo := minio.ObjectInfo{
+			UserDefined:  encrypted,
+			PutObjReader: getTestPutObjectReader(t, data),
+		}
+		info, err := gateway.CopyObject(ctx, testBucket1, "source", testBucket1, "encrypted", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len(data)) || info.UserDefined[crypto.S3SealedKey] != "c2VhbGVk" {
+			t.Fatalf("unexpected object info %+v", info)
+		}
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, "encrypted")
+		if err != nil {
+			t.Fatal(err)
+		}
+		links, err := fileLinks(ctx, gateway.dagClient, obj.GetDataHash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(links) != 3 {
+			t.Fatalf("expected the copy to link 3 chunks, but got %d", len(links))
+		}
+		expectData(t, "encrypted")
+	})
+	t.Run("ParallelDownload", func(t *testing.T) {
+		// copy sources are read with CheckCopyPrecondFn set, which downloads the linked chunks in parallel
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, "encrypted", nil, nil, minio.LockType(0), minio.ObjectOptions{
+			CheckCopyPrecondFn: func(minio.ObjectInfo, string) bool { return false },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		got, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("the parallel download does not match the source")
+		}
+	})
+	t.Run("Progress", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		srcInfo := minio.ObjectInfo{
+			UserDefined:  encrypted,
+			PutObjReader: minio.NewPutObjReader(getTestHashReader(t, pr, int64(len(data))), nil, nil),
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := gateway.CopyObject(ctx, testBucket1, "source", testBucket1, "progress", srcInfo, minio.ObjectOptions{}, minio.ObjectOptions{})
+			done <- err
+		}()
+		if _, err := pw.Write(data[:1024]); err != nil {
+			t.Fatal(err)
+		}
+		var copies []*CopyProgress
+		for i := 0; i < 100; i++ {
+			resp, err := gateway.ListCopies(ctx, &ListCopiesRequest{Bucket: testBucket1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if copies = resp.GetCopies(); len(copies) == 1 && copies[0].GetCopiedBytes() == 1024 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if len(copies) != 1 {
+			t.Fatalf("expected 1 copy in progress, but got %d", len(copies))
+		}
+		c := copies[0]
+		if c.GetSrcObject() != "source" || c.GetDstObject() != "progress" || c.GetBytes() != int64(len(data)) || c.GetCopiedBytes() != 1024 {
+			t.Fatalf("unexpected copy progress %+v", c)
+		}
+		resp, err := gateway.ListCopies(ctx, &ListCopiesRequest{Bucket: testBucket2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetCopies()) != 0 {
+			t.Fatalf("expected no copies to %s, but got %d", testBucket2, len(resp.GetCopies()))
+		}
+		if _, err := pw.Write(data[1024:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		expectData(t, "progress")
+		resp, err = gateway.ListCopies(ctx, &ListCopiesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetCopies()) != 0 {
+			t.Fatalf("expected finished copies to be removed, but got %d", len(resp.GetCopies()))
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+/* Design Notes
+---------------
+
+Every call to TemporalX made for an S3 request carries the correlation id of the request in the
+x-correlation-id gRPC metadata, so the logs of TemporalX can be joined with the logs of the gateway for one
+request. The correlation id is the trace id of the W3C traceparent header of the request if it has a valid one,
+which also joins the logs of the services the client called before, and the x-amz-request-id of the request
+otherwise, which is returned to the client and logged by the gateway. A valid traceparent is also forwarded
+as is in the traceparent metadata, so TemporalX can continue the trace.
+
+The metadata is set by interceptors of the connections to TemporalX, from the request info that the S3
+handlers keep in the context of a request, so every call of the gateway, the ledger, and the crdt datastore
+that is made with the context of a request carries it, without each call site passing it. Calls of background
+loops, such as garbage collection and pin checks, belong to no request and carry no correlation id.
+*/
+
+const (
+	// correlationIDKey is the gRPC metadata of the correlation id of a TemporalX call
+	correlationIDKey = "x-correlation-id"
+	// traceParentKey is the gRPC metadata of the W3C trace context of a TemporalX call
+	traceParentKey = "traceparent"
+)
+
+// correlationDialOptions returns the interceptors that set the correlation id on the calls of a connection
+func correlationDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(withCorrelationID(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withCorrelationID(ctx), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// withCorrelationID returns ctx with the correlation id of its S3 request in the outgoing gRPC metadata,
+// or ctx if it has no S3 request
+func withCorrelationID(ctx context.Context) context.Context {
+	info := logger.GetReqInfo(ctx)
+	if info == nil || info.RequestID == "" {
+		return ctx
+	}
+	for _, tag := range info.GetTags() {
+		if tag.Key != xhttp.TraceParent {
+			continue
+		}
+		if traceID, ok := parseTraceParent(tag.Val); ok {
+			return metadata.AppendToOutgoingContext(ctx, correlationIDKey, traceID, traceParentKey, tag.Val)
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, correlationIDKey, info.RequestID)
+}
+
+// parseTraceParent returns the trace id of a version 00 W3C traceparent, which is
+// 00-<32 hex digits trace id>-<16 hex digits parent id>-<2 hex digits flags>
+func parseTraceParent(v string) (string, bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", false
+	}
+	for i, size := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != size || strings.ToLower(parts[i]) != parts[i] {
+			return "", false
+		}
+		if _, err := hex.DecodeString(parts[i]); err != nil {
+			return "", false
+		}
+		// the trace id and parent id must not be all zeros
+		if (i == 1 || i == 2) && strings.Trim(parts[i], "0") == "" {
+			return "", false
+		}
+	}
+	return parts[1], true
+}
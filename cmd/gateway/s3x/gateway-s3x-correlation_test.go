@@ -0,0 +1,50 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestS3X_CorrelationID(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	for _, tt := range []struct {
+		value string
+		valid bool
+	}{
+		{traceParent, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+	} {
+		if _, ok := parseTraceParent(tt.value); ok != tt.valid {
+			t.Fatalf("expected %q to be valid %v", tt.value, tt.valid)
+		}
+	}
+	outgoing := func(ctx context.Context) metadata.MD {
+		md, _ := metadata.FromOutgoingContext(withCorrelationID(ctx))
+		return md
+	}
+	if md := outgoing(context.Background()); len(md.Get(correlationIDKey)) != 0 {
+		t.Fatalf("expected no correlation id without a request, got %v", md)
+	}
+	info := &logger.ReqInfo{RequestID: "16B3C5F8E1A2D4F0"}
+	ctx := logger.SetReqInfo(context.Background(), info)
+	if md := outgoing(ctx); len(md.Get(correlationIDKey)) != 1 || md.Get(correlationIDKey)[0] != info.RequestID || len(md.Get(traceParentKey)) != 0 {
+		t.Fatalf("expected the request id as correlation id, got %v", md)
+	}
+	info.AppendTags(xhttp.TraceParent, "invalid")
+	if md := outgoing(ctx); md.Get(correlationIDKey)[0] != info.RequestID || len(md.Get(traceParentKey)) != 0 {
+		t.Fatalf("expected an invalid traceparent to be ignored, got %v", md)
+	}
+	ctx = logger.SetReqInfo(context.Background(), (&logger.ReqInfo{RequestID: info.RequestID}).AppendTags(xhttp.TraceParent, traceParent))
+	if md := outgoing(ctx); md.Get(correlationIDKey)[0] != "4bf92f3577b34da6a3ce929d0e0e4736" || md.Get(traceParentKey)[0] != traceParent {
+		t.Fatalf("expected the trace id as correlation id, got %v", md)
+	}
+}
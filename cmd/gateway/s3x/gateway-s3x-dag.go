@@ -0,0 +1,151 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-merkledag"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+The data of an object is a unixfs file DAG on IPFS, chunked by TemporalX or by the chunker of its bucket on
+upload, or linked by the gateway from other files for composed objects and copies. GetObjectDAG walks the DAG from the data hash of an object
+in depth first order, which is the order of the data, so every block is returned with the offset and size of
+the data stored in and below it, and clients can fetch the blocks of a range of an object directly from IPFS.
+
+The offsets of linked blocks are computed from the blocksizes of their parent, so walking a DAG only reads the
+blocks that are returned, and the walk stops after maxBlocks blocks. Erasure coded objects are stored as shards
+on several nodes instead of a single DAG, so they have no DAG to return.
+*/
+
+const (
+	// defaultDAGBlocks is the number of blocks returned if the request does not limit them
+	defaultDAGBlocks = 1000
+	// maxDAGBlocks is the maximum number of blocks returned by a request
+	maxDAGBlocks = 10000
+)
+
+// decodeFileNode decodes a dag-pb block and its unixfs data
+func decodeFileNode(data []byte) (*merkledag.ProtoNode, *unixfs_pb.Data, error) {
+	node, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	fsData := &unixfs_pb.Data{}
+	if err := proto.Unmarshal(node.Data(), fsData); err != nil {
+		return nil, nil, err
+	}
+	return node, fsData, nil
+}
+
+// GetObjectDAG returns the IPFS blocks of the data of an object with their sizes and links, root first
+func (x *xObjects) GetObjectDAG(ctx context.Context, req *ObjectDAGRequest) (*ObjectDAGResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	max := req.GetMaxBlocks()
+	switch {
+	case max < 0:
+		return nil, status.Error(codes.InvalidArgument, "max blocks can not be negative")
+	case max == 0:
+		max = defaultDAGBlocks
+	case max > maxDAGBlocks:
+		max = maxDAGBlocks
+	}
+	obj, err := x.ledgerStore.Object(ctx, req.GetBucket(), req.GetObject())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if obj.GetErasure() != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "object is erasure coded into %d shards, which are not a single DAG", len(obj.GetErasure().GetShardHashes()))
+	}
+	resp := &ObjectDAGResponse{
+		Bucket:      req.GetBucket(),
+		Object:      req.GetObject(),
+		DataHash:    obj.GetDataHash(),
+		Compression: obj.ObjectInfo.GetCompression(),
+		Chunker:     obj.ObjectInfo.GetChunker(),
+	}
+	resp.Blocks, resp.Truncated, err = x.dagBlocks(ctx, obj.GetDataHash(), int(max))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return resp, nil
+}
+
+// dagBlocks returns up to max blocks of the DAG of hash in depth first order, and true if more blocks follow
+func (x *xObjects) dagBlocks(ctx context.Context, hash string, max int) ([]*DAGBlock, bool, error) {
+	type pending struct {
+		hash          string
+		offset, depth int64
+	}
+	var (
+		blocks []*DAGBlock
+		stack  = []pending{{hash: hash}}
+	)
+	for len(stack) > 0 {
+		if len(blocks) == max {
+			return blocks, true, nil
+		}
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		c, err := cid.Decode(p.hash)
+		if err != nil {
+			return nil, false, err
+		}
+		data, err := ipfsBytes(ctx, x.dagClient, p.hash)
+		if err != nil {
+			return nil, false, err
+		}
+		block := &DAGBlock{
+			Cid:    p.hash,
+			Codec:  cid.CodecToStr[c.Type()],
+			Size_:  int64(len(data)),
+			Offset: p.offset,
+			Depth:  p.depth,
+		}
+		blocks = append(blocks, block)
+		switch c.Type() {
+		case cid.Raw:
+			block.DataSize = int64(len(data))
+			continue
+		case cid.DagProtobuf:
+		default:
+			return nil, false, fmt.Errorf("%v has unsupported codec %v", p.hash, block.Codec)
+		}
+		node, fsData, err := decodeFileNode(data)
+		if err != nil {
+			return nil, false, err
+		}
+		block.Type = strings.ToLower(fsData.GetType().String())
+		block.DataSize = int64(len(fsData.GetData()))
+		if fsData.Filesize != nil {
+			block.DataSize = int64(fsData.GetFilesize())
+		}
+		// the data of the node comes before the data of its links, in the order of the links
+		offsets := make([]int64, len(node.Links()))
+		offset := p.offset + int64(len(fsData.GetData()))
+		for i, l := range node.Links() {
+			block.Links = append(block.Links, &DAGLink{Cid: l.Cid.String(), Name: l.Name, Size_: l.Size})
+			offsets[i] = offset
+			if i < len(fsData.GetBlocksizes()) {
+				offset += int64(fsData.GetBlocksizes()[i])
+			}
+		}
+		for i := len(node.Links()) - 1; i >= 0; i-- {
+			stack = append(stack, pending{hash: node.Links()[i].Cid.String(), offset: offsets[i], depth: p.depth + 1})
+		}
+	}
+	return blocks, false, nil
+}
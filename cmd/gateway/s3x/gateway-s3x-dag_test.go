@@ -0,0 +1,103 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_ObjectDAG_Badger(t *testing.T) {
+	testS3XObjectDAG(t, DSTypeBadger)
+}
+func TestS3X_ObjectDAG_Crdt(t *testing.T) {
+	testS3XObjectDAG(t, DSTypeCrdt)
+}
+func testS3XObjectDAG(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"a": "hello", "b": "dag world"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, name, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gateway.ComposeObject(ctx, &ComposeObjectRequest{Bucket: testBucket1, Object: "ab", Sources: []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			req  *ObjectDAGRequest
+			code codes.Code
+		}{
+			{&ObjectDAGRequest{Object: "a"}, codes.InvalidArgument},
+			{&ObjectDAGRequest{Bucket: testBucket1}, codes.InvalidArgument},
+			{&ObjectDAGRequest{Bucket: testBucket1, Object: "a", MaxBlocks: -1}, codes.InvalidArgument},
+			{&ObjectDAGRequest{Bucket: testBucket1, Object: "missing"}, codes.NotFound},
+		} {
+			if _, err := gateway.GetObjectDAG(ctx, tt.req); status.Code(err) != tt.code {
+				t.Fatalf("%v: expected code %v, but got %v", tt.req, tt.code, err)
+			}
+		}
+	})
+	t.Run("Leaf", func(t *testing.T) {
+		resp, err := gateway.GetObjectDAG(ctx, &ObjectDAGRequest{Bucket: testBucket1, Object: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetDataHash() != obj.GetDataHash() || resp.GetTruncated() || len(resp.GetBlocks()) == 0 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		root := resp.GetBlocks()[0]
+		if root.GetCid() != obj.GetDataHash() || root.GetDataSize() != 5 || root.GetOffset() != 0 || root.GetDepth() != 0 {
+			t.Fatalf("unexpected root block %+v", root)
+		}
+	})
+	t.Run("Linked", func(t *testing.T) {
+		resp, err := gateway.GetObjectDAG(ctx, &ObjectDAGRequest{Bucket: testBucket1, Object: "ab"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks := resp.GetBlocks()
+		if resp.GetTruncated() || len(blocks) < 3 {
+			t.Fatalf("expected a root block with two linked blocks, but got %+v", resp)
+		}
+		root := blocks[0]
+		if root.GetCodec() != "protobuf" || root.GetType() != "file" || root.GetDataSize() != 14 || len(root.GetLinks()) != 2 {
+			t.Fatalf("unexpected root block %+v", root)
+		}
+		// the linked blocks follow their parent in the order of the data
+		var offsets []int64
+		for _, b := range blocks[1:] {
+			if b.GetDepth() == 1 {
+				offsets = append(offsets, b.GetOffset())
+			}
+		}
+		if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 5 {
+			t.Fatalf("expected linked blocks at offsets 0 and 5, but got %v", offsets)
+		}
+		if blocks[1].GetCid() != root.GetLinks()[0].GetCid() {
+			t.Fatalf("expected the first linked block %s after the root, but got %s", root.GetLinks()[0].GetCid(), blocks[1].GetCid())
+		}
+		truncated, err := gateway.GetObjectDAG(ctx, &ObjectDAGRequest{Bucket: testBucket1, Object: "ab", MaxBlocks: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(truncated.GetBlocks()) != 2 || !truncated.GetTruncated() {
+			t.Fatalf("expected 2 blocks and a truncated response, but got %d and %v", len(truncated.GetBlocks()), truncated.GetTruncated())
+		}
+	})
+}
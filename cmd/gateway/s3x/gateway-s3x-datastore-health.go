@@ -0,0 +1,295 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/* Design Notes
+---------------
+
+A degrading Badger volume shows as slow and failing ledger operations long before S3 requests fail with errors
+that point at the datastore. The ledger datastore is wrapped by an instrumentedDatastore, so every Get, Has,
+GetSize, Put, Delete, Query, batch commit, and sync of the ledger is timed in a latency histogram by operation,
+and its errors are counted by operation. ErrNotFound is a result, not an error. A query is timed until its
+results were read or closed, and fails if reading a result fails.
+
+The operations of the last dsHealthWindow are also counted in a ring of one minute buckets, which is summarized
+by datastoreHealth: the number of operations, of failed operations, and of operations slower than the slow
+operation threshold. The error budget is the share of those operations that may fail or be slow. A gateway whose
+datastore exceeded its error budget reports itself not ready, so load balancers and orchestrators move traffic
+away before requests start failing, and returns to ready once the window passed without exceeding it. The budget
+is only checked once the window has dsHealthMinOps operations, so a single failure of an idle gateway does not
+take it out of service. The summary is returned by GetDatastoreStats.
+*/
+
+const (
+	// dsHealthWindow is the time the health of the ledger datastore is summarized over
+	dsHealthWindow = 5 * time.Minute
+	// dsHealthBucket is the time of one bucket of the health window
+	dsHealthBucket = time.Minute
+	// dsHealthMinOps is how many operations the health window needs before the error budget is checked
+	dsHealthMinOps = 20
+	// defaultDSErrorBudget is the share of operations that may fail or be slow by default
+	defaultDSErrorBudget = 0.01
+	// defaultDSSlowOp is the duration of slow datastore operations by default
+	defaultDSSlowOp = time.Second
+)
+
+var (
+	datastoreOpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "s3x",
+		Subsystem: "datastore",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of the operations on the ledger datastore in seconds by operation",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"op"})
+	datastoreOpErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3x",
+		Subsystem: "datastore",
+		Name:      "operation_errors_total",
+		Help:      "Total number of failed operations on the ledger datastore by operation",
+	}, []string{"op"})
+)
+
+func init() {
+	prometheus.MustRegister(datastoreOpDuration, datastoreOpErrors)
+}
+
+// dsHealthCounts are the operations of one bucket of the health window
+type dsHealthCounts struct {
+	start                   time.Time
+	ops, errors, slows, bad int64
+}
+
+// datastoreHealth summarizes the operations on the ledger datastore in the health window
+type datastoreHealth struct {
+	mu      sync.Mutex
+	budget  float64
+	slowOp  time.Duration
+	buckets [dsHealthWindow / dsHealthBucket]dsHealthCounts
+}
+
+// newDatastoreHealth returns the health of a datastore with the default error budget
+func newDatastoreHealth() *datastoreHealth {
+	return &datastoreHealth{budget: defaultDSErrorBudget, slowOp: defaultDSSlowOp}
+}
+
+// setBudget sets the share of operations that may fail or take at least slowOp, the defaults are kept for zero values
+func (h *datastoreHealth) setBudget(budget float64, slowOp time.Duration) error {
+	if budget < 0 || budget > 1 {
+		return fmt.Errorf("datastore error budget must be between 0 and 1, got %v", budget)
+	}
+	if slowOp < 0 {
+		return fmt.Errorf("datastore slow operation duration can not be negative, got %v", slowOp)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if budget > 0 {
+		h.budget = budget
+	}
+	if slowOp > 0 {
+		h.slowOp = slowOp
+	}
+	return nil
+}
+
+// record counts an operation that ended at now after took
+func (h *datastoreHealth) record(now time.Time, took time.Duration, failed bool) {
+	start := now.Truncate(dsHealthBucket)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := &h.buckets[start.Unix()/int64(dsHealthBucket.Seconds())%int64(len(h.buckets))]
+	if !b.start.Equal(start) {
+		*b = dsHealthCounts{start: start}
+	}
+	b.ops++
+	if failed {
+		b.errors++
+	}
+	slow := took >= h.slowOp
+	if slow {
+		b.slows++
+	}
+	// an operation that failed slowly is counted against the budget once
+	if failed || slow {
+		b.bad++
+	}
+}
+
+// summary returns the health of the datastore in the health window that ends at now
+func (h *datastoreHealth) summary(now time.Time) *DatastoreHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := &DatastoreHealth{
+		WindowSeconds: int64(dsHealthWindow.Seconds()),
+		ErrorBudget:   h.budget,
+		SlowOpMillis:  h.slowOp.Milliseconds(),
+	}
+	oldest := now.Truncate(dsHealthBucket).Add(dsHealthBucket - dsHealthWindow)
+	var bad int64
+	for _, b := range h.buckets {
+		if b.start.Before(oldest) || b.start.After(now) {
+			continue
+		}
+		s.Operations += b.ops
+		s.Errors += b.errors
+		s.SlowOperations += b.slows
+		bad += b.bad
+	}
+	if s.Operations > 0 {
+		s.FailureRatio = float64(bad) / float64(s.Operations)
+	}
+	s.Healthy = s.Operations < dsHealthMinOps || s.FailureRatio <= h.budget
+	return s
+}
+
+// instrumentedDatastore times the operations on a datastore, and counts their errors
+type instrumentedDatastore struct {
+	ds     datastore.Batching
+	health *datastoreHealth
+}
+
+// instrumentDatastore returns ds with its operations recorded in health
+func instrumentDatastore(ds datastore.Batching, health *datastoreHealth) *instrumentedDatastore {
+	return &instrumentedDatastore{ds: ds, health: health}
+}
+
+// observe records an operation that started at start and failed with err
+func (d *instrumentedDatastore) observe(op string, start time.Time, err error) {
+	now := time.Now()
+	took := now.Sub(start)
+	failed := err != nil && err != datastore.ErrNotFound
+	datastoreOpDuration.WithLabelValues(op).Observe(took.Seconds())
+	if failed {
+		datastoreOpErrors.WithLabelValues(op).Inc()
+	}
+	d.health.record(now, took, failed)
+}
+
+// Get implements datastore.Read
+func (d *instrumentedDatastore) Get(key datastore.Key) (value []byte, err error) {
+	start := time.Now()
+	value, err = d.ds.Get(key)
+	d.observe("get", start, err)
+	return value, err
+}
+
+// Has implements datastore.Read
+func (d *instrumentedDatastore) Has(key datastore.Key) (exists bool, err error) {
+	start := time.Now()
+	exists, err = d.ds.Has(key)
+	d.observe("has", start, err)
+	return exists, err
+}
+
+// GetSize implements datastore.Read
+func (d *instrumentedDatastore) GetSize(key datastore.Key) (size int, err error) {
+	start := time.Now()
+	size, err = d.ds.GetSize(key)
+	d.observe("get_size", start, err)
+	return size, err
+}
+
+// Query implements datastore.Read, the query is recorded once its results were read, failed, or were closed
+func (d *instrumentedDatastore) Query(q query.Query) (query.Results, error) {
+	start := time.Now()
+	results, err := d.ds.Query(q)
+	if err != nil {
+		d.observe("query", start, err)
+		return nil, err
+	}
+	var (
+		once    sync.Once
+		lastErr error
+	)
+	done := func() { once.Do(func() { d.observe("query", start, lastErr) }) }
+	return query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			r, ok := results.NextSync()
+			if ok && r.Error != nil {
+				lastErr = r.Error
+			}
+			// readers stop at the first error
+			if !ok || r.Error != nil {
+				done()
+			}
+			return r, ok
+		},
+		Close: func() error {
+			err := results.Close()
+			if err != nil && lastErr == nil {
+				lastErr = err
+			}
+			done()
+			return err
+		},
+	}), nil
+}
+
+// Put implements datastore.Write
+func (d *instrumentedDatastore) Put(key datastore.Key, value []byte) error {
+	start := time.Now()
+	err := d.ds.Put(key, value)
+	d.observe("put", start, err)
+	return err
+}
+
+// Delete implements datastore.Write
+func (d *instrumentedDatastore) Delete(key datastore.Key) error {
+	start := time.Now()
+	err := d.ds.Delete(key)
+	d.observe("delete", start, err)
+	return err
+}
+
+// Sync implements datastore.Datastore
+func (d *instrumentedDatastore) Sync(prefix datastore.Key) error {
+	start := time.Now()
+	err := d.ds.Sync(prefix)
+	d.observe("sync", start, err)
+	return err
+}
+
+// Close implements io.Closer
+func (d *instrumentedDatastore) Close() error {
+	return d.ds.Close()
+}
+
+// Batch implements datastore.Batching, the writes of a batch are recorded when it is committed
+func (d *instrumentedDatastore) Batch() (datastore.Batch, error) {
+	b, err := d.ds.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedBatch{Batch: b, d: d}, nil
+}
+
+// instrumentedBatch records the commit of a batch
+type instrumentedBatch struct {
+	datastore.Batch
+	d *instrumentedDatastore
+}
+
+// Commit implements datastore.Batch
+func (b *instrumentedBatch) Commit() error {
+	start := time.Now()
+	err := b.Batch.Commit()
+	b.d.observe("commit", start, err)
+	return err
+}
+
+// IsReady returns false while the ledger datastore exceeds its error budget, or once the gateway was shut down
+func (x *xObjects) IsReady(ctx context.Context) bool {
+	if x.ctx.Err() != nil {
+		return false
+	}
+	// operations are timed with the system clock, not the clock of the ledger
+	return x.ledgerStore.dsHealth.summary(time.Now()).Healthy
+}
@@ -0,0 +1,156 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+// failingDatastore fails the operations on its keys with the prefix fail
+type failingDatastore struct {
+	datastore.Batching
+}
+
+var errDatastoreFailed = errors.New("datastore failed")
+
+func (d failingDatastore) Get(key datastore.Key) ([]byte, error) {
+	if key.IsDescendantOf(datastore.NewKey("fail")) {
+		return nil, errDatastoreFailed
+	}
+	return d.Batching.Get(key)
+}
+
+func (d failingDatastore) Query(q query.Query) (query.Results, error) {
+	if q.Prefix == "/fail" {
+		sent := false
+		return query.ResultsFromIterator(q, query.Iterator{Next: func() (query.Result, bool) {
+			if sent {
+				return query.Result{}, false
+			}
+			sent = true
+			return query.Result{Error: errDatastoreFailed}, true
+		}}), nil
+	}
+	return d.Batching.Query(q)
+}
+
+func TestDatastoreHealth(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newDatastoreHealth()
+	if err := h.setBudget(1.5, 0); err == nil {
+		t.Fatal("expected a budget above 1 to be invalid")
+	}
+	if err := h.setBudget(0.1, -time.Second); err == nil {
+		t.Fatal("expected a negative slow operation duration to be invalid")
+	}
+	if err := h.setBudget(0.1, 0); err != nil {
+		t.Fatal(err)
+	}
+	// a failure of an idle datastore is within the budget
+	h.record(start, time.Millisecond, true)
+	if s := h.summary(start); !s.Healthy || s.Errors != 1 || s.FailureRatio != 1 {
+		t.Fatalf("expected an idle datastore to be healthy, got %+v", s)
+	}
+	for i := 0; i < 8; i++ {
+		h.record(start.Add(time.Minute), time.Millisecond, false)
+	}
+	h.record(start.Add(time.Minute), defaultDSSlowOp, false)
+	// an operation that failed slowly uses the budget once
+	h.record(start.Add(2*time.Minute), 2*defaultDSSlowOp, true)
+	for i := 0; i < 9; i++ {
+		h.record(start.Add(2*time.Minute), time.Millisecond, false)
+	}
+	s := h.summary(start.Add(2 * time.Minute))
+	if s.Operations != 20 || s.Errors != 2 || s.SlowOperations != 2 || s.FailureRatio != 0.15 || s.Healthy {
+		t.Fatalf("expected 3 of 20 operations to exceed the budget of 0.1, got %+v", s)
+	}
+	// the first failure left the window, which has too few operations to check the budget
+	if s := h.summary(start.Add(5 * time.Minute)); s.Operations != 19 || s.Errors != 1 || !s.Healthy {
+		t.Fatalf("expected 19 operations within the budget, got %+v", s)
+	}
+	if s := h.summary(start.Add(7 * time.Minute)); s.Operations != 0 || !s.Healthy {
+		t.Fatalf("expected the datastore to recover once the window passed, got %+v", s)
+	}
+	// buckets of the ring are reused for later minutes
+	h.record(start.Add(10*time.Minute), time.Millisecond, false)
+	if s := h.summary(start.Add(10 * time.Minute)); s.Operations != 1 || s.Errors != 0 {
+		t.Fatalf("expected the reused bucket to be reset, got %+v", s)
+	}
+}
+
+func TestInstrumentedDatastore(t *testing.T) {
+	h := newDatastoreHealth()
+	ds := instrumentDatastore(failingDatastore{dssync.MutexWrap(datastore.NewMapDatastore())}, h)
+	if err := ds.Put(datastore.NewKey("ok"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Get(datastore.NewKey("ok")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Get(datastore.NewKey("missing")); err != datastore.ErrNotFound {
+		t.Fatalf("expected %v, got %v", datastore.ErrNotFound, err)
+	}
+	if _, err := ds.Get(datastore.NewKey("fail/1")); err != errDatastoreFailed {
+		t.Fatalf("expected %v, got %v", errDatastoreFailed, err)
+	}
+	b, err := ds.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(datastore.NewKey("ok")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ds.Query(query.Query{Prefix: "/ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := results.Rest(); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no entries, got %v %v", entries, err)
+	}
+	// a query fails once reading its results failed
+	results, err = ds.Query(query.Query{Prefix: "/fail"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := results.Rest(); err != errDatastoreFailed {
+		t.Fatalf("expected %v, got %v", errDatastoreFailed, err)
+	}
+	// put, get, missing get, failed get, commit, and the two queries
+	if s := h.summary(time.Now()); s.Operations != 7 || s.Errors != 2 {
+		t.Fatalf("expected 2 of 7 operations to fail, got %+v", s)
+	}
+}
+
+func TestS3X_DatastoreHealth(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !gateway.IsReady(ctx) {
+		t.Fatal("expected the gateway to be ready")
+	}
+	stats, err := gateway.GetDatastoreStats(ctx, &DatastoreStatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := stats.GetHealth(); h.GetOperations() == 0 || !h.GetHealthy() || h.GetErrorBudget() != defaultDSErrorBudget {
+		t.Fatalf("expected the operations of the bucket creation to be healthy, got %+v", h)
+	}
+	// a gateway that is shut down is not ready
+	if err := gateway.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.IsReady(ctx) {
+		t.Fatal("expected a gateway that was shut down to not be ready")
+	}
+}
@@ -0,0 +1,108 @@
+package s3x
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Objects uploaded with a gzip Content-Encoding are stored as is, and served with the same Content-Encoding.
+Like S3 behind CloudFront, buckets can opt in to decompress these objects for clients that do not accept gzip,
+for which the decoded size is recorded on upload. This is independent of the s3x bucket compression,
+which is always removed before data is served.
+*/
+
+// SetBucketDecompressOnRead configures if gzip encoded objects of a bucket are decompressed for clients that do not accept gzip
+func (x *xObjects) SetBucketDecompressOnRead(ctx context.Context, req *SetBucketDecompressOnReadRequest) (*SetBucketDecompressOnReadResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.DecompressOnRead = req.GetEnabled()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, decompress-on-read: %v", req.GetBucket(), req.GetEnabled())
+	return &SetBucketDecompressOnReadResponse{
+		Bucket:  req.GetBucket(),
+		Enabled: req.GetEnabled(),
+	}, nil
+}
+
+// isGzipEncoding returns true if a Content-Encoding is gzip
+func isGzipEncoding(contentEncoding string) bool {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		return true
+	}
+	return false
+}
+
+// userDefinedValue returns the value of a header in user defined metadata, ignoring the case of its name
+func userDefinedValue(userDefined map[string]string, header string) string {
+	for k, v := range userDefined {
+		if strings.EqualFold(k, header) {
+			return v
+		}
+	}
+	return ""
+}
+
+// shouldDecodeGzip returns true if an object should be served decompressed to a request with the given headers,
+// copy requests always receive the stored data.
+func shouldDecodeGzip(config *BucketConfig, info *ObjectInfo, h http.Header) bool {
+	return config.GetDecompressOnRead() &&
+		isGzipEncoding(info.GetContentEncoding()) &&
+		info.GetDecodedSize() > 0 &&
+		h != nil &&
+		h.Get("X-Amz-Copy-Source") == "" &&
+		!acceptsEncoding(h, "gzip")
+}
+
+// decodedSizeCounter determines the decompressed size of gzip data written to it,
+// Write never fails, so it can be used with io.TeeReader.
+type decodedSizeCounter struct {
+	pw   *io.PipeWriter
+	done chan int64
+}
+
+func newDecodedSizeCounter() *decodedSizeCounter {
+	pr, pw := io.Pipe()
+	c := &decodedSizeCounter{pw: pw, done: make(chan int64, 1)}
+	go func() {
+		var n int64
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			n, err = io.Copy(ioutil.Discard, zr)
+		}
+		if err != nil {
+			n = 0 // the data is not valid gzip, the decoded size is unknown
+		}
+		// drain the rest, so writes never block
+		_, _ = io.Copy(ioutil.Discard, pr)
+		c.done <- n
+	}()
+	return c
+}
+
+func (c *decodedSizeCounter) Write(p []byte) (int, error) {
+	_, _ = c.pw.Write(p)
+	return len(p), nil
+}
+
+// Size finishes counting and returns the decoded size, or 0 if the data was not valid gzip
+func (c *decodedSizeCounter) Size() int64 {
+	_ = c.pw.Close()
+	return <-c.done
+}
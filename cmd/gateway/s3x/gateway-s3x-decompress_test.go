@@ -0,0 +1,103 @@
+package s3x
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_DecompressOnRead_Badger(t *testing.T) {
+	testS3XDecompressOnRead(t, DSTypeBadger)
+}
+func TestS3X_DecompressOnRead_Crdt(t *testing.T) {
+	testS3XDecompressOnRead(t, DSTypeCrdt)
+}
+func testS3XDecompressOnRead(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	const plain = "hello gzip encoded world"
+	buf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write([]byte(plain)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	gzipOpts := minio.ObjectOptions{UserDefined: map[string]string{"Content-Encoding": "gzip"}}
+	if _, err := gateway.PutObject(ctx, testBucket1, "encoded", getTestPutObjectReader(t, []byte(encoded)), gzipOpts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "notgzip", getTestPutObjectReader(t, []byte(plain)), gzipOpts); err != nil {
+		t.Fatal(err)
+	}
+	for object, want := range map[string]int64{"encoded": int64(len(plain)), "notgzip": 0} {
+		oi, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if oi.GetDecodedSize() != want {
+			t.Fatalf("expected decoded size %v for %v, but got %v", want, object, oi.GetDecodedSize())
+		}
+	}
+	read := func(t *testing.T, object string, rs *minio.HTTPRangeSpec, h http.Header) (string, minio.ObjectInfo) {
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, object, rs, h, minio.LockType(0), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gr.Close()
+		data, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), gr.ObjInfo
+	}
+	t.Run("Disabled", func(t *testing.T) {
+		data, info := read(t, "encoded", nil, http.Header{})
+		if data != encoded || info.ContentEncoding != "gzip" {
+			t.Fatalf("expected the stored gzip data, but got encoding %q", info.ContentEncoding)
+		}
+	})
+	if _, err := gateway.SetBucketDecompressOnRead(ctx, &SetBucketDecompressOnReadRequest{Bucket: testBucket1, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		object   string
+		rs       *minio.HTTPRangeSpec
+		h        http.Header
+		wantData string
+		wantEnc  string
+	}{
+		{"Decoded", "encoded", nil, http.Header{}, plain, ""},
+		{"Decoded-Range", "encoded", &minio.HTTPRangeSpec{Start: 6, End: 9}, http.Header{}, plain[6:10], ""},
+		{"Accepts-Gzip", "encoded", nil, http.Header{"Accept-Encoding": {"gzip, deflate"}}, encoded, "gzip"},
+		{"Copy-Source", "encoded", nil, http.Header{"X-Amz-Copy-Source": {"/bucket/object"}}, encoded, "gzip"},
+		{"Unknown-Decoded-Size", "notgzip", nil, http.Header{}, plain, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, info := read(t, tt.object, tt.rs, tt.h)
+			if data != tt.wantData {
+				t.Fatalf("expected data %q, but got %q", tt.wantData, data)
+			}
+			if info.ContentEncoding != tt.wantEnc {
+				t.Fatalf("expected encoding %q, but got %q", tt.wantEnc, info.ContentEncoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package s3x
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+)
+
+/* Design Notes
+---------------
+
+The root credential of the gateway is the owner of every bucket, and its requests are allowed without any policy.
+Multi-tenant gateways in production can instead deny the requests of the root credential by default, so that a
+leaked root credential only grants what the bucket policies of the gateway explicitly allow it. The S3 handlers
+then evaluate the S3 requests of the root credential like those of any other account, against the bucket policies
+whose principal matches its access key, and deny its admin requests, since bucket policies can not allow admin
+actions. Requests that list all buckets or create a bucket have no bucket policy, and are always denied.
+
+The break-glass key is the emergency exit, a request of the root credential that carries it in the
+x-s3x-break-glass header is allowed as if deny-by-default was not set, and every such request is logged. The key
+only adds to the signature of the request, it does not replace the root credential. The header is removed from
+request traces, audit entries, and logged requests, and only the sha256 of the key is kept in memory, so the key
+should be long and random, and sent over TLS.
+*/
+
+// minBreakGlassKeySize is the minimum number of characters of a break-glass key
+const minBreakGlassKeySize = 16
+
+// ownerRestriction denies the requests of the root credential that are not allowed by bucket policies
+type ownerRestriction struct {
+	enabled bool
+	// breakGlassSum is the sha256 of the break-glass key, nil if there is none
+	breakGlassSum []byte
+}
+
+// newOwnerRestriction returns the restriction of the root credential, a break-glass key is only used when enabled
+func newOwnerRestriction(enabled bool, breakGlassKey string) (ownerRestriction, error) {
+	if breakGlassKey == "" {
+		return ownerRestriction{enabled: enabled}, nil
+	}
+	if !enabled {
+		return ownerRestriction{}, fmt.Errorf("a break-glass key is only used with deny-by-default")
+	}
+	if len(breakGlassKey) < minBreakGlassKeySize {
+		return ownerRestriction{}, fmt.Errorf("break-glass key must be at least %v characters", minBreakGlassKeySize)
+	}
+	sum := sha256.Sum256([]byte(breakGlassKey))
+	return ownerRestriction{enabled: true, breakGlassSum: sum[:]}, nil
+}
+
+// DenyOwnerByDefault returns true if requests of the root credential are only allowed by bucket policies
+func (x *xObjects) DenyOwnerByDefault() bool {
+	return x.denyByDefault.enabled
+}
+
+// IsBreakGlassKey returns true if key is the break-glass key of the gateway
+func (x *xObjects) IsBreakGlassKey(key string) bool {
+	if x.denyByDefault.breakGlassSum == nil || key == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return subtle.ConstantTimeCompare(sum[:], x.denyByDefault.breakGlassSum) == 1
+}
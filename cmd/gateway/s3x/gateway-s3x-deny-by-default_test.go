@@ -0,0 +1,45 @@
+package s3x
+
+import (
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+var _ minio.OwnerRestricter = (*xObjects)(nil)
+
+func TestS3X_DenyByDefault(t *testing.T) {
+	const key = "0123456789abcdef"
+	for _, tt := range []struct {
+		enabled bool
+		key     string
+		err     bool
+	}{
+		{false, "", false},
+		{true, "", false},
+		{true, key, false},
+		{false, key, true},
+		{true, "short", true},
+	} {
+		if _, err := newOwnerRestriction(tt.enabled, tt.key); (err != nil) != tt.err {
+			t.Fatalf("unexpected error %v for enabled %v with key %q", err, tt.enabled, tt.key)
+		}
+	}
+	x := &xObjects{}
+	if x.DenyOwnerByDefault() || x.IsBreakGlassKey("") {
+		t.Fatal("expected the root credential to not be restricted by default")
+	}
+	var err error
+	if x.denyByDefault, err = newOwnerRestriction(true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !x.DenyOwnerByDefault() || x.IsBreakGlassKey("") || x.IsBreakGlassKey(key) {
+		t.Fatal("expected the root credential to be restricted without a break-glass key")
+	}
+	if x.denyByDefault, err = newOwnerRestriction(true, key); err != nil {
+		t.Fatal(err)
+	}
+	if !x.DenyOwnerByDefault() || !x.IsBreakGlassKey(key) || x.IsBreakGlassKey("") || x.IsBreakGlassKey(key+"0") {
+		t.Fatal("expected only the break-glass key to be accepted")
+	}
+}
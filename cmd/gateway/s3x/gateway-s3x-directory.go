@@ -0,0 +1,221 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+)
+
+// GetDirectoryView returns the hash of the UnixFS directory view of a bucket, or of all buckets,
+// directories are rebuilt if their bucket changed since they were last built
+func (x *xObjects) GetDirectoryView(ctx context.Context, req *DirectoryViewRequest) (*DirectoryViewResponse, error) {
+	var (
+		d   *BucketDirectory
+		err error
+	)
+	if req.GetBucket() != "" {
+		d, err = x.bucketDirectory(ctx, req.GetBucket())
+	} else {
+		d, err = x.rootDirectory(ctx)
+	}
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, directory-hash: %s, directory-skipped: %v", req.GetBucket(), d.DirectoryHash, d.Skipped)
+	return &DirectoryViewResponse{
+		Hash:    d.DirectoryHash,
+		Skipped: d.Skipped,
+	}, nil
+}
+
+// rootDirectory builds the directory of all buckets, with the directory of each bucket under its name
+func (x *xObjects) rootDirectory(ctx context.Context) (*BucketDirectory, error) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		return nil, err
+	}
+	root := newDirectoryTree()
+	var skipped int64
+	for _, bucket := range names {
+		d, err := x.bucketDirectory(ctx, bucket)
+		if err == ErrLedgerBucketDoesNotExist {
+			continue // deleted while building
+		}
+		if err != nil {
+			return nil, err
+		}
+		c, err := cid.Decode(d.DirectoryHash)
+		if err != nil {
+			return nil, err
+		}
+		root.dirs[bucket] = &directoryTree{hash: &c, size: d.DataSize}
+		skipped += d.Skipped
+	}
+	c, size, err := root.save(ctx, x)
+	if err != nil {
+		return nil, err
+	}
+	return &BucketDirectory{DirectoryHash: c.String(), Skipped: skipped, DataSize: size}, nil
+}
+
+// bucketDirectory returns the directory of the committed state of a bucket, it is only built if the bucket changed
+// since the directory was last built
+func (x *xObjects) bucketDirectory(ctx context.Context, bucket string) (*BucketDirectory, error) {
+	bucketHash, err := x.ledgerStore.GetBucketHash(bucket)
+	if err != nil {
+		return nil, err
+	}
+	d, ok, err := x.ledgerStore.BucketDirectory(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if ok && d.BucketHash == bucketHash {
+		return d, nil
+	}
+	bucketHash, objects, err := x.ledgerStore.BucketState(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	// names are added in order, so the same object wins a collision every time
+	sort.Strings(names)
+	tree := newDirectoryTree()
+	d = &BucketDirectory{BucketHash: bucketHash}
+	for _, name := range names {
+		obj, err := ipfsObject(ctx, x.dagClient, objects[name])
+		if err != nil {
+			return nil, err
+		}
+		if !tree.add(name, obj) {
+			d.Skipped++
+		}
+	}
+	c, size, err := tree.save(ctx, x)
+	if err != nil {
+		return nil, err
+	}
+	d.DirectoryHash, d.DataSize = c.String(), size
+	if err := x.ledgerStore.PutBucketDirectory(bucket, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// directoryTree is a directory of the view that is being built
+type directoryTree struct {
+	files map[string]*ipld.Link
+	dirs  map[string]*directoryTree
+	// hash and size are set for directories that were already saved
+	hash *cid.Cid
+	size uint64
+}
+
+func newDirectoryTree() *directoryTree {
+	return &directoryTree{
+		files: make(map[string]*ipld.Link),
+		dirs:  make(map[string]*directoryTree),
+	}
+}
+
+// add adds the data of an object under its name, and returns false if the name is not a valid path,
+// collides with a directory, or the object has no data hash. Names ending with "/" add an empty directory.
+func (t *directoryTree) add(name string, obj *Object) bool {
+	isDir := strings.HasSuffix(name, "/")
+	segments := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." {
+			return false
+		}
+	}
+	if !isDir {
+		segments, name = segments[:len(segments)-1], segments[len(segments)-1]
+	}
+	dir := t
+	for _, s := range segments {
+		if _, ok := dir.files[s]; ok {
+			return false
+		}
+		sub, ok := dir.dirs[s]
+		if !ok {
+			sub = newDirectoryTree()
+			dir.dirs[s] = sub
+		}
+		dir = sub
+	}
+	if isDir {
+		return true
+	}
+	if _, ok := dir.dirs[name]; ok {
+		return false
+	}
+	c, err := cid.Decode(obj.GetDataHash())
+	if err != nil {
+		return false // erasure coded objects have no data hash
+	}
+	dir.files[name] = &ipld.Link{Name: name, Cid: c, Size: uint64(obj.GetObjectInfo().Size_)}
+	return true
+}
+
+// save saves the directory and its subdirectories as UnixFS directory nodes,
+// and returns the hash and the total size of the directory
+func (t *directoryTree) save(ctx context.Context, x *xObjects) (cid.Cid, uint64, error) {
+	if t.hash != nil {
+		return *t.hash, t.size, nil
+	}
+	links := make([]*ipld.Link, 0, len(t.files)+len(t.dirs))
+	var size uint64
+	for _, l := range t.files {
+		links = append(links, l)
+		size += l.Size
+	}
+	for name, sub := range t.dirs {
+		c, subSize, err := sub.save(ctx, x)
+		if err != nil {
+			return cid.Undef, 0, err
+		}
+		links = append(links, &ipld.Link{Name: name, Cid: c, Size: subSize})
+		size += subSize
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Name < links[j].Name })
+	node := &merkledag.ProtoNode{}
+	node.SetCidBuilder(merkledag.V1CidPrefix())
+	node.SetLinks(links)
+	data, err := proto.Marshal(&unixfs_pb.Data{Type: unixfs_pb.Data_Directory.Enum()})
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	node.SetData(data)
+	h, err := ipfsSaveProtoNode(ctx, x.dagClient, node)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	c, err := cid.Decode(h)
+	return c, size, err
+}
+
+// directoryLoop rebuilds the directories of changed buckets every interval until the gateway is shut down
+func (x *xObjects) directoryLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := x.rootDirectory(x.ctx); err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to build the directory view: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-merkledag"
+)
+
+func TestS3X_DirectoryView(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(object string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("directory view "+object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, object := range []string{"a.txt", "a.txt/b.txt", "docs/readme.md", "docs/img/logo.png", "empty/", "bad//name"} {
+		put(object)
+	}
+	// links returns the names of the links of a directory, to their hashes
+	links := func(hash string) map[string]string {
+		t.Helper()
+		data, err := ipfsBytes(ctx, gateway.dagClient, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := merkledag.DecodeProtobuf(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]string)
+		for _, l := range node.Links() {
+			m[l.Name] = l.Cid.String()
+		}
+		return m
+	}
+	view, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the collision with a.txt and the empty path segment are skipped
+	if view.Skipped != 2 {
+		t.Fatalf("expected 2 skipped objects, but got %v", view.Skipped)
+	}
+	top := links(view.Hash)
+	if len(top) != 3 || top["a.txt"] == "" || top["docs"] == "" || top["empty"] == "" {
+		t.Fatalf("unexpected directory %v", top)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top["a.txt"] != hash {
+		t.Fatalf("expected a.txt to link its data %v, but got %v", hash, top["a.txt"])
+	}
+	if docs := links(top["docs"]); len(docs) != 2 || len(links(docs["img"])) != 1 {
+		t.Fatalf("unexpected docs directory %v", docs)
+	}
+	if len(links(top["empty"])) != 0 {
+		t.Fatal("expected an empty directory")
+	}
+
+	// unchanged buckets keep their directory, changed buckets are rebuilt
+	again, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Hash != view.Hash {
+		t.Fatalf("expected the same directory, but got %v", again.Hash)
+	}
+	put("docs/new.md")
+	changed, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Hash == view.Hash || len(links(links(changed.Hash)["docs"])) != 3 {
+		t.Fatal("expected the directory to be rebuilt")
+	}
+
+	root, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := links(root.Hash)
+	if len(buckets) != 2 || buckets[testBucket1] != changed.Hash || buckets[testBucket2] == "" || root.Skipped != 2 {
+		t.Fatalf("unexpected root directory %v", buckets)
+	}
+
+	if err := gateway.DeleteBucket(ctx, testBucket2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := gateway.ledgerStore.BucketDirectory(testBucket2); err != nil || ok {
+		t.Fatalf("expected the directory of a deleted bucket to be removed, but got %v, %v", ok, err)
+	}
+	if _, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{Bucket: testBucket2}); err == nil {
+		t.Fatal("expected an error for a deleted bucket")
+	}
+}
@@ -0,0 +1,215 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A bucket with a DNSLink domain can be served by public IPFS gateways at that domain, once the domain has a
+_dnslink TXT record with the path of the directory view of the bucket. The gateway does not publish IPNS names,
+so the record links the immutable /ipfs/ path of the current directory, and has to be updated when the bucket
+changes. GetBucketDNSLink returns the current record, and if a DNS provider webhook is configured, the records
+of all buckets with a domain are posted to it every interval when they changed since they were last posted, so
+a small adapter can update the records with any DNS provider.
+*/
+
+const (
+	// defaultDNSLinkInterval is how often changed records are posted to the DNS provider webhook by default
+	defaultDNSLinkInterval = time.Minute
+	// dnslinkWebhookTimeout is the timeout of a post to the DNS provider webhook
+	dnslinkWebhookTimeout = 30 * time.Second
+)
+
+// SetBucketDNSLink sets the domain the directory view of a bucket is linked to, an empty domain disables DNSLink
+func (x *xObjects) SetBucketDNSLink(ctx context.Context, req *SetBucketDNSLinkRequest) (*SetBucketDNSLinkResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	domain := strings.ToLower(strings.TrimSuffix(req.GetDomain(), "."))
+	if domain != "" && !validDNSLinkDomain(domain) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid domain %q", req.GetDomain())
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.DnslinkDomain = domain
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, dnslink-domain: %s", req.GetBucket(), domain)
+	return &SetBucketDNSLinkResponse{
+		Bucket: req.GetBucket(),
+		Domain: domain,
+	}, nil
+}
+
+// GetBucketDNSLink returns the _dnslink TXT record of the domain of a bucket
+func (x *xObjects) GetBucketDNSLink(ctx context.Context, req *GetBucketDNSLinkRequest) (*DNSLinkRecord, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	config, err := x.ledgerStore.GetBucketConfig(ctx, req.GetBucket())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if config.GetDnslinkDomain() == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "bucket %s has no dnslink domain", req.GetBucket())
+	}
+	r, err := x.dnslinkRecord(ctx, req.GetBucket(), config.GetDnslinkDomain())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return r, nil
+}
+
+// dnslinkRecord returns the record that links a domain to the current directory view of a bucket
+func (x *xObjects) dnslinkRecord(ctx context.Context, bucket, domain string) (*DNSLinkRecord, error) {
+	d, err := x.bucketDirectory(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSLinkRecord{
+		Bucket: bucket,
+		Domain: domain,
+		Name:   "_dnslink." + domain,
+		Value:  "dnslink=/ipfs/" + d.DirectoryHash,
+	}, nil
+}
+
+// validDNSLinkDomain returns true for lowercase domain names with at least two labels
+func validDNSLinkDomain(domain string) bool {
+	if len(domain) > 253 {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, c := range l {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// dnslinkWebhook posts changed DNSLink records to a DNS provider webhook
+type dnslinkWebhook struct {
+	url    string
+	client *http.Client
+
+	mu sync.Mutex
+	// posted are the values last posted by record name
+	posted map[string]string
+}
+
+// newDNSLinkWebhook returns a webhook that posts to an http(s) url
+func newDNSLinkWebhook(webhook string) (*dnslinkWebhook, error) {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported dnslink webhook %q", webhook)
+	}
+	return &dnslinkWebhook{
+		url:    webhook,
+		client: &http.Client{Timeout: dnslinkWebhookTimeout},
+		posted: make(map[string]string),
+	}, nil
+}
+
+// post posts a record as json, unless the same value was already posted for its name
+func (w *dnslinkWebhook) post(ctx context.Context, r *DNSLinkRecord) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.posted[r.Name] == r.Value {
+		return false, nil
+	}
+	data, err := json.Marshal(struct {
+		*DNSLinkRecord
+		Type string `json:"type"`
+	}{r, "TXT"})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("dnslink webhook responded with status %v", resp.Status)
+	}
+	w.posted[r.Name] = r.Value
+	return true, nil
+}
+
+// dnslinkLoop posts the changed DNSLink records of all buckets every interval until the gateway is shut down
+func (x *xObjects) dnslinkLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			x.postDNSLinks(x.ctx)
+		}
+	}
+}
+
+// postDNSLinks posts the records of all buckets with a domain that changed since they were last posted
+func (x *xObjects) postDNSLinks(ctx context.Context) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		log.Printf("failed to post dnslink records: %v", err)
+		return
+	}
+	for _, bucket := range names {
+		config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+		if err != nil || config.GetDnslinkDomain() == "" {
+			continue
+		}
+		r, err := x.dnslinkRecord(ctx, bucket, config.GetDnslinkDomain())
+		if err != nil {
+			if ctx.Err() == nil && err != ErrLedgerBucketDoesNotExist {
+				log.Printf("bucket-name: %s, failed to build dnslink record: %v", bucket, err)
+			}
+			continue
+		}
+		posted, err := x.dnslinkWebhook.post(ctx, r)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("bucket-name: %s, failed to post dnslink record: %v", bucket, err)
+			}
+			continue
+		}
+		if posted {
+			log.Printf("bucket-name: %s, dnslink-record: %s, dnslink-value: %s", bucket, r.Name, r.Value)
+		}
+	}
+}
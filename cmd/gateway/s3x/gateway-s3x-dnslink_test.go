@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_DNSLink(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, domain := range []string{"localhost", "-bad.example.com", "under_score.example.com", "a..example.com"} {
+		if _, err := gateway.SetBucketDNSLink(ctx, &SetBucketDNSLinkRequest{Bucket: testBucket1, Domain: domain}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected code %v for %q, but got %v", codes.InvalidArgument, domain, err)
+		}
+	}
+	if _, err := gateway.GetBucketDNSLink(ctx, &GetBucketDNSLinkRequest{Bucket: testBucket1}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+	resp, err := gateway.SetBucketDNSLink(ctx, &SetBucketDNSLinkRequest{Bucket: testBucket1, Domain: "WWW.Example.com."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Domain != "www.example.com" {
+		t.Fatalf("expected the domain to be normalized, but got %v", resp.Domain)
+	}
+	record, err := gateway.GetBucketDNSLink(ctx, &GetBucketDNSLinkRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := gateway.GetDirectoryView(ctx, &DirectoryViewRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Name != "_dnslink.www.example.com" || record.Value != "dnslink=/ipfs/"+view.Hash {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	var (
+		mu     sync.Mutex
+		posted []map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		posted = append(posted, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	if _, err := newDNSLinkWebhook("ftp://example.com"); err == nil {
+		t.Fatal("expected an error for an unsupported webhook")
+	}
+	if gateway.dnslinkWebhook, err = newDNSLinkWebhook(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	// records are only posted when they changed
+	gateway.postDNSLinks(ctx)
+	gateway.postDNSLinks(ctx)
+	if len(posted) != 1 || posted[0]["name"] != record.Name || posted[0]["value"] != record.Value || posted[0]["type"] != "TXT" {
+		t.Fatalf("unexpected posted records %v", posted)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("dnslink")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.postDNSLinks(ctx)
+	if len(posted) != 2 || posted[1]["value"] == record.Value {
+		t.Fatalf("expected the changed record to be posted, but got %v", posted)
+	}
+}
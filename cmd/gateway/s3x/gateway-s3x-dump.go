@@ -0,0 +1,107 @@
+package s3x
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+The ledger state of a bucket is spread over the bucket DAG on IPFS, the object DAGs, and the multipart records of
+the datastore, so debugging a bucket, or auditing it against an external system, needs all of them. DumpLedger
+returns the ledger entries of a bucket in pages of object names, with the hashes of every object and its data, and
+the noncurrent versions of each object. The multipart uploads in progress are dumped with the first page.
+
+Each object of a page is loaded from IPFS for its data hash, under the read lock of the bucket, so pages hold at most
+the ledger batch size of objects, and writes to the bucket wait for one page at a time. Pages are not a snapshot,
+objects written between pages may be missing from a dump.
+
+The same dump is served as newline delimited json at ledgerDumpPath, with one record per line for the bucket, each
+object, and each multipart upload, for tools that process dumps of any size as a stream. A dump that fails after it
+started ends with an error record, since the http status was already sent.
+*/
+
+// ledgerDumpPath serves the ledger dump of a bucket as newline delimited json
+const ledgerDumpPath = "/ledger/dump/ndjson"
+
+// DumpLedger returns a page of the ledger entries of a bucket, for debugging and audits
+func (x *xObjects) DumpLedger(ctx context.Context, req *LedgerDumpRequest) (*LedgerDump, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetMax() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max can not be negative")
+	}
+	dump, err := x.ledgerStore.DumpBucket(ctx, req.GetBucket(), req.GetAfter(), int(req.GetMax()), x.clock.Now())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return dump, nil
+}
+
+// ledgerDumpRecord is a line of a newline delimited json ledger dump
+type ledgerDumpRecord struct {
+	// Record is bucket, object, multipart, or error
+	Record    string            `json:"record"`
+	Bucket    string            `json:"bucket"`
+	Hash      string            `json:"hash,omitempty"`
+	Created   int64             `json:"created,omitempty"`
+	Objects   int64             `json:"objects,omitempty"`
+	Object    *LedgerDumpObject `json:"object,omitempty"`
+	Multipart *MultipartSession `json:"multipart,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// ServeLedgerDump writes every page of the ledger dump of the bucket parameter as newline delimited json
+func (x *xObjects) ServeLedgerDump(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bucket := r.URL.Query().Get("bucket")
+	dump, err := x.DumpLedger(r.Context(), &LedgerDumpRequest{Bucket: bucket})
+	if err != nil {
+		http.Error(w, status.Convert(err).Message(), runtime.HTTPStatusFromCode(status.Code(err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&ledgerDumpRecord{
+		Record:  "bucket",
+		Bucket:  bucket,
+		Hash:    dump.GetHash(),
+		Created: dump.GetCreated(),
+		Objects: dump.GetObjects(),
+	}); err != nil {
+		return
+	}
+	for _, m := range dump.GetMultipart() {
+		if err := enc.Encode(&ledgerDumpRecord{Record: "multipart", Bucket: bucket, Multipart: m}); err != nil {
+			return
+		}
+	}
+	for {
+		for _, e := range dump.GetEntries() {
+			if err := enc.Encode(&ledgerDumpRecord{Record: "object", Bucket: bucket, Object: e}); err != nil {
+				return
+			}
+		}
+		if dump.GetNext() == "" {
+			return
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if dump, err = x.DumpLedger(r.Context(), &LedgerDumpRequest{Bucket: bucket, After: dump.GetNext()}); err != nil {
+			_ = enc.Encode(&ledgerDumpRecord{Record: "error", Bucket: bucket, Error: status.Convert(err).Message()})
+			return
+		}
+	}
+}
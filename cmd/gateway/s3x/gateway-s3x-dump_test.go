@@ -0,0 +1,122 @@
+package s3x
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_DumpLedger(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.ledgerStore.batchSize = 2
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+		Bucket: testBucket1, Versioning: VersioningConfig{Enabled: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	objects := []string{"a", "b", "c"}
+	for _, object := range append(objects, "a") {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, "multipart", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		first, err := gateway.DumpLedger(ctx, &LedgerDumpRequest{Bucket: testBucket1, Max: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bucketHash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Hash != bucketHash || first.Objects != int64(len(objects)) || first.Next != "b" {
+			t.Fatalf("unexpected dump %+v", first)
+		}
+		if len(first.Entries) != 2 || first.Entries[0].Name != "a" || first.Entries[1].Name != "b" {
+			t.Fatalf("expected the first two objects, but got %+v", first.Entries)
+		}
+		a := first.Entries[0]
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.DataHash != dataHash || a.Size_ != 1 || len(a.Versions) != 1 || a.Versions[0].ObjectHash == a.ObjectHash {
+			t.Fatalf("unexpected entry %+v", a)
+		}
+		if len(first.Multipart) != 1 || first.Multipart[0].UploadID != uploadID {
+			t.Fatalf("expected the multipart upload %v, but got %+v", uploadID, first.Multipart)
+		}
+		second, err := gateway.DumpLedger(ctx, &LedgerDumpRequest{Bucket: testBucket1, After: first.Next})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(second.Entries) != 1 || second.Entries[0].Name != "c" || second.Next != "" || len(second.Multipart) != 0 {
+			t.Fatalf("unexpected last page %+v", second)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []*LedgerDumpRequest{{}, {Bucket: testBucket1, Max: -1}} {
+			if _, err := gateway.DumpLedger(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+			}
+		}
+		if _, err := gateway.DumpLedger(ctx, &LedgerDumpRequest{Bucket: testBucket2}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+	})
+	t.Run("NDJSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		gateway.ServeLedgerDump(w, httptest.NewRequest(http.MethodGet, ledgerDumpPath+"?bucket="+testBucket1, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %v, but got %v: %s", http.StatusOK, w.Code, w.Body)
+		}
+		var records []string
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var r ledgerDumpRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+			name := r.Record
+			if r.Object != nil {
+				name += ":" + r.Object.Name
+			}
+			records = append(records, name)
+		}
+		expected := []string{"bucket", "multipart", "object:a", "object:b", "object:c"}
+		if len(records) != len(expected) {
+			t.Fatalf("expected records %v, but got %v", expected, records)
+		}
+		for i := range expected {
+			if records[i] != expected[i] {
+				t.Fatalf("expected records %v, but got %v", expected, records)
+			}
+		}
+		w = httptest.NewRecorder()
+		gateway.ServeLedgerDump(w, httptest.NewRequest(http.MethodGet, ledgerDumpPath+"?bucket="+testBucket2, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status %v, but got %v", http.StatusNotFound, w.Code)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package s3x
+
+import (
+	"context"
+	"net/http"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+The default encryption of a bucket is kept in its config. The S3 handlers encrypt uploads into a bucket
+with a default encryption using SSE-S3, with the object keys generated by the KMS master key of an
+aws:kms default, so s3x only stores encrypted data. The sealed object keys are part of the object metadata
+that s3x keeps with the objects and multipart uploads, and are needed to decrypt them on reads.
+
+The extension apis that link the data of existing objects into new objects, compose and append, can not decrypt
+or encrypt data, so they refuse encrypted sources, whose ciphertext would be served as plaintext, and buckets with
+a default encryption, whose objects must be encrypted.
+*/
+
+// encryptionMetadataKeys are the object metadata keys of the sealed keys of encrypted objects
+var encryptionMetadataKeys = []string{
+	crypto.SSEMultipart,
+	crypto.SSEIV,
+	crypto.SSESealAlgorithm,
+	crypto.SSECSealedKey,
+	crypto.S3SealedKey,
+	crypto.S3KMSKeyID,
+	crypto.S3KMSSealedKey,
+}
+
+// isEncryptionMetadataKey returns true for the object metadata keys needed to decrypt encrypted objects
+func isEncryptionMetadataKey(k string) bool {
+	k = http.CanonicalHeaderKey(k)
+	for _, key := range encryptionMetadataKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEncryptionMetadata returns true if the metadata of an object has the sealed keys of encrypted data
+func hasEncryptionMetadata(userDefined map[string]string) bool {
+	for k := range userDefined {
+		if isEncryptionMetadataKey(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// assertNoDefaultEncryption returns a FailedPrecondition error if a bucket encrypts new objects by default, for
+// the extension apis that write objects from existing data without the S3 handlers, which can not encrypt it
+func (x *xObjects) assertNoDefaultEncryption(ctx context.Context, bucket, operation string) error {
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return toGrpcErr(err)
+	}
+	if c.GetEncryption() != nil {
+		return status.Errorf(codes.FailedPrecondition, "bucket %q encrypts new objects by default, objects can not be %s in it", bucket, operation)
+	}
+	return nil
+}
+
+// encryptionConfig returns the stored form of a bucket encryption configuration, nil if config is nil
+func encryptionConfig(config *bucketsse.BucketSSEConfig) *EncryptionConfig {
+	if config == nil || len(config.Rules) == 0 {
+		return nil
+	}
+	action := config.Rules[0].DefaultEncryptionAction
+	return &EncryptionConfig{
+		Algorithm:      string(action.Algorithm),
+		KmsMasterKeyID: action.MasterKeyID,
+	}
+}
+
+// SetBucketSSEConfig sets the default encryption of new objects in a bucket
+func (x *xObjects) SetBucketSSEConfig(ctx context.Context, bucket string, config *bucketsse.BucketSSEConfig) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		c.Encryption = encryptionConfig(config)
+		return nil
+	}), bucket, "", "")
+}
+
+// GetBucketSSEConfig returns the default encryption of new objects in a bucket
+func (x *xObjects) GetBucketSSEConfig(ctx context.Context, bucket string) (*bucketsse.BucketSSEConfig, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	if c.GetEncryption() == nil {
+		return nil, minio.BucketSSEConfigNotFound{Bucket: bucket}
+	}
+	return &bucketsse.BucketSSEConfig{
+		XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Rules: []bucketsse.SSERule{{
+			DefaultEncryptionAction: bucketsse.EncryptionAction{
+				Algorithm:   bucketsse.SSEAlgorithm(c.GetEncryption().GetAlgorithm()),
+				MasterKeyID: c.GetEncryption().GetKmsMasterKeyID(),
+			},
+		}},
+	}, nil
+}
+
+// DeleteBucketSSEConfig removes the default encryption of new objects in a bucket
+func (x *xObjects) DeleteBucketSSEConfig(ctx context.Context, bucket string) error {
+	return x.SetBucketSSEConfig(ctx, bucket, nil)
+}
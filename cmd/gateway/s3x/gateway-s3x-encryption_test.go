@@ -0,0 +1,128 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_EncryptionMetadata_Badger(t *testing.T) {
+	testS3XEncryptionMetadata(t, DSTypeBadger)
+}
+func TestS3X_EncryptionMetadata_Crdt(t *testing.T) {
+	testS3XEncryptionMetadata(t, DSTypeCrdt)
+}
+func testS3XEncryptionMetadata(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	metadata := map[string]string{
+		crypto.SSEIV:             "iv",
+		crypto.SSESealAlgorithm:  "DARE-SHA256",
+		crypto.S3SealedKey:       "sealed-key",
+		crypto.S3KMSKeyID:        "bucket-key",
+		crypto.S3KMSSealedKey:    "kms-sealed-key",
+		"X-Minio-Internal-Other": "dropped",
+	}
+	checkMetadata := func(t *testing.T, userDefined map[string]string) {
+		t.Helper()
+		for k, v := range metadata {
+			if !isEncryptionMetadataKey(k) {
+				if _, ok := userDefined[k]; ok {
+					t.Fatalf("expected %v not to be kept", k)
+				}
+				continue
+			}
+			if userDefined[k] != v {
+				t.Fatalf("expected %v to be %v, but got %v", k, v, userDefined[k])
+			}
+		}
+	}
+	t.Run("PutObject", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("encrypted data")), minio.ObjectOptions{
+			UserDefined: metadata,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMetadata(t, info.UserDefined)
+	})
+	t.Run("Multipart", func(t *testing.T) {
+		userDefined := map[string]string{crypto.SSEMultipart: ""}
+		for k, v := range metadata {
+			userDefined[k] = v
+		}
+		uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{UserDefined: userDefined})
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, 1, getTestPutObjectReader(t, []byte("encrypted part")), minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lpi, err := gateway.ListObjectParts(ctx, testBucket1, testObject1, uploadID, 0, 10, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !crypto.IsMultiPart(lpi.UserDefined) {
+			t.Fatalf("expected the upload to be an encrypted multipart upload, but got %v", lpi.UserDefined)
+		}
+		checkMetadata(t, lpi.UserDefined)
+		if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uploadID, []minio.CompletePart{
+			{PartNumber: 1, ETag: part.ETag},
+		}, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMetadata(t, info.UserDefined)
+		want := minio.ObjectPartInfo{Number: 1, ETag: part.ETag, Size: 14, ActualSize: 14}
+		if len(info.Parts) != 1 || info.Parts[0] != want {
+			t.Fatalf("expected parts %+v, but got %+v", []minio.ObjectPartInfo{want}, info.Parts)
+		}
+	})
+}
+
+func TestS3X_ComposeAppendEncrypted(t *testing.T) {
+	ctx := context.Background()
+	ls, _, _ := newFaultyLedger(t)
+	x := &xObjects{ledgerStore: ls, clock: testClock{}}
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := testLedgerObject(testBucket1, testObject1, "ciphertext")
+	encrypted.ObjectInfo.UserDefined = map[string]string{crypto.S3SealedKey: "sealed-key"}
+	if err := ls.PutObject(ctx, testBucket1, testObject1, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	// the ciphertext of the source would be served as the plaintext of the composed object
+	if _, err := x.ComposeObject(ctx, &ComposeObjectRequest{Bucket: testBucket1, Object: "composed", Sources: []string{testObject1}}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected an encrypted source to be refused, but got %v", err)
+	}
+	if _, err := ls.CreateBucket(ctx, testBucket2, &Bucket{Config: &BucketConfig{Encryption: &EncryptionConfig{Algorithm: "AES256"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.ComposeObject(ctx, &ComposeObjectRequest{Bucket: testBucket2, Object: "composed", Sources: []string{testObject1}}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected a bucket with a default encryption to be refused, but got %v", err)
+	}
+	// appended data is linked without being encrypted
+	if _, err := x.AppendObject(ctx, &AppendObjectRequest{Bucket: testBucket2, Object: "appended", Data: []byte("a"), Create: true}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected appending to a bucket with a default encryption to be refused, but got %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/klauspost/reedsolomon"
+)
+
+/* Design Notes
+---------------
+
+When several TemporalX endpoints are configured for erasure coding, PutObject splits object data into
+reed-solomon data and parity shards, and stores each shard as a file on a different node.
+Objects can be read as long as no more than parityShards nodes are lost, at a storage cost of
+(data+parity)/data instead of a full copy per node.
+
+Only PutObject erasure codes data, objects created by multipart uploads, compose or append are stored
+on the primary TemporalX node. The shard files are not reference counted.
+*/
+
+// erasureChunkSize is the number of bytes each shard holds per stripe
+const erasureChunkSize = 1024 * 1024
+
+// errErasureNotConfigured is returned when reading erasure coded data without configured erasure nodes
+var errErasureNotConfigured = errors.New("object is erasure coded, but no erasure endpoints are configured")
+
+// erasureNode is a TemporalX node that stores shards
+type erasureNode struct {
+	addr   string
+	client pb.FileAPIClient
+}
+
+// erasureStore erasure codes object data across TemporalX nodes
+type erasureStore struct {
+	nodes     []erasureNode
+	data      int
+	parity    int
+	chunkSize int
+	enc       reedsolomon.Encoder
+}
+
+// newErasureStore returns an erasureStore that stores one shard on each node,
+// so the number of data shards is the number of nodes minus parity.
+func newErasureStore(addrs []string, clients []pb.FileAPIClient, parity int) (*erasureStore, error) {
+	if len(addrs) != len(clients) {
+		return nil, errors.New("number of erasure addresses and clients must match")
+	}
+	if parity < 1 || parity >= len(addrs) {
+		return nil, fmt.Errorf("erasure coding needs between 1 and %v parity shards for %v nodes, but got %v", len(addrs)-1, len(addrs), parity)
+	}
+	enc, err := reedsolomon.New(len(addrs)-parity, parity)
+	if err != nil {
+		return nil, err
+	}
+	es := &erasureStore{
+		data:      len(addrs) - parity,
+		parity:    parity,
+		chunkSize: erasureChunkSize,
+		enc:       enc,
+	}
+	for i, addr := range addrs {
+		es.nodes = append(es.nodes, erasureNode{addr: addr, client: clients[i]})
+	}
+	return es, nil
+}
+
+type uploadResult struct {
+	hash string
+	err  error
+}
+
+// upload erasure codes all data of r and stores the shards on the nodes
+func (es *erasureStore) upload(ctx context.Context, r io.Reader) (*ErasureInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	writers := make([]*io.PipeWriter, len(es.nodes))
+	results := make([]chan uploadResult, len(es.nodes))
+	for i, node := range es.nodes {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		results[i] = make(chan uploadResult, 1)
+		go func(client pb.FileAPIClient, pr *io.PipeReader, result chan<- uploadResult) {
+			hash, _, err := ipfsFileUpload(ctx, client, pr)
+			_ = pr.CloseWithError(err) // unblock writes if the upload failed
+			result <- uploadResult{hash: hash, err: err}
+		}(node.client, pr, results[i])
+	}
+	size, err := es.encode(r, writers)
+	for _, w := range writers {
+		_ = w.CloseWithError(err)
+	}
+	info := &ErasureInfo{
+		DataShards:   int64(es.data),
+		ParityShards: int64(es.parity),
+		ChunkSize:    int64(es.chunkSize),
+		Size_:        size,
+	}
+	for i, result := range results {
+		res := <-result
+		if err == nil && res.err != nil {
+			err = fmt.Errorf("failed to store shard %v on %v: %v", i, es.nodes[i].addr, res.err)
+		}
+		info.ShardHashes = append(info.ShardHashes, res.hash)
+		info.ShardNodes = append(info.ShardNodes, es.nodes[i].addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// encode splits r into stripes, and writes the shards of each stripe to writers
+func (es *erasureStore) encode(r io.Reader, writers []*io.PipeWriter) (int64, error) {
+	var size int64
+	buf := make([]byte, es.data*es.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return size, err
+		}
+		size += int64(n)
+		shards, serr := es.enc.Split(buf[:n])
+		if serr != nil {
+			return size, serr
+		}
+		if serr := es.enc.Encode(shards); serr != nil {
+			return size, serr
+		}
+		for i, shard := range shards {
+			if _, werr := writers[i].Write(shard); werr != nil {
+				return size, werr
+			}
+		}
+		if err == io.ErrUnexpectedEOF {
+			return size, nil
+		}
+	}
+}
+
+// client returns the client of the node that stores a shard, or nil if the node is not configured anymore
+func (es *erasureStore) client(info *ErasureInfo, shard int) pb.FileAPIClient {
+	addr := info.GetShardNodes()[shard]
+	if shard < len(es.nodes) && es.nodes[shard].addr == addr {
+		return es.nodes[shard].client
+	}
+	for _, node := range es.nodes {
+		if node.addr == addr {
+			return node.client
+		}
+	}
+	return nil
+}
+
+// download writes the data of an erasure coded object to w, starting at startOffset for length bytes,
+// or until the end if both are 0. Missing shards are reconstructed from the parity shards.
+func (es *erasureStore) download(ctx context.Context, w io.Writer, info *ErasureInfo, startOffset, length int64) (int64, error) {
+	var (
+		data       = int(info.GetDataShards())
+		total      = data + int(info.GetParityShards())
+		chunk      = info.GetChunkSize()
+		stripeSize = int64(data) * chunk
+		size       = info.GetSize_()
+	)
+	if len(info.GetShardHashes()) != total || len(info.GetShardNodes()) != total || chunk <= 0 {
+		return 0, errors.New("invalid erasure info")
+	}
+	enc, err := reedsolomon.New(data, total-data)
+	if err != nil {
+		return 0, err
+	}
+	if startOffset == 0 && length == 0 {
+		length = size
+	}
+	if startOffset+length > size {
+		return 0, fmt.Errorf("range %v-%v exceeds data size %v", startOffset, startOffset+length, size)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	firstStripe := startOffset / stripeSize
+	readers := make([]*io.PipeReader, total)
+	for i := range readers {
+		client := es.client(info, i)
+		if client == nil {
+			continue
+		}
+		offset := firstStripe * chunk
+		shardSize := shardFileSize(size, data, chunk)
+		if offset >= shardSize {
+			continue
+		}
+		pr, pw := io.Pipe()
+		readers[i] = pr
+		go func(client pb.FileAPIClient, hash string) {
+			_, err := ipfsFileDownload(ctx, client, pw, hash, offset, shardSize-offset)
+			_ = pw.CloseWithError(err)
+		}(client, info.GetShardHashes()[i])
+	}
+	defer func() {
+		for _, r := range readers {
+			if r != nil {
+				_ = r.Close()
+			}
+		}
+	}()
+	var written int64
+	skip := startOffset - firstStripe*stripeSize
+	for stripe := firstStripe; written < length; stripe++ {
+		stripeData := size - stripe*stripeSize
+		if stripeData > stripeSize {
+			stripeData = stripeSize
+		}
+		shardLen := (stripeData + int64(data) - 1) / int64(data)
+		shards := make([][]byte, total)
+		missing := 0
+		for i, r := range readers {
+			if r == nil {
+				missing++
+				continue
+			}
+			shard := make([]byte, shardLen)
+			if _, err := io.ReadFull(r, shard); err != nil {
+				// a failed node is not read again for the following stripes
+				_ = r.Close()
+				readers[i] = nil
+				missing++
+				continue
+			}
+			shards[i] = shard
+		}
+		if missing > total-data {
+			return written, fmt.Errorf("%v of %v shards are unavailable, at most %v can be lost", missing, total, total-data)
+		}
+		if missing > 0 {
+			if err := enc.ReconstructData(shards); err != nil {
+				return written, err
+			}
+		}
+		out := make([]byte, 0, stripeData)
+		for _, shard := range shards[:data] {
+			out = append(out, shard...)
+		}
+		out = out[skip:stripeData]
+		skip = 0
+		if remaining := length - written; int64(len(out)) > remaining {
+			out = out[:remaining]
+		}
+		n, err := w.Write(out)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// shardFileSize returns the size of each shard file for data of the given size
+func shardFileSize(size int64, data int, chunk int64) int64 {
+	stripeSize := int64(data) * chunk
+	full := size / stripeSize
+	rest := size % stripeSize
+	return full*chunk + (rest+int64(data)-1)/int64(data)
+}
+
+// erasureDecompressedDownload is erasureStore.download for compressed data, startOffset and length apply to the decompressed data
+func (x *xObjects) erasureDecompressedDownload(ctx context.Context, w io.Writer, info *ErasureInfo, compression string, startOffset, length int64) (int64, error) {
+	if x.erasure == nil {
+		return 0, errErasureNotConfigured
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := x.erasure.download(ctx, pw, info, 0, 0)
+		_ = pw.CloseWithError(err)
+	}()
+	return decompressCopy(w, pr, compression, startOffset, length)
+}
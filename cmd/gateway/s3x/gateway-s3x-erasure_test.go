@@ -0,0 +1,121 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+)
+
+// lostFileClient simulates a lost TemporalX node
+type lostFileClient struct {
+	pb.FileAPIClient
+}
+
+func (lostFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	return nil, errors.New("node lost")
+}
+
+func TestS3X_Erasure_Badger(t *testing.T) {
+	testS3XErasure(t, DSTypeBadger)
+}
+func TestS3X_Erasure_Crdt(t *testing.T) {
+	testS3XErasure(t, DSTypeCrdt)
+}
+func testS3XErasure(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	addrs := []string{"node0", "node1", "node2", "node3"}
+	clients := []pb.FileAPIClient{gateway.fileClient, gateway.fileClient, gateway.fileClient, gateway.fileClient}
+	if _, err := newErasureStore(addrs, clients, len(addrs)); err == nil {
+		t.Fatal("expected an error for as many parity shards as nodes")
+	}
+	es, err := newErasureStore(addrs, clients, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es.chunkSize = 16 // use many stripes
+	gateway.erasure = es
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Erasure == nil || len(obj.Erasure.GetShardHashes()) != len(addrs) || obj.ObjectInfo.GetSize_() != int64(len(data)) {
+		t.Fatalf("expected an erasure coded object of %v bytes, but got %v", len(data), obj)
+	}
+	tests := []struct {
+		name                string
+		lost                int
+		startOffset, length int64
+		wantErr             bool
+	}{
+		{"All-Nodes", 0, 0, 0, false},
+		{"Range", 0, 100, 300, false},
+		{"Range-Last-Stripe", 0, 990, 10, false},
+		{"Lost-Parity-Limit", 2, 0, 0, false},
+		{"Lost-Parity-Limit-Range", 2, 33, 500, false},
+		{"Lost-Too-Many", 3, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range es.nodes {
+				es.nodes[i].client = gateway.fileClient
+				if i < tt.lost {
+					es.nodes[i].client = lostFileClient{}
+				}
+			}
+			buf := bytes.NewBuffer(nil)
+			err := gateway.GetObject(ctx, testBucket1, testObject1, tt.startOffset, tt.length, buf, "", minio.ObjectOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetObject() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := data
+			if tt.length != 0 {
+				want = data[tt.startOffset : tt.startOffset+tt.length]
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("expected %v bytes of data, but got %v different bytes", len(want), buf.Len())
+			}
+		})
+	}
+	t.Run("Compressed", func(t *testing.T) {
+		for i := range es.nodes {
+			es.nodes[i].client = gateway.fileClient
+		}
+		if _, err := gateway.SetBucketCompression(ctx, &SetBucketCompressionRequest{Bucket: testBucket1, Compression: compressionZstd}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, "compressed", getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, "compressed", 10, 20, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), data[10:30]) {
+			t.Fatal("decompressed erasure coded data does not match")
+		}
+	})
+}
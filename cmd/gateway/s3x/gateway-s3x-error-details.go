@@ -0,0 +1,84 @@
+package s3x
+
+import (
+	"context"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+S3 errors only tell clients what failed, a SlowDown does not tell whether TemporalX is unreachable or the ledger
+datastore is failing. Gateways started with --debug.errors return the chain of errors behind a failed request to
+clients that send the x-s3x-debug-errors header, as a JSON array in the x-s3x-error-details response header, from
+the outermost error to the root cause, each with its source: ledger, datastore, temporalx for gRPC statuses of
+TemporalX with their status code, context for canceled and timed out requests, or gateway for anything else.
+
+The details are set where errors are converted to S3 errors, so every object layer error that reaches a client
+carries them. They are set in the response headers as soon as the error is converted, so a request that recovers
+from an error can return its details with a successful response. The messages of the errors can carry the
+addresses of the backends of the gateway, which is why they are only returned when the gateway opts in.
+*/
+
+// ledgerErrors are the errors of the ledger
+var ledgerErrors = []error{
+	ErrLedgerBucketExists, ErrLedgerBucketDoesNotExist, ErrLedgerObjectDoesNotExist, ErrLedgerObjectExists,
+	ErrLedgerNonEmptyBucket, ErrInvalidUploadID, ErrInvalidPartNumber, ErrLedgerSnapshotDoesNotExist,
+	ErrLedgerVersionDoesNotExist, ErrLedgerObjectRetained, ErrWORMRetentionShortened, ErrImportDoesNotExist,
+	ErrUploadGrantDoesNotExist, ErrUploadGrantUsed, ErrLedgerNilBucket, ErrLedgerCacheChanged, ErrLedgerRootNotSaved,
+	ErrLedgerNotEmpty, ErrLedgerStandby, ErrInvalidBucketName, ErrInvalidObjectName, ErrObjectNameTooLong,
+	ErrObjectTooLarge, ErrPartTooLarge, ErrMetadataTooLarge, ErrInsufficientCapacity, ErrUploadOffsetMismatch,
+	ErrInvalidPinMode,
+}
+
+// datastoreErrors are the errors of the ledger datastore
+var datastoreErrors = []error{datastore.ErrNotFound, datastore.ErrBatchUnsupported}
+
+// errorDetails returns the chain of errors of err, from the outermost error to the root cause,
+// wrappers that do not add to the message of the error they wrap are skipped
+func errorDetails(err error) []minio.ErrorDetail {
+	var details []minio.ErrorDetail
+	for err != nil {
+		if len(details) == 0 || details[len(details)-1].Message != err.Error() {
+			details = append(details, errorDetail(err))
+		}
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	return details
+}
+
+// errorDetail returns the source of err, ignoring the errors it wraps
+func errorDetail(err error) minio.ErrorDetail {
+	d := minio.ErrorDetail{Source: "gateway", Message: err.Error()}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		d.Source, d.Code = "temporalx", status.Code(err).String()
+		return d
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		d.Source = "context"
+		return d
+	}
+	for _, e := range datastoreErrors {
+		if err == e {
+			d.Source = "datastore"
+			return d
+		}
+	}
+	for _, e := range ledgerErrors {
+		if err == e {
+			d.Source = "ledger"
+			return d
+		}
+	}
+	return d
+}
@@ -0,0 +1,50 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	pkgerrors "github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_ErrorDetails(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want []minio.ErrorDetail
+	}{
+		{"Nil", nil, nil},
+		{"Ledger", ErrLedgerObjectDoesNotExist, []minio.ErrorDetail{
+			{Source: "ledger", Message: ErrLedgerObjectDoesNotExist.Error()},
+		}},
+		{"WrappedDatastore", fmt.Errorf("loading bucket: %w", datastore.ErrNotFound), []minio.ErrorDetail{
+			{Source: "gateway", Message: "loading bucket: " + datastore.ErrNotFound.Error()},
+			{Source: "datastore", Message: datastore.ErrNotFound.Error()},
+		}},
+		{"WrappedTemporalX", pkgerrors.Wrap(unavailable, "dag client error"), []minio.ErrorDetail{
+			{Source: "gateway", Message: "dag client error: " + unavailable.Error()},
+			{Source: "temporalx", Code: "Unavailable", Message: unavailable.Error()},
+		}},
+		{"Context", context.DeadlineExceeded, []minio.ErrorDetail{
+			{Source: "context", Message: context.DeadlineExceeded.Error()},
+		}},
+		{"Unknown", errors.New("unknown"), []minio.ErrorDetail{
+			{Source: "gateway", Message: "unknown"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorDetails(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, but got %+v", tt.want, got)
+			}
+		})
+	}
+}
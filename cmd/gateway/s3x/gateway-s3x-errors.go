@@ -1,9 +1,13 @@
 package s3x
 
 import (
+	"context"
 	"errors"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -16,6 +20,9 @@ var (
 	// ErrLedgerObjectDoesNotExist is an error message returned from the internal
 	// ledgerStore indicating that a object does not exist
 	ErrLedgerObjectDoesNotExist = errors.New("object does not exist")
+	// ErrLedgerObjectExists is an error message returned from the internal
+	// ledgerStore indicating that a object already exists
+	ErrLedgerObjectExists = errors.New("object exists")
 	// ErrLedgerNonEmptyBucket is an error message returned from the internal
 	// ledgerStore indicating that a bucket is not empty
 	ErrLedgerNonEmptyBucket = errors.New("bucket is not empty")
@@ -23,26 +30,243 @@ var (
 	// does not exist
 	ErrInvalidUploadID = errors.New("invalid multipart upload id")
 	// ErrInvalidPartNumber is an error message returned when the multipart part
-	// number is out of range (not mappable to a minio error type)
+	// number is out of range, or an upload has too many parts
 	ErrInvalidPartNumber = errors.New("invalid multipart part number")
+	// ErrLedgerSnapshotDoesNotExist is an error message returned from the internal
+	// ledgerStore indicating that a bucket snapshot does not exist
+	ErrLedgerSnapshotDoesNotExist = errors.New("snapshot does not exist")
+	// ErrLedgerVersionDoesNotExist is an error message returned from the internal
+	// ledgerStore indicating that a noncurrent object version does not exist
+	ErrLedgerVersionDoesNotExist = errors.New("object version does not exist")
+	// ErrLedgerObjectRetained is an error message returned from the internal
+	// ledgerStore indicating that an object is retained by the WORM retention of its bucket
+	ErrLedgerObjectRetained = errors.New("object is retained by the WORM retention of its bucket")
+	// ErrWORMRetentionShortened is an error message returned from the internal
+	// ledgerStore indicating that the WORM retention of a bucket can not be shortened
+	ErrWORMRetentionShortened = errors.New("WORM retention can only be extended")
+	// ErrImportDoesNotExist is an error message returned from the internal
+	// ledgerStore indicating that an import does not exist
+	ErrImportDoesNotExist = errors.New("import does not exist")
+	// ErrUploadGrantDoesNotExist is an error message returned from the internal
+	// ledgerStore indicating that an upload grant does not exist
+	ErrUploadGrantDoesNotExist = errors.New("upload grant does not exist")
+	// ErrUploadGrantUsed is an error message returned from the internal
+	// ledgerStore indicating that an upload grant was already used
+	ErrUploadGrantUsed = errors.New("upload grant was already used")
+	// ErrLedgerNilBucket is an error message returned from the internal
+	// ledgerStore when a bucket is created without bucket data
+	ErrLedgerNilBucket = errors.New("can not create nil bucket")
+	// ErrLedgerCacheChanged is an error message returned from the internal
+	// ledgerStore when a cached bucket changed while it was loaded, this should never happen
+	ErrLedgerCacheChanged = errors.New("bucket cache state changed unexpectedly")
+	// ErrLedgerRootNotSaved is an error message returned from the internal
+	// ledgerStore when the root of the ledger was never saved to IPFS
+	ErrLedgerRootNotSaved = errors.New("ledger root was not saved")
+	// ErrLedgerNotEmpty is an error message returned from the internal
+	// ledgerStore when a ledger that already has buckets is recovered
+	ErrLedgerNotEmpty = errors.New("ledger is not empty")
+	// ErrLedgerStandby is an error message returned from the internal
+	// ledgerStore when a bucket is created on a standby gateway that was not promoted
+	ErrLedgerStandby = errors.New("gateway is a standby")
+	// ErrInvalidBucketName is an error message returned when a bucket name
+	// does not satisfy the name validation of the gateway
+	ErrInvalidBucketName = errors.New("invalid bucket name")
+	// ErrInvalidObjectName is an error message returned when an object key
+	// does not satisfy the name validation of the gateway
+	ErrInvalidObjectName = errors.New("invalid object name")
+	// ErrObjectNameTooLong is an error message returned when an object key
+	// is longer than 1024 bytes
+	ErrObjectNameTooLong = errors.New("object name too long")
+	// ErrObjectTooLarge is an error message returned when an object exceeds
+	// the maximum object size of the gateway
+	ErrObjectTooLarge = errors.New("object too large")
+	// ErrPartTooLarge is an error message returned when a multipart part exceeds
+	// the maximum part size of the gateway
+	ErrPartTooLarge = errors.New("multipart part too large")
+	// ErrMetadataTooLarge is an error message returned when the user defined metadata
+	// of an object exceeds the maximum metadata size of the gateway
+	ErrMetadataTooLarge = errors.New("metadata too large")
+	// ErrInsufficientCapacity is an error message returned when an upload
+	// would exceed the storage capacity of TemporalX
+	ErrInsufficientCapacity = errors.New("insufficient storage capacity")
+	// ErrUploadOffsetMismatch is an error message returned when a chunk of an upload session
+	// does not start at the number of bytes the session received
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match the received data")
+	// ErrInvalidPinMode is an error message returned when an upload selects an unknown pin mode
+	ErrInvalidPinMode = errors.New("invalid pin mode")
+	// ErrInvalidIdempotencyKey is an error message returned when an upload has an idempotency key that is too
+	// long or not printable
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	// ErrIdempotencyKeyReused is an error message returned when an upload reuses the idempotency key of an
+	// upload of other data to the same object
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for other data")
 )
 
-// toMinioErr converts gRPC or ledger errors into compatible minio errors
-// or if no error is present return nil
-func (x *xObjects) toMinioErr(err error, bucket, object, id string) error {
-	switch err {
-	case ErrLedgerBucketDoesNotExist:
-		err = minio.BucketNotFound{Bucket: bucket}
-	case ErrLedgerObjectDoesNotExist:
-		err = minio.ObjectNotFound{Bucket: bucket, Object: object}
-	case ErrLedgerBucketExists:
-		err = minio.BucketAlreadyExists{Bucket: bucket}
-	case ErrInvalidUploadID:
-		err = minio.InvalidUploadID{Bucket: bucket, Object: object, UploadID: id}
-	case ErrLedgerNonEmptyBucket:
-		err = minio.BucketNotEmpty{Bucket: bucket}
-	case nil:
+// minioErrors maps ledger and datastore errors, also when wrapped, to minio errors
+// for the bucket, object, and upload or version id of a request.
+var minioErrors = []struct {
+	err   error
+	toErr func(bucket, object, id string) error
+}{
+	{ErrLedgerBucketDoesNotExist, func(bucket, object, id string) error {
+		return minio.BucketNotFound{Bucket: bucket}
+	}},
+	{ErrLedgerObjectDoesNotExist, func(bucket, object, id string) error {
+		return minio.ObjectNotFound{Bucket: bucket, Object: object}
+	}},
+	{ErrLedgerVersionDoesNotExist, func(bucket, object, id string) error {
+		return minio.VersionNotFound{Bucket: bucket, Object: object, VersionID: id}
+	}},
+	{ErrLedgerObjectExists, func(bucket, object, id string) error {
+		return minio.ObjectAlreadyExists{Bucket: bucket, Object: object}
+	}},
+	{ErrLedgerBucketExists, func(bucket, object, id string) error {
+		return minio.BucketAlreadyExists{Bucket: bucket}
+	}},
+	{ErrInvalidUploadID, func(bucket, object, id string) error {
+		return minio.InvalidUploadID{Bucket: bucket, Object: object, UploadID: id}
+	}},
+	{ErrLedgerNonEmptyBucket, func(bucket, object, id string) error {
+		return minio.BucketNotEmpty{Bucket: bucket}
+	}},
+	{ErrLedgerObjectRetained, func(bucket, object, id string) error {
+		return minio.PrefixAccessDenied{Bucket: bucket, Object: object}
+	}},
+	{ErrInvalidBucketName, func(bucket, object, id string) error {
+		return minio.BucketNameInvalid{Bucket: bucket}
+	}},
+	{ErrInvalidObjectName, func(bucket, object, id string) error {
+		return minio.ObjectNameInvalid{Bucket: bucket, Object: object}
+	}},
+	{ErrObjectNameTooLong, func(bucket, object, id string) error {
+		return minio.ObjectNameTooLong{Bucket: bucket, Object: object}
+	}},
+	{ErrObjectTooLarge, func(bucket, object, id string) error {
+		return minio.ObjectTooLarge{Bucket: bucket, Object: object}
+	}},
+	{ErrPartTooLarge, func(bucket, object, id string) error {
+		return minio.PartTooBig{}
+	}},
+	{ErrInvalidPartNumber, func(bucket, object, id string) error {
+		return minio.InvalidPartNumber{}
+	}},
+	{ErrMetadataTooLarge, func(bucket, object, id string) error {
+		return minio.MetadataTooLarge{}
+	}},
+	{ErrInsufficientCapacity, func(bucket, object, id string) error {
+		return minio.StorageFull{}
+	}},
+	{ErrInvalidPinMode, func(bucket, object, id string) error {
+		return minio.UnsupportedMetadata{}
+	}},
+	{ErrInvalidIdempotencyKey, func(bucket, object, id string) error {
+		return minio.UnsupportedMetadata{}
+	}},
+	{ErrIdempotencyKeyReused, func(bucket, object, id string) error {
+		return minio.PreConditionFailed{}
+	}},
+	{ErrLedgerStandby, func(bucket, object, id string) error {
+		return minio.BackendDown{}
+	}},
+	{datastore.ErrNotFound, notFoundErr},
+	{context.DeadlineExceeded, func(bucket, object, id string) error {
+		return minio.OperationTimedOut{}
+	}},
+}
+
+// grpcMinioErrors maps the gRPC status codes of TemporalX errors to minio errors,
+// unavailable and exhausted backends are reported as SlowDown so S3 clients retry.
+var grpcMinioErrors = map[codes.Code]func(bucket, object, id string) error{
+	codes.NotFound: notFoundErr,
+	codes.AlreadyExists: func(bucket, object, id string) error {
+		if object == "" {
+			return minio.BucketAlreadyExists{Bucket: bucket}
+		}
+		return minio.ObjectAlreadyExists{Bucket: bucket, Object: object}
+	},
+	codes.Unavailable:       func(bucket, object, id string) error { return minio.SlowDown{} },
+	codes.ResourceExhausted: func(bucket, object, id string) error { return minio.SlowDown{} },
+	codes.DeadlineExceeded:  func(bucket, object, id string) error { return minio.OperationTimedOut{} },
+}
+
+// notFoundErr returns ObjectNotFound for object requests, and BucketNotFound otherwise
+func notFoundErr(bucket, object, id string) error {
+	if object == "" {
+		return minio.BucketNotFound{Bucket: bucket}
+	}
+	return minio.ObjectNotFound{Bucket: bucket, Object: object}
+}
+
+// rootCause returns the innermost error of wrapped errors
+func rootCause(err error) error {
+	for {
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return err
+		}
+	}
+}
+
+// toMinioErr converts gRPC, datastore, or ledger errors into compatible minio errors,
+// unknown errors are returned as is, or if no error is present return nil. The chain of
+// err is returned to requests of ctx that asked for it if the gateway returns error details.
+func (x *xObjects) toMinioErr(ctx context.Context, err error, bucket, object, id string) error {
+	if err == nil {
 		return nil
 	}
+	if x.debugErrors && minio.ErrorDetailsRequested(ctx) {
+		minio.SetErrorDetails(ctx, errorDetails(err))
+	}
+	for _, m := range minioErrors {
+		if errors.Is(err, m.err) {
+			return m.toErr(bucket, object, id)
+		}
+	}
+	if s, ok := status.FromError(rootCause(err)); ok {
+		if f, ok := grpcMinioErrors[s.Code()]; ok {
+			return f(bucket, object, id)
+		}
+	}
 	return err
 }
+
+// grpcErrors maps ledger errors to the gRPC status codes of the s3x specific APIs
+var grpcErrors = []struct {
+	code codes.Code
+	errs []error
+}{
+	{codes.NotFound, []error{ErrLedgerBucketDoesNotExist, ErrLedgerObjectDoesNotExist, ErrInvalidUploadID, ErrLedgerSnapshotDoesNotExist,
+		ErrLedgerVersionDoesNotExist, ErrLedgerRootNotSaved, ErrImportDoesNotExist, ErrUploadGrantDoesNotExist}},
+	{codes.AlreadyExists, []error{ErrLedgerBucketExists, ErrLedgerObjectExists}},
+	{codes.FailedPrecondition, []error{ErrLedgerNonEmptyBucket, ErrLedgerNotEmpty, ErrLedgerStandby, ErrUploadOffsetMismatch,
+		ErrLedgerObjectRetained, ErrWORMRetentionShortened, ErrUploadGrantUsed}},
+	{codes.InvalidArgument, []error{ErrInvalidBucketName, ErrInvalidObjectName, ErrObjectNameTooLong,
+		ErrObjectTooLarge, ErrPartTooLarge, ErrInvalidPartNumber, ErrMetadataTooLarge}},
+	{codes.ResourceExhausted, []error{ErrInsufficientCapacity}},
+}
+
+// toGrpcErr converts ledger errors, also when they are wrapped, into gRPC status errors for the s3x
+// specific APIs, gRPC status errors are returned unchanged, or if no error is present return nil
+func toGrpcErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, m := range grpcErrors {
+		for _, e := range m.errs {
+			if errors.Is(err, e) {
+				return status.Error(m.code, err.Error())
+			}
+		}
+	}
+	if s, ok := status.FromError(rootCause(err)); ok {
+		return s.Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,100 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	pkgerrors "github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_ToMinioErr(t *testing.T) {
+	x := &xObjects{}
+	unknown := errors.New("unknown")
+	tests := []struct {
+		name   string
+		err    error
+		object string
+		want   error
+	}{
+		{"Nil", nil, "", nil},
+		{"BucketDoesNotExist", ErrLedgerBucketDoesNotExist, "", minio.BucketNotFound{Bucket: testBucket1}},
+		{"ObjectDoesNotExist", ErrLedgerObjectDoesNotExist, testObject1, minio.ObjectNotFound{Bucket: testBucket1, Object: testObject1}},
+		{"VersionDoesNotExist", ErrLedgerVersionDoesNotExist, testObject1, minio.VersionNotFound{Bucket: testBucket1, Object: testObject1, VersionID: "id"}},
+		{"ObjectExists", ErrLedgerObjectExists, testObject1, minio.ObjectAlreadyExists{Bucket: testBucket1, Object: testObject1}},
+		{"BucketExists", ErrLedgerBucketExists, "", minio.BucketAlreadyExists{Bucket: testBucket1}},
+		{"InvalidUploadID", ErrInvalidUploadID, testObject1, minio.InvalidUploadID{Bucket: testBucket1, Object: testObject1, UploadID: "id"}},
+		{"NonEmptyBucket", ErrLedgerNonEmptyBucket, "", minio.BucketNotEmpty{Bucket: testBucket1}},
+		{"ObjectTooLarge", ErrObjectTooLarge, testObject1, minio.ObjectTooLarge{Bucket: testBucket1, Object: testObject1}},
+		{"PartTooLarge", ErrPartTooLarge, testObject1, minio.PartTooBig{}},
+		{"InvalidPartNumber", ErrInvalidPartNumber, testObject1, minio.InvalidPartNumber{}},
+		{"MetadataTooLarge", ErrMetadataTooLarge, testObject1, minio.MetadataTooLarge{}},
+		{"InvalidPinMode", ErrInvalidPinMode, testObject1, minio.UnsupportedMetadata{}},
+		{"WrappedLedgerError", fmt.Errorf("loading: %w", ErrLedgerObjectDoesNotExist), testObject1, minio.ObjectNotFound{Bucket: testBucket1, Object: testObject1}},
+		{"DatastoreNotFound", datastore.ErrNotFound, "", minio.BucketNotFound{Bucket: testBucket1}},
+		{"DatastoreObjectNotFound", datastore.ErrNotFound, testObject1, minio.ObjectNotFound{Bucket: testBucket1, Object: testObject1}},
+		{"DeadlineExceeded", context.DeadlineExceeded, testObject1, minio.OperationTimedOut{}},
+		{"GrpcNotFound", status.Error(codes.NotFound, "not found"), testObject1, minio.ObjectNotFound{Bucket: testBucket1, Object: testObject1}},
+		{"GrpcAlreadyExists", status.Error(codes.AlreadyExists, "exists"), "", minio.BucketAlreadyExists{Bucket: testBucket1}},
+		{"GrpcUnavailable", status.Error(codes.Unavailable, "unavailable"), testObject1, minio.SlowDown{}},
+		{"GrpcResourceExhausted", status.Error(codes.ResourceExhausted, "exhausted"), testObject1, minio.SlowDown{}},
+		{"GrpcDeadlineExceeded", status.Error(codes.DeadlineExceeded, "deadline"), testObject1, minio.OperationTimedOut{}},
+		{"WrappedGrpcError", pkgerrors.Wrap(status.Error(codes.Unavailable, "unavailable"), "dag client error"), testObject1, minio.SlowDown{}},
+		{"GrpcInternal", status.Error(codes.Internal, "internal"), testObject1, status.Error(codes.Internal, "internal")},
+		{"Unknown", unknown, testObject1, unknown},
+		{"MinioError", minio.BucketNameInvalid{Bucket: testBucket1}, "", minio.BucketNameInvalid{Bucket: testBucket1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.toMinioErr(context.Background(), tt.err, testBucket1, tt.object, "id"); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %#v, but got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestS3X_ToGrpcErr(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Nil", nil, nil},
+		{"BucketDoesNotExist", ErrLedgerBucketDoesNotExist, status.Error(codes.NotFound, ErrLedgerBucketDoesNotExist.Error())},
+		{"ObjectExists", ErrLedgerObjectExists, status.Error(codes.AlreadyExists, ErrLedgerObjectExists.Error())},
+		{"Standby", ErrLedgerStandby, status.Error(codes.FailedPrecondition, ErrLedgerStandby.Error())},
+		{"InvalidObjectName", ErrInvalidObjectName, status.Error(codes.InvalidArgument, ErrInvalidObjectName.Error())},
+		{"InsufficientCapacity", ErrInsufficientCapacity, status.Error(codes.ResourceExhausted, ErrInsufficientCapacity.Error())},
+		{"WrappedLedgerError", fmt.Errorf("loading: %w", ErrLedgerObjectDoesNotExist),
+			status.Error(codes.NotFound, "loading: "+ErrLedgerObjectDoesNotExist.Error())},
+		{"PkgWrappedLedgerError", pkgerrors.Wrap(ErrLedgerSnapshotDoesNotExist, "loading"),
+			status.Error(codes.NotFound, "loading: "+ErrLedgerSnapshotDoesNotExist.Error())},
+		{"GrpcError", unavailable, unavailable},
+		{"WrappedGrpcError", pkgerrors.Wrap(unavailable, "dag client error"), unavailable},
+		{"Unknown", errors.New("unknown"), status.Error(codes.Internal, "unknown")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toGrpcErr(tt.err)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("expected no error, but got %v", got)
+				}
+				return
+			}
+			if s, want := status.Convert(got), status.Convert(tt.want); s.Code() != want.Code() || s.Message() != want.Message() {
+				t.Fatalf("expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+	if got := toGrpcErr(unavailable); got != unavailable {
+		t.Fatalf("expected the gRPC status error to be returned unchanged, but got %#v", got)
+	}
+}
@@ -0,0 +1,295 @@
+package s3x
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Object changes made through this gateway are published to an in memory hub, and streamed to clients
+connected to /events/<token> on the http api, as server-sent events, or as websocket text messages if the
+client requests a websocket upgrade. Tokens are created with CreateEventStream, and carry the policy of the
+stream: the bucket, an optional object prefix and event names, and an expiry after which the stream is closed.
+
+Events are best effort, a client that does not keep up misses events instead of slowing down writes,
+and changes made by other gateways sharing a crdt ledger are not published.
+*/
+
+const (
+	// eventsPathPrefix is the http path under which event streams are served
+	eventsPathPrefix = "/events/"
+	// defaultEventStreamExpiry is how long event stream tokens are valid if no expiry is requested
+	defaultEventStreamExpiry = time.Hour
+	// maxEventStreamExpiry is the longest valid event stream token
+	maxEventStreamExpiry = 7 * 24 * time.Hour
+	// eventBufferSize is the number of events buffered per client before events are dropped
+	eventBufferSize = 256
+	// eventKeepAlive is how often an idle server-sent event stream sends a comment to keep the connection open
+	eventKeepAlive = 30 * time.Second
+
+	eventObjectCreated = "s3:ObjectCreated:Put"
+	eventObjectRemoved = "s3:ObjectRemoved:Delete"
+)
+
+// bucketEvent is a change of an object
+type bucketEvent struct {
+	EventName   string    `json:"eventName"`
+	Bucket      string    `json:"bucket"`
+	Object      string    `json:"object"`
+	Size        int64     `json:"size,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// newObjectEvent returns an event of an object at now, info is nil for removed objects
+func newObjectEvent(name, bucket, object string, info *ObjectInfo, now time.Time) bucketEvent {
+	e := bucketEvent{
+		EventName: name,
+		Bucket:    bucket,
+		Object:    object,
+		Time:      now.UTC(),
+	}
+	if info != nil {
+		e.Size = info.Size_
+		e.ETag = info.Etag
+		e.ContentType = info.ContentType
+	}
+	return e
+}
+
+// matchEventName returns true if name matches pattern, patterns can end with a * wildcard
+func matchEventName(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == name
+}
+
+// eventSubscriber receives the events of a bucket that match its filter
+type eventSubscriber struct {
+	bucket string
+	prefix string
+	events []string
+	ch     chan bucketEvent
+}
+
+// matches returns true if the subscriber should receive e
+func (s *eventSubscriber) matches(e bucketEvent) bool {
+	if e.Bucket != s.bucket || !strings.HasPrefix(e.Object, s.prefix) {
+		return false
+	}
+	if len(s.events) == 0 {
+		return true
+	}
+	for _, pattern := range s.events {
+		if matchEventName(pattern, e.EventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventHub publishes events to subscribers
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*eventSubscriber]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[*eventSubscriber]struct{})}
+}
+
+// subscribe returns a subscriber for the events of a bucket, which must be unsubscribed when done
+func (h *eventHub) subscribe(bucket, prefix string, events []string) *eventSubscriber {
+	s := &eventSubscriber{
+		bucket: bucket,
+		prefix: prefix,
+		events: events,
+		ch:     make(chan bucketEvent, eventBufferSize),
+	}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *eventHub) unsubscribe(s *eventSubscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+// publish sends e to all matching subscribers without blocking, subscribers with full buffers miss the event
+func (h *eventHub) publish(e bucketEvent) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
+
+// eventToken is the signed policy of an event stream
+type eventToken struct {
+	Bucket  string   `json:"b"`
+	Prefix  string   `json:"p,omitempty"`
+	Events  []string `json:"n,omitempty"`
+	Expires int64    `json:"e"`
+}
+
+// CreateEventStream creates a time-limited url that streams the object events of a bucket
+func (x *xObjects) CreateEventStream(ctx context.Context, req *EventStreamRequest) (*EventStreamResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	for _, name := range req.GetEvents() {
+		if !matchEventName(name, eventObjectCreated) && !matchEventName(name, eventObjectRemoved) {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported event %q", name)
+		}
+	}
+	expiry := defaultEventStreamExpiry
+	if req.GetExpiresSeconds() != 0 {
+		expiry = time.Duration(req.GetExpiresSeconds()) * time.Second
+	}
+	if expiry <= 0 || expiry > maxEventStreamExpiry {
+		return nil, status.Errorf(codes.InvalidArgument, "expiry must be between 1 second and %v", maxEventStreamExpiry)
+	}
+	// the stream shows the events of the bucket to anyone with its url, like a bucket notification listener
+	if err := callerAllowed(ctx, policy.ListenBucketNotificationAction, req.GetBucket(), ""); err != nil {
+		return nil, err
+	}
+	if err := x.ledgerStore.AssertBucketExits(req.GetBucket()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	expires := x.clock.Now().Add(expiry)
+	token, err := signToken(x.eventsKey, eventToken{
+		Bucket:  req.GetBucket(),
+		Prefix:  req.GetPrefix(),
+		Events:  req.GetEvents(),
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("bucket-name: %s, event-stream-prefix: %q, event-stream-events: %v", req.GetBucket(), req.GetPrefix(), req.GetEvents())
+	return &EventStreamResponse{
+		Url:     strings.TrimSuffix(req.GetEndpoint(), "/") + eventsPathPrefix + token,
+		Expires: expires.Unix(),
+	}, nil
+}
+
+// ServeEvents streams the events allowed by the token of /events/<token> until the token expires,
+// the client disconnects, or the gateway shuts down.
+func (x *xObjects) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	t := &eventToken{}
+	if !verifyToken(x.eventsKey, strings.TrimPrefix(r.URL.Path, eventsPathPrefix), t) {
+		http.Error(w, "invalid event stream token", http.StatusForbidden)
+		return
+	}
+	remaining := time.Unix(t.Expires, 0).Sub(x.clock.Now())
+	if remaining < 0 {
+		http.Error(w, "event stream token expired", http.StatusForbidden)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), remaining)
+	defer cancel()
+	sub := x.ledgerStore.events.subscribe(t.Bucket, t.Prefix, t.Events)
+	defer x.ledgerStore.events.unsubscribe(sub)
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		websocket.Server{
+			// the token authorizes the stream, so requests from any origin are accepted
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(conn *websocket.Conn) {
+				// clients do not send messages, reading only detects when they disconnect
+				go func() {
+					_, _ = io.Copy(ioutil.Discard, conn)
+					cancel()
+				}()
+				x.streamEvents(ctx, sub, func(e bucketEvent) error {
+					return websocket.JSON.Send(conn, e)
+				}, nil)
+			},
+		}.ServeHTTP(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	x.streamEvents(ctx, sub, func(e bucketEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.EventName, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}, func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
+
+// streamEvents sends the events of sub until ctx or the gateway is done, or sending fails,
+// keepAlive is called when no event was sent for eventKeepAlive if set.
+func (x *xObjects) streamEvents(ctx context.Context, sub *eventSubscriber, send func(bucketEvent) error, keepAlive func() error) {
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-x.ctx.Done():
+			return
+		case e := <-sub.ch:
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if keepAlive != nil {
+				if err := keepAlive(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
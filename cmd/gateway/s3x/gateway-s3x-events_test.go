@@ -0,0 +1,165 @@
+package s3x
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Events_Badger(t *testing.T) {
+	testS3XEvents(t, DSTypeBadger)
+}
+func TestS3X_Events_Crdt(t *testing.T) {
+	testS3XEvents(t, DSTypeCrdt)
+}
+func testS3XEvents(t *testing.T, dsType DSType) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(gateway.ServeEvents))
+	defer server.Close()
+	stream := func(t *testing.T, req *EventStreamRequest) string {
+		req.Bucket = testBucket1
+		req.Endpoint = server.URL
+		resp, err := gateway.CreateEventStream(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetUrl()
+	}
+	put := func(t *testing.T, object string) {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := gateway.CreateEventStream(ctx, &EventStreamRequest{Bucket: testBucket1, Events: []string{"s3:BucketCreated:*"}}); err == nil {
+			t.Fatal("expected an error for an unsupported event")
+		}
+		denied := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.GetObjectAction}})
+		if _, err := gateway.CreateEventStream(denied, &EventStreamRequest{Bucket: testBucket1}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected a caller not allowed to listen to the bucket to be denied, but got %v", err)
+		}
+		allowed := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.ListenBucketNotificationAction}})
+		if _, err := gateway.CreateEventStream(allowed, &EventStreamRequest{Bucket: testBucket1}); err != nil {
+			t.Fatalf("expected a caller allowed to listen to the bucket to create a stream, but got %v", err)
+		}
+		u := stream(t, &EventStreamRequest{})
+		resp, err := http.Get(u + "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected status %v for a modified token, but got %v", http.StatusForbidden, resp.StatusCode)
+		}
+	})
+	t.Run("SSE", func(t *testing.T) {
+		u := stream(t, &EventStreamRequest{Prefix: "logs/", Events: []string{"s3:ObjectCreated:*"}})
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected an event stream, but got %q", ct)
+		}
+		put(t, "images/skipped.png") // filtered by prefix
+		put(t, "logs/a.log")
+		if err := gateway.DeleteObject(ctx, testBucket1, "logs/a.log"); err != nil { // filtered by event name
+			t.Fatal(err)
+		}
+		put(t, "logs/b.log")
+		r := bufio.NewReader(resp.Body)
+		for _, want := range []string{"logs/a.log", "logs/b.log"} {
+			var e bucketEvent
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.HasPrefix(line, "data: ") {
+					if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+						t.Fatal(err)
+					}
+					break
+				}
+			}
+			if e.Object != want || e.EventName != eventObjectCreated || e.Bucket != testBucket1 {
+				t.Fatalf("expected a created event of %v, but got %+v", want, e)
+			}
+		}
+	})
+	t.Run("WebSocket", func(t *testing.T) {
+		u := stream(t, &EventStreamRequest{Events: []string{eventObjectRemoved}})
+		conn, err := websocket.Dial("ws"+strings.TrimPrefix(u, "http"), "", server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		put(t, "ws.txt")
+		if err := gateway.DeleteObject(ctx, testBucket1, "ws.txt"); err != nil {
+			t.Fatal(err)
+		}
+		var e bucketEvent
+		if err := websocket.JSON.Receive(conn, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Object != "ws.txt" || e.EventName != eventObjectRemoved {
+			t.Fatalf("expected a removed event of ws.txt, but got %+v", e)
+		}
+	})
+}
+
+func TestS3X_EventStreamClock(t *testing.T) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	ls, _, _ := newFaultyLedger(t)
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	// the gateway is shut down, so streams end once their headers are sent
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+	x := &xObjects{ctx: done, ledgerStore: ls, clock: fixedClock(now), eventsKey: []byte("events key")}
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := x.CreateEventStream(ctx, &EventStreamRequest{Bucket: testBucket1, ExpiresSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Minute).Unix(); resp.GetExpires() != want {
+		t.Fatalf("expected the stream to expire a minute after the time of the gateway clock, %d, but got %d", want, resp.GetExpires())
+	}
+	for _, test := range []struct {
+		now  time.Time
+		code int
+	}{
+		{now.Add(30 * time.Second), http.StatusOK},
+		{now.Add(2 * time.Minute), http.StatusForbidden},
+	} {
+		x.clock = fixedClock(test.now)
+		rec := httptest.NewRecorder()
+		x.ServeEvents(rec, httptest.NewRequest(http.MethodGet, resp.GetUrl(), nil))
+		if rec.Code != test.code {
+			t.Fatalf("expected status %d at %v, but got %d: %s", test.code, test.now, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// objectExpiryInterval is how often expired objects are removed from all buckets
+const objectExpiryInterval = time.Hour
+
+// SetBucketObjectTTL sets after how many days objects of a bucket without their own expiration are expired,
+// 0 disables the default expiration, objects with their own expiration are expired regardless.
+func (x *xObjects) SetBucketObjectTTL(ctx context.Context, req *SetBucketObjectTTLRequest) (*SetBucketObjectTTLResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetDays() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "days can not be negative")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.ObjectTTLDays = req.GetDays()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-ttl-days: %v", req.GetBucket(), req.GetDays())
+	return &SetBucketObjectTTLResponse{
+		Bucket: req.GetBucket(),
+		Days:   req.GetDays(),
+	}, nil
+}
+
+// expireObjectsLoop removes expired objects every interval until the gateway is shut down
+func (x *xObjects) expireObjectsLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := x.ledgerStore.ExpireObjects(x.ctx, now)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to expire objects: %v", err)
+			}
+			if n > 0 {
+				log.Printf("expired %v objects", n)
+			}
+		}
+	}
+}
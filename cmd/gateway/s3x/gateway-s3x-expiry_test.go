@@ -0,0 +1,99 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_ObjectExpiry(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gateway.SetBucketObjectTTL(ctx, &SetBucketObjectTTLRequest{Bucket: testBucket1, Days: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+	}
+	if _, err := gateway.SetBucketObjectTTL(ctx, &SetBucketObjectTTLRequest{Bucket: testBucket1, Days: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketTrash(ctx, &SetBucketTrashRequest{Bucket: testBucket1, RetentionDays: 7}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(bucket, object string, meta map[string]string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{UserDefined: meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(testBucket1, "default", nil)
+	put(testBucket1, "never", map[string]string{"X-Amz-Meta-S3x-Ttl-Days": "0"})
+	put(testBucket1, "longer", map[string]string{"X-Amz-Meta-S3x-Ttl-Days": "3"})
+	put(testBucket1, "invalid", map[string]string{"X-Amz-Meta-S3x-Ttl-Days": "soon"})
+	// locked objects are kept until their lock is released
+	put(testBucket1, "held", map[string]string{"X-Amz-Object-Lock-Legal-Hold": "ON"})
+	// objects of buckets without a ttl only expire with their own expiration
+	put(testBucket2, "default", nil)
+	put(testBucket2, "own", map[string]string{"X-Amz-Meta-S3x-Ttl-Days": "1"})
+
+	exists := func(bucket, object string) bool {
+		t.Helper()
+		_, err := gateway.GetObjectInfo(ctx, bucket, object, minio.ObjectOptions{})
+		if err != nil && err != (minio.ObjectNotFound{Bucket: bucket, Object: object}) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	n, err := gateway.ledgerStore.ExpireObjects(ctx, gateway.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no objects to expire yet, but got %v", n)
+	}
+	n, err = gateway.ledgerStore.ExpireObjects(ctx, gateway.clock.Now().Add(2*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || exists(testBucket1, "default") || exists(testBucket1, "invalid") || exists(testBucket2, "own") {
+		t.Fatalf("expected 3 objects to expire, but got %v", n)
+	}
+	if !exists(testBucket1, "longer") || !exists(testBucket2, "default") {
+		t.Fatal("expected objects with a longer or without expiration to be kept")
+	}
+	// expired objects are removed like deleted objects
+	names, _, _, err := gateway.ledgerStore.ListTrash(ctx, testBucket1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected the expired objects to be trashed, but got %v", names)
+	}
+	n, err = gateway.ledgerStore.ExpireObjects(ctx, gateway.clock.Now().Add(30*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || exists(testBucket1, "longer") || !exists(testBucket1, "never") || !exists(testBucket1, "held") {
+		t.Fatalf("expected 1 object to expire, but got %v", n)
+	}
+	// the default expiration can be disabled
+	if _, err := gateway.SetBucketObjectTTL(ctx, &SetBucketObjectTTLRequest{Bucket: testBucket1}); err != nil {
+		t.Fatal(err)
+	}
+	put(testBucket1, "default", nil)
+	if n, err := gateway.ledgerStore.ExpireObjects(ctx, gateway.clock.Now().Add(30*24*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("expected no objects to expire, but got %v, %v", n, err)
+	}
+}
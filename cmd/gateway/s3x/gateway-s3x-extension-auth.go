@@ -0,0 +1,212 @@
+package s3x
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+The info and extension apis change and expose the ledger, so every call is authenticated with the S3 credentials
+of the gateway and its IAM users. Requests to the http endpoint are signed like S3 requests, with AWS signature
+v4 or v2, and are forwarded by the grpc-gateway to the grpc endpoint with the verified access key and a proxy key
+that is random per process, so the grpc endpoint trusts forwarded calls only from its own http endpoint. Direct
+grpc calls send their access key, the date of the call, a random nonce, and the signature of the date, nonce and
+method with the secret key in the metadata, see minio.ExtensionCallSignature, and the session token of temporary
+credentials. A nonce can only be used once while the date of its call is within the allowed clock skew, so captured
+calls can not be replayed. The grpc-gateway does not forward the s3x- headers of requests, and calls that send a
+credential more than once are rejected, so only the forwarded credentials of the verified request are trusted.
+
+Calls of the root credential are allowed, calls of other credentials only to the delegated methods, which mint
+tokens for buckets and objects and check that the caller may access them like the S3 api would. The cluster
+datastore api is authorized by its own key, and the token urls of share links, upload grants, and event streams,
+and the /ipfs/ paths of content addressed data, are authorized by their path. Both endpoints listen on localhost
+by default, and are served without TLS, so exposing them needs a TLS terminating proxy.
+*/
+
+const (
+	// the grpc metadata keys of the credentials of a call
+	callAccessKeyHeader     = "s3x-access-key"
+	callDateHeader          = "s3x-date"
+	callSignatureHeader     = "s3x-signature"
+	callNonceHeader         = "s3x-nonce"
+	callSessionTokenHeader  = "s3x-security-token"
+	callProxyKeyHeader      = "s3x-proxy-key"
+	ledgerDatastoreAPIScope = "/s3x.LedgerDatastoreAPI/"
+	// callHeaderPrefix is the prefix of the metadata keys of the credentials of a call
+	callHeaderPrefix = "s3x-"
+)
+
+// callCredentialHeaders are the metadata keys of the credentials of a call, each of them may only be sent once
+var callCredentialHeaders = []string{
+	callAccessKeyHeader, callDateHeader, callSignatureHeader, callNonceHeader, callSessionTokenHeader, callProxyKeyHeader,
+}
+
+// delegatedMethods are the methods that credentials other than the root credential can call,
+// each method authorizes the caller for the buckets and objects of the call itself
+var delegatedMethods = map[string]bool{
+	"/s3x.ExtensionAPI/CreateShareLink":   true,
+	"/s3x.ExtensionAPI/CreateUploadGrant": true,
+	"/s3x.ExtensionAPI/CreateEventStream": true,
+}
+
+// extensionCaller is the authenticated caller of an api call, see minio.ExtensionCaller
+type extensionCaller interface {
+	AccessKey() string
+	IsOwner() bool
+	IsAllowed(action policy.Action, bucket, object string) bool
+}
+
+type extensionCallerKey struct{}
+
+// withExtensionCaller returns a context of a call of c
+func withExtensionCaller(ctx context.Context, c extensionCaller) context.Context {
+	return context.WithValue(ctx, extensionCallerKey{}, c)
+}
+
+// callerAllowed returns a PermissionDenied error unless the caller of a call may do action on a bucket or object
+func callerAllowed(ctx context.Context, action policy.Action, bucket, object string) error {
+	c, ok := ctx.Value(extensionCallerKey{}).(extensionCaller)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "the call is not authenticated")
+	}
+	if !c.IsAllowed(action, bucket, object) {
+		return status.Errorf(codes.PermissionDenied, "%s is not allowed %s on %s", c.AccessKey(), action, strings.TrimSuffix(bucket+"/"+object, "/"))
+	}
+	return nil
+}
+
+// newProxyKey returns a random key that authorizes the calls forwarded by the http endpoint
+func newProxyKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// authenticateCall returns the context of a grpc call with its caller, or an error if the call
+// is not authenticated, or its caller may not call the method
+func (x *xObjects) authenticateCall(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	// a credential sent twice could be checked with one value and used with the other
+	for _, key := range callCredentialHeaders {
+		if len(md.Get(key)) > 1 {
+			return nil, status.Errorf(codes.Unauthenticated, "%s was sent more than once", key)
+		}
+	}
+	get := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	var caller minio.ExtensionCaller
+	var err error
+	if key := get(callProxyKeyHeader); key != "" {
+		if !hmac.Equal([]byte(key), []byte(x.proxyKey)) {
+			return nil, status.Error(codes.Unauthenticated, "invalid proxy key")
+		}
+		caller, err = minio.ExtensionCallerOf(get(callAccessKeyHeader), get(callSessionTokenHeader))
+	} else {
+		caller, err = minio.AuthenticateExtensionCall(get(callAccessKeyHeader), get(callSessionTokenHeader),
+			get(callDateHeader), get(callNonceHeader), method, get(callSignatureHeader), x.clock.Now())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !caller.IsOwner() && !delegatedMethods[method] {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", caller.AccessKey(), method)
+	}
+	return withExtensionCaller(ctx, caller), nil
+}
+
+// unaryAuth authenticates the unary calls of the info and extension apis
+func (x *xObjects) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, ledgerDatastoreAPIScope) {
+		return handler(ctx, req)
+	}
+	ctx, err := x.authenticateCall(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream is a server stream with the context of its authenticated call
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context { return s.ctx }
+
+// streamAuth authenticates the streaming calls of the info and extension apis
+func (x *xObjects) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, ledgerDatastoreAPIScope) {
+		return handler(srv, ss)
+	}
+	ctx, err := x.authenticateCall(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticateHTTP verifies the S3 signature of the requests to an http handler, and adds the
+// credentials the calls of the grpc-gateway are forwarded with to their context
+func (x *xObjects) authenticateHTTP(owner bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, err := minio.AuthenticateExtensionRequest(r.Context(), r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if owner && !caller.IsOwner() {
+			http.Error(w, caller.AccessKey()+" is not allowed to call "+r.URL.Path, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withExtensionCaller(r.Context(), caller)))
+	})
+}
+
+// forwardedHeaderMatcher forwards the http headers of requests to the grpc-gateway as grpc metadata like
+// runtime.DefaultHeaderMatcher, except for the credentials of calls, which are only set by forwardedCredentials
+func forwardedHeaderMatcher(key string) (string, bool) {
+	key, ok := runtime.DefaultHeaderMatcher(key)
+	if !ok || strings.HasPrefix(strings.ToLower(key), callHeaderPrefix) {
+		return "", false
+	}
+	return key, true
+}
+
+// forwardedCredentials returns the metadata the grpc-gateway forwards the call of an authenticated request with
+func (x *xObjects) forwardedCredentials(ctx context.Context, r *http.Request) metadata.MD {
+	c, ok := r.Context().Value(extensionCallerKey{}).(extensionCaller)
+	if !ok {
+		return nil
+	}
+	token := r.Header.Get(xhttp.AmzSecurityToken)
+	if token == "" {
+		token = r.URL.Query().Get(xhttp.AmzSecurityToken)
+	}
+	return metadata.Pairs(
+		callProxyKeyHeader, x.proxyKey,
+		callAccessKeyHeader, c.AccessKey(),
+		callSessionTokenHeader, token,
+	)
+}
@@ -0,0 +1,90 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testCaller is a caller that is allowed the actions on the buckets in allowed
+type testCaller struct {
+	owner   bool
+	allowed map[string]policy.Action
+}
+
+func (c testCaller) AccessKey() string { return "testcaller" }
+func (c testCaller) IsOwner() bool     { return c.owner }
+func (c testCaller) IsAllowed(action policy.Action, bucket, object string) bool {
+	return c.owner || c.allowed[bucket] == action
+}
+
+func TestExtensionAuth(t *testing.T) {
+	x := &xObjects{clock: testClock{}, proxyKey: "proxykey"}
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	call := func(ctx context.Context, method string) codes.Code {
+		called = false
+		_, err := x.unaryAuth(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		if (err == nil) != called {
+			t.Fatalf("expected the handler to be called only without an error, but got %v", err)
+		}
+		return status.Code(err)
+	}
+	ctx := context.Background()
+	if code := call(ctx, "/s3x.LedgerDatastoreAPI/Get"); code != codes.OK {
+		t.Fatalf("expected the cluster api to be authorized by its own key, but got %v", code)
+	}
+	if code := call(ctx, "/s3x.ExtensionAPI/RenameObject"); code != codes.Unauthenticated {
+		t.Fatalf("expected a call without credentials to be unauthenticated, but got %v", code)
+	}
+	forged := metadata.NewIncomingContext(ctx, metadata.Pairs(callProxyKeyHeader, "forged", callAccessKeyHeader, "admin"))
+	if code := call(forged, "/s3x.ExtensionAPI/RenameObject"); code != codes.Unauthenticated {
+		t.Fatalf("expected a call with a forged proxy key to be unauthenticated, but got %v", code)
+	}
+	// the credential checked with the first value must not be used with the second
+	duplicated := metadata.NewIncomingContext(ctx, metadata.Pairs(callProxyKeyHeader, "proxykey",
+		callAccessKeyHeader, "admin", callAccessKeyHeader, "user"))
+	if code := call(duplicated, "/s3x.ExtensionAPI/RenameObject"); code != codes.Unauthenticated {
+		t.Fatalf("expected a call with a duplicated access key to be unauthenticated, but got %v", code)
+	}
+	unsigned := metadata.NewIncomingContext(ctx, metadata.Pairs(callAccessKeyHeader, "admin"))
+	if code := call(unsigned, "/s3x.InfoAPI/GetHash"); code != codes.Unauthenticated {
+		t.Fatalf("expected an unsigned call to be unauthenticated, but got %v", code)
+	}
+
+	if code := status.Code(callerAllowed(ctx, policy.GetObjectAction, "bucket", "object")); code != codes.Unauthenticated {
+		t.Fatalf("expected a context without a caller to be unauthenticated, but got %v", code)
+	}
+	caller := withExtensionCaller(ctx, testCaller{allowed: map[string]policy.Action{"bucket": policy.GetObjectAction}})
+	if err := callerAllowed(caller, policy.GetObjectAction, "bucket", "object"); err != nil {
+		t.Fatal(err)
+	}
+	if code := status.Code(callerAllowed(caller, policy.PutObjectAction, "bucket", "object")); code != codes.PermissionDenied {
+		t.Fatalf("expected a denied action, but got %v", code)
+	}
+	if code := status.Code(callerAllowed(caller, policy.GetObjectAction, "other", "object")); code != codes.PermissionDenied {
+		t.Fatalf("expected a denied bucket, but got %v", code)
+	}
+}
+
+func TestForwardedHeaderMatcher(t *testing.T) {
+	for header, forwarded := range map[string]bool{
+		"Grpc-Metadata-S3x-Access-Key": false,
+		"Grpc-Metadata-s3x-proxy-key":  false,
+		"Grpc-Metadata-Request-Id":     true,
+		"Authorization":                true,
+		"X-Custom":                     false,
+	} {
+		if key, ok := forwardedHeaderMatcher(header); ok != forwarded {
+			t.Errorf("expected %s to be forwarded %v, but got %q %v", header, forwarded, key, ok)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"log"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RenameObject moves an object to a new key within a bucket by re-keying the ledger entry,
+// the object data stored on IPFS is left untouched.
+func (x *xObjects) RenameObject(ctx context.Context, req *RenameObjectRequest) (*RenameObjectResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" || req.GetNewObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	if req.GetObject() == req.GetNewObject() {
+		return nil, status.Error(codes.InvalidArgument, "object and new object names are the same")
+	}
+	if err := x.names.checkObjectName(req.GetNewObject()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	hash, err := x.ledgerStore.RenameObject(ctx, req.GetBucket(), req.GetObject(), req.GetNewObject(), req.GetOverwrite())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, renamed-to: %s", req.GetBucket(), req.GetObject(), req.GetNewObject())
+	return &RenameObjectResponse{
+		Bucket: req.GetBucket(),
+		Object: req.GetNewObject(),
+		Hash:   hash,
+	}, nil
+}
+
+// maxComposeSources is the maximum number of objects that can be composed in one request
+const maxComposeSources = 32
+
+// ComposeObject creates an object from the concatenation of existing objects by linking their data DAGs,
+// no object data is downloaded or uploaded.
+func (x *xObjects) ComposeObject(ctx context.Context, req *ComposeObjectRequest) (*ComposeObjectResponse, error) {
+	bucket, object := req.GetBucket(), req.GetObject()
+	if bucket == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if object == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	if len(req.GetSources()) == 0 || len(req.GetSources()) > maxComposeSources {
+		return nil, status.Errorf(codes.InvalidArgument, "number of sources must be between 1 and %v", maxComposeSources)
+	}
+	if err := x.names.checkObjectName(object); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if err := x.assertNoDefaultEncryption(ctx, bucket, "composed"); err != nil {
+		return nil, err
+	}
+	defer x.ledgerStore.locker.write(bucket)()
+	hashes := make([]string, 0, len(req.GetSources()))
+	sizes := make([]uint64, 0, len(req.GetSources()))
+	parts := make([]ObjectPartInfo, 0, len(req.GetSources()))
+	var total int64
+	contentType := req.GetContentType()
+	for i, name := range req.GetSources() {
+		src, err := x.ledgerStore.object(ctx, bucket, name)
+		if err != nil {
+			return nil, toGrpcErr(err)
+		}
+		if src.ObjectInfo.GetCompression() != "" || src.Erasure != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "source %q is compressed or erasure coded and can not be composed", name)
+		}
+		if hasEncryptionMetadata(src.ObjectInfo.GetUserDefined()) {
+			return nil, status.Errorf(codes.FailedPrecondition, "source %q is encrypted and can not be composed", name)
+		}
+		if contentType == "" {
+			contentType = src.ObjectInfo.GetContentType()
+		}
+		parts = append(parts, ObjectPartInfo{
+			Number:       int64(i + 1),
+			Name:         name,
+			LastModified: src.ObjectInfo.GetModTime(),
+			Size_:        src.ObjectInfo.GetSize_(),
+			ActualSize:   src.ObjectInfo.GetSize_(),
+			DataHash:     src.GetDataHash(),
+		})
+		if src.ObjectInfo.GetSize_() == 0 {
+			continue // empty objects do not contribute any data
+		}
+		hashes = append(hashes, src.GetDataHash())
+		sizes = append(sizes, uint64(src.ObjectInfo.GetSize_()))
+		total += src.ObjectInfo.GetSize_()
+	}
+	if err := x.limits.checkObjectSize(total); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	dataHash, size, err := x.saveFileLinks(ctx, hashes, sizes)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	obj := &Object{
+		DataHash:   dataHash,
+		ObjectInfo: newObjectInfo(bucket, object, int(size), minio.ObjectOptions{}, x.clock.Now()),
+	}
+	obj.ObjectInfo.ContentType = contentType
+	obj.ObjectInfo.Parts = parts
+	if err := x.ledgerStore.putObject(ctx, bucket, object, obj); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	hash, err := x.ledgerStore.getObjectHash(ctx, bucket, object)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, composed-from: %v, file-hash: %s", bucket, object, req.GetSources(), dataHash)
+	return &ComposeObjectResponse{
+		Bucket:   bucket,
+		Object:   object,
+		Hash:     hash,
+		DataHash: dataHash,
+		Size_:    int64(size),
+	}, nil
+}
+
+// AppendObject adds data to the end of an object by linking the new data into the object's DAG,
+// the size and ETag of the object are updated in a single ledger update.
+func (x *xObjects) AppendObject(ctx context.Context, req *AppendObjectRequest) (*AppendObjectResponse, error) {
+	bucket, object := req.GetBucket(), req.GetObject()
+	if bucket == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if object == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	if len(req.GetData()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "data is empty")
+	}
+	if err := x.names.checkObjectName(object); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if err := x.ledgerStore.AssertBucketExits(bucket); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if err := x.assertNoDefaultEncryption(ctx, bucket, "appended"); err != nil {
+		return nil, err
+	}
+	// upload before claiming the bucket lock, as this is the slow part
+	hash, size, err := ipfsFileUpload(ctx, x.fileClient, bytes.NewReader(req.GetData()))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	defer x.ledgerStore.locker.write(bucket)()
+	obj, err := x.ledgerStore.object(ctx, bucket, object)
+	switch {
+	case err == ErrLedgerObjectDoesNotExist && req.GetCreate():
+		obj = &Object{ObjectInfo: newObjectInfo(bucket, object, 0, minio.ObjectOptions{}, x.clock.Now())}
+	case err != nil:
+		return nil, toGrpcErr(err)
+	}
+	if obj.ObjectInfo.GetCompression() != "" || obj.Erasure != nil {
+		return nil, status.Error(codes.FailedPrecondition, "compressed or erasure coded objects can not be appended to")
+	}
+	if hasEncryptionMetadata(obj.ObjectInfo.GetUserDefined()) {
+		return nil, status.Error(codes.FailedPrecondition, "encrypted objects can not be appended to")
+	}
+	md5Hash := md5.Sum(req.GetData())
+	parts := appendableParts(obj)
+	parts = append(parts, ObjectPartInfo{
+		Number:       int64(len(parts) + 1),
+		Name:         object,
+		LastModified: x.clock.Now().UTC(),
+		Size_:        int64(size),
+		ActualSize:   int64(size),
+		DataHash:     hash,
+		Etag:         hex.EncodeToString(md5Hash[:]),
+	})
+	hashes := make([]string, 0, len(parts))
+	sizes := make([]uint64, 0, len(parts))
+	completed := make([]minio.CompletePart, 0, len(parts))
+	var total int64
+	for _, p := range parts {
+		hashes = append(hashes, p.GetDataHash())
+		sizes = append(sizes, uint64(p.GetSize_()))
+		completed = append(completed, minio.CompletePart{PartNumber: int(p.GetNumber()), ETag: partETag(p)})
+		total += p.GetSize_()
+	}
+	if err := x.limits.checkObjectSize(total); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	dataHash, totalSize, err := x.saveFileLinks(ctx, hashes, sizes)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	obj.DataHash = dataHash
+	obj.ObjectInfo.Size_ = int64(totalSize)
+	// an object of a single part has the md5 of its data as ETag, appended objects the
+	// composite ETag of their parts, as multipart uploads
+	if len(parts) == 1 {
+		obj.ObjectInfo.Etag = partETag(parts[0])
+	} else {
+		obj.ObjectInfo.Etag = minio.ComputeCompleteMultipartMD5(completed)
+	}
+	obj.ObjectInfo.Parts = parts
+	obj.ObjectInfo.ModTime = x.clock.Now().UTC()
+	obj.ObjectInfo.UserDefined = withoutChecksums(obj.ObjectInfo.UserDefined)
+	if err := x.ledgerStore.putObject(ctx, bucket, object, obj); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	objHash, err := x.ledgerStore.getObjectHash(ctx, bucket, object)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, appended-hash: %s, file-hash: %s", bucket, object, hash, dataHash)
+	return &AppendObjectResponse{
+		Bucket:   bucket,
+		Object:   object,
+		Hash:     objHash,
+		DataHash: dataHash,
+		Size_:    int64(totalSize),
+	}, nil
+}
+
+// appendableParts returns the parts the object data is made of, so appending keeps the DAG flat.
+// Objects without recorded parts are treated as a single part, with the ETag of the object if it is the md5 of its data.
+func appendableParts(obj *Object) []ObjectPartInfo {
+	var total int64
+	for _, p := range obj.ObjectInfo.GetParts() {
+		total += p.GetSize_()
+	}
+	if len(obj.ObjectInfo.GetParts()) > 0 && total == obj.ObjectInfo.GetSize_() {
+		parts := make([]ObjectPartInfo, 0, len(obj.ObjectInfo.GetParts()))
+		for _, p := range obj.ObjectInfo.GetParts() {
+			if p.GetSize_() > 0 {
+				parts = append(parts, p)
+			}
+		}
+		return parts
+	}
+	if obj.ObjectInfo.GetSize_() == 0 {
+		return nil
+	}
+	return []ObjectPartInfo{{
+		Number:       1,
+		Name:         obj.ObjectInfo.GetName(),
+		LastModified: obj.ObjectInfo.GetModTime(),
+		Size_:        obj.ObjectInfo.GetSize_(),
+		ActualSize:   obj.ObjectInfo.GetSize_(),
+		DataHash:     obj.GetDataHash(),
+		Etag:         md5ETag(obj.ObjectInfo.GetEtag()),
+	}}
+}
+
+// CloneBucket creates a new bucket with the same objects as an existing bucket,
+// only ledger references are copied so the clone is instant regardless of the bucket size.
+func (x *xObjects) CloneBucket(ctx context.Context, req *CloneBucketRequest) (*CloneBucketResponse, error) {
+	if req.GetBucket() == "" || req.GetNewBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetBucket() == req.GetNewBucket() {
+		return nil, status.Error(codes.InvalidArgument, "bucket and new bucket names are the same")
+	}
+	if err := x.names.checkBucketName(req.GetNewBucket()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	b := &Bucket{BucketInfo: BucketInfo{Created: x.clock.Now().UTC()}}
+	hash, n, err := x.ledgerStore.CloneBucket(ctx, req.GetBucket(), req.GetNewBucket(), b, req.GetCopyConfig())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, cloned-to: %s, bucket-hash: %s", req.GetBucket(), req.GetNewBucket(), hash)
+	return &CloneBucketResponse{
+		Bucket:  req.GetNewBucket(),
+		Hash:    hash,
+		Objects: int64(n),
+	}, nil
+}
@@ -0,0 +1,358 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Extension_Badger(t *testing.T) {
+	testS3XExtension(t, DSTypeBadger)
+}
+func TestS3X_Extension_Crdt(t *testing.T) {
+	testS3XExtension(t, DSTypeCrdt)
+}
+func testS3XExtension(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	testPutObject(t, gateway)
+	t.Run("RenameObject", func(t *testing.T) {
+		const renamed = "renamedobject"
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name     string
+			req      *RenameObjectRequest
+			wantCode codes.Code
+		}{
+			{"Fail-Empty-Bucket", &RenameObjectRequest{Object: testObject1, NewObject: renamed}, codes.InvalidArgument},
+			{"Fail-Same-Name", &RenameObjectRequest{Bucket: testBucket1, Object: testObject1, NewObject: testObject1}, codes.InvalidArgument},
+			{"Fail-No-Bucket", &RenameObjectRequest{Bucket: testBucket2, Object: testObject1, NewObject: renamed}, codes.NotFound},
+			{"Fail-No-Object", &RenameObjectRequest{Bucket: testBucket1, Object: "notarealobj", NewObject: renamed}, codes.NotFound},
+			{"Success", &RenameObjectRequest{Bucket: testBucket1, Object: testObject1, NewObject: renamed}, codes.OK},
+			{"Fail-Source-Moved", &RenameObjectRequest{Bucket: testBucket1, Object: testObject1, NewObject: renamed}, codes.NotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := gateway.RenameObject(ctx, tt.req)
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("RenameObject() err %v, want code %v", err, tt.wantCode)
+				}
+				if err == nil && resp.GetObject() != renamed {
+					t.Fatalf("expected object %v, but got %v", renamed, resp.GetObject())
+				}
+			})
+		}
+		gateway.restart(t)
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, renamed, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != renamed {
+			t.Fatalf("expected object name %v, but got %v", renamed, info.Name)
+		}
+		newDataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, renamed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newDataHash != dataHash {
+			t.Fatalf("object data changed from %v to %v", dataHash, newDataHash)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected old object name to be removed")
+		}
+		testPutObject(t, gateway)
+		if _, err := gateway.RenameObject(ctx, &RenameObjectRequest{
+			Bucket: testBucket1, Object: testObject1, NewObject: renamed,
+		}); status.Code(err) != codes.AlreadyExists {
+			t.Fatal("expected AlreadyExists, but got", err)
+		}
+		if _, err := gateway.RenameObject(ctx, &RenameObjectRequest{
+			Bucket: testBucket1, Object: testObject1, NewObject: renamed, Overwrite: true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("ComposeObject", func(t *testing.T) {
+		const composed = "composedobject"
+		testPutObject(t, gateway)
+		tests := []struct {
+			name     string
+			req      *ComposeObjectRequest
+			wantCode codes.Code
+		}{
+			{"Fail-No-Sources", &ComposeObjectRequest{Bucket: testBucket1, Object: composed}, codes.InvalidArgument},
+			{"Fail-No-Bucket", &ComposeObjectRequest{Bucket: testBucket2, Object: composed, Sources: []string{testObject1}}, codes.NotFound},
+			{"Fail-No-Source", &ComposeObjectRequest{Bucket: testBucket1, Object: composed, Sources: []string{"notarealobj"}}, codes.NotFound},
+			{"Success", &ComposeObjectRequest{Bucket: testBucket1, Object: composed, Sources: []string{testObject1, testObject1}}, codes.OK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := gateway.ComposeObject(ctx, tt.req)
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("ComposeObject() err %v, want code %v", err, tt.wantCode)
+				}
+				if err == nil && resp.GetSize_() != int64(2*len(testObject1Data)) {
+					t.Fatalf("unexpected composed size %v", resp.GetSize_())
+				}
+			})
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, composed, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if want := testObject1Data + testObject1Data; buf.String() != want {
+			t.Fatalf("expected composed data %q, but got %q", want, buf.String())
+		}
+	})
+	t.Run("AppendObject", func(t *testing.T) {
+		const appended = "appendedobject"
+		tests := []struct {
+			name     string
+			req      *AppendObjectRequest
+			wantCode codes.Code
+			wantData string
+			// the data of each part, an object of a single part has the ETag of the md5 of its data
+			wantParts []string
+		}{
+			{"Fail-No-Data", &AppendObjectRequest{Bucket: testBucket1, Object: appended}, codes.InvalidArgument, "", nil},
+			{"Fail-No-Object", &AppendObjectRequest{Bucket: testBucket1, Object: appended, Data: []byte("a")}, codes.NotFound, "", nil},
+			{"Fail-No-Bucket", &AppendObjectRequest{Bucket: testBucket2, Object: appended, Data: []byte("a"), Create: true}, codes.NotFound, "", nil},
+			{"Success-Create", &AppendObjectRequest{Bucket: testBucket1, Object: appended, Data: []byte("a"), Create: true}, codes.OK, "a", []string{"a"}},
+			{"Success-Append", &AppendObjectRequest{Bucket: testBucket1, Object: appended, Data: []byte("bc")}, codes.OK, "abc", []string{"a", "bc"}},
+			{"Success-Append-Again", &AppendObjectRequest{Bucket: testBucket1, Object: appended, Data: []byte("d")}, codes.OK, "abcd", []string{"a", "bc", "d"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := gateway.AppendObject(ctx, tt.req)
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("AppendObject() err %v, want code %v", err, tt.wantCode)
+				}
+				if err != nil {
+					return
+				}
+				if resp.GetSize_() != int64(len(tt.wantData)) {
+					t.Fatalf("expected size %v, but got %v", len(tt.wantData), resp.GetSize_())
+				}
+				buf := bytes.NewBuffer(nil)
+				if err := gateway.GetObject(ctx, testBucket1, appended, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+					t.Fatal(err)
+				}
+				if buf.String() != tt.wantData {
+					t.Fatalf("expected data %q, but got %q", tt.wantData, buf.String())
+				}
+				info, err := gateway.GetObjectInfo(ctx, testBucket1, appended, minio.ObjectOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				completed := make([]minio.CompletePart, 0, len(tt.wantParts))
+				for i, p := range tt.wantParts {
+					sum := md5.Sum([]byte(p))
+					completed = append(completed, minio.CompletePart{PartNumber: i + 1, ETag: hex.EncodeToString(sum[:])})
+				}
+				want := minio.ComputeCompleteMultipartMD5(completed)
+				if len(completed) == 1 {
+					want = s3ETag(completed[0].ETag)
+				}
+				if info.ETag != want {
+					t.Fatalf("expected ETag %v, but got %v", want, info.ETag)
+				}
+			})
+		}
+		oi, err := gateway.ledgerStore.ObjectInfo(ctx, testBucket1, appended)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(oi.GetParts()) != 3 {
+			t.Fatalf("expected a flat DAG of 3 parts, but got %v", len(oi.GetParts()))
+		}
+	})
+	t.Run("Trash", func(t *testing.T) {
+		const trashed = "trashedobject"
+		tests := []struct {
+			name     string
+			req      *SetBucketTrashRequest
+			wantCode codes.Code
+		}{
+			{"Fail-No-Bucket", &SetBucketTrashRequest{Bucket: "nosuchbucket", RetentionDays: 1}, codes.NotFound},
+			{"Fail-Negative", &SetBucketTrashRequest{Bucket: testBucket1, RetentionDays: -1}, codes.InvalidArgument},
+			{"Success", &SetBucketTrashRequest{Bucket: testBucket1, RetentionDays: 1}, codes.OK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := gateway.SetBucketTrash(ctx, tt.req); status.Code(err) != tt.wantCode {
+					t.Fatalf("SetBucketTrash() err %v, want code %v", err, tt.wantCode)
+				}
+			})
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, trashed, getTestPutObjectReader(t, []byte("trash")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, trashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, trashed); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, trashed, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected trashed object to be hidden")
+		}
+		list, err := gateway.ListTrash(ctx, &ListTrashRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.GetObjects()) != 1 || list.GetObjects()[0].GetObject() != trashed {
+			t.Fatalf("expected %v in trash, but got %v", trashed, list.GetObjects())
+		}
+		if d := list.GetObjects()[0].GetExpires() - list.GetObjects()[0].GetDeleted(); d != 24*60*60 {
+			t.Fatalf("expected expiry a day after deletion, but got %v seconds", d)
+		}
+		if _, err := gateway.RestoreObject(ctx, &RestoreObjectRequest{Bucket: testBucket1, Object: trashed}); err != nil {
+			t.Fatal(err)
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := gateway.GetObject(ctx, testBucket1, trashed, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "trash" {
+			t.Fatalf("expected restored data %q, but got %q", "trash", buf.String())
+		}
+		if _, err := gateway.RestoreObject(ctx, &RestoreObjectRequest{Bucket: testBucket1, Object: trashed}); status.Code(err) != codes.NotFound {
+			t.Fatalf("RestoreObject() err %v, want code %v", err, codes.NotFound)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, trashed); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := gateway.ledgerStore.PurgeTrash(ctx, gateway.clock.Now()); err != nil || n != 0 {
+			t.Fatalf("expected nothing to be purged before expiry, but got %v, %v", n, err)
+		}
+		if n, err := gateway.ledgerStore.PurgeTrash(ctx, gateway.clock.Now().Add(48*time.Hour)); err != nil || n != 1 {
+			t.Fatalf("expected 1 object to be purged after expiry, but got %v, %v", n, err)
+		}
+		refs, err := gateway.ledgerStore.DataRefCount(dataHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refs != 0 {
+			t.Fatalf("expected purged data to be unreferenced, but got %v references", refs)
+		}
+		if _, err := gateway.SetBucketTrash(ctx, &SetBucketTrashRequest{Bucket: testBucket1}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("CloneBucket", func(t *testing.T) {
+		const cloned = "clonedbucket"
+		srcObjects, _, err := gateway.ledgerStore.GetObjectInfos(ctx, testBucket1, "", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(srcObjects) == 0 {
+			t.Fatal("expected objects to clone")
+		}
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, srcObjects[0].Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs, err := gateway.ledgerStore.DataRefCount(dataHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name     string
+			req      *CloneBucketRequest
+			wantCode codes.Code
+		}{
+			{"Fail-Empty-Bucket", &CloneBucketRequest{NewBucket: cloned}, codes.InvalidArgument},
+			{"Fail-Same-Name", &CloneBucketRequest{Bucket: testBucket1, NewBucket: testBucket1}, codes.InvalidArgument},
+			{"Fail-No-Bucket", &CloneBucketRequest{Bucket: "nosuchbucket", NewBucket: cloned}, codes.NotFound},
+			{"Success", &CloneBucketRequest{Bucket: testBucket1, NewBucket: cloned, CopyConfig: true}, codes.OK},
+			{"Fail-Bucket-Exists", &CloneBucketRequest{Bucket: testBucket1, NewBucket: cloned}, codes.AlreadyExists},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, err := gateway.CloneBucket(ctx, tt.req)
+				if code := status.Code(err); code != tt.wantCode {
+					t.Fatalf("CloneBucket() err %v, want code %v", err, tt.wantCode)
+				}
+				if err == nil && resp.GetObjects() != int64(len(srcObjects)) {
+					t.Fatalf("expected %v cloned objects, but got %v", len(srcObjects), resp.GetObjects())
+				}
+			})
+		}
+		clonedObjects, _, err := gateway.ledgerStore.GetObjectInfos(ctx, cloned, "", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(clonedObjects) != len(srcObjects) {
+			t.Fatalf("expected %v objects in clone, but got %v", len(srcObjects), len(clonedObjects))
+		}
+		if n, err := gateway.ledgerStore.DataRefCount(dataHash); err != nil || n != refs+1 {
+			t.Fatalf("expected %v references to cloned data, but got %v, %v", refs+1, n, err)
+		}
+		if err := gateway.DeleteObject(ctx, cloned, srcObjects[0].Name); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, srcObjects[0].Name, minio.ObjectOptions{}); err != nil {
+			t.Fatalf("expected source object to survive deletion from clone, but got %v", err)
+		}
+	})
+	t.Run("GetObjectProof", func(t *testing.T) {
+		if _, err := gateway.GetObjectProof(ctx, &ObjectProofRequest{Bucket: testBucket1, Object: "nosuchobject"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("GetObjectProof() err %v, want code %v", err, codes.NotFound)
+		}
+		objects, _, err := gateway.ledgerStore.GetObjectInfos(ctx, testBucket1, "", "", 1)
+		if err != nil || len(objects) == 0 {
+			t.Fatalf("expected an object to prove, but got %v, %v", objects, err)
+		}
+		proof, err := gateway.GetObjectProof(ctx, &ObjectProofRequest{Bucket: testBucket1, Object: objects[0].Name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bucketHash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyObjectProof(bucketHash, proof); err != nil {
+			t.Fatal(err)
+		}
+		dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, objects[0].Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.GetDataHash() != dataHash {
+			t.Fatalf("expected data hash %v, but got %v", dataHash, proof.GetDataHash())
+		}
+		tampered := *proof
+		tampered.DataHash = "tampered"
+		if err := VerifyObjectProof(bucketHash, &tampered); err == nil {
+			t.Fatal("expected tampered data hash to fail verification")
+		}
+		tampered = *proof
+		tampered.ObjectBlock = append([]byte{}, proof.GetObjectBlock()...)
+		tampered.ObjectBlock[0] ^= 0xff
+		if err := VerifyObjectProof(bucketHash, &tampered); err == nil {
+			t.Fatal("expected tampered object block to fail verification")
+		}
+		if err := VerifyObjectProof("otherhash", proof); err == nil {
+			t.Fatal("expected proof for another bucket hash to fail verification")
+		}
+	})
+}
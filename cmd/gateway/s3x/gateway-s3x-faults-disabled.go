@@ -0,0 +1,6 @@
+// +build !faults
+
+package s3x
+
+// faultsBuild is false in builds without the faults build tag, which refuse to start with --faults rules
+const faultsBuild = false
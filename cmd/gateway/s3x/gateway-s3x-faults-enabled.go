@@ -0,0 +1,6 @@
+// +build faults
+
+package s3x
+
+// faultsBuild is true in builds with the faults build tag, which inject the faults of the --faults rules
+const faultsBuild = true
@@ -0,0 +1,487 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Backend failures are hard to reproduce: TemporalX and the ledger datastore rarely fail on demand, and tools that
+break the network or the disk of a gateway are not available in every CI. Gateways built with the faults build tag
+inject faults into their own backends, as configured by the --faults rules, so operators and CI can check how the
+gateway, its retries, deadlines, readiness, and metrics behave while a backend fails. Builds without the tag refuse
+to start with fault rules, so a production gateway can not be degraded by a stray flag.
+
+A rule applies to a layer, temporalx or datastore, or to one operation of it, and has any of an error rate, a
+latency, and a partial failure rate. The rule of an operation replaces the rule of its layer:
+
+	temporalx:error=0.05,latency=50ms;temporalx.DownloadFile:partial=0.2;datastore.put:error=0.01
+
+The operations of temporalx are the names of the gRPC methods, such as DagGet, Persist, UploadFile, and
+DownloadFile, and the faults are injected by interceptors of the connections to TemporalX, below the resumption
+of streams and the call deadlines, so both handle injected faults like real ones. An injected error is the
+Unavailable gRPC code, a partial failure fails a stream with it after a few messages. The operations of the
+datastore are those of the datastore metrics, get, has, get_size, put, delete, query, commit, and sync, and the
+faults are injected below the instrumentation of the ledger datastore, so the metrics and the error budget count
+them. A partial failure fails a query after its first result, and applies part of the writes of a batch without
+committing it atomically, like a crash during a commit.
+
+Every injected fault is counted by layer, operation, and kind in s3x_faults_injected_total. The faults are drawn
+from a random source seeded with --faults.seed, so a failing run can be repeated.
+*/
+
+// Layers that faults are injected into
+const (
+	faultLayerTemporalX = "temporalx"
+	faultLayerDatastore = "datastore"
+)
+
+// Kinds of injected faults
+const (
+	faultError   = "error"
+	faultLatency = "latency"
+	faultPartial = "partial"
+)
+
+// faultPartialMaxMessages is the largest number of messages a stream transfers before a partial failure
+const faultPartialMaxMessages = 4
+
+var (
+	// errFaultsUnsupported is returned when fault rules are configured for a build without the faults tag
+	errFaultsUnsupported = errors.New("fault injection requires a gateway built with the faults build tag")
+	// errInjectedFault is the error of datastore operations that fail by fault injection
+	errInjectedFault = errors.New("injected datastore fault")
+)
+
+var faultsInjectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "s3x",
+	Subsystem: "faults",
+	Name:      "injected_total",
+	Help:      "Total number of injected faults by layer, operation, and kind",
+}, []string{"layer", "op", "fault"})
+
+func init() {
+	prometheus.MustRegister(faultsInjectedTotal)
+}
+
+// faultRule is the faults injected into the operations of a layer or of one operation
+type faultRule struct {
+	// errorRate is the share of operations that fail
+	errorRate float64
+	// latency is added to every operation
+	latency time.Duration
+	// partialRate is the share of streams, queries, and batches that fail after a part of them
+	partialRate float64
+}
+
+// faultInjector injects faults into the operations of TemporalX and of the ledger datastore
+type faultInjector struct {
+	// rules are the rules by layer, and by layer.op for the rules of one operation
+	rules map[string]faultRule
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// newFaultInjector returns the fault injector of the rules, nil if there are no rules.
+// The faults are drawn from a random source seeded with seed, or with the time if 0.
+func newFaultInjector(rules string, seed int64) (*faultInjector, error) {
+	if strings.TrimSpace(rules) == "" {
+		return nil, nil
+	}
+	if !faultsBuild {
+		return nil, errFaultsUnsupported
+	}
+	return parseFaultRules(rules, seed)
+}
+
+// parseFaultRules parses rules separated by semicolons, each a layer or layer.op followed by a colon and
+// comma separated error, latency, and partial settings
+func parseFaultRules(rules string, seed int64) (*faultInjector, error) {
+	f := &faultInjector{rules: make(map[string]faultRule)}
+	for _, r := range strings.Split(rules, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		target, settings := r, ""
+		if i := strings.Index(r, ":"); i >= 0 {
+			target, settings = r[:i], r[i+1:]
+		}
+		layer := target
+		if i := strings.Index(target, "."); i >= 0 {
+			layer = target[:i]
+			if target[i+1:] == "" {
+				return nil, fmt.Errorf("fault rule %q has no operation", r)
+			}
+		}
+		if layer != faultLayerTemporalX && layer != faultLayerDatastore {
+			return nil, fmt.Errorf("fault rule %q has an unknown layer, expected %v or %v", r, faultLayerTemporalX, faultLayerDatastore)
+		}
+		if _, ok := f.rules[target]; ok {
+			return nil, fmt.Errorf("fault rule %q is set twice", target)
+		}
+		var rule faultRule
+		for _, s := range strings.Split(settings, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			kv := strings.SplitN(s, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("fault rule %q has an invalid setting %q", r, s)
+			}
+			var err error
+			switch kv[0] {
+			case faultError:
+				rule.errorRate, err = parseFaultRate(kv[1])
+			case faultPartial:
+				rule.partialRate, err = parseFaultRate(kv[1])
+			case faultLatency:
+				if rule.latency, err = time.ParseDuration(kv[1]); err == nil && rule.latency < 0 {
+					err = errors.New("latency can not be negative")
+				}
+			default:
+				err = fmt.Errorf("unknown setting, expected %v, %v, or %v", faultError, faultLatency, faultPartial)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("fault rule %q has an invalid setting %q: %v", r, s, err)
+			}
+		}
+		f.rules[target] = rule
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	f.rnd = rand.New(rand.NewSource(seed))
+	return f, nil
+}
+
+// parseFaultRate parses the share of operations a fault is injected into
+func parseFaultRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, errors.New("rate must be between 0 and 1")
+	}
+	return rate, nil
+}
+
+// rule returns the rule of an operation of a layer, and false if no faults are injected into it
+func (f *faultInjector) rule(layer, op string) (faultRule, bool) {
+	if f == nil {
+		return faultRule{}, false
+	}
+	if rule, ok := f.rules[layer+"."+op]; ok {
+		return rule, true
+	}
+	rule, ok := f.rules[layer]
+	return rule, ok
+}
+
+// draw returns true with the probability rate
+func (f *faultInjector) draw(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+// intn returns a random number in [0, n)
+func (f *faultInjector) intn(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Intn(n)
+}
+
+// inject waits for the latency of an operation, and returns true if the operation must fail
+func (f *faultInjector) inject(ctx context.Context, layer, op string, rule faultRule) (bool, error) {
+	if rule.latency > 0 {
+		faultsInjectedTotal.WithLabelValues(layer, op, faultLatency).Inc()
+		timer := time.NewTimer(rule.latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		}
+	}
+	if f.draw(rule.errorRate) {
+		faultsInjectedTotal.WithLabelValues(layer, op, faultError).Inc()
+		return true, nil
+	}
+	return false, nil
+}
+
+// partial returns the number of messages or entries after which an operation fails, and false if it does not
+func (f *faultInjector) partial(layer, op string, rule faultRule, max int) (int, bool) {
+	if !f.draw(rule.partialRate) {
+		return 0, false
+	}
+	faultsInjectedTotal.WithLabelValues(layer, op, faultPartial).Inc()
+	return 1 + f.intn(max), true
+}
+
+// injectedCallErr returns the error of a TemporalX call that fails by fault injection
+func injectedCallErr(method string) error {
+	return status.Errorf(codes.Unavailable, "injected fault in %v", method)
+}
+
+// dialOptions returns the interceptors that inject faults into the calls of a connection, none if f is nil
+func (f *faultInjector) dialOptions() []grpc.DialOption {
+	if f == nil {
+		return nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(f.unary),
+		grpc.WithChainStreamInterceptor(f.stream),
+	}
+}
+
+// unary injects faults into a unary call
+func (f *faultInjector) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	op := path.Base(method)
+	rule, ok := f.rule(faultLayerTemporalX, op)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	fail, err := f.inject(ctx, faultLayerTemporalX, op, rule)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if fail {
+		return injectedCallErr(method)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// stream injects faults into a stream, a partial failure fails the stream after a few messages
+func (f *faultInjector) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	op := path.Base(method)
+	rule, ok := f.rule(faultLayerTemporalX, op)
+	if !ok {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	fail, err := f.inject(ctx, faultLayerTemporalX, op, rule)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if fail {
+		return nil, injectedCallErr(method)
+	}
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if failAfter, ok := f.partial(faultLayerTemporalX, op, rule, faultPartialMaxMessages); ok {
+		return &injectingStream{ClientStream: cs, method: method, failAfter: failAfter}, nil
+	}
+	return cs, nil
+}
+
+// injectingStream fails once failAfter messages were sent or received
+type injectingStream struct {
+	grpc.ClientStream
+	method    string
+	failAfter int
+	messages  int
+}
+
+// SendMsg implements grpc.ClientStream
+func (s *injectingStream) SendMsg(m interface{}) error {
+	if s.messages >= s.failAfter {
+		return injectedCallErr(s.method)
+	}
+	s.messages++
+	return s.ClientStream.SendMsg(m)
+}
+
+// RecvMsg implements grpc.ClientStream
+func (s *injectingStream) RecvMsg(m interface{}) error {
+	if s.messages >= s.failAfter {
+		return injectedCallErr(s.method)
+	}
+	s.messages++
+	return s.ClientStream.RecvMsg(m)
+}
+
+// datastore returns ds with faults injected into its operations, ds if f is nil or has no datastore rules
+func (f *faultInjector) datastore(ds datastore.Batching) datastore.Batching {
+	if f == nil {
+		return ds
+	}
+	for target := range f.rules {
+		if strings.HasPrefix(target, faultLayerDatastore) {
+			return &injectingDatastore{ds: ds, f: f}
+		}
+	}
+	return ds
+}
+
+// injectingDatastore injects faults into the operations on a datastore
+type injectingDatastore struct {
+	ds datastore.Batching
+	f  *faultInjector
+}
+
+// inject returns errInjectedFault if an operation must fail
+func (d *injectingDatastore) inject(op string) (faultRule, error) {
+	rule, ok := d.f.rule(faultLayerDatastore, op)
+	if !ok {
+		return rule, nil
+	}
+	// datastore operations have no context, their latency can not be canceled
+	fail, _ := d.f.inject(context.Background(), faultLayerDatastore, op, rule)
+	if fail {
+		return rule, errInjectedFault
+	}
+	return rule, nil
+}
+
+// Get implements datastore.Read
+func (d *injectingDatastore) Get(key datastore.Key) ([]byte, error) {
+	if _, err := d.inject("get"); err != nil {
+		return nil, err
+	}
+	return d.ds.Get(key)
+}
+
+// Has implements datastore.Read
+func (d *injectingDatastore) Has(key datastore.Key) (bool, error) {
+	if _, err := d.inject("has"); err != nil {
+		return false, err
+	}
+	return d.ds.Has(key)
+}
+
+// GetSize implements datastore.Read
+func (d *injectingDatastore) GetSize(key datastore.Key) (int, error) {
+	if _, err := d.inject("get_size"); err != nil {
+		return -1, err
+	}
+	return d.ds.GetSize(key)
+}
+
+// Query implements datastore.Read, a partial failure fails the query after its first result
+func (d *injectingDatastore) Query(q query.Query) (query.Results, error) {
+	rule, err := d.inject("query")
+	if err != nil {
+		return nil, err
+	}
+	results, err := d.ds.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := d.f.partial(faultLayerDatastore, "query", rule, 1); !ok {
+		return results, nil
+	}
+	read := 0
+	return query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			if read > 0 {
+				return query.Result{Error: errInjectedFault}, true
+			}
+			r, ok := results.NextSync()
+			read++
+			return r, ok
+		},
+		Close: results.Close,
+	}), nil
+}
+
+// Put implements datastore.Write
+func (d *injectingDatastore) Put(key datastore.Key, value []byte) error {
+	if _, err := d.inject("put"); err != nil {
+		return err
+	}
+	return d.ds.Put(key, value)
+}
+
+// Delete implements datastore.Write
+func (d *injectingDatastore) Delete(key datastore.Key) error {
+	if _, err := d.inject("delete"); err != nil {
+		return err
+	}
+	return d.ds.Delete(key)
+}
+
+// Sync implements datastore.Datastore
+func (d *injectingDatastore) Sync(prefix datastore.Key) error {
+	if _, err := d.inject("sync"); err != nil {
+		return err
+	}
+	return d.ds.Sync(prefix)
+}
+
+// Close implements io.Closer
+func (d *injectingDatastore) Close() error {
+	return d.ds.Close()
+}
+
+// Batch implements datastore.Batching, the faults of a batch are injected when it is committed
+func (d *injectingDatastore) Batch() (datastore.Batch, error) {
+	b, err := d.ds.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &injectingBatch{Batch: b, d: d}, nil
+}
+
+// injectingBatch records the writes of a batch, so a partial failure can apply a part of them
+type injectingBatch struct {
+	datastore.Batch
+	d      *injectingDatastore
+	writes []func() error
+}
+
+// Put implements datastore.Write
+func (b *injectingBatch) Put(key datastore.Key, value []byte) error {
+	b.writes = append(b.writes, func() error { return b.d.ds.Put(key, value) })
+	return b.Batch.Put(key, value)
+}
+
+// Delete implements datastore.Write
+func (b *injectingBatch) Delete(key datastore.Key) error {
+	b.writes = append(b.writes, func() error { return b.d.ds.Delete(key) })
+	return b.Batch.Delete(key)
+}
+
+// Commit implements datastore.Batch, a partial failure applies a part of the writes one at a time and fails
+func (b *injectingBatch) Commit() error {
+	rule, err := b.d.inject("commit")
+	if err != nil {
+		return err
+	}
+	if len(b.writes) == 0 {
+		return b.Batch.Commit()
+	}
+	n, ok := b.d.f.partial(faultLayerDatastore, "commit", rule, len(b.writes))
+	if !ok {
+		return b.Batch.Commit()
+	}
+	// the batch is not committed, and at most all but one write is applied
+	for _, write := range b.writes[:n-1] {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	return errInjectedFault
+}
@@ -0,0 +1,199 @@
+package s3x
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseFaultRules(t *testing.T) {
+	f, err := parseFaultRules(" temporalx:error=0.5,latency=10ms ; temporalx.DownloadFile:partial=1;datastore.put:error=1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		layer, op string
+		want      faultRule
+		ok        bool
+	}{
+		{faultLayerTemporalX, "DagGet", faultRule{errorRate: 0.5, latency: 10 * time.Millisecond}, true},
+		// the rule of an operation replaces the rule of its layer
+		{faultLayerTemporalX, "DownloadFile", faultRule{partialRate: 1}, true},
+		{faultLayerDatastore, "put", faultRule{errorRate: 1}, true},
+		{faultLayerDatastore, "get", faultRule{}, false},
+	} {
+		if rule, ok := f.rule(tt.layer, tt.op); rule != tt.want || ok != tt.ok {
+			t.Fatalf("%v.%v: expected %+v %v, got %+v %v", tt.layer, tt.op, tt.want, tt.ok, rule, ok)
+		}
+	}
+	for _, rules := range []string{
+		"disk:error=1",
+		"temporalx.:error=1",
+		"temporalx:error=2",
+		"temporalx:latency=-1s",
+		"temporalx:latency",
+		"temporalx:timeout=1s",
+		"datastore:error=0.1;datastore:error=0.2",
+	} {
+		if _, err := parseFaultRules(rules, 1); err == nil {
+			t.Fatalf("expected %q to be invalid", rules)
+		}
+	}
+	// no rules inject no faults in any build
+	if f, err := newFaultInjector(" ", 0); f != nil || err != nil {
+		t.Fatalf("expected no fault injector, got %v %v", f, err)
+	}
+	if _, err := newFaultInjector("temporalx:error=1", 0); faultsBuild != (err == nil) {
+		t.Fatalf("expected fault rules to be supported only by builds with the faults tag, got %v", err)
+	}
+}
+
+func TestFaultInjector_Datastore(t *testing.T) {
+	f, err := parseFaultRules("datastore.put:error=1;datastore.query:partial=1;datastore.commit:partial=1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := f.datastore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	if err := ds.Put(datastore.NewKey("a"), []byte("a")); err != errInjectedFault {
+		t.Fatalf("expected %v, got %v", errInjectedFault, err)
+	}
+	// a partial commit applies a part of the writes
+	b, err := ds.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := b.Put(datastore.NewKey(key), []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Commit(); err != errInjectedFault {
+		t.Fatalf("expected %v, got %v", errInjectedFault, err)
+	}
+	applied := 0
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if has, err := ds.Has(datastore.NewKey(key)); err != nil {
+			t.Fatal(err)
+		} else if has {
+			applied++
+		}
+	}
+	if applied == 4 {
+		t.Fatal("expected a part of the writes to be applied")
+	}
+	// a partial query fails after its first result, if it has one
+	if applied > 0 {
+		results, err := ds.Query(query.Query{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := results.Rest(); err != errInjectedFault {
+			t.Fatalf("expected %v, got %v", errInjectedFault, err)
+		}
+	}
+	// datastores without datastore rules are not wrapped
+	f, err = parseFaultRules("temporalx:error=1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain := datastore.NewMapDatastore(); f.datastore(plain) != plain {
+		t.Fatal("expected the datastore to be returned as is")
+	}
+}
+
+// messageStream is a client stream of endless messages
+type messageStream struct {
+	grpc.ClientStream
+}
+
+func (messageStream) SendMsg(m interface{}) error { return nil }
+func (messageStream) RecvMsg(m interface{}) error { return nil }
+
+func TestFaultInjector_TemporalX(t *testing.T) {
+	ctx := context.Background()
+	f, err := parseFaultRules("temporalx.DagGet:error=1;temporalx.Persist:latency=1h;temporalx.DownloadFile:partial=1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoked := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		invoked++
+		return nil
+	}
+	if err := f.unary(ctx, "/pb.NodeAPI/DagGet", nil, nil, nil, invoker); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected an injected Unavailable error, got %v", err)
+	}
+	if err := f.unary(ctx, "/pb.NodeAPI/DagPut", nil, nil, nil, invoker); err != nil || invoked != 1 {
+		t.Fatalf("expected calls without rules to be invoked, got %v", err)
+	}
+	// the latency ends with the deadline of the call
+	deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := f.unary(deadline, persistMethod, nil, nil, nil, invoker); status.Code(err) != codes.DeadlineExceeded || invoked != 1 {
+		t.Fatalf("expected the call to exceed its deadline, got %v", err)
+	}
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return messageStream{}, nil
+	}
+	cs, err := f.stream(ctx, &grpc.StreamDesc{}, nil, "/pb.FileAPI/DownloadFile", streamer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	for ; received <= faultPartialMaxMessages; received++ {
+		if err := cs.RecvMsg(nil); err != nil {
+			if status.Code(err) != codes.Unavailable {
+				t.Fatalf("expected an injected Unavailable error, got %v", err)
+			}
+			break
+		}
+	}
+	if received == 0 || received > faultPartialMaxMessages {
+		t.Fatalf("expected the stream to fail after 1 to %v messages, got %v", faultPartialMaxMessages, received)
+	}
+	if opts := (*faultInjector)(nil).dialOptions(); len(opts) != 0 {
+		t.Fatal("expected no interceptors without rules")
+	}
+}
+
+func TestS3X_Faults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3x-faults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	xaddr := os.Getenv("TEST_XAPI")
+	if xaddr == "" {
+		xaddr = "xapi.temporal.cloud:9090"
+	}
+	temx := &TEMX{
+		DSType:   DSTypeBadger,
+		DSPath:   dir,
+		XAddr:    xaddr,
+		Insecure: true,
+		Faults:   "datastore.put:error=1",
+	}
+	_, err = temx.getXObjects(auth.Credentials{})
+	if !faultsBuild {
+		if err != errFaultsUnsupported {
+			t.Fatalf("expected %v, got %v", errFaultsUnsupported, err)
+		}
+		return
+	}
+	// the startup health check writes to the datastore
+	if err == nil || !strings.Contains(err.Error(), errInjectedFault.Error()) {
+		t.Fatalf("expected the injected fault to fail the startup, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+// defaultGCGrace is how long data stays unreferenced before it is collected by default
+const defaultGCGrace = 24 * time.Hour
+
+// CollectGarbage removes the data of unreferenced data hashes from TemporalX, except the data of locked objects,
+// or reports what would be removed in a dry run.
+func (x *xObjects) CollectGarbage(ctx context.Context, req *CollectGarbageRequest) (*CollectGarbageResponse, error) {
+	gc, err := x.collectGarbage(ctx, x.clock.Now(), req.GetDryRun())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("dry-run: %v, collected: %v, held: %v, pending: %v", req.GetDryRun(), len(gc.collected), len(gc.held), gc.pending)
+	return &CollectGarbageResponse{
+		DryRun:    req.GetDryRun(),
+		Collected: gc.collected,
+		Held:      gc.held,
+		Pending:   gc.pending,
+	}, nil
+}
+
+func (x *xObjects) collectGarbage(ctx context.Context, now time.Time, dryRun bool) (*garbageCollection, error) {
+	return x.ledgerStore.CollectGarbage(ctx, now, x.gcGrace, dryRun, func(hash string) error {
+		return x.removeData(ctx, hash)
+	})
+}
+
+// removeData removes the root block of object data from TemporalX, and from the cold cluster if the data was moved
+// there, so the rest of its blocks are unlinked
+func (x *xObjects) removeData(ctx context.Context, hash string) error {
+	if err := removeBlock(ctx, x.dagClient, hash); err != nil {
+		return err
+	}
+	c, cold, err := x.ledgerStore.ColdData(hash)
+	if err != nil || !cold {
+		return err
+	}
+	if x.cold == nil {
+		return fmt.Errorf("data %v was moved to %v: %w", hash, c.Node, errColdNotConfigured)
+	}
+	return removeBlock(ctx, x.cold.dagClient, hash)
+}
+
+// removeBlock removes a block from a TemporalX node
+func removeBlock(ctx context.Context, dagClient pb.NodeAPIClient, hash string) error {
+	_, err := dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+		RequestType: pb.BSREQTYPE_BS_DELETE,
+		Cids:        []string{hash},
+	})
+	return err
+}
+
+// saveFileLinks saves a unixfs file node that concatenates the data of the given hashes, like ipfsSaveFileLinks,
+// and records its links in the ledger, so the linked data is not collected while the file node is in use
+func (x *xObjects) saveFileLinks(ctx context.Context, hashes []string, sizes []uint64) (string, uint64, error) {
+	hash, size, err := ipfsSaveFileLinks(ctx, x.dagClient, hashes, sizes)
+	if err != nil {
+		return "", 0, err
+	}
+	return hash, size, x.ledgerStore.AddDataLinks(hash, hashes)
+}
+
+// gcLoop collects unreferenced data every interval until the gateway is shut down
+func (x *xObjects) gcLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			gc, err := x.collectGarbage(x.ctx, now, false)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to collect garbage: %v", err)
+			}
+			if gc != nil && (len(gc.collected) > 0 || len(gc.held) > 0) {
+				log.Printf("collected %v unreferenced data hashes, %v held by locked objects", len(gc.collected), len(gc.held))
+			}
+		}
+	}
+}
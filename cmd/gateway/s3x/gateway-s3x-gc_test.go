@@ -0,0 +1,138 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	now := gateway.clock.Now()
+	hashes := make(map[string]string)
+	put := func(object, data string, meta map[string]string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{UserDefined: meta}); err != nil {
+			t.Fatal(err)
+		}
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[object] = hash
+		if err := gateway.DeleteObject(ctx, testBucket1, object); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("free", "unreferenced and unlocked data", nil)
+	put("legal", "unreferenced data under a legal hold", map[string]string{"X-Amz-Object-Lock-Legal-Hold": "ON"})
+	put("retained", "unreferenced data under a retention", map[string]string{
+		"X-Amz-Object-Lock-Mode":              "GOVERNANCE",
+		"X-Amz-Object-Lock-Retain-Until-Date": now.Add(48 * time.Hour).Format(time.RFC3339),
+	})
+	stored := func(hash string) bool {
+		t.Helper()
+		resp, err := gateway.dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+			RequestType: pb.BSREQTYPE_BS_HAS,
+			Cids:        []string{hash},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(resp.GetBlocks()) == 1
+	}
+
+	// data is kept for the grace period
+	gc, err := gateway.collectGarbage(ctx, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.pending != 3 || len(gc.collected) != 0 || len(gc.held) != 0 {
+		t.Fatalf("expected all data to be pending, but got %+v", gc)
+	}
+
+	// a dry run reports without removing anything
+	gc, err = gateway.collectGarbage(ctx, now.Add(25*time.Hour), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gc.collected) != 1 || gc.collected[0] != hashes["free"] || len(gc.held) != 2 {
+		t.Fatalf("unexpected dry run %+v", gc)
+	}
+	for _, h := range gc.held {
+		switch h.Object {
+		case "legal":
+			if !h.LegalHold || h.RetainUntil != 0 || h.DataHash != hashes["legal"] {
+				t.Fatalf("unexpected hold %+v", h)
+			}
+		case "retained":
+			if h.LegalHold || h.RetainUntil != now.Add(48*time.Hour).Unix() || h.DataHash != hashes["retained"] {
+				t.Fatalf("unexpected hold %+v", h)
+			}
+		default:
+			t.Fatalf("unexpected hold %+v", h)
+		}
+	}
+	if !stored(hashes["free"]) {
+		t.Fatal("expected a dry run to keep the data")
+	}
+
+	gc, err = gateway.collectGarbage(ctx, now.Add(25*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gc.collected) != 1 || len(gc.held) != 2 {
+		t.Fatalf("unexpected collection %+v", gc)
+	}
+	if stored(hashes["free"]) || !stored(hashes["legal"]) || !stored(hashes["retained"]) {
+		t.Fatal("expected only the unlocked data to be removed")
+	}
+	garbage, err := gateway.ledgerStore.GarbageData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := garbage[hashes["free"]]; ok || len(garbage) != 2 {
+		t.Fatalf("expected the collected data to leave the queue, but got %v", garbage)
+	}
+
+	// data is collected once its retention ends
+	gc, err = gateway.collectGarbage(ctx, now.Add(72*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gc.collected) != 1 || gc.collected[0] != hashes["retained"] || len(gc.held) != 1 || gc.held[0].Object != "legal" {
+		t.Fatalf("unexpected collection %+v", gc)
+	}
+
+	// data that is referenced again is not collected
+	if _, err := gateway.PutObject(ctx, testBucket1, "again", getTestPutObjectReader(t, []byte("unreferenced data under a legal hold")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gc, err = gateway.collectGarbage(ctx, now.Add(72*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gc.collected) != 0 || len(gc.held) != 0 || gc.pending != 0 {
+		t.Fatalf("expected no garbage, but got %+v", gc)
+	}
+
+	resp, err := gateway.CollectGarbage(ctx, &CollectGarbageRequest{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetDryRun() || len(resp.GetCollected()) != 0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
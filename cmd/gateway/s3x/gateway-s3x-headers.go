@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	bucketheaders "github.com/RTradeLtd/s3x/pkg/bucket/headers"
+)
+
+/* Design Notes
+---------------
+
+Operators front buckets with static response headers, such as security headers or CORS headers beyond what the
+CORS configuration sets, and require request headers, such as a tenant header set by a front door proxy, without
+running a separate proxy. The rules are kept in the json file of --headers.config, each for a bucket or all
+buckets, and optionally a prefix of object names. The gateway exposes them through BucketHeadersConfigurer, and
+the HTTP layer sets the response headers of the rules that apply to a request, and denies it with AccessDenied if
+it lacks a required header, before the request is authenticated. An invalid file fails the startup. The file is
+read again on SIGHUP, and a file that became invalid is logged and the previous rules are kept.
+*/
+
+// bucketHeaders are the header rules of buckets loaded from a file
+type bucketHeaders struct {
+	path string
+
+	mu     sync.RWMutex
+	config *bucketheaders.Config
+}
+
+// loadBucketHeaders reads the header rules of buckets from the json file at path
+func loadBucketHeaders(path string) (*bucketHeaders, error) {
+	h := &bucketHeaders{path: path}
+	if err := h.reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// reload reads the rules from the file again, the rules are kept if the file is invalid
+func (h *bucketHeaders) reload() error {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	config, err := bucketheaders.ParseConfig(f)
+	if err != nil {
+		return fmt.Errorf("invalid header configuration %s: %v", h.path, err)
+	}
+	h.mu.Lock()
+	h.config = config
+	h.mu.Unlock()
+	return nil
+}
+
+// BucketHeaders returns the header rules of buckets, nil if the gateway has none
+func (x *xObjects) BucketHeaders() *bucketheaders.Config {
+	if x.headers == nil {
+		return nil
+	}
+	x.headers.mu.RLock()
+	defer x.headers.mu.RUnlock()
+	return x.headers.config
+}
+
+// headersReloadLoop reads the header rules again on every SIGHUP, until the gateway is shut down
+func (x *xObjects) headersReloadLoop() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-hup:
+		}
+		if err := x.headers.reload(); err != nil {
+			log.Printf("headers: reload failed, keeping the previous rules, error: %v", err)
+			continue
+		}
+		log.Printf("headers: reloaded, path: %s", x.headers.path)
+	}
+}
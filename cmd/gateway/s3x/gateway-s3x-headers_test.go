@@ -0,0 +1,55 @@
+package s3x
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+var _ minio.BucketHeadersConfigurer = (*xObjects)(nil)
+
+func TestS3X_BucketHeaders(t *testing.T) {
+	x := &xObjects{}
+	if x.BucketHeaders() != nil {
+		t.Fatal("expected no header rules by default")
+	}
+	dir, err := ioutil.TempDir("", "s3x-headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "headers.json")
+	if _, err := loadBucketHeaders(path); err == nil {
+		t.Fatal("expected a missing file to fail")
+	}
+	write := func(config string) {
+		if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"rules": [{"bucket": "*", "responseHeaders": {"x-frame-options": "DENY"}}]}`)
+	if x.headers, err = loadBucketHeaders(path); err != nil {
+		t.Fatal(err)
+	}
+	if rules := x.BucketHeaders().Rules; len(rules) != 1 || rules[0].ResponseHeaders["X-Frame-Options"] != "DENY" {
+		t.Fatalf("expected the rules of the file, got %+v", rules)
+	}
+	// an invalid file keeps the previous rules
+	write(`{"rules": [{"bucket": "*", "responseHeaders": {"Content-Type": "text/html"}}]}`)
+	if err := x.headers.reload(); err == nil {
+		t.Fatal("expected a reserved response header to be invalid")
+	}
+	if rules := x.BucketHeaders().Rules; len(rules) != 1 || rules[0].ResponseHeaders["X-Frame-Options"] != "DENY" {
+		t.Fatalf("expected the previous rules, got %+v", rules)
+	}
+	write(`{"rules": [{"bucket": "testbucket", "requiredRequestHeaders": [{"name": "x-tenant"}]}]}`)
+	if err := x.headers.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if rules := x.BucketHeaders().Rules; len(rules) != 1 || rules[0].Bucket != "testbucket" {
+		t.Fatalf("expected the reloaded rules, got %+v", rules)
+	}
+}
@@ -0,0 +1,51 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+)
+
+/* Design Notes
+---------------
+
+The gateway validates its backends once at startup instead of failing on the first request:
+the ledger datastore must round trip a probe value, and TemporalX must store and return a dag node.
+A failure is returned from NewGatewayLayer, so the gateway exits with the cause instead of panicking
+or serving requests it can not complete.
+*/
+
+// startupHealthTimeout bounds the startup health check, so an unreachable TemporalX does not block startup
+const startupHealthTimeout = 30 * time.Second
+
+// dsHealthKey is written and removed by the startup health check
+var dsHealthKey = datastore.NewKey("health")
+
+// checkHealth verifies that the ledger datastore and the TemporalX dag api are usable
+func (ls *ledgerStore) checkHealth(ctx context.Context) error {
+	probe := []byte(time.Now().UTC().String())
+	if err := ls.ds.Put(dsHealthKey, probe); err != nil {
+		return errors.Wrap(err, "ledger datastore is not writable")
+	}
+	data, err := ls.ds.Get(dsHealthKey)
+	if err != nil {
+		return errors.Wrap(err, "ledger datastore is not readable")
+	}
+	if !bytes.Equal(data, probe) {
+		return errors.New("ledger datastore returned a different value than written")
+	}
+	if err := ls.ds.Delete(dsHealthKey); err != nil {
+		return errors.Wrap(err, "ledger datastore can not delete")
+	}
+	h, err := ipfsSave(ctx, ls.dag, &Bucket{})
+	if err != nil {
+		return errors.Wrap(err, "temporalx dag api is not writable")
+	}
+	if _, err := ipfsBucket(ctx, ls.dag, h); err != nil {
+		return errors.Wrap(err, "temporalx dag api is not readable")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package s3x
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"google.golang.org/grpc"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultyDatastore fails writes while failPut is set, or writes of the ledger keys under failPrefix if set,
+// and discards batches instead of committing them while failCommit is set
+type faultyDatastore struct {
+	datastore.Batching
+	failPut    bool
+	failPrefix datastore.Key
+	failCommit bool
+}
+
+func (f *faultyDatastore) Put(key datastore.Key, value []byte) error {
+	if f.failPut || (f.failPrefix.String() != "" && dsPrefix.Child(f.failPrefix).IsAncestorOf(key)) {
+		return errInjected
+	}
+	return f.Batching.Put(key, value)
+}
+
+// Batch returns a batch that writes through Put, so batched writes fail like other writes
+func (f *faultyDatastore) Batch() (datastore.Batch, error) {
+	if f.failCommit {
+		return faultyBatch{datastore.NewBasicBatch(f)}, nil
+	}
+	return datastore.NewBasicBatch(f), nil
+}
+
+// faultyBatch is a batch that is lost before it is committed
+type faultyBatch struct {
+	datastore.Batch
+}
+
+func (faultyBatch) Commit() error {
+	return errInjected
+}
+
+// memoryDag is an in memory dag api, that fails every request while fail is set,
+// or returns no hashes for puts while noHashes is set
+type memoryDag struct {
+	pb.NodeAPIClient
+	mu       sync.Mutex
+	nodes    map[string][]byte
+	fail     bool
+	noHashes bool
+}
+
+func newMemoryDag() *memoryDag {
+	return &memoryDag{nodes: make(map[string][]byte)}
+}
+
+func (m *memoryDag) Dag(ctx context.Context, req *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return nil, errInjected
+	}
+	switch req.GetRequestType() {
+	case pb.DAGREQTYPE_DAG_PUT:
+		if m.noHashes {
+			return &pb.DagResponse{}, nil
+		}
+		sum := sha256.Sum256(req.GetData())
+		h := hex.EncodeToString(sum[:])
+		m.nodes[h] = req.GetData()
+		return &pb.DagResponse{Hashes: []string{h}}, nil
+	case pb.DAGREQTYPE_DAG_GET:
+		data, ok := m.nodes[req.GetHash()]
+		if !ok {
+			return nil, errors.New("node not found")
+		}
+		return &pb.DagResponse{RawData: data}, nil
+	}
+	return nil, errors.New("unsupported dag request")
+}
+
+func newFaultyLedger(t *testing.T) (*ledgerStore, *faultyDatastore, *memoryDag) {
+	ds := &faultyDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	dag := newMemoryDag()
+	ls, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ls, ds, dag
+}
+
+func TestS3X_FaultInjection(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Healthy", func(t *testing.T) {
+		ls, _, _ := newFaultyLedger(t)
+		if err := ls.checkHealth(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("DatastoreFault", func(t *testing.T) {
+		ls, ds, _ := newFaultyLedger(t)
+		ds.failPut = true
+		if err := ls.checkHealth(ctx); err == nil {
+			t.Fatal("expected the health check to fail")
+		}
+	})
+	t.Run("DagFault", func(t *testing.T) {
+		ls, _, dag := newFaultyLedger(t)
+		dag.fail = true
+		if err := ls.checkHealth(ctx); err == nil {
+			t.Fatal("expected the health check to fail")
+		}
+		dag.fail = false
+		dag.noHashes = true
+		if err := ls.checkHealth(ctx); err == nil {
+			t.Fatal("expected the health check to fail without a returned hash")
+		}
+	})
+	t.Run("NilBucket", func(t *testing.T) {
+		ls, _, _ := newFaultyLedger(t)
+		if _, err := ls.CreateBucket(ctx, testBucket1, nil); err != ErrLedgerNilBucket {
+			t.Fatal("expected ErrLedgerNilBucket, but got: ", err)
+		}
+	})
+	t.Run("CopyObject", func(t *testing.T) {
+		ls, ds, dag := newFaultyLedger(t)
+		x := &xObjects{ledgerStore: ls, dagClient: dag, clock: systemClock{}}
+		if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data"}); err != nil {
+			t.Fatal(err)
+		}
+		copyObject := func() error {
+			_, err := x.CopyObject(ctx, testBucket1, testObject1, testBucket1, "copied object",
+				minio.ObjectInfo{}, minio.ObjectOptions{}, minio.ObjectOptions{})
+			return err
+		}
+		dag.noHashes = true
+		if err := copyObject(); err == nil {
+			t.Fatal("expected an error when the dag returns no hash")
+		}
+		dag.noHashes = false
+		ds.failPut = true
+		if err := copyObject(); err == nil {
+			t.Fatal("expected an error when the datastore fails")
+		}
+		ds.failPut = false
+		dag.fail = true
+		if err := copyObject(); err == nil {
+			t.Fatal("expected an error when the dag fails")
+		}
+		dag.fail = false
+		if err := copyObject(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package s3x
+
+import (
+	"log"
+	"time"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+/* Design Notes
+---------------
+
+Clients that retry a PutObject after a timeout can not know whether the first attempt was stored, and every
+retry that is stored again replaces the object, which creates a noncurrent version in versioned buckets, and is
+metered and counted against the capacity again. Uploads can pass an idempotency key in the x-s3x-idempotency-key
+header, and every upload with a key records its resulting object info under the bucket, object, and key, in the
+same batch as the object. The data of a retry is uploaded again, which stores nothing new since TemporalX is
+content addressed, and its data hash, or the hashes of its shards if it is erasure coded, is compared with the
+record under the bucket lock: retries of the same data return the recorded object info without saving,
+publishing, or metering anything, and uploads of other data with the same key fail, so a key can not accidentally
+drop a real change. The record is returned even if the object was replaced or deleted since, like the response of
+the first attempt would have been.
+
+Records are kept for idempotencyWindow, which bounds how late a retry is recognized, and are pruned by a
+maintenance loop, or removed with their bucket. Keys are scoped to an object, so clients can use one key for the
+retries of an upload without coordinating keys across objects. Only single part uploads read the header.
+*/
+
+const (
+	// idempotencyWindow is how long the result of an upload with an idempotency key is returned to its retries
+	idempotencyWindow = 24 * time.Hour
+	// idempotencyPruneInterval is how often expired idempotency records are removed
+	idempotencyPruneInterval = time.Hour
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key
+	maxIdempotencyKeyLength = 256
+)
+
+// idempotencyKey returns the idempotency key of an upload from the x-s3x-idempotency-key header, empty if
+// it has none
+func idempotencyKey(userDefined map[string]string) (string, error) {
+	key := userDefinedValue(userDefined, xhttp.S3xIdempotencyKey)
+	if len(key) > maxIdempotencyKeyLength {
+		return "", ErrInvalidIdempotencyKey
+	}
+	for _, c := range key {
+		if c < ' ' || c > '~' {
+			return "", ErrInvalidIdempotencyKey
+		}
+	}
+	return key, nil
+}
+
+// pruneIdempotencyLoop removes expired idempotency records every interval until the gateway is shut down
+func (x *xObjects) pruneIdempotencyLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := x.ledgerStore.PruneIdempotencyRecords(now)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to prune idempotency records: %v", err)
+			}
+			if n > 0 {
+				log.Printf("pruned %v idempotency records", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+func TestS3X_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+		Bucket: testBucket1, Versioning: VersioningConfig{Enabled: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(key, data string) (minio.ObjectInfo, error) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{xhttp.S3xIdempotencyKey: key}}
+		return gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), opts)
+	}
+	versions := func() int {
+		_, versions, err := gateway.ledgerStore.ListObjectVersions(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(versions[testObject1].Versions)
+	}
+
+	first, err := put("upload-1", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.UserDefined[xhttp.S3xIdempotencyKey] != "" {
+		t.Fatal("expected the idempotency key not to be stored as metadata")
+	}
+	// retries return the first upload without replacing it
+	for i := 0; i < 3; i++ {
+		retry, err := put("upload-1", "data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry.ETag != first.ETag || !retry.ModTime.Equal(first.ModTime) || retry.Size != first.Size {
+			t.Fatalf("expected the retry to return the first upload, got %+v", retry)
+		}
+	}
+	if n := versions(); n != 0 {
+		t.Fatalf("expected retries not to create versions, got %v", n)
+	}
+	if _, err := put("upload-1", "other"); err != (minio.PreConditionFailed{}) {
+		t.Fatal("expected err PreConditionFailed, but got: ", err)
+	}
+	// another key is another upload, also of the same data
+	if _, err := put("upload-2", "data"); err != nil {
+		t.Fatal(err)
+	}
+	if n := versions(); n != 1 {
+		t.Fatalf("expected a new upload to create a version, got %v", n)
+	}
+	for _, invalid := range []string{strings.Repeat("k", maxIdempotencyKeyLength+1), "key\n"} {
+		if _, err := put(invalid, "data"); err != (minio.UnsupportedMetadata{}) {
+			t.Fatalf("expected err UnsupportedMetadata for key %q, but got: %v", invalid, err)
+		}
+	}
+
+	// the test clock is at the zero time, so the records expired by now
+	n, err := gateway.ledgerStore.PruneIdempotencyRecords(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 pruned records, got %v", n)
+	}
+	if _, err := put("upload-1", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if n := versions(); n != 2 {
+		t.Fatalf("expected the key to be usable again after it expired, got %v versions", n)
+	}
+
+	// the records of a bucket are removed with it
+	if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{Bucket: testBucket1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.DeleteBucket(ctx, testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := gateway.ledgerStore.idempotencyRecord(testBucket1, testObject1, "upload-1", time.Time{}); err != nil || ok {
+		t.Fatalf("expected the record to be removed with the bucket, got %v %v", ok, err)
+	}
+}
+
+func TestS3X_LedgerIdempotencyRecord(t *testing.T) {
+	ctx := context.Background()
+	ls, _, _ := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	first := testLedgerObject(testBucket1, "confidential/object", "data")
+	first.ObjectInfo.Etag = "8d777f385d3dfec8815d20f7496026dc"
+	first.ObjectInfo.ModTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, replayed, err := ls.PutObjectIdempotent(ctx, testBucket1, "confidential/object", "upload-1", first); err != nil || replayed {
+		t.Fatalf("expected the first upload to be stored, but got %v %v", replayed, err)
+	}
+	data, err := ls.ds.Get(ls.idempotencyRecordKey(testBucket1, "confidential/object", "upload-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "confidential") {
+		t.Fatal("expected the record not to have the object name")
+	}
+	// the retry returns its own info with the ETag, size and modification time of the first upload
+	retry := testLedgerObject(testBucket1, "confidential/object", "data")
+	retry.ObjectInfo.ModTime = first.ObjectInfo.ModTime.Add(time.Minute)
+	info, replayed, err := ls.PutObjectIdempotent(ctx, testBucket1, "confidential/object", "upload-1", retry)
+	if err != nil || !replayed {
+		t.Fatalf("expected the retry to be replayed, but got %v %v", replayed, err)
+	}
+	if info.GetName() != "confidential/object" || info.GetEtag() != first.ObjectInfo.Etag ||
+		info.GetSize_() != first.ObjectInfo.Size_ || !info.GetModTime().Equal(first.ObjectInfo.ModTime) {
+		t.Fatalf("expected the info of the first upload, but got %+v", info)
+	}
+}
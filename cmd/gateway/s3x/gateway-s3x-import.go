@@ -0,0 +1,405 @@
+package s3x
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+	miniogo "github.com/minio/minio-go/v6"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Onboarding a bucket from another S3 compatible service can take days, so imports run in the background, and save
+their progress in the ledger datastore. The source is listed in pages of importPageSize keys, in key order, and
+the objects of a page are copied by importWorkers at a time. After each page the key of its last object is saved as
+the marker of the import, so an interrupted import, whether it was cancelled or the gateway stopped, resumes
+listing after the marker.
+
+The result of every object is saved as well, with how its data was checked against the source: by md5 if the ETag
+of the source is the md5 of the data, and by size for objects uploaded in parts, whose ETags are not. Results are
+listed by GetImport, optionally only the failed ones, as the verification report of the import. An
+object whose result shows it was imported with the same ETag and size is skipped, so the page interrupted by a
+restart, or an import resumed with retryFailed from the first key, only copies the objects that are missing.
+
+The secret key of the source is only held while the import runs, it is never written to the datastore, so
+imports are resumed by StartImport with their id and the credentials of the source. Results are keyed by their
+names encoded like the ledger keys of the destination bucket, so they are listed in the order of these keys, and
+the records themselves still hold the names of the source.
+*/
+
+const (
+	// importRunning and the other import states are the states of an ImportJob
+	importRunning     = "running"
+	importInterrupted = "interrupted"
+	importCompleted   = "completed"
+	// importFailed is the state of an import stopped by an error, or of an object that was not imported
+	importFailed = "failed"
+	// importImported is the state of an imported object
+	importImported = "imported"
+	// importPageSize is the number of source keys listed, and processed, between saves of the import progress
+	importPageSize = 1000
+	// importWorkers is the number of objects of a page copied in parallel
+	importWorkers = 4
+)
+
+// importTracker tracks the imports running on this gateway
+type importTracker struct {
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+// start tracks a running import, false if it is already running
+func (t *importTracker) start(id string, cancel context.CancelFunc) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.running[id]; ok {
+		return false
+	}
+	if t.running == nil {
+		t.running = make(map[string]context.CancelFunc)
+	}
+	t.running[id] = cancel
+	return true
+}
+
+// finish stops tracking an import
+func (t *importTracker) finish(id string) {
+	t.mu.Lock()
+	delete(t.running, id)
+	t.mu.Unlock()
+}
+
+// isRunning returns if an import is running
+func (t *importTracker) isRunning(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.running[id]
+	return ok
+}
+
+// cancel cancels a running import, false if it is not running
+func (t *importTracker) cancel(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cancel, ok := t.running[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// StartImport starts importing the objects of an S3 compatible source into a bucket, or resumes an import
+func (x *xObjects) StartImport(ctx context.Context, req *StartImportRequest) (*ImportJob, error) {
+	src := req.GetSource()
+	if src.GetAccessKey() == "" || src.GetSecretKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "source credentials are empty")
+	}
+	var job *ImportJob
+	if req.GetId() != "" {
+		stored, err := x.ledgerStore.GetImportJob(req.GetId())
+		if err != nil {
+			return nil, toGrpcErr(err)
+		}
+		job = stored
+		// resumed imports keep their source, and only take the credentials of the request
+		src = &ImportSource{
+			Endpoint:  job.GetSource().GetEndpoint(),
+			AccessKey: src.GetAccessKey(),
+			SecretKey: src.GetSecretKey(),
+			Region:    job.GetSource().GetRegion(),
+			Bucket:    job.GetSource().GetBucket(),
+			Prefix:    job.GetSource().GetPrefix(),
+		}
+		if req.GetRetryFailed() {
+			job.Marker = ""
+		}
+	} else {
+		if src.GetBucket() == "" {
+			return nil, status.Error(codes.InvalidArgument, "source bucket name is empty")
+		}
+		if req.GetBucket() == "" {
+			return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+		}
+		job = &ImportJob{
+			Id: ksuid.New().String(),
+			Source: &ImportSource{
+				Endpoint:  src.GetEndpoint(),
+				AccessKey: src.GetAccessKey(),
+				Region:    src.GetRegion(),
+				Bucket:    src.GetBucket(),
+				Prefix:    src.GetPrefix(),
+			},
+			Bucket:  req.GetBucket(),
+			Started: x.clock.Now().Unix(),
+		}
+	}
+	client, err := newImportClient(src)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := x.ledgerStore.BucketExists(job.GetBucket())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if !exists {
+		return nil, toGrpcErr(ErrLedgerBucketDoesNotExist)
+	}
+	ictx, cancel := context.WithCancel(x.ctx)
+	if !x.imports.start(job.GetId(), cancel) {
+		cancel()
+		return nil, status.Error(codes.FailedPrecondition, "import is already running")
+	}
+	job.State = importRunning
+	job.Error = ""
+	job.Updated = x.clock.Now().Unix()
+	if err := x.ledgerStore.PutImportJob(job); err != nil {
+		x.imports.finish(job.GetId())
+		cancel()
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, import-id: %s, importing from %s/%s after %q", job.GetBucket(), job.GetId(), src.GetEndpoint(), src.GetBucket(), job.GetMarker())
+	result := *job
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		defer x.imports.finish(job.GetId())
+		defer cancel()
+		x.runImport(ictx, client, job)
+	}()
+	return &result, nil
+}
+
+// newImportClient returns a client of the S3 api of an import source
+func newImportClient(src *ImportSource) (miniogo.Core, error) {
+	endpoint, err := url.Parse(src.GetEndpoint())
+	if err != nil || endpoint.Host == "" {
+		return miniogo.Core{}, status.Error(codes.InvalidArgument, "source endpoint must be an absolute url")
+	}
+	region := src.GetRegion()
+	if region == "" {
+		region = "us-east-1"
+	}
+	client, err := miniogo.NewWithRegion(endpoint.Host, src.GetAccessKey(), src.GetSecretKey(), endpoint.Scheme == "https", region)
+	if err != nil {
+		return miniogo.Core{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return miniogo.Core{Client: client}, nil
+}
+
+// runImport imports the pages of the source of job after its marker, and saves the progress after each page
+func (x *xObjects) runImport(ctx context.Context, client miniogo.Core, job *ImportJob) {
+	src := job.GetSource()
+	for {
+		page, err := client.ListObjectsV2(src.GetBucket(), src.GetPrefix(), "", false, "", importPageSize, job.GetMarker())
+		if err == nil && len(page.Contents) > 0 {
+			x.importPage(ctx, client, job, page.Contents)
+			if ctx.Err() == nil {
+				job.Marker = page.Contents[len(page.Contents)-1].Key
+			}
+		}
+		switch {
+		case ctx.Err() != nil:
+			job.State = importInterrupted
+		case err != nil:
+			job.State = importFailed
+			job.Error = err.Error()
+		case !page.IsTruncated || len(page.Contents) == 0:
+			job.State = importCompleted
+		}
+		job.Updated = x.clock.Now().Unix()
+		if err := x.ledgerStore.PutImportJob(job); err != nil {
+			log.Printf("bucket-name: %s, import-id: %s, failed to save progress: %v", job.GetBucket(), job.GetId(), err)
+			return
+		}
+		if job.GetState() != importRunning {
+			log.Printf("bucket-name: %s, import-id: %s, import %s after %q", job.GetBucket(), job.GetId(), job.GetState(), job.GetMarker())
+			return
+		}
+	}
+}
+
+// importPage imports the objects of a page of the source, and adds their results to the counters of job
+func (x *xObjects) importPage(ctx context.Context, client miniogo.Core, job *ImportJob, objects []miniogo.ObjectInfo) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		next = make(chan miniogo.ObjectInfo)
+	)
+	for i := 0; i < importWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range next {
+				r, prev, skipped := x.importObject(ctx, client, job, info)
+				if r == nil {
+					continue
+				}
+				mu.Lock()
+				// objects processed before are counted by their latest result
+				switch {
+				case skipped:
+				case prev.GetState() == importImported:
+					job.Imported--
+					job.Bytes -= prev.GetSize_()
+				case prev.GetState() == importFailed:
+					job.Failed--
+				}
+				switch {
+				case skipped:
+					job.Skipped++
+				case r.GetState() == importImported:
+					job.Imported++
+					job.Bytes += r.GetSize_()
+				default:
+					job.Failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, info := range objects {
+		select {
+		case next <- info:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(next)
+	wg.Wait()
+}
+
+// importObject copies an object of the source into the bucket of job and saves its result, with the previous
+// result of the object, and true if it was imported before with the same ETag and size. The result is nil if the
+// import was interrupted before the object was processed.
+func (x *xObjects) importObject(
+	ctx context.Context,
+	client miniogo.Core,
+	job *ImportJob,
+	info miniogo.ObjectInfo,
+) (*ImportObjectResult, *ImportObjectResult, bool) {
+	sourceEtag := strings.Trim(info.ETag, `"`)
+	prev, err := x.ledgerStore.GetImportResult(job, info.Key)
+	if err == nil && prev.GetState() == importImported && prev.GetSourceEtag() == sourceEtag && prev.GetSize_() == info.Size {
+		return prev, prev, true
+	}
+	r := &ImportObjectResult{Name: info.Key, Size_: info.Size, SourceEtag: sourceEtag}
+	if err == nil {
+		err = x.copyImportObject(ctx, client, job, r)
+	}
+	if ctx.Err() != nil {
+		return nil, nil, false
+	}
+	r.State = importImported
+	if err != nil {
+		r.State = importFailed
+		r.Error = err.Error()
+		log.Printf("bucket-name: %s, import-id: %s, object-name: %s, import failed: %v", job.GetBucket(), job.GetId(), info.Key, err)
+	}
+	r.Processed = x.clock.Now().Unix()
+	if err := x.ledgerStore.PutImportResult(job, r); err != nil {
+		log.Printf("bucket-name: %s, import-id: %s, object-name: %s, failed to save result: %v", job.GetBucket(), job.GetId(), info.Key, err)
+	}
+	return r, prev, false
+}
+
+// copyImportObject downloads an object of the source, stores it, and verifies it by md5 if the source ETag is an
+// md5, or by size otherwise
+func (x *xObjects) copyImportObject(ctx context.Context, client miniogo.Core, job *ImportJob, r *ImportObjectResult) error {
+	body, info, _, err := client.GetObjectWithContext(ctx, job.GetSource().GetBucket(), r.GetName(), miniogo.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if info.Size != r.GetSize_() {
+		return fmt.Errorf("source object changed from %d to %d bytes", r.GetSize_(), info.Size)
+	}
+	md5Hex := ""
+	if isMD5Hex(r.GetSourceEtag()) {
+		md5Hex = r.GetSourceEtag()
+	}
+	// the md5 is always computed, to store it as the ETag of objects uploaded in parts as well
+	data, err := hash.NewReader(body, info.Size, md5Hex, "", info.Size, true)
+	if err != nil {
+		return err
+	}
+	stored, err := x.PutObject(ctx, job.GetBucket(), r.GetName(), minio.NewPutObjReader(data, nil, nil), minio.ObjectOptions{
+		UserDefined: minio.FromMinioClientObjectInfo(job.GetSource().GetBucket(), info).UserDefined,
+	})
+	if err != nil {
+		return err
+	}
+	if stored.Size != info.Size {
+		return fmt.Errorf("stored %d of %d bytes", stored.Size, info.Size)
+	}
+	r.Etag = hex.EncodeToString(data.MD5Current())
+	r.Verified = "size"
+	if md5Hex != "" {
+		r.Verified = "md5"
+	}
+	return nil
+}
+
+// isMD5Hex returns if an ETag is the hex encoded md5 of the data, rather than the ETag of a multipart upload
+func isMD5Hex(etag string) bool {
+	if len(etag) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
+
+// GetImport returns the progress of an import, and a page of the results of its objects
+func (x *xObjects) GetImport(ctx context.Context, req *GetImportRequest) (*GetImportResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "import id is empty")
+	}
+	if req.GetMax() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max can not be negative")
+	}
+	job, err := x.ledgerStore.GetImportJob(req.GetId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if job.GetState() == importRunning && !x.imports.isRunning(job.GetId()) {
+		// the gateway stopped while the import was running
+		job.State = importInterrupted
+	}
+	results, truncated, err := x.ledgerStore.ImportResults(job, req.GetAfter(), int(req.GetMax()), req.GetFailedOnly())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp := &GetImportResponse{Job: job, Results: results}
+	if truncated {
+		resp.Next = results[len(results)-1].GetName()
+	}
+	return resp, nil
+}
+
+// CancelImport interrupts a running import, which can be resumed with StartImport
+func (x *xObjects) CancelImport(ctx context.Context, req *CancelImportRequest) (*ImportJob, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "import id is empty")
+	}
+	job, err := x.ledgerStore.GetImportJob(req.GetId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if !x.imports.cancel(req.GetId()) {
+		return nil, status.Error(codes.FailedPrecondition, "import is not running")
+	}
+	log.Printf("bucket-name: %s, import-id: %s, import cancelled", job.GetBucket(), job.GetId())
+	return job, nil
+}
@@ -0,0 +1,275 @@
+package s3x
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeImportSource serves the listing and objects of a bucket like an S3 compatible service
+type fakeImportSource struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	etags   map[string]string
+	gets    map[string]int
+}
+
+func (s *fakeImportSource) put(name string, data []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if etag == "" {
+		sum := md5.Sum(data)
+		etag = hex.EncodeToString(sum[:])
+	}
+	s.objects[name] = data
+	s.etags[name] = etag
+}
+
+func (s *fakeImportSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+s.bucket)
+	if path == "" || path == "/" {
+		s.list(w, r)
+		return
+	}
+	name := strings.TrimPrefix(path, "/")
+	data, ok := s.objects[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.gets[name]++
+	w.Header().Set("ETag", `"`+s.etags[name]+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Amz-Meta-Source", name)
+	_, _ = w.Write(data)
+}
+
+func (s *fakeImportSource) list(w http.ResponseWriter, r *http.Request) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int64
+	}
+	type result struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}
+	q := r.URL.Query()
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		if strings.HasPrefix(name, q.Get("prefix")) && name > q.Get("start-after") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	res := result{Name: s.bucket, Prefix: q.Get("prefix"), MaxKeys: maxKeys}
+	if maxKeys > 0 && len(names) > maxKeys {
+		names, res.IsTruncated = names[:maxKeys], true
+	}
+	for _, name := range names {
+		res.Contents = append(res.Contents, content{
+			Key:          name,
+			LastModified: time.Unix(0, 0).UTC().Format(time.RFC3339),
+			ETag:         `"` + s.etags[name] + `"`,
+			Size:         int64(len(s.objects[name])),
+		})
+	}
+	res.KeyCount = len(res.Contents)
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+func waitImport(t *testing.T, gateway *testGateway, id string) *ImportJob {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		resp, err := gateway.GetImport(context.Background(), &GetImportRequest{Id: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Job.State != importRunning {
+			return resp.Job
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("import did not finish")
+	return nil
+}
+
+func TestS3X_Import(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	source := &fakeImportSource{
+		bucket:  "source",
+		objects: make(map[string][]byte),
+		etags:   make(map[string]string),
+		gets:    make(map[string]int),
+	}
+	source.put("data/a", []byte("hello"), "")
+	source.put("data/b", []byte("multipart"), "0123456789abcdef0123456789abcdef-2")
+	source.put("data/c", []byte("corrupt"), "0123456789abcdef0123456789abcdef")
+	source.put("other", []byte("skipped by prefix"), "")
+	server := httptest.NewServer(source)
+	defer server.Close()
+	src := &ImportSource{
+		Endpoint:  server.URL,
+		AccessKey: "access",
+		SecretKey: "secret",
+		Bucket:    "source",
+		Prefix:    "data/",
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []*StartImportRequest{
+			{Bucket: testBucket1},
+			{Bucket: testBucket1, Source: &ImportSource{AccessKey: "access", SecretKey: "secret"}},
+			{Source: src},
+			{Bucket: testBucket1, Source: &ImportSource{AccessKey: "access", SecretKey: "secret", Bucket: "source"}},
+		} {
+			if _, err := gateway.StartImport(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+			}
+		}
+		if _, err := gateway.StartImport(ctx, &StartImportRequest{Bucket: testBucket2, Source: src}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+		if _, err := gateway.GetImport(ctx, &GetImportRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+		if _, err := gateway.CancelImport(ctx, &CancelImportRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+	})
+
+	job, err := gateway.StartImport(ctx, &StartImportRequest{Bucket: testBucket1, Source: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Source.SecretKey != "" {
+		t.Fatal("secret key of the source was returned")
+	}
+	job = waitImport(t, gateway, job.Id)
+	t.Run("Completed", func(t *testing.T) {
+		if job.State != importCompleted || job.Imported != 2 || job.Failed != 1 || job.Bytes != 14 || job.Marker != "data/c" {
+			t.Fatalf("unexpected import %+v", job)
+		}
+		stored, err := gateway.ledgerStore.GetImportJob(job.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Source.SecretKey != "" {
+			t.Fatal("secret key of the source was stored")
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, "data/a", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != 5 || info.ContentType != "text/plain" || info.UserDefined["X-Amz-Meta-Source"] != "data/a" {
+			t.Fatalf("unexpected object %+v", info)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, "data/c", minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected the corrupt object to not be imported")
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, "other", minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected objects outside of the prefix to not be imported")
+		}
+	})
+	t.Run("Results", func(t *testing.T) {
+		resp, err := gateway.GetImport(ctx, &GetImportRequest{Id: job.Id, Max: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Results) != 2 || resp.Next != "data/b" {
+			t.Fatalf("unexpected results %+v, next %q", resp.Results, resp.Next)
+		}
+		if a := resp.Results[0]; a.Name != "data/a" || a.State != importImported || a.Verified != "md5" || a.Etag != a.SourceEtag {
+			t.Fatalf("unexpected result %+v", a)
+		}
+		if b := resp.Results[1]; b.State != importImported || b.Verified != "size" {
+			t.Fatalf("unexpected result %+v", b)
+		}
+		failed, err := gateway.GetImport(ctx, &GetImportRequest{Id: job.Id, FailedOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed.Results) != 1 || failed.Results[0].Name != "data/c" || failed.Results[0].Error == "" || failed.Next != "" {
+			t.Fatalf("unexpected failed results %+v", failed.Results)
+		}
+	})
+	t.Run("RetryFailed", func(t *testing.T) {
+		source.put("data/c", []byte("corrupt"), "")
+		if _, err := gateway.StartImport(ctx, &StartImportRequest{Id: job.Id, Source: &ImportSource{AccessKey: "access"}}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+		}
+		retry, err := gateway.StartImport(ctx, &StartImportRequest{
+			Id:          job.Id,
+			Source:      &ImportSource{AccessKey: "access", SecretKey: "secret"},
+			RetryFailed: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry = waitImport(t, gateway, retry.Id)
+		if retry.State != importCompleted || retry.Imported != 3 || retry.Skipped != 2 || retry.Failed != 0 || retry.Bytes != 21 {
+			t.Fatalf("unexpected import %+v", retry)
+		}
+		source.mu.Lock()
+		gets := source.gets["data/a"]
+		source.mu.Unlock()
+		if gets != 1 {
+			t.Fatalf("expected the imported object to be downloaded once, but got %v", gets)
+		}
+		if _, err := gateway.CancelImport(ctx, &CancelImportRequest{Id: job.Id}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+		}
+	})
+	t.Run("Restart", func(t *testing.T) {
+		stored, err := gateway.ledgerStore.GetImportJob(job.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored.State = importRunning
+		if err := gateway.ledgerStore.PutImportJob(stored); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := gateway.GetImport(ctx, &GetImportRequest{Id: job.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Job.State != importInterrupted {
+			t.Fatalf("expected an import that is not running to be %v, but got %v", importInterrupted, resp.Job.State)
+		}
+	})
+}
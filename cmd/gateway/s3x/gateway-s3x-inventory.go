@@ -0,0 +1,232 @@
+package s3x
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Batch tools such as Athena, Spark, and S3 Batch Operations read bucket listings as S3 Inventory reports, a
+manifest.json that lists gzip compressed CSV data files, and a manifest.checksum with the md5 of the manifest.
+ExportInventory writes such a report for the committed state of a bucket, with one row per object of the columns
+of inventoryFileSchema, and stores it on IPFS as a UnixFS directory:
+
+	manifest.json
+	manifest.checksum
+	data/00000.csv.gz
+	...
+
+The keys of the manifest are the paths of the data files in the directory, so a report is read from any IPFS
+gateway at /ipfs/<directoryHash>/<key>. Data files are built one at a time, with at most rowsPerFile rows, and are
+gzipped without a modification time, so exports of the same bucket state have the same hashes. The hashes of the
+directory, the manifest, and every data file are recorded under dsInventoryKey, and are kept when the bucket is
+deleted, since a report is an immutable record of the bucket at the time it was exported. There is no destination
+bucket, the destinationBucket of the manifest is the arn of the bucket that was listed.
+
+Reports list object names and sizes, and anyone with the hash of a report can read it, so exports of encrypted
+buckets, like their directory views, should only be shared with readers of the bucket.
+*/
+
+const (
+	// inventoryRowsPerFile is the default maximum number of objects listed in each data file of an inventory
+	inventoryRowsPerFile = 100000
+	// inventoryFileSchema are the columns of the data files of an inventory
+	inventoryFileSchema = "Bucket, Key, Size, LastModifiedDate, ETag, StorageClass"
+	// inventoryVersion is the version of the S3 Inventory manifest format
+	inventoryVersion = "2016-11-30"
+)
+
+// inventoryManifest is the manifest.json of an S3 Inventory report
+type inventoryManifest struct {
+	SourceBucket      string                  `json:"sourceBucket"`
+	DestinationBucket string                  `json:"destinationBucket"`
+	Version           string                  `json:"version"`
+	CreationTimestamp string                  `json:"creationTimestamp"`
+	FileFormat        string                  `json:"fileFormat"`
+	FileSchema        string                  `json:"fileSchema"`
+	Files             []inventoryManifestFile `json:"files"`
+}
+
+// inventoryManifestFile is a data file of an inventory manifest
+type inventoryManifestFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+// ExportInventory stores the listing of a bucket on IPFS as an S3 Inventory report
+func (x *xObjects) ExportInventory(ctx context.Context, req *ExportInventoryRequest) (*BucketInventory, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	rowsPerFile := int(req.GetRowsPerFile())
+	if rowsPerFile <= 0 {
+		rowsPerFile = inventoryRowsPerFile
+	}
+	inv, err := x.exportInventory(ctx, req.GetBucket(), rowsPerFile)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, inventory-id: %s, inventory-hash: %s, objects: %v", inv.GetBucket(), inv.GetId(), inv.GetDirectoryHash(), inv.GetObjects())
+	return inv, nil
+}
+
+// ListInventories returns the inventories exported for a bucket
+func (x *xObjects) ListInventories(ctx context.Context, req *ListInventoriesRequest) (*ListInventoriesResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	inventories, err := x.ledgerStore.BucketInventories(req.GetBucket())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &ListInventoriesResponse{Inventories: inventories}, nil
+}
+
+// exportInventory writes the data files and the manifest of an inventory of the committed state of a bucket,
+// saves them in a directory, and records the inventory
+func (x *xObjects) exportInventory(ctx context.Context, bucket string, rowsPerFile int) (*BucketInventory, error) {
+	bucketHash, objects, err := x.ledgerStore.BucketState(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := x.clock.Now()
+	inv := &BucketInventory{
+		Id:         ksuid.New().String(),
+		Bucket:     bucket,
+		BucketHash: bucketHash,
+		Created:    now.Unix(),
+		Objects:    int64(len(names)),
+	}
+	data := newDirectoryTree()
+	for start := 0; start < len(names); start += rowsPerFile {
+		end := start + rowsPerFile
+		if end > len(names) {
+			end = len(names)
+		}
+		f, err := x.writeInventoryFile(ctx, bucket, names[start:end], objects)
+		if err != nil {
+			return nil, err
+		}
+		f.Key = fmt.Sprintf("data/%05d.csv.gz", len(inv.Files))
+		if err := addInventoryLink(data, strings.TrimPrefix(f.Key, "data/"), f.Hash, f.Size_); err != nil {
+			return nil, err
+		}
+		inv.Files = append(inv.Files, f)
+	}
+	manifest := inventoryManifest{
+		SourceBucket:      bucket,
+		DestinationBucket: "arn:aws:s3:::" + bucket,
+		Version:           inventoryVersion,
+		CreationTimestamp: strconv.FormatInt(now.UnixNano()/1e6, 10),
+		FileFormat:        "CSV",
+		FileSchema:        inventoryFileSchema,
+		Files:             make([]inventoryManifestFile, 0, len(inv.Files)),
+	}
+	for _, f := range inv.Files {
+		manifest.Files = append(manifest.Files, inventoryManifestFile{Key: f.Key, Size: f.Size_, MD5checksum: f.Md5})
+	}
+	manifestData, err := json.Marshal(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	root := newDirectoryTree()
+	root.dirs["data"] = data
+	if inv.ManifestHash, err = x.saveInventoryLink(ctx, root, "manifest.json", manifestData); err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(manifestData)
+	if _, err := x.saveInventoryLink(ctx, root, "manifest.checksum", []byte(hex.EncodeToString(sum[:]))); err != nil {
+		return nil, err
+	}
+	c, _, err := root.save(ctx, x)
+	if err != nil {
+		return nil, err
+	}
+	inv.DirectoryHash = c.String()
+	if err := x.ledgerStore.PutBucketInventory(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// writeInventoryFile stores a gzipped CSV data file with a row for each of names
+func (x *xObjects) writeInventoryFile(ctx context.Context, bucket string, names []string, objects map[string]string) (*InventoryFile, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, name := range names {
+		obj, err := ipfsObject(ctx, x.dagClient, objects[name])
+		if err != nil {
+			return nil, err
+		}
+		info := obj.ObjectInfo
+		storageClass := info.StorageClass
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+		row := []string{
+			bucket,
+			url.QueryEscape(name),
+			strconv.FormatInt(info.Size_, 10),
+			info.ModTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+			s3ETag(info.Etag),
+			storageClass,
+		}
+		// every field is quoted, like the data files written by S3
+		if _, err := zw.Write([]byte(`"` + strings.Join(row, `","`) + "\"\n")); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(buf.Bytes())
+	f := &InventoryFile{Size_: int64(buf.Len()), Md5: hex.EncodeToString(sum[:])}
+	var err error
+	if f.Hash, _, err = ipfsFileUpload(ctx, x.fileClient, &buf); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// saveInventoryLink stores data as a file of a directory, and returns its hash
+func (x *xObjects) saveInventoryLink(ctx context.Context, dir *directoryTree, name string, data []byte) (string, error) {
+	hash, _, err := ipfsFileUpload(ctx, x.fileClient, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return hash, addInventoryLink(dir, name, hash, int64(len(data)))
+}
+
+// addInventoryLink adds a file of size bytes to a directory
+func addInventoryLink(dir *directoryTree, name, hash string, size int64) error {
+	c, err := cid.Decode(hash)
+	if err != nil {
+		return err
+	}
+	dir.files[name] = &ipld.Link{Name: name, Cid: c, Size: uint64(size)}
+	return nil
+}
@@ -0,0 +1,150 @@
+package s3x
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-merkledag"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Inventory(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	objects := []string{"a b.txt", "docs/readme.md", "z"}
+	for _, object := range objects {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("inventory "+object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	download := func(hash string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		if _, err := ipfsFileDownload(ctx, gateway.fileClient, &buf, hash, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	inv, err := gateway.ExportInventory(ctx, &ExportInventoryRequest{Bucket: testBucket1, RowsPerFile: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucketHash, err := gateway.ledgerStore.GetBucketHash(testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.BucketHash != bucketHash || inv.Objects != 3 || len(inv.Files) != 2 {
+		t.Fatalf("unexpected inventory %+v", inv)
+	}
+
+	t.Run("Manifest", func(t *testing.T) {
+		data := download(inv.ManifestHash)
+		var manifest inventoryManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if manifest.SourceBucket != testBucket1 || manifest.FileFormat != "CSV" || manifest.FileSchema != inventoryFileSchema || len(manifest.Files) != 2 {
+			t.Fatalf("unexpected manifest %+v", manifest)
+		}
+		for i, f := range manifest.Files {
+			if f.Key != inv.Files[i].Key || f.MD5checksum != inv.Files[i].Md5 || f.Size != inv.Files[i].Size_ {
+				t.Fatalf("expected file %+v, but got %+v", inv.Files[i], f)
+			}
+		}
+		dir, err := ipfsBytes(ctx, gateway.dagClient, inv.DirectoryHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := merkledag.DecodeProtobuf(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links := make(map[string]string)
+		for _, l := range node.Links() {
+			links[l.Name] = l.Cid.String()
+		}
+		if len(links) != 3 || links["manifest.json"] != inv.ManifestHash || links["data"] == "" {
+			t.Fatalf("unexpected directory %v", links)
+		}
+		sum := md5.Sum(data)
+		if checksum := string(download(links["manifest.checksum"])); checksum != hex.EncodeToString(sum[:]) {
+			t.Fatalf("expected checksum %x, but got %v", sum, checksum)
+		}
+	})
+	t.Run("DataFiles", func(t *testing.T) {
+		var rows [][]string
+		for _, f := range inv.Files {
+			data := download(f.Hash)
+			if sum := md5.Sum(data); hex.EncodeToString(sum[:]) != f.Md5 {
+				t.Fatalf("file %v does not match its md5", f.Key)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(zr).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, records...)
+		}
+		if len(rows) != 3 || rows[0][1] != "a+b.txt" || rows[1][1] != "docs%2Freadme.md" || rows[2][1] != "z" {
+			t.Fatalf("unexpected rows %v", rows)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, "z", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if z := rows[2]; z[0] != testBucket1 || z[2] != "11" || z[4] != info.ETag || z[5] != "STANDARD" {
+			t.Fatalf("unexpected row %v", z)
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		again, err := gateway.ExportInventory(ctx, &ExportInventoryRequest{Bucket: testBucket1, RowsPerFile: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the same bucket state is stored as the same files
+		if again.Id == inv.Id || again.Files[0].Hash != inv.Files[0].Hash {
+			t.Fatalf("unexpected inventory %+v", again)
+		}
+		resp, err := gateway.ListInventories(ctx, &ListInventoriesRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]bool)
+		for _, i := range resp.Inventories {
+			ids[i.Id] = true
+		}
+		if len(resp.Inventories) != 2 || !ids[inv.Id] || !ids[again.Id] {
+			t.Fatalf("unexpected inventories %+v", resp.Inventories)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := gateway.ExportInventory(ctx, &ExportInventoryRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+		}
+		if _, err := gateway.ListInventories(ctx, &ListInventoriesRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+		}
+		if _, err := gateway.ExportInventory(ctx, &ExportInventoryRequest{Bucket: testBucket2}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/ipfs/go-cid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ipfsPathPrefix is the http path under which data is served by cid
+const ipfsPathPrefix = "/ipfs/"
+
+// ServeIPFSPath serves GET and HEAD requests for /ipfs/<cid> with the file data of the cid,
+// this works for any object data hash, as well as any other file TemporalX can retrieve.
+// Range and conditional requests are supported.
+func (x *xObjects) ServeIPFSPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := strings.TrimPrefix(r.URL.Path, ipfsPathPrefix)
+	if strings.Contains(h, "/") {
+		http.Error(w, "paths within a cid are not supported", http.StatusBadRequest)
+		return
+	}
+	c, err := cid.Decode(h)
+	if err != nil {
+		http.Error(w, "invalid cid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	fileClient, dagClient, err := x.dataClients(c.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	size, err := ipfsFileSize(ctx, dagClient, c.String())
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if x.publicGateway.offloads(size) {
+		x.publicGateway.serve(w, r, c.String(), "", http.Header{"Etag": []string{`"` + c.String() + `"`}})
+		return
+	}
+	rs := &ipfsReadSeeker{
+		ctx:        ctx,
+		fileClient: fileClient,
+		hash:       c.String(),
+		size:       size,
+	}
+	defer rs.Close()
+	// content addressed data never changes
+	w.Header().Set("Etag", `"`+c.String()+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	http.ServeContent(w, r, "", time.Time{}, rs) // zero modification time disables Last-Modified
+}
+
+// ipfsReadSeeker reads file data from TemporalX, downloading from the current offset
+// to the end of the file on the first Read after a Seek.
+type ipfsReadSeeker struct {
+	ctx        context.Context
+	fileClient pb.FileAPIClient
+	hash       string
+	size       int64
+
+	offset int64
+	pr     *io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *ipfsReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.pr == nil {
+		ctx, cancel := context.WithCancel(r.ctx)
+		pr, pw := io.Pipe()
+		go func(offset int64) {
+			_, err := ipfsFileDownload(ctx, r.fileClient, pw, r.hash, offset, r.size-offset)
+			_ = pw.CloseWithError(err)
+		}(r.offset)
+		r.pr, r.cancel = pr, cancel
+	}
+	n, err := r.pr.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *ipfsReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close stops an ongoing download
+func (r *ipfsReadSeeker) Close() error {
+	if r.pr != nil {
+		r.cancel()
+		_ = r.pr.Close()
+		r.pr, r.cancel = nil, nil
+	}
+	return nil
+}
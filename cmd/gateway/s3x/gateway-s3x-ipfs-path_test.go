@@ -0,0 +1,74 @@
+package s3x
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_IPFSPath_Badger(t *testing.T) {
+	testS3XIPFSPath(t, DSTypeBadger)
+}
+func TestS3X_IPFSPath_Crdt(t *testing.T) {
+	testS3XIPFSPath(t, DSTypeCrdt)
+}
+func testS3XIPFSPath(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	const data = "hello ipfs path"
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dataHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		rangeHdr   string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Success-Get", http.MethodGet, ipfsPathPrefix + dataHash, "", http.StatusOK, data},
+		{"Success-Range", http.MethodGet, ipfsPathPrefix + dataHash, "bytes=6-9", http.StatusPartialContent, data[6:10]},
+		{"Success-Suffix-Range", http.MethodGet, ipfsPathPrefix + dataHash, "bytes=-4", http.StatusPartialContent, data[len(data)-4:]},
+		{"Success-Head", http.MethodHead, ipfsPathPrefix + dataHash, "", http.StatusOK, ""},
+		{"Fail-Range", http.MethodGet, ipfsPathPrefix + dataHash, "bytes=100-200", http.StatusRequestedRangeNotSatisfiable, ""},
+		{"Fail-Method", http.MethodPost, ipfsPathPrefix + dataHash, "", http.StatusMethodNotAllowed, ""},
+		{"Fail-Invalid-CID", http.MethodGet, ipfsPathPrefix + "notacid", "", http.StatusBadRequest, ""},
+		{"Fail-Sub-Path", http.MethodGet, ipfsPathPrefix + dataHash + "/file", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			rec := httptest.NewRecorder()
+			gateway.ServeIPFSPath(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %v, but got %v: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Fatalf("expected body %q, but got %q", tt.wantBody, rec.Body.String())
+			}
+			if tt.method == http.MethodHead && rec.Header().Get("Content-Length") != strconv.Itoa(len(data)) {
+				t.Fatalf("expected content length %v, but got %v", len(data), rec.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package s3x
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Listing buckets used to load every bucket from IPFS to read its creation time, so the ledger keeps the
+BucketInfo of each bucket in the datastore next to the bucket hash. The info of a bucket never changes
+after it is created, so the records are written when buckets are created and removed when they are
+deleted, and the records of buckets created before records existed are written by the listings that
+read them.
+
+Bucket keys are sorted by name in the datastore, so a page of buckets sorted by name is read with a
+keys only query that starts after the continuation token and stops after the page is full. Sorting by
+creation time needs the info of every bucket, which is read from the records without loading any bucket.
+*/
+
+// dsBucketInfoKey maps bucket names to the BucketInfo of the bucket
+var dsBucketInfoKey = datastore.NewKey("c")
+
+// putBucketInfoRecord saves the info record of a bucket
+func putBucketInfoRecord(w datastore.Write, bucket string, info *BucketInfo) error {
+	data, err := info.Marshal()
+	if err != nil {
+		return err
+	}
+	return w.Put(dsBucketInfoKey.ChildString(bucket), data)
+}
+
+// deleteBucketInfoRecord removes the info record of a bucket
+func deleteBucketInfoRecord(w datastore.Write, bucket string) error {
+	if err := w.Delete(dsBucketInfoKey.ChildString(bucket)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// listingBucketInfo returns the info of a bucket from its record, the bucket is loaded and
+// its record is written if the record is missing
+func (ls *ledgerStore) listingBucketInfo(ctx context.Context, bucket string) (*BucketInfo, error) {
+	data, err := ls.ds.Get(dsBucketInfoKey.ChildString(bucket))
+	switch err {
+	case nil:
+		info := &BucketInfo{}
+		if err := info.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return info, nil
+	case datastore.ErrNotFound:
+	default:
+		return nil, err
+	}
+	info, err := ls.GetBucketInfo(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := putBucketInfoRecord(ls.ds, bucket, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListBucketNames returns up to max bucket names in name order, starting with prefix and after
+// the marker if one is given, and if more names follow, max <= 0 returns all names.
+func (ls *ledgerStore) ListBucketNames(prefix, marker string, max int) ([]string, bool, error) {
+	q := query.Query{
+		Prefix:   dsBucketKey.String(),
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+	}
+	if prefix != "" {
+		q.Filters = append(q.Filters, query.FilterKeyPrefix{Prefix: dsBucketKey.ChildString(prefix).String()})
+	}
+	if marker != "" {
+		q.Filters = append(q.Filters, query.FilterKeyCompare{Op: query.GreaterThan, Key: dsBucketKey.ChildString(marker).String()})
+	}
+	rs, err := ls.ds.Query(q)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rs.Close()
+	names := []string{}
+	for r := range rs.Next() {
+		if r.Error != nil {
+			return nil, false, r.Error
+		}
+		if max > 0 && len(names) == max {
+			return names, true, nil
+		}
+		names = append(names, datastore.NewKey(r.Key).BaseNamespace())
+	}
+	return names, false, nil
+}
@@ -0,0 +1,112 @@
+package s3x
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+)
+
+var _ minio.BucketPageLister = (*xObjects)(nil)
+
+// fixedClock returns the same time, so tests can choose the creation time of each bucket
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestS3X_BucketList_Badger(t *testing.T) {
+	testS3XBucketList(t, DSTypeBadger)
+}
+func TestS3X_BucketList_Crdt(t *testing.T) {
+	testS3XBucketList(t, DSTypeCrdt)
+}
+func testS3XBucketList(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// created in a different order than their names
+	for i, name := range []string{"photos-b", "docs", "photos-c", "photos-a"} {
+		gateway.clock = fixedClock(start.Add(time.Duration(i) * time.Hour))
+		if err := gateway.MakeBucketWithLocation(ctx, name, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ls := gateway.ledgerStore
+	pages := func(t *testing.T, opts minio.ListBucketsOptions) []string {
+		t.Helper()
+		var pages []string
+		for {
+			info, err := gateway.ListBucketsPage(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, b := range info.Buckets {
+				names = append(names, b.Name)
+			}
+			pages = append(pages, strings.Join(names, ","))
+			if info.NextContinuationToken == "" {
+				return pages
+			}
+			opts.ContinuationToken = info.NextContinuationToken
+		}
+	}
+	expect := func(t *testing.T, opts minio.ListBucketsOptions, expected ...string) {
+		t.Helper()
+		if got := pages(t, opts); strings.Join(got, "|") != strings.Join(expected, "|") {
+			t.Fatalf("expected pages %q, but got %q", expected, got)
+		}
+	}
+	t.Run("Name", func(t *testing.T) {
+		expect(t, minio.ListBucketsOptions{}, "docs,photos-a,photos-b,photos-c")
+		expect(t, minio.ListBucketsOptions{MaxBuckets: 3}, "docs,photos-a,photos-b", "photos-c")
+		expect(t, minio.ListBucketsOptions{MaxBuckets: 2}, "docs,photos-a", "photos-b,photos-c")
+		expect(t, minio.ListBucketsOptions{MaxBuckets: 2, Prefix: "photos"}, "photos-a,photos-b", "photos-c")
+		expect(t, minio.ListBucketsOptions{Prefix: "none"}, "")
+	})
+	t.Run("Created", func(t *testing.T) {
+		expect(t, minio.ListBucketsOptions{SortByCreated: true}, "photos-b,docs,photos-c,photos-a")
+		expect(t, minio.ListBucketsOptions{SortByCreated: true, MaxBuckets: 3}, "photos-b,docs,photos-c", "photos-a")
+		expect(t, minio.ListBucketsOptions{SortByCreated: true, MaxBuckets: 1, Prefix: "photos"}, "photos-b", "photos-c", "photos-a")
+		info, err := gateway.ListBucketsPage(ctx, minio.ListBucketsOptions{SortByCreated: true, MaxBuckets: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created := info.Buckets[0].Created; !created.Equal(start) {
+			t.Fatalf("expected created time %v, but got %v", start, created)
+		}
+	})
+	t.Run("MissingRecord", func(t *testing.T) {
+		// buckets created before info records existed have their records written when listed
+		if err := ls.ds.Delete(dsBucketInfoKey.ChildString("docs")); err != nil {
+			t.Fatal(err)
+		}
+		buckets, err := gateway.ListBuckets(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buckets) != 4 || buckets[0].Name != "docs" || !buckets[0].Created.Equal(start.Add(time.Hour)) {
+			t.Fatalf("unexpected buckets %v", buckets)
+		}
+		if _, err := ls.ds.Get(dsBucketInfoKey.ChildString("docs")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := gateway.DeleteBucket(ctx, "photos-a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ls.ds.Get(dsBucketInfoKey.ChildString("photos-a")); err != datastore.ErrNotFound {
+			t.Fatalf("expected the info record to be removed, but got %v", err)
+		}
+		expect(t, minio.ListBucketsOptions{MaxBuckets: 2}, "docs,photos-b", "photos-c")
+	})
+}
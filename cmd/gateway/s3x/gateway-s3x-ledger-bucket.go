@@ -22,7 +22,7 @@ func (m *LedgerBucketEntry) ensureCache(ctx context.Context, dag pb.NodeAPIClien
 			return err
 		}
 		if m.Bucket != nil {
-			panic("ensureCache state changed unexpectedly, this should never happen")
+			return ErrLedgerCacheChanged
 		}
 		m.Bucket = b
 	}
@@ -51,6 +51,52 @@ func (ls *ledgerStore) GetBucketHash(bucket string) (string, error) {
 	return b.IpfsHash, nil
 }
 
+//GetBucketConfig returns a copy of the s3x specific settings of a bucket
+func (ls *ledgerStore) GetBucketConfig(ctx context.Context, bucket string) (*BucketConfig, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	c := BucketConfig{}
+	if b.Bucket.Config != nil {
+		c = *b.Bucket.Config
+	}
+	return &c, nil
+}
+
+//UpdateBucketConfig modifies the s3x specific settings of a bucket using the update function,
+//if update returns an error the bucket is not modified.
+func (ls *ledgerStore) UpdateBucketConfig(ctx context.Context, bucket string, update func(c *BucketConfig) error) error {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	c := BucketConfig{}
+	if b.Bucket.Config != nil {
+		c = *b.Bucket.Config
+	}
+	if err := update(&c); err != nil {
+		return err
+	}
+	b.Bucket.Config = &c
+	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
+	return err
+}
+
+//SetBucketLocation changes the location of a bucket, which is its region label
+func (ls *ledgerStore) SetBucketLocation(ctx context.Context, bucket, location string) error {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	b.Bucket.BucketInfo.Location = location
+	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
+	return err
+}
+
 // getBucketNilable returns a lazy loading LedgerBucketEntry
 //
 // if err is returned, then the datastore can not be read
@@ -59,13 +105,25 @@ func (ls *ledgerStore) getBucketNilable(bucket string) (*LedgerBucketEntry, erro
 	ls.mapLocker.Lock()
 	b, ok := ls.l.Buckets[bucket]
 	ls.mapLocker.Unlock()
+	if ok && ls.shared {
+		// another gateway of the cluster may have changed the bucket since it was cached
+		bHash, err := ls.ds.Get(dsBucketKey.ChildString(bucket))
+		if err != nil && err != datastore.ErrNotFound {
+			return nil, err
+		}
+		if string(bHash) != b.IpfsHash {
+			ls.evictBucket(bucket)
+			ok = false
+		}
+	}
 	if !ok {
+		if exists, cached := ls.existence.get(bucket); cached && !exists {
+			return nil, nil
+		}
 		bHash, err := ls.ds.Get(dsBucketKey.ChildString(bucket))
 		if err != nil {
 			if err == datastore.ErrNotFound {
-				ls.mapLocker.Lock()
-				ls.l.Buckets[bucket] = nil
-				ls.mapLocker.Unlock()
+				ls.existence.set(bucket, false)
 				return nil, nil
 			}
 			return nil, err
@@ -126,7 +184,10 @@ func (ls *ledgerStore) CreateBucket(ctx context.Context, bucket string, b *Bucke
 
 func (ls *ledgerStore) createBucket(ctx context.Context, bucket string, b *Bucket) (*LedgerBucketEntry, error) {
 	if b == nil {
-		panic("can not create nil bucket")
+		return nil, ErrLedgerNilBucket
+	}
+	if ls.isStandby() {
+		return nil, ErrLedgerStandby
 	}
 	ex, err := ls.bucketExists(bucket)
 	if err != nil {
@@ -138,23 +199,78 @@ func (ls *ledgerStore) createBucket(ctx context.Context, bucket string, b *Bucke
 	if b.BucketInfo.Name == "" {
 		b.BucketInfo.Name = bucket
 	}
-	return ls.saveBucket(ctx, bucket, b)
+	return ls.saveBucket(ctx, bucket, b, func(w datastore.Write) error {
+		return putBucketInfoRecord(w, bucket, &b.BucketInfo)
+	})
 }
 
-func (ls *ledgerStore) saveBucket(ctx context.Context, bucket string, b *Bucket) (*LedgerBucketEntry, error) {
+// CloneBucket creates newBucket from b with the location and objects of bucket by copying the ledger references,
+// the data of every cloned object gains a reference, and the trash is not cloned.
+func (ls *ledgerStore) CloneBucket(ctx context.Context, bucket, newBucket string, b *Bucket, copyConfig bool) (string, int, error) {
+	// always lock in the same order, so concurrent clones in opposite directions can not deadlock
+	if bucket < newBucket {
+		defer ls.locker.read(bucket)()
+		defer ls.locker.write(newBucket)()
+	} else {
+		defer ls.locker.write(newBucket)()
+		defer ls.locker.read(bucket)()
+	}
+	src, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", 0, err
+	}
+	b.BucketInfo.Location = src.Bucket.BucketInfo.Location
+	b.Objects = make(map[string]string, len(src.Bucket.Objects))
+	dataHashes := make([]string, 0, len(src.Bucket.Objects))
+	for name, h := range src.Bucket.Objects {
+		dataHash, err := ls.objectDataHashNilable(ctx, bucket, name)
+		if err != nil {
+			return "", 0, err
+		}
+		b.Objects[name] = h
+		dataHashes = append(dataHashes, dataHash)
+	}
+	if copyConfig && src.Bucket.Config != nil {
+		c := *src.Bucket.Config
+		b.Config = &c
+	}
+	// reference the data before the clone links it, so it is never collected while in use
+	for i, h := range dataHashes {
+		if err := ls.addDataRef(h); err != nil {
+			_, _ = ls.releaseData(dataHashes[:i]) // the failed reference is more relevant
+			return "", 0, err
+		}
+	}
+	lb, err := ls.createBucket(ctx, newBucket, b)
+	if err != nil {
+		_, _ = ls.releaseData(dataHashes)
+		return "", 0, err
+	}
+	if err := ls.copyIndex(bucket, newBucket); err != nil {
+		return "", 0, err
+	}
+	return lb.IpfsHash, len(b.Objects), nil
+}
+
+// saveBucket saves a bucket to ipfs, and commits its hash with the given writes in a single datastore batch,
+// see commitBucket
+func (ls *ledgerStore) saveBucket(ctx context.Context, bucket string, b *Bucket, writes ...ledgerWrite) (*LedgerBucketEntry, error) {
 	//check if bucket is valid
 	if b.BucketInfo.Name != bucket {
 		return nil, fmt.Errorf("bucket name miss match %v != %v", bucket, b.BucketInfo.Name)
 	}
 
 	//save to ipfs and get hash
-	bHash, err := ipfsSave(ctx, ls.dag, b)
+	bHash, shards, err := ls.saveBucketBlocks(ctx, b)
 	if err != nil {
+		ls.evictBucket(bucket)
 		return nil, err
 	}
-	if err := ls.ds.Put(dsBucketKey.ChildString(bucket), []byte(bHash)); err != nil {
+	if err := ls.commitBucket(bucket, bHash, writes...); err != nil {
 		return nil, err
 	}
+	b.ObjectShards = shards
+	ls.changed.reset(bucket)
 
 	//save hash to ledger
 	lb := &LedgerBucketEntry{
@@ -164,6 +280,7 @@ func (ls *ledgerStore) saveBucket(ctx context.Context, bucket string, b *Bucket)
 	ls.mapLocker.Lock()
 	ls.l.Buckets[bucket] = lb
 	ls.mapLocker.Unlock()
+	ls.existence.set(bucket, true)
 	return lb, nil
 }
 
@@ -185,21 +302,82 @@ func (ls *ledgerStore) assertBucketExits(bucket string) error {
 	return nil
 }
 
-func (ls *ledgerStore) bucketExists(bucket string) (bool, error) {
-	b, err := ls.getBucketNilable(bucket)
-	return b != nil, err
-}
-
-// DeleteBucket is used to remove a ledger bucket entry
-func (ls *ledgerStore) DeleteBucket(bucket string) error {
+// DeleteBucket is used to remove a ledger bucket entry, buckets in WORM mode are only removed when empty
+func (ls *ledgerStore) DeleteBucket(ctx context.Context, bucket string) error {
 	defer ls.locker.write(bucket)()
-	err := ls.assertBucketExits(bucket)
+	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
 		return err
 	}
+	if wormRetention(b.Bucket.GetConfig()) > 0 && len(b.Bucket.Objects) > 0 {
+		return ErrLedgerNonEmptyBucket
+	}
 	ls.mapLocker.Lock()
 	delete(ls.l.Buckets, bucket)
 	ls.mapLocker.Unlock()
-	return ls.ds.Delete(dsBucketKey.ChildString(bucket))
+	ls.changed.reset(bucket)
+	if err := ls.deleteIndex(bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteListing(bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteIdempotencyRecords(bucket); err != nil {
+		return err
+	}
+	// the index and listing are removed first, so an interrupted deletion leaves a bucket that rebuilds them,
+	// the bucket and its info record are removed together
+	batch, err := ls.ds.Batch()
+	if err != nil {
+		return err
+	}
+	if err := deleteBucketInfoRecord(batch, bucket); err != nil {
+		return err
+	}
+	if err := batch.Delete(dsBucketKey.ChildString(bucket)); err != nil {
+		return err
+	}
+	if err := batch.Delete(dsDirectoryKey.ChildString(bucket)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	ls.existence.set(bucket, false)
+	return nil
 	//todo: remove from ipfs
 }
+
+// ObjectProof returns a proof with the hashes and raw IPFS blocks of a bucket, the shard of one of its objects if
+// the bucket is sharded, and the object, read under the same lock so the blocks are the ones linked from the bucket
+// block. The data hash of the proof is not set.
+func (ls *ledgerStore) ObjectProof(ctx context.Context, bucket, object string) (*ObjectProofResponse, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	objectHash, ok := b.Bucket.Objects[object]
+	if !ok {
+		return nil, ErrLedgerObjectDoesNotExist
+	}
+	proof := &ObjectProofResponse{
+		Bucket:     bucket,
+		Object:     object,
+		BucketHash: b.IpfsHash,
+		ObjectHash: objectHash,
+	}
+	if proof.BucketBlock, err = ipfsBytes(ctx, ls.dag, b.IpfsHash); err != nil {
+		return nil, err
+	}
+	if shards := b.Bucket.ObjectShards; len(shards) > 0 {
+		proof.ShardHash = shards[shardOf(object, len(shards))]
+		if proof.ShardBlock, err = ipfsBytes(ctx, ls.dag, proof.ShardHash); err != nil {
+			return nil, err
+		}
+	}
+	if proof.ObjectBlock, err = ipfsBytes(ctx, ls.dag, objectHash); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
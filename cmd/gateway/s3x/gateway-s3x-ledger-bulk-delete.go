@@ -0,0 +1,106 @@
+package s3x
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// bulkRemoval is the result of BulkRemoveObjects
+type bulkRemoval struct {
+	removed int
+	batches int
+	// missing are the requested objects that did not exist, and locked the objects that were kept
+	missing []string
+	locked  []string
+}
+
+// BulkRemoveObjects removes the objects of a bucket with the given names, or with prefix if names is empty, that are
+// not under a retention, a legal hold, or the WORM retention of the bucket at now. The objects are removed in batches of at most the batch size of the
+// ledger, each batch holds the bucket lock once, so other requests to the bucket are not blocked until all objects
+// are removed. The result counts the objects removed before an error.
+func (ls *ledgerStore) BulkRemoveObjects(ctx context.Context, bucket string, names []string, prefix string, now time.Time) (*bulkRemoval, error) {
+	requested := make(map[string]bool, len(names))
+	for _, n := range names {
+		requested[n] = true
+	}
+	candidates, err := ls.SearchObjects(ctx, bucket, func(info *ObjectInfo) bool {
+		if len(names) > 0 {
+			return requested[info.Name]
+		}
+		return strings.HasPrefix(info.Name, prefix)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := &bulkRemoval{}
+	if len(names) > 0 {
+		for _, c := range candidates {
+			delete(requested, c.Name)
+		}
+		for n := range requested {
+			r.missing = append(r.missing, n)
+		}
+	}
+	for len(candidates) > 0 {
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+		batch := candidates
+		if len(batch) > ls.batchSize {
+			batch = batch[:ls.batchSize]
+		}
+		candidates = candidates[len(batch):]
+		r.batches++
+		if err := ls.removeUnlockedObjects(ctx, bucket, batch, now, r); err != nil {
+			return r, err
+		}
+	}
+	sort.Strings(r.missing)
+	return r, nil
+}
+
+// removeUnlockedObjects removes the candidates that still exist and are neither locked nor retained by the WORM
+// retention of the bucket at now, and adds them to r
+func (ls *ledgerStore) removeUnlockedObjects(ctx context.Context, bucket string, candidates []*ObjectInfo, now time.Time, r *bulkRemoval) error {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	retention := wormRetention(b.Bucket.GetConfig())
+	unlocked := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		data, err := ls.ds.Get(ls.indexObjectKey(bucket, c.Name))
+		if err == datastore.ErrNotFound {
+			// removed since it was found
+			r.missing = append(r.missing, c.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		info := &ObjectInfo{}
+		if err := info.Unmarshal(data); err != nil {
+			return err
+		}
+		if objectLocked(info, now) || wormRetained(info.ModTime, retention, now) {
+			r.locked = append(r.locked, c.Name)
+			continue
+		}
+		unlocked = append(unlocked, c.Name)
+	}
+	if len(unlocked) == 0 {
+		return nil
+	}
+	missing, err := ls.removeObjects(ctx, bucket, unlocked...)
+	if err != nil {
+		return err
+	}
+	r.removed += len(unlocked) - len(missing)
+	r.missing = append(r.missing, missing...)
+	return nil
+}
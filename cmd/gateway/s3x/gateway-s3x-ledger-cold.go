@@ -0,0 +1,55 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+Data moved to the cold TemporalX cluster is recorded under dsColdKey by data hash, since objects that share data
+share where it is stored. Reads of recorded data go to the cold cluster, and the record is removed with the data
+by garbage collection. Records are only added while the data is referenced, so a transition that races with a
+collection does not leave a record behind.
+*/
+
+// dsColdKey maps the hashes of data moved to the cold cluster to their ColdData
+var dsColdKey = datastore.NewKey("f")
+
+// ColdData returns the record of data moved to the cold cluster, and false if the data was not moved
+func (ls *ledgerStore) ColdData(dataHash string) (*ColdData, bool, error) {
+	data, err := ls.ds.Get(dsColdKey.ChildString(dataHash))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	c := &ColdData{}
+	if err := c.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	return c, true, nil
+}
+
+// PutColdData records that data was moved to the cold cluster, and returns false if the data is no longer referenced
+func (ls *ledgerStore) PutColdData(dataHash string, c *ColdData) (bool, error) {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	if n, err := ls.dataRefCount(dataHash); err != nil || n == 0 {
+		return false, err
+	}
+	data, err := c.Marshal()
+	if err != nil {
+		return false, err
+	}
+	return true, ls.ds.Put(dsColdKey.ChildString(dataHash), data)
+}
+
+// deleteColdData removes the cold record of a data hash, the caller holds rlocker
+func (ls *ledgerStore) deleteColdData(dataHash string) error {
+	if err := ls.ds.Delete(dsColdKey.ChildString(dataHash)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	return nil
+}
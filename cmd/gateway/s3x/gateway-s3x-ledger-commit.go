@@ -0,0 +1,80 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+The ledger is write-through: a mutation returns after everything it changed is in the datastore, the
+loaded buckets in memory are only a cache of the bucket hashes in the datastore. Every mutation of the
+objects of a bucket saves the new bucket to IPFS, and then commits the new bucket hash in a single datastore
+batch with the entries that describe the changed objects, the search index entries and listing records. After
+a crash the datastore either holds the previous bucket hash and entries, or the new ones, never a bucket with
+the entries of another version of it.
+
+If the bucket can not be saved or the batch can not be committed, the cached bucket, which the mutation
+already changed, is evicted, so the next access loads the bucket hash that was committed. Data references
+are kept outside of the batch, a reference is added before the batch that links the data, and released after
+the batch that unlinks it, so an interrupted mutation can only keep unused data, never collect used data.
+Entries that are removed in bounded batches, such as the entries of a deleted bucket, are removed before
+the entry that makes them visible, so an interrupted removal leaves entries that are rebuilt or ignored.
+*/
+
+// ledgerWrite is a datastore write that is committed in the same batch as a bucket hash
+type ledgerWrite func(w datastore.Write) error
+
+// objectWrites returns the writes that index an object, save its listing record, and record the hold of a locked
+// object on its data
+func (ls *ledgerStore) objectWrites(bucket, object, objectHash string, obj *Object) ledgerWrite {
+	return func(w datastore.Write) error {
+		if err := ls.indexObject(w, bucket, object, &obj.ObjectInfo); err != nil {
+			return err
+		}
+		if err := ls.putDataHold(w, bucket, object, obj); err != nil {
+			return err
+		}
+		return ls.putListingRecord(w, bucket, object, objectHash, &obj.ObjectInfo)
+	}
+}
+
+// removedObjectWrites returns the writes that remove objects from the index and the listing
+func (ls *ledgerStore) removedObjectWrites(bucket string, objects ...string) ledgerWrite {
+	return func(w datastore.Write) error {
+		if err := ls.unindexObjects(w, bucket, objects...); err != nil {
+			return err
+		}
+		return ls.deleteListingRecords(w, bucket, objects...)
+	}
+}
+
+// commitBucket commits the hash of a bucket together with the given writes, the cached bucket is evicted
+// if the commit fails
+func (ls *ledgerStore) commitBucket(bucket, hash string, writes ...ledgerWrite) (err error) {
+	defer func() {
+		if err != nil {
+			ls.evictBucket(bucket)
+		}
+	}()
+	batch, err := ls.ds.Batch()
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(dsBucketKey.ChildString(bucket), []byte(hash)); err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if err := write(batch); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// evictBucket removes a bucket from the cache, so it is loaded from its committed hash when accessed next
+func (ls *ledgerStore) evictBucket(bucket string) {
+	ls.mapLocker.Lock()
+	delete(ls.l.Buckets, bucket)
+	ls.mapLocker.Unlock()
+}
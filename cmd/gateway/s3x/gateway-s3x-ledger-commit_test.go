@@ -0,0 +1,249 @@
+package s3x
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// committedObjects returns the object hashes of a bucket as committed to the datastore, by loading
+// the bucket into a new ledger, and checks that the index and listing match the committed objects
+func committedObjects(t *testing.T, ds datastore.Batching, dag *memoryDag, bucket string) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	ls, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := 0
+	if err := ls.forEachIndexEntry(bucket, func(query.Entry) error {
+		indexed++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != len(b.Bucket.Objects) {
+		t.Fatalf("expected %d index entries, but got %d", len(b.Bucket.Objects), indexed)
+	}
+	listed := 0
+	if err := ls.forEachEntry(query.Query{Prefix: listingBucketKey(bucket).String(), KeysOnly: true}, func(query.Entry) error {
+		listed++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if listed != len(b.Bucket.Objects) {
+		t.Fatalf("expected %d listing records, but got %d", len(b.Bucket.Objects), listed)
+	}
+	for name, h := range b.Bucket.Objects {
+		data, err := ls.ds.Get(ls.listingObjectKey(bucket, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r := &ListingRecord{}
+		if err := r.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if r.GetObjectHash() != h {
+			t.Fatalf("expected the listing record of %s to be made from %s, but got %s", name, h, r.GetObjectHash())
+		}
+		if has, err := ls.ds.Has(ls.indexObjectKey(bucket, name)); err != nil || !has {
+			t.Fatalf("expected %s to be indexed, but got %v, %v", name, has, err)
+		}
+	}
+	return b.Bucket.Objects
+}
+
+// checkCommitted fails unless exactly the given objects of a bucket are committed
+func checkCommitted(t *testing.T, ds datastore.Batching, dag *memoryDag, bucket string, objects ...string) map[string]string {
+	t.Helper()
+	committed := committedObjects(t, ds, dag, bucket)
+	var names []string
+	for name := range committed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(objects)
+	if len(names) != len(objects) {
+		t.Fatalf("expected objects %v to be committed, but got %v", objects, names)
+	}
+	for i := range names {
+		if names[i] != objects[i] {
+			t.Fatalf("expected objects %v to be committed, but got %v", objects, names)
+		}
+	}
+	return committed
+}
+
+func testLedgerObject(bucket, object, data string) *Object {
+	return &Object{
+		DataHash:   "data-" + data,
+		ObjectInfo: ObjectInfo{Bucket: bucket, Name: object, Size_: int64(len(data))},
+	}
+}
+
+func TestS3X_LedgerCommit(t *testing.T) {
+	ctx := context.Background()
+	ls, ds, dag := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{Config: &BucketConfig{TrashRetentionDays: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := ls.ds.Has(dsBucketInfoKey.ChildString(testBucket1)); err != nil || !has {
+		t.Fatalf("expected the bucket info record to be committed with the bucket, but got %v, %v", has, err)
+	}
+	checkCommitted(t, ds, dag, testBucket1)
+	for _, o := range []string{"a", "b", "c"} {
+		if err := ls.PutObject(ctx, testBucket1, o, testLedgerObject(testBucket1, o, o)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkCommitted(t, ds, dag, testBucket1, "a", "b", "c")
+	if err := ls.RemoveObject(ctx, testBucket1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	checkCommitted(t, ds, dag, testBucket1, "b", "c")
+	if _, err := ls.RestoreObject(ctx, testBucket1, "a", false); err != nil {
+		t.Fatal(err)
+	}
+	checkCommitted(t, ds, dag, testBucket1, "a", "b", "c")
+	if _, err := ls.RenameObject(ctx, testBucket1, "c", "d", false); err != nil {
+		t.Fatal(err)
+	}
+	checkCommitted(t, ds, dag, testBucket1, "a", "b", "d")
+	if _, err := ls.RemoveObjects(ctx, testBucket1, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	checkCommitted(t, ds, dag, testBucket1, "d")
+	if err := ls.UpdateBucketConfig(ctx, testBucket1, func(c *BucketConfig) error {
+		c.Versioning = &VersioningConfig{Enabled: true}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	original := checkCommitted(t, ds, dag, testBucket1, "d")["d"]
+	if err := ls.PutObject(ctx, testBucket1, "d", testLedgerObject(testBucket1, "d", "new d")); err != nil {
+		t.Fatal(err)
+	}
+	if checkCommitted(t, ds, dag, testBucket1, "d")["d"] == original {
+		t.Fatal("expected the replaced object to be committed")
+	}
+	_, versions, err := ls.ListObjectVersions(ctx, testBucket1, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions["d"].Versions) != 1 {
+		t.Fatalf("expected 1 version, but got %+v", versions)
+	}
+	if _, _, err := ls.RestoreObjectVersion(ctx, testBucket1, "d", versions["d"].Versions[0].VersionId); err != nil {
+		t.Fatal(err)
+	}
+	if checkCommitted(t, ds, dag, testBucket1, "d")["d"] != original {
+		t.Fatal("expected the restored version to be committed")
+	}
+	if _, _, err := ls.CloneBucket(ctx, testBucket1, testBucket2, &Bucket{}, true); err != nil {
+		t.Fatal(err)
+	}
+	// the clone copies the index, its listing records are written when it is listed
+	clone, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects, err := clone.getBucketLoaded(ctx, testBucket2); err != nil || objects.Bucket.Objects["d"] != original {
+		t.Fatalf("expected the clone to be committed, but got %v", err)
+	}
+	if has, err := clone.ds.Has(clone.indexObjectKey(testBucket2, "d")); err != nil || !has {
+		t.Fatalf("expected the clone to be indexed, but got %v, %v", has, err)
+	}
+	if err := ls.DeleteBucket(ctx, testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := reopened.BucketExists(testBucket1); err != nil || exists {
+		t.Fatalf("expected the deleted bucket not to exist, but got %v, %v", exists, err)
+	}
+	for _, prefix := range []datastore.Key{indexBucketKey(testBucket1), listingBucketKey(testBucket1), dsBucketInfoKey.ChildString(testBucket1)} {
+		if err := reopened.forEachEntry(query.Query{Prefix: prefix.String(), KeysOnly: true}, func(e query.Entry) error {
+			t.Fatalf("expected no entries of the deleted bucket, but got %v", e.Key)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestS3X_LedgerCommitFault(t *testing.T) {
+	ctx := context.Background()
+	ls, ds, dag := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []string{"a", "b"} {
+		if err := ls.PutObject(ctx, testBucket1, o, testLedgerObject(testBucket1, o, o)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	committed := checkCommitted(t, ds, dag, testBucket1, "a", "b")
+	ds.failCommit = true
+	for name, mutate := range map[string]func() error{
+		"PutObject": func() error {
+			return ls.PutObject(ctx, testBucket1, "c", testLedgerObject(testBucket1, "c", "c"))
+		},
+		"ReplaceObject": func() error {
+			return ls.PutObject(ctx, testBucket1, "a", testLedgerObject(testBucket1, "a", "new a"))
+		},
+		"RemoveObject": func() error {
+			return ls.RemoveObject(ctx, testBucket1, "a")
+		},
+		"RenameObject": func() error {
+			_, err := ls.RenameObject(ctx, testBucket1, "a", "c", false)
+			return err
+		},
+		"UpdateBucketConfig": func() error {
+			return ls.UpdateBucketConfig(ctx, testBucket1, func(c *BucketConfig) error {
+				c.TrashRetentionDays = 1
+				return nil
+			})
+		},
+		"CreateBucket": func() error {
+			_, err := ls.CreateBucket(ctx, testBucket2, &Bucket{})
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := mutate(); err != errInjected {
+				t.Fatalf("expected the injected fault, but got %v", err)
+			}
+			for o, h := range checkCommitted(t, ds, dag, testBucket1, "a", "b") {
+				if h != committed[o] {
+					t.Fatalf("expected %s to be unchanged after the failed commit", o)
+				}
+			}
+			// the ledger does not keep the change that was not committed
+			b, err := ls.getBucketLoaded(ctx, testBucket1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b.Bucket.Objects) != 2 || b.Bucket.Objects["a"] != committed["a"] || b.Bucket.GetConfig().GetTrashRetentionDays() != 0 {
+				t.Fatalf("expected the ledger to reload the committed bucket, but got %+v", b.Bucket)
+			}
+			if exists, err := ls.BucketExists(testBucket2); err != nil || exists {
+				t.Fatalf("expected the bucket that was not committed not to exist, but got %v, %v", exists, err)
+			}
+		})
+	}
+	ds.failCommit = false
+	if err := ls.RemoveObject(ctx, testBucket1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	checkCommitted(t, ds, dag, testBucket1, "b")
+}
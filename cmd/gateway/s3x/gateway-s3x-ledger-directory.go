@@ -0,0 +1,64 @@
+package s3x
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+The directory view of a bucket is a UnixFS directory tree of its objects, built from a committed bucket state.
+Building it reads every object of the bucket, so the last built directory is recorded under dsDirectoryKey by
+bucket name, with the hash of the bucket it was built from. Every mutation of a bucket commits a new bucket hash,
+so a recorded directory is current exactly while its bucket hash is the committed one, also for changes made by
+other gateways sharing a crdt ledger, and is rebuilt otherwise. The record is removed with the bucket.
+*/
+
+// dsDirectoryKey maps bucket names to the BucketDirectory last built for the bucket
+var dsDirectoryKey = datastore.NewKey("d")
+
+// BucketState returns the committed hash of a bucket, and a copy of its object names to object hashes,
+// read under the same lock so they are the same state
+func (ls *ledgerStore) BucketState(ctx context.Context, bucket string) (string, map[string]string, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", nil, err
+	}
+	objects := make(map[string]string, len(b.Bucket.Objects))
+	for name, hash := range b.Bucket.Objects {
+		objects[name] = hash
+	}
+	return b.IpfsHash, objects, nil
+}
+
+// BucketDirectory returns the directory last built for a bucket, and false if none was built
+func (ls *ledgerStore) BucketDirectory(bucket string) (*BucketDirectory, bool, error) {
+	data, err := ls.ds.Get(dsDirectoryKey.ChildString(bucket))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	d := &BucketDirectory{}
+	if err := d.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	return d, true, nil
+}
+
+// PutBucketDirectory records the directory built for a bucket, unless the bucket was deleted
+func (ls *ledgerStore) PutBucketDirectory(bucket string, d *BucketDirectory) error {
+	defer ls.locker.read(bucket)()
+	if err := ls.assertBucketExits(bucket); err != nil {
+		return err
+	}
+	data, err := d.Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsDirectoryKey.ChildString(bucket), data)
+}
@@ -0,0 +1,77 @@
+package s3x
+
+import (
+	"context"
+	"time"
+)
+
+// DumpBucket returns up to max ledger entries of the objects of a bucket with names after the given name, ordered by
+// name, at most the batch size of the ledger if max is larger or not positive. The first page, without after, also
+// has the multipart uploads to the bucket in progress at now. Objects are loaded from IPFS for their data hashes.
+func (ls *ledgerStore) DumpBucket(ctx context.Context, bucket, after string, max int, now time.Time) (*LedgerDump, error) {
+	dump, err := ls.dumpObjects(ctx, bucket, after, max)
+	if err != nil {
+		return nil, err
+	}
+	if after != "" {
+		return dump, nil
+	}
+	uploads, err := ls.ListMultipartUploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range uploads {
+		if m.GetObjectInfo().GetBucket() == bucket {
+			dump.Multipart = append(dump.Multipart, multipartSession(m, now))
+		}
+	}
+	return dump, nil
+}
+
+// dumpObjects returns the bucket entries and a page of the object entries of a dump
+func (ls *ledgerStore) dumpObjects(ctx context.Context, bucket, after string, max int) (*LedgerDump, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 || max > ls.batchSize {
+		max = ls.batchSize
+	}
+	objs := b.GetBucket().GetObjects()
+	names, truncated := firstNames(objs, "", after, max)
+	dump := &LedgerDump{
+		Bucket:  bucket,
+		Hash:    b.GetIpfsHash(),
+		Created: b.GetBucket().BucketInfo.Created.Unix(),
+		Objects: int64(len(objs)),
+		Entries: make([]*LedgerDumpObject, 0, len(names)),
+	}
+	for _, name := range names {
+		obj, err := ipfsObject(ctx, ls.dag, objs[name])
+		if err != nil {
+			return nil, err
+		}
+		e := &LedgerDumpObject{
+			Name:       name,
+			ObjectHash: objs[name],
+			DataHash:   obj.GetDataHash(),
+			Size_:      obj.ObjectInfo.Size_,
+			Etag:       obj.ObjectInfo.Etag,
+			ModTime:    obj.ObjectInfo.ModTime.Unix(),
+		}
+		versions := b.GetBucket().GetVersions()[name]
+		for _, v := range versions.GetVersions() {
+			e.Versions = append(e.Versions, &LedgerDumpVersion{
+				VersionId:  v.GetVersionId(),
+				ObjectHash: v.GetObjectHash(),
+				Noncurrent: v.Noncurrent.Unix(),
+			})
+		}
+		dump.Entries = append(dump.Entries, e)
+	}
+	if truncated {
+		dump.Next = names[len(names)-1]
+	}
+	return dump, nil
+}
@@ -0,0 +1,72 @@
+package s3x
+
+import "sync"
+
+/* Design Notes
+---------------
+
+HeadBucket and the existence checks of object requests only need to know whether a bucket exists,
+so they are answered from the bucket entries already in the ledger cache, a bounded existence cache,
+or a keyed Has on the datastore, without reading the bucket hash or decoding the bucket from ipfs.
+
+The existence cache also remembers missing buckets, so probing names that do not exist does not
+grow the ledger cache. It is updated when buckets are saved or deleted, and reset once it is full.
+*/
+
+// maxBucketExistence is the number of bucket names kept in the existence cache
+const maxBucketExistence = 4096
+
+// bucketExistence caches whether bucket names exist
+type bucketExistence struct {
+	mu       sync.Mutex
+	exists   map[string]bool
+	disabled bool // nothing is cached if set
+}
+
+// get returns whether a bucket exists, and false for ok if it is not cached
+func (e *bucketExistence) get(bucket string) (exists, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.disabled {
+		return false, false
+	}
+	exists, ok = e.exists[bucket]
+	return exists, ok
+}
+
+// set records whether a bucket exists
+func (e *bucketExistence) set(bucket string, exists bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.disabled {
+		return
+	}
+	if e.exists == nil || len(e.exists) >= maxBucketExistence {
+		e.exists = make(map[string]bool)
+	}
+	e.exists[bucket] = exists
+}
+
+// BucketExists returns true if the bucket exists, without loading it
+func (ls *ledgerStore) BucketExists(bucket string) (bool, error) {
+	defer ls.locker.read(bucket)()
+	return ls.bucketExists(bucket)
+}
+
+func (ls *ledgerStore) bucketExists(bucket string) (bool, error) {
+	ls.mapLocker.Lock()
+	b := ls.l.Buckets[bucket]
+	ls.mapLocker.Unlock()
+	if b != nil {
+		return true, nil
+	}
+	if exists, ok := ls.existence.get(bucket); ok {
+		return exists, nil
+	}
+	exists, err := ls.ds.Has(dsBucketKey.ChildString(bucket))
+	if err != nil {
+		return false, err
+	}
+	ls.existence.set(bucket, exists)
+	return exists, nil
+}
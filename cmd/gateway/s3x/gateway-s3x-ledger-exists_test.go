@@ -0,0 +1,61 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestS3X_LedgerBucketExists(t *testing.T) {
+	ctx := context.Background()
+	ls, ds, dag := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	// a new ledger on the same datastore has no cached buckets, and must not load them to check existence
+	ls, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag.fail = true
+	for i := 0; i < 2; i++ {
+		if exists, err := ls.BucketExists(testBucket1); err != nil || !exists {
+			t.Fatalf("expected %v to exist, but got %v, %v", testBucket1, exists, err)
+		}
+		if exists, err := ls.BucketExists(testBucket2); err != nil || exists {
+			t.Fatalf("expected %v not to exist, but got %v, %v", testBucket2, exists, err)
+		}
+	}
+	if err := ls.AssertBucketExits(testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if len(ls.l.Buckets) != 0 {
+		t.Fatalf("expected no buckets to be cached by existence checks, but got %v", len(ls.l.Buckets))
+	}
+	dag.fail = false
+	if _, err := ls.CreateBucket(ctx, testBucket2, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := ls.BucketExists(testBucket2); err != nil || !exists {
+		t.Fatalf("expected the created bucket to exist, but got %v, %v", exists, err)
+	}
+	if err := ls.DeleteBucket(ctx, testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := ls.BucketExists(testBucket1); err != nil || exists {
+		t.Fatalf("expected the deleted bucket not to exist, but got %v, %v", exists, err)
+	}
+}
+
+func TestS3X_BucketExistenceLimit(t *testing.T) {
+	var e bucketExistence
+	for i := 0; i < maxBucketExistence+1; i++ {
+		e.set(fmt.Sprint("bucket", i), false)
+	}
+	if len(e.exists) > maxBucketExistence {
+		t.Fatalf("expected at most %v cached buckets, but got %v", maxBucketExistence, len(e.exists))
+	}
+	if exists, ok := e.get(fmt.Sprint("bucket", maxBucketExistence)); !ok || exists {
+		t.Fatal("expected the last bucket to be cached as missing")
+	}
+}
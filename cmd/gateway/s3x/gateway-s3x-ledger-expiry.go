@@ -0,0 +1,128 @@
+package s3x
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+A bucket can have a default object TTL, after which objects are expired by removing them like a delete request,
+so expired objects go to the trash or become noncurrent versions if the bucket keeps them. Objects uploaded with
+their own expiration in objectTTLMetadataKey are expired after their own number of days instead, 0 keeps them.
+Objects under a legal hold or an unexpired retention are not expired until their lock is released.
+
+Expired objects are found with the object index under the read lock of the bucket, and checked again under
+the write lock before they are removed, so an object that was replaced since it was found is kept.
+*/
+
+// objectTTLMetadataKey is the user metadata key of the number of days after which an object expires,
+// it overrides the object TTL of its bucket
+const objectTTLMetadataKey = "x-amz-meta-s3x-ttl-days"
+
+// objectExpiry returns when an object expires with the default object TTL of its bucket,
+// false if it does not expire, invalid object TTLs are ignored
+func objectExpiry(info *ObjectInfo, defaultDays int64) (time.Time, bool) {
+	days := defaultDays
+	if v := userDefinedValue(info.GetUserDefined(), objectTTLMetadataKey); v != "" {
+		if d, err := strconv.ParseInt(v, 10, 64); err == nil && d >= 0 {
+			days = d
+		}
+	}
+	if days <= 0 {
+		return time.Time{}, false
+	}
+	return info.ModTime.Add(time.Duration(days) * 24 * time.Hour), true
+}
+
+// objectExpired returns true if an object of a bucket with the config expired at now, and is neither locked nor
+// retained by the WORM retention of the bucket
+func objectExpired(info *ObjectInfo, config *BucketConfig, now time.Time) bool {
+	expires, ok := objectExpiry(info, config.GetObjectTTLDays())
+	return ok && !expires.After(now) && !objectLocked(info, now) && !wormRetained(info.ModTime, wormRetention(config), now)
+}
+
+// ExpireObjects removes the objects of all buckets that expired at now, it returns the number of expired objects
+func (ls *ledgerStore) ExpireObjects(ctx context.Context, now time.Time) (int, error) {
+	names, err := ls.GetBucketNames()
+	if err != nil {
+		return 0, err
+	}
+	var expired int
+	for _, bucket := range names {
+		if err := ctx.Err(); err != nil {
+			return expired, err
+		}
+		n, err := ls.expireBucketObjects(ctx, bucket, now)
+		expired += n
+		if err != nil && err != ErrLedgerBucketDoesNotExist { // the bucket might be deleted concurrently
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+func (ls *ledgerStore) expireBucketObjects(ctx context.Context, bucket string, now time.Time) (int, error) {
+	config, err := ls.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return 0, err
+	}
+	candidates, err := ls.SearchObjects(ctx, bucket, func(info *ObjectInfo) bool {
+		return objectExpired(info, config, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var expired int
+	for len(candidates) > 0 {
+		batch := candidates
+		if len(batch) > ls.batchSize {
+			batch = batch[:ls.batchSize]
+		}
+		candidates = candidates[len(batch):]
+		n, err := ls.removeExpiredObjects(ctx, bucket, batch, now)
+		expired += n
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// removeExpiredObjects removes the candidates that are still expired at now, and returns how many were removed
+func (ls *ledgerStore) removeExpiredObjects(ctx context.Context, bucket string, candidates []*ObjectInfo, now time.Time) (int, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return 0, err
+	}
+	expired := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		data, err := ls.ds.Get(ls.indexObjectKey(bucket, c.Name))
+		if err == datastore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		info := &ObjectInfo{}
+		if err := info.Unmarshal(data); err != nil {
+			return 0, err
+		}
+		if objectExpired(info, b.Bucket.GetConfig(), now) {
+			expired = append(expired, c.Name)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	missing, err := ls.removeObjects(ctx, bucket, expired...)
+	if err != nil {
+		return 0, err
+	}
+	return len(expired) - len(missing), nil
+}
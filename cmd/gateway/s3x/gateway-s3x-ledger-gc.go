@@ -0,0 +1,177 @@
+package s3x
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Data hashes whose reference count drops to zero are not dropped silently, they are enqueued in the
+garbage queue of the ledger under dsGarbageKey, with the time they became unreferenced. A data hash that
+gains a reference again, for example because the same data is uploaded again, leaves the queue, so only
+data that no object, version, trash entry, or snapshot points to is ever collected from the queue.
+
+Replacing an object is a single bucket save: the new data is referenced before the bucket is saved, and
+the replaced data is released after, so a failure in between can leak a reference but never release data
+that is still in use. If the save fails the in memory bucket is rolled back, so readers never observe
+an object that was not persisted.
+
+CollectGarbage removes the data of queued hashes that stayed unreferenced for a grace period. Objects,
+versions, trash entries, snapshots, multipart parts, and the file nodes that link data, see the notes on
+dsLinkKey, all count as references, so a queued hash is only checked again for its reference count and for
+the data holds of locked objects, see the notes on dsHoldKey, before its data is removed. Each hash is checked
+and removed under rlocker, so it can not gain a reference in between, except by an upload of the same data,
+which stores the data before it references it: the grace period keeps data that was released recently, but
+data uploaded again while it is collected can be lost. The links of a collected file node are released.
+A dry run reports what would be collected and held without removing anything.
+*/
+
+// dsGarbageKey is the prefix of unreferenced data hashes, to the time they became unreferenced
+var dsGarbageKey = datastore.NewKey("g")
+
+// enqueueGarbage adds an unreferenced data hash to the garbage queue, the caller holds rlocker
+func (ls *ledgerStore) enqueueGarbage(hash string) error {
+	now, err := ls.clock.Now().UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsGarbageKey.ChildString(hash), now)
+}
+
+// dequeueGarbage removes a data hash from the garbage queue if it is queued, the caller holds rlocker
+func (ls *ledgerStore) dequeueGarbage(hash string) error {
+	key := dsGarbageKey.ChildString(hash)
+	queued, err := ls.ds.Has(key)
+	if err != nil || !queued {
+		return err
+	}
+	return ls.ds.Delete(key)
+}
+
+// GarbageData returns the queued unreferenced data hashes, to the time they became unreferenced,
+// ForEachGarbage should be used instead if the queue can be large
+func (ls *ledgerStore) GarbageData(ctx context.Context) (map[string]time.Time, error) {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	garbage := make(map[string]time.Time)
+	err := ls.forEachGarbage(ctx, func(hash string, unreferenced time.Time) error {
+		garbage[hash] = unreferenced
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return garbage, nil
+}
+
+// ForEachGarbage calls fn with each queued unreferenced data hash and the time it became unreferenced,
+// as the queue is read, so the queue is never held in memory. The queue can change while it is read,
+// so a data hash must be checked to still be unreferenced before its data is collected.
+func (ls *ledgerStore) ForEachGarbage(ctx context.Context, fn func(hash string, unreferenced time.Time) error) error {
+	return ls.forEachGarbage(ctx, fn)
+}
+
+func (ls *ledgerStore) forEachGarbage(ctx context.Context, fn func(hash string, unreferenced time.Time) error) error {
+	return ls.forEachEntry(query.Query{Prefix: dsGarbageKey.String()}, func(e query.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var t time.Time
+		if err := t.UnmarshalText(e.Value); err != nil {
+			return err
+		}
+		return fn(datastore.NewKey(e.Key).BaseNamespace(), t)
+	})
+}
+
+// garbageCollection is the result of a garbage collection
+type garbageCollection struct {
+	collected []string   // the data hashes whose data was removed, or would be in a dry run
+	held      []HeldData // the holds that keep unreferenced data
+	pending   int64      // the number of data hashes that are still in their grace period
+}
+
+// CollectGarbage removes the data of the queued data hashes that were unreferenced for longer than grace at now
+// with remove, unless the data is held by a locked object. If dryRun is set nothing is removed.
+func (ls *ledgerStore) CollectGarbage(ctx context.Context, now time.Time, grace time.Duration, dryRun bool, remove func(hash string) error) (*garbageCollection, error) {
+	gc := &garbageCollection{}
+	err := ls.forEachGarbage(ctx, func(hash string, unreferenced time.Time) error {
+		if unreferenced.Add(grace).After(now) {
+			gc.pending++
+			return nil
+		}
+		return ls.collectData(hash, now, dryRun, remove, gc)
+	})
+	return gc, err
+}
+
+// collectData removes the data of a queued data hash if it is still unreferenced and not held
+func (ls *ledgerStore) collectData(hash string, now time.Time, dryRun bool, remove func(hash string) error, gc *garbageCollection) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	// the data may have been referenced again since the queue was read
+	queued, err := ls.ds.Has(dsGarbageKey.ChildString(hash))
+	if err != nil || !queued {
+		return err
+	}
+	if n, err := ls.dataRefCount(hash); err != nil || n > 0 {
+		return err
+	}
+	held, err := ls.activeDataHolds(hash, now)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		gc.held = append(gc.held, held...)
+		return nil
+	}
+	gc.collected = append(gc.collected, hash)
+	if dryRun {
+		return nil
+	}
+	if err := remove(hash); err != nil {
+		return err
+	}
+	if err := ls.deleteDataHolds(hash); err != nil {
+		return err
+	}
+	if err := ls.deletePinStatus(hash); err != nil {
+		return err
+	}
+	if err := ls.deleteColdData(hash); err != nil {
+		return err
+	}
+	if err := ls.dequeuePin(hash); err != nil {
+		return err
+	}
+	if err := ls.dequeueGarbage(hash); err != nil {
+		return err
+	}
+	// the data linked by a collected file node is queued once nothing else references it
+	return ls.releaseDataLinks(hash)
+}
+
+// keepObjectEntry records the current object and versions of a name in the in memory bucket,
+// and returns a function that restores them if the bucket could not be saved
+func (ls *ledgerStore) keepObjectEntry(b *Bucket, object string) (restore func()) {
+	h, hasObject := b.Objects[object]
+	v, hasVersions := b.Versions[object]
+	versions := append([]ObjectVersion(nil), v.Versions...)
+	return func() {
+		if hasObject {
+			ls.setObject(b, object, h)
+		} else {
+			ls.deleteObject(b, object)
+		}
+		if hasVersions {
+			b.Versions[object] = ObjectVersions{Versions: versions}
+		} else {
+			delete(b.Versions, object)
+		}
+	}
+}
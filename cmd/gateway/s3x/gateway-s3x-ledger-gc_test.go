@@ -0,0 +1,84 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+)
+
+func TestS3X_LedgerGarbage(t *testing.T) {
+	ctx := context.Background()
+	ls, ds, _ := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	assertGarbage := func(t *testing.T, want ...string) {
+		t.Helper()
+		garbage, err := ls.GarbageData(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(garbage) != len(want) {
+			t.Fatalf("expected garbage %v, but got %v", want, garbage)
+		}
+		for _, h := range want {
+			if _, ok := garbage[h]; !ok {
+				t.Fatalf("expected %v to be garbage, but got %v", h, garbage)
+			}
+		}
+	}
+	assertDataHash := func(t *testing.T, want string) {
+		t.Helper()
+		obj, err := ls.object(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.GetDataHash() != want {
+			t.Fatalf("expected data hash %v, but got %v", want, obj.GetDataHash())
+		}
+	}
+	if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Overwrite", func(t *testing.T) {
+		if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data2"}); err != nil {
+			t.Fatal(err)
+		}
+		assertDataHash(t, "data2")
+		assertGarbage(t, "data1")
+	})
+	t.Run("SameData", func(t *testing.T) {
+		if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data2"}); err != nil {
+			t.Fatal(err)
+		}
+		assertGarbage(t, "data1")
+		if n, err := ls.DataRefCount("data2"); err != nil || n != 1 {
+			t.Fatalf("expected 1 reference to the overwritten data, but got %v, %v", n, err)
+		}
+	})
+	t.Run("Rereferenced", func(t *testing.T) {
+		if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data1"}); err != nil {
+			t.Fatal(err)
+		}
+		assertGarbage(t, "data2")
+	})
+	t.Run("FailedSave", func(t *testing.T) {
+		ds.failPrefix = dsBucketKey
+		if err := ls.PutObject(ctx, testBucket1, testObject1, &Object{DataHash: "data3"}); err != errInjected {
+			t.Fatal("expected the injected error, but got: ", err)
+		}
+		ds.failPrefix = datastore.Key{}
+		assertDataHash(t, "data1")
+		assertGarbage(t, "data2", "data3")
+		if n, err := ls.DataRefCount("data1"); err != nil || n != 1 {
+			t.Fatalf("expected the current data to keep its reference, but got %v, %v", n, err)
+		}
+	})
+	t.Run("Remove", func(t *testing.T) {
+		if err := ls.RemoveObject(ctx, testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		}
+		assertGarbage(t, "data1", "data2", "data3")
+	})
+}
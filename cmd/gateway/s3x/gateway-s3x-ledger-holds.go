@@ -0,0 +1,139 @@
+package s3x
+
+import (
+	"strings"
+	"time"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/pkg/bucket/object/lock"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+The object lock of an object, its retention and legal hold, is kept in its metadata, so it ends with the
+object: a locked object that is removed anyway, by an expiration or a bucket deletion, would leave its data
+to garbage collection. To keep the data of locked objects, every write of an object with a lock records a
+data hold under dsHoldKey, by data hash and object name, in the same batch as the object. The garbage
+collector keeps unreferenced data while any of its holds has a legal hold or an unexpired retention, and
+removes expired holds with the data. A write of the object that releases its lock removes its hold.
+
+Holds are kept per object name instead of per data hash, so objects that share data do not release the
+holds of each other. Only objects that were written with lock metadata have holds.
+*/
+
+// dsHoldKey maps data hashes and object names to the DataHold of the object
+var dsHoldKey = datastore.NewKey("k")
+
+// dataHoldKey returns the key of the hold of an object on a data hash
+func (ls *ledgerStore) dataHoldKey(dataHash, bucket, object string) datastore.Key {
+	return dsHoldKey.ChildString(dataHash).ChildString(bucket).ChildString(ls.names.encode(bucket, object))
+}
+
+// isObjectLockMetadataKey returns true for the metadata keys of the retention and legal hold of an object
+func isObjectLockMetadataKey(k string) bool {
+	switch strings.ToLower(k) {
+	case strings.ToLower(xhttp.AmzObjectLockMode), strings.ToLower(xhttp.AmzObjectLockRetainUntilDate),
+		strings.ToLower(xhttp.AmzObjectLockLegalHold):
+		return true
+	}
+	return false
+}
+
+// objectDataHold returns the object lock of an object, and false if it has no lock metadata
+func objectDataHold(info *ObjectInfo) (DataHold, bool) {
+	var locked bool
+	meta := make(map[string]string)
+	for k, v := range info.GetUserDefined() {
+		if isObjectLockMetadataKey(k) {
+			meta[strings.ToLower(k)] = v
+			locked = true
+		}
+	}
+	if !locked {
+		return DataHold{}, false
+	}
+	retention := lock.GetObjectRetentionMeta(meta)
+	return DataHold{
+		RetainUntil: retention.RetainUntilDate.Time,
+		LegalHold:   lock.GetObjectLegalHoldMeta(meta).Status == lock.ON,
+	}, true
+}
+
+// active returns true if the hold keeps its data at now
+func (h DataHold) active(now time.Time) bool {
+	return h.LegalHold || h.RetainUntil.After(now)
+}
+
+// objectLocked returns true if an object is under a legal hold or a retention at now
+func objectLocked(info *ObjectInfo, now time.Time) bool {
+	h, ok := objectDataHold(info)
+	return ok && h.active(now)
+}
+
+// putDataHold records the object lock of an object on its data, or removes the hold of an unlocked object
+func (ls *ledgerStore) putDataHold(w datastore.Write, bucket, object string, obj *Object) error {
+	h, ok := objectDataHold(&obj.ObjectInfo)
+	if !ok || obj.GetDataHash() == "" {
+		return nil // erasure coded objects have no data hash
+	}
+	key := ls.dataHoldKey(obj.GetDataHash(), bucket, object)
+	if !h.LegalHold && h.RetainUntil.IsZero() {
+		if err := w.Delete(key); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+	data, err := h.Marshal()
+	if err != nil {
+		return err
+	}
+	return w.Put(key, data)
+}
+
+// activeDataHolds returns the holds on a data hash that keep it at now, the caller holds rlocker
+func (ls *ledgerStore) activeDataHolds(dataHash string, now time.Time) ([]HeldData, error) {
+	parent := dsHoldKey.ChildString(dataHash)
+	var held []HeldData
+	err := ls.forEachEntry(query.Query{Prefix: parent.String()}, func(e query.Entry) error {
+		key := datastore.NewKey(e.Key)
+		// skip the holds of data hashes starting with this data hash
+		if !key.Parent().Parent().Equal(parent) {
+			return nil
+		}
+		var h DataHold
+		if err := h.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		if !h.active(now) {
+			return nil
+		}
+		bucket := key.Parent().BaseNamespace()
+		object, err := ls.names.decode(bucket, key.BaseNamespace())
+		if err != nil {
+			return err
+		}
+		d := HeldData{
+			DataHash:  dataHash,
+			Bucket:    bucket,
+			Object:    object,
+			LegalHold: h.LegalHold,
+		}
+		if !h.RetainUntil.IsZero() {
+			d.RetainUntil = h.RetainUntil.Unix()
+		}
+		held = append(held, d)
+		return nil
+	})
+	return held, err
+}
+
+// deleteDataHolds removes the holds on a data hash, the caller holds rlocker
+func (ls *ledgerStore) deleteDataHolds(dataHash string) error {
+	parent := dsHoldKey.ChildString(dataHash)
+	return ls.deleteKeys(parent, func(key datastore.Key) bool {
+		return key.Parent().Parent().Equal(parent)
+	})
+}
@@ -0,0 +1,131 @@
+package s3x
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// dsIdempotencyKey maps bucket and object names and idempotency keys to the IdempotencyRecord of the upload
+var dsIdempotencyKey = datastore.NewKey("e")
+
+// idempotencyBucketKey returns the parent key of the idempotency records of a bucket
+func idempotencyBucketKey(bucket string) datastore.Key {
+	return dsIdempotencyKey.ChildString(bucket)
+}
+
+// idempotencyRecordKey returns the key of the record of the upload of an object with an idempotency key,
+// the key is hex encoded since clients choose it
+func (ls *ledgerStore) idempotencyRecordKey(bucket, object, key string) datastore.Key {
+	return idempotencyBucketKey(bucket).ChildString(ls.names.encode(bucket, object)).ChildString(hex.EncodeToString([]byte(key)))
+}
+
+// idempotencyRecord returns the record of the upload of an object with an idempotency key, and false if there
+// is none or it is older than idempotencyWindow at now
+func (ls *ledgerStore) idempotencyRecord(bucket, object, key string, now time.Time) (*IdempotencyRecord, bool, error) {
+	data, err := ls.ds.Get(ls.idempotencyRecordKey(bucket, object, key))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	r := &IdempotencyRecord{}
+	if err := r.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	return r, !r.expired(now), nil
+}
+
+// expired returns true if the record is no longer returned to retries at now
+func (r *IdempotencyRecord) expired(now time.Time) bool {
+	return !r.Created.Add(idempotencyWindow).After(now)
+}
+
+// PutObjectIdempotent saves an object like PutObject, and records it as the result of the upload with an
+// idempotency key in the same batch. If an upload of the object with the key is already recorded, nothing is
+// saved and the object info of the retry with the recorded ETag, size and modification time is returned with true,
+// or ErrIdempotencyKeyReused if the data differs. Records do not keep the object name, see the notes on dsNamesKey.
+func (ls *ledgerStore) PutObjectIdempotent(ctx context.Context, bucket, object, key string, obj *Object) (*ObjectInfo, bool, error) {
+	defer ls.locker.write(bucket)()
+	now := ls.clock.Now()
+	r, ok, err := ls.idempotencyRecord(bucket, object, key, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		if r.GetDataHash() != contentAddress(obj) {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		info := obj.ObjectInfo
+		info.Etag, info.Size_, info.ModTime = r.GetEtag(), r.GetSize_(), r.GetModTime()
+		return &info, true, nil
+	}
+	r = &IdempotencyRecord{
+		DataHash: contentAddress(obj),
+		Created:  now.UTC(),
+		Etag:     obj.ObjectInfo.GetEtag(),
+		Size_:    obj.ObjectInfo.GetSize_(),
+		ModTime:  obj.ObjectInfo.GetModTime(),
+	}
+	if err := ls.putObject(ctx, bucket, object, obj, ls.idempotencyRecordWrite(bucket, object, key, r)); err != nil {
+		return nil, false, err
+	}
+	return &obj.ObjectInfo, false, nil
+}
+
+// contentAddress returns the hash of the data of an object, or the hashes of the shards of erasure coded data,
+// which are the same for uploads of the same data
+func contentAddress(obj *Object) string {
+	if obj.GetDataHash() != "" {
+		return obj.GetDataHash()
+	}
+	return strings.Join(obj.GetErasure().GetShardHashes(), ",")
+}
+
+// idempotencyRecordWrite returns the write that saves the record of an upload with an idempotency key
+func (ls *ledgerStore) idempotencyRecordWrite(bucket, object, key string, r *IdempotencyRecord) ledgerWrite {
+	return func(w datastore.Write) error {
+		data, err := r.Marshal()
+		if err != nil {
+			return err
+		}
+		return w.Put(ls.idempotencyRecordKey(bucket, object, key), data)
+	}
+}
+
+// PruneIdempotencyRecords removes the records of uploads with idempotency keys that expired at now, and returns
+// the number of removed records
+func (ls *ledgerStore) PruneIdempotencyRecords(now time.Time) (int, error) {
+	var expired []datastore.Key
+	if err := ls.forEachEntry(query.Query{Prefix: dsIdempotencyKey.String()}, func(e query.Entry) error {
+		var r IdempotencyRecord
+		if err := r.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		if r.expired(now) {
+			expired = append(expired, datastore.NewKey(e.Key))
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for _, key := range expired {
+		if err := ls.ds.Delete(key); err != nil && err != datastore.ErrNotFound {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// deleteIdempotencyRecords removes the idempotency records of all objects of a bucket
+func (ls *ledgerStore) deleteIdempotencyRecords(bucket string) error {
+	parent := idempotencyBucketKey(bucket)
+	return ls.deleteKeys(parent, func(key datastore.Key) bool {
+		return key.Parent().Parent().Equal(parent)
+	})
+}
@@ -0,0 +1,142 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// dsImportKey maps import ids to their ImportJob, and import ids and source object names to their ImportObjectResult
+var dsImportKey = datastore.NewKey("j")
+
+// importJobKey returns the key of an import job, the results of its objects are its children
+func importJobKey(id string) datastore.Key {
+	return dsImportKey.ChildString(id)
+}
+
+// importResultKey returns the key of the result of an object of an import, with the object name encoded like
+// the ledger keys of the destination bucket
+func (ls *ledgerStore) importResultKey(job *ImportJob, object string) datastore.Key {
+	return importJobKey(job.GetId()).ChildString(ls.names.encode(job.GetBucket(), object))
+}
+
+// PutImportJob saves the progress of an import
+func (ls *ledgerStore) PutImportJob(job *ImportJob) error {
+	data, err := ls.names.marshalImportJob(job)
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(importJobKey(job.GetId()), data)
+}
+
+// GetImportJob returns the last saved progress of an import
+func (ls *ledgerStore) GetImportJob(id string) (*ImportJob, error) {
+	data, err := ls.ds.Get(importJobKey(id))
+	if err == datastore.ErrNotFound {
+		return nil, ErrImportDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ls.names.unmarshalImportJob(data)
+}
+
+// PutImportResult saves the result of an object of an import
+func (ls *ledgerStore) PutImportResult(job *ImportJob, r *ImportObjectResult) error {
+	data, err := ls.names.marshalImportResult(job.GetBucket(), r)
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(ls.importResultKey(job, r.GetName()), data)
+}
+
+// GetImportResult returns the result of an object of an import, nil if the object was not processed
+func (ls *ledgerStore) GetImportResult(job *ImportJob, object string) (*ImportObjectResult, error) {
+	data, err := ls.ds.Get(ls.importResultKey(job, object))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ls.names.unmarshalImportResult(job.GetBucket(), data)
+}
+
+// ImportResults returns up to max results of an import in the order of their keys, after the result of the given
+// name, only of failed objects if failedOnly is set, and true if more results follow. At most the batch size of the
+// ledger is returned if max is larger or not positive.
+func (ls *ledgerStore) ImportResults(job *ImportJob, after string, max int, failedOnly bool) ([]*ImportObjectResult, bool, error) {
+	if max <= 0 || max > ls.batchSize {
+		max = ls.batchSize
+	}
+	parent := importJobKey(job.GetId())
+	start := ls.importResultKey(job, after).String()
+	results := make([]*ImportObjectResult, 0, max)
+	truncated := false
+	err := ls.forEachEntry(query.Query{Prefix: parent.String(), Orders: []query.Order{query.OrderByKey{}}}, func(e query.Entry) error {
+		key := datastore.NewKey(e.Key)
+		if truncated || !key.Parent().Equal(parent) || (after != "" && e.Key <= start) {
+			return nil
+		}
+		r, err := ls.names.unmarshalImportResult(job.GetBucket(), e.Value)
+		if err != nil {
+			return err
+		}
+		if failedOnly && r.GetState() != importFailed {
+			return nil
+		}
+		if len(results) == max {
+			truncated = true
+			return nil
+		}
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return results, truncated, nil
+}
+
+// marshalImportJob returns the record of an import, with its marker encrypted if the bucket it imports into has a
+// name key
+func (n *objectNames) marshalImportJob(job *ImportJob) ([]byte, error) {
+	record := *job
+	record.Marker = n.encodeRecord(job.GetBucket(), job.GetMarker())
+	return record.Marshal()
+}
+
+// unmarshalImportJob returns the import of a record, see marshalImportJob
+func (n *objectNames) unmarshalImportJob(data []byte) (*ImportJob, error) {
+	job := &ImportJob{}
+	if err := job.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	marker, err := n.decodeRecord(job.GetBucket(), job.GetMarker())
+	if err != nil {
+		return nil, err
+	}
+	job.Marker = marker
+	return job, nil
+}
+
+// marshalImportResult returns the record of the result of an object of an import into bucket, with the object name
+// encrypted if the bucket has a name key
+func (n *objectNames) marshalImportResult(bucket string, r *ImportObjectResult) ([]byte, error) {
+	record := *r
+	record.Name = n.encodeRecord(bucket, r.GetName())
+	return record.Marshal()
+}
+
+// unmarshalImportResult returns the result of an object of an import into bucket, see marshalImportResult
+func (n *objectNames) unmarshalImportResult(bucket string, data []byte) (*ImportObjectResult, error) {
+	r := &ImportObjectResult{}
+	if err := r.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	name, err := n.decodeRecord(bucket, r.GetName())
+	if err != nil {
+		return nil, err
+	}
+	r.Name = name
+	return r, nil
+}
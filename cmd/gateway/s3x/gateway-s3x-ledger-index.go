@@ -0,0 +1,181 @@
+package s3x
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Searching objects by their metadata would need every object to be decoded from IPFS, so the ledger keeps
+a secondary index of the ObjectInfo of each object in the datastore, maintained whenever objects are written,
+renamed, restored, or removed.
+
+Buckets created before the index existed are indexed on their first search, an index is complete
+once its bucket marker key is set. Object names are base64 encoded, since datastore keys are cleaned as paths,
+or encrypted if the ledger has a name key, see objectNames.
+*/
+
+// dsIndexKey maps bucket and object names to the ObjectInfo of the object, the bucket key marks a complete index
+var dsIndexKey = datastore.NewKey("i")
+
+// indexBucketKey returns the key of the index marker of a bucket, and the parent of its object entries
+func indexBucketKey(bucket string) datastore.Key {
+	return dsIndexKey.ChildString(bucket)
+}
+
+// indexObjectKey returns the key of the index entry of an object
+func (ls *ledgerStore) indexObjectKey(bucket, object string) datastore.Key {
+	return indexBucketKey(bucket).ChildString(ls.names.encode(bucket, object))
+}
+
+// indexObject saves the info of an object to the index, without its name if names are encrypted
+func (ls *ledgerStore) indexObject(w datastore.Write, bucket, object string, info *ObjectInfo) error {
+	if ls.names.encrypts(bucket) {
+		unnamed := *info
+		unnamed.Name = ""
+		info = &unnamed
+	}
+	data, err := info.Marshal()
+	if err != nil {
+		return err
+	}
+	return w.Put(ls.indexObjectKey(bucket, object), data)
+}
+
+// unindexObjects removes objects from the index
+func (ls *ledgerStore) unindexObjects(w datastore.Write, bucket string, objects ...string) error {
+	for _, o := range objects {
+		if err := w.Delete(ls.indexObjectKey(bucket, o)); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachIndexEntry calls fn with each index entry of a bucket as it is read
+func (ls *ledgerStore) forEachIndexEntry(bucket string, fn func(query.Entry) error) error {
+	parent := indexBucketKey(bucket)
+	return ls.forEachEntry(query.Query{Prefix: parent.String()}, func(e query.Entry) error {
+		// skip the marker, and entries of buckets with names starting with this bucket name
+		if !datastore.NewKey(e.Key).Parent().Equal(parent) {
+			return nil
+		}
+		return fn(e)
+	})
+}
+
+// indexEntryInfo returns the object info of an index entry of a bucket
+func (ls *ledgerStore) indexEntryInfo(bucket string, e query.Entry) (*ObjectInfo, error) {
+	info := &ObjectInfo{}
+	if err := info.Unmarshal(e.Value); err != nil {
+		return nil, err
+	}
+	if ls.names.encrypts(bucket) {
+		name, err := ls.names.decode(bucket, datastore.NewKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		info.Name = name
+	}
+	return info, nil
+}
+
+// indexComplete returns true if all objects of a bucket are indexed
+func (ls *ledgerStore) indexComplete(bucket string) (bool, error) {
+	return ls.ds.Has(indexBucketKey(bucket))
+}
+
+// rebuildIndex indexes all objects of a bucket, and marks the index as complete
+func (ls *ledgerStore) rebuildIndex(ctx context.Context, bucket string) error {
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if err := ls.deleteKeys(indexBucketKey(bucket), childOf(indexBucketKey(bucket))); err != nil {
+		return err
+	}
+	for name := range b.Bucket.Objects {
+		obj, err := ls.object(ctx, bucket, name)
+		if err != nil {
+			return err
+		}
+		if err := ls.indexObject(ls.ds, bucket, name, &obj.ObjectInfo); err != nil {
+			return err
+		}
+	}
+	return ls.ds.Put(indexBucketKey(bucket), nil)
+}
+
+// copyIndex copies the index of bucket to newBucket, the copy is only complete if the source index is
+func (ls *ledgerStore) copyIndex(bucket, newBucket string) error {
+	if err := ls.forEachIndexEntry(bucket, func(e query.Entry) error {
+		info, err := ls.indexEntryInfo(bucket, e)
+		if err != nil {
+			return err
+		}
+		info.Bucket = newBucket
+		return ls.indexObject(ls.ds, newBucket, info.Name, info)
+	}); err != nil {
+		return err
+	}
+	complete, err := ls.indexComplete(bucket)
+	if err != nil || !complete {
+		return err
+	}
+	return ls.ds.Put(indexBucketKey(newBucket), nil)
+}
+
+// deleteIndex removes the index of a bucket, the marker is removed first, so an interrupted
+// deletion leaves an incomplete index that is rebuilt by the next search
+func (ls *ledgerStore) deleteIndex(bucket string) error {
+	if err := ls.ds.Delete(indexBucketKey(bucket)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	return ls.deleteKeys(indexBucketKey(bucket), childOf(indexBucketKey(bucket)))
+}
+
+// SearchObjects returns the info of all objects in a bucket for which match returns true, sorted by name,
+// the bucket is indexed first if needed.
+func (ls *ledgerStore) SearchObjects(ctx context.Context, bucket string, match func(*ObjectInfo) bool) ([]*ObjectInfo, error) {
+	unlock := ls.locker.read(bucket)
+	if err := ls.assertBucketExits(bucket); err != nil {
+		unlock()
+		return nil, err
+	}
+	complete, err := ls.indexComplete(bucket)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		unlock = ls.locker.write(bucket)
+		err = ls.rebuildIndex(ctx, bucket)
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer ls.locker.read(bucket)()
+	var infos []*ObjectInfo
+	if err := ls.forEachIndexEntry(bucket, func(e query.Entry) error {
+		info, err := ls.indexEntryInfo(bucket, e)
+		if err != nil {
+			return err
+		}
+		if match(info) {
+			infos = append(infos, info)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
@@ -0,0 +1,44 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// dsInventoryKey maps bucket names and inventory ids to the BucketInventory exported for the bucket
+var dsInventoryKey = datastore.NewKey("v")
+
+// PutBucketInventory records an inventory exported for a bucket, unless the bucket was deleted
+func (ls *ledgerStore) PutBucketInventory(inv *BucketInventory) error {
+	defer ls.locker.read(inv.GetBucket())()
+	if err := ls.assertBucketExits(inv.GetBucket()); err != nil {
+		return err
+	}
+	data, err := inv.Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsInventoryKey.ChildString(inv.GetBucket()).ChildString(inv.GetId()), data)
+}
+
+// BucketInventories returns the inventories exported for a bucket in the order of their ids, which order by the
+// second they were exported in. Inventories are kept when their bucket is deleted.
+func (ls *ledgerStore) BucketInventories(bucket string) ([]*BucketInventory, error) {
+	parent := dsInventoryKey.ChildString(bucket)
+	var inventories []*BucketInventory
+	err := ls.forEachEntry(query.Query{Prefix: parent.String(), Orders: []query.Order{query.OrderByKey{}}}, func(e query.Entry) error {
+		if !datastore.NewKey(e.Key).Parent().Equal(parent) {
+			return nil
+		}
+		inv := &BucketInventory{}
+		if err := inv.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		inventories = append(inventories, inv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inventories, nil
+}
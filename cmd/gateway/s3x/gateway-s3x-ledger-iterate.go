@@ -0,0 +1,130 @@
+package s3x
+
+import (
+	"container/heap"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Buckets can hold millions of objects, so listing, deleting, and collecting the ledger entries of a bucket
+never holds more than batchSize entries in memory, regardless of the size of the bucket:
+
+* datastore entries are streamed from queries one at a time with forEachEntry, instead of reading all
+  results of a query, so iterating the search index, listing records, or garbage queue holds one entry.
+* entries are deleted with deleteKeys, which streams the keys and commits a datastore batch whenever
+  batchSize keys are queued, so removing the records of a bucket holds at most batchSize keys.
+* listings return at most batchSize objects per page, and select the page from the names of the bucket
+  with a bounded heap, so only the names of the page are held besides the bucket itself. This trades
+  time for memory, every page is a pass over all names instead of a slice of a sorted copy of them.
+
+The name to object hash map of a loaded bucket is kept in memory as part of the bucket, like it always was,
+these guarantees bound the memory used on top of it. The batch size is configured with LedgerBatchSize.
+*/
+
+// defaultLedgerBatchSize is the default maximum number of entries held by listings, deletions, and garbage collection
+const defaultLedgerBatchSize = 1000
+
+// forEachEntry calls fn with each entry of a query as the entry is read, fn may write to the datastore
+func (ls *ledgerStore) forEachEntry(q query.Query, fn func(query.Entry) error) error {
+	rs, err := ls.ds.Query(q)
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	for r := range rs.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		if err := fn(r.Entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteKeys removes the keys with the given prefix for which match returns true, or all keys with the prefix
+// if match is nil, in batches of at most batchSize keys
+func (ls *ledgerStore) deleteKeys(prefix datastore.Key, match func(datastore.Key) bool) error {
+	batch, err := ls.ds.Batch()
+	if err != nil {
+		return err
+	}
+	queued := 0
+	if err := ls.forEachEntry(query.Query{Prefix: prefix.String(), KeysOnly: true}, func(e query.Entry) error {
+		key := datastore.NewKey(e.Key)
+		if match != nil && !match(key) {
+			return nil
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		if queued++; queued < ls.batchSize {
+			return nil
+		}
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+		queued = 0
+		batch, err = ls.ds.Batch()
+		return err
+	}); err != nil {
+		return err
+	}
+	if queued == 0 {
+		return nil
+	}
+	return batch.Commit()
+}
+
+// childOf returns a match function for deleteKeys that only matches the direct children of parent,
+// which skips the keys of buckets with names starting with the name of the bucket of parent
+func childOf(parent datastore.Key) func(datastore.Key) bool {
+	return func(key datastore.Key) bool {
+		return key.Parent().Equal(parent)
+	}
+}
+
+// nameHeap is a max heap of names, used to keep the first names in order without sorting all names
+type nameHeap []string
+
+func (h nameHeap) Len() int            { return len(h) }
+func (h nameHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h nameHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nameHeap) Push(x interface{}) { *h = append(*h, x.(string)) }
+func (h *nameHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// firstNames returns up to max names of objects in order, that start with prefix and come after the given
+// name, and true if more names follow, at most max+1 names are held while the objects are read.
+func firstNames(objects map[string]string, prefix, after string, max int) ([]string, bool) {
+	h := make(nameHeap, 0, max+1)
+	for name := range objects {
+		if !strings.HasPrefix(name, prefix) || name <= after {
+			continue
+		}
+		if len(h) <= max {
+			heap.Push(&h, name)
+		} else if name < h[0] {
+			h[0] = name
+			heap.Fix(&h, 0)
+		}
+	}
+	truncated := len(h) > max
+	if truncated {
+		heap.Pop(&h)
+	}
+	names := make([]string, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		names[i] = heap.Pop(&h).(string)
+	}
+	return names, truncated
+}
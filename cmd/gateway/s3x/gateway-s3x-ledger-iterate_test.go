@@ -0,0 +1,246 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+func TestFirstNames(t *testing.T) {
+	objects := map[string]string{"a/1": "", "a/2": "", "a/3": "", "b/1": "", "c": ""}
+	testCases := []struct {
+		prefix, after string
+		max           int
+		names         []string
+		truncated     bool
+	}{
+		{"", "", 10, []string{"a/1", "a/2", "a/3", "b/1", "c"}, false},
+		{"", "", 5, []string{"a/1", "a/2", "a/3", "b/1", "c"}, false},
+		{"", "", 2, []string{"a/1", "a/2"}, true},
+		{"", "a/2", 2, []string{"a/3", "b/1"}, true},
+		{"a/", "a/1", 2, []string{"a/2", "a/3"}, false},
+		{"", "c", 2, []string{}, false},
+		{"d", "", 2, []string{}, false},
+	}
+	for i, tc := range testCases {
+		names, truncated := firstNames(objects, tc.prefix, tc.after, tc.max)
+		if !reflect.DeepEqual(names, tc.names) || truncated != tc.truncated {
+			t.Fatalf("test %d: expected %v %v, but got %v %v", i+1, tc.names, tc.truncated, names, truncated)
+		}
+	}
+}
+
+// batchCountingDatastore records the largest number of operations committed in a single batch
+type batchCountingDatastore struct {
+	datastore.Batching
+	largest int
+}
+
+func (d *batchCountingDatastore) Batch() (datastore.Batch, error) {
+	b, err := d.Batching.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &countingBatch{Batch: b, ds: d}, nil
+}
+
+type countingBatch struct {
+	datastore.Batch
+	ds  *batchCountingDatastore
+	ops int
+}
+
+func (b *countingBatch) Put(key datastore.Key, value []byte) error {
+	b.ops++
+	return b.Batch.Put(key, value)
+}
+
+func (b *countingBatch) Delete(key datastore.Key) error {
+	b.ops++
+	return b.Batch.Delete(key)
+}
+
+func (b *countingBatch) Commit() error {
+	if b.ops > b.ds.largest {
+		b.ds.largest = b.ops
+	}
+	return b.Batch.Commit()
+}
+
+func TestS3X_BoundedMemory_Badger(t *testing.T) {
+	testS3XBoundedMemory(t, DSTypeBadger)
+}
+func TestS3X_BoundedMemory_Crdt(t *testing.T) {
+	testS3XBoundedMemory(t, DSTypeCrdt)
+}
+
+// testS3XBoundedMemory creates a synthetic bucket with S3X_STRESS_OBJECTS objects, 10000 by default,
+// set it to 1000000 or more to stress test buckets with millions of objects.
+func testS3XBoundedMemory(t *testing.T, dsType DSType) {
+	count := 10000
+	if v := os.Getenv("S3X_STRESS_OBJECTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count = n
+	}
+	const batchSize = 100
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ls := gateway.ledgerStore
+	ls.batchSize = batchSize
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "seed", getTestPutObjectReader(t, []byte("seed")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := ls.Object(ctx, testBucket1, "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objHash, err := ls.GetObjectHash(ctx, testBucket1, "seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the synthetic objects are added to the bucket, which is saved in shards once, and their ledger
+	// entries are written directly
+	record, err := newListingRecord(objHash, &obj.ObjectInfo).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := obj.ObjectInfo.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreferenced, err := time.Time{}.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ls.getBucketLoaded(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := ls.ds.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("synthetic/%08d", i)
+		ls.setObject(b.Bucket, name, objHash)
+		for key, value := range map[datastore.Key][]byte{
+			ls.listingObjectKey(testBucket1, name):                record,
+			ls.indexObjectKey(testBucket1, name):                  info,
+			dsGarbageKey.ChildString(fmt.Sprintf("garbage%d", i)): unreferenced,
+		} {
+			if err := batch.Put(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if (i+1)%10000 == 0 || i == count-1 {
+			if err := batch.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			if batch, err = ls.ds.Batch(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := ls.saveBucket(ctx, testBucket1, b.Bucket); err != nil {
+		t.Fatal(err)
+	}
+	// the tests load the bucket from its shards
+	ls.evictBucket(testBucket1)
+	if b, err = ls.getBucketLoaded(ctx, testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Bucket.Objects) != count+1 || len(b.Bucket.ObjectShards) != shardCount(count+1) {
+		t.Fatalf("expected %d objects in %d shards, but got %d in %d", count+1, shardCount(count+1), len(b.Bucket.Objects), len(b.Bucket.ObjectShards))
+	}
+	counting := &batchCountingDatastore{Batching: ls.ds}
+	ls.ds = counting
+
+	t.Run("List", func(t *testing.T) {
+		// every page reads all names of the bucket, so only the first, second, and last pages are listed
+		expectPage := func(t *testing.T, loi minio.ListObjectsV2Info, first, n int, truncated bool) {
+			t.Helper()
+			if len(loi.Objects) != n || loi.IsTruncated != truncated {
+				t.Fatalf("expected %d objects and truncated %v, but got %d and %v", n, truncated, len(loi.Objects), loi.IsTruncated)
+			}
+			for i, o := range loi.Objects {
+				if name := fmt.Sprintf("synthetic/%08d", first+i); o.Name != name {
+					t.Fatalf("expected object %d to be %s, but got %s", i, name, o.Name)
+				}
+			}
+		}
+		loi, err := gateway.ListObjectsV2(ctx, testBucket1, "synthetic/", "", "", 1000, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectPage(t, loi, 0, batchSize, true)
+		loi, err = gateway.ListObjectsV2(ctx, testBucket1, "synthetic/", loi.NextContinuationToken, "", 1000, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectPage(t, loi, batchSize, batchSize, true)
+		loi, err = gateway.ListObjectsV2(ctx, testBucket1, "synthetic/", "", "", 1000, false, fmt.Sprintf("synthetic/%08d", count-batchSize/2-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectPage(t, loi, count-batchSize/2, batchSize/2, false)
+		list, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Objects) != batchSize || !list.IsTruncated || list.NextMarker != list.Objects[batchSize-1].Name {
+			t.Fatalf("unexpected first page of %d objects, truncated %v, next marker %s", len(list.Objects), list.IsTruncated, list.NextMarker)
+		}
+	})
+	t.Run("Garbage", func(t *testing.T) {
+		queued := 0
+		if err := ls.ForEachGarbage(ctx, func(hash string, _ time.Time) error {
+			queued++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if queued < count {
+			t.Fatalf("expected at least %d queued data hashes, but got %d", count, queued)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := gateway.DeleteBucket(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if counting.largest > batchSize {
+			t.Fatalf("expected batches of at most %d deletions, but got %d", batchSize, counting.largest)
+		}
+		for _, prefix := range []datastore.Key{listingBucketKey(testBucket1), indexBucketKey(testBucket1)} {
+			rs, err := ls.ds.Query(query.Query{Prefix: prefix.String(), KeysOnly: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := rs.Rest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Fatalf("expected the entries under %s to be deleted, but %d remain", prefix, len(entries))
+			}
+		}
+	})
+}
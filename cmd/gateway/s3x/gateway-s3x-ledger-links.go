@@ -0,0 +1,89 @@
+package s3x
+
+import (
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+The data of composed, appended, chunked and multipart objects is a unixfs file node that links the data of other
+hashes, which can also be the data of other objects or of the parts of uploads. Garbage collection removes the
+root block of unreferenced data, so linked data must stay referenced while a file node links it. The links of a
+file node are recorded under dsLinkKey when it is saved, and each linked hash gains one reference, once per file
+node no matter how many objects use it. The file node itself is referenced by its objects like any other data,
+and once it is collected its links are released, so linked data that nothing else references is queued in turn.
+
+A file node that nothing references yet is queued when its links are recorded, and leaves the queue once the
+object that uses it is saved, so the links of a file node whose object failed to be saved are released after the
+grace period. The record is deleted before the links are released, and the links are referenced before the record
+is written, so a failure in between can leak references but never release data that is still linked.
+*/
+
+// dsLinkKey is the prefix of the data hashes of file nodes, to the data hashes they link to
+var dsLinkKey = datastore.NewKey("m")
+
+// AddDataLinks records the data hashes a file node links to, and references each of them until the
+// file node is collected
+func (ls *ledgerStore) AddDataLinks(hash string, linked []string) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	key := dsLinkKey.ChildString(hash)
+	recorded, err := ls.ds.Has(key)
+	if err != nil || recorded {
+		return err
+	}
+	seen := make(map[string]bool, len(linked))
+	unique := make([]string, 0, len(linked))
+	for _, h := range linked {
+		if h == "" || h == hash || seen[h] {
+			continue
+		}
+		seen[h] = true
+		unique = append(unique, h)
+	}
+	for _, h := range unique {
+		if err := ls.incrementDataRef(h); err != nil {
+			return err
+		}
+	}
+	if err := ls.ds.Put(key, []byte(strings.Join(unique, "\n"))); err != nil {
+		return err
+	}
+	if n, err := ls.dataRefCount(hash); err != nil || n > 0 {
+		return err
+	}
+	return ls.enqueueGarbage(hash)
+}
+
+// dataLinks returns the data hashes a file node links to, or nil if its links are not recorded
+func (ls *ledgerStore) dataLinks(hash string) ([]string, error) {
+	data, err := ls.ds.Get(dsLinkKey.ChildString(hash))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	return strings.Split(string(data), "\n"), nil
+}
+
+// releaseDataLinks releases the references of a collected file node to the data it links to,
+// the caller holds rlocker
+func (ls *ledgerStore) releaseDataLinks(hash string) error {
+	linked, err := ls.dataLinks(hash)
+	if err != nil {
+		return err
+	}
+	if err := ls.ds.Delete(dsLinkKey.ChildString(hash)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	for _, h := range linked {
+		if _, err := ls.decrementDataRef(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestS3X_LedgerDataLinks(t *testing.T) {
+	ctx := context.Background()
+	ls, _, _ := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	collect := func(t *testing.T, want ...string) {
+		t.Helper()
+		var removed []string
+		if _, err := ls.CollectGarbage(ctx, time.Now(), 0, false, func(hash string) error {
+			removed = append(removed, hash)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(removed)
+		if !reflect.DeepEqual(removed, want) {
+			t.Fatalf("expected %v to be collected, but got %v", want, removed)
+		}
+	}
+	refs := func(t *testing.T, hash string, want int64) {
+		t.Helper()
+		if n, err := ls.DataRefCount(hash); err != nil || n != want {
+			t.Fatalf("expected %d references to %s, but got %d, %v", want, hash, n, err)
+		}
+	}
+	// the source object and the file node share data-a
+	if err := ls.PutObject(ctx, testBucket1, "source", testLedgerObject(testBucket1, "source", "a")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		// the links of a file node are only referenced once
+		if err := ls.AddDataLinks("file", []string{"data-a", "data-b", "data-a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs(t, "data-a", 2)
+	refs(t, "data-b", 1)
+	composite := testLedgerObject(testBucket1, "composite", "")
+	composite.DataHash = "file"
+	if err := ls.PutObject(ctx, testBucket1, "composite", composite); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("SourceRemoved", func(t *testing.T) {
+		if err := ls.RemoveObject(ctx, testBucket1, "source"); err != nil {
+			t.Fatal(err)
+		}
+		refs(t, "data-a", 1)
+		collect(t)
+	})
+	t.Run("FileRemoved", func(t *testing.T) {
+		if err := ls.RemoveObject(ctx, testBucket1, "composite"); err != nil {
+			t.Fatal(err)
+		}
+		collect(t, "file")
+		refs(t, "data-a", 0)
+		collect(t, "data-a", "data-b")
+	})
+	t.Run("FailedSave", func(t *testing.T) {
+		// a file node whose object was never saved releases its links once it is collected
+		if err := ls.AddDataLinks("orphan", []string{"data-c"}); err != nil {
+			t.Fatal(err)
+		}
+		refs(t, "data-c", 1)
+		collect(t, "orphan")
+		collect(t, "data-c")
+	})
+}
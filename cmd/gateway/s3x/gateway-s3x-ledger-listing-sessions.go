@@ -0,0 +1,93 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+/* Design Notes
+---------------
+
+A paginated listing reads every page from the bucket at the time of the page, so an object written between two
+pages is listed if its name sorts after the previous page, and an object removed between two pages is skipped,
+while the pages of a listing are expected to list the bucket at a single point in time. Buckets are immutable
+IPFS DAGs, so the bucket at the time of the first page is the hash the bucket had then, and a listing session
+lists every page of that hash, like a snapshot records a bucket by its hash.
+
+Listing a bucket hash needs the object map of its bucket DAG, which is loaded from IPFS, so the object maps of
+the most recently listed hashes are kept in memory, and a session only loads its snapshot once while it is
+listed. Once the bucket changes, the listing records of the current objects do not match the objects of the
+snapshot, so the objects of a snapshot that are not current are loaded from IPFS, and their records are not
+replaced, since the listing records belong to the current bucket. Bucket and object DAGs are never collected, so
+every snapshot can be listed.
+*/
+
+// listingSessionCacheSize is how many bucket snapshots of listing sessions are kept in memory
+const listingSessionCacheSize = 16
+
+// listingSessions caches the object maps of the bucket snapshots listed by listing sessions, by bucket hash
+type listingSessions struct {
+	mu      sync.Mutex
+	buckets map[string]*listingSnapshot
+	used    uint64 // incremented by each listing, to evict the least recently listed snapshot
+}
+
+// listingSnapshot is the object map of a bucket snapshot
+type listingSnapshot struct {
+	objects map[string]string
+	used    uint64
+}
+
+// objects returns the object map of a bucket hash, which is loaded from IPFS unless it is cached
+func (s *listingSessions) objects(ctx context.Context, dag pb.NodeAPIClient, bucketHash string) (map[string]string, error) {
+	s.mu.Lock()
+	s.used++
+	if snapshot, ok := s.buckets[bucketHash]; ok {
+		snapshot.used = s.used
+		s.mu.Unlock()
+		return snapshot.objects, nil
+	}
+	s.mu.Unlock()
+	b, err := ipfsBucket(ctx, dag, bucketHash)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets == nil {
+		s.buckets = make(map[string]*listingSnapshot)
+	}
+	if len(s.buckets) >= listingSessionCacheSize {
+		evict := ""
+		for h, snapshot := range s.buckets {
+			if evict == "" || snapshot.used < s.buckets[evict].used {
+				evict = h
+			}
+		}
+		delete(s.buckets, evict)
+	}
+	s.buckets[bucketHash] = &listingSnapshot{objects: b.GetObjects(), used: s.used}
+	return b.GetObjects(), nil
+}
+
+// GetObjectInfosAt is GetObjectInfos of the bucket at bucketHash, the hash of the bucket when a listing session
+// started, or of the current bucket if bucketHash is empty, and returns the hash of the listed bucket.
+func (ls *ledgerStore) GetObjectInfosAt(ctx context.Context, bucket, bucketHash, prefix, after string, max int) ([]ObjectInfo, string, bool, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if bucketHash == "" || bucketHash == b.IpfsHash {
+		list, truncated, err := ls.objectInfos(ctx, bucket, b.GetBucket().GetObjects(), prefix, after, max, true)
+		return list, b.IpfsHash, truncated, err
+	}
+	objs, err := ls.sessions.objects(ctx, ls.dag, bucketHash)
+	if err != nil {
+		return nil, "", false, err
+	}
+	list, truncated, err := ls.objectInfos(ctx, bucket, objs, prefix, after, max, false)
+	return list, bucketHash, truncated, err
+}
@@ -0,0 +1,160 @@
+package s3x
+
+import (
+	"context"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+Listing objects only needs their size, modification time, and etag, but objects are stored on IPFS with
+their data hash and all their metadata, so the ledger keeps a compact listing record of each object in the
+datastore, and listings read the records instead of loading the objects from IPFS.
+
+A record holds the hash of the object it was made from, and is stale once the bucket maps the object name
+to another hash. Records are written whenever the ledger writes an object, stale and missing records, such as
+the records of objects written before records existed or synced from other nodes, are written by the
+listings that read them, so a listing only loads the objects that changed since they were last listed.
+
+Records keep the encryption metadata and parts of encrypted objects, the S3 handlers need them to list the
+decrypted sizes and etags, and the object tagging, which filtered listings match, but no other metadata.
+Object names are encoded like in the search index.
+
+Records have the version of the format they were written with, records of older versions lack fields and
+are stale like records of replaced objects.
+*/
+
+// listingRecordVersion is the version of the format of listing records, see ListingRecord
+const listingRecordVersion = 1
+
+// dsListingKey maps bucket and object names to the ListingRecord of the object
+var dsListingKey = datastore.NewKey("l")
+
+// listingBucketKey returns the parent key of the listing records of a bucket
+func listingBucketKey(bucket string) datastore.Key {
+	return dsListingKey.ChildString(bucket)
+}
+
+// listingObjectKey returns the key of the listing record of an object
+func (ls *ledgerStore) listingObjectKey(bucket, object string) datastore.Key {
+	return listingBucketKey(bucket).ChildString(ls.names.encode(bucket, object))
+}
+
+// newListingRecord returns the listing record of the object info of an object hash
+func newListingRecord(objectHash string, info *ObjectInfo) *ListingRecord {
+	r := &ListingRecord{
+		ObjectHash:   objectHash,
+		Size_:        info.GetSize_(),
+		ModTime:      info.GetModTime(),
+		Etag:         info.GetEtag(),
+		StorageClass: info.GetStorageClass(),
+		ContentType:  info.GetContentType(),
+		Tagging:      userDefinedValue(info.GetUserDefined(), xhttp.AmzObjectTagging),
+		Version:      listingRecordVersion,
+	}
+	for k, v := range info.GetUserDefined() {
+		if isEncryptionMetadataKey(k) {
+			if r.Encryption == nil {
+				r.Encryption = make(map[string]string)
+			}
+			r.Encryption[k] = v
+		}
+	}
+	if len(r.Encryption) > 0 {
+		for _, p := range info.GetParts() {
+			r.Parts = append(r.Parts, ObjectPartInfo{
+				Number:     p.GetNumber(),
+				Size_:      p.GetSize_(),
+				ActualSize: p.GetActualSize(),
+				Etag:       p.GetEtag(),
+			})
+		}
+	}
+	return r
+}
+
+// objectInfo returns the object info of a listed object
+func (r *ListingRecord) objectInfo(bucket, object string) ObjectInfo {
+	userDefined := r.GetEncryption()
+	if r.GetTagging() != "" {
+		userDefined = make(map[string]string, len(r.GetEncryption())+1)
+		for k, v := range r.GetEncryption() {
+			userDefined[k] = v
+		}
+		userDefined[xhttp.AmzObjectTagging] = r.GetTagging()
+	}
+	return ObjectInfo{
+		Bucket:       bucket,
+		Name:         object,
+		Size_:        r.GetSize_(),
+		ModTime:      r.GetModTime(),
+		Etag:         r.GetEtag(),
+		StorageClass: r.GetStorageClass(),
+		ContentType:  r.GetContentType(),
+		UserDefined:  userDefined,
+		Parts:        r.GetParts(),
+	}
+}
+
+// putListingRecord saves the listing record of the object info of an object hash
+func (ls *ledgerStore) putListingRecord(w datastore.Write, bucket, object, objectHash string, info *ObjectInfo) error {
+	return ls.saveListingRecord(w, bucket, object, newListingRecord(objectHash, info))
+}
+
+// saveListingRecord saves the listing record of an object
+func (ls *ledgerStore) saveListingRecord(w datastore.Write, bucket, object string, r *ListingRecord) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	return w.Put(ls.listingObjectKey(bucket, object), data)
+}
+
+// deleteListingRecords removes the listing records of objects
+func (ls *ledgerStore) deleteListingRecords(w datastore.Write, bucket string, objects ...string) error {
+	for _, o := range objects {
+		if err := w.Delete(ls.listingObjectKey(bucket, o)); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteListing removes the listing records of all objects of a bucket
+func (ls *ledgerStore) deleteListing(bucket string) error {
+	return ls.deleteKeys(listingBucketKey(bucket), childOf(listingBucketKey(bucket)))
+}
+
+// listingInfo returns the object info of an object hash for listings, from the listing record of
+// the object if it is current, otherwise the object is loaded, and its listing record is replaced if save is set
+func (ls *ledgerStore) listingInfo(ctx context.Context, bucket, object, objectHash string, save bool) (ObjectInfo, error) {
+	data, err := ls.ds.Get(ls.listingObjectKey(bucket, object))
+	switch err {
+	case nil:
+		r := &ListingRecord{}
+		if err := r.Unmarshal(data); err != nil {
+			return ObjectInfo{}, err
+		}
+		if r.GetObjectHash() == objectHash && r.GetVersion() == listingRecordVersion {
+			return r.objectInfo(bucket, object), nil
+		}
+	case datastore.ErrNotFound:
+	default:
+		return ObjectInfo{}, err
+	}
+	obj, err := ipfsObject(ctx, ls.dag, objectHash)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	r := newListingRecord(objectHash, &obj.ObjectInfo)
+	if !save {
+		return r.objectInfo(bucket, object), nil
+	}
+	if err := ls.saveListingRecord(ls.ds, bucket, object, r); err != nil {
+		return ObjectInfo{}, err
+	}
+	return r.objectInfo(bucket, object), nil
+}
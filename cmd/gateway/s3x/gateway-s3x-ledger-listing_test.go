@@ -0,0 +1,136 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/ipfs/go-datastore"
+)
+
+func TestS3X_Listing_Badger(t *testing.T) {
+	testS3XListing(t, DSTypeBadger)
+}
+func TestS3X_Listing_Crdt(t *testing.T) {
+	testS3XListing(t, DSTypeCrdt)
+}
+func testS3XListing(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []string{"a.txt", "b.txt"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, o, getTestPutObjectReader(t, []byte("hello "+o)), minio.ObjectOptions{
+			UserDefined: map[string]string{"Content-Type": "text/plain", "X-Amz-Meta-Owner": "tester"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ls := gateway.ledgerStore
+	record := func(t *testing.T, object string) *ListingRecord {
+		t.Helper()
+		data, err := ls.ds.Get(ls.listingObjectKey(testBucket1, object))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &ListingRecord{}
+		if err := r.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	checkListed := func(t *testing.T, object string, size int64) {
+		t.Helper()
+		loi, err := gateway.ListObjectsV2(ctx, testBucket1, object, "", "", 1000, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != 1 {
+			t.Fatalf("expected %v to be listed, but got %+v", object, loi.Objects)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, object, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := loi.Objects[0]
+		if got.Bucket != testBucket1 || got.Name != object || got.Size != size || got.ETag != info.ETag ||
+			!got.ModTime.Equal(info.ModTime) || got.ContentType != "text/plain" {
+			t.Fatalf("expected listing %+v, but got %+v", info, got)
+		}
+		if len(got.UserDefined) != 0 {
+			t.Fatalf("expected no user metadata in listings, but got %v", got.UserDefined)
+		}
+	}
+	t.Run("Written", func(t *testing.T) {
+		for _, o := range []string{"a.txt", "b.txt"} {
+			hash, err := ls.GetObjectHash(ctx, testBucket1, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := record(t, o); r.GetObjectHash() != hash || r.GetSize_() != int64(len("hello "+o)) {
+				t.Fatalf("expected a listing record of %v, but got %+v", hash, r)
+			}
+			checkListed(t, o, int64(len("hello "+o)))
+		}
+	})
+	t.Run("Current", func(t *testing.T) {
+		// a current record is listed without loading the object
+		r := record(t, "a.txt")
+		r.Size_ = 1
+		if err := ls.saveListingRecord(ls.ds, testBucket1, "a.txt", r); err != nil {
+			t.Fatal(err)
+		}
+		checkListed(t, "a.txt", 1)
+	})
+	t.Run("Stale", func(t *testing.T) {
+		for _, update := range []func(){
+			func() {
+				r := record(t, "a.txt")
+				r.ObjectHash = "stale"
+				if err := ls.saveListingRecord(ls.ds, testBucket1, "a.txt", r); err != nil {
+					t.Fatal(err)
+				}
+			},
+			func() {
+				if err := ls.deleteListingRecords(ls.ds, testBucket1, "a.txt"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		} {
+			update()
+			checkListed(t, "a.txt", int64(len("hello a.txt")))
+			if hash, _ := ls.GetObjectHash(ctx, testBucket1, "a.txt"); record(t, "a.txt").GetObjectHash() != hash {
+				t.Fatal("expected the listing record to be replaced")
+			}
+		}
+	})
+	t.Run("Removed", func(t *testing.T) {
+		if _, err := gateway.RenameObject(ctx, &RenameObjectRequest{Bucket: testBucket1, Object: "b.txt", NewObject: "c.txt"}); err != nil {
+			t.Fatal(err)
+		}
+		checkListed(t, "c.txt", int64(len("hello b.txt")))
+		if err := gateway.DeleteObject(ctx, testBucket1, "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range []string{"a.txt", "b.txt"} {
+			if _, err := ls.ds.Get(ls.listingObjectKey(testBucket1, o)); err != datastore.ErrNotFound {
+				t.Fatalf("expected the listing record of %v to be removed, but got %v", o, err)
+			}
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, "c.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteBucket(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ls.ds.Get(ls.listingObjectKey(testBucket1, "c.txt")); err != datastore.ErrNotFound {
+			t.Fatalf("expected the listing records of the bucket to be removed, but got %v", err)
+		}
+	})
+}
@@ -1,8 +1,12 @@
 package s3x
 
 import (
+	"context"
+	"sort"
+
 	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 )
 
 /* Design Notes
@@ -17,41 +21,109 @@ The reason for this is so that we can enable easy reuse of internal code.
 // SETTER FUNCTINS //
 /////////////////////
 
-// AbortMultipartUpload is used to abort a multipart upload
-func (ls *ledgerStore) AbortMultipartUpload(bucket, multipartID string) error {
+// AbortMultipartUpload is used to abort a multipart upload, it returns the aborted upload
+// and the data hashes of its parts that are not referenced by any object, which are enqueued for garbage collection
+func (ls *ledgerStore) AbortMultipartUpload(bucket, multipartID string) (*MultipartUpload, []string, error) {
 	err := ls.AssertBucketExits(bucket)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return ls.DeleteMultipartID(multipartID)
-}
-
-// NewMultipartUpload is used to store the initial start of a multipart upload request
-func (ls *ledgerStore) NewMultipartUpload(multipartID string, info *ObjectInfo) error {
-	bucket := info.GetBucket()
-	err := ls.assertBucketExits(bucket)
+	defer ls.plocker.write(multipartID)()
+	m, err := ls.getMultipartLoaded(multipartID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	m := &MultipartUpload{
+	if m.GetObjectInfo().GetBucket() != bucket {
+		return nil, nil, ErrInvalidUploadID
+	}
+	if err := ls.DeleteMultipartID(multipartID); err != nil {
+		return nil, nil, err
+	}
+	unreferenced, err := ls.releasePartData(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, unreferenced, nil
+}
+
+// NewMultipartUpload is used to store the initial start of a multipart upload request,
+// owner is the access key that started the upload
+func (ls *ledgerStore) NewMultipartUpload(multipartID, owner string, info *ObjectInfo) error {
+	return ls.newMultipartUpload(&MultipartUpload{
 		ObjectInfo:  info,
 		Id:          multipartID,
 		ObjectParts: make(map[int64]ObjectPartInfo),
+		Owner:       owner,
+	})
+}
+
+// NewUploadSession is used to store the start of a resumable upload session of the extension api
+func (ls *ledgerStore) NewUploadSession(multipartID string, info *ObjectInfo) error {
+	return ls.newMultipartUpload(&MultipartUpload{
+		ObjectInfo:  info,
+		Id:          multipartID,
+		ObjectParts: make(map[int64]ObjectPartInfo),
+		Resumable:   true,
+	})
+}
+
+// PutUploadChunk is used to record the next chunk of an upload session, offset must be the number of bytes
+// received before, otherwise ErrUploadOffsetMismatch is returned and the chunk data is released.
+// The number of chunks received is returned.
+func (ls *ledgerStore) PutUploadChunk(multipartID string, offset int64, pi minio.PartInfo, dataHash string) (int64, error) {
+	defer ls.plocker.write(multipartID)()
+	m, err := ls.getUploadSession(multipartID)
+	if err != nil {
+		return 0, err
 	}
-	ls.pmapLocker.Lock()
-	ls.l.MultipartUploads[multipartID] = m
-	ls.pmapLocker.Unlock()
-	data, err := m.Marshal()
+	received, chunks := uploadSessionOffset(m)
+	pn := chunks + 1
+	part := ObjectPartInfo{
+		Number:       pn,
+		Name:         m.GetObjectInfo().GetName(),
+		LastModified: pi.LastModified,
+		Size_:        pi.Size,
+		ActualSize:   pi.ActualSize,
+		DataHash:     dataHash,
+		Etag:         pi.ETag,
+		Checksum:     pi.Checksum,
+	}
+	if offset != received {
+		// the chunk was sent again or concurrently with another chunk, its data is kept if the session has it
+		for _, p := range m.ObjectParts {
+			if p.GetDataHash() == dataHash {
+				return 0, ErrUploadOffsetMismatch
+			}
+		}
+		if _, err := ls.releasePartData(&MultipartUpload{ObjectParts: map[int64]ObjectPartInfo{pn: part}}); err != nil {
+			return 0, err
+		}
+		return 0, ErrUploadOffsetMismatch
+	}
+	if pn > defaultMaxPartCount {
+		return 0, ErrInvalidPartNumber
+	}
+	if m.ObjectParts == nil {
+		m.ObjectParts = make(map[int64]ObjectPartInfo)
+	}
+	m.ObjectParts[pn] = part
+	data, err := ls.names.marshalMultipart(m)
 	if err != nil {
-		return err
+		delete(m.ObjectParts, pn)
+		return 0, err
 	}
-	return ls.ds.Put(dsPartKey.ChildString(multipartID), data)
+	if err := ls.ds.Put(dsPartKey.ChildString(multipartID), data); err != nil {
+		delete(m.ObjectParts, pn)
+		return 0, err
+	}
+	return pn, nil
 }
 
-// PutObjectPart is used to record an individual object part within a multipart upload
-func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string, pi minio.PartInfo) error {
+// PutObjectPart is used to record an individual object part within a multipart upload,
+// pi.ETag is the md5 of the part data, and dataHash the hash of the part data on ipfs
+func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string, pi minio.PartInfo, dataHash string) error {
 	pn := int64(pi.PartNumber)
-	if pn > 10000 {
+	if pn < 1 || pn > defaultMaxPartCount {
 		return ErrInvalidPartNumber
 	}
 
@@ -65,6 +137,10 @@ func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string,
 	if err != nil {
 		return err
 	}
+	// the chunks of upload sessions are numbered in data order, so parts can not be added to them
+	if m.GetResumable() {
+		return ErrInvalidUploadID
+	}
 	if m.ObjectParts == nil {
 		m.ObjectParts = make(map[int64]ObjectPartInfo)
 	}
@@ -74,9 +150,10 @@ func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string,
 		LastModified: pi.LastModified,
 		Size_:        pi.Size,
 		ActualSize:   pi.ActualSize,
-		DataHash:     pi.ETag,
+		DataHash:     dataHash,
+		Etag:         pi.ETag,
 	}
-	data, err := m.Marshal()
+	data, err := ls.names.marshalMultipart(m)
 	if err != nil {
 		return err
 	}
@@ -87,6 +164,18 @@ func (ls *ledgerStore) PutObjectPart(bucketName, objectName, multipartID string,
 // GETTER FUNCTINS //
 /////////////////////
 
+// GetUploadSession is used to return an upload session,
+// returned unlock function must be used after map iteration is done.
+func (ls *ledgerStore) GetUploadSession(id string) (*MultipartUpload, func(), error) {
+	unlock := ls.plocker.read(id)
+	m, err := ls.getUploadSession(id)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return m, unlock, nil
+}
+
 // GetObjectParts is used to return multipart upload parts,
 // returned unlock function must be used after map iteration is done.
 func (ls *ledgerStore) GetObjectDetails(id string) (*MultipartUpload, func(), error) {
@@ -99,6 +188,30 @@ func (ls *ledgerStore) GetObjectDetails(id string) (*MultipartUpload, func(), er
 	return m, unlock, nil
 }
 
+// ListMultipartUploads returns all multipart uploads in progress
+func (ls *ledgerStore) ListMultipartUploads(ctx context.Context) ([]*MultipartUpload, error) {
+	rs, err := ls.ds.Query(query.Query{Prefix: dsPartKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	var uploads []*MultipartUpload
+	for r := range rs.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m, err := ls.names.unmarshalMultipart(r.Value)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, m)
+	}
+	return uploads, nil
+}
+
 // MultipartIDExists is used to lookup if the given multipart id exists
 func (ls *ledgerStore) MultipartIDExists(id string) error {
 	return ls.assertValidUploadID(id)
@@ -135,6 +248,42 @@ func (ls *ledgerStore) assertValidUploadID(uploadID string) error {
 	return err
 }
 
+// newMultipartUpload stores a new multipart upload
+func (ls *ledgerStore) newMultipartUpload(m *MultipartUpload) error {
+	err := ls.assertBucketExits(m.GetObjectInfo().GetBucket())
+	if err != nil {
+		return err
+	}
+	ls.pmapLocker.Lock()
+	ls.l.MultipartUploads[m.GetId()] = m
+	ls.pmapLocker.Unlock()
+	data, err := ls.names.marshalMultipart(m)
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsPartKey.ChildString(m.GetId()), data)
+}
+
+// getUploadSession returns an upload session, multipart uploads of the S3 api are reported as ErrInvalidUploadID
+func (ls *ledgerStore) getUploadSession(uploadID string) (*MultipartUpload, error) {
+	m, err := ls.getMultipartLoaded(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.GetResumable() {
+		return nil, ErrInvalidUploadID
+	}
+	return m, nil
+}
+
+// uploadSessionOffset returns the number of bytes and chunks received by an upload session
+func uploadSessionOffset(m *MultipartUpload) (offset int64, chunks int64) {
+	for _, p := range m.ObjectParts {
+		offset += p.GetSize_()
+	}
+	return offset, int64(len(m.ObjectParts))
+}
+
 func (ls *ledgerStore) getMultipartLoaded(uploadID string) (*MultipartUpload, error) {
 	m, err := ls.getMultipartNilable(uploadID)
 	if err != nil {
@@ -157,13 +306,43 @@ func (ls *ledgerStore) DeleteMultipartID(uploadID string) error {
 	return err
 }
 
+// releasePartData enqueues the data hashes of the parts of an upload that are not referenced by any object
+// for garbage collection, and returns them
+func (ls *ledgerStore) releasePartData(m *MultipartUpload) ([]string, error) {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	var unreferenced []string
+	seen := make(map[string]bool)
+	for _, p := range m.ObjectParts {
+		h := p.GetDataHash()
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		// parts with the same data as an object share its data hash, and are released with the object
+		n, err := ls.dataRefCount(h)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			continue
+		}
+		if err := ls.enqueueGarbage(h); err != nil {
+			return nil, err
+		}
+		unreferenced = append(unreferenced, h)
+	}
+	sort.Strings(unreferenced)
+	return unreferenced, nil
+}
+
 // getMultipartNilable returns a MultipartUpload or nil if it did not exist
 func (ls *ledgerStore) getMultipartNilable(uploadID string) (*MultipartUpload, error) {
 	ls.pmapLocker.Lock()
 	defer ls.pmapLocker.Unlock()
 	mu, ok := ls.l.MultipartUploads[uploadID]
-	if ok {
-		// fast path
+	if ok && !ls.shared {
+		// fast path, other gateways of a cluster may have changed the upload
 		return mu, nil
 	}
 	data, err := ls.ds.Get(dsPartKey.ChildString(uploadID))
@@ -173,8 +352,7 @@ func (ls *ledgerStore) getMultipartNilable(uploadID string) (*MultipartUpload, e
 	if err != nil {
 		return nil, err
 	}
-	mu = &MultipartUpload{}
-	err = mu.Unmarshal(data)
+	mu, err = ls.names.unmarshalMultipart(data)
 	if err != nil {
 		return nil, err
 	}
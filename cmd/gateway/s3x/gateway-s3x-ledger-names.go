@@ -0,0 +1,462 @@
+package s3x
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+The search index, the listing records, and the data holds are keyed by object name, so the names of all
+objects can be read from the datastore without IPFS. Buckets whose object names are confidential can be given
+a name key, the ledger then encrypts the object names of these keys, and stores index entries without names.
+Name keys are set per bucket, so buckets of different tenants do not share a key, and buckets without a key keep
+base64 encoded names.
+
+Ledger keys are looked up by name, so names are encrypted deterministically: the IV of a name is the HMAC of
+the name, truncated to an AES block, and the name is encrypted with AES-CTR under that IV, like AES-SIV, so a
+name always has the same ciphertext within its bucket, and a decrypted name is authenticated by its IV. The
+keys are derived from the name key and the bucket name, so the same name in two buckets has different
+ciphertexts. Prefix listings are not affected, they list the object maps of bucket DAGs, and only look up
+listing records by name.
+
+The check value of the name key of a bucket is recorded under dsNamesKey. A bucket without a check value has
+base64 encoded names, and is migrated once when a key is set for it: holds are re-keyed, the records that have
+names are encrypted, the index and listing records are dropped, and rebuilt by the next search and listing of
+the bucket. Idempotency records are dropped, so retries of uploads made before the migration are stored again.
+The check value is recorded last, so an interrupted migration is repeated, names that are already encrypted are
+recognized by their IV. The ledger can not be opened with another key for the bucket or without a key, since
+the names can not be decrypted.
+
+The records of multipart uploads in progress, imports and upload grants are stored with the encrypted names of
+their objects, and are migrated with the holds. Idempotency records are keyed by encrypted names, and keep only
+the ETag, size and modification time of their object, not its name. Inventory records have no object names. The
+key only covers these records: bucket names, the bucket DAGs on IPFS, the directory trees of buckets, the
+inventory data files on IPFS, and the records of cold objects still have the names of their objects, so a copy of
+the datastore and its IPFS blocks can reveal object names.
+*/
+
+// dsNamesKey maps bucket names to the check value of the key their object names are encrypted with, unset if
+// their names are base64 encoded
+var dsNamesKey = datastore.NewKey("o")
+
+// minNameKeySize is the minimum length of a name key
+const minNameKeySize = 16
+
+// objectNames encodes the object names of ledger keys and records, the names of buckets with a name key are
+// encrypted, the names of other buckets, or all names if it is nil, are base64 encoded in keys and kept in records
+type objectNames struct {
+	buckets map[string]*bucketNames
+}
+
+// bucketNames are the keys the object names of a bucket are encrypted with
+type bucketNames struct {
+	ivKey []byte
+	block cipher.Block
+	check string
+}
+
+// newObjectNames returns the object name encoding of comma separated bucket=key name keys, names are base64
+// encoded if it is empty
+func newObjectNames(keys string) (*objectNames, error) {
+	n := &objectNames{buckets: make(map[string]*bucketNames)}
+	for _, e := range strings.Split(keys, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		split := strings.SplitN(e, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			// the entry is not printed, it can be a key
+			return nil, errors.New("invalid name key, expected bucket=key")
+		}
+		if len(split[1]) < minNameKeySize {
+			return nil, fmt.Errorf("name key of bucket %s must be at least %v characters", split[0], minNameKeySize)
+		}
+		if _, ok := n.buckets[split[0]]; ok {
+			return nil, fmt.Errorf("bucket %s has more than one name key", split[0])
+		}
+		b, err := newBucketNames(split[0], []byte(split[1]))
+		if err != nil {
+			return nil, err
+		}
+		n.buckets[split[0]] = b
+	}
+	if len(n.buckets) == 0 {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// newBucketNames derives the IV and encryption keys, and the check value, of the names of a bucket from its name key
+func newBucketNames(bucket string, key []byte) (*bucketNames, error) {
+	bucketKey := mac(key, "s3x object names:"+bucket)
+	block, err := aes.NewCipher(mac(bucketKey, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return &bucketNames{
+		ivKey: mac(bucketKey, "iv"),
+		block: block,
+		check: hex.EncodeToString(mac(bucketKey, "check")),
+	}, nil
+}
+
+// mac returns the HMAC-SHA256 of data under key
+func mac(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	_, _ = m.Write([]byte(data)) // hashes never return errors
+	return m.Sum(nil)
+}
+
+// bucket returns the keys of the names of a bucket, nil if its names are not encrypted
+func (n *objectNames) bucket(bucket string) *bucketNames {
+	if n == nil {
+		return nil
+	}
+	return n.buckets[bucket]
+}
+
+// encrypts returns true if the object names of a bucket are encrypted
+func (n *objectNames) encrypts(bucket string) bool {
+	return n.bucket(bucket) != nil
+}
+
+// check returns the check value recorded for the name key of a bucket, empty without a key
+func (n *objectNames) check(bucket string) string {
+	if b := n.bucket(bucket); b != nil {
+		return b.check
+	}
+	return ""
+}
+
+// encode returns the ledger key name of an object
+func (n *objectNames) encode(bucket, object string) string {
+	b := n.bucket(bucket)
+	if b == nil {
+		return base64.RawURLEncoding.EncodeToString([]byte(object))
+	}
+	data := make([]byte, aes.BlockSize+len(object))
+	copy(data, mac(b.ivKey, object)[:aes.BlockSize])
+	cipher.NewCTR(b.block, data[:aes.BlockSize]).XORKeyStream(data[aes.BlockSize:], []byte(object))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// errNameNotDecrypted is returned for key names that are not encrypted with the name key of their bucket
+var errNameNotDecrypted = errors.New("object name can not be decrypted with the name key")
+
+// decode returns the object name of a ledger key name
+func (n *objectNames) decode(bucket, name string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(name)
+	b := n.bucket(bucket)
+	if b == nil {
+		return string(data), err
+	}
+	if err != nil || len(data) < aes.BlockSize {
+		return "", errNameNotDecrypted
+	}
+	object := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCTR(b.block, data[:aes.BlockSize]).XORKeyStream(object, data[aes.BlockSize:])
+	if !hmac.Equal(mac(b.ivKey, string(object))[:aes.BlockSize], data[:aes.BlockSize]) {
+		return "", errNameNotDecrypted
+	}
+	return string(object), nil
+}
+
+// encodeRecord returns the name of an object as it is stored in ledger records, encrypted if the names of its
+// bucket are encrypted, and as is otherwise. Empty names stay empty.
+func (n *objectNames) encodeRecord(bucket, object string) string {
+	if object == "" || !n.encrypts(bucket) {
+		return object
+	}
+	return n.encode(bucket, object)
+}
+
+// decodeRecord returns the object name of a name stored in a ledger record, see encodeRecord
+func (n *objectNames) decodeRecord(bucket, name string) (string, error) {
+	if name == "" || !n.encrypts(bucket) {
+		return name, nil
+	}
+	return n.decode(bucket, name)
+}
+
+// setObjectNames sets the object name encoding of the ledger, and migrates the buckets with base64 encoded names
+// that have a name key to encrypted names, it is called before the ledger is used
+func (ls *ledgerStore) setObjectNames(names *objectNames) error {
+	recorded := make(map[string]string)
+	if err := ls.forEachEntry(query.Query{Prefix: dsNamesKey.String()}, func(e query.Entry) error {
+		if key := datastore.NewKey(e.Key); key.Parent().Equal(dsNamesKey) {
+			recorded[key.BaseNamespace()] = string(e.Value)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for bucket, check := range recorded {
+		switch {
+		case check == names.check(bucket):
+		case names.encrypts(bucket):
+			return fmt.Errorf("object names of bucket %s are encrypted with another name key", bucket)
+		default:
+			return fmt.Errorf("object names of bucket %s are encrypted, but no name key is set for it", bucket)
+		}
+	}
+	if names != nil {
+		for bucket := range names.buckets {
+			if _, ok := recorded[bucket]; ok {
+				continue
+			}
+			if err := ls.encryptBucketNames(names, bucket); err != nil {
+				return err
+			}
+		}
+	}
+	ls.names = names
+	return nil
+}
+
+// encryptBucketNames migrates a bucket with base64 encoded names to encrypted names, and records the check value
+// of its name key last, buckets that do not exist yet only have the check value recorded
+func (ls *ledgerStore) encryptBucketNames(names *objectNames, bucket string) error {
+	for _, encrypt := range []func(names *objectNames, bucket string) error{
+		ls.encryptHoldNames,
+		ls.encryptMultipartNames,
+		ls.encryptImportNames,
+		ls.encryptUploadGrantNames,
+	} {
+		if err := encrypt(names, bucket); err != nil {
+			return err
+		}
+	}
+	if err := ls.deleteIndex(bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteListing(bucket); err != nil {
+		return err
+	}
+	if err := ls.deleteIdempotencyRecords(bucket); err != nil {
+		return err
+	}
+	return ls.ds.Put(dsNamesKey.ChildString(bucket), []byte(names.check(bucket)))
+}
+
+// encryptHoldNames re-keys the data holds of a bucket with base64 encoded names to encrypted names
+func (ls *ledgerStore) encryptHoldNames(names *objectNames, bucket string) error {
+	var holds []query.Entry
+	if err := ls.forEachEntry(query.Query{Prefix: dsHoldKey.String()}, func(e query.Entry) error {
+		if datastore.NewKey(e.Key).Parent().BaseNamespace() == bucket {
+			holds = append(holds, e)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	batch, err := ls.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for i, e := range holds {
+		key := datastore.NewKey(e.Key)
+		if _, err := names.decode(bucket, key.BaseNamespace()); err == nil {
+			continue // re-keyed by an interrupted migration
+		}
+		object, err := base64.RawURLEncoding.DecodeString(key.BaseNamespace())
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(key.Parent().ChildString(names.encode(bucket, string(object))), e.Value); err != nil {
+			return err
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		if (i+1)%ls.batchSize == 0 {
+			if err := batch.Commit(); err != nil {
+				return err
+			}
+			if batch, err = ls.ds.Batch(); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.Commit()
+}
+
+// encryptMultipartNames encrypts the object names of the multipart uploads in progress of a bucket
+func (ls *ledgerStore) encryptMultipartNames(names *objectNames, bucket string) error {
+	var uploads []query.Entry
+	if err := ls.forEachEntry(query.Query{Prefix: dsPartKey.String()}, func(e query.Entry) error {
+		uploads = append(uploads, e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, e := range uploads {
+		m := &MultipartUpload{}
+		if err := m.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		info := m.GetObjectInfo()
+		if info.GetBucket() != bucket {
+			continue
+		}
+		if _, err := names.decode(bucket, info.GetName()); err == nil {
+			continue // encrypted by an interrupted migration
+		}
+		data, err := names.marshalMultipart(m)
+		if err != nil {
+			return err
+		}
+		if err := ls.ds.Put(datastore.NewKey(e.Key), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptImportNames re-keys the object results of the imports into a bucket, and encrypts the names in them
+// and in the imports
+func (ls *ledgerStore) encryptImportNames(names *objectNames, bucket string) error {
+	var entries []query.Entry
+	if err := ls.forEachEntry(query.Query{Prefix: dsImportKey.String()}, func(e query.Entry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	var plain *objectNames
+	jobs := make(map[string]bool)
+	for _, e := range entries {
+		key := datastore.NewKey(e.Key)
+		if !key.Parent().Equal(dsImportKey) {
+			continue
+		}
+		job, err := plain.unmarshalImportJob(e.Value)
+		if err != nil {
+			return err
+		}
+		if job.GetBucket() != bucket {
+			continue
+		}
+		jobs[job.GetId()] = true
+		if _, err := names.unmarshalImportJob(e.Value); err == nil {
+			continue // encrypted by an interrupted migration
+		}
+		data, err := names.marshalImportJob(job)
+		if err != nil {
+			return err
+		}
+		if err := ls.ds.Put(key, data); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		key := datastore.NewKey(e.Key)
+		if !key.Parent().Parent().Equal(dsImportKey) || !jobs[key.Parent().BaseNamespace()] {
+			continue
+		}
+		if _, err := names.decode(bucket, key.BaseNamespace()); err == nil {
+			continue // re-keyed by an interrupted migration
+		}
+		r, err := plain.unmarshalImportResult(bucket, e.Value)
+		if err != nil {
+			return err
+		}
+		data, err := names.marshalImportResult(bucket, r)
+		if err != nil {
+			return err
+		}
+		if err := ls.ds.Put(key.Parent().ChildString(names.encode(bucket, r.GetName())), data); err != nil {
+			return err
+		}
+		if err := ls.ds.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptUploadGrantNames encrypts the prefixes and object names of the upload grants of a bucket
+func (ls *ledgerStore) encryptUploadGrantNames(names *objectNames, bucket string) error {
+	var grants []query.Entry
+	if err := ls.forEachEntry(query.Query{Prefix: dsUploadGrantKey.String()}, func(e query.Entry) error {
+		grants = append(grants, e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	var plain *objectNames
+	for _, e := range grants {
+		g, err := plain.unmarshalUploadGrant(e.Value)
+		if err != nil {
+			return err
+		}
+		if g.GetBucket() != bucket {
+			continue
+		}
+		if _, err := names.unmarshalUploadGrant(e.Value); err == nil {
+			continue // encrypted by an interrupted migration
+		}
+		data, err := names.marshalUploadGrant(g)
+		if err != nil {
+			return err
+		}
+		if err := ls.ds.Put(datastore.NewKey(e.Key), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalMultipart returns the record of a multipart upload, with the names of its object encrypted if its
+// bucket has a name key
+func (n *objectNames) marshalMultipart(m *MultipartUpload) ([]byte, error) {
+	if m.ObjectInfo == nil || !n.encrypts(m.ObjectInfo.Bucket) {
+		return m.Marshal()
+	}
+	bucket := m.ObjectInfo.Bucket
+	info := *m.ObjectInfo
+	info.Name = n.encode(bucket, info.Name)
+	record := *m
+	record.ObjectInfo = &info
+	record.ObjectParts = make(map[int64]ObjectPartInfo, len(m.ObjectParts))
+	for pn, part := range m.ObjectParts {
+		part.Name = n.encode(bucket, part.Name)
+		record.ObjectParts[pn] = part
+	}
+	return record.Marshal()
+}
+
+// unmarshalMultipart returns the multipart upload of a record, see marshalMultipart
+func (n *objectNames) unmarshalMultipart(data []byte) (*MultipartUpload, error) {
+	m := &MultipartUpload{}
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	if m.ObjectInfo == nil || !n.encrypts(m.ObjectInfo.Bucket) {
+		return m, nil
+	}
+	bucket := m.ObjectInfo.Bucket
+	name, err := n.decode(bucket, m.ObjectInfo.Name)
+	if err != nil {
+		return nil, err
+	}
+	m.ObjectInfo.Name = name
+	for pn, part := range m.ObjectParts {
+		if part.Name, err = n.decode(bucket, part.Name); err != nil {
+			return nil, err
+		}
+		m.ObjectParts[pn] = part
+	}
+	return m, nil
+}
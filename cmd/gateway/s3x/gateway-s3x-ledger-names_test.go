@@ -0,0 +1,346 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/ipfs/go-datastore/query"
+)
+
+func TestObjectNames(t *testing.T) {
+	for _, keys := range []string{
+		testBucket1 + "=short",
+		"0123456789abcdef",
+		"=0123456789abcdef",
+		testBucket1 + "=0123456789abcdef," + testBucket1 + "=fedcba9876543210",
+	} {
+		if _, err := newObjectNames(keys); err == nil {
+			t.Fatalf("expected name keys %q to be rejected", keys)
+		}
+	}
+	if names, err := newObjectNames(" , "); err != nil || names != nil {
+		t.Fatalf("expected no name keys, but got %v, %v", names, err)
+	}
+	names, err := newObjectNames(testBucket1 + "=0123456789abcdef," + testBucket2 + "=0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newObjectNames(testBucket1 + "=fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names.check(testBucket1) == names.check(testBucket2) || names.check(testBucket1) == other.check(testBucket1) {
+		t.Fatal("expected the check values of the name keys of buckets to differ")
+	}
+	const object = "confidential/report.pdf"
+	encoded := names.encode(testBucket1, object)
+	if encoded != names.encode(testBucket1, object) {
+		t.Fatal("expected names to be encrypted deterministically")
+	}
+	if encoded == names.encode(testBucket2, object) {
+		t.Fatal("expected names to be encrypted with the key of their bucket")
+	}
+	if decoded, err := names.decode(testBucket1, encoded); err != nil || decoded != object {
+		t.Fatalf("expected %v, but got %v, %v", object, decoded, err)
+	}
+	for _, n := range []struct {
+		names  *objectNames
+		bucket string
+	}{{names, testBucket2}, {other, testBucket1}} {
+		if _, err := n.names.decode(n.bucket, encoded); err != errNameNotDecrypted {
+			t.Fatalf("expected errNameNotDecrypted, but got %v", err)
+		}
+	}
+	var plain *objectNames
+	if decoded, err := plain.decode(testBucket1, plain.encode(testBucket1, object)); err != nil || decoded != object {
+		t.Fatalf("expected %v, but got %v, %v", object, decoded, err)
+	}
+	// buckets without a name key keep base64 encoded names in keys, and plain names in records
+	if names.encode("plain", object) != plain.encode("plain", object) || names.encodeRecord("plain", object) != object {
+		t.Fatal("expected the names of a bucket without a name key not to be encrypted")
+	}
+	if names.encodeRecord(testBucket1, object) != encoded || names.encodeRecord(testBucket1, "") != "" {
+		t.Fatal("expected the names in records to be encrypted like the names of keys")
+	}
+	if _, err := names.decodeRecord(testBucket1, object); err != errNameNotDecrypted {
+		t.Fatalf("expected a plain name in a record of a bucket with a name key not to decode, but got %v", err)
+	}
+}
+
+func TestMultipartNames(t *testing.T) {
+	names, err := newObjectNames(testBucket1 + "=0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const object = "confidential/report.pdf"
+	m := &MultipartUpload{
+		Id:          "upload",
+		ObjectInfo:  &ObjectInfo{Bucket: testBucket1, Name: object},
+		ObjectParts: map[int64]ObjectPartInfo{1: {Number: 1, Name: object}},
+	}
+	data, err := names.marshalMultipart(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(object)) {
+		t.Fatal("expected the record to not contain the object name")
+	}
+	if m.ObjectInfo.Name != object || m.ObjectParts[1].Name != object {
+		t.Fatal("expected the upload to be unchanged")
+	}
+	got, err := names.unmarshalMultipart(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ObjectInfo.Name != object || got.ObjectParts[1].Name != object {
+		t.Fatalf("expected %v, but got %v", object, got)
+	}
+	var plain *objectNames
+	if _, err := plain.unmarshalMultipart(data); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = plain.marshalMultipart(m); err != nil || !bytes.Contains(data, []byte(object)) {
+		t.Fatalf("expected a plain record, but got %v", err)
+	}
+}
+
+func TestS3X_LedgerNameEncryption(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	objects := []string{"confidential/a", "confidential/b", "confidential/held", "confidential/retried"}
+	// putRetried uploads confidential/retried with an idempotency key, which records the upload
+	putRetried := func() {
+		t.Helper()
+		opts := minio.ObjectOptions{UserDefined: map[string]string{xhttp.S3xIdempotencyKey: "upload-1"}}
+		if _, err := gateway.PutObject(ctx, testBucket1, "confidential/retried", getTestPutObjectReader(t, []byte("retried")), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, object := range objects[:3] {
+		var meta map[string]string
+		if object == "confidential/held" {
+			meta = map[string]string{"X-Amz-Object-Lock-Legal-Hold": "ON"}
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(object)), minio.ObjectOptions{UserDefined: meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putRetried()
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, "confidential/upload", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// search and list to write the index and the listing records with encoded names
+	if _, err := gateway.ledgerStore.SearchObjects(ctx, testBucket1, func(*ObjectInfo) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 10); err != nil {
+		t.Fatal(err)
+	}
+	// leaked returns the ledger keys and values that have the object names in them
+	leaked := func() []string {
+		t.Helper()
+		var keys []string
+		if err := gateway.ledgerStore.forEachEntry(query.Query{}, func(e query.Entry) error {
+			if bytes.Contains([]byte(e.Key), []byte("confidential")) || bytes.Contains(e.Value, []byte("confidential")) {
+				keys = append(keys, e.Key)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	if len(leaked()) == 0 {
+		t.Fatal("expected the index entries to have names without a name key")
+	}
+
+	gateway.temx.LedgerNameKeys = testBucket1 + "=0123456789abcdef"
+	gateway.restart(t)
+	putRetried()
+	if _, err := gateway.ledgerStore.SearchObjects(ctx, testBucket1, func(*ObjectInfo) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 10); err != nil {
+		t.Fatal(err)
+	}
+	if keys := leaked(); len(keys) > 0 {
+		t.Fatalf("expected no names in the ledger, but got %v", keys)
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		infos, err := gateway.ledgerStore.SearchObjects(ctx, testBucket1, func(*ObjectInfo) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != len(objects) {
+			t.Fatalf("expected %v objects, but got %v", len(objects), len(infos))
+		}
+		for i, info := range infos {
+			if info.Name != objects[i] {
+				t.Fatalf("expected %v, but got %v", objects[i], info.Name)
+			}
+		}
+	})
+	t.Run("List", func(t *testing.T) {
+		loi, err := gateway.ListObjects(ctx, testBucket1, "confidential/", "", "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loi.Objects) != len(objects) || loi.Objects[0].Name != objects[0] || loi.Objects[0].Size != int64(len(objects[0])) {
+			t.Fatalf("unexpected listing %+v", loi.Objects)
+		}
+	})
+	t.Run("Holds", func(t *testing.T) {
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "confidential/held")
+		if err != nil {
+			t.Fatal(err)
+		}
+		held, err := gateway.ledgerStore.activeDataHolds(hash, gateway.clock.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(held) != 1 || held[0].Object != "confidential/held" || !held[0].LegalHold {
+			t.Fatalf("expected the migrated hold of confidential/held, but got %v", held)
+		}
+	})
+	t.Run("Multipart", func(t *testing.T) {
+		lmi, err := gateway.ListMultipartUploads(ctx, testBucket1, "confidential/", "", "", "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lmi.Uploads) != 1 || lmi.Uploads[0].Object != "confidential/upload" || lmi.Uploads[0].UploadID != uploadID {
+			t.Fatalf("expected the migrated upload of confidential/upload, but got %+v", lmi.Uploads)
+		}
+	})
+	t.Run("Other-Key", func(t *testing.T) {
+		if err := gateway.xObjects.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"", testBucket1 + "=fedcba9876543210"} {
+			gateway.temx.LedgerNameKeys = key
+			if _, err := gateway.temx.NewGatewayLayer(gateway.creds); err == nil {
+				t.Fatalf("expected the ledger not to open with key %q", key)
+			}
+		}
+		gateway.temx.LedgerNameKeys = testBucket1 + "=0123456789abcdef"
+		g, err := gateway.temx.NewGatewayLayer(gateway.creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gateway.xObjects = g.(*xObjects)
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, objects[0], minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestS3X_LedgerNameMigration(t *testing.T) {
+	ctx := context.Background()
+	ls, _, _ := newFaultyLedger(t)
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if _, err := ls.CreateBucket(ctx, bucket, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grant := &UploadGrant{Id: "grant", Bucket: testBucket1, Prefix: "confidential/", Object: "confidential/a"}
+	if err := ls.PutUploadGrant(grant); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.PutUploadGrant(&UploadGrant{Id: "public-grant", Bucket: testBucket2, Prefix: "public/"}); err != nil {
+		t.Fatal(err)
+	}
+	job := &ImportJob{Id: "import", Bucket: testBucket1, Marker: "confidential/b"}
+	if err := ls.PutImportJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.PutImportResult(job, &ImportObjectResult{Name: "confidential/b", State: importFailed}); err != nil {
+		t.Fatal(err)
+	}
+	// leaked returns the ledger keys whose keys or values have the names of testBucket1 in them
+	leaked := func() []string {
+		t.Helper()
+		var keys []string
+		if err := ls.forEachEntry(query.Query{}, func(e query.Entry) error {
+			if bytes.Contains([]byte(e.Key+string(e.Value)), []byte("confidential")) {
+				keys = append(keys, e.Key)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	if len(leaked()) == 0 {
+		t.Fatal("expected the records to have names without a name key")
+	}
+
+	names, err := newObjectNames(testBucket1 + "=0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the migration is repeated if it was interrupted before the check value was recorded
+	for i := 0; i < 2; i++ {
+		if err := ls.encryptBucketNames(names, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ls.setObjectNames(names); err != nil {
+		t.Fatal(err)
+	}
+	if keys := leaked(); len(keys) > 0 {
+		t.Fatalf("expected no names of %v in the ledger, but got %v", testBucket1, keys)
+	}
+	g, err := ls.GetUploadGrant(grant.GetId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.GetPrefix() != grant.GetPrefix() || g.GetObject() != grant.GetObject() {
+		t.Fatalf("expected the migrated grant %+v, but got %+v", grant, g)
+	}
+	if g, err := ls.GetUploadGrant("public-grant"); err != nil || g.GetPrefix() != "public/" {
+		t.Fatalf("expected the grant of a bucket without a name key to be unchanged, but got %+v, %v", g, err)
+	}
+	if job, err = ls.GetImportJob(job.GetId()); err != nil || job.GetMarker() != "confidential/b" {
+		t.Fatalf("expected the migrated import, but got %+v, %v", job, err)
+	}
+	r, err := ls.GetImportResult(job, "confidential/b")
+	if err != nil || r.GetName() != "confidential/b" {
+		t.Fatalf("expected the migrated result, but got %+v, %v", r, err)
+	}
+	if results, _, err := ls.ImportResults(job, "", 0, true); err != nil || len(results) != 1 || results[0].GetName() != "confidential/b" {
+		t.Fatalf("expected the migrated result, but got %+v, %v", results, err)
+	}
+
+	other, err := newObjectNames(testBucket1 + "=fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*objectNames{nil, other} {
+		if err := ls.setObjectNames(n); err == nil {
+			t.Fatalf("expected the ledger not to open with name keys %v", n)
+		}
+	}
+	// a key can be added for another bucket
+	both, err := newObjectNames(testBucket1 + "=0123456789abcdef," + testBucket2 + "=fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.setObjectNames(both); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := ls.GetUploadGrant("public-grant"); err != nil || g.GetPrefix() != "public/" {
+		t.Fatalf("expected the migrated grant of %v, but got %+v, %v", testBucket2, g, err)
+	}
+}
@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Data uploaded with asynchronous pinning is queued under dsPinQueueKey by data hash, so the queue survives
+restarts and objects that share data are pinned once. Entries are only added while the data is referenced, and
+are removed with the data by garbage collection, so a queued hash always has an object to pin it for. An entry
+leaves the queue when its data was pinned, or when it failed too many times, and the pin status of the data is
+recorded in the same step, so the state of queued data is never lost in between.
+*/
+
+// dsPinQueueKey maps the data hashes waiting to be pinned to their PinQueueEntry
+var dsPinQueueKey = datastore.NewKey("q")
+
+// pinQueueStats summarizes the pin queue
+type pinQueueStats struct {
+	depth        int64
+	retrying     int64
+	oldestQueued time.Time
+}
+
+// EnqueuePin queues data to be pinned, unless the data is no longer referenced, queued data keeps its entry
+func (ls *ledgerStore) EnqueuePin(dataHash string, now time.Time) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	if n, err := ls.dataRefCount(dataHash); err != nil || n == 0 {
+		return err
+	}
+	key := dsPinQueueKey.ChildString(dataHash)
+	if queued, err := ls.ds.Has(key); err != nil || queued {
+		return err
+	}
+	data, err := (&PinQueueEntry{Queued: now, NextAttempt: now}).Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(key, data)
+}
+
+// PinQueueEntry returns the queue entry of a data hash, and false if the data is not queued
+func (ls *ledgerStore) PinQueueEntry(dataHash string) (*PinQueueEntry, bool, error) {
+	data, err := ls.ds.Get(dsPinQueueKey.ChildString(dataHash))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	e := &PinQueueEntry{}
+	if err := e.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	return e, true, nil
+}
+
+// ForEachQueuedPin calls fn with each queued data hash and its entry as the queue is read,
+// so the queue is never held in memory
+func (ls *ledgerStore) ForEachQueuedPin(ctx context.Context, fn func(dataHash string, e *PinQueueEntry) error) error {
+	return ls.forEachEntry(query.Query{Prefix: dsPinQueueKey.String()}, func(e query.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entry := &PinQueueEntry{}
+		if err := entry.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		return fn(datastore.NewKey(e.Key).BaseNamespace(), entry)
+	})
+}
+
+// PinQueueStats returns the depth of the pin queue, the number of retried entries, and the oldest entry
+func (ls *ledgerStore) PinQueueStats(ctx context.Context) (*pinQueueStats, error) {
+	stats := &pinQueueStats{}
+	err := ls.ForEachQueuedPin(ctx, func(dataHash string, e *PinQueueEntry) error {
+		stats.depth++
+		if e.Attempts > 0 {
+			stats.retrying++
+		}
+		if stats.oldestQueued.IsZero() || e.Queued.Before(stats.oldestQueued) {
+			stats.oldestQueued = e.Queued
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RetryPin records a failed attempt to pin queued data, unless the data left the queue
+func (ls *ledgerStore) RetryPin(dataHash string, e *PinQueueEntry) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	key := dsPinQueueKey.ChildString(dataHash)
+	if queued, err := ls.ds.Has(key); err != nil || !queued {
+		return err
+	}
+	data, err := e.Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(key, data)
+}
+
+// FinishPin removes data from the pin queue and records its pin status, unless the data is no longer referenced
+func (ls *ledgerStore) FinishPin(dataHash string, s *PinStatus) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	if err := ls.dequeuePin(dataHash); err != nil {
+		return err
+	}
+	if n, err := ls.dataRefCount(dataHash); err != nil || n == 0 {
+		return err
+	}
+	data, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsPinKey.ChildString(dataHash), data)
+}
+
+// dequeuePin removes data from the pin queue, the caller holds rlocker
+func (ls *ledgerStore) dequeuePin(dataHash string) error {
+	if err := ls.ds.Delete(dsPinQueueKey.ChildString(dataHash)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package s3x
+
+import (
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+The data of objects is pinned on the TemporalX node when it is uploaded, but a pin can be lost, for example
+when blocks are removed from the node by hand or the node is restored from an older backup. The result of the
+last check of the data of an object is recorded under dsPinKey by data hash, so objects that share data share
+their pin status, and the status is removed with the data by garbage collection. Statuses are only recorded
+while the data is referenced, so a check that races with a collection does not leave a status behind.
+*/
+
+// dsPinKey maps data hashes to the PinStatus of their last check
+var dsPinKey = datastore.NewKey("n")
+
+// PinStatus returns the recorded pin status of a data hash, and false if it was never checked
+func (ls *ledgerStore) PinStatus(dataHash string) (*PinStatus, bool, error) {
+	data, err := ls.ds.Get(dsPinKey.ChildString(dataHash))
+	if err == datastore.ErrNotFound {
+		return &PinStatus{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	s := &PinStatus{}
+	if err := s.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// PutPinStatus records the pin status of a data hash, unless the data is no longer referenced
+func (ls *ledgerStore) PutPinStatus(dataHash string, s *PinStatus) error {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	if n, err := ls.dataRefCount(dataHash); err != nil || n == 0 {
+		return err
+	}
+	data, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(dsPinKey.ChildString(dataHash), data)
+}
+
+// deletePinStatus removes the pin status of a data hash, the caller holds rlocker
+func (ls *ledgerStore) deletePinStatus(dataHash string) error {
+	if err := ls.ds.Delete(dsPinKey.ChildString(dataHash)); err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A ledger is recovered from a saved ledger root, or from the hashes of buckets, for example published with
+IPNS or kept in backups, whose names are taken from their bucket info. Recovery walks the bucket and object
+blocks, and writes everything else the ledger derives from them: the reference counts of object data, counted
+from the current, trashed, and noncurrent objects of every bucket and from the objects of every snapshot, the
+search index, listing records, and bucket info records.
+
+The reference counts are written first, since a bucket whose data is not counted could have its data collected
+when an object is removed, then the snapshots and multipart uploads, and the buckets last, each in a single
+batch with its entries. Recovery only writes to a ledger that holds nothing but the state being recovered, so
+an interrupted recovery is resumed by recovering the same root or buckets again: the reference counts are
+written as totals, and buckets that were already committed are skipped.
+*/
+
+// ledgerRecovery is the state of a ledger root loaded from IPFS
+type ledgerRecovery struct {
+	root       *LedgerRoot
+	buckets    map[string]*Bucket
+	dataHashes map[string]string // object hash to data hash
+	refs       map[string]int64  // data hash to number of references
+	objects    int64
+}
+
+// bucketsRoot returns a ledger root of the buckets with the given hashes, named after their bucket info
+func (ls *ledgerStore) bucketsRoot(ctx context.Context, hashes []string) (*LedgerRoot, error) {
+	root := &LedgerRoot{Buckets: make(map[string]string, len(hashes))}
+	for _, h := range hashes {
+		b, err := ipfsBucket(ctx, ls.dag, h)
+		if err != nil {
+			return nil, err
+		}
+		name := b.BucketInfo.Name
+		if name == "" {
+			return nil, ErrInvalidBucketName
+		}
+		if _, ok := root.Buckets[name]; ok {
+			return nil, ErrLedgerBucketExists
+		}
+		root.Buckets[name] = h
+	}
+	return root, nil
+}
+
+// loadRecovery loads the buckets of a ledger root and counts the references of their data
+func (ls *ledgerStore) loadRecovery(ctx context.Context, root *LedgerRoot) (*ledgerRecovery, error) {
+	r := &ledgerRecovery{
+		root:       root,
+		buckets:    make(map[string]*Bucket, len(root.Buckets)),
+		dataHashes: make(map[string]string),
+		refs:       make(map[string]int64),
+	}
+	for name, h := range root.Buckets {
+		b, err := ipfsBucket(ctx, ls.dag, h)
+		if err != nil {
+			return nil, err
+		}
+		if b.BucketInfo.Name != name {
+			return nil, fmt.Errorf("bucket name miss match %v != %v", name, b.BucketInfo.Name)
+		}
+		r.buckets[name] = b
+		r.objects += int64(len(b.Objects))
+		if err := r.reference(ctx, ls, b, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range root.Snapshots {
+		b, err := ipfsBucket(ctx, ls.dag, s.BucketHash)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.reference(ctx, ls, b, false); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// reference counts the references of the objects of a bucket to their data, including the objects in its
+// trash and its noncurrent versions if all is set, snapshots only reference the current objects
+func (r *ledgerRecovery) reference(ctx context.Context, ls *ledgerStore, b *Bucket, all bool) error {
+	objectHashes := make([]string, 0, len(b.Objects))
+	for _, h := range b.Objects {
+		objectHashes = append(objectHashes, h)
+	}
+	if all {
+		for _, d := range b.Trash {
+			objectHashes = append(objectHashes, d.ObjectHash)
+		}
+		for _, versions := range b.Versions {
+			for _, v := range versions.Versions {
+				objectHashes = append(objectHashes, v.ObjectHash)
+			}
+		}
+	}
+	for _, h := range objectHashes {
+		dataHash, ok := r.dataHashes[h]
+		if !ok {
+			obj, err := ipfsObject(ctx, ls.dag, h)
+			if err != nil {
+				return err
+			}
+			dataHash = obj.GetDataHash()
+			r.dataHashes[h] = dataHash
+		}
+		if dataHash != "" { // erasure coded objects have no data hash
+			r.refs[dataHash]++
+		}
+	}
+	return nil
+}
+
+// checkRecoverable returns ErrLedgerNotEmpty unless the ledger holds nothing but the state of a recovery,
+// which is left by an interrupted recovery of the same state
+func (ls *ledgerStore) checkRecoverable(r *ledgerRecovery) error {
+	if err := ls.forEachEntry(query.Query{Prefix: dsBucketKey.String()}, func(e query.Entry) error {
+		if r.root.Buckets[datastore.NewKey(e.Key).BaseNamespace()] != string(e.Value) {
+			return ErrLedgerNotEmpty
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	snapshots := make(map[datastore.Key]bool, len(r.root.Snapshots))
+	for _, s := range r.root.Snapshots {
+		snapshots[snapshotBucketKey(s.Bucket).ChildString(s.Id)] = true
+	}
+	if err := ls.forEachEntry(query.Query{Prefix: dsSnapshotKey.String(), KeysOnly: true}, func(e query.Entry) error {
+		if !snapshots[datastore.NewKey(e.Key)] {
+			return ErrLedgerNotEmpty
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return ls.forEachEntry(query.Query{Prefix: dsPartKey.String(), KeysOnly: true}, func(e query.Entry) error {
+		if _, ok := r.root.MultipartUploads[datastore.NewKey(e.Key).BaseNamespace()]; !ok {
+			return ErrLedgerNotEmpty
+		}
+		return nil
+	})
+}
+
+// Recover rebuilds an empty ledger from a ledger root
+func (ls *ledgerStore) Recover(ctx context.Context, root *LedgerRoot) (*RecoverLedgerResponse, error) {
+	ls.rootLocker.Lock()
+	defer ls.rootLocker.Unlock()
+	names := make([]string, 0, len(root.Buckets))
+	for name := range root.Buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		defer ls.locker.write(name)()
+	}
+	r, err := ls.loadRecovery(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.checkRecoverable(r); err != nil {
+		return nil, err
+	}
+	ls.rlocker.Lock()
+	for h, n := range r.refs {
+		if err := ls.ds.Put(dsRefKey.ChildString(h), []byte(strconv.FormatInt(n, 10))); err != nil {
+			ls.rlocker.Unlock()
+			return nil, err
+		}
+	}
+	ls.rlocker.Unlock()
+	for _, s := range root.Snapshots {
+		data, err := s.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if err := ls.ds.Put(snapshotBucketKey(s.Bucket).ChildString(s.Id), data); err != nil {
+			return nil, err
+		}
+	}
+	for id, h := range root.MultipartUploads {
+		data, err := ipfsBytes(ctx, ls.dag, h)
+		if err != nil {
+			return nil, err
+		}
+		m := &MultipartUpload{}
+		if err := m.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		if m.Id != id {
+			return nil, fmt.Errorf("multipart upload id miss match %v != %v", id, m.Id)
+		}
+		if err := ls.ds.Put(dsPartKey.ChildString(id), data); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		if err := ls.recoverBucket(ctx, name, root.Buckets[name], r.buckets[name]); err != nil {
+			return nil, err
+		}
+	}
+	return &RecoverLedgerResponse{
+		Buckets:          int64(len(root.Buckets)),
+		Objects:          r.objects,
+		Snapshots:        int64(len(root.Snapshots)),
+		MultipartUploads: int64(len(root.MultipartUploads)),
+		DataHashes:       int64(len(r.refs)),
+	}, nil
+}
+
+// recoverBucket commits a bucket hash with the info record, index, and listing records of the bucket,
+// a bucket that is already committed is skipped
+func (ls *ledgerStore) recoverBucket(ctx context.Context, bucket, hash string, b *Bucket) error {
+	committed, err := ls.ds.Get(dsBucketKey.ChildString(bucket))
+	if err == nil && string(committed) == hash {
+		return nil
+	}
+	if err != nil && err != datastore.ErrNotFound {
+		return err
+	}
+	writes := make([]ledgerWrite, 0, len(b.Objects)+2)
+	writes = append(writes, func(w datastore.Write) error {
+		return putBucketInfoRecord(w, bucket, &b.BucketInfo)
+	})
+	for name, h := range b.Objects {
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, ls.objectWrites(bucket, name, h, obj))
+	}
+	// all objects are indexed, so the index is complete
+	writes = append(writes, func(w datastore.Write) error {
+		return w.Put(indexBucketKey(bucket), nil)
+	})
+	if err := ls.commitBucket(bucket, hash, writes...); err != nil {
+		return err
+	}
+	ls.existence.set(bucket, true)
+	return nil
+}
+
+// RecoverLedger rebuilds an empty ledger from a ledger root or from bucket hashes saved on IPFS
+func (x *xObjects) RecoverLedger(ctx context.Context, req *RecoverLedgerRequest) (*RecoverLedgerResponse, error) {
+	if (req.GetRootHash() == "") == (len(req.GetBucketHashes()) == 0) {
+		return nil, status.Error(codes.InvalidArgument, "either a root hash or bucket hashes must be provided")
+	}
+	root := &LedgerRoot{}
+	var err error
+	if req.GetRootHash() != "" {
+		err = ipfsUnmarshal(ctx, x.dagClient, req.GetRootHash(), root)
+	} else {
+		root, err = x.ledgerStore.bucketsRoot(ctx, req.GetBucketHashes())
+	}
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp, err := x.ledgerStore.Recover(ctx, root)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("recovered-buckets: %v, objects: %v, snapshots: %v", resp.Buckets, resp.Objects, resp.Snapshots)
+	return resp, nil
+}
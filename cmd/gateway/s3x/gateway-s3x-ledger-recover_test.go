@@ -0,0 +1,162 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ledgerRefs returns the reference counts of all data hashes of a ledger
+func ledgerRefs(t *testing.T, ls *ledgerStore) map[string]string {
+	t.Helper()
+	refs := make(map[string]string)
+	if err := ls.forEachEntry(query.Query{Prefix: dsRefKey.String()}, func(e query.Entry) error {
+		refs[datastore.NewKey(e.Key).BaseNamespace()] = string(e.Value)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
+func TestS3X_LedgerRecover(t *testing.T) {
+	ctx := context.Background()
+	ls, _, dag := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{Config: &BucketConfig{
+		TrashRetentionDays: 1,
+		Versioning:         &VersioningConfig{Enabled: true},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.CreateBucket(ctx, testBucket2, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []string{"a", "b", "c"} {
+		if err := ls.PutObject(ctx, testBucket1, o, testLedgerObject(testBucket1, o, o)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// shared data, a noncurrent version, a trashed object, and a snapshot all reference data
+	if err := ls.PutObject(ctx, testBucket2, "a", testLedgerObject(testBucket2, "a", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.PutObject(ctx, testBucket1, "b", testLedgerObject(testBucket1, "b", "new b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.CreateSnapshot(ctx, testBucket1, "", time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.RemoveObject(ctx, testBucket1, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.NewMultipartUpload("upload1", "owner", &ObjectInfo{Bucket: testBucket2, Name: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ls.SaveRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &LedgerRoot{}
+	if err := ipfsUnmarshal(ctx, dag, info.Hash, root); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	recovered, err := newLedgerStore(ds, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := recovered.Recover(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Buckets != 2 || resp.Objects != 3 || resp.Snapshots != 1 || resp.MultipartUploads != 1 {
+		t.Fatalf("unexpected recovery %+v", resp)
+	}
+	for _, name := range []string{testBucket1, testBucket2} {
+		want, err := ls.GetBucketHash(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := recovered.GetBucketHash(name); err != nil || got != want {
+			t.Fatalf("expected %v to be recovered with hash %v, but got %v, %v", name, want, got, err)
+		}
+	}
+	checkCommitted(t, ds, dag, testBucket1, "a", "b")
+	checkCommitted(t, ds, dag, testBucket2, "a")
+	if complete, err := recovered.indexComplete(testBucket1); err != nil || !complete {
+		t.Fatalf("expected the recovered index to be complete, but got %v, %v", complete, err)
+	}
+	want, got := ledgerRefs(t, ls), ledgerRefs(t, recovered)
+	if len(got) != len(want) || int64(len(want)) != resp.DataHashes {
+		t.Fatalf("expected the references %v, but got %v", want, got)
+	}
+	for h, n := range want {
+		if got[h] != n {
+			t.Fatalf("expected %v references of %v, but got %v", n, h, got[h])
+		}
+	}
+	if snapshots, err := recovered.ListSnapshots(testBucket1); err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected the snapshot to be recovered, but got %v, %v", snapshots, err)
+	}
+	if err := recovered.MultipartIDExists("upload1"); err != nil {
+		t.Fatal(err)
+	}
+	// recovering the same root again resumes an interrupted recovery
+	if _, err := recovered.Recover(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recovered.CreateBucket(ctx, "other", &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recovered.Recover(ctx, root); err != ErrLedgerNotEmpty {
+		t.Fatalf("expected %v, but got %v", ErrLedgerNotEmpty, err)
+	}
+}
+
+func TestS3X_RecoverLedgerBuckets(t *testing.T) {
+	ctx := context.Background()
+	ls, _, dag := newFaultyLedger(t)
+	h, err := ls.CreateBucket(ctx, testBucket1, &Bucket{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.PutObject(ctx, testBucket1, "a", testLedgerObject(testBucket1, "a", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = ls.GetBucketHash(testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := newLedgerStore(dssync.MutexWrap(datastore.NewMapDatastore()), dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := &xObjects{ledgerStore: recovered, dagClient: dag}
+	for _, req := range []*RecoverLedgerRequest{{}, {RootHash: "root", BucketHashes: []string{h}}} {
+		if _, err := x.RecoverLedger(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+		}
+	}
+	if _, err := x.RecoverLedger(ctx, &RecoverLedgerRequest{BucketHashes: []string{h, h}}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected code %v, but got %v", codes.AlreadyExists, err)
+	}
+	resp, err := x.RecoverLedger(ctx, &RecoverLedgerRequest{BucketHashes: []string{h}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Buckets != 1 || resp.Objects != 1 || resp.DataHashes != 1 {
+		t.Fatalf("unexpected recovery %+v", resp)
+	}
+	if n, err := recovered.DataRefCount("data-a"); err != nil || n != 1 {
+		t.Fatalf("expected 1 reference, but got %v, %v", n, err)
+	}
+	if _, err := recovered.Object(ctx, testBucket1, "a"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package s3x
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ipfs/go-datastore"
+)
+
+/* Design Notes
+---------------
+
+Object data is content addressed, so many ledger objects (copies, renames, overwrites with the same data)
+can reference the same data hash. Reference counts are kept per data hash, so data is only considered
+unreferenced once no object in any bucket points to it anymore.
+*/
+
+// DataRefCount returns the number of ledger objects referencing the given data hash
+func (ls *ledgerStore) DataRefCount(hash string) (int64, error) {
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	return ls.dataRefCount(hash)
+}
+
+func (ls *ledgerStore) dataRefCount(hash string) (int64, error) {
+	data, err := ls.ds.Get(dsRefKey.ChildString(hash))
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// addDataRef increments the reference count of a data hash
+func (ls *ledgerStore) addDataRef(hash string) error {
+	if hash == "" {
+		return nil // erasure coded objects have no data hash
+	}
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	return ls.incrementDataRef(hash)
+}
+
+// incrementDataRef increments the reference count of a data hash, the caller holds rlocker
+func (ls *ledgerStore) incrementDataRef(hash string) error {
+	n, err := ls.dataRefCount(hash)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// the data is referenced again, so it must not be collected
+		if err := ls.dequeueGarbage(hash); err != nil {
+			return err
+		}
+	}
+	return ls.ds.Put(dsRefKey.ChildString(hash), []byte(strconv.FormatInt(n+1, 10)))
+}
+
+// removeDataRef decrements the reference count of a data hash and returns the remaining count,
+// unreferenced data hashes are enqueued for garbage collection
+func (ls *ledgerStore) removeDataRef(hash string) (int64, error) {
+	if hash == "" {
+		return 0, nil
+	}
+	ls.rlocker.Lock()
+	defer ls.rlocker.Unlock()
+	return ls.decrementDataRef(hash)
+}
+
+// decrementDataRef decrements the reference count of a data hash and returns the remaining count,
+// the caller holds rlocker
+func (ls *ledgerStore) decrementDataRef(hash string) (int64, error) {
+	n, err := ls.dataRefCount(hash)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 1 {
+		// data saved before reference counting was added has no count, treat it as a single reference
+		if err := ls.ds.Delete(dsRefKey.ChildString(hash)); err != nil {
+			return 0, err
+		}
+		return 0, ls.enqueueGarbage(hash)
+	}
+	return n - 1, ls.ds.Put(dsRefKey.ChildString(hash), []byte(strconv.FormatInt(n-1, 10)))
+}
+
+// objectDataHashNilable returns the data hash of an object, or an empty string if the object does not exist
+func (ls *ledgerStore) objectDataHashNilable(ctx context.Context, bucket, object string) (string, error) {
+	obj, err := ls.object(ctx, bucket, object)
+	if err == ErrLedgerObjectDoesNotExist {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return obj.GetDataHash(), nil
+}
@@ -0,0 +1,151 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Buckets and objects are IPFS blocks, but the names of the buckets, the snapshots, and the multipart uploads
+in progress are only kept in the datastore, so losing the datastore loses the way to the blocks. The ledger
+root is an IPFS block that links this state: the hash of every bucket by name, the snapshots of all buckets,
+and the hash of every multipart upload, which is saved as a block when the root is saved. Everything else in
+the datastore is derived from the linked blocks, and rebuilt by recovery: the reference counts of object data,
+the search index, listing records, and bucket info records.
+
+The root is saved every ledger root interval if it is enabled, and on request, and the datastore only keeps the
+hash of the last saved root, so operators can publish it, for example with IPNS, and recover the ledger from
+IPFS alone. The root is built from the committed bucket hashes, so it never links a bucket state that was not
+committed, and the ledger is not locked while it is saved. A root with the same state has the same hash, so
+saving a ledger that did not change saves no new block.
+*/
+
+// dsRootKey holds the LedgerRootInfo of the last saved ledger root, badger queries match raw key prefixes,
+// so the key must not start with the letter of another ledger key
+var dsRootKey = datastore.NewKey("t")
+
+// ledgerRoot returns the root of the committed state of the ledger
+func (ls *ledgerStore) ledgerRoot(ctx context.Context) (*LedgerRoot, error) {
+	root := &LedgerRoot{
+		Buckets:          make(map[string]string),
+		MultipartUploads: make(map[string]string),
+	}
+	if err := ls.forEachEntry(query.Query{Prefix: dsBucketKey.String()}, func(e query.Entry) error {
+		root.Buckets[datastore.NewKey(e.Key).BaseNamespace()] = string(e.Value)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := ls.forEachEntry(query.Query{Prefix: dsSnapshotKey.String()}, func(e query.Entry) error {
+		s := Snapshot{}
+		if err := s.Unmarshal(e.Value); err != nil {
+			return err
+		}
+		root.Snapshots = append(root.Snapshots, s)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(root.Snapshots, func(i, j int) bool {
+		a, b := root.Snapshots[i], root.Snapshots[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		return a.Id < b.Id
+	})
+	if err := ls.forEachEntry(query.Query{Prefix: dsPartKey.String()}, func(e query.Entry) error {
+		h, err := ipfsSaveBytes(ctx, ls.dag, e.Value)
+		if err != nil {
+			return err
+		}
+		root.MultipartUploads[datastore.NewKey(e.Key).BaseNamespace()] = h
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// SaveRoot saves the root of the committed state of the ledger to IPFS, and records its hash
+func (ls *ledgerStore) SaveRoot(ctx context.Context) (*LedgerRootInfo, error) {
+	ls.rootLocker.Lock()
+	defer ls.rootLocker.Unlock()
+	root, err := ls.ledgerRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h, err := ipfsSave(ctx, ls.dag, root)
+	if err != nil {
+		return nil, err
+	}
+	info := &LedgerRootInfo{
+		Hash:             h,
+		Saved:            ls.clock.Now().Unix(),
+		Buckets:          int64(len(root.Buckets)),
+		Snapshots:        int64(len(root.Snapshots)),
+		MultipartUploads: int64(len(root.MultipartUploads)),
+	}
+	data, err := info.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.ds.Put(dsRootKey, data); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Root returns the last saved root of the ledger
+func (ls *ledgerStore) Root() (*LedgerRootInfo, error) {
+	data, err := ls.ds.Get(dsRootKey)
+	if err == datastore.ErrNotFound {
+		return nil, ErrLedgerRootNotSaved
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &LedgerRootInfo{}
+	return info, info.Unmarshal(data)
+}
+
+// ledgerRootLoop saves the ledger root every interval until the gateway is shut down
+func (x *xObjects) ledgerRootLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := x.ledgerStore.SaveRoot(x.ctx); err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to save the ledger root: %v", err)
+			}
+		}
+	}
+}
+
+// SaveLedgerRoot saves the root of the ledger to IPFS, the ledger can be recovered from the hash of the root
+func (x *xObjects) SaveLedgerRoot(ctx context.Context, req *SaveLedgerRootRequest) (*LedgerRootInfo, error) {
+	info, err := x.ledgerStore.SaveRoot(ctx)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("ledger-root: %s, buckets: %v", info.Hash, info.Buckets)
+	return info, nil
+}
+
+// GetLedgerRoot returns the last saved root of the ledger
+func (x *xObjects) GetLedgerRoot(ctx context.Context, req *GetLedgerRootRequest) (*LedgerRootInfo, error) {
+	info, err := x.ledgerStore.Root()
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return info, nil
+}
@@ -0,0 +1,83 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestS3X_LedgerRoot(t *testing.T) {
+	ctx := context.Background()
+	ls, _, dag := newFaultyLedger(t)
+	if _, err := ls.Root(); err != ErrLedgerRootNotSaved {
+		t.Fatalf("expected %v, but got %v", ErrLedgerRootNotSaved, err)
+	}
+	for _, b := range []string{testBucket1, testBucket2} {
+		if _, err := ls.CreateBucket(ctx, b, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ls.PutObject(ctx, testBucket1, "a", testLedgerObject(testBucket1, "a", "a")); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ls.CreateSnapshot(ctx, testBucket1, "", time.Unix(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := &ObjectInfo{Bucket: testBucket2, Name: "b"}
+	if err := ls.NewMultipartUpload("upload1", "owner", upload); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ls.SaveRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GetBuckets() != 2 || info.GetSnapshots() != 1 || info.GetMultipartUploads() != 1 {
+		t.Fatalf("unexpected root info %+v", info)
+	}
+	if saved, err := ls.Root(); err != nil || saved.GetHash() != info.GetHash() {
+		t.Fatalf("expected the saved root %v, but got %v, %v", info.GetHash(), saved, err)
+	}
+	// the root links the state of the ledger
+	root := &LedgerRoot{}
+	if err := ipfsUnmarshal(ctx, dag, info.GetHash(), root); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{testBucket1, testBucket2} {
+		b, err := ls.getBucketLoaded(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root.Buckets[name] != b.IpfsHash {
+			t.Fatalf("expected the root to link %v of %v, but got %v", b.IpfsHash, name, root.Buckets[name])
+		}
+	}
+	if len(root.Snapshots) != 1 || root.Snapshots[0].GetBucketHash() != snapshot.GetBucketHash() {
+		t.Fatalf("expected the root to hold the snapshot %+v, but got %+v", snapshot, root.Snapshots)
+	}
+	m := &MultipartUpload{}
+	if err := ipfsUnmarshal(ctx, dag, root.MultipartUploads["upload1"], m); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetId() != "upload1" || m.GetObjectInfo().GetName() != "b" {
+		t.Fatalf("expected the root to link the multipart upload, but got %+v", m)
+	}
+	// the root only changes with the ledger
+	again, err := ls.SaveRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.GetHash() != info.GetHash() {
+		t.Fatalf("expected the root of an unchanged ledger to keep its hash %v, but got %v", info.GetHash(), again.GetHash())
+	}
+	if err := ls.RemoveObject(ctx, testBucket1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := ls.SaveRoot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.GetHash() == info.GetHash() {
+		t.Fatal("expected the root to change with the ledger")
+	}
+}
@@ -0,0 +1,189 @@
+package s3x
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+)
+
+/* Design Notes
+---------------
+
+A bucket block holds the hashes of its objects while it has at most maxShardObjects objects. The objects of larger
+buckets are split into a power of two number of ObjectShard blocks by the FNV hash of their names, so a shard holds
+about maxShardObjects objects on average, and the bucket block only holds the hashes of its shards. The number of
+shards only depends on the number of objects, and the shard of an object only on its name, so the hash of a bucket
+still only depends on its contents. A bucket that grows or shrinks past a power of two rewrites all of its shards.
+
+Loaded buckets hold all of their objects in Bucket.Objects, the shards are only how they are saved. Object names
+are set and deleted with setObject and deleteObject, which record the changed names of the loaded bucket, so saving
+a bucket only rewrites the shards of the changed names, by patching the saved shards, and the bucket block. The
+cost of a mutation does not grow with the number of objects of the bucket. A bucket whose Bucket.ObjectShards do
+not match its number of objects, such as a new bucket, writes all of its shards.
+*/
+
+// maxShardObjects is the number of objects a bucket block holds before its objects are split into shards,
+// and the average number of objects of a shard, a variable so tests can shard small buckets
+var maxShardObjects = 1024
+
+// shardCount returns the number of shards of a bucket with n objects, 0 if the bucket block holds its objects
+func shardCount(n int) int {
+	if n <= maxShardObjects {
+		return 0
+	}
+	count := 2
+	for count*maxShardObjects < n {
+		count *= 2
+	}
+	return count
+}
+
+// shardOf returns the shard of an object of a bucket with count shards
+func shardOf(object string, count int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(object)) // hashes never return errors
+	return int(h.Sum32() & uint32(count-1))
+}
+
+// ipfsShard returns an object shard from IPFS using its hash
+func ipfsShard(ctx context.Context, dag pb.NodeAPIClient, h string) (*ObjectShard, error) {
+	s := &ObjectShard{}
+	if err := ipfsUnmarshal(ctx, dag, h, s); err != nil {
+		return nil, err
+	}
+	if s.Objects == nil {
+		s.Objects = make(map[string]string)
+	}
+	return s, nil
+}
+
+// loadShards adds the objects of the shards of a bucket block to the bucket
+func loadShards(ctx context.Context, dag pb.NodeAPIClient, b *Bucket) error {
+	if len(b.ObjectShards) > 0 && b.Objects == nil {
+		b.Objects = make(map[string]string)
+	}
+	for _, h := range b.ObjectShards {
+		s, err := ipfsShard(ctx, dag, h)
+		if err != nil {
+			return err
+		}
+		for name, objHash := range s.Objects {
+			b.Objects[name] = objHash
+		}
+	}
+	return nil
+}
+
+// changedObjects records the names of the objects of loaded buckets that changed since the buckets were saved
+type changedObjects struct {
+	mu      sync.Mutex
+	buckets map[string]*changedNames
+}
+
+// changedNames are the changed object names of a loaded bucket
+type changedNames struct {
+	bucket *Bucket
+	names  map[string]struct{}
+}
+
+// add records that an object of a loaded bucket changed, the names recorded for another loaded bucket of
+// the same name, which was evicted, are dropped
+func (c *changedObjects) add(b *Bucket, object string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buckets == nil {
+		c.buckets = make(map[string]*changedNames)
+	}
+	n, ok := c.buckets[b.BucketInfo.Name]
+	if !ok || n.bucket != b {
+		n = &changedNames{bucket: b, names: make(map[string]struct{})}
+		c.buckets[b.BucketInfo.Name] = n
+	}
+	n.names[object] = struct{}{}
+}
+
+// get returns the changed object names of a loaded bucket, the caller holds the write lock of the bucket
+func (c *changedObjects) get(b *Bucket) map[string]struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.buckets[b.BucketInfo.Name]; ok && n.bucket == b {
+		return n.names
+	}
+	return nil
+}
+
+// reset forgets the changed object names of a bucket, once it is saved or removed
+func (c *changedObjects) reset(bucket string) {
+	c.mu.Lock()
+	delete(c.buckets, bucket)
+	c.mu.Unlock()
+}
+
+// setObject maps an object name of a loaded bucket to an object hash
+func (ls *ledgerStore) setObject(b *Bucket, object, objHash string) {
+	if b.Objects == nil {
+		b.Objects = make(map[string]string)
+	}
+	b.Objects[object] = objHash
+	ls.changed.add(b, object)
+}
+
+// deleteObject removes an object name from a loaded bucket
+func (ls *ledgerStore) deleteObject(b *Bucket, object string) {
+	delete(b.Objects, object)
+	ls.changed.add(b, object)
+}
+
+// saveBucketBlocks saves the shards of the changed objects of a bucket and its block, and returns the hash of the
+// bucket and of its shards
+func (ls *ledgerStore) saveBucketBlocks(ctx context.Context, b *Bucket) (string, []string, error) {
+	block := *b
+	count := shardCount(len(b.Objects))
+	if count == 0 {
+		block.ObjectShards = nil
+		h, err := ipfsSave(ctx, ls.dag, &block)
+		return h, nil, err
+	}
+	block.Objects = nil
+	block.ObjectShards = make([]string, count)
+	var shards map[int]*ObjectShard
+	if len(b.ObjectShards) == count {
+		copy(block.ObjectShards, b.ObjectShards)
+		shards = make(map[int]*ObjectShard)
+		for name := range ls.changed.get(b) {
+			i := shardOf(name, count)
+			s, ok := shards[i]
+			if !ok {
+				var err error
+				if s, err = ipfsShard(ctx, ls.dag, b.ObjectShards[i]); err != nil {
+					return "", nil, err
+				}
+				shards[i] = s
+			}
+			if h, ok := b.Objects[name]; ok {
+				s.Objects[name] = h
+			} else {
+				delete(s.Objects, name)
+			}
+		}
+	} else {
+		shards = make(map[int]*ObjectShard, count)
+		for i := 0; i < count; i++ {
+			shards[i] = &ObjectShard{Objects: make(map[string]string, len(b.Objects)/count)}
+		}
+		for name, h := range b.Objects {
+			shards[shardOf(name, count)].Objects[name] = h
+		}
+	}
+	for i, s := range shards {
+		h, err := ipfsSave(ctx, ls.dag, s)
+		if err != nil {
+			return "", nil, err
+		}
+		block.ObjectShards[i] = h
+	}
+	h, err := ipfsSave(ctx, ls.dag, &block)
+	return h, block.ObjectShards, err
+}
@@ -0,0 +1,166 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/ipfs/go-merkledag"
+	"google.golang.org/grpc"
+)
+
+// cidDag is an in memory dag api that returns CIDs, so proofs of its blocks can be verified
+type cidDag struct {
+	*memoryDag
+}
+
+func (d cidDag) Dag(ctx context.Context, req *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if req.GetRequestType() != pb.DAGREQTYPE_DAG_PUT {
+		return d.memoryDag.Dag(ctx, req, opts...)
+	}
+	c, err := merkledag.V1CidPrefix().Sum(req.GetData())
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nodes[c.String()] = req.GetData()
+	return &pb.DagResponse{Hashes: []string{c.String()}}, nil
+}
+
+// withMaxShardObjects runs f with buckets sharded once they have more than max objects
+func withMaxShardObjects(max int, f func()) {
+	defer func(max int) { maxShardObjects = max }(maxShardObjects)
+	maxShardObjects = max
+	f()
+}
+
+func TestShardCount(t *testing.T) {
+	withMaxShardObjects(4, func() {
+		for n, count := range map[int]int{0: 0, 4: 0, 5: 2, 8: 2, 9: 4, 16: 4, 17: 8, 1000: 256} {
+			if got := shardCount(n); got != count {
+				t.Fatalf("expected %d shards of %d objects, but got %d", count, n, got)
+			}
+		}
+	})
+}
+
+func TestS3X_LedgerShards(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Commit", func(t *testing.T) {
+		// every bucket with more than one object is sharded
+		withMaxShardObjects(1, func() { TestS3X_LedgerCommit(t) })
+	})
+	t.Run("CommitFault", func(t *testing.T) {
+		withMaxShardObjects(1, func() { TestS3X_LedgerCommitFault(t) })
+	})
+	withMaxShardObjects(4, func() {
+		ls, ds, dag := newFaultyLedger(t)
+		ls.dag = cidDag{dag}
+		if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for i := 0; i < 40; i++ {
+			name := fmt.Sprintf("object%02d", i)
+			if err := ls.PutObject(ctx, testBucket1, name, testLedgerObject(testBucket1, name, name)); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, name)
+		}
+		committed := checkCommitted(t, ds, dag, testBucket1, names...)
+		b, err := ls.getBucketLoaded(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Bucket.ObjectShards) != 16 {
+			t.Fatalf("expected 16 shards, but got %d", len(b.Bucket.ObjectShards))
+		}
+		block := &Bucket{}
+		if err := ipfsUnmarshal(ctx, ls.dag, b.IpfsHash, block); err != nil {
+			t.Fatal(err)
+		}
+		if len(block.Objects) != 0 {
+			t.Fatalf("expected the bucket block to only link its shards, but it has %d objects", len(block.Objects))
+		}
+
+		t.Run("Mutation", func(t *testing.T) {
+			shards := append([]string(nil), b.Bucket.ObjectShards...)
+			if err := ls.PutObject(ctx, testBucket1, "object07", testLedgerObject(testBucket1, "object07", "new")); err != nil {
+				t.Fatal(err)
+			}
+			changed := 0
+			for i, h := range b.Bucket.ObjectShards {
+				if h != shards[i] {
+					changed++
+					if i != shardOf("object07", len(shards)) {
+						t.Fatalf("expected only the shard of object07 to change, but shard %d changed", i)
+					}
+				}
+			}
+			if changed != 1 {
+				t.Fatalf("expected the shard of object07 to change, but %d shards changed", changed)
+			}
+			if got := checkCommitted(t, ds, dag, testBucket1, names...)["object07"]; got == committed["object07"] {
+				t.Fatal("expected the replaced object to be committed")
+			}
+		})
+		t.Run("Proof", func(t *testing.T) {
+			proof, err := ls.ObjectProof(ctx, testBucket1, "object11")
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof.DataHash = "data-object11"
+			bucketHash, err := ls.GetBucketHash(testBucket1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyObjectProof(bucketHash, proof); err != nil {
+				t.Fatal(err)
+			}
+			tampered := *proof
+			tampered.ShardBlock = append([]byte(nil), proof.ShardBlock...)
+			tampered.ShardBlock[len(tampered.ShardBlock)-1] ^= 1
+			if err := VerifyObjectProof(bucketHash, &tampered); err == nil {
+				t.Fatal("expected a tampered shard block to fail verification")
+			}
+		})
+		t.Run("Deterministic", func(t *testing.T) {
+			other, _, _ := newFaultyLedger(t)
+			other.dag = ls.dag
+			if _, err := other.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+				t.Fatal(err)
+			}
+			for i := len(names) - 1; i >= 0; i-- {
+				data := names[i]
+				if names[i] == "object07" {
+					data = "new"
+				}
+				if err := other.PutObject(ctx, testBucket1, names[i], testLedgerObject(testBucket1, names[i], data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h, err := other.GetBucketHash(testBucket1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, err := ls.GetBucketHash(testBucket1); err != nil || h != want {
+				t.Fatalf("expected the bucket hash %s regardless of the order of writes, but got %s, %v", want, h, err)
+			}
+		})
+		t.Run("Shrink", func(t *testing.T) {
+			if _, err := ls.RemoveObjects(ctx, testBucket1, names[4:]...); err != nil {
+				t.Fatal(err)
+			}
+			checkCommitted(t, ds, dag, testBucket1, names[:4]...)
+			b, err := ls.getBucketLoaded(ctx, testBucket1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(b.Bucket.ObjectShards) != 0 {
+				t.Fatalf("expected a bucket of 4 objects to have no shards, but got %d", len(b.Bucket.ObjectShards))
+			}
+		})
+	})
+}
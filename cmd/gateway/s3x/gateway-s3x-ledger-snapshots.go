@@ -0,0 +1,233 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+/* Design Notes
+---------------
+
+Buckets are immutable IPFS DAGs, so a snapshot only records the hash of a bucket at a point in time.
+To keep the data of the snapshot objects from being considered unreferenced once the objects are overwritten
+or removed, every snapshot holds a data reference for each of its objects, which is released when
+the snapshot is pruned.
+
+Snapshots are kept in the datastore under the bucket name, with ids ordered by creation time,
+and outlive the bucket, so a deleted bucket can still be restored from them.
+*/
+
+// dsSnapshotKey maps bucket names and snapshot ids to snapshots
+var dsSnapshotKey = datastore.NewKey("s")
+
+// snapshotBucketKey returns the parent key of the snapshots of a bucket
+func snapshotBucketKey(bucket string) datastore.Key {
+	return dsSnapshotKey.ChildString(bucket)
+}
+
+// newSnapshotID returns a snapshot id that sorts by creation time,
+// snapshots of several schedules can be taken at the same time.
+func newSnapshotID(created time.Time, schedule string) string {
+	if schedule == "" {
+		return fmt.Sprintf("%019d", created.UnixNano())
+	}
+	return fmt.Sprintf("%019d-%s", created.UnixNano(), schedule)
+}
+
+// CreateSnapshot records the current hash of a bucket, schedule is empty for snapshots that are not scheduled
+func (ls *ledgerStore) CreateSnapshot(ctx context.Context, bucket, schedule string, created time.Time) (*Snapshot, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	dataHashes := make([]string, 0, len(b.Bucket.Objects))
+	for name := range b.Bucket.Objects {
+		h, err := ls.objectDataHashNilable(ctx, bucket, name)
+		if err != nil {
+			return nil, err
+		}
+		dataHashes = append(dataHashes, h)
+	}
+	s := &Snapshot{
+		Id:         newSnapshotID(created, schedule),
+		Bucket:     bucket,
+		BucketHash: b.IpfsHash,
+		Created:    created.UTC(),
+		Schedule:   schedule,
+		Objects:    int64(len(b.Bucket.Objects)),
+	}
+	data, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.ds.Put(snapshotBucketKey(bucket).ChildString(s.Id), data); err != nil {
+		return nil, err
+	}
+	for _, h := range dataHashes {
+		if err := ls.addDataRef(h); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ListSnapshots returns the snapshots of a bucket ordered by creation time
+func (ls *ledgerStore) ListSnapshots(bucket string) ([]*Snapshot, error) {
+	defer ls.locker.read(bucket)()
+	return ls.listSnapshots(bucket)
+}
+
+func (ls *ledgerStore) listSnapshots(bucket string) ([]*Snapshot, error) {
+	rs, err := ls.ds.Query(query.Query{Prefix: snapshotBucketKey(bucket).String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rs.Rest()
+	if err != nil {
+		return nil, err
+	}
+	parent := snapshotBucketKey(bucket)
+	var snapshots []*Snapshot
+	for _, e := range entries {
+		// skip the snapshots of buckets with names starting with this bucket name
+		if !datastore.NewKey(e.Key).Parent().Equal(parent) {
+			continue
+		}
+		s := &Snapshot{}
+		if err := s.Unmarshal(e.Value); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Id < snapshots[j].Id
+	})
+	return snapshots, nil
+}
+
+func (ls *ledgerStore) snapshot(bucket, id string) (*Snapshot, error) {
+	data, err := ls.ds.Get(snapshotBucketKey(bucket).ChildString(id))
+	if err == datastore.ErrNotFound {
+		return nil, ErrLedgerSnapshotDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	return s, s.Unmarshal(data)
+}
+
+// snapshotObjects returns the objects of a snapshot, and the data hashes they reference
+func (ls *ledgerStore) snapshotObjects(ctx context.Context, s *Snapshot) (*Bucket, []string, error) {
+	b, err := ipfsBucket(ctx, ls.dag, s.BucketHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataHashes := make([]string, 0, len(b.Objects))
+	for _, h := range b.Objects {
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		dataHashes = append(dataHashes, obj.GetDataHash())
+	}
+	return b, dataHashes, nil
+}
+
+// deleteSnapshot removes a snapshot and releases its data references,
+// returning the data hashes that are no longer referenced.
+func (ls *ledgerStore) deleteSnapshot(ctx context.Context, s *Snapshot) ([]string, error) {
+	_, dataHashes, err := ls.snapshotObjects(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.ds.Delete(snapshotBucketKey(s.Bucket).ChildString(s.Id)); err != nil {
+		return nil, err
+	}
+	var unreferenced []string
+	for _, h := range dataHashes {
+		n, err := ls.removeDataRef(h)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 && h != "" {
+			unreferenced = append(unreferenced, h)
+		}
+	}
+	return unreferenced, nil
+}
+
+// PruneSnapshots removes the oldest scheduled snapshots of a bucket beyond the number keep allows for their schedule,
+// and returns the pruned snapshots and the data hashes that are no longer referenced.
+// Snapshots that were not scheduled are never pruned.
+func (ls *ledgerStore) PruneSnapshots(ctx context.Context, bucket string, keep map[string]int64) ([]*Snapshot, []string, error) {
+	defer ls.locker.write(bucket)()
+	snapshots, err := ls.listSnapshots(bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		pruned       []*Snapshot
+		unreferenced []string
+		kept         = make(map[string]int64)
+	)
+	// newest first, so the most recent snapshots of each schedule are kept
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		if s.Schedule == "" {
+			continue
+		}
+		if kept[s.Schedule] < keep[s.Schedule] {
+			kept[s.Schedule]++
+			continue
+		}
+		hashes, err := ls.deleteSnapshot(ctx, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		pruned = append(pruned, s)
+		unreferenced = append(unreferenced, hashes...)
+	}
+	return pruned, unreferenced, nil
+}
+
+// RestoreSnapshot creates newBucket with b and the location and objects of a snapshot of bucket,
+// the data of every restored object gains a reference.
+func (ls *ledgerStore) RestoreSnapshot(ctx context.Context, bucket, id, newBucket string, b *Bucket) (string, int, error) {
+	// always lock in the same order, so concurrent restores in opposite directions can not deadlock
+	if bucket < newBucket {
+		defer ls.locker.read(bucket)()
+		defer ls.locker.write(newBucket)()
+	} else if bucket > newBucket {
+		defer ls.locker.write(newBucket)()
+		defer ls.locker.read(bucket)()
+	} else {
+		defer ls.locker.write(newBucket)()
+	}
+	s, err := ls.snapshot(bucket, id)
+	if err != nil {
+		return "", 0, err
+	}
+	src, dataHashes, err := ls.snapshotObjects(ctx, s)
+	if err != nil {
+		return "", 0, err
+	}
+	b.BucketInfo.Location = src.BucketInfo.Location
+	b.Objects = src.Objects
+	lb, err := ls.createBucket(ctx, newBucket, b)
+	if err != nil {
+		return "", 0, err
+	}
+	for _, h := range dataHashes {
+		if err := ls.addDataRef(h); err != nil {
+			return "", 0, err
+		}
+	}
+	return lb.IpfsHash, len(b.Objects), nil
+}
@@ -21,6 +21,7 @@ var (
 	dsPrefix    = datastore.NewKey("ledgerRoot")
 	dsBucketKey = datastore.NewKey("b") //bucket name to ipfsHash of LedgerBucketEntry
 	dsPartKey   = datastore.NewKey("p") //part ID to MultipartUpload
+	dsRefKey    = datastore.NewKey("r") //data hash to number of objects referencing it
 )
 
 // ledgerStore is an internal bookkeeper that
@@ -30,26 +31,46 @@ var (
 // Object hashes are saved in ipfs and cached in memory,
 // Object data is saved in ipfs.
 type ledgerStore struct {
-	ds  datastore.Batching
-	dag pb.NodeAPIClient //to be used as direct access to ipfs to optimize algorithm
-	l   *Ledger          //a cache of the values in datastore and ipfs
+	ds       datastore.Batching
+	dsHealth *datastoreHealth    //summarizes the recent operations on ds
+	backend  datastore.Datastore //the datastore the ledger is stored in, below crdt and namespaces, nil if unknown
+	dag      pb.NodeAPIClient    //to be used as direct access to ipfs to optimize algorithm
+	l        *Ledger             //a cache of the values in datastore and ipfs
 
 	locker     bucketLocker //a locker to protect buckets from concurrent access (per bucket)
 	plocker    bucketLocker //a locker to protect MultipartUploads from concurrent access (per upload ID)
 	mapLocker  sync.Mutex   //a lock to protect the l.Buckets map from concurrent access
 	pmapLocker sync.Mutex   //a lock to protect the l.MultipartUploads map from concurrent access
+	rlocker    ledgerMutex  //a lock to protect data hash reference counts from concurrent access
+	rootLocker ledgerMutex  //a lock to serialize saving the ledger root
+	standby    int32        //1 while the ledger is the empty ledger of a standby gateway, accessed atomically
+
+	existence bucketExistence //caches whether buckets exist, without loading them
+	changed   changedObjects  //records the objects of loaded buckets that changed since they were saved
+	batchSize int             //the maximum number of entries held by listings, deletions, and garbage collection
+	sessions  listingSessions //caches the bucket snapshots listed by listing sessions
+	names     *objectNames    //encodes the object names of ledger keys
+	shared    bool            //true if other gateways of a cluster write to the datastore
+
+	events *eventHub //publishes object changes to event stream clients
+	clock  Clock     //provides the timestamps of trashed objects and versions
 
 	cleanup []func() error //a list of functions to call before we close the backing database.
 }
 
 func newLedgerStore(ds datastore.Batching, dag pb.NodeAPIClient) (*ledgerStore, error) {
+	dsHealth := newDatastoreHealth()
 	ls := &ledgerStore{
-		ds:  namespace.Wrap(ds, dsPrefix),
-		dag: dag,
+		ds:       namespace.Wrap(instrumentDatastore(ds, dsHealth), dsPrefix),
+		dsHealth: dsHealth,
+		dag:      dag,
 		l: &Ledger{
 			Buckets:          make(map[string]*LedgerBucketEntry),
 			MultipartUploads: make(map[string]*MultipartUpload),
 		},
+		events:    newEventHub(),
+		clock:     systemClock{},
+		batchSize: defaultLedgerBatchSize,
 	}
 	return ls, nil
 }
@@ -78,6 +99,12 @@ func (ls *ledgerStore) object(ctx context.Context, bucket, object string) (*Obje
 	return ipfsObject(ctx, ls.dag, h)
 }
 
+//Object returns the object, which holds both the data hash and the ObjectInfo.
+func (ls *ledgerStore) Object(ctx context.Context, bucket, object string) (*Object, error) {
+	defer ls.locker.read(bucket)()
+	return ls.object(ctx, bucket, object)
+}
+
 //ObjectInfo returns the ObjectInfo of the object.
 func (ls *ledgerStore) ObjectInfo(ctx context.Context, bucket, object string) (*ObjectInfo, error) {
 	defer ls.locker.read(bucket)()
@@ -136,45 +163,153 @@ func (ls *ledgerStore) removeObjects(ctx context.Context, bucket string, objects
 		return objects, nil
 	}
 
+	now := ls.clock.Now()
+	if err := ls.assertNotRetained(ctx, b.Bucket, now, objects...); err != nil {
+		return nil, err
+	}
 	missing := []string{}
+	removed := make([]string, 0, len(objects))
+	dataHashes := make([]string, 0, len(objects))
+	// versioning takes precedence over the trash
+	trash := !versioningEnabled(b.Bucket) && b.Bucket.GetConfig().GetTrashRetentionDays() > 0
 	for _, o := range objects {
-		_, ok := b.Bucket.Objects[o]
+		h, ok := b.Bucket.Objects[o]
 		if !ok {
 			missing = append(missing, o)
 			continue
 		}
-		delete(b.Bucket.Objects, o)
+		if trash {
+			// the data of trashed objects stays referenced until the trash is purged,
+			// only the replaced trash entry of the same name is released now
+			replaced, err := ls.trashObject(ctx, b.Bucket, o, h)
+			if err != nil {
+				return nil, err
+			}
+			if replaced != "" {
+				dataHashes = append(dataHashes, replaced)
+			}
+			ls.deleteObject(b.Bucket, o)
+			removed = append(removed, o)
+			continue
+		}
+		retired, err := ls.retireObject(ctx, bucket, b.Bucket, o, now)
+		if err != nil {
+			return nil, err
+		}
+		dataHashes = append(dataHashes, retired...)
+		ls.deleteObject(b.Bucket, o)
+		removed = append(removed, o)
 	}
-	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
-	return missing, err
+	if _, err = ls.saveBucket(ctx, bucket, b.Bucket, ls.removedObjectWrites(bucket, removed...)); err != nil {
+		return nil, err
+	}
+	for _, o := range removed {
+		ls.events.publish(newObjectEvent(eventObjectRemoved, bucket, o, nil, ls.clock.Now()))
+	}
+	for _, h := range dataHashes {
+		if _, err := ls.removeDataRef(h); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
 	//todo: gc on ipfs
 }
 
+// RenameObject moves an object to a new name within the same bucket without touching the object data,
+// an existing object with the new name is only replaced if overwrite is set.
+func (ls *ledgerStore) RenameObject(ctx context.Context, bucket, object, newObject string, overwrite bool) (string, error) {
+	defer ls.locker.write(bucket)()
+	return ls.renameObject(ctx, bucket, object, newObject, overwrite)
+}
+
+func (ls *ledgerStore) renameObject(ctx context.Context, bucket, object, newObject string, overwrite bool) (string, error) {
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := b.Bucket.Objects[object]; !ok {
+		return "", ErrLedgerObjectDoesNotExist
+	}
+	if _, ok := b.Bucket.Objects[newObject]; ok && !overwrite {
+		return "", ErrLedgerObjectExists
+	}
+	if err := ls.assertNotRetained(ctx, b.Bucket, ls.clock.Now(), object, newObject); err != nil {
+		return "", err
+	}
+	obj, err := ls.object(ctx, bucket, object)
+	if err != nil {
+		return "", err
+	}
+	obj.ObjectInfo.Name = newObject
+	oHash, err := ipfsSave(ctx, ls.dag, obj)
+	if err != nil {
+		return "", err
+	}
+	replacedDataHashes, err := ls.retireObject(ctx, bucket, b.Bucket, newObject, ls.clock.Now())
+	if err != nil {
+		return "", err
+	}
+	// both keys are updated in a single bucket save, so the rename is atomic
+	ls.deleteObject(b.Bucket, object)
+	ls.setObject(b.Bucket, newObject, oHash)
+	if _, err = ls.saveBucket(ctx, bucket, b.Bucket,
+		ls.removedObjectWrites(bucket, object), ls.objectWrites(bucket, newObject, oHash, obj)); err != nil {
+		return "", err
+	}
+	ls.events.publish(newObjectEvent(eventObjectRemoved, bucket, object, nil, ls.clock.Now()))
+	ls.events.publish(newObjectEvent(eventObjectCreated, bucket, newObject, &obj.ObjectInfo, ls.clock.Now()))
+	if _, err := ls.releaseData(replacedDataHashes); err != nil {
+		return "", err
+	}
+	return oHash, nil
+}
+
 //PutObject saves an object by hash into the given bucket
 func (ls *ledgerStore) PutObject(ctx context.Context, bucket, object string, obj *Object) error {
 	defer ls.locker.write(bucket)()
 	return ls.putObject(ctx, bucket, object, obj)
 }
 
-//putObject saves an object by hash into the given bucket
-func (ls *ledgerStore) putObject(ctx context.Context, bucket, object string, obj *Object) error {
+//putObject saves an object by hash into the given bucket, together with the given writes
+func (ls *ledgerStore) putObject(ctx context.Context, bucket, object string, obj *Object, writes ...ledgerWrite) error {
 	oHash, err := ipfsSave(ctx, ls.dag, obj)
 	if err != nil {
 		return err
 	}
-	return ls.putObjectHash(ctx, bucket, object, oHash)
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if err := ls.assertNotRetained(ctx, b.Bucket, ls.clock.Now(), object); err != nil {
+		return err
+	}
+	// reference the new data before the bucket points to it, so it is never collected while in use
+	if err := ls.addDataRef(obj.GetDataHash()); err != nil {
+		return err
+	}
+	restore := ls.keepObjectEntry(b.Bucket, object)
+	replacedDataHashes, err := ls.retireObject(ctx, bucket, b.Bucket, object, ls.clock.Now())
+	if err == nil {
+		// the new object and the retired version are written in a single bucket save
+		err = ls.putObjectHash(ctx, bucket, object, oHash, append(writes, ls.objectWrites(bucket, object, oHash, obj))...)
+	}
+	if err != nil {
+		restore()
+		_, _ = ls.removeDataRef(obj.GetDataHash()) // the failed save is more relevant
+		return err
+	}
+	ls.events.publish(newObjectEvent(eventObjectCreated, bucket, object, &obj.ObjectInfo, ls.clock.Now()))
+	_, err = ls.releaseData(replacedDataHashes)
+	return err
 }
 
-// putObjectHash saves an object by hash into the given bucket
-func (ls *ledgerStore) putObjectHash(ctx context.Context, bucket, object, objHash string) error {
+// putObjectHash saves an object by hash into the given bucket, together with the given writes
+func (ls *ledgerStore) putObjectHash(ctx context.Context, bucket, object, objHash string, writes ...ledgerWrite) error {
 	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
 		return err
 	}
-	if b.Bucket.Objects == nil {
-		b.Bucket.Objects = make(map[string]string)
-	}
-	b.Bucket.Objects[object] = objHash
-	_, err = ls.saveBucket(ctx, bucket, b.Bucket)
+	ls.setObject(b.Bucket, object, objHash)
+	_, err = ls.saveBucket(ctx, bucket, b.Bucket, writes...)
 	return err
 }
@@ -0,0 +1,154 @@
+package s3x
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+When a bucket has a trash retention configured, deleted objects are moved from Bucket.Objects to Bucket.Trash.
+Trashed objects keep their data referenced until they are purged, either because their retention expired,
+or because a newer object with the same name was deleted.
+*/
+
+// trashObject moves an object hash into the bucket trash, and returns the data hash of the trash entry it replaced if any
+func (ls *ledgerStore) trashObject(ctx context.Context, b *Bucket, object, objHash string) (string, error) {
+	if b.Trash == nil {
+		b.Trash = make(map[string]DeletedObject)
+	}
+	var replaced string
+	if old, ok := b.Trash[object]; ok {
+		obj, err := ipfsObject(ctx, ls.dag, old.ObjectHash)
+		if err != nil {
+			return "", err
+		}
+		replaced = obj.GetDataHash()
+	}
+	b.Trash[object] = DeletedObject{
+		ObjectHash: objHash,
+		Deleted:    ls.clock.Now().UTC(),
+	}
+	return replaced, nil
+}
+
+// ListTrash returns the deleted objects of a bucket with the given prefix ordered by name, and the trash retention
+func (ls *ledgerStore) ListTrash(ctx context.Context, bucket, prefix string) ([]string, map[string]DeletedObject, time.Duration, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var names []string
+	entries := make(map[string]DeletedObject)
+	for name, d := range b.Bucket.Trash {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+			entries[name] = d
+		}
+	}
+	sort.Strings(names)
+	return names, entries, trashRetention(b.Bucket), nil
+}
+
+// RestoreObject moves an object out of the bucket trash,
+// an existing object with the same name is only replaced if overwrite is set.
+func (ls *ledgerStore) RestoreObject(ctx context.Context, bucket, object string, overwrite bool) (string, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+	d, ok := b.Bucket.Trash[object]
+	if !ok {
+		return "", ErrLedgerObjectDoesNotExist
+	}
+	if _, ok := b.Bucket.Objects[object]; ok && !overwrite {
+		return "", ErrLedgerObjectExists
+	}
+	if err := ls.assertNotRetained(ctx, b.Bucket, ls.clock.Now(), object); err != nil {
+		return "", err
+	}
+	obj, err := ipfsObject(ctx, ls.dag, d.ObjectHash)
+	if err != nil {
+		return "", err
+	}
+	replacedDataHashes, err := ls.retireObject(ctx, bucket, b.Bucket, object, ls.clock.Now())
+	if err != nil {
+		return "", err
+	}
+	ls.setObject(b.Bucket, object, d.ObjectHash)
+	delete(b.Bucket.Trash, object)
+	if _, err := ls.saveBucket(ctx, bucket, b.Bucket, ls.objectWrites(bucket, object, d.ObjectHash, obj)); err != nil {
+		return "", err
+	}
+	ls.events.publish(newObjectEvent(eventObjectCreated, bucket, object, &obj.ObjectInfo, ls.clock.Now()))
+	if _, err := ls.releaseData(replacedDataHashes); err != nil {
+		return "", err
+	}
+	return d.ObjectHash, nil
+}
+
+// PurgeTrash permanently removes trashed objects that were deleted before their bucket's retention ended at now,
+// it returns the number of purged objects.
+func (ls *ledgerStore) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	names, err := ls.GetBucketNames()
+	if err != nil {
+		return 0, err
+	}
+	var purged int
+	for _, bucket := range names {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		n, err := ls.purgeBucketTrash(ctx, bucket, now)
+		purged += n
+		if err != nil && err != ErrLedgerBucketDoesNotExist { // the bucket might be deleted concurrently
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+func (ls *ledgerStore) purgeBucketTrash(ctx context.Context, bucket string, now time.Time) (int, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return 0, err
+	}
+	retention := trashRetention(b.Bucket)
+	var dataHashes []string
+	for name, d := range b.Bucket.Trash {
+		// entries are kept while the trash is disabled, so they are not lost by toggling the setting
+		if retention == 0 || d.Deleted.Add(retention).After(now) {
+			continue
+		}
+		obj, err := ipfsObject(ctx, ls.dag, d.ObjectHash)
+		if err != nil {
+			return 0, err
+		}
+		dataHashes = append(dataHashes, obj.GetDataHash())
+		delete(b.Bucket.Trash, name)
+	}
+	if len(dataHashes) == 0 {
+		return 0, nil
+	}
+	if _, err := ls.saveBucket(ctx, bucket, b.Bucket); err != nil {
+		return 0, err
+	}
+	for _, h := range dataHashes {
+		if _, err := ls.removeDataRef(h); err != nil {
+			return 0, err
+		}
+	}
+	return len(dataHashes), nil
+	//todo: gc on ipfs
+}
+
+// trashRetention returns how long deleted objects are kept in the trash of the bucket
+func trashRetention(b *Bucket) time.Duration {
+	return time.Duration(b.GetConfig().GetTrashRetentionDays()) * 24 * time.Hour
+}
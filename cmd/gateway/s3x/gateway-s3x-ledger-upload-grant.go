@@ -0,0 +1,100 @@
+package s3x
+
+import (
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// dsUploadGrantKey maps upload grant ids to their UploadGrant
+var dsUploadGrantKey = datastore.NewKey("u")
+
+// uploadGrantKey returns the key of an upload grant
+func uploadGrantKey(id string) datastore.Key {
+	return dsUploadGrantKey.ChildString(id)
+}
+
+// PutUploadGrant saves an upload grant
+func (ls *ledgerStore) PutUploadGrant(g *UploadGrant) error {
+	data, err := ls.names.marshalUploadGrant(g)
+	if err != nil {
+		return err
+	}
+	return ls.ds.Put(uploadGrantKey(g.GetId()), data)
+}
+
+// GetUploadGrant returns an upload grant
+func (ls *ledgerStore) GetUploadGrant(id string) (*UploadGrant, error) {
+	data, err := ls.ds.Get(uploadGrantKey(id))
+	if err == datastore.ErrNotFound {
+		return nil, ErrUploadGrantDoesNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ls.names.unmarshalUploadGrant(data)
+}
+
+// ClaimUploadGrant marks an upload grant as used for object at now, and returns it, or returns ErrUploadGrantUsed
+// if it was already used
+func (ls *ledgerStore) ClaimUploadGrant(id, object string, now time.Time) (*UploadGrant, error) {
+	defer ls.plocker.write(id)()
+	g, err := ls.GetUploadGrant(id)
+	if err != nil {
+		return nil, err
+	}
+	if g.GetUsed() != 0 {
+		return nil, ErrUploadGrantUsed
+	}
+	g.Object, g.Used = object, now.Unix()
+	return g, ls.PutUploadGrant(g)
+}
+
+// ReleaseUploadGrant marks a claimed upload grant as unused again, after its upload failed
+func (ls *ledgerStore) ReleaseUploadGrant(id string) error {
+	defer ls.plocker.write(id)()
+	g, err := ls.GetUploadGrant(id)
+	if err != nil {
+		return err
+	}
+	g.Object, g.Used = "", 0
+	return ls.PutUploadGrant(g)
+}
+
+// RevokeUploadGrant deletes an upload grant that was not used, and returns it
+func (ls *ledgerStore) RevokeUploadGrant(id string) (*UploadGrant, error) {
+	defer ls.plocker.write(id)()
+	g, err := ls.GetUploadGrant(id)
+	if err != nil {
+		return nil, err
+	}
+	if g.GetUsed() != 0 {
+		return nil, ErrUploadGrantUsed
+	}
+	return g, ls.ds.Delete(uploadGrantKey(id))
+}
+
+// marshalUploadGrant returns the record of an upload grant, with its prefix and object name encrypted if its bucket
+// has a name key
+func (n *objectNames) marshalUploadGrant(g *UploadGrant) ([]byte, error) {
+	record := *g
+	record.Prefix = n.encodeRecord(g.GetBucket(), g.GetPrefix())
+	record.Object = n.encodeRecord(g.GetBucket(), g.GetObject())
+	return record.Marshal()
+}
+
+// unmarshalUploadGrant returns the upload grant of a record, see marshalUploadGrant
+func (n *objectNames) unmarshalUploadGrant(data []byte) (*UploadGrant, error) {
+	g := &UploadGrant{}
+	if err := g.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	var err error
+	if g.Prefix, err = n.decodeRecord(g.GetBucket(), g.GetPrefix()); err != nil {
+		return nil, err
+	}
+	if g.Object, err = n.decodeRecord(g.GetBucket(), g.GetObject()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
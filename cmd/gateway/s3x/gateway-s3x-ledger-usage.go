@@ -0,0 +1,58 @@
+package s3x
+
+import (
+	"context"
+)
+
+/* Design Notes
+---------------
+
+Trashed objects and noncurrent versions keep their data stored on TemporalX until they are purged, but the
+object index only holds the current objects of a bucket, so their sizes are read from their objects in IPFS.
+The object hashes are copied under the bucket lock, and decoded without it, since objects are immutable.
+*/
+
+// retainedUsage is the number and size of the objects a bucket keeps after they were deleted or replaced
+type retainedUsage struct {
+	trashed, trashedBytes  int64 // objects in the trash
+	versions, versionBytes int64 // noncurrent versions
+}
+
+// RetainedUsage returns the number and size of the trashed objects and noncurrent versions of a bucket
+func (ls *ledgerStore) RetainedUsage(ctx context.Context, bucket string) (retainedUsage, error) {
+	unlock := ls.locker.read(bucket)
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		unlock()
+		return retainedUsage{}, err
+	}
+	trashed := make([]string, 0, len(b.Bucket.Trash))
+	for _, d := range b.Bucket.Trash {
+		trashed = append(trashed, d.ObjectHash)
+	}
+	var versions []string
+	for _, v := range b.Bucket.Versions {
+		for _, version := range v.Versions {
+			versions = append(versions, version.ObjectHash)
+		}
+	}
+	unlock()
+	var u retainedUsage
+	for _, h := range trashed {
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return retainedUsage{}, err
+		}
+		u.trashed++
+		u.trashedBytes += obj.ObjectInfo.Size_
+	}
+	for _, h := range versions {
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return retainedUsage{}, err
+		}
+		u.versions++
+		u.versionBytes += obj.ObjectInfo.Size_
+	}
+	return u, nil
+}
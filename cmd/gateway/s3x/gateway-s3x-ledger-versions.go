@@ -0,0 +1,274 @@
+package s3x
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+/* Design Notes
+---------------
+
+When versioning is enabled for a bucket, objects that are replaced or deleted are not released,
+instead their object hashes are appended to the noncurrent versions of their name in Bucket.Versions,
+and their data stays referenced until the version is pruned.
+
+Versions are pruned when a new version of an object exceeds the keepVersions of the bucket, and periodically
+when they are older than keepDays, in both cases the data hashes that are no longer referenced are returned.
+Versioning takes precedence over the trash, deleted objects of versioned buckets are not trashed.
+*/
+
+// versioningEnabled returns true if replaced and deleted objects of b are kept as versions
+func versioningEnabled(b *Bucket) bool {
+	return b.GetConfig().GetVersioning().GetEnabled()
+}
+
+// addObjectVersion appends the current object of a name to its noncurrent versions, the caller saves the bucket
+func addObjectVersion(b *Bucket, object string, now time.Time) {
+	h, ok := b.Objects[object]
+	if !ok {
+		return
+	}
+	if b.Versions == nil {
+		b.Versions = make(map[string]ObjectVersions)
+	}
+	v := b.Versions[object]
+	v.Versions = append(v.Versions, ObjectVersion{
+		VersionId:  ksuid.New().String(),
+		ObjectHash: h,
+		Noncurrent: now.UTC(),
+	})
+	b.Versions[object] = v
+}
+
+// pruneObjectVersions removes the noncurrent versions of an object beyond the retention of the bucket at now,
+// and returns the object hashes of the removed versions, the caller saves the bucket.
+func pruneObjectVersions(b *Bucket, object string, now time.Time) []string {
+	config := b.GetConfig().GetVersioning()
+	v, ok := b.Versions[object]
+	if !ok {
+		return nil
+	}
+	var pruned []string
+	keep := v.Versions[:0]
+	for i, version := range v.Versions {
+		tooMany := config.GetKeepVersions() > 0 && int64(len(v.Versions)-i) > config.GetKeepVersions()
+		tooOld := config.GetKeepDays() > 0 && version.Noncurrent.Add(time.Duration(config.GetKeepDays())*24*time.Hour).Before(now)
+		if tooMany || tooOld {
+			pruned = append(pruned, version.ObjectHash)
+			continue
+		}
+		keep = append(keep, version)
+	}
+	if len(keep) == 0 {
+		delete(b.Versions, object)
+	} else {
+		b.Versions[object] = ObjectVersions{Versions: keep}
+	}
+	return pruned
+}
+
+// versionDataHashes returns the data hashes of the objects of versions
+func (ls *ledgerStore) versionDataHashes(ctx context.Context, objectHashes []string) ([]string, error) {
+	dataHashes := make([]string, 0, len(objectHashes))
+	for _, h := range objectHashes {
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return nil, err
+		}
+		dataHashes = append(dataHashes, obj.GetDataHash())
+	}
+	return dataHashes, nil
+}
+
+// retireObject prepares replacing or removing the current object of a name before the bucket is saved,
+// and returns the data hashes to release once it is saved: if versioning is enabled the object becomes
+// a noncurrent version and the data of versions beyond the retention is returned, otherwise the data of the object.
+func (ls *ledgerStore) retireObject(ctx context.Context, bucket string, b *Bucket, object string, now time.Time) ([]string, error) {
+	if !versioningEnabled(b) {
+		h, err := ls.objectDataHashNilable(ctx, bucket, object)
+		if err != nil || h == "" {
+			return nil, err
+		}
+		return []string{h}, nil
+	}
+	addObjectVersion(b, object, now)
+	return ls.versionDataHashes(ctx, pruneObjectVersions(b, object, now))
+}
+
+// releaseData releases a reference of each data hash, and returns the data hashes that are no longer referenced
+func (ls *ledgerStore) releaseData(dataHashes []string) ([]string, error) {
+	var unreferenced []string
+	for _, h := range dataHashes {
+		n, err := ls.removeDataRef(h)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 && h != "" {
+			unreferenced = append(unreferenced, h)
+		}
+	}
+	return unreferenced, nil
+}
+
+// ListObjectVersions returns the noncurrent versions of the objects of a bucket with the given prefix by object name
+func (ls *ledgerStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]string, map[string]ObjectVersions, error) {
+	defer ls.locker.read(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	versions := make(map[string]ObjectVersions)
+	for name, v := range b.Bucket.Versions {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+			versions[name] = v
+		}
+	}
+	sort.Strings(names)
+	return names, versions, nil
+}
+
+// RestoreObjectVersion makes a noncurrent version the current object, the replaced current object becomes a version
+func (ls *ledgerStore) RestoreObjectVersion(ctx context.Context, bucket, object, versionID string) (string, []string, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := ls.assertNotRetained(ctx, b.Bucket, ls.clock.Now(), object); err != nil {
+		return "", nil, err
+	}
+	v := b.Bucket.Versions[object]
+	i := -1
+	for j, version := range v.Versions {
+		if version.VersionId == versionID {
+			i = j
+		}
+	}
+	if i < 0 {
+		return "", nil, ErrLedgerVersionDoesNotExist
+	}
+	restored := v.Versions[i].ObjectHash
+	obj, err := ipfsObject(ctx, ls.dag, restored)
+	if err != nil {
+		return "", nil, err
+	}
+	v.Versions = append(v.Versions[:i:i], v.Versions[i+1:]...)
+	b.Bucket.Versions[object] = v
+	if len(v.Versions) == 0 {
+		delete(b.Bucket.Versions, object)
+	}
+	dataHashes, err := ls.retireObject(ctx, bucket, b.Bucket, object, ls.clock.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	ls.setObject(b.Bucket, object, restored)
+	if _, err := ls.saveBucket(ctx, bucket, b.Bucket, ls.objectWrites(bucket, object, restored, obj)); err != nil {
+		return "", nil, err
+	}
+	ls.events.publish(newObjectEvent(eventObjectCreated, bucket, object, &obj.ObjectInfo, ls.clock.Now()))
+	unreferenced, err := ls.releaseData(dataHashes)
+	if err != nil {
+		return "", nil, err
+	}
+	return restored, unreferenced, nil
+}
+
+// RemoveObjectVersions permanently removes noncurrent versions, given by object names and their version ids,
+// and returns an error for each version that did not exist and the data hashes that are no longer referenced.
+func (ls *ledgerStore) RemoveObjectVersions(ctx context.Context, bucket string, objects, versionIDs []string) ([]error, []string, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs := make([]error, len(objects))
+	var removed []string
+	for i, object := range objects {
+		v := b.Bucket.Versions[object]
+		j := -1
+		for k, version := range v.Versions {
+			if version.VersionId == versionIDs[i] {
+				j = k
+			}
+		}
+		if j < 0 {
+			errs[i] = ErrLedgerVersionDoesNotExist
+			continue
+		}
+		removed = append(removed, v.Versions[j].ObjectHash)
+		v.Versions = append(v.Versions[:j:j], v.Versions[j+1:]...)
+		if len(v.Versions) == 0 {
+			delete(b.Bucket.Versions, object)
+		} else {
+			b.Bucket.Versions[object] = v
+		}
+	}
+	if len(removed) == 0 {
+		return errs, nil, nil
+	}
+	dataHashes, err := ls.versionDataHashes(ctx, removed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := ls.saveBucket(ctx, bucket, b.Bucket); err != nil {
+		return nil, nil, err
+	}
+	unreferenced, err := ls.releaseData(dataHashes)
+	return errs, unreferenced, err
+}
+
+// PruneVersions removes the noncurrent versions of a bucket beyond its retention at now,
+// and returns the number of pruned versions and the data hashes that are no longer referenced.
+func (ls *ledgerStore) PruneVersions(ctx context.Context, bucket string, now time.Time) (int, []string, error) {
+	defer ls.locker.write(bucket)()
+	b, err := ls.getBucketLoaded(ctx, bucket)
+	if err != nil {
+		return 0, nil, err
+	}
+	var pruned []string
+	for name := range b.Bucket.Versions {
+		pruned = append(pruned, pruneObjectVersions(b.Bucket, name, now)...)
+	}
+	if len(pruned) == 0 {
+		return 0, nil, nil
+	}
+	dataHashes, err := ls.versionDataHashes(ctx, pruned)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := ls.saveBucket(ctx, bucket, b.Bucket); err != nil {
+		return 0, nil, err
+	}
+	unreferenced, err := ls.releaseData(dataHashes)
+	return len(pruned), unreferenced, err
+}
+
+// PruneAllVersions prunes the noncurrent versions of all buckets, see PruneVersions
+func (ls *ledgerStore) PruneAllVersions(ctx context.Context, now time.Time) (int, []string, error) {
+	names, err := ls.GetBucketNames()
+	if err != nil {
+		return 0, nil, err
+	}
+	var (
+		pruned       int
+		unreferenced []string
+	)
+	for _, bucket := range names {
+		if err := ctx.Err(); err != nil {
+			return pruned, unreferenced, err
+		}
+		n, hashes, err := ls.PruneVersions(ctx, bucket, now)
+		pruned += n
+		unreferenced = append(unreferenced, hashes...)
+		if err != nil && err != ErrLedgerBucketDoesNotExist { // the bucket might be deleted concurrently
+			return pruned, unreferenced, err
+		}
+	}
+	return pruned, unreferenced, nil
+}
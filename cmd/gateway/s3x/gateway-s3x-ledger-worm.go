@@ -0,0 +1,71 @@
+package s3x
+
+import (
+	"context"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+Object lock retention is set per object by clients, and governance retention can be bypassed with the right
+permissions. Buckets in WORM mode instead retain every object for the WORM retention of the bucket after it was
+written, and the ledger itself rejects overwriting, renaming, and deleting retained objects with
+ErrLedgerObjectRetained, whatever the credentials of the request. The checks run in the ledger operations that
+replace or remove current objects, before the in memory bucket is changed, so a rejected request changes nothing:
+
+* putObject, which stores uploads, copies, and completed multipart uploads
+* renameObject, for its source and an overwritten destination
+* removeObjects, for all its objects, also when the bucket has a trash
+* RestoreObject and RestoreObjectVersion, which overwrite the current object
+
+Expiration and bulk deletes skip retained objects like objects under an object lock, and a WORM bucket is only
+deleted when it is empty. The retention is a compliance mode: once set it can only be extended, never shortened or
+disabled. Noncurrent versions and trashed objects are not current objects, they keep their own pruning, so buckets
+that need every version kept should not prune versions.
+*/
+
+// wormRetention returns how long objects of a bucket with the config are retained after they were written,
+// 0 if the bucket is not in WORM mode
+func wormRetention(c *BucketConfig) time.Duration {
+	return time.Duration(c.GetWormRetentionDays()) * 24 * time.Hour
+}
+
+// wormRetained returns true if an object written at modTime is retained at now by a WORM retention
+func wormRetained(modTime time.Time, retention time.Duration, now time.Time) bool {
+	return retention > 0 && modTime.Add(retention).After(now)
+}
+
+// assertNotRetained returns ErrLedgerObjectRetained if any of the current objects of the names is retained by the
+// WORM retention of the bucket at now, the caller holds the write lock of the bucket
+func (ls *ledgerStore) assertNotRetained(ctx context.Context, b *Bucket, now time.Time, objects ...string) error {
+	retention := wormRetention(b.GetConfig())
+	if retention == 0 {
+		return nil
+	}
+	for _, object := range objects {
+		h, ok := b.GetObjects()[object]
+		if !ok {
+			continue
+		}
+		obj, err := ipfsObject(ctx, ls.dag, h)
+		if err != nil {
+			return err
+		}
+		if wormRetained(obj.ObjectInfo.ModTime, retention, now) {
+			return ErrLedgerObjectRetained
+		}
+	}
+	return nil
+}
+
+// SetWORMRetention sets the WORM retention of a bucket to days, which can not be less than its current retention
+func (ls *ledgerStore) SetWORMRetention(ctx context.Context, bucket string, days int64) error {
+	return ls.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		if days < c.GetWormRetentionDays() {
+			return ErrWORMRetentionShortened
+		}
+		c.WormRetentionDays = days
+		return nil
+	})
+}
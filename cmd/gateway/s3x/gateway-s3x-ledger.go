@@ -2,8 +2,6 @@ package s3x
 
 import (
 	"context"
-	"sort"
-	"strings"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
@@ -31,33 +29,34 @@ func (ls *ledgerStore) Close() error {
 // GETTER FUNCTINS //
 /////////////////////
 
-// GetObjectInfos returns a list of ordered ObjectInfos with given prefix ordered by name
-func (ls *ledgerStore) GetObjectInfos(ctx context.Context, bucket, prefix, startsFrom string, max int) ([]ObjectInfo, error) {
+// GetObjectInfos returns up to max ObjectInfos ordered by name, of the objects with given prefix and names after
+// the given name, and true if more objects follow. At most the batch size of the ledger is returned if max is
+// larger or not positive. The ObjectInfos only have the fields kept in listing records.
+func (ls *ledgerStore) GetObjectInfos(ctx context.Context, bucket, prefix, after string, max int) ([]ObjectInfo, bool, error) {
 	defer ls.locker.read(bucket)()
 	b, err := ls.getBucketLoaded(ctx, bucket)
 	if err != nil {
-		return nil, err
-	}
-	var names []string
-	objs := b.GetBucket().GetObjects()
-	for name := range objs {
-		if strings.HasPrefix(name, prefix) && strings.Compare(startsFrom, name) <= 0 {
-			names = append(names, name)
-		}
+		return nil, false, err
 	}
-	sort.Strings(names)
-	if max > 0 && len(names) > max {
-		names = names[:max]
+	return ls.objectInfos(ctx, bucket, b.GetBucket().GetObjects(), prefix, after, max, true)
+}
+
+// objectInfos returns the object infos of the first objects of an object map, the listing records of the objects
+// are replaced if current is set, when objs are the objects of the current bucket
+func (ls *ledgerStore) objectInfos(ctx context.Context, bucket string, objs map[string]string, prefix, after string, max int, current bool) ([]ObjectInfo, bool, error) {
+	if max <= 0 || max > ls.batchSize {
+		max = ls.batchSize
 	}
+	names, truncated := firstNames(objs, prefix, after, max)
 	list := make([]ObjectInfo, 0, len(names))
 	for _, name := range names {
-		obj, err := ls.object(ctx, bucket, name)
+		info, err := ls.listingInfo(ctx, bucket, name, objs[name], current)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		list = append(list, obj.GetObjectInfo())
+		list = append(list, info)
 	}
-	return list, nil
+	return list, truncated, nil
 }
 
 // GetObjectHash is used to retrieve the corresponding IPFS CID for an object
@@ -2,9 +2,12 @@ package s3x
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	pb "github.com/RTradeLtd/TxPB/v3/go"
 	"github.com/ipfs/go-datastore"
+	"google.golang.org/grpc"
 
 	dssync "github.com/ipfs/go-datastore/sync"
 )
@@ -92,3 +95,145 @@ func testS3XLedgerStore(t *testing.T, dsType DSType) {
 		}
 	})
 }
+
+// countingDagClient counts the bytes of the blocks written to IPFS
+type countingDagClient struct {
+	pb.NodeAPIClient
+	written int64
+}
+
+func (c *countingDagClient) Dag(ctx context.Context, req *pb.DagRequest, opts ...grpc.CallOption) (*pb.DagResponse, error) {
+	if req.GetRequestType() == pb.DAGREQTYPE_DAG_PUT {
+		c.written += int64(len(req.GetData()))
+	}
+	return c.NodeAPIClient.Dag(ctx, req, opts...)
+}
+
+// countingDatastore counts the bytes of the values written to a datastore, directly and by batches
+type countingDatastore struct {
+	datastore.Batching
+	written int64
+}
+
+func (d *countingDatastore) Put(key datastore.Key, value []byte) error {
+	d.written += int64(len(value))
+	return d.Batching.Put(key, value)
+}
+
+func (d *countingDatastore) Batch() (datastore.Batch, error) {
+	b, err := d.Batching.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &countingDatastoreBatch{Batch: b, ds: d}, nil
+}
+
+// countingDatastoreBatch counts the bytes of the values written by a batch to its datastore
+type countingDatastoreBatch struct {
+	datastore.Batch
+	ds *countingDatastore
+}
+
+func (b *countingDatastoreBatch) Put(key datastore.Key, value []byte) error {
+	b.ds.written += int64(len(value))
+	return b.Batch.Put(key, value)
+}
+
+// BenchmarkS3X_PutObjectLedger measures the ledger writes of adding objects to a bucket that grows from up to a
+// million objects, on an in memory ledger. Mutations only save the shard of the changed object and the bucket block,
+// so the bytes written per object do not grow with the number of objects of the bucket.
+func BenchmarkS3X_PutObjectLedger(b *testing.B) {
+	for _, objects := range []int{100, 10000, 1000000} {
+		b.Run(fmt.Sprintf("objects=%d", objects), func(b *testing.B) {
+			benchmarkPutObjectLedger(b, objects)
+		})
+	}
+}
+
+func benchmarkPutObjectLedger(b *testing.B, objects int) {
+	ctx := context.Background()
+	ls, err := newLedgerStore(dssync.MutexWrap(datastore.NewMapDatastore()), newMemoryDag())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		b.Fatal(err)
+	}
+	obj := testLedgerObject(testBucket1, "seed", "seed")
+	if err := ls.PutObject(ctx, testBucket1, "seed", obj); err != nil {
+		b.Fatal(err)
+	}
+	objHash, err := ls.GetObjectHash(ctx, testBucket1, "seed")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// the objects are only added to the saved bucket, their object is the seed object
+	lb, err := ls.getBucketLoaded(ctx, testBucket1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i < objects; i++ {
+		ls.setObject(lb.Bucket, fmt.Sprintf("object%08d", i), objHash)
+	}
+	if _, err := ls.saveBucket(ctx, testBucket1, lb.Bucket); err != nil {
+		b.Fatal(err)
+	}
+	dag := &countingDagClient{NodeAPIClient: ls.dag}
+	ds := &countingDatastore{Batching: ls.ds}
+	ls.dag, ls.ds = dag, ds
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ls.PutObject(ctx, testBucket1, fmt.Sprintf("added%08d", i), obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(dag.written)/float64(b.N), "ipfs-bytes/op")
+	b.ReportMetric(float64(ds.written)/float64(b.N), "ds-bytes/op")
+}
+
+func TestS3X_BucketHashDeterministic(t *testing.T) {
+	ctx := context.Background()
+	objects := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	// bucketHash fills a bucket in the given order of objects, and returns the bucket hash after every mutation
+	bucketHash := func(t *testing.T, order []int) []string {
+		ls, _, _ := newFaultyLedger(t)
+		if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+			t.Fatal(err)
+		}
+		hash := func() string {
+			b, err := ls.getBucketLoaded(ctx, testBucket1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b.IpfsHash
+		}
+		hashes := []string{hash()}
+		for _, i := range order {
+			o := objects[i]
+			if err := ls.PutObject(ctx, testBucket1, o, testLedgerObject(testBucket1, o, o)); err != nil {
+				t.Fatal(err)
+			}
+			hashes = append(hashes, hash())
+		}
+		if _, err := ls.RemoveObjects(ctx, testBucket1, objects...); err != nil {
+			t.Fatal(err)
+		}
+		return append(hashes, hash())
+	}
+	forward := bucketHash(t, []int{0, 1, 2, 3, 4, 5, 6, 7})
+	backward := bucketHash(t, []int{7, 6, 5, 4, 3, 2, 1, 0})
+	seen := make(map[string]bool)
+	for _, h := range forward[:len(forward)-1] {
+		if seen[h] {
+			t.Fatalf("expected every mutation to change the bucket hash, but %v repeats", h)
+		}
+		seen[h] = true
+	}
+	if forward[len(forward)-2] != backward[len(backward)-2] {
+		t.Fatalf("expected buckets with the same objects to have the same hash, but got %v and %v", forward[len(forward)-2], backward[len(backward)-2])
+	}
+	if forward[len(forward)-1] != forward[0] {
+		t.Fatalf("expected the hash of the emptied bucket %v to be the hash of the new bucket %v", forward[len(forward)-1], forward[0])
+	}
+}
@@ -0,0 +1,127 @@
+package s3x
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/* Design Notes
+---------------
+
+Upload limits are enforced by the gateway before data is written to the ledger, so a single request
+can not exhaust TemporalX. Declared sizes are rejected before any data is uploaded, and the data of
+requests without a declared size is counted while it is uploaded, so streams that exceed a limit are
+aborted with the same error. A limit of 0 in an uploadLimits value disables that check, while
+newUploadLimits fills in the S3 defaults for unset limits.
+*/
+
+const (
+	// defaultMaxObjectSize is the S3 maximum object size of 5 TiB
+	defaultMaxObjectSize int64 = 5 << 40
+	// defaultMaxPartSize is the S3 maximum multipart part size of 5 GiB
+	defaultMaxPartSize int64 = 5 << 30
+	// defaultMaxPartCount is the S3 maximum number of parts of a multipart upload
+	defaultMaxPartCount = 10000
+	// defaultMaxMetadataSize is the S3 maximum size of user defined metadata of 2 KiB
+	defaultMaxMetadataSize = 2 << 10
+)
+
+// uploadLimits are the size limits of objects, multipart parts, and user defined metadata
+type uploadLimits struct {
+	objectSize   int64
+	partSize     int64
+	partCount    int
+	metadataSize int
+}
+
+// newUploadLimits returns the uploadLimits of the gateway configuration, unset limits are the S3 defaults
+func newUploadLimits(objectSize, partSize int64, partCount, metadataSize int) (uploadLimits, error) {
+	if objectSize < 0 || partSize < 0 || partCount < 0 || metadataSize < 0 {
+		return uploadLimits{}, fmt.Errorf("upload limits must not be negative")
+	}
+	if partCount > defaultMaxPartCount {
+		return uploadLimits{}, fmt.Errorf("max part count must not exceed %v, got %v", defaultMaxPartCount, partCount)
+	}
+	l := uploadLimits{
+		objectSize:   objectSize,
+		partSize:     partSize,
+		partCount:    partCount,
+		metadataSize: metadataSize,
+	}
+	if l.objectSize == 0 {
+		l.objectSize = defaultMaxObjectSize
+	}
+	if l.partSize == 0 {
+		l.partSize = defaultMaxPartSize
+	}
+	if l.partCount == 0 {
+		l.partCount = defaultMaxPartCount
+	}
+	if l.metadataSize == 0 {
+		l.metadataSize = defaultMaxMetadataSize
+	}
+	return l, nil
+}
+
+// checkObjectSize returns ErrObjectTooLarge if size exceeds the maximum object size
+func (l uploadLimits) checkObjectSize(size int64) error {
+	if l.objectSize > 0 && size > l.objectSize {
+		return ErrObjectTooLarge
+	}
+	return nil
+}
+
+// checkPart returns ErrInvalidPartNumber or ErrPartTooLarge if a part is out of range or exceeds the maximum part size
+func (l uploadLimits) checkPart(partID int, size int64) error {
+	if partID < 1 || (l.partCount > 0 && partID > l.partCount) {
+		return ErrInvalidPartNumber
+	}
+	if l.partSize > 0 && size > l.partSize {
+		return ErrPartTooLarge
+	}
+	return nil
+}
+
+// checkPartCount returns ErrInvalidPartNumber if count exceeds the maximum number of parts
+func (l uploadLimits) checkPartCount(count int) error {
+	if l.partCount > 0 && count > l.partCount {
+		return ErrInvalidPartNumber
+	}
+	return nil
+}
+
+// checkMetadata returns ErrMetadataTooLarge if the keys and values of the user defined metadata exceed the maximum size,
+// the x-amz-meta- prefix of keys is not counted
+func (l uploadLimits) checkMetadata(userDefined map[string]string) error {
+	if l.metadataSize <= 0 {
+		return nil
+	}
+	var size int
+	for k, v := range userDefined {
+		if strings.HasPrefix(strings.ToLower(k), userMetadataPrefix) {
+			size += len(k) - len(userMetadataPrefix) + len(v)
+		}
+	}
+	if size > l.metadataSize {
+		return ErrMetadataTooLarge
+	}
+	return nil
+}
+
+// limitReader returns err once more than limit bytes are read from r, a limit of 0 disables the check
+type limitReader struct {
+	r     io.Reader
+	limit int64
+	err   error
+	read  int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.limit > 0 && l.read > l.limit {
+		return n, l.err
+	}
+	return n, err
+}
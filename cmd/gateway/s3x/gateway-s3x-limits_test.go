@@ -0,0 +1,121 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_UploadLimits(t *testing.T) {
+	limits, err := newUploadLimits(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (uploadLimits{defaultMaxObjectSize, defaultMaxPartSize, defaultMaxPartCount, defaultMaxMetadataSize}) {
+		t.Fatalf("expected the default limits, but got %+v", limits)
+	}
+	if _, err := newUploadLimits(-1, 0, 0, 0); err == nil {
+		t.Fatal("expected an error for a negative limit")
+	}
+	if _, err := newUploadLimits(0, 0, defaultMaxPartCount+1, 0); err == nil {
+		t.Fatal("expected an error for a part count above the S3 maximum")
+	}
+	limits = uploadLimits{objectSize: 10, partSize: 5, partCount: 2, metadataSize: 8}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"ObjectSize", limits.checkObjectSize(10), nil},
+		{"UnknownObjectSize", limits.checkObjectSize(-1), nil},
+		{"ObjectTooLarge", limits.checkObjectSize(11), ErrObjectTooLarge},
+		{"Part", limits.checkPart(2, 5), nil},
+		{"PartZero", limits.checkPart(0, 5), ErrInvalidPartNumber},
+		{"PartNumberTooLarge", limits.checkPart(3, 5), ErrInvalidPartNumber},
+		{"PartTooLarge", limits.checkPart(1, 6), ErrPartTooLarge},
+		{"PartCount", limits.checkPartCount(3), ErrInvalidPartNumber},
+		{"Metadata", limits.checkMetadata(map[string]string{"X-Amz-Meta-Key": "value", "Content-Type": "text/plain"}), nil},
+		{"MetadataTooLarge", limits.checkMetadata(map[string]string{"X-Amz-Meta-Key": "values"}), ErrMetadataTooLarge},
+		{"NoLimits", uploadLimits{}.checkObjectSize(1 << 50), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != tt.want {
+				t.Fatalf("expected %v, but got %v", tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestS3X_Limits_Badger(t *testing.T) {
+	testS3XLimits(t, DSTypeBadger)
+}
+func TestS3X_Limits_Crdt(t *testing.T) {
+	testS3XLimits(t, DSTypeCrdt)
+}
+func testS3XLimits(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	gateway.limits = uploadLimits{objectSize: 8, partSize: 4, partCount: 2, metadataSize: 16}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	tooLarge := minio.ObjectTooLarge{Bucket: testBucket1, Object: testObject1}
+	t.Run("PutObject", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("12345678")), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("123456789")), minio.ObjectOptions{}); err != tooLarge {
+			t.Fatal("expected err ObjectTooLarge, but got: ", err)
+		}
+		// the size of streams is only known once they are read
+		r := minio.NewPutObjReader(getTestHashReader(t, bytes.NewReader([]byte("123456789")), -1), nil, nil)
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, r, minio.ObjectOptions{}); err != tooLarge {
+			t.Fatal("expected err ObjectTooLarge, but got: ", err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != 8 {
+			t.Fatalf("expected the rejected uploads not to replace the object, but got size %v", info.Size)
+		}
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{"X-Amz-Meta-Key": strings.Repeat("v", 16)}}
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("data")), opts); err != (minio.MetadataTooLarge{}) {
+			t.Fatal("expected err MetadataTooLarge, but got: ", err)
+		}
+		if _, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, opts); err != (minio.MetadataTooLarge{}) {
+			t.Fatal("expected err MetadataTooLarge, but got: ", err)
+		}
+	})
+	t.Run("Multipart", func(t *testing.T) {
+		uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, 1, getTestPutObjectReader(t, []byte("12345")), minio.ObjectOptions{}); err != (minio.PartTooBig{}) {
+			t.Fatal("expected err PartTooBig, but got: ", err)
+		}
+		r := minio.NewPutObjReader(getTestHashReader(t, bytes.NewReader([]byte("12345")), -1), nil, nil)
+		if _, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, 1, r, minio.ObjectOptions{}); err != (minio.PartTooBig{}) {
+			t.Fatal("expected err PartTooBig, but got: ", err)
+		}
+		if _, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, 3, getTestPutObjectReader(t, []byte("1234")), minio.ObjectOptions{}); err != (minio.InvalidPartNumber{}) {
+			t.Fatal("expected err InvalidPartNumber, but got: ", err)
+		}
+		parts := []minio.CompletePart{{PartNumber: 1}, {PartNumber: 2}, {PartNumber: 3}}
+		if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uploadID, parts, minio.ObjectOptions{}); err != (minio.InvalidPartNumber{}) {
+			t.Fatal("expected err InvalidPartNumber, but got: ", err)
+		}
+	})
+}
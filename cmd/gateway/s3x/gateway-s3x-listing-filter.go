@@ -0,0 +1,86 @@
+package s3x
+
+import minio "github.com/RTradeLtd/s3x/cmd"
+
+/* Design Notes
+---------------
+
+Clients syncing a subset of a large bucket can filter listings by size, modification time, and tags with the
+x-s3x-* query parameters, see minio.ListingFilter. Filtered listings read the same listing records as other
+listings, which keep the object tagging for this, and skip the objects that do not match.
+
+A filtered page scans up to maxFilterScan objects, then ends truncated at the last scanned object, so a page of
+a bucket with few matches can be empty but still truncated, and clients keep following the marker or
+continuation token as they would for unfiltered listings. Unfiltered listings are a single ledger read as before.
+*/
+
+// maxFilterScan is the number of objects a filtered listing page scans at most
+const maxFilterScan = 10000
+
+// listingMatcher returns a function that returns true for objects matching all conditions of f,
+// or nil if f is nil
+func listingMatcher(f *minio.ListingFilter) func(*ObjectInfo) bool {
+	if f == nil {
+		return nil
+	}
+	return func(info *ObjectInfo) bool {
+		if info.Size_ < f.MinSize || (f.MaxSize > 0 && info.Size_ > f.MaxSize) {
+			return false
+		}
+		if !f.ModifiedAfter.IsZero() && !info.ModTime.After(f.ModifiedAfter) {
+			return false
+		}
+		if len(f.Tags) > 0 {
+			tags := objectTags(info)
+			for k, v := range f.Tags {
+				if got, ok := tags[k]; !ok || got != v {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// filterObjectInfos returns up to max objects after the given name for which match returns true, the name of the
+// last scanned object, and true if more objects follow it. list returns up to n objects after a name, all of a
+// ledger batch if n is not positive, and is called until max objects match, maxFilterScan objects were scanned,
+// or no objects follow. If match is nil, list is called once for max objects, and all of them are returned.
+func filterObjectInfos(
+	max int,
+	after string,
+	match func(*ObjectInfo) bool,
+	list func(after string, n int) ([]ObjectInfo, bool, error),
+) ([]ObjectInfo, string, bool, error) {
+	if match == nil {
+		objs, truncated, err := list(after, max)
+		if err != nil {
+			return nil, "", false, err
+		}
+		if len(objs) > 0 {
+			after = objs[len(objs)-1].GetName()
+		}
+		return objs, after, truncated, nil
+	}
+	var matched []ObjectInfo
+	scanned := 0
+	for {
+		objs, truncated, err := list(after, 0)
+		if err != nil {
+			return nil, "", false, err
+		}
+		for i := range objs {
+			after = objs[i].GetName()
+			scanned++
+			if match(&objs[i]) {
+				matched = append(matched, objs[i])
+			}
+			if (max > 0 && len(matched) == max) || scanned == maxFilterScan {
+				return matched, after, truncated || i < len(objs)-1, nil
+			}
+		}
+		if !truncated || len(objs) == 0 {
+			return matched, after, false, nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+func TestFilterObjectInfos(t *testing.T) {
+	var objs []ObjectInfo
+	for i := 0; i < 10; i++ {
+		objs = append(objs, ObjectInfo{Name: fmt.Sprintf("object-%d", i), Size_: int64(i)})
+	}
+	// list returns batches of three objects
+	calls := 0
+	list := func(after string, n int) ([]ObjectInfo, bool, error) {
+		calls++
+		if n <= 0 || n > 3 {
+			n = 3
+		}
+		var page []ObjectInfo
+		for _, o := range objs {
+			if o.Name > after && len(page) < n {
+				page = append(page, o)
+			}
+		}
+		return page, len(page) > 0 && page[len(page)-1].Name != objs[len(objs)-1].Name, nil
+	}
+	even := func(info *ObjectInfo) bool { return info.Size_%2 == 0 }
+
+	got, last, truncated, err := filterObjectInfos(2, "", nil, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || last != "object-1" || !truncated || calls != 1 {
+		t.Fatalf("expected one unfiltered page of two objects, but got %v, %v, %v after %v calls", got, last, truncated, calls)
+	}
+
+	calls = 0
+	got, last, truncated, err = filterObjectInfos(3, "", even, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[2].Name != "object-4" || last != "object-4" || !truncated || calls != 2 {
+		t.Fatalf("expected objects 0, 2 and 4, but got %v, %v, %v after %v calls", got, last, truncated, calls)
+	}
+	got, last, truncated, err = filterObjectInfos(3, last, even, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Name != "object-8" || last != "object-9" || truncated {
+		t.Fatalf("expected objects 6 and 8 on the last page, but got %v, %v, %v", got, last, truncated)
+	}
+
+	none := func(*ObjectInfo) bool { return false }
+	got, last, truncated, err = filterObjectInfos(3, "", none, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 || last != "object-9" || truncated {
+		t.Fatalf("expected no objects, but got %v, %v, %v", got, last, truncated)
+	}
+}
+
+func TestListingMatcher(t *testing.T) {
+	modified := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	info := &ObjectInfo{
+		Size_:       10,
+		ModTime:     modified,
+		UserDefined: map[string]string{xhttp.AmzObjectTagging: "team=storage&tier=hot"},
+	}
+	testCases := []struct {
+		filter *minio.ListingFilter
+		match  bool
+	}{
+		{&minio.ListingFilter{}, true},
+		{&minio.ListingFilter{MinSize: 10, MaxSize: 10}, true},
+		{&minio.ListingFilter{MinSize: 11}, false},
+		{&minio.ListingFilter{MaxSize: 9}, false},
+		{&minio.ListingFilter{ModifiedAfter: modified.Add(-time.Second)}, true},
+		{&minio.ListingFilter{ModifiedAfter: modified}, false},
+		{&minio.ListingFilter{Tags: map[string]string{"team": "storage", "tier": "hot"}}, true},
+		{&minio.ListingFilter{Tags: map[string]string{"team": "compute"}}, false},
+		{&minio.ListingFilter{Tags: map[string]string{"owner": ""}}, false},
+	}
+	for i, testCase := range testCases {
+		if got := listingMatcher(testCase.filter)(info); got != testCase.match {
+			t.Errorf("test %d: expected match %v, but got %v", i+1, testCase.match, got)
+		}
+	}
+	if listingMatcher(nil) != nil {
+		t.Fatal("expected no matcher without a filter")
+	}
+}
+
+func TestS3X_ListingFilter(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(object, data, tagging string) {
+		t.Helper()
+		opts := minio.ObjectOptions{UserDefined: map[string]string{xhttp.AmzObjectTagging: tagging}}
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a", "a", "team=storage")
+	put("b", "bbbb", "team=compute")
+	put("c", "cccc", "team=storage")
+	put("d", "d", "team=storage")
+	put("e", "eeee", "team=storage")
+
+	filtered := minio.WithListingFilter(ctx, &minio.ListingFilter{
+		MinSize: 2,
+		Tags:    map[string]string{"team": "storage"},
+	})
+	loi, err := gateway.ListObjects(filtered, testBucket1, "", "", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loi.Objects) != 1 || loi.Objects[0].Name != "c" || !loi.IsTruncated || loi.NextMarker != "c" {
+		t.Fatalf("expected a truncated page with object c, but got %+v", loi)
+	}
+	if loi, err = gateway.ListObjects(filtered, testBucket1, "", loi.NextMarker, "", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(loi.Objects) != 1 || loi.Objects[0].Name != "e" || loi.IsTruncated {
+		t.Fatalf("expected a last page with object e, but got %+v", loi)
+	}
+
+	v2, err := gateway.ListObjectsV2(filtered, testBucket1, "", "", "", 1, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v2.Objects) != 1 || v2.Objects[0].Name != "c" || !v2.IsTruncated {
+		t.Fatalf("expected a truncated page with object c, but got %+v", v2)
+	}
+	// objects written after the listing session started are not listed
+	put("f", "ffff", "team=storage")
+	if v2, err = gateway.ListObjectsV2(filtered, testBucket1, "", v2.NextContinuationToken, "", 1, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(v2.Objects) != 1 || v2.Objects[0].Name != "e" || v2.IsTruncated {
+		t.Fatalf("expected a last page with object e, but got %+v", v2)
+	}
+}
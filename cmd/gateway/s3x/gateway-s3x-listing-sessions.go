@@ -0,0 +1,57 @@
+package s3x
+
+import (
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+/* Design Notes
+---------------
+
+The continuation token of a ListObjectsV2 page is a signed listing token, which records the bucket hash of the
+first page of the listing, so every page of a listing session lists the bucket as it was when the listing
+started, see GetObjectInfosAt. Tokens are signed like share links, so clients can not list hashes of other
+buckets or make the gateway load arbitrary DAGs.
+
+Sessions end after listingSessionTTL, a listing that continues an older token lists the current bucket after the
+last listed object, and starts a new session, so clients that never finish a listing do not keep reading a bucket
+that changed long ago. Tokens that are not listing tokens are the names of the last listed objects, as returned
+by gateways before listing sessions, and continue on the current bucket. ListObjects has no sessions, since S3
+clients choose its marker, which is the name of an object.
+*/
+
+// listingSessionTTL is how long the pages of a listing list the bucket as it was when the listing started
+const listingSessionTTL = time.Hour
+
+// listingToken is the signed payload of a ListObjectsV2 continuation token
+type listingToken struct {
+	Bucket string `json:"b"`
+	// Hash is the hash of the listed bucket
+	Hash string `json:"h"`
+	// After is the name of the last listed object
+	After string `json:"a"`
+	// Started is when the listing session started, in unix seconds
+	Started int64 `json:"s"`
+}
+
+// signListingToken encodes and signs a listing token
+func (x *xObjects) signListingToken(t listingToken) (string, error) {
+	return signToken(x.listingKey, t)
+}
+
+// parseListingToken returns the listing token of a continuation token of bucket, without a hash if its session
+// ended or the token is an object name
+func (x *xObjects) parseListingToken(bucket, token string) (listingToken, error) {
+	t := listingToken{}
+	if !verifyToken(x.listingKey, token, &t) {
+		return listingToken{After: token}, nil
+	}
+	if t.Bucket != bucket {
+		return t, minio.InvalidContinuationToken{Token: token}
+	}
+	if x.clock.Now().Sub(time.Unix(t.Started, 0)) > listingSessionTTL {
+		t.Hash = ""
+	}
+	return t, nil
+}
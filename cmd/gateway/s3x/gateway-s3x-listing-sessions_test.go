@@ -0,0 +1,117 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_ListingSessions(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(object, data string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, object := range []string{"a", "b", "c", "d", "e"} {
+		put(object, object)
+	}
+	// list returns the names and sizes of a page of two objects
+	list := func(token, startAfter string) ([]string, []int64, minio.ListObjectsV2Info) {
+		t.Helper()
+		loi, err := gateway.ListObjectsV2(ctx, testBucket1, "", token, "", 2, false, startAfter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		var sizes []int64
+		for _, o := range loi.Objects {
+			names = append(names, o.Name)
+			sizes = append(sizes, o.Size)
+		}
+		return names, sizes, loi
+	}
+	expectNames := func(got []string, expected ...string) {
+		t.Helper()
+		if len(got) != len(expected) {
+			t.Fatalf("expected %v, but got %v", expected, got)
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Fatalf("expected %v, but got %v", expected, got)
+			}
+		}
+	}
+	names, _, first := list("", "")
+	expectNames(names, "a", "b")
+	// the bucket changes while the listing is paginated
+	if err := gateway.DeleteObject(ctx, testBucket1, "c"); err != nil {
+		t.Fatal(err)
+	}
+	put("bb", "bb")
+	put("d", "overwritten")
+	put("f", "f")
+	t.Run("Snapshot", func(t *testing.T) {
+		names, sizes, loi := list(first.NextContinuationToken, "")
+		expectNames(names, "c", "d")
+		if sizes[1] != 1 {
+			t.Fatalf("expected the size of d when the listing started, but got %v", sizes[1])
+		}
+		names, _, loi = list(loi.NextContinuationToken, "")
+		expectNames(names, "e")
+		if loi.IsTruncated {
+			t.Fatal("expected the last page of the listing")
+		}
+		// the listings of the snapshot do not replace the listing records of the current objects
+		data, err := gateway.ledgerStore.ds.Get(gateway.ledgerStore.listingObjectKey(testBucket1, "d"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &ListingRecord{}
+		if err := r.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if r.GetSize_() != int64(len("overwritten")) {
+			t.Fatalf("expected the listing record of the current d, but got %v", r)
+		}
+	})
+	t.Run("Current", func(t *testing.T) {
+		names, _, loi := list("", "b")
+		expectNames(names, "bb", "d")
+		names, sizes, _ := list(loi.NextContinuationToken, "")
+		expectNames(names, "e", "f")
+		if sizes[0] != 1 {
+			t.Fatalf("expected the size of e, but got %v", sizes[0])
+		}
+	})
+	t.Run("Expired", func(t *testing.T) {
+		gateway.clock = fixedClock(time.Now().Add(listingSessionTTL + time.Minute))
+		defer func() { gateway.clock = systemClock{} }()
+		names, _, _ := list(first.NextContinuationToken, "")
+		expectNames(names, "bb", "d")
+	})
+	t.Run("Object-Name", func(t *testing.T) {
+		names, _, _ := list("bb", "")
+		expectNames(names, "d", "e")
+	})
+	t.Run("Other-Bucket", func(t *testing.T) {
+		_, err := gateway.ListObjectsV2(ctx, testBucket2, "", first.NextContinuationToken, "", 2, false, "")
+		if _, ok := err.(minio.InvalidContinuationToken); !ok {
+			t.Fatalf("expected InvalidContinuationToken, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+)
+
+/* Design Notes
+---------------
+
+Operators of a shared gateway bill tenants by usage, so requests, and the bytes they transfer, are counted
+per bucket and access key of the S3 request, and flushed as usage records to a sink at an interval.
+Requests are classed like S3 pricing: deletes and aborts are free, reads (GET, HEAD, SELECT) are class B,
+and everything else, such as writes and listings, is class A.
+
+Only requests made through the S3 api are metered, every layer method called by a request counts it,
+so the request info is tagged when counted to not count it twice. Storage is sampled at each flush,
+the logical size of each bucket times the flush period in hours, and is recorded without an access key,
+as are downloads through share links.
+
+Records that fail to be written to the sink are kept, and written with the next flush.
+*/
+
+const (
+	// usageMeteredTag tags the request info of requests that were already counted
+	usageMeteredTag = "usage-metered"
+	// usageFlushTimeout limits the flush of usage records when the gateway shuts down
+	usageFlushTimeout = 30 * time.Second
+)
+
+// usageRecord is the usage of a bucket by an access key during a period
+type usageRecord struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	Bucket           string    `json:"bucket"`
+	AccessKey        string    `json:"accessKey,omitempty"`
+	StorageByteHours float64   `json:"storageByteHours,omitempty"`
+	ClassARequests   int64     `json:"classARequests,omitempty"`
+	ClassBRequests   int64     `json:"classBRequests,omitempty"`
+	FreeRequests     int64     `json:"freeRequests,omitempty"`
+	IngressBytes     int64     `json:"ingressBytes,omitempty"`
+	EgressBytes      int64     `json:"egressBytes,omitempty"`
+}
+
+type usageKey struct {
+	bucket    string
+	accessKey string
+}
+
+// usageMeter counts the usage of buckets by access keys, a nil meter counts nothing
+type usageMeter struct {
+	mu      sync.Mutex
+	start   time.Time
+	usage   map[usageKey]*usageRecord
+	pending []usageRecord
+}
+
+func newUsageMeter(start time.Time) *usageMeter {
+	return &usageMeter{
+		start: start,
+		usage: make(map[usageKey]*usageRecord),
+	}
+}
+
+// record returns the usage record of a bucket and access key, the caller must hold m.mu
+func (m *usageMeter) record(bucket, accessKey string) *usageRecord {
+	k := usageKey{bucket: bucket, accessKey: accessKey}
+	r, ok := m.usage[k]
+	if !ok {
+		r = &usageRecord{Bucket: bucket, AccessKey: accessKey}
+		m.usage[k] = r
+	}
+	return r
+}
+
+// requestClass returns the pricing class of an S3 api: "a", "b", or "" for free requests
+func requestClass(api string) string {
+	switch {
+	case strings.HasPrefix(api, "Delete"), strings.HasPrefix(api, "Abort"):
+		return ""
+	case strings.HasPrefix(api, "Get"), strings.HasPrefix(api, "Head"),
+		strings.HasPrefix(api, "Select"), strings.HasPrefix(api, "WebDownload"):
+		return "b"
+	default:
+		return "a"
+	}
+}
+
+// request counts the S3 request of ctx once, requests not made through the S3 api are not counted
+func (m *usageMeter) request(ctx context.Context) {
+	info := logger.GetReqInfo(ctx)
+	if m == nil || info == nil || info.API == "" {
+		return
+	}
+	for _, kv := range info.GetTags() {
+		if kv.Key == usageMeteredTag {
+			return
+		}
+	}
+	info.SetTags(usageMeteredTag, "true")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.record(info.BucketName, info.AccessKey)
+	switch requestClass(info.API) {
+	case "a":
+		r.ClassARequests++
+	case "b":
+		r.ClassBRequests++
+	default:
+		r.FreeRequests++
+	}
+}
+
+// transfer counts bytes uploaded to, and downloaded from a bucket by the S3 request of ctx,
+// downloads without S3 request info are counted without an access key.
+func (m *usageMeter) transfer(ctx context.Context, bucket string, ingress, egress int64) {
+	if m == nil || (ingress == 0 && egress == 0) {
+		return
+	}
+	info := logger.GetReqInfo(ctx)
+	if info == nil && ingress != 0 {
+		return
+	}
+	var accessKey string
+	if info != nil {
+		accessKey = info.AccessKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.record(bucket, accessKey)
+	r.IngressBytes += ingress
+	r.EgressBytes += egress
+}
+
+// collect returns the pending records and the usage since the last collect, and starts a new period at end,
+// storage is the size in bytes of each bucket.
+func (m *usageMeter) collect(end time.Time, storage map[string]int64) []usageRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hours := end.Sub(m.start).Hours()
+	for bucket, size := range storage {
+		if size > 0 {
+			m.record(bucket, "").StorageByteHours = float64(size) * hours
+		}
+	}
+	records := m.pending
+	for _, r := range m.usage {
+		r.Start, r.End = m.start, end
+		records = append(records, *r)
+	}
+	m.start = end
+	m.usage = make(map[usageKey]*usageRecord)
+	m.pending = nil
+	return records
+}
+
+// retry keeps records that could not be written, to be written with the next flush
+func (m *usageMeter) retry(records []usageRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(records, m.pending...)
+}
+
+// countingResponseWriter counts the bytes of the response body
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// usageSink writes usage records
+type usageSink interface {
+	write(ctx context.Context, records []usageRecord) error
+}
+
+// newUsageSink returns the sink of a metering.sink flag value: file:<path>, an http(s) webhook url,
+// or bucket://<bucket>/<prefix>.
+func (x *xObjects) newUsageSink(sink string) (usageSink, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		if path == "" {
+			return nil, errors.New("usage sink file path is empty")
+		}
+		return &fileUsageSink{path: path}, nil
+	case "http", "https":
+		return &webhookUsageSink{url: sink, client: &http.Client{Timeout: usageFlushTimeout}}, nil
+	case "bucket":
+		if u.Host == "" {
+			return nil, errors.New("usage sink bucket name is empty")
+		}
+		return &bucketUsageSink{x: x, bucket: u.Host, prefix: strings.TrimPrefix(u.Path, "/")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported usage sink %q", sink)
+	}
+}
+
+// encodeUsageRecords encodes records as json lines
+func encodeUsageRecords(records []usageRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// fileUsageSink appends records to a file as json lines
+type fileUsageSink struct {
+	path string
+}
+
+func (s *fileUsageSink) write(ctx context.Context, records []usageRecord) error {
+	data, err := encodeUsageRecords(records)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// webhookUsageSink posts records to a url as a json array
+type webhookUsageSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookUsageSink) write(ctx context.Context, records []usageRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("usage webhook responded with status %v", resp.Status)
+	}
+	return nil
+}
+
+// bucketUsageSink stores each flush of records as a json lines object in a bucket of the gateway
+type bucketUsageSink struct {
+	x      *xObjects
+	bucket string
+	prefix string
+}
+
+func (s *bucketUsageSink) write(ctx context.Context, records []usageRecord) error {
+	data, err := encodeUsageRecords(records)
+	if err != nil {
+		return err
+	}
+	r, err := hash.NewReader(bytes.NewReader(data), int64(len(data)), "", "", int64(len(data)), false)
+	if err != nil {
+		return err
+	}
+	object := s.prefix + "usage-" + time.Now().UTC().Format("20060102T150405.000000000Z") + ".jsonl"
+	_, err = s.x.PutObject(ctx, s.bucket, object, minio.NewPutObjReader(r, nil, nil), minio.ObjectOptions{
+		UserDefined: map[string]string{"content-type": "application/x-ndjson"},
+	})
+	return err
+}
+
+// flushUsage writes the usage since the last flush to sink
+func (x *xObjects) flushUsage(ctx context.Context, sink usageSink, now time.Time) error {
+	storage, err := x.storedBytes(ctx)
+	if err != nil {
+		return err
+	}
+	records := x.meter.collect(now, storage)
+	if len(records) == 0 {
+		return nil
+	}
+	if err := sink.write(ctx, records); err != nil {
+		x.meter.retry(records)
+		return err
+	}
+	return nil
+}
+
+// meteringLoop flushes usage records to sink at every interval, and when the gateway shuts down
+func (x *xObjects) meteringLoop(sink usageSink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), usageFlushTimeout)
+			if err := x.flushUsage(ctx, sink, x.clock.Now().UTC()); err != nil {
+				log.Printf("failed to flush usage records: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := x.flushUsage(x.ctx, sink, x.clock.Now().UTC()); err != nil {
+				log.Printf("failed to flush usage records: %v", err)
+			}
+		}
+	}
+}
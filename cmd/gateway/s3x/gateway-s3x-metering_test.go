@@ -0,0 +1,181 @@
+package s3x
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+)
+
+func TestS3X_Metering_Badger(t *testing.T) {
+	testS3XMetering(t, DSTypeBadger)
+}
+func TestS3X_Metering_Crdt(t *testing.T) {
+	testS3XMetering(t, DSTypeCrdt)
+}
+func testS3XMetering(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	start := time.Now().UTC().Add(-2 * time.Hour)
+	gateway.meter = newUsageMeter(start)
+	// request returns the context of an S3 request
+	request := func(api, accessKey string) context.Context {
+		return logger.SetReqInfo(ctx, &logger.ReqInfo{API: api, BucketName: testBucket1, AccessKey: accessKey})
+	}
+	if err := gateway.MakeBucketWithLocation(request("PutBucket", "tenant"), testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(request("PutObject", "tenant"), testBucket1, testObject1, getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gateway.GetObjectNInfo(request("GetObject", "tenant"), testBucket1, testObject1, nil, http.Header{}, minio.LockType(0), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(gr); err != nil {
+		t.Fatal(err)
+	}
+	gr.Close()
+	head := request("HeadObject", "tenant")
+	for i := 0; i < 2; i++ { // counted once per request
+		if _, err := gateway.GetObjectInfo(head, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gateway.DeleteObject(request("DeleteObject", "other"), testBucket1, "missing"); err == nil {
+		t.Fatal("expected an error deleting a missing object")
+	}
+	// requests not made through the S3 api are not counted
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(gateway.testPath, "usage.jsonl")
+		defer os.Remove(path)
+		sink, err := gateway.newUsageSink("file:" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := start.Add(2 * time.Hour)
+		if err := gateway.flushUsage(ctx, sink, end); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		records := make(map[string]usageRecord)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var r usageRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+			if !r.Start.Equal(start) || !r.End.Equal(end) {
+				t.Fatalf("unexpected period of %+v", r)
+			}
+			records[r.AccessKey] = r
+		}
+		want := map[string]usageRecord{
+			"tenant": {ClassARequests: 2, ClassBRequests: 2, IngressBytes: 4, EgressBytes: 4},
+			"other":  {FreeRequests: 1},
+			"":       {StorageByteHours: 8},
+		}
+		if len(records) != len(want) {
+			t.Fatalf("expected %v records, but got %+v", len(want), records)
+		}
+		for key, w := range want {
+			r := records[key]
+			w.Start, w.End, w.Bucket, w.AccessKey = r.Start, r.End, testBucket1, key
+			if r != w {
+				t.Fatalf("expected %+v, but got %+v", w, r)
+			}
+		}
+	})
+	t.Run("Webhook-Retry", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			received []usageRecord
+			fail     = true
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if fail {
+				fail = false
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var records []usageRecord
+			if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+				t.Error(err)
+			}
+			received = append(received, records...)
+		}))
+		defer server.Close()
+		sink, err := gateway.newUsageSink(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.ListBuckets(request("ListBuckets", "tenant")); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now().UTC()
+		if err := gateway.flushUsage(ctx, sink, now); err == nil {
+			t.Fatal("expected an error from the webhook")
+		}
+		if err := gateway.flushUsage(ctx, sink, now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var requests int64
+		for _, r := range received {
+			requests += r.ClassARequests
+		}
+		// the failed records are written with the next flush, with the storage of both periods
+		if len(received) != 3 || requests != 1 {
+			t.Fatalf("expected the records of both flushes, but got %+v", received)
+		}
+	})
+	t.Run("Bucket", func(t *testing.T) {
+		sink, err := gateway.newUsageSink("bucket://" + testBucket1 + "/usage/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.flushUsage(ctx, sink, time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+		objs, _, err := gateway.ledgerStore.GetObjectInfos(ctx, testBucket1, "usage/", "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(objs) != 1 {
+			t.Fatalf("expected a usage object, but got %v", objs)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, sink := range []string{"ftp://example.com", "bucket:///prefix", "file:"} {
+			if _, err := gateway.newUsageSink(sink); err == nil {
+				t.Fatalf("expected an error for sink %q", sink)
+			}
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package s3x
+
+import (
+	"context"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+)
+
+/* Design Notes
+---------------
+
+The metrics configurations of a bucket are kept in its config, only their ids and prefixes are
+stored since tag and access point filters are rejected by the S3 handlers. The requests matching
+each configuration are counted in memory by the S3 handlers and exported as Prometheus metrics,
+so the counters start from zero whenever the gateway restarts.
+*/
+
+// SetBucketMetricsConfig adds a metrics configuration to a bucket, or replaces the configuration with the same id
+func (x *xObjects) SetBucketMetricsConfig(ctx context.Context, bucket string, config *bucketmetrics.Config) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	stored := &MetricsConfig{Id: config.ID, Prefix: config.Prefix()}
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		for i, m := range c.Metrics {
+			if m.GetId() == config.ID {
+				c.Metrics[i] = stored
+				return nil
+			}
+		}
+		c.Metrics = append(c.Metrics, stored)
+		return nil
+	}), bucket, "", "")
+}
+
+// DeleteBucketMetricsConfig removes a metrics configuration from a bucket
+func (x *xObjects) DeleteBucketMetricsConfig(ctx context.Context, bucket, id string) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		for i, m := range c.Metrics {
+			if m.GetId() == id {
+				c.Metrics = append(c.Metrics[:i], c.Metrics[i+1:]...)
+				return nil
+			}
+		}
+		return minio.BucketMetricsConfigNotFound{Bucket: bucket, ID: id}
+	}), bucket, "", "")
+}
+
+// ListBucketMetricsConfigs returns the metrics configurations of a bucket
+func (x *xObjects) ListBucketMetricsConfigs(ctx context.Context, bucket string) ([]bucketmetrics.Config, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	configs := make([]bucketmetrics.Config, 0, len(c.GetMetrics()))
+	for _, m := range c.GetMetrics() {
+		config := bucketmetrics.Config{
+			XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/",
+			ID:    m.GetId(),
+		}
+		if m.GetPrefix() != "" {
+			config.Filter = &bucketmetrics.Filter{Prefix: m.GetPrefix()}
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
@@ -0,0 +1,80 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
+)
+
+var _ minio.BucketMetricsConfigurer = (*xObjects)(nil)
+
+func TestS3X_BucketMetrics_Badger(t *testing.T) {
+	testS3XBucketMetrics(t, DSTypeBadger)
+}
+func TestS3X_BucketMetrics_Crdt(t *testing.T) {
+	testS3XBucketMetrics(t, DSTypeCrdt)
+}
+func testS3XBucketMetrics(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	checkConfigs := func(t *testing.T, want ...bucketmetrics.Config) {
+		t.Helper()
+		got, err := gateway.ListBucketMetricsConfigs(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %v configurations, but got %+v", len(want), got)
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Prefix() != want[i].Prefix() {
+				t.Fatalf("expected configuration %+v, but got %+v", want[i], got[i])
+			}
+		}
+	}
+	all := bucketmetrics.Config{ID: "all"}
+	docs := bucketmetrics.Config{ID: "docs", Filter: &bucketmetrics.Filter{Prefix: "documents/"}}
+	t.Run("Set", func(t *testing.T) {
+		checkConfigs(t)
+		for _, c := range []bucketmetrics.Config{all, docs} {
+			c := c
+			if err := gateway.SetBucketMetricsConfig(ctx, testBucket1, &c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		gateway.restart(t)
+		checkConfigs(t, all, docs)
+		// replaces the configuration with the same id
+		docs.Filter.Prefix = "docs/"
+		if err := gateway.SetBucketMetricsConfig(ctx, testBucket1, &docs); err != nil {
+			t.Fatal(err)
+		}
+		checkConfigs(t, all, docs)
+		if err := gateway.SetBucketMetricsConfig(ctx, "missingbucket", &all); err != (minio.BucketNotFound{Bucket: "missingbucket"}) {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+		if _, err := gateway.ListBucketMetricsConfigs(ctx, "missingbucket"); err != (minio.BucketNotFound{Bucket: "missingbucket"}) {
+			t.Fatalf("expected BucketNotFound, but got %v", err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := gateway.DeleteBucketMetricsConfig(ctx, testBucket1, "all"); err != nil {
+			t.Fatal(err)
+		}
+		checkConfigs(t, docs)
+		err := gateway.DeleteBucketMetricsConfig(ctx, testBucket1, "all")
+		if err != (minio.BucketMetricsConfigNotFound{Bucket: testBucket1, ID: "all"}) {
+			t.Fatalf("expected BucketMetricsConfigNotFound, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Multipart uploads that are never completed or aborted keep the data of their parts on TemporalX, so
+operators can list the uploads in progress with the access key that started them, their size, and age,
+and abort uploads that were abandoned. Aborting an upload, through this api or the S3 api, enqueues the
+data hashes of its parts for garbage collection, unless the same data is referenced by an object.
+*/
+
+// multipartSession returns the api representation of a multipart upload at now
+func multipartSession(m *MultipartUpload, now time.Time) *MultipartSession {
+	s := &MultipartSession{
+		UploadID:  m.GetId(),
+		Owner:     m.GetOwner(),
+		Bucket:    m.GetObjectInfo().GetBucket(),
+		Object:    m.GetObjectInfo().GetName(),
+		Parts:     int64(len(m.GetObjectParts())),
+		Initiated: m.GetObjectInfo().GetModTime().Unix(),
+	}
+	for _, p := range m.GetObjectParts() {
+		s.Bytes += p.GetSize_()
+	}
+	if age := now.Sub(m.GetObjectInfo().GetModTime()); age > 0 {
+		s.AgeSeconds = int64(age / time.Second)
+	}
+	return s
+}
+
+// ListMultipartSessions returns the multipart uploads in progress, oldest first
+func (x *xObjects) ListMultipartSessions(ctx context.Context, req *ListMultipartSessionsRequest) (*ListMultipartSessionsResponse, error) {
+	if req.GetMinAgeSeconds() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "minimum age can not be negative")
+	}
+	uploads, err := x.ledgerStore.ListMultipartUploads(ctx)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	now := x.clock.Now()
+	sessions := make([]*MultipartSession, 0, len(uploads))
+	for _, m := range uploads {
+		s := multipartSession(m, now)
+		if req.GetBucket() != "" && s.Bucket != req.GetBucket() {
+			continue
+		}
+		if s.AgeSeconds < req.GetMinAgeSeconds() {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].Initiated != sessions[j].Initiated {
+			return sessions[i].Initiated < sessions[j].Initiated
+		}
+		return sessions[i].UploadID < sessions[j].UploadID
+	})
+	return &ListMultipartSessionsResponse{Sessions: sessions}, nil
+}
+
+// AbortMultipartSession aborts a multipart upload in progress, and enqueues the data of its parts for garbage collection
+func (x *xObjects) AbortMultipartSession(ctx context.Context, req *AbortMultipartSessionRequest) (*AbortMultipartSessionResponse, error) {
+	if req.GetUploadID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload id is empty")
+	}
+	m, unlock, err := x.ledgerStore.GetObjectDetails(req.GetUploadID())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	bucket := m.GetObjectInfo().GetBucket()
+	unlock()
+	aborted, unreferenced, err := x.ledgerStore.AbortMultipartUpload(bucket, req.GetUploadID())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	s := multipartSession(aborted, x.clock.Now())
+	log.Printf("bucket-name: %s, object-name: %s, upload-id: %s, aborted-multipart-upload", s.Bucket, s.Object, s.UploadID)
+	return &AbortMultipartSessionResponse{
+		Session:      s,
+		Unreferenced: unreferenced,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_MultipartSessions_Badger(t *testing.T) {
+	testS3XMultipartSessions(t, DSTypeBadger)
+}
+func TestS3X_MultipartSessions_Crdt(t *testing.T) {
+	testS3XMultipartSessions(t, DSTypeCrdt)
+}
+func testS3XMultipartSessions(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, b := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, b, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reqCtx := logger.SetReqInfo(ctx, &logger.ReqInfo{AccessKey: "owner1"})
+	uploadID, err := gateway.NewMultipartUpload(reqCtx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.NewMultipartUpload(ctx, testBucket2, testObject1, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range []string{"part one", "part two"} {
+		if _, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, i+1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the data of the first part is also the data of an object, so it is not released on abort
+	if _, err := gateway.PutObject(ctx, testBucket2, testObject1, getTestPutObjectReader(t, []byte("part one")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("List", func(t *testing.T) {
+		resp, err := gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetSessions()) != 2 {
+			t.Fatalf("expected 2 sessions, but got %v", resp.GetSessions())
+		}
+		resp, err = gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetSessions()) != 1 {
+			t.Fatalf("expected 1 session, but got %v", resp.GetSessions())
+		}
+		s := resp.GetSessions()[0]
+		want := MultipartSession{
+			UploadID:  uploadID,
+			Owner:     "owner1",
+			Bucket:    testBucket1,
+			Object:    testObject1,
+			Parts:     2,
+			Bytes:     16,
+			Initiated: gateway.clock.Now().Unix(),
+		}
+		if *s != want {
+			t.Fatalf("expected session %+v, but got %+v", want, *s)
+		}
+		resp, err = gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{MinAgeSeconds: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetSessions()) != 0 {
+			t.Fatalf("expected no sessions older than a second, but got %v", resp.GetSessions())
+		}
+		if _, err := gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{MinAgeSeconds: -1}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, but got %v", err)
+		}
+	})
+	t.Run("Abort", func(t *testing.T) {
+		m, unlock, err := gateway.ledgerStore.GetObjectDetails(uploadID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept, released := m.ObjectParts[1].DataHash, m.ObjectParts[2].DataHash
+		unlock()
+		resp, err := gateway.AbortMultipartSession(ctx, &AbortMultipartSessionRequest{UploadID: uploadID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetSession().GetUploadID() != uploadID || resp.GetSession().GetBytes() != 16 {
+			t.Fatalf("unexpected aborted session %+v", resp.GetSession())
+		}
+		if len(resp.GetUnreferenced()) != 1 || resp.GetUnreferenced()[0] != released {
+			t.Fatalf("expected %v to be unreferenced, but got %v", released, resp.GetUnreferenced())
+		}
+		garbage, err := gateway.ledgerStore.GarbageData(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := garbage[released]; !ok {
+			t.Fatalf("expected %v to be garbage, but got %v", released, garbage)
+		}
+		if _, ok := garbage[kept]; ok {
+			t.Fatalf("expected %v referenced by an object not to be garbage", kept)
+		}
+		if _, err := gateway.AbortMultipartSession(ctx, &AbortMultipartSessionRequest{UploadID: uploadID}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected NotFound, but got %v", err)
+		}
+		if _, err := gateway.AbortMultipartSession(ctx, &AbortMultipartSessionRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument, but got %v", err)
+		}
+		resp2, err := gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp2.GetSessions()) != 1 || resp2.GetSessions()[0].GetBucket() != testBucket2 {
+			t.Fatalf("expected only the session of %v, but got %v", testBucket2, resp2.GetSessions())
+		}
+	})
+	t.Run("S3 Abort", func(t *testing.T) {
+		if err := gateway.AbortMultipartUpload(ctx, testBucket1, testObject1, uploadID); err == nil {
+			t.Fatal("expected aborting an aborted upload to fail")
+		}
+		resp, err := gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{Bucket: testBucket2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := resp.GetSessions()[0].GetUploadID()
+		if err := gateway.AbortMultipartUpload(ctx, testBucket1, testObject1, id); err == nil {
+			t.Fatal("expected aborting an upload of another bucket to fail")
+		}
+		if err := gateway.AbortMultipartUpload(ctx, testBucket2, testObject1, id); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
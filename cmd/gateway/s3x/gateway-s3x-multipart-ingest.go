@@ -0,0 +1,72 @@
+package s3x
+
+import (
+	"sync"
+)
+
+/* Design Notes
+---------------
+
+A part uploaded as a single TemporalX file is pushed over one gRPC stream, so a client that uploads the parts of
+a large object one at a time, or a few at a time, is limited by the round trips of a single stream per part.
+PutObjectPart instead splits each part into chunks of chunkSize bytes and pushes them as separate files in
+parallel, and links them in order into the unixfs file node of the part, like uploadChunks does for copies.
+
+The chunks in flight of all parts of the same multipart upload share a budget of MultipartConcurrency slots, so
+a single part uses the whole budget, and a client that sends many parts of one upload at once does not open more
+streams to TemporalX, or hold more chunks in memory, than one upload is allowed. Each chunk is held in memory
+while it is pushed, so an upload holds at most MultipartConcurrency chunks. Parts of different uploads have
+separate budgets.
+
+A part of at most chunkSize bytes is stored as a single file, as before, so it has the hash of an object with
+the same data.
+*/
+
+// defaultMultipartConcurrency is how many chunks of the parts of a multipart upload are pushed at the same time
+// by default
+const defaultMultipartConcurrency = 8
+
+// partBudgets are the slots shared by the chunks of the parts of each multipart upload that are being uploaded
+type partBudgets struct {
+	size int // the slots of each upload
+
+	mu      sync.Mutex
+	uploads map[string]*partBudget
+}
+
+// partBudget is the budget of a multipart upload
+type partBudget struct {
+	slots chan struct{}
+	parts int // the parts of the upload that use the budget
+}
+
+// newPartBudgets returns budgets of size slots for each multipart upload
+func newPartBudgets(size int) *partBudgets {
+	return &partBudgets{size: size, uploads: make(map[string]*partBudget)}
+}
+
+// acquire returns the slots of a multipart upload, release must be called once the part was uploaded
+func (b *partBudgets) acquire(uploadID string) (slots chan struct{}, release func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	budget, ok := b.uploads[uploadID]
+	if !ok {
+		budget = &partBudget{slots: make(chan struct{}, b.size)}
+		b.uploads[uploadID] = budget
+	}
+	budget.parts++
+	return budget.slots, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if budget.parts--; budget.parts == 0 {
+			delete(b.uploads, uploadID)
+		}
+	}
+}
+
+// len returns the number of multipart uploads with parts being uploaded
+func (b *partBudgets) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.uploads)
+}
@@ -0,0 +1,97 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"sync"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestPartBudgets(t *testing.T) {
+	b := newPartBudgets(2)
+	slots1, release1 := b.acquire("upload1")
+	slots2, release2 := b.acquire("upload1")
+	other, releaseOther := b.acquire("upload2")
+	if slots1 != slots2 || cap(slots1) != 2 {
+		t.Fatal("expected the parts of an upload to share a budget of 2 slots")
+	}
+	if slots1 == other {
+		t.Fatal("expected separate budgets for separate uploads")
+	}
+	releaseOther()
+	release1()
+	if b.len() != 1 {
+		t.Fatalf("expected the budget of the upload in progress to be kept, but got %v budgets", b.len())
+	}
+	release2()
+	if b.len() != 0 {
+		t.Fatalf("expected no budgets, but got %v", b.len())
+	}
+}
+
+func TestS3X_ParallelPartIngestion(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.partBudgets = newPartBudgets(2)
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the parts span several chunks, and are uploaded at the same time
+	parts := make([][]byte, 3)
+	for i := range parts {
+		parts[i] = make([]byte, 2*chunkSize+100*(i+1))
+		if _, err := io.ReadFull(&patternReader{pattern: []byte{byte('a' + i), byte('0' + i)}}, parts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	completed := make([]minio.CompletePart, len(parts))
+	var wg sync.WaitGroup
+	errs := make(chan error, len(parts))
+	for i := range parts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uploadID, i+1, getTestPutObjectReader(t, parts[i]), minio.ObjectOptions{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if sum := md5.Sum(parts[i]); pi.ETag != hex.EncodeToString(sum[:]) || pi.Size != int64(len(parts[i])) {
+				t.Errorf("part %v: unexpected part info %+v", i+1, pi)
+			}
+			completed[i] = minio.CompletePart{PartNumber: i + 1, ETag: pi.ETag}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := gateway.partBudgets.len(); n != 0 {
+		t.Fatalf("expected the budget to be released, but got %v budgets", n)
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uploadID, completed, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, &buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := bytes.Join(parts, nil); !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("expected %v bytes of the parts in order, but got %v bytes", len(want), buf.Len())
+	}
+}
@@ -2,16 +2,15 @@ package s3x
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	fmt "fmt"
-	"time"
+	"io"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
-	proto "github.com/gogo/protobuf/proto"
-	"github.com/ipfs/go-cid"
-	ipld "github.com/ipfs/go-ipld-format"
-	"github.com/ipfs/go-merkledag"
-	unixfs_pb "github.com/ipfs/go-unixfs/pb"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
 	"github.com/segmentio/ksuid"
 )
 
@@ -27,10 +26,23 @@ func (x *xObjects) NewMultipartUpload(
 	bucket, object string,
 	opts minio.ObjectOptions,
 ) (uploadID string, err error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	if err := x.names.checkObjectName(object); err != nil {
+		return "", x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	if err := x.limits.checkMetadata(opts.UserDefined); err != nil {
+		return "", x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	var owner string
+	if reqInfo := logger.GetReqInfo(ctx); reqInfo != nil {
+		owner = reqInfo.AccessKey
+	}
 	uploadID = ksuid.New().String()
-	info := newObjectInfo(bucket, object, 0, opts)
-	return uploadID, x.toMinioErr(
-		x.ledgerStore.NewMultipartUpload(uploadID, &info),
+	info := newObjectInfo(bucket, object, 0, opts, x.clock.Now())
+	return uploadID, x.toMinioErr(ctx,
+		x.ledgerStore.NewMultipartUpload(uploadID, owner, &info),
 		bucket, object, uploadID,
 	)
 }
@@ -43,23 +55,54 @@ func (x *xObjects) PutObjectPart(
 	r *minio.PutObjReader,
 	opts minio.ObjectOptions,
 ) (pi minio.PartInfo, e error) {
-	err := x.ledgerStore.AssertBucketExits(bucket)
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityBulk)
+	if err != nil {
+		return pi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+	}
+	defer finish()
+	if err := x.limits.checkPart(partID, r.Size()); err != nil {
+		return pi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+	}
+	if err := x.ledgerStore.AssertBucketExits(bucket); err != nil {
+		return pi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return pi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	release, err := x.capacity.reserve(r.Size())
 	if err != nil {
-		return pi, x.toMinioErr(err, bucket, "", "")
+		return pi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
-	hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+	defer func() { release(pi.Size) }()
+	// the md5 of the part data is its S3 ETag, and is needed for the ETag of the completed object
+	md5Hash := md5.New()
+	body := x.bandwidth.reader(ctx, bucket, config, &limitReader{r: r, limit: x.limits.partSize, err: ErrPartTooLarge})
+	data, remove, err := x.spool.spool(io.TeeReader(body, md5Hash), r.Size())
 	if err != nil {
-		return pi, x.toMinioErr(err, bucket, object, uploadID)
+		return pi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+	}
+	defer remove()
+	slots, done := x.partBudgets.acquire(uploadID)
+	defer done()
+	hash, size, err := x.uploadChunksWithSlots(ctx, &fixedChunker{r: data, size: chunkSize}, slots)
+	if err != nil {
+		return pi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
 	pi = minio.PartInfo{
 		PartNumber:   partID,
-		LastModified: time.Now().UTC(),
-		ETag:         hash,
+		LastModified: x.clock.Now().UTC(),
+		ETag:         hex.EncodeToString(md5Hash.Sum(nil)),
 		Size:         int64(size),
 		ActualSize:   int64(size),
+		Checksum:     partChecksum(opts.UserDefined),
 	}
-	return pi, x.toMinioErr(
-		x.ledgerStore.PutObjectPart(bucket, object, uploadID, pi),
+	x.meter.transfer(ctx, bucket, pi.Size, 0)
+	return pi, x.toMinioErr(ctx,
+		x.ledgerStore.PutObjectPart(bucket, object, uploadID, pi, hash),
 		bucket, object, uploadID)
 }
 
@@ -79,6 +122,14 @@ func (x *xObjects) ListObjectParts(
 	partNumberMarker, maxParts int,
 	opts minio.ObjectOptions,
 ) (lpi minio.ListPartsInfo, e error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return lpi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+	}
+	defer finish()
 	lpi = minio.ListPartsInfo{
 		Bucket:           bucket,
 		Object:           object,
@@ -89,32 +140,38 @@ func (x *xObjects) ListObjectParts(
 	m, unlock, err := x.ledgerStore.GetObjectDetails(uploadID)
 	defer unlock()
 	if err != nil {
-		return lpi, x.toMinioErr(err, bucket, object, uploadID)
+		return lpi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
 	if m.GetObjectInfo().GetBucket() != bucket ||
 		m.GetObjectInfo().GetName() != object {
-		return lpi, x.toMinioErr(ErrInvalidUploadID, bucket, object, uploadID)
+		return lpi, x.toMinioErr(ctx, ErrInvalidUploadID, bucket, object, uploadID)
 	}
+	// the metadata of the upload tells the handlers whether the parts are encrypted
+	lpi.UserDefined = m.GetObjectInfo().GetUserDefined()
 
 	for _, part := range m.ObjectParts {
 		lpi.Parts = append(lpi.Parts, minio.PartInfo{
 			PartNumber: int(part.GetNumber()),
-			ETag:       minio.ToS3ETag(part.GetDataHash()),
+			ETag:       partETag(part),
 			Size:       part.GetSize_(),
+			Checksum:   part.GetChecksum(),
 		})
 	}
 
 	return lpi, nil
 }
 
-// AbortMultipartUpload aborts a ongoing multipart upload
+// AbortMultipartUpload aborts a ongoing multipart upload, and releases the data of its parts
 func (x *xObjects) AbortMultipartUpload(
 	ctx context.Context,
 	bucket, object, uploadID string,
 ) error {
-	// TODO(bonedaddy): remove the corresponding objects from ipfs
-	return x.toMinioErr(
-		x.ledgerStore.AbortMultipartUpload(bucket, uploadID),
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	_, _, err := x.ledgerStore.AbortMultipartUpload(bucket, uploadID)
+	return x.toMinioErr(ctx,
+		err,
 		bucket,
 		object,
 		uploadID,
@@ -129,69 +186,90 @@ func (x *xObjects) CompleteMultipartUpload(
 	uploadedParts []minio.CompletePart,
 	opts minio.ObjectOptions,
 ) (oi minio.ObjectInfo, e error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
 	err := x.ledgerStore.AssertBucketExits(bucket)
 	if err != nil {
-		return oi, x.toMinioErr(err, bucket, object, uploadID)
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
 	m, unlock, err := x.ledgerStore.GetObjectDetails(uploadID)
 	if err != nil {
-		return oi, x.toMinioErr(err, bucket, object, uploadID)
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
 	defer unlock()
-	totalSize := uint64(0)
-	links := make([]*ipld.Link, 0, len(uploadedParts))
-	blocks := make([]uint64, 0, len(uploadedParts))
+	if err := x.limits.checkPartCount(len(uploadedParts)); err != nil {
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+	}
+	// parts of uploads with a checksum algorithm are stored with their checksum, which the client can also send
+	algorithm := userDefinedValue(m.GetObjectInfo().GetUserDefined(), xhttp.AmzChecksumAlgorithm)
+	checksums := make([]string, 0, len(uploadedParts))
+	var total int64
+	hashes := make([]string, 0, len(uploadedParts))
+	sizes := make([]uint64, 0, len(uploadedParts))
+	parts := make([]ObjectPartInfo, 0, len(uploadedParts))
+	completed := make([]minio.CompletePart, 0, len(uploadedParts))
 	for _, p := range uploadedParts {
 		number := int64(p.PartNumber)
 		pi, ok := m.ObjectParts[number]
 		if !ok {
-			return oi, x.toMinioErr(fmt.Errorf("PartNumber %v not found", number), bucket, object, uploadID)
+			return oi, minio.InvalidPart{PartNumber: p.PartNumber, GotETag: p.ETag}
 		}
-		if pi.ActualSize <= 0 {
-			return oi, x.toMinioErr(fmt.Errorf("PartNumber %v reported ActualSize as %v", number, pi.ActualSize), bucket, object, uploadID)
+		etag := partETag(pi)
+		if p.ETag != "" && pi.Etag != "" && minio.ToS3ETag(p.ETag) != minio.ToS3ETag(etag) {
+			return oi, minio.InvalidPart{PartNumber: p.PartNumber, ExpETag: etag, GotETag: p.ETag}
 		}
-		cid, err := cid.Decode(pi.DataHash)
-		if err != nil {
-			return oi, x.toMinioErr(fmt.Errorf("PartNumber %v hash is not cid, %v", number, err), bucket, object, uploadID)
+		if algorithm != "" {
+			if got := p.Get(algorithm); pi.GetChecksum() == "" || got != "" && got != pi.GetChecksum() {
+				return oi, minio.InvalidPart{PartNumber: p.PartNumber}
+			}
+			checksums = append(checksums, pi.GetChecksum())
 		}
-		size := uint64(pi.ActualSize)
-		totalSize += size
-		links = append(links, &ipld.Link{
-			Size: size,
-			Cid:  cid,
-		})
-		blocks = append(blocks, size)
-	}
-	protoNode := &merkledag.ProtoNode{}
-	protoNode.SetCidBuilder(merkledag.V1CidPrefix())
-	protoNode.SetLinks(links)
-	data, err := proto.Marshal(&unixfs_pb.Data{
-		Type:       unixfs_pb.Data_File.Enum(),
-		Filesize:   &totalSize,
-		Blocksizes: blocks,
-	})
-	if err != nil {
-		return oi, x.toMinioErr(err, bucket, object, uploadID)
+		parts = append(parts, pi)
+		completed = append(completed, minio.CompletePart{PartNumber: p.PartNumber, ETag: etag})
+		if pi.ActualSize <= 0 {
+			return oi, x.toMinioErr(ctx, fmt.Errorf("PartNumber %v reported ActualSize as %v", number, pi.ActualSize), bucket, object, uploadID)
+		}
+		hashes = append(hashes, pi.DataHash)
+		sizes = append(sizes, uint64(pi.ActualSize))
+		total += pi.ActualSize
+	}
+	if err := x.limits.checkObjectSize(total); err != nil {
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
-	protoNode.SetData(data)
-	dataHash, err := ipfsSaveProtoNode(ctx, x.dagClient, protoNode)
+	dataHash, totalSize, err := x.saveFileLinks(ctx, hashes, sizes)
 	if err != nil {
-		return oi, x.toMinioErr(err, bucket, object, uploadID)
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
 	loi := m.ObjectInfo
 	if loi == nil || len(opts.UserDefined) != 0 {
-		noi := newObjectInfo(bucket, object, int(totalSize), opts)
+		noi := newObjectInfo(bucket, object, int(totalSize), opts, x.clock.Now())
 		loi = &noi
 	} else {
 		loi.Size_ = int64(totalSize)
-		loi.ModTime = time.Now().UTC()
+		loi.ModTime = x.clock.Now().UTC()
+	}
+	loi.Etag = minio.ComputeCompleteMultipartMD5(completed)
+	loi.Parts = parts
+	if algorithm != "" {
+		// the object keeps the composite checksum of its parts instead of the algorithm of the upload
+		checksum, err := minio.ComputeCompleteMultipartChecksum(algorithm, checksums)
+		if err != nil {
+			return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
+		}
+		loi.UserDefined = withoutChecksums(loi.UserDefined)
+		if loi.UserDefined == nil {
+			loi.UserDefined = make(map[string]string)
+		}
+		loi.UserDefined[xhttp.AmzChecksumPrefix+algorithm] = checksum
 	}
 	err = x.ledgerStore.PutObject(ctx, bucket, object, &Object{
 		DataHash:   dataHash,
 		ObjectInfo: *loi,
 	})
 	if err != nil {
-		return oi, x.toMinioErr(err, bucket, object, uploadID)
+		return oi, x.toMinioErr(ctx, err, bucket, object, uploadID)
 	}
-	return getMinioObjectInfo(loi), x.AbortMultipartUpload(ctx, bucket, object, uploadID)
+	// the parts are linked from the completed object, so their data is not released
+	return getMinioObjectInfo(loi), x.toMinioErr(ctx, x.ledgerStore.DeleteMultipartID(uploadID), bucket, object, uploadID)
 }
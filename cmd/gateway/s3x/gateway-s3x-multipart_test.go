@@ -15,9 +15,11 @@ func TestS3X_Multipart_Crdt(t *testing.T) {
 	testS3XMultipart(t, DSTypeCrdt)
 }
 func testS3XMultipart(t *testing.T, dsType DSType) {
-	bucket := "my multipart bucket"
+	bucket := "my-multipart-bucket"
 	object := "my multipart object"
-	objectETag := "bafybeibzfoslocl3zs4fngsqminlpikibos7u664circ6mw7kjwkwa6y54"
+	// the md5 of "data", and the md5 of six of them concatenated with the part count
+	partETag := "8d777f385d3dfec8815d20f7496026dc"
+	objectETag := "708ae0340fc76c9aa017bbaf25d51abe-6"
 	ctx := context.Background()
 	gateway := newTestGateway(t, dsType)
 	defer func() {
@@ -25,7 +27,7 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 			t.Fatal(err)
 		}
 	}()
-	if err := gateway.MakeBucketWithLocation(ctx, bucket, "us-east-1"); err != nil {
+	if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{Location: "us-east-1"}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -41,7 +43,7 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 	totalSize := len(partData) * parts
 	partsInfo := []minio.PartInfo{}
 	t.Run("add parts", func(t *testing.T) {
-		for i := 0; i < parts; i++ {
+		for i := 1; i <= parts; i++ {
 			pi, err := gateway.PutObjectPart(ctx, bucket, object, uID, i, getTestPutObjectReader(t, partData), minio.ObjectOptions{})
 			if err != nil {
 				t.Fatal(err)
@@ -49,13 +51,35 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 			if pi.PartNumber != i {
 				t.Fatalf("expected part number %v, but received %v", i, pi.PartNumber)
 			}
-			if pi.ETag != objectETag {
-				t.Fatalf("expected ETag %v, but received %v", objectETag, pi.ETag)
+			if pi.ETag != partETag {
+				t.Fatalf("expected ETag %v, but received %v", partETag, pi.ETag)
 			}
 			partsInfo = append(partsInfo, pi)
 		}
 	})
 	gateway.restart(t) //make sure parts still exist after restart
+	t.Run("list parts", func(t *testing.T) {
+		lpi, err := gateway.ListObjectParts(ctx, bucket, object, uID, 0, parts, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lpi.Parts) != parts {
+			t.Fatalf("expected %v parts, but received %v", parts, len(lpi.Parts))
+		}
+		for _, p := range lpi.Parts {
+			if p.ETag != partETag {
+				t.Fatalf("expected ETag %v, but received %v", partETag, p.ETag)
+			}
+		}
+	})
+	t.Run("complete with wrong part ETag", func(t *testing.T) {
+		uploadParts := []minio.CompletePart{{PartNumber: 1, ETag: "wrong"}}
+		if _, err := gateway.CompleteMultipartUpload(ctx, bucket, object, uID, uploadParts, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected an InvalidPart error")
+		} else if _, ok := err.(minio.InvalidPart); !ok {
+			t.Fatalf("expected an InvalidPart error, but received %v", err)
+		}
+	})
 	t.Run("complete", func(t *testing.T) {
 		uploadParts := make([]minio.CompletePart, 0, parts)
 		for _, pi := range partsInfo {
@@ -71,6 +95,9 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 		if oi.Size != int64(totalSize) {
 			t.Fatalf("expected file size %v, but received %v", totalSize, oi.Size)
 		}
+		if oi.ETag != objectETag {
+			t.Fatalf("expected ETag %v, but received %v", objectETag, oi.ETag)
+		}
 	})
 
 	t.Run("get completed object", func(t *testing.T) {
@@ -84,3 +111,63 @@ func testS3XMultipart(t *testing.T, dsType DSType) {
 		}
 	})
 }
+
+func TestS3X_MultipartChecksum(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// the base64 crc32 of "data", and the crc32 of two of them concatenated with the part count
+	partChecksum := "rfPzYw=="
+	objectChecksum := "r8Hllw==-2"
+	uID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{
+		UserDefined: map[string]string{"x-amz-checksum-algorithm": "crc32"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uploadParts []minio.CompletePart
+	for i := 1; i <= 2; i++ {
+		opts := minio.ObjectOptions{UserDefined: map[string]string{"x-amz-checksum-crc32": partChecksum}}
+		pi, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, uID, i, getTestPutObjectReader(t, []byte("data")), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploadParts = append(uploadParts, minio.CompletePart{PartNumber: i, ETag: pi.ETag})
+	}
+	lpi, err := gateway.ListObjectParts(ctx, testBucket1, testObject1, uID, 0, 2, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range lpi.Parts {
+		if p.Checksum != partChecksum {
+			t.Fatalf("expected part checksum %v, but got %v", partChecksum, p.Checksum)
+		}
+	}
+
+	// a part checksum sent on completion must match the stored one
+	wrong := append([]minio.CompletePart{}, uploadParts...)
+	wrong[1].ChecksumCRC32 = "AAAAAA=="
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, wrong, minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected an InvalidPart error")
+	} else if _, ok := err.(minio.InvalidPart); !ok {
+		t.Fatalf("expected an InvalidPart error, but received %v", err)
+	}
+	uploadParts[0].ChecksumCRC32 = partChecksum
+	info, err := gateway.CompleteMultipartUpload(ctx, testBucket1, testObject1, uID, uploadParts, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.UserDefined["x-amz-checksum-crc32"] != objectChecksum {
+		t.Fatalf("expected object checksum %v, but got %v", objectChecksum, info.UserDefined)
+	}
+	if _, ok := info.UserDefined["x-amz-checksum-algorithm"]; ok {
+		t.Fatalf("expected the checksum algorithm of the upload to be dropped, but got %v", info.UserDefined)
+	}
+}
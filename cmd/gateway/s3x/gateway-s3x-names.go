@@ -0,0 +1,65 @@
+package s3x
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/minio/minio-go/v6/pkg/s3utils"
+)
+
+// NameValidation is how strictly bucket names and object keys are validated before they are written to the ledger
+type NameValidation string
+
+const (
+	// NameValidationStrict only allows DNS-compatible bucket names, and object keys without control characters
+	NameValidationStrict NameValidation = "strict"
+	// NameValidationRelaxed also allows legacy bucket names with uppercase letters and underscores,
+	// and object keys with control characters
+	NameValidationRelaxed NameValidation = "relaxed"
+)
+
+// maxObjectNameLength is the maximum length of object keys in bytes
+const maxObjectNameLength = 1024
+
+// newNameValidation returns the NameValidation of a flag value, strict if empty
+func newNameValidation(v string) (NameValidation, error) {
+	switch NameValidation(v) {
+	case "", NameValidationStrict:
+		return NameValidationStrict, nil
+	case NameValidationRelaxed:
+		return NameValidationRelaxed, nil
+	}
+	return "", fmt.Errorf(`name validation "%v" not supported`, v)
+}
+
+// checkBucketName returns ErrInvalidBucketName if bucket is not a valid bucket name
+func (v NameValidation) checkBucketName(bucket string) error {
+	check := s3utils.CheckValidBucketNameStrict
+	if v == NameValidationRelaxed {
+		check = s3utils.CheckValidBucketName
+	}
+	if check(bucket) != nil {
+		return ErrInvalidBucketName
+	}
+	return nil
+}
+
+// checkObjectName returns ErrObjectNameTooLong or ErrInvalidObjectName if object is not a valid object key
+func (v NameValidation) checkObjectName(object string) error {
+	if len(object) > maxObjectNameLength {
+		return ErrObjectNameTooLong
+	}
+	if object == "" || !utf8.ValidString(object) {
+		return ErrInvalidObjectName
+	}
+	if v == NameValidationRelaxed {
+		return nil
+	}
+	for _, r := range object {
+		if unicode.IsControl(r) {
+			return ErrInvalidObjectName
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package s3x
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_NameValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation NameValidation
+		bucket     string
+		object     string
+		bucketErr  error
+		objectErr  error
+	}{
+		{"Valid", NameValidationStrict, "my-bucket.1", "docs/a file.txt", nil, nil},
+		{"ShortBucket", NameValidationStrict, "ab", "a", ErrInvalidBucketName, nil},
+		{"LongBucket", NameValidationStrict, strings.Repeat("a", 64), "a", ErrInvalidBucketName, nil},
+		{"IPBucket", NameValidationStrict, "192.168.1.1", "a", ErrInvalidBucketName, nil},
+		{"UppercaseBucket", NameValidationStrict, "MyBucket", "a", ErrInvalidBucketName, nil},
+		{"UppercaseBucketRelaxed", NameValidationRelaxed, "My_Bucket", "a", nil, nil},
+		{"AdjacentDots", NameValidationRelaxed, "my..bucket", "a", ErrInvalidBucketName, nil},
+		{"EmptyObject", NameValidationStrict, "bucket", "", nil, ErrInvalidObjectName},
+		{"LongObject", NameValidationStrict, "bucket", strings.Repeat("a", 1025), nil, ErrObjectNameTooLong},
+		{"InvalidUTF8", NameValidationRelaxed, "bucket", "a\xffb", nil, ErrInvalidObjectName},
+		{"ControlCharacter", NameValidationStrict, "bucket", "a\x01b", nil, ErrInvalidObjectName},
+		{"ControlCharacterRelaxed", NameValidationRelaxed, "bucket", "a\x01b", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.validation.checkBucketName(tt.bucket); err != tt.bucketErr {
+				t.Fatalf("expected bucket name error %v, but got %v", tt.bucketErr, err)
+			}
+			if err := tt.validation.checkObjectName(tt.object); err != tt.objectErr {
+				t.Fatalf("expected object name error %v, but got %v", tt.objectErr, err)
+			}
+		})
+	}
+	if _, err := newNameValidation("lenient"); err == nil {
+		t.Fatal("expected an error for an unsupported name validation")
+	}
+}
+
+func TestS3X_Names_Badger(t *testing.T) {
+	testS3XNames(t, DSTypeBadger)
+}
+func TestS3X_Names_Crdt(t *testing.T) {
+	testS3XNames(t, DSTypeCrdt)
+}
+func testS3XNames(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, "Invalid_Bucket", minio.BucketOptions{Location: "us-east-1"}); err != (minio.BucketNameInvalid{Bucket: "Invalid_Bucket"}) {
+		t.Fatal("expected err BucketNameInvalid, but got: ", err)
+	}
+	if _, err := gateway.GetBucketInfo(ctx, "Invalid_Bucket"); err == nil {
+		t.Fatal("expected the invalid bucket not to be created")
+	}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	object := "bad\nkey"
+	if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("data")), minio.ObjectOptions{}); err != (minio.ObjectNameInvalid{Bucket: testBucket1, Object: object}) {
+		t.Fatal("expected err ObjectNameInvalid, but got: ", err)
+	}
+	if _, err := gateway.NewMultipartUpload(ctx, testBucket1, strings.Repeat("a", 1025), minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected an error for a too long object name")
+	}
+	if _, err := gateway.CloneBucket(ctx, &CloneBucketRequest{Bucket: testBucket1, NewBucket: "ab"}); err == nil {
+		t.Fatal("expected an error cloning to an invalid bucket name")
+	}
+}
@@ -2,6 +2,7 @@ package s3x
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -11,27 +12,43 @@ import (
 	minio "github.com/RTradeLtd/s3x/cmd"
 )
 
-// ListObjects lists all blobs in S3 bucket filtered by prefix
+// ListObjects lists the blobs in S3 bucket filtered by prefix, after the marker
 func (x *xObjects) ListObjects(
 	ctx context.Context,
 	bucket, prefix, marker, delimiter string,
 	maxKeys int,
 ) (loi minio.ListObjectsInfo, e error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return loi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	defer finish()
 	// TODO(bonedaddy): implement complex search (George: prefix implemented)
-	objs, err := x.ledgerStore.GetObjectInfos(ctx, bucket, prefix, "", 0)
+	objs, last, truncated, err := filterObjectInfos(maxKeys, marker, listingMatcher(minio.GetListingFilter(ctx)),
+		func(after string, n int) ([]ObjectInfo, bool, error) {
+			return x.ledgerStore.GetObjectInfos(ctx, bucket, prefix, after, n)
+		})
 	if err != nil {
-		return loi, x.toMinioErr(err, bucket, "", "")
+		return loi, x.toMinioErr(ctx, err, bucket, "", "")
 	}
 	loi.Objects = make([]minio.ObjectInfo, 0, len(objs))
 	for _, obj := range objs {
 		loi.Objects = append(loi.Objects, getMinioObjectInfo(&obj))
 	}
+	if truncated {
+		loi.IsTruncated = true
+		loi.NextMarker = last
+	}
 	// TODO(bonedaddy): consider if we should use the following helper func
 	// return minio.FromMinioClientListBucketResult(bucket, result), nil
 	return loi, nil
 }
 
-// ListObjectsV2 lists all objects in B2 bucket filtered by prefix, returns upto max 1000 entries at a time.
+// ListObjectsV2 lists the objects in S3 bucket filtered by prefix, after the continuation token or startAfter,
+// pages have at most maxKeys objects and are limited by the batch size of the ledger.
 func (x *xObjects) ListObjectsV2(
 	ctx context.Context,
 	bucket, prefix, continuationToken, delimiter string,
@@ -39,14 +56,45 @@ func (x *xObjects) ListObjectsV2(
 	fetchOwner bool,
 	startAfter string,
 ) (loi minio.ListObjectsV2Info, err error) {
-	objs, err := x.ledgerStore.GetObjectInfos(ctx, bucket, prefix, startAfter, 1000)
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opList)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
 	if err != nil {
-		return loi, x.toMinioErr(err, bucket, "", "")
+		return loi, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	defer finish()
+	// the first page starts a listing session, whose pages list the bucket as it was then
+	session := listingToken{Bucket: bucket, After: startAfter}
+	if continuationToken != "" {
+		if session, err = x.parseListingToken(bucket, continuationToken); err != nil {
+			return loi, err
+		}
+	}
+	if session.Hash == "" {
+		session.Started = x.clock.Now().Unix()
+	}
+	// pages of a filtered listing read the ledger in several batches, the first one fixes the session hash
+	objs, last, truncated, err := filterObjectInfos(maxKeys, session.After, listingMatcher(minio.GetListingFilter(ctx)),
+		func(after string, n int) (objs []ObjectInfo, truncated bool, err error) {
+			objs, session.Hash, truncated, err = x.ledgerStore.GetObjectInfosAt(ctx, bucket, session.Hash, prefix, after, n)
+			return objs, truncated, err
+		})
+	if err != nil {
+		return loi, x.toMinioErr(ctx, err, bucket, "", "")
 	}
 	loi.Objects = make([]minio.ObjectInfo, 0, len(objs))
 	for _, obj := range objs {
 		loi.Objects = append(loi.Objects, getMinioObjectInfo(&obj))
 	}
+	loi.ContinuationToken = continuationToken
+	if truncated {
+		loi.IsTruncated = true
+		session.Bucket, session.After = bucket, last
+		if loi.NextContinuationToken, err = x.signListingToken(session); err != nil {
+			return loi, x.toMinioErr(ctx, err, bucket, "", "")
+		}
+	}
 	return loi, nil
 }
 
@@ -59,24 +107,78 @@ func (x *xObjects) GetObjectNInfo(
 	lockType minio.LockType,
 	opts minio.ObjectOptions,
 ) (gr *minio.GetObjectReader, err error) {
-	objinfo, err := x.GetObjectInfo(ctx, bucket, object, opts)
+	x.meter.request(ctx)
+	// the deadline covers reading the object, and is released when the reader is closed
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+	// the slot is held while the object is streamed, and is released when the reader is closed
+	finish, err := x.scheduler.admit(ctx, priorityBulk)
 	if err != nil {
-		return gr, err // the error from this is already properly converted
+		return gr, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	defer func() {
+		if err != nil {
+			finish()
+		}
+	}()
+	// the object is resolved once, so its info and data belong to the same version even if it is overwritten
+	// while it is read, the data of the replaced version is kept for the gc grace period
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return gr, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	oi := &obj.ObjectInfo
+	objinfo := getMinioObjectInfo(oi)
+	config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return gr, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	decode := shouldDecodeGzip(config, oi, h)
+	if decode {
+		objinfo.ContentEncoding = ""
+		objinfo.Size = oi.GetDecodedSize()
 	}
 	var startOffset, length int64
 	startOffset, length, err = rs.GetOffsetLength(objinfo.Size)
 	if err != nil {
 		return nil, err
 	}
-	pr, pw := io.Pipe()
-	go func() {
-		err := x.GetObject(ctx, bucket, object, startOffset, length, pw, objinfo.ETag, opts)
-		_ = pw.CloseWithError(err)
-	}()
-	// Setup cleanup function to cause the above go-routine to
-	// exit in case of partial read
-	pipeCloser := func() { pr.Close() }
-	return minio.NewGetObjectReaderFromReader(pr, objinfo, opts.CheckCopyPrecondFn, pipeCloser)
+	tracked := x.streams.open(ctx, bucket, object)
+	stream := newObjectStream(func(w io.Writer) error {
+		if opts.CheckCopyPrecondFn == nil {
+			// copy sources are read within the gateway
+			w = x.bandwidth.writer(ctx, bucket, config, w)
+		}
+		if !decode {
+			return x.readObject(ctx, bucket, object, obj, startOffset, length, w, opts)
+		}
+		// the whole stored object is needed to decompress any range of it
+		epr, epw := io.Pipe()
+		exited := tracked.goroutineStarted()
+		go func() {
+			defer exited()
+			err := x.readObject(ctx, bucket, object, obj, 0, 0, epw, opts)
+			_ = epw.CloseWithError(err)
+		}()
+		_, err := decompressCopy(w, epr, compressionGzip, startOffset, length)
+		_ = epr.Close()
+		return err
+	}, x.getBufferSize)
+	stream.tracked = tracked
+	// Setup cleanup function to stop the download in case of
+	// partial read, and count the bytes that were read
+	streamCloser := func() {
+		_ = stream.Close()
+		cancel()
+		finish()
+		x.meter.transfer(ctx, bucket, 0, stream.n)
+		tracked.close()
+	}
+	return minio.NewGetObjectReaderFromReader(stream, objinfo, opts.CheckCopyPrecondFn, streamCloser)
 }
 
 // GetObject reads an object from TemporalX. Supports additional
@@ -85,6 +187,7 @@ func (x *xObjects) GetObjectNInfo(
 //
 // startOffset indicates the starting read location of the object.
 // length indicates the total length of the object.
+// etag fails the read with InvalidETag if the object was replaced, unless it is empty.
 func (x *xObjects) GetObject(
 	ctx context.Context,
 	bucket, object string,
@@ -93,10 +196,38 @@ func (x *xObjects) GetObject(
 	etag string,
 	opts minio.ObjectOptions,
 ) error {
-	fileHash, size, err := x.ledgerStore.GetObjectDataHash(ctx, bucket, object)
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityBulk)
+	if err != nil {
+		return x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	defer finish()
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
 	if err != nil {
-		return x.toMinioErr(err, bucket, object, "")
+		return x.toMinioErr(ctx, err, bucket, object, "")
 	}
+	if etag != "" && etag != s3ETag(obj.ObjectInfo.GetEtag()) {
+		return minio.InvalidETag{}
+	}
+	config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	return x.readObject(ctx, bucket, object, obj, startOffset, length, x.bandwidth.writer(ctx, bucket, config, writer), opts)
+}
+
+// readObject reads the data of a resolved version of an object, so the data matches its info
+func (x *xObjects) readObject(
+	ctx context.Context,
+	bucket, object string,
+	obj *Object,
+	startOffset, length int64,
+	writer io.Writer,
+	opts minio.ObjectOptions,
+) error {
+	fileHash, size := obj.GetDataHash(), obj.ObjectInfo.GetSize_()
 	if size < startOffset+length {
 		return minio.InvalidRange{
 			OffsetBegin:  startOffset,
@@ -104,8 +235,29 @@ func (x *xObjects) GetObject(
 			ResourceSize: size,
 		}
 	}
-	if _, err := ipfsFileDownload(ctx, x.fileClient, writer, fileHash, startOffset, length); err != nil {
-		return x.toMinioErr(err, bucket, object, "")
+	fileClient, _, err := x.dataClients(fileHash)
+	if err != nil {
+		return x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	c := obj.ObjectInfo.GetCompression()
+	switch {
+	case obj.Erasure != nil && c != "":
+		_, err = x.erasureDecompressedDownload(ctx, writer, obj.Erasure, c, startOffset, length)
+	case obj.Erasure != nil:
+		if x.erasure == nil {
+			return x.toMinioErr(ctx, errErasureNotConfigured, bucket, object, "")
+		}
+		_, err = x.erasure.download(ctx, writer, obj.Erasure, startOffset, length)
+	case c != "":
+		_, err = ipfsDecompressedDownload(ctx, fileClient, writer, fileHash, c, startOffset, length)
+	case opts.CheckCopyPrecondFn != nil && startOffset == 0 && length == size:
+		// copy sources are read whole, so their blocks are downloaded in parallel
+		_, err = x.parallelDownload(ctx, writer, fileHash)
+	default:
+		_, err = ipfsFileDownload(ctx, fileClient, writer, fileHash, startOffset, length)
+	}
+	if err != nil {
+		return x.toMinioErr(ctx, err, bucket, object, "")
 	}
 	return nil
 }
@@ -116,20 +268,26 @@ func (x *xObjects) GetObjectInfo(
 	bucket, object string,
 	opts minio.ObjectOptions,
 ) (objInfo minio.ObjectInfo, err error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityInteractive)
+	if err != nil {
+		return objInfo, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	defer finish()
 	oi, err := x.ledgerStore.ObjectInfo(ctx, bucket, object)
-	return getMinioObjectInfo(oi), x.toMinioErr(err, bucket, object, "")
+	return getMinioObjectInfo(oi), x.toMinioErr(ctx, err, bucket, object, "")
 }
 
 //newObjectInfo create an ObjectInfo
-func newObjectInfo(bucket, object string, size int, opts minio.ObjectOptions) ObjectInfo {
+func newObjectInfo(bucket, object string, size int, opts minio.ObjectOptions, modTime time.Time) ObjectInfo {
 	// TODO(bonedaddy): ensure consistency with the way s3 and b2 handle this
 	obinfo := ObjectInfo{
-		Bucket: bucket,
-		Name:   object,
-		Size_:  int64(size),
-	}
-	if !isTest { // creates consistent hashes for testing
-		obinfo.ModTime = time.Now().UTC()
+		Bucket:  bucket,
+		Name:    object,
+		Size_:   int64(size),
+		ModTime: modTime.UTC(),
 	}
 	for k, v := range opts.UserDefined {
 		switch strings.ToLower(k) {
@@ -141,6 +299,17 @@ func newObjectInfo(bucket, object string, size int, opts minio.ObjectOptions) Ob
 			obinfo.ContentLanguage = v
 		case "content-type":
 			obinfo.ContentType = v
+		default:
+			// user metadata and tags are kept, so they are returned on reads and can be searched,
+			// the sealed keys of encrypted objects, so they can be decrypted, the checksum
+			// verified by the handlers, so it is returned on reads, and the object lock, so the
+			// data of locked objects is not collected
+			if isUserMetadataKey(k) || isEncryptionMetadataKey(k) || isChecksumMetadataKey(k) || isObjectLockMetadataKey(k) {
+				if obinfo.UserDefined == nil {
+					obinfo.UserDefined = make(map[string]string)
+				}
+				obinfo.UserDefined[k] = v
+			}
 		}
 	}
 	return obinfo
@@ -154,21 +323,161 @@ func (x *xObjects) PutObject(
 	r *minio.PutObjReader,
 	opts minio.ObjectOptions,
 ) (minio.ObjectInfo, error) {
-	err := x.ledgerStore.AssertBucketExits(bucket)
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	finish, err := x.scheduler.admit(ctx, priorityBulk)
+	if err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	defer finish()
+	return x.putObject(ctx, bucket, object, r, opts, nil)
+}
+
+// putObject stores the data of r as an object, copies that store new data pass their progress,
+// which counts the bytes read from r and uploads the data in parallel chunks
+func (x *xObjects) putObject(
+	ctx context.Context,
+	bucket, object string,
+	r *minio.PutObjReader,
+	opts minio.ObjectOptions,
+	progress *copyProgress,
+) (minio.ObjectInfo, error) {
+	if err := x.names.checkObjectName(object); err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	if err := x.limits.checkObjectSize(r.Size()); err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	if err := x.limits.checkMetadata(opts.UserDefined); err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	pin, err := pinMode(opts.UserDefined)
 	if err != nil {
-		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, "", "")
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
 	}
-	hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+	var idempotency string
+	if progress == nil {
+		// copies are not retried with the key of their request
+		if idempotency, err = idempotencyKey(opts.UserDefined); err != nil {
+			return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+		}
+	}
+	config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
 	if err != nil {
-		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, "", "")
 	}
-	obinfo := newObjectInfo(bucket, object, size, opts)
-	err = x.ledgerStore.PutObject(ctx, bucket, object, &Object{
-		DataHash:   hash,
-		ObjectInfo: obinfo,
-	})
+	release, err := x.capacity.reserve(r.Size())
 	if err != nil {
-		return minio.ObjectInfo{}, x.toMinioErr(err, bucket, object, "")
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	var stored int64
+	defer func() { release(stored) }()
+	var (
+		data    io.Reader = &limitReader{r: r, limit: x.limits.objectSize, err: ErrObjectTooLarge}
+		counter *countingReader
+		decoded *decodedSizeCounter
+	)
+	if progress != nil {
+		data = progress.reader(data)
+	} else {
+		data = x.bandwidth.reader(ctx, bucket, config, data)
+		spooled, remove, err := x.spool.spool(data, r.Size())
+		if err != nil {
+			return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+		}
+		defer remove()
+		data = spooled
+	}
+	if isGzipEncoding(userDefinedValue(opts.UserDefined, "content-encoding")) {
+		decoded = newDecodedSizeCounter()
+		data = io.TeeReader(data, decoded)
+	}
+	if config.GetCompression() != "" {
+		counter = &countingReader{r: data}
+		cr, err := compressReader(config.GetCompression(), counter)
+		if err != nil {
+			return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+		}
+		defer cr.Close()
+		data = cr
+	}
+	var (
+		hash         string
+		size         int
+		erasure      *ErasureInfo
+		replicaNodes []string
+		chunkedBy    string
+	)
+	switch {
+	case x.erasure != nil:
+		erasure, err = x.erasure.upload(ctx, data)
+		size = int(erasure.GetSize_())
+	case config.GetChunker() != "":
+		var c chunker
+		if c, err = newChunker(config.GetChunker(), data); err == nil {
+			hash, size, err = x.uploadChunks(ctx, c)
+			chunkedBy = config.GetChunker()
+		}
+	case progress != nil && config.GetReplicationFactor() <= 1:
+		hash, size, err = x.uploadChunks(ctx, &fixedChunker{r: data, size: chunkSize})
+		chunkedBy = fmt.Sprintf("size-%d", chunkSize)
+	default:
+		hash, size, replicaNodes, err = x.replicatedUpload(ctx, bucket+"/"+object, data, config.GetReplicationFactor())
+	}
+	var decodedSize int64
+	if decoded != nil {
+		decodedSize = decoded.Size()
+	}
+	var pinStatus *PinStatus
+	if err == nil && hash != "" && pin == pinModeImmediate {
+		pinStatus, err = x.pinData(ctx, hash)
+	}
+	if err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	obinfo := newObjectInfo(bucket, object, size, opts, x.clock.Now())
+	// the md5 of the uploaded data, so overwrites with other data change the ETag
+	obinfo.Etag = r.MD5CurrentHexString()
+	obinfo.Unpinned = hash != "" && pin == pinModeNone
+	obinfo.DecodedSize = decodedSize
+	obinfo.StorageClass = replicationStorageClass(int64(len(replicaNodes)) + 1)
+	obinfo.Chunker = chunkedBy
+	if counter != nil {
+		obinfo.Size_ = counter.n
+		obinfo.Compression = config.GetCompression()
+		obinfo.CompressedSize = int64(size)
+	}
+	obj := &Object{
+		DataHash:     hash,
+		ObjectInfo:   obinfo,
+		Erasure:      erasure,
+		ReplicaNodes: replicaNodes,
+	}
+	if idempotency != "" {
+		recorded, replayed, err := x.ledgerStore.PutObjectIdempotent(ctx, bucket, object, idempotency, obj)
+		if err != nil {
+			return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+		}
+		if replayed {
+			// the retry stored nothing new, so it is neither metered nor counted against the capacity
+			log.Printf("bucket-name: %s, object-name: %s, file-hash: %s, replayed upload", bucket, object, hash)
+			return getMinioObjectInfo(recorded), nil
+		}
+	} else if err := x.ledgerStore.PutObject(ctx, bucket, object, obj); err != nil {
+		return minio.ObjectInfo{}, x.toMinioErr(ctx, err, bucket, object, "")
+	}
+	stored = obinfo.Size_
+	x.meter.transfer(ctx, bucket, obinfo.Size_, 0)
+	switch {
+	case pinStatus != nil:
+		if err := x.ledgerStore.PutPinStatus(hash, pinStatus); err != nil {
+			log.Printf("bucket-name: %s, object-name: %s, failed to record pin status: %v", bucket, object, err)
+		}
+	case hash != "" && pin == pinModeAsync:
+		if err := x.queuePin(hash); err != nil {
+			log.Printf("bucket-name: %s, object-name: %s, failed to queue pin: %v", bucket, object, err)
+		}
 	}
 	log.Printf("bucket-name: %s, object-name: %s, file-hash: %s", bucket, object, hash)
 	return getMinioObjectInfo(&obinfo), nil
@@ -184,9 +493,28 @@ func (x *xObjects) CopyObject(
 	srcInfo minio.ObjectInfo,
 	srcOpts, dstOpts minio.ObjectOptions,
 ) (objInfo minio.ObjectInfo, err error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
 	// TODO(bonedaddy): implement usage of options
 	// TODO(bonedaddy): ensure we properly update the ledger with the destination object
 	// TODO(bonedaddy): ensure the destination object is properly adjusted with metadata
+	if err := x.names.checkObjectName(dstObject); err != nil {
+		return objInfo, x.toMinioErr(ctx, err, dstBucket, dstObject, "")
+	}
+	if srcInfo.PutObjReader != nil {
+		src, err := x.ledgerStore.ObjectInfo(ctx, srcBucket, srcObject)
+		if err != nil {
+			return objInfo, x.toMinioErr(ctx, err, srcBucket, srcObject, "")
+		}
+		if copyTouchesData(src, srcInfo.UserDefined) {
+			// the handlers stream the re-encrypted source through PutObjReader, which is stored
+			// as a new object, before the bucket locks are taken since reading it reads the source
+			p := x.copies.start(srcBucket, srcObject, dstBucket, dstObject, srcInfo.PutObjReader.Size(), x.clock.Now())
+			defer x.copies.finish(p)
+			return x.putObject(ctx, dstBucket, dstObject, srcInfo.PutObjReader, minio.ObjectOptions{UserDefined: srcInfo.UserDefined}, p)
+		}
+	}
 
 	//lock ordering by bucket name
 	if srcBucket == dstBucket {
@@ -202,42 +530,36 @@ func (x *xObjects) CopyObject(
 	// ensure destination bucket exists
 	err = x.ledgerStore.assertBucketExits(dstBucket)
 	if err != nil {
-		return objInfo, x.toMinioErr(err, dstBucket, "", "")
+		return objInfo, x.toMinioErr(ctx, err, dstBucket, "", "")
 	}
 
 	obj1, err := x.ledgerStore.object(ctx, srcBucket, srcObject)
 	if err != nil {
-		return objInfo, x.toMinioErr(err, srcBucket, srcObject, "")
+		return objInfo, x.toMinioErr(ctx, err, srcBucket, srcObject, "")
 	}
 	if obj1 == nil {
-		return objInfo, x.toMinioErr(ErrLedgerObjectDoesNotExist, srcBucket, srcObject, "")
+		return objInfo, x.toMinioErr(ctx, ErrLedgerObjectDoesNotExist, srcBucket, srcObject, "")
 	}
 
-	//copy object so the original will not be modified
-	data, err := obj1.Marshal()
-	if err != nil {
-		panic(err)
-	}
-	obj := &Object{}
-	if err = obj.Unmarshal(data); err != nil {
-		panic(err)
-	}
+	// objects are decoded from ipfs on every read, so obj is not shared and can be modified,
+	// the destination references the same data hash as the source, so no object data is copied
+	obj := obj1
 
 	// update relevant fields
 	obj.ObjectInfo.Name = dstObject
 	obj.ObjectInfo.Bucket = dstBucket
-	obj.ObjectInfo.ModTime = time.Now().UTC()
+	obj.ObjectInfo.ModTime = x.clock.Now().UTC()
 
 	err = x.ledgerStore.putObject(ctx, dstBucket, dstObject, obj)
 	if err != nil {
-		return objInfo, x.toMinioErr(err, dstBucket, dstObject, "")
+		return objInfo, x.toMinioErr(ctx, err, dstBucket, dstObject, "")
 	}
 	log.Printf(
 		"dst-bucket: %s,  dst-object: %s\n",
 		dstBucket, dstObject,
 	)
 	objInfo = getMinioObjectInfo(&obj.ObjectInfo)
-	return objInfo, x.toMinioErr(err, dstBucket, dstObject, "")
+	return objInfo, x.toMinioErr(ctx, err, dstBucket, dstObject, "")
 }
 
 // DeleteObject deletes a blob in bucket
@@ -245,8 +567,11 @@ func (x *xObjects) DeleteObject(
 	ctx context.Context,
 	bucket, object string,
 ) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
 	err := x.ledgerStore.RemoveObject(ctx, bucket, object)
-	return x.toMinioErr(err, bucket, object, "")
+	return x.toMinioErr(ctx, err, bucket, object, "")
 }
 
 func (x *xObjects) DeleteObjects(
@@ -254,14 +579,53 @@ func (x *xObjects) DeleteObjects(
 	bucket string,
 	objects []string,
 ) ([]error, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
 	missing, err := x.ledgerStore.RemoveObjects(ctx, bucket, objects...)
 	if err != nil {
-		return nil, x.toMinioErr(err, bucket, "", "")
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
 	}
 	// TODO(bonedaddy): implement removal from ipfs
-	errs := make([]error, len(missing))
-	for i, m := range missing {
-		errs[i] = x.toMinioErr(ErrLedgerObjectDoesNotExist, bucket, m, "")
+	isMissing := make(map[string]bool, len(missing))
+	for _, m := range missing {
+		isMissing[m] = true
+	}
+	// errors are returned in the order of objects
+	errs := make([]error, len(objects))
+	for i, o := range objects {
+		if isMissing[o] {
+			errs[i] = x.toMinioErr(ctx, ErrLedgerObjectDoesNotExist, bucket, o, "")
+		}
+	}
+	return errs, nil
+}
+
+// DeleteObjectVersions permanently removes noncurrent versions of objects,
+// the errors are returned in the order of objects.
+func (x *xObjects) DeleteObjectVersions(
+	ctx context.Context,
+	bucket string,
+	objects []minio.ObjectIdentifier,
+) ([]error, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	names := make([]string, len(objects))
+	versionIDs := make([]string, len(objects))
+	for i, o := range objects {
+		names[i], versionIDs[i] = o.ObjectName, o.VersionID
+	}
+	lerrs, unreferenced, err := x.ledgerStore.RemoveObjectVersions(ctx, bucket, names, versionIDs)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	if len(unreferenced) > 0 {
+		log.Printf("bucket-name: %s, versions-deleted: %v, unreferenced-data: %v", bucket, len(objects), unreferenced)
+	}
+	errs := make([]error, len(objects))
+	for i, o := range objects {
+		errs[i] = x.toMinioErr(ctx, lerrs[i], bucket, o.ObjectName, o.VersionID)
 	}
 	return errs, nil
 }
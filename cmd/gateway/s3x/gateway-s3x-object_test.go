@@ -3,8 +3,12 @@ package s3x
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"math"
+	"strings"
 	"testing"
 
 	minio "github.com/RTradeLtd/s3x/cmd"
@@ -87,7 +91,7 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 		bucketName, objectName string
 	}
 	// setup test bucket
-	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
 		t.Fatal(err)
 	}
 	t.Run("PutObject", func(t *testing.T) {
@@ -233,10 +237,47 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 			})
 		}
 	})
+	t.Run("GetObjectNInfo/Ranges", func(t *testing.T) {
+		data := "0123456789"
+		if _, err := gateway.PutObject(ctx, testBucket1, "ranges", getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name string
+			rs   *minio.HTTPRangeSpec
+			want string
+		}{
+			{"Closed", &minio.HTTPRangeSpec{Start: 2, End: 4}, "234"},
+			{"Open-Ended", &minio.HTTPRangeSpec{Start: 6, End: -1}, "6789"},
+			{"Open-Ended-Last-Byte", &minio.HTTPRangeSpec{Start: 9, End: -1}, "9"},
+			{"End-Past-Size", &minio.HTTPRangeSpec{Start: 8, End: 100}, "89"},
+			{"Suffix", &minio.HTTPRangeSpec{IsSuffixLength: true, Start: -3, End: -1}, "789"},
+			{"Suffix-Past-Size", &minio.HTTPRangeSpec{IsSuffixLength: true, Start: -100, End: -1}, data},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				gr, err := gateway.GetObjectNInfo(ctx, testBucket1, "ranges", tt.rs, nil, 0, minio.ObjectOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer gr.Close()
+				got, err := ioutil.ReadAll(gr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Fatalf("expected %q, but got %q", tt.want, got)
+				}
+			})
+		}
+		if _, err := gateway.GetObjectNInfo(ctx, testBucket1, "ranges", &minio.HTTPRangeSpec{Start: 10, End: -1}, nil, 0, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected a range starting after the end of the object to fail")
+		}
+	})
 	t.Run("CopyObject", func(t *testing.T) {
-		dstBucket := "dstBucket"
+		dstBucket := "dstbucket"
 		dstObject := "dstObject"
-		err := gateway.MakeBucketWithLocation(ctx, dstBucket, "")
+		err := gateway.MakeBucketWithLocation(ctx, dstBucket, minio.BucketOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -250,6 +291,24 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 		if info.Name != dstObject {
 			t.Fatal("expected destination object name, got:", info.Name)
 		}
+		srcHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dstHash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, dstBucket, dstObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if srcHash != dstHash {
+			t.Fatalf("expected copy to reference data hash %v, but got %v", srcHash, dstHash)
+		}
+		refs, err := gateway.ledgerStore.DataRefCount(srcHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refs != 2 {
+			t.Fatalf("expected 2 references to copied data, but got %v", refs)
+		}
 	})
 	t.Run("DeleteObject", func(t *testing.T) {
 		err := gateway.DeleteObject(ctx, testBucket1, testObject1)
@@ -274,12 +333,53 @@ func testS3XGObject(t *testing.T, dsType DSType) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(errs) != 1 {
-			t.Fatal("expected one missing object, but go errors: ", errs)
+		if len(errs) != len(list) {
+			t.Fatal("expected an error for each object, but got errors: ", errs)
+		}
+		if errs[0] != nil {
+			t.Fatal("expected the object to be deleted, but got: ", errs[0])
+		}
+		if _, ok := errs[1].(minio.ObjectNotFound); !ok {
+			t.Fatal("expected err ObjectNotFound for the missing object, but got: ", errs[1])
 		}
 	})
 }
 
+func TestS3X_ObjectChecksums(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	opts := minio.ObjectOptions{UserDefined: map[string]string{"x-amz-checksum-crc32": "y/Q5Jg=="}}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("123456789")), opts); err != nil {
+		t.Fatal(err)
+	}
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.UserDefined["x-amz-checksum-crc32"] != "y/Q5Jg==" {
+		t.Fatalf("expected the checksum to be stored, but got %v", info.UserDefined)
+	}
+	// the checksum no longer matches appended data
+	if _, err := gateway.AppendObject(ctx, &AppendObjectRequest{Bucket: testBucket1, Object: testObject1, Data: []byte("0")}); err != nil {
+		t.Fatal(err)
+	}
+	info, err = gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.UserDefined["x-amz-checksum-crc32"]; ok {
+		t.Fatalf("expected the checksum to be dropped, but got %v", info.UserDefined)
+	}
+}
+
 func getTestHashReader(t testing.TB, input io.Reader, size int64) *hash.Reader {
 	r, err := hash.NewReader(input, size, "", "", size, false)
 	if err != nil {
@@ -296,3 +396,88 @@ func getTestPutObjectReader(t testing.TB, data []byte) *minio.PutObjReader {
 			int64(len(data)),
 		), nil, nil)
 }
+
+func TestS3X_ReadDuringOverwrite(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	versions := []string{"first version", "the second, longer version of the object"}
+	// the data is uploaded with its md5, so each version has the same ETag every time it is uploaded
+	upload := func(data string) (minio.ObjectInfo, error) {
+		sum := md5.Sum([]byte(data))
+		r, err := hash.NewReader(strings.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]), "", int64(len(data)), false)
+		if err != nil {
+			return minio.ObjectInfo{}, err
+		}
+		return gateway.PutObject(ctx, testBucket1, testObject1, minio.NewPutObjReader(r, nil, nil), minio.ObjectOptions{})
+	}
+	put := func(data string) minio.ObjectInfo {
+		t.Helper()
+		info, err := upload(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	read := func(gr *minio.GetObjectReader) string {
+		t.Helper()
+		defer gr.Close()
+		data, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	first := put(versions[0])
+
+	t.Run("Overwritten-Before-Read", func(t *testing.T) {
+		gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, nil, 0, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		put(versions[1])
+		if got := read(gr); got != versions[0] || gr.ObjInfo.ETag != first.ETag {
+			t.Fatalf("expected the first version %q with ETag %v, but got %q with ETag %v", versions[0], first.ETag, got, gr.ObjInfo.ETag)
+		}
+	})
+	t.Run("Stale-ETag", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 1, &buf, first.ETag, minio.ObjectOptions{}); err != (minio.InvalidETag{}) {
+			t.Fatalf("expected %v, but got %v", minio.InvalidETag{}, err)
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		etags := make(map[string]string)
+		for _, v := range versions {
+			etags[put(v).ETag] = v
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 20; i++ {
+				if _, err := upload(versions[i%2]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		defer func() { <-done }()
+		for i := 0; i < 20; i++ {
+			gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, &minio.HTTPRangeSpec{Start: 1, End: -1}, nil, 0, minio.ObjectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := etags[gr.ObjInfo.ETag]
+			if got := read(gr); want == "" || got != want[1:] {
+				t.Fatalf("expected the data of ETag %v, but got %q", gr.ObjInfo.ETag, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+/* Design Notes
+---------------
+
+The data of objects is pinned on the TemporalX node with Persist before PutObject returns. High throughput
+ingest of transient data can pass the x-s3x-pin header to skip the wait: "async" queues the data to be pinned by
+the pin queue after the object was stored, and "false" stores the data unpinned, so its pins are neither checked
+nor repaired. Pinning writes the pin status of the data, so GetObjectPinStatus reports when asynchronously pinned
+data was pinned.
+
+Only PutObject reads the header, multipart uploads, copies, and appends pin their data as before. Erasure coded
+data is spread across nodes as shards, so it is not pinned.
+*/
+
+const (
+	// pinModeImmediate pins data before the upload returns, the default
+	pinModeImmediate = "true"
+	// pinModeAsync pins data after the upload returned
+	pinModeAsync = "async"
+	// pinModeNone stores data unpinned
+	pinModeNone = "false"
+)
+
+// pinMode returns the pin mode selected by the x-s3x-pin header of an upload
+func pinMode(userDefined map[string]string) (string, error) {
+	switch mode := strings.ToLower(userDefinedValue(userDefined, xhttp.S3xPin)); mode {
+	case "":
+		return pinModeImmediate, nil
+	case pinModeImmediate, pinModeAsync, pinModeNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidPinMode, mode)
+	}
+}
+
+// pinData pins data on the TemporalX node that stores it, and returns its pin status
+func (x *xObjects) pinData(ctx context.Context, hash string) (*PinStatus, error) {
+	_, dagClient, err := x.dataClients(hash)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := dagClient.Persist(ctx, &pb.PersistRequest{Cids: []string{hash}})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.GetStatus()[hash] {
+		return nil, fmt.Errorf("failed to pin %v: %s", hash, resp.GetErrors()[hash])
+	}
+	return &PinStatus{Pinned: true, Checked: x.clock.Now().UTC()}, nil
+}
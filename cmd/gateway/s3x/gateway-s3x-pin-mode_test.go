@@ -0,0 +1,84 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_PinMode(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gateway.clock = fixedClock(now)
+	put := func(object, mode string) error {
+		_, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("pin mode "+object)), minio.ObjectOptions{
+			UserDefined: map[string]string{"X-S3x-Pin": mode},
+		})
+		return err
+	}
+	// pinStatus returns the recorded pin status of the data of an object
+	pinStatus := func(object string) (*PinStatus, bool) {
+		t.Helper()
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, ok, err := gateway.ledgerStore.PinStatus(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, ok
+	}
+	if err := put("invalid", "later"); err != (minio.UnsupportedMetadata{}) {
+		t.Fatalf("expected %v, but got %v", minio.UnsupportedMetadata{}, err)
+	}
+	for _, tt := range []struct{ object, mode string }{{"default", ""}, {"immediate", "TRUE"}, {"async", "async"}, {"unpinned", "false"}} {
+		if err := put(tt.object, tt.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, object := range []string{"default", "immediate"} {
+		if s, ok := pinStatus(object); !ok || !s.Pinned || !s.Checked.Equal(now) {
+			t.Fatalf("expected %s to be pinned before the upload returned, but got %+v", object, s)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if s, ok := pinStatus("async"); ok && s.Pinned {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the async object to be pinned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := pinStatus("unpinned"); ok {
+		t.Fatal("expected the unpinned object to have no pin status")
+	}
+	obj, err := gateway.ledgerStore.Object(ctx, testBucket1, "unpinned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !obj.ObjectInfo.Unpinned || len(obj.ObjectInfo.UserDefined) != 0 {
+		t.Fatalf("expected the object to be unpinned without the header in its metadata, but got %+v", obj.ObjectInfo)
+	}
+	// unpinned data is not checked
+	resp, err := gateway.VerifyBucketPins(ctx, &VerifyBucketPinsRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Checked != 3 {
+		t.Fatalf("expected 3 checked objects, but got %v", resp.Checked)
+	}
+}
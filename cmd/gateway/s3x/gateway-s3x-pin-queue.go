@@ -0,0 +1,186 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/* Design Notes
+---------------
+
+Uploads with asynchronous pinning queue their data in the ledger, and pinQueueLoop pins the queued data with at
+most pinWorkers concurrent Persist calls. The loop wakes when data was queued, and at least every
+pinQueueInterval to retry failed attempts once their backoff passed. The backoff doubles with each failed attempt,
+up to pinQueueMaxBackoff, and data that failed pinQueueMaxAttempts times leaves the queue with a lost pin status,
+so the pin check can repair it like any other lost pin.
+
+The queue is read in batches of batchSize due entries, so a large queue is never held in memory, and the depth of
+the queue is published as a metric after every pass.
+*/
+
+const (
+	// pinQueueInterval is how often failed attempts to pin queued data are retried
+	pinQueueInterval = 10 * time.Second
+	// pinQueueBackoff is how long the first retry of queued data waits, doubled with each failed attempt
+	pinQueueBackoff = 30 * time.Second
+	// pinQueueMaxBackoff is the longest wait between attempts to pin queued data
+	pinQueueMaxBackoff = time.Hour
+	// pinQueueMaxAttempts is how often pinning queued data fails before it is recorded as lost
+	pinQueueMaxAttempts = 10
+	// defaultPinQueueWorkers is how many queued uploads are pinned at the same time by default
+	defaultPinQueueWorkers = 4
+)
+
+var (
+	pinQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "pins",
+		Name:      "queue_depth",
+		Help:      "Number of uploads waiting to be pinned asynchronously",
+	})
+	pinAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3x",
+		Subsystem: "pins",
+		Name:      "attempts_total",
+		Help:      "Total number of attempts to pin queued uploads by result",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(pinQueueDepth, pinAttemptsTotal)
+}
+
+// GetPinQueue returns the depth of the queue of data waiting to be pinned asynchronously
+func (x *xObjects) GetPinQueue(ctx context.Context, req *PinQueueRequest) (*PinQueueResponse, error) {
+	stats, err := x.ledgerStore.PinQueueStats(ctx)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp := &PinQueueResponse{
+		Depth:    stats.depth,
+		Retrying: stats.retrying,
+	}
+	if !stats.oldestQueued.IsZero() {
+		resp.OldestQueued = stats.oldestQueued.Unix()
+	}
+	return resp, nil
+}
+
+// queuePin queues data to be pinned asynchronously and wakes the pin queue
+func (x *xObjects) queuePin(hash string) error {
+	if err := x.ledgerStore.EnqueuePin(hash, x.clock.Now().UTC()); err != nil {
+		return err
+	}
+	select {
+	case x.pinWake <- struct{}{}:
+	default: // the queue is already woken
+	}
+	return nil
+}
+
+// pinQueueLoop pins queued data when data is queued, and retries failed attempts every interval,
+// until the gateway is shut down
+func (x *xObjects) pinQueueLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-x.pinWake:
+		}
+		if err := x.processPinQueue(x.ctx, x.clock.Now().UTC()); err != nil && x.ctx.Err() == nil {
+			log.Printf("failed to process pin queue: %v", err)
+		}
+	}
+}
+
+// processPinQueue pins the queued data whose next attempt is due at now, a batch at a time,
+// and publishes the depth of the queue
+func (x *xObjects) processPinQueue(ctx context.Context, now time.Time) error {
+	for {
+		var (
+			batch   []string
+			entries = make(map[string]*PinQueueEntry)
+			depth   int64
+		)
+		err := x.ledgerStore.ForEachQueuedPin(ctx, func(hash string, e *PinQueueEntry) error {
+			depth++
+			if e.NextAttempt.After(now) || len(batch) >= x.ledgerStore.batchSize {
+				return nil
+			}
+			batch = append(batch, hash)
+			entries[hash] = e
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		pinQueueDepth.Set(float64(depth))
+		if len(batch) == 0 {
+			return nil
+		}
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			lastErr error
+			sem     = make(chan struct{}, x.pinWorkers)
+		)
+		for _, hash := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(hash string, e *PinQueueEntry) {
+				defer func() { <-sem; wg.Done() }()
+				if err := x.pinQueued(ctx, hash, e, now); err != nil {
+					mu.Lock()
+					lastErr = err
+					mu.Unlock()
+				}
+			}(hash, entries[hash])
+		}
+		wg.Wait()
+		// pinned data left the queue, and failed attempts are not due until their backoff passed,
+		// so the next batch only holds entries that were not tried yet
+		if lastErr != nil {
+			return lastErr
+		}
+	}
+}
+
+// pinQueued pins queued data, and removes it from the queue with its pin status, or schedules another attempt
+func (x *xObjects) pinQueued(ctx context.Context, hash string, e *PinQueueEntry, now time.Time) error {
+	s, err := x.pinData(ctx, hash)
+	if err == nil {
+		pinAttemptsTotal.WithLabelValues("success").Inc()
+		return x.ledgerStore.FinishPin(hash, s)
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	pinAttemptsTotal.WithLabelValues("failure").Inc()
+	e.Attempts++
+	e.LastError = err.Error()
+	if e.Attempts >= pinQueueMaxAttempts {
+		log.Printf("file-hash: %s, failed to pin after %v attempts: %v", hash, e.Attempts, err)
+		return x.ledgerStore.FinishPin(hash, &PinStatus{Checked: now, Error: e.LastError})
+	}
+	e.NextAttempt = now.Add(pinQueueRetryBackoff(e.Attempts))
+	return x.ledgerStore.RetryPin(hash, e)
+}
+
+// pinQueueRetryBackoff returns how long to wait after a number of failed attempts to pin queued data
+func pinQueueRetryBackoff(attempts int64) time.Duration {
+	backoff := pinQueueBackoff
+	for i := int64(1); i < attempts && backoff < pinQueueMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > pinQueueMaxBackoff {
+		return pinQueueMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,154 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+)
+
+// failingPersistClient fails to pin data on a TemporalX node
+type failingPersistClient struct {
+	pb.NodeAPIClient
+}
+
+func (c failingPersistClient) Persist(ctx context.Context, in *pb.PersistRequest, opts ...grpc.CallOption) (*pb.PersistResponse, error) {
+	return nil, errors.New("node unavailable")
+}
+
+func TestS3X_PinQueue(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gateway.clock = fixedClock(now)
+	// the data is stored unpinned, and queued without waking the pin queue, so only the test processes it
+	queue := func(object string) string {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("pin queue "+object)), minio.ObjectOptions{
+			UserDefined: map[string]string{"X-S3x-Pin": "false"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.ledgerStore.EnqueuePin(hash, now); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	state := func(object string) *ObjectPinStatus {
+		t.Helper()
+		s, err := gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: object})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	pinQueue := func() *PinQueueResponse {
+		t.Helper()
+		resp, err := gateway.GetPinQueue(ctx, &PinQueueRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	process := func(at time.Time) {
+		t.Helper()
+		if err := gateway.processPinQueue(ctx, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue("retried")
+	if s := state("retried"); s.State != pinStateQueued {
+		t.Fatalf("expected state %v, but got %+v", pinStateQueued, s)
+	}
+	if q := pinQueue(); q.Depth != 1 || q.Retrying != 0 || q.OldestQueued != now.Unix() {
+		t.Fatalf("unexpected pin queue %+v", q)
+	}
+
+	// failed attempts are retried after their backoff
+	dagClient := gateway.dagClient
+	gateway.dagClient = failingPersistClient{dagClient}
+	process(now)
+	process(now.Add(pinQueueBackoff / 2))
+	if s := state("retried"); s.State != pinStateQueued || s.Attempts != 1 || s.Error == "" {
+		t.Fatalf("expected one failed attempt, but got %+v", s)
+	}
+	if q := pinQueue(); q.Depth != 1 || q.Retrying != 1 {
+		t.Fatalf("unexpected pin queue %+v", q)
+	}
+	gateway.dagClient = dagClient
+	process(now.Add(pinQueueBackoff))
+	if s := state("retried"); s.State != pinStatePinned || !s.Pinned {
+		t.Fatalf("expected the data to be pinned, but got %+v", s)
+	}
+	if q := pinQueue(); q.Depth != 0 {
+		t.Fatalf("expected an empty pin queue, but got %+v", q)
+	}
+
+	// data that fails too often leaves the queue as lost
+	queue("lost")
+	gateway.dagClient = failingPersistClient{dagClient}
+	at := now
+	for i := 0; i < pinQueueMaxAttempts; i++ {
+		process(at)
+		at = at.Add(pinQueueMaxBackoff)
+	}
+	gateway.dagClient = dagClient
+	if s := state("lost"); s.State != pinStateLost || s.Pinned || s.Error == "" {
+		t.Fatalf("expected the data to be lost, but got %+v", s)
+	}
+
+	// queued data is removed from the queue with the data
+	hash := queue("collected")
+	if err := gateway.DeleteObject(ctx, testBucket1, "collected"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.collectGarbage(ctx, now.AddDate(1, 0, 0), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := gateway.ledgerStore.PinQueueEntry(hash); err != nil || ok {
+		t.Fatalf("expected the data to leave the queue, but got %v, %v", ok, err)
+	}
+
+	// data stored unpinned is never queued
+	if _, err := gateway.PutObject(ctx, testBucket1, "unpinned", getTestPutObjectReader(t, []byte("unpinned")), minio.ObjectOptions{
+		UserDefined: map[string]string{"X-S3x-Pin": "false"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if s := state("unpinned"); s.State != pinStateUnpinned {
+		t.Fatalf("expected state %v, but got %+v", pinStateUnpinned, s)
+	}
+}
+
+func TestPinQueueRetryBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int64
+		want     time.Duration
+	}{
+		{1, pinQueueBackoff},
+		{2, 2 * pinQueueBackoff},
+		{3, 4 * pinQueueBackoff},
+		{pinQueueMaxAttempts, pinQueueMaxBackoff},
+	} {
+		if got := pinQueueRetryBackoff(tt.attempts); got != tt.want {
+			t.Fatalf("attempts %v: expected %v, but got %v", tt.attempts, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the pin states of the data of objects reported by GetObjectPinStatus
+const (
+	pinStatePinned    = "pinned"
+	pinStateLost      = "lost"
+	pinStateQueued    = "queued"
+	pinStateUnpinned  = "unpinned"
+	pinStateUnchecked = "unchecked"
+)
+
+// GetObjectPinStatus returns whether the data of an object is pinned on the TemporalX node, as recorded by the last check,
+// or checks the node now if requested
+func (x *xObjects) GetObjectPinStatus(ctx context.Context, req *GetObjectPinStatusRequest) (*ObjectPinStatus, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	obj, err := x.ledgerStore.Object(ctx, req.GetBucket(), req.GetObject())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if obj == nil {
+		return nil, toGrpcErr(ErrLedgerObjectDoesNotExist)
+	}
+	if obj.GetDataHash() == "" {
+		// the shards of erasure coded objects are spread across nodes
+		return nil, status.Error(codes.FailedPrecondition, "erasure coded objects are not pinned on the TemporalX node")
+	}
+	if req.GetCheck() {
+		s, err := x.checkObjectPin(ctx, req.GetObject(), obj, false)
+		if err != nil {
+			return nil, toGrpcErr(err)
+		}
+		log.Printf("bucket-name: %s, object-name: %s, pinned: %v", req.GetBucket(), req.GetObject(), s.Pinned)
+		return s, nil
+	}
+	recorded, ok, err := x.ledgerStore.PinStatus(obj.GetDataHash())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	ps := objectPinStatus(req.GetObject(), obj.GetDataHash(), recorded)
+	queued, isQueued, err := x.ledgerStore.PinQueueEntry(obj.GetDataHash())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	switch {
+	case isQueued:
+		ps.State, ps.Attempts, ps.Error = pinStateQueued, queued.Attempts, queued.LastError
+	case !ok && obj.ObjectInfo.Unpinned:
+		ps.State = pinStateUnpinned
+	}
+	return ps, nil
+}
+
+// VerifyBucketPins checks that the data of all objects in a bucket is pinned on the TemporalX node, and optionally
+// pins lost data again
+func (x *xObjects) VerifyBucketPins(ctx context.Context, req *VerifyBucketPinsRequest) (*VerifyBucketPinsResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	resp, err := x.verifyBucketPins(ctx, req.GetBucket(), req.GetRepair())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, pins-checked: %v, unpinned: %v", req.GetBucket(), resp.Checked, len(resp.Unpinned))
+	return resp, nil
+}
+
+// objectPinStatus returns the pin status of an object from the recorded status of its data
+func objectPinStatus(object, dataHash string, s *PinStatus) *ObjectPinStatus {
+	ps := &ObjectPinStatus{
+		Object:   object,
+		DataHash: dataHash,
+		Pinned:   s.Pinned,
+		Error:    s.Error,
+		State:    pinStateUnchecked,
+	}
+	if !s.Checked.IsZero() {
+		ps.Checked = s.Checked.Unix()
+		ps.State = pinStateLost
+	}
+	if s.Pinned {
+		ps.State = pinStatePinned
+	}
+	if !s.Repaired.IsZero() {
+		ps.LastRepaired = s.Repaired.Unix()
+	}
+	return ps
+}
+
+// verifyBucketPins checks the pins of the data of all objects in a bucket
+func (x *xObjects) verifyBucketPins(ctx context.Context, bucket string, repair bool) (*VerifyBucketPinsResponse, error) {
+	hashes, unlock, err := x.ledgerStore.GetObjectHashes(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	unlock()
+	resp := &VerifyBucketPinsResponse{Bucket: bucket}
+	for _, name := range names {
+		obj, err := x.ledgerStore.Object(ctx, bucket, name)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil || obj.GetDataHash() == "" || obj.ObjectInfo.Unpinned {
+			continue // removed while verifying, erasure coded, or stored unpinned
+		}
+		s, err := x.checkObjectPin(ctx, name, obj, repair)
+		if err != nil {
+			return nil, err
+		}
+		resp.Checked++
+		if !s.Pinned || s.Repaired {
+			resp.Unpinned = append(resp.Unpinned, s)
+		}
+	}
+	return resp, nil
+}
+
+// checkObjectPin checks whether the data of an object is pinned on the TemporalX node, pins it again if requested,
+// and records the result
+func (x *xObjects) checkObjectPin(ctx context.Context, object string, obj *Object, repair bool) (*ObjectPinStatus, error) {
+	recorded, _, err := x.ledgerStore.PinStatus(obj.GetDataHash())
+	if err != nil {
+		return nil, err
+	}
+	s := &PinStatus{
+		Checked:  x.clock.Now().UTC(),
+		Repaired: recorded.Repaired,
+	}
+	s.Pinned, err = x.dataPinned(ctx, obj.GetDataHash())
+	if err != nil {
+		s.Error = err.Error()
+	}
+	var repaired bool
+	if !s.Pinned && repair {
+		if err := x.repinData(ctx, obj); err != nil {
+			s.Error = err.Error()
+		} else {
+			s.Pinned, s.Repaired, s.Error, repaired = true, s.Checked, "", true
+		}
+	}
+	if err := x.ledgerStore.PutPinStatus(obj.GetDataHash(), s); err != nil {
+		return nil, err
+	}
+	ps := objectPinStatus(object, obj.GetDataHash(), s)
+	ps.Repaired = repaired
+	return ps, nil
+}
+
+// dataPinned returns true if the root block of object data is stored on the TemporalX node, or on the cold cluster
+// if the data was moved there
+func (x *xObjects) dataPinned(ctx context.Context, hash string) (bool, error) {
+	_, dagClient, err := x.dataClients(hash)
+	if err != nil {
+		return false, err
+	}
+	resp, err := dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+		RequestType: pb.BSREQTYPE_BS_HAS,
+		Cids:        []string{hash},
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(resp.GetBlocks()) == 1, nil
+}
+
+// repinData pins lost object data on the TemporalX node, or the cold cluster, again, from the network, or from
+// a replication node of the object if the network does not have it
+func (x *xObjects) repinData(ctx context.Context, obj *Object) error {
+	hash := obj.GetDataHash()
+	_, dagClient, err := x.dataClients(hash)
+	if err != nil {
+		return err
+	}
+	resp, err := dagClient.Persist(ctx, &pb.PersistRequest{Cids: []string{hash}})
+	if err == nil && resp.GetStatus()[hash] {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("failed to pin %v: %s", hash, resp.GetErrors()[hash])
+	}
+	for _, addr := range obj.GetReplicaNodes() {
+		if rerr := x.copyFromReplica(ctx, addr, hash); rerr != nil {
+			err = rerr
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+// copyFromReplica copies data from a replication node to the main node
+func (x *xObjects) copyFromReplica(ctx context.Context, addr, hash string) error {
+	var client pb.FileAPIClient
+	if x.replicas != nil {
+		client = x.replicas.client(addr)
+	}
+	if client == nil {
+		return fmt.Errorf("replication node %v is not configured", addr)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := ipfsFileDownload(ctx, client, pw, hash, 0, 0)
+		_ = pw.CloseWithError(err)
+	}()
+	got, _, err := ipfsFileUpload(ctx, x.fileClient, pr)
+	if err != nil {
+		return fmt.Errorf("failed to copy data from replication node %v: %v", addr, err)
+	}
+	if got != hash {
+		return fmt.Errorf("data of replication node %v was stored as %v instead of %v", addr, got, hash)
+	}
+	return nil
+}
+
+// pinCheckLoop verifies and repairs the pins of all buckets every interval until the gateway is shut down
+func (x *xObjects) pinCheckLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			x.checkPins(x.ctx)
+		}
+	}
+}
+
+// checkPins verifies and repairs the pins of all buckets
+func (x *xObjects) checkPins(ctx context.Context) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		log.Printf("failed to check pins: %v", err)
+		return
+	}
+	for _, bucket := range names {
+		resp, err := x.verifyBucketPins(ctx, bucket, true)
+		if err != nil {
+			if ctx.Err() == nil && err != ErrLedgerBucketDoesNotExist {
+				log.Printf("bucket-name: %s, failed to check pins: %v", bucket, err)
+			}
+			continue
+		}
+		for _, s := range resp.Unpinned {
+			if !s.Repaired {
+				log.Printf("bucket-name: %s, object-name: %s, unpinned: %s", bucket, s.Object, s.Error)
+			}
+		}
+	}
+}
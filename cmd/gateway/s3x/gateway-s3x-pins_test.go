@@ -0,0 +1,95 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Pins(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// checks are recorded with the time of the gateway
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gateway.clock = fixedClock(now)
+	for _, object := range []string{"pinned", "lost"} {
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("pin status of "+object)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+	}
+	// the status is recorded when the data is pinned by the upload
+	s, err := gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: "lost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Pinned || s.Checked != now.Unix() {
+		t.Fatalf("expected the status recorded by the upload, but got %+v", s)
+	}
+	s, err = gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: "lost", Check: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Pinned || s.Checked != now.Unix() {
+		t.Fatalf("expected the data to be pinned, but got %+v", s)
+	}
+
+	// lose the pin of one object
+	if _, err := gateway.dagClient.Blockstore(ctx, &pb.BlockstoreRequest{
+		RequestType: pb.BSREQTYPE_BS_DELETE,
+		Cids:        []string{s.DataHash},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := gateway.VerifyBucketPins(ctx, &VerifyBucketPinsRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Checked != 2 || len(resp.Unpinned) != 1 || resp.Unpinned[0].Object != "lost" || resp.Unpinned[0].Repaired {
+		t.Fatalf("expected one unpinned object, but got %+v", resp)
+	}
+	s, err = gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: "lost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Pinned || s.Checked == 0 {
+		t.Fatalf("expected the recorded status to be unpinned, but got %+v", s)
+	}
+
+	resp, err = gateway.VerifyBucketPins(ctx, &VerifyBucketPinsRequest{Bucket: testBucket1, Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Unpinned) != 1 || !resp.Unpinned[0].Repaired || !resp.Unpinned[0].Pinned {
+		t.Fatalf("expected the object to be repaired, but got %+v", resp)
+	}
+	s, err = gateway.GetObjectPinStatus(ctx, &GetObjectPinStatusRequest{Bucket: testBucket1, Object: "lost", Check: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Pinned || s.Repaired || s.LastRepaired != now.Unix() {
+		t.Fatalf("expected the data to be pinned again, but got %+v", s)
+	}
+	resp, err = gateway.VerifyBucketPins(ctx, &VerifyBucketPinsRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Unpinned) != 0 {
+		t.Fatalf("expected all objects to be pinned, but got %+v", resp)
+	}
+}
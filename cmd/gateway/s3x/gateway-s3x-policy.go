@@ -2,22 +2,61 @@ package s3x
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"strings"
 
+	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
 )
 
+/* Design Notes
+---------------
+
+The policy of a bucket is kept as JSON in its config, and in gateway mode the S3 handlers read it for every anonymous
+request, so a policy applies as soon as it is set. Signed requests are allowed by the IAM policies of their users
+instead, which use the same conditions. The condition values of a request include aws:SourceIp, aws:Referer,
+aws:SecureTransport, aws:CurrentTime, and aws:EpochTime, so a policy can limit access to a network with IpAddress, to
+HTTPS with Bool, to a referer with StringLike, and to a time window with the Date conditions. Deny statements are
+checked before Allow statements, so restrictions are written as Deny statements with the negated condition.
+*/
+
 // SetBucketPolicy sets policy on bucket
 func (x *xObjects) SetBucketPolicy(ctx context.Context, bucket string, bucketPolicy *policy.Policy) error {
-	return errors.New("not yet implemented")
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	data, err := json.Marshal(bucketPolicy)
+	if err != nil {
+		return err
+	}
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		c.Policy = string(data)
+		return nil
+	}), bucket, "", "")
 }
 
 // GetBucketPolicy will get policy on bucket
 func (x *xObjects) GetBucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error) {
-	return nil, errors.New("not yet implemented")
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	if c.GetPolicy() == "" {
+		return nil, minio.BucketPolicyNotFound{Bucket: bucket}
+	}
+	return policy.ParseConfig(strings.NewReader(c.GetPolicy()), bucket)
 }
 
 // DeleteBucketPolicy deletes all policies on bucket
 func (x *xObjects) DeleteBucketPolicy(ctx context.Context, bucket string) error {
-	return errors.New("not yet implemented")
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		c.Policy = ""
+		return nil
+	}), bucket, "", "")
 }
@@ -0,0 +1,84 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+func TestS3X_BucketPolicy(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.GetBucketPolicy(ctx, testBucket1); err != (minio.BucketPolicyNotFound{Bucket: testBucket1}) {
+		t.Fatalf("expected the bucket to have no policy, but got %v", err)
+	}
+	if err := gateway.SetBucketPolicy(ctx, testBucket2, &policy.Policy{Version: policy.DefaultVersion}); err != (minio.BucketNotFound{Bucket: testBucket2}) {
+		t.Fatalf("expected %v, but got %v", minio.BucketNotFound{Bucket: testBucket2}, err)
+	}
+	// public reads from the office network over HTTPS until the end of 2020
+	p, err := policy.ParseConfig(bytes.NewReader([]byte(`{
+	"Version": "2012-10-17",
+	"Statement": [
+		{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::`+testBucket1+`/*"},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::`+testBucket1+`/*",
+			"Condition": {"NotIpAddress": {"aws:SourceIp": "10.0.0.0/8"}}},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::`+testBucket1+`/*",
+			"Condition": {"Bool": {"aws:SecureTransport": "false"}}},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::`+testBucket1+`/*",
+			"Condition": {"DateGreaterThanEquals": {"aws:CurrentTime": "2021-01-01T00:00:00Z"}}}
+	]
+}`)), testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.SetBucketPolicy(ctx, testBucket1, p); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := gateway.GetBucketPolicy(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Statements) != 4 {
+		t.Fatalf("unexpected policy %+v", stored)
+	}
+	for _, tt := range []struct {
+		ip, secure, now string
+		allowed         bool
+	}{
+		{"10.1.2.3", "true", "2020-06-01T00:00:00Z", true},
+		{"192.168.1.1", "true", "2020-06-01T00:00:00Z", false},
+		{"10.1.2.3", "false", "2020-06-01T00:00:00Z", false},
+		{"10.1.2.3", "true", "2021-01-01T00:00:00Z", false},
+	} {
+		allowed := stored.IsAllowed(policy.Args{
+			Action:     policy.GetObjectAction,
+			BucketName: testBucket1,
+			ObjectName: "object",
+			ConditionValues: map[string][]string{
+				"SourceIp":        {tt.ip},
+				"SecureTransport": {tt.secure},
+				"CurrentTime":     {tt.now},
+			},
+		})
+		if allowed != tt.allowed {
+			t.Fatalf("expected a request from %v with secure transport %v at %v to be allowed %v", tt.ip, tt.secure, tt.now, tt.allowed)
+		}
+	}
+	if err := gateway.DeleteBucketPolicy(ctx, testBucket1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.GetBucketPolicy(ctx, testBucket1); err != (minio.BucketPolicyNotFound{Bucket: testBucket1}) {
+		t.Fatalf("expected the policy to be deleted, but got %v", err)
+	}
+}
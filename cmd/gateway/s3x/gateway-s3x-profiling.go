@@ -0,0 +1,16 @@
+package s3x
+
+/* Design Notes
+---------------
+
+The data paths of the gateway buffer objects, parts, and DAG nodes in memory, so support often needs the runtime
+profiles of a gateway to explain its memory and CPU use. Gateways started with --profiling expose them to admin
+requests allowed the admin:Profiling action: one CPU, block, heap, or goroutine profile, or a bundle of all of
+them, sampled for the requested duration. Profiles reveal the internals of the gateway, and sampling them costs
+CPU, so they are disabled by default, and only one CPU or block profile is sampled at a time.
+*/
+
+// ProfilingEnabled returns true if the runtime profiles of the gateway are exposed to admin requests
+func (x *xObjects) ProfilingEnabled() bool {
+	return x.profiling
+}
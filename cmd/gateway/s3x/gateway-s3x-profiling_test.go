@@ -0,0 +1,20 @@
+package s3x
+
+import (
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+var _ minio.ProfilingEnabler = (*xObjects)(nil)
+
+func TestS3X_ProfilingEnabled(t *testing.T) {
+	x := &xObjects{}
+	if x.ProfilingEnabled() {
+		t.Fatal("expected profiling to be disabled by default")
+	}
+	x.profiling = true
+	if !x.ProfilingEnabled() {
+		t.Fatal("expected profiling to be enabled")
+	}
+}
@@ -0,0 +1,92 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetObjectProof returns a proof that an object is part of the bucket as identified by its current hash,
+// see VerifyObjectProof for how the proof is checked.
+func (x *xObjects) GetObjectProof(ctx context.Context, req *ObjectProofRequest) (*ObjectProofResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	proof, err := x.ledgerStore.ObjectProof(ctx, req.GetBucket(), req.GetObject())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	obj := &Object{}
+	if err := obj.Unmarshal(proof.GetObjectBlock()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	proof.DataHash = obj.GetDataHash()
+	return proof, nil
+}
+
+// VerifyObjectProof checks that the blocks of a proof hash to the hashes they claim,
+// and that they link the bucket hash, through the shard of the object if the bucket is sharded,
+// to the object hash and data hash.
+// A nil error means the object was part of the bucket when the bucket had the hash bucketHash.
+func VerifyObjectProof(bucketHash string, proof *ObjectProofResponse) error {
+	if proof.GetBucketHash() != bucketHash {
+		return fmt.Errorf("proof is for bucket hash %v, not %v", proof.GetBucketHash(), bucketHash)
+	}
+	if err := verifyBlock(proof.GetBucketHash(), proof.GetBucketBlock()); err != nil {
+		return fmt.Errorf("invalid bucket block: %v", err)
+	}
+	if err := verifyBlock(proof.GetObjectHash(), proof.GetObjectBlock()); err != nil {
+		return fmt.Errorf("invalid object block: %v", err)
+	}
+	b := &Bucket{}
+	if err := b.Unmarshal(proof.GetBucketBlock()); err != nil {
+		return err
+	}
+	objects := b.GetObjects()
+	if shards := b.GetObjectShards(); len(shards) > 0 {
+		if h := shards[shardOf(proof.GetObject(), len(shards))]; h != proof.GetShardHash() {
+			return fmt.Errorf("bucket links the shard of %q to %q, not %q", proof.GetObject(), h, proof.GetShardHash())
+		}
+		if err := verifyBlock(proof.GetShardHash(), proof.GetShardBlock()); err != nil {
+			return fmt.Errorf("invalid shard block: %v", err)
+		}
+		s := &ObjectShard{}
+		if err := s.Unmarshal(proof.GetShardBlock()); err != nil {
+			return err
+		}
+		objects = s.GetObjects()
+	}
+	if h := objects[proof.GetObject()]; h != proof.GetObjectHash() {
+		return fmt.Errorf("bucket links object %q to %q, not %q", proof.GetObject(), h, proof.GetObjectHash())
+	}
+	obj := &Object{}
+	if err := obj.Unmarshal(proof.GetObjectBlock()); err != nil {
+		return err
+	}
+	if obj.GetDataHash() != proof.GetDataHash() {
+		return fmt.Errorf("object links data %q, not %q", obj.GetDataHash(), proof.GetDataHash())
+	}
+	return nil
+}
+
+// verifyBlock checks that data hashes to the given cid using the same cid version, codec and hash function
+func verifyBlock(hash string, data []byte) error {
+	c, err := cid.Decode(hash)
+	if err != nil {
+		return err
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return err
+	}
+	if !sum.Equals(c) {
+		return fmt.Errorf("block hashes to %v, not %v", sum, c)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package s3x
+
+import (
+	"context"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/publicaccess"
+)
+
+/* Design Notes
+---------------
+
+The public access block of a bucket is kept in its config, and can only add to the public access
+blocked for all buckets by the gateway. The S3 handlers reject ACLs and bucket policies that grant
+public access, and ignore the bucket policies of anonymous requests, according to the combination.
+
+ACLs that grant public access are never honored by the S3 handlers, so IgnorePublicAcls is stored
+and returned for compatibility with clients and security scanners, but does not change any behavior.
+*/
+
+// publicAccessBlockConfig returns the stored form of a public access block configuration, nil if config is nil
+func publicAccessBlockConfig(config *publicaccess.Config) *PublicAccessBlockConfig {
+	if config == nil {
+		return nil
+	}
+	return &PublicAccessBlockConfig{
+		BlockPublicAcls:       config.BlockPublicAcls,
+		IgnorePublicAcls:      config.IgnorePublicAcls,
+		BlockPublicPolicy:     config.BlockPublicPolicy,
+		RestrictPublicBuckets: config.RestrictPublicBuckets,
+	}
+}
+
+// SetPublicAccessBlock sets the public access blocked for a bucket
+func (x *xObjects) SetPublicAccessBlock(ctx context.Context, bucket string, config *publicaccess.Config) error {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opWrite)
+	defer cancel()
+	return x.toMinioErr(ctx, x.ledgerStore.UpdateBucketConfig(ctx, bucket, func(c *BucketConfig) error {
+		c.PublicAccessBlock = publicAccessBlockConfig(config)
+		return nil
+	}), bucket, "", "")
+}
+
+// GetPublicAccessBlock returns the public access blocked for a bucket by its configuration
+func (x *xObjects) GetPublicAccessBlock(ctx context.Context, bucket string) (*publicaccess.Config, error) {
+	x.meter.request(ctx)
+	ctx, cancel := x.timeouts.apply(ctx, opRead)
+	defer cancel()
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return nil, x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	block := c.GetPublicAccessBlock()
+	if block == nil {
+		return nil, minio.PublicAccessBlockNotFound{Bucket: bucket}
+	}
+	return &publicaccess.Config{
+		XMLNS:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		BlockPublicAcls:       block.GetBlockPublicAcls(),
+		IgnorePublicAcls:      block.GetIgnorePublicAcls(),
+		BlockPublicPolicy:     block.GetBlockPublicPolicy(),
+		RestrictPublicBuckets: block.GetRestrictPublicBuckets(),
+	}, nil
+}
+
+// DeletePublicAccessBlock removes the public access block configuration of a bucket
+func (x *xObjects) DeletePublicAccessBlock(ctx context.Context, bucket string) error {
+	return x.SetPublicAccessBlock(ctx, bucket, nil)
+}
+
+// PublicAccessBlock returns the public access blocked for a bucket, by the gateway or the bucket,
+// only the public access blocked by the gateway applies to buckets that do not exist
+func (x *xObjects) PublicAccessBlock(ctx context.Context, bucket string) (publicaccess.Config, error) {
+	if x.blockPublicAccess {
+		return publicaccess.BlockAll(), nil
+	}
+	if bucket == "" {
+		return publicaccess.Config{}, nil
+	}
+	c, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	switch err {
+	case nil:
+	case ErrLedgerBucketDoesNotExist:
+		return publicaccess.Config{}, nil
+	default:
+		return publicaccess.Config{}, err
+	}
+	block := c.GetPublicAccessBlock()
+	return publicaccess.Config{
+		BlockPublicAcls:       block.GetBlockPublicAcls(),
+		IgnorePublicAcls:      block.GetIgnorePublicAcls(),
+		BlockPublicPolicy:     block.GetBlockPublicPolicy(),
+		RestrictPublicBuckets: block.GetRestrictPublicBuckets(),
+	}, nil
+}
@@ -0,0 +1,87 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/publicaccess"
+)
+
+var _ minio.PublicAccessBlocker = (*xObjects)(nil)
+
+func TestS3X_PublicAccessBlock_Badger(t *testing.T) {
+	testS3XPublicAccessBlock(t, DSTypeBadger)
+}
+func TestS3X_PublicAccessBlock_Crdt(t *testing.T) {
+	testS3XPublicAccessBlock(t, DSTypeCrdt)
+}
+func testS3XPublicAccessBlock(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, b := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, b, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkBlocked := func(t *testing.T, bucket string, want publicaccess.Config) {
+		t.Helper()
+		got, err := gateway.PublicAccessBlock(ctx, bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("expected %v to block %+v, but got %+v", bucket, want, got)
+		}
+	}
+	block := publicaccess.Config{BlockPublicPolicy: true, RestrictPublicBuckets: true}
+	t.Run("Bucket", func(t *testing.T) {
+		if _, err := gateway.GetPublicAccessBlock(ctx, testBucket1); err != (minio.PublicAccessBlockNotFound{Bucket: testBucket1}) {
+			t.Fatalf("expected PublicAccessBlockNotFound, but got %v", err)
+		}
+		checkBlocked(t, testBucket1, publicaccess.Config{})
+		if err := gateway.SetPublicAccessBlock(ctx, testBucket1, &block); err != nil {
+			t.Fatal(err)
+		}
+		gateway.restart(t)
+		got, err := gateway.GetPublicAccessBlock(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.BlockPublicPolicy != block.BlockPublicPolicy || got.RestrictPublicBuckets != block.RestrictPublicBuckets ||
+			got.BlockPublicAcls || got.IgnorePublicAcls {
+			t.Fatalf("expected %+v, but got %+v", block, *got)
+		}
+		checkBlocked(t, testBucket1, block)
+		checkBlocked(t, testBucket2, publicaccess.Config{})
+		checkBlocked(t, "missingbucket", publicaccess.Config{})
+		if err := gateway.SetPublicAccessBlock(ctx, "missingbucket", &block); err == nil {
+			t.Fatal("expected setting the public access block of a missing bucket to fail")
+		}
+	})
+	t.Run("Gateway", func(t *testing.T) {
+		gateway.blockPublicAccess = true
+		defer func() { gateway.blockPublicAccess = false }()
+		for _, b := range []string{testBucket1, testBucket2, "missingbucket", ""} {
+			checkBlocked(t, b, publicaccess.BlockAll())
+		}
+		// the configuration of the bucket is still returned as set
+		if _, err := gateway.GetPublicAccessBlock(ctx, testBucket2); err != (minio.PublicAccessBlockNotFound{Bucket: testBucket2}) {
+			t.Fatalf("expected PublicAccessBlockNotFound, but got %v", err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := gateway.DeletePublicAccessBlock(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetPublicAccessBlock(ctx, testBucket1); err != (minio.PublicAccessBlockNotFound{Bucket: testBucket1}) {
+			t.Fatalf("expected PublicAccessBlockNotFound, but got %v", err)
+		}
+		checkBlocked(t, testBucket1, publicaccess.Config{})
+	})
+}
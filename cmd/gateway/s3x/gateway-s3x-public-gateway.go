@@ -0,0 +1,143 @@
+package s3x
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+/* Design Notes
+---------------
+
+Object data is content addressed, so any public IPFS gateway that can reach the TemporalX node serves the same
+bytes for a data hash. To offload the egress of large downloads from the gateway host, share links and the
+/ipfs/ path can serve data of at least a minimum size through a configured public gateway, either by
+redirecting the client to it, which offloads the bandwidth, or by proxying it, which keeps the links on the
+gateway host but offloads reads from the TemporalX node. Only data that is stored as it is downloaded can be
+offloaded, so compressed, encrypted, and erasure coded objects are always served by the gateway.
+
+The gateway does not have a website hosting mode, share links and the /ipfs/ path are the requests it serves
+without S3 authentication, so they are the requests that are offloaded.
+*/
+
+const (
+	// publicGatewayRedirect redirects clients to the public gateway
+	publicGatewayRedirect = "redirect"
+	// publicGatewayProxy streams data from the public gateway to clients
+	publicGatewayProxy = "proxy"
+)
+
+// proxiedHeaders are the response headers of the public gateway that are returned to clients by the proxy
+var proxiedHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified"}
+
+// forwardedHeaders are the request headers of clients that are sent to the public gateway by the proxy
+var forwardedHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Match"}
+
+// publicGateway serves large object data through a public IPFS gateway
+type publicGateway struct {
+	url     *url.URL
+	mode    string
+	minSize int64
+	client  *http.Client
+}
+
+// newPublicGateway returns a public gateway that serves data of at least minSize bytes from an http(s) url
+func newPublicGateway(gatewayURL, mode string, minSize int64) (*publicGateway, error) {
+	u, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid public gateway url %q", gatewayURL)
+	}
+	switch mode {
+	case "":
+		mode = publicGatewayRedirect
+	case publicGatewayRedirect, publicGatewayProxy:
+	default:
+		return nil, fmt.Errorf("public gateway mode must be %q or %q, got %q", publicGatewayRedirect, publicGatewayProxy, mode)
+	}
+	if minSize < 0 {
+		return nil, fmt.Errorf("public gateway minimum size can not be negative, got %v", minSize)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &publicGateway{url: u, mode: mode, minSize: minSize, client: &http.Client{}}, nil
+}
+
+// offloads returns true if data of size bytes is served through the public gateway, g can be nil
+func (g *publicGateway) offloads(size int64) bool {
+	return g != nil && size >= g.minSize
+}
+
+// offloadsObject returns true if the data of an object is served through the public gateway, g can be nil
+func (g *publicGateway) offloadsObject(obj *Object) bool {
+	info := obj.GetObjectInfo()
+	if obj.GetDataHash() == "" || obj.Erasure != nil || info.GetCompression() != "" {
+		return false
+	}
+	for k := range info.GetUserDefined() {
+		if isEncryptionMetadataKey(k) {
+			return false
+		}
+	}
+	return g.offloads(info.GetSize_())
+}
+
+// dataURL returns the url of a data hash on the public gateway, with the file name gateways use for downloads
+func (g *publicGateway) dataURL(hash, name string) *url.URL {
+	u := *g.url
+	u.Path += "/ipfs/" + hash
+	if name != "" {
+		u.RawQuery = url.Values{"filename": []string{path.Base(name)}}.Encode()
+	}
+	return &u
+}
+
+// serve serves a data hash through the public gateway, header are the headers of the object,
+// which are returned by the proxy in place of those of the public gateway
+func (g *publicGateway) serve(w http.ResponseWriter, r *http.Request, hash, name string, header http.Header) {
+	u := g.dataURL(hash, name)
+	if g.mode == publicGatewayRedirect {
+		http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+		return
+	}
+	req, err := http.NewRequest(r.Method, u.String(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := g.client.Do(req.WithContext(r.Context()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	// unmet conditions and ranges are the answer of the public gateway to the request of the client
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusPreconditionFailed && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		http.Error(w, "public gateway responded with status "+resp.Status, http.StatusBadGateway)
+		return
+	}
+	for _, h := range proxiedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	if v := resp.Header.Get("Content-Type"); v != "" && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", v)
+	}
+	for h := range header {
+		w.Header().Set(h, header.Get(h))
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, resp.Body)
+	}
+}
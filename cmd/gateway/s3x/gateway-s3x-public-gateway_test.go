@@ -0,0 +1,106 @@
+package s3x
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_PublicGateway(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := "hello public gateway"
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{
+		UserDefined: map[string]string{"content-type": "text/plain"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ipfsPathPrefix+hash {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Accept-Ranges", "bytes")
+		_, _ = w.Write([]byte(data))
+	}))
+	defer upstream.Close()
+
+	for _, tt := range []struct{ url, mode string }{{"ftp://example.com", ""}, {"http://", ""}, {upstream.URL, "mirror"}} {
+		if _, err := newPublicGateway(tt.url, tt.mode, 0); err == nil {
+			t.Fatalf("expected an error for %q in mode %q", tt.url, tt.mode)
+		}
+	}
+	link, err := gateway.CreateShareLink(withExtensionCaller(ctx, testCaller{owner: true}), &ShareLinkRequest{Bucket: testBucket1, Object: testObject1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Redirect", func(t *testing.T) {
+		if gateway.publicGateway, err = newPublicGateway(upstream.URL+"/", publicGatewayRedirect, 1); err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name    string
+			handler http.HandlerFunc
+			path    string
+			want    string
+		}{
+			{"ShareLink", gateway.ServeShareLink, link.GetUrl(), upstream.URL + ipfsPathPrefix + hash + "?filename=" + testObject1},
+			{"IPFSPath", gateway.ServeIPFSPath, ipfsPathPrefix + hash, upstream.URL + ipfsPathPrefix + hash},
+		}
+		for _, tt := range tests {
+			rec := serve(tt.handler, tt.path)
+			if rec.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("%s: expected status %v, but got %v: %s", tt.name, http.StatusTemporaryRedirect, rec.Code, rec.Body.String())
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.want {
+				t.Fatalf("%s: expected Location %q, but got %q", tt.name, tt.want, loc)
+			}
+		}
+	})
+	t.Run("Below-MinSize", func(t *testing.T) {
+		if gateway.publicGateway, err = newPublicGateway(upstream.URL, publicGatewayRedirect, int64(len(data)+1)); err != nil {
+			t.Fatal(err)
+		}
+		rec := serve(gateway.ServeShareLink, link.GetUrl())
+		if rec.Code != http.StatusOK || rec.Body.String() != data {
+			t.Fatalf("expected the object to be served by the gateway, but got %v: %s", rec.Code, rec.Body.String())
+		}
+	})
+	t.Run("Proxy", func(t *testing.T) {
+		if gateway.publicGateway, err = newPublicGateway(upstream.URL, publicGatewayProxy, 0); err != nil {
+			t.Fatal(err)
+		}
+		rec := serve(gateway.ServeShareLink, link.GetUrl())
+		if rec.Code != http.StatusOK || rec.Body.String() != data {
+			t.Fatalf("expected the object to be proxied, but got %v: %s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+			t.Fatalf("expected the Content-Type of the object, but got %q", ct)
+		}
+		if ar := rec.Header().Get("Accept-Ranges"); ar != "bytes" {
+			t.Fatalf("expected the Accept-Ranges of the public gateway, but got %q", ar)
+		}
+	})
+}
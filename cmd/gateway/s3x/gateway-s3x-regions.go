@@ -0,0 +1,145 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+In a deployment with a gateway per region, each close to the TemporalX cluster of its region and sharing the ledger
+through clustering, a bucket is labeled with the region of its primary TemporalX cluster in the location of its
+bucket info. New buckets are labeled with the region of the gateway that created them, and SetBucketRegion changes
+the label, such as after the data of a bucket was moved to another cluster. The gateway exposes the labels through
+BucketRegionRouter, and the HTTP layer sets x-amz-bucket-region on the responses to the requests to buckets, and
+redirects the requests to buckets of other regions with 301 PermanentRedirect to the endpoint of their gateway, like
+AWS does for requests sent to the endpoint of another region. Buckets without a label, or with the label of a region
+without a known gateway, are served by every gateway. GetBucketRoutingHint returns the same decision, so clients can
+send requests to the right gateway in the first place.
+*/
+
+// bucketRegions are the region of the gateway and the endpoints of the gateways of other regions
+type bucketRegions struct {
+	local string
+	// endpoints maps regions to the urls of their gateways
+	endpoints map[string]string
+}
+
+// newBucketRegions parses the region of the gateway and the comma separated region=url endpoints of other
+// regions, the endpoint of the region of the gateway is ignored so all gateways can share one list. It
+// returns nil if the gateway has no region.
+func newBucketRegions(local, endpoints string) (*bucketRegions, error) {
+	if local == "" {
+		if endpoints != "" {
+			return nil, fmt.Errorf("region endpoints require the region of the gateway")
+		}
+		return nil, nil
+	}
+	r := &bucketRegions{local: local, endpoints: make(map[string]string)}
+	for _, e := range strings.Split(endpoints, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		split := strings.SplitN(e, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, fmt.Errorf("invalid region endpoint %q, expected region=url", e)
+		}
+		u, err := url.Parse(split[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return nil, fmt.Errorf("invalid url of region %s %q, expected http(s)://host[:port]", split[0], split[1])
+		}
+		if _, ok := r.endpoints[split[0]]; ok {
+			return nil, fmt.Errorf("region %s has more than one endpoint", split[0])
+		}
+		if split[0] != local {
+			r.endpoints[split[0]] = u.Scheme + "://" + u.Host
+		}
+	}
+	return r, nil
+}
+
+// bucketLocation returns the location of a new bucket created with location, which is the region of the gateway
+// since requests can only create buckets in the region of the server
+func (r *bucketRegions) bucketLocation(location string) string {
+	if r == nil {
+		return location
+	}
+	return r.local
+}
+
+// route returns the region of a bucket with a location, and the endpoint of its gateway if it is not served locally
+func (r *bucketRegions) route(location string) (region, endpoint string) {
+	if location == "" || location == r.local {
+		return r.local, ""
+	}
+	return location, r.endpoints[location]
+}
+
+// BucketRegion returns the region label of a bucket, and the endpoint of the gateway of its region if requests to
+// the bucket are served by another gateway, or no region if the gateway has none or the bucket does not exist
+func (x *xObjects) BucketRegion(ctx context.Context, bucket string) (string, string, error) {
+	if x.regions == nil {
+		return "", "", nil
+	}
+	info, err := x.ledgerStore.GetBucketInfo(ctx, bucket)
+	switch err {
+	case nil:
+	case ErrLedgerBucketDoesNotExist:
+		return "", "", nil
+	default:
+		return "", "", x.toMinioErr(ctx, err, bucket, "", "")
+	}
+	region, endpoint := x.regions.route(info.GetLocation())
+	return region, endpoint, nil
+}
+
+// SetBucketRegion labels a bucket with a region, the region of the gateway or one with a known gateway
+func (x *xObjects) SetBucketRegion(ctx context.Context, req *SetBucketRegionRequest) (*BucketRoutingHint, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if x.regions == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the gateway has no region")
+	}
+	if _, ok := x.regions.endpoints[req.GetRegion()]; !ok && req.GetRegion() != x.regions.local {
+		return nil, status.Errorf(codes.InvalidArgument, "region %q has no gateway", req.GetRegion())
+	}
+	if err := x.ledgerStore.SetBucketLocation(ctx, req.GetBucket(), req.GetRegion()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, region: %s", req.GetBucket(), req.GetRegion())
+	return x.bucketRoutingHint(req.GetBucket(), req.GetRegion()), nil
+}
+
+// GetBucketRoutingHint returns the region of a bucket, and the endpoint of the gateway of the region
+func (x *xObjects) GetBucketRoutingHint(ctx context.Context, req *BucketRoutingHintRequest) (*BucketRoutingHint, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	info, err := x.ledgerStore.GetBucketInfo(ctx, req.GetBucket())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if x.regions == nil {
+		return &BucketRoutingHint{Bucket: req.GetBucket(), Region: info.GetLocation(), Local: true}, nil
+	}
+	return x.bucketRoutingHint(req.GetBucket(), info.GetLocation()), nil
+}
+
+// bucketRoutingHint returns the routing hint of a bucket with a location
+func (x *xObjects) bucketRoutingHint(bucket, location string) *BucketRoutingHint {
+	region, endpoint := x.regions.route(location)
+	return &BucketRoutingHint{
+		Bucket:   bucket,
+		Region:   region,
+		Endpoint: endpoint,
+		Local:    endpoint == "",
+	}
+}
@@ -0,0 +1,91 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ minio.BucketRegionRouter = (*xObjects)(nil)
+
+func TestS3X_BucketRegions(t *testing.T) {
+	for _, invalid := range [][2]string{
+		{"", "eu-west-1=https://eu.example.com"},
+		{"us-east-1", "eu-west-1"},
+		{"us-east-1", "=https://eu.example.com"},
+		{"us-east-1", "eu-west-1=ftp://eu.example.com"},
+		{"us-east-1", "eu-west-1=https://eu.example.com/path"},
+		{"us-east-1", "eu-west-1=https://eu.example.com,eu-west-1=https://eu2.example.com"},
+	} {
+		if _, err := newBucketRegions(invalid[0], invalid[1]); err == nil {
+			t.Fatalf("expected %v to be invalid", invalid)
+		}
+	}
+	if r, err := newBucketRegions("", ""); err != nil || r != nil {
+		t.Fatalf("expected no regions, got %v %v", r, err)
+	}
+
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	// without a region all buckets are served by the gateway
+	if region, endpoint, err := gateway.BucketRegion(ctx, testBucket1); err != nil || region != "" || endpoint != "" {
+		t.Fatalf("expected no region, got %q %q %v", region, endpoint, err)
+	}
+	if _, err := gateway.SetBucketRegion(ctx, &SetBucketRegionRequest{Bucket: testBucket1, Region: "us-east-1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+
+	// all gateways can share the list of endpoints
+	var err error
+	if gateway.regions, err = newBucketRegions("us-east-1", "us-east-1=https://us.example.com, eu-west-1=https://eu.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket2, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	hint, err := gateway.GetBucketRoutingHint(ctx, &BucketRoutingHintRequest{Bucket: testBucket2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint.Region != "us-east-1" || hint.Endpoint != "" || !hint.Local {
+		t.Fatalf("expected new buckets to be labeled with the region of the gateway, got %+v", hint)
+	}
+	if _, err := gateway.SetBucketRegion(ctx, &SetBucketRegionRequest{Bucket: testBucket1, Region: "ap-east-1"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+	}
+	if _, err := gateway.SetBucketRegion(ctx, &SetBucketRegionRequest{Bucket: "missing", Region: "eu-west-1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+	}
+	hint, err = gateway.SetBucketRegion(ctx, &SetBucketRegionRequest{Bucket: testBucket1, Region: "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint.Region != "eu-west-1" || hint.Endpoint != "https://eu.example.com" || hint.Local {
+		t.Fatalf("unexpected hint %+v", hint)
+	}
+	if region, endpoint, err := gateway.BucketRegion(ctx, testBucket1); err != nil || region != "eu-west-1" || endpoint != "https://eu.example.com" {
+		t.Fatalf("expected the bucket to be redirected, got %q %q %v", region, endpoint, err)
+	}
+	if region, endpoint, err := gateway.BucketRegion(ctx, "missing"); err != nil || region != "" || endpoint != "" {
+		t.Fatalf("expected no region of a missing bucket, got %q %q %v", region, endpoint, err)
+	}
+	// the label is kept in the bucket info
+	info, err := gateway.ledgerStore.GetBucketInfo(ctx, testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Location != "eu-west-1" {
+		t.Fatalf("expected the location to be the region, got %q", info.Location)
+	}
+}
@@ -0,0 +1,399 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Buckets can declare a replication factor, the number of TemporalX nodes that must store the data of each object.
+The main TemporalX node always stores the data, PutObject streams the same data to factor-1 of the configured
+replication nodes at the same time, and fails unless every node stored it under the same hash.
+The replication nodes used are recorded in the object, and reported as a "REPLICATED_<n>" storage class.
+
+Objects created by multipart uploads, compose, append, or before the factor was raised are only stored on the
+main node, until VerifyBucketReplication, or the periodic scrub, repairs them by copying the data from the main node.
+Erasure coded objects are not replicated, since their shards are already spread across nodes.
+*/
+
+// replicationStorageClassPrefix is the storage class prefix of objects stored on more than one node
+const replicationStorageClassPrefix = "REPLICATED_"
+
+// errReplicationNotConfigured is returned when a bucket requires more replicas than there are replication nodes
+var errReplicationNotConfigured = errors.New("not enough replication endpoints are configured for the bucket replication factor")
+
+// replicaNode is a TemporalX node that stores copies of object data
+type replicaNode struct {
+	addr   string
+	client pb.FileAPIClient
+}
+
+// replicaStore copies object data to TemporalX nodes besides the main node
+type replicaStore struct {
+	nodes []replicaNode
+}
+
+// newReplicaStore returns a replicaStore that replicates data to the given nodes
+func newReplicaStore(addrs []string, clients []pb.FileAPIClient) (*replicaStore, error) {
+	if len(addrs) != len(clients) {
+		return nil, errors.New("number of replication addresses and clients must match")
+	}
+	rs := &replicaStore{}
+	for i, addr := range addrs {
+		rs.nodes = append(rs.nodes, replicaNode{addr: addr, client: clients[i]})
+	}
+	return rs, nil
+}
+
+// client returns the client of a node, or nil if the node is not configured anymore
+func (rs *replicaStore) client(addr string) pb.FileAPIClient {
+	for _, node := range rs.nodes {
+		if node.addr == addr {
+			return node.client
+		}
+	}
+	return nil
+}
+
+// pick returns up to n nodes that are not excluded, the first node depends on key to spread data across all nodes
+func (rs *replicaStore) pick(key string, n int, exclude map[string]bool) []replicaNode {
+	var nodes []replicaNode
+	if len(rs.nodes) == 0 {
+		return nodes
+	}
+	start := int(crc32.ChecksumIEEE([]byte(key)) % uint32(len(rs.nodes)))
+	for i := 0; i < len(rs.nodes) && len(nodes) < n; i++ {
+		node := rs.nodes[(start+i)%len(rs.nodes)]
+		if !exclude[node.addr] {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// replicationStorageClass returns the storage class of data stored on the given number of nodes
+func replicationStorageClass(nodes int64) string {
+	if nodes <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("%s%d", replicationStorageClassPrefix, nodes)
+}
+
+// SetBucketReplication configures on how many TemporalX nodes the data of new objects in a bucket is stored
+func (x *xObjects) SetBucketReplication(ctx context.Context, req *SetBucketReplicationRequest) (*SetBucketReplicationResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetFactor() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "replication factor can not be negative")
+	}
+	if available := x.replicaCount() + 1; req.GetFactor() > int64(available) {
+		return nil, status.Errorf(codes.FailedPrecondition, "replication factor %v exceeds the %v available TemporalX nodes", req.GetFactor(), available)
+	}
+	err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		if req.GetFactor() > 1 && c.GetChunker() != "" {
+			return errChunkerReplication
+		}
+		c.ReplicationFactor = req.GetFactor()
+		return nil
+	})
+	if err == errChunkerReplication {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, replication-factor: %v", req.GetBucket(), req.GetFactor())
+	return &SetBucketReplicationResponse{
+		Bucket:       req.GetBucket(),
+		Factor:       req.GetFactor(),
+		StorageClass: replicationStorageClass(req.GetFactor()),
+	}, nil
+}
+
+// VerifyBucketReplication checks that the data of all objects in a bucket can be read from as many replication nodes
+// as the bucket replication factor requires, and optionally stores missing replicas again
+func (x *xObjects) VerifyBucketReplication(ctx context.Context, req *VerifyBucketReplicationRequest) (*VerifyBucketReplicationResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	resp, err := x.verifyBucketReplication(ctx, req.GetBucket(), req.GetRepair())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, replication-checked: %v, under-replicated: %v", req.GetBucket(), resp.Checked, len(resp.Objects))
+	return resp, nil
+}
+
+// replicaCount returns the number of configured replication nodes
+func (x *xObjects) replicaCount() int {
+	if x.replicas == nil {
+		return 0
+	}
+	return len(x.replicas.nodes)
+}
+
+// replicatedUpload stores all data of r on the main node and on factor-1 replication nodes,
+// it returns the data hash, size, and the replication nodes used.
+func (x *xObjects) replicatedUpload(ctx context.Context, key string, r io.Reader, factor int64) (string, int, []string, error) {
+	if factor <= 1 {
+		hash, size, err := ipfsFileUpload(ctx, x.fileClient, r)
+		return hash, size, nil, err
+	}
+	if int64(x.replicaCount()) < factor-1 {
+		return "", 0, nil, errReplicationNotConfigured
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	nodes := x.replicas.pick(key, int(factor-1), nil)
+	clients := []pb.FileAPIClient{x.fileClient}
+	for _, node := range nodes {
+		clients = append(clients, node.client)
+	}
+	writers := make([]io.Writer, len(clients))
+	pipes := make([]*io.PipeWriter, len(clients))
+	results := make([]chan uploadResult, len(clients))
+	for i, client := range clients {
+		pr, pw := io.Pipe()
+		writers[i], pipes[i] = pw, pw
+		results[i] = make(chan uploadResult, 1)
+		go func(client pb.FileAPIClient, pr *io.PipeReader, result chan<- uploadResult) {
+			hash, _, err := ipfsFileUpload(ctx, client, pr)
+			_ = pr.CloseWithError(err) // unblock writes if the upload failed
+			result <- uploadResult{hash: hash, err: err}
+		}(client, pr, results[i])
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), r)
+	for _, pw := range pipes {
+		_ = pw.CloseWithError(err)
+	}
+	var (
+		hash  string
+		addrs []string
+	)
+	for i, result := range results {
+		res := <-result
+		switch {
+		case err != nil:
+		case res.err != nil && i == 0:
+			err = res.err
+		case res.err != nil:
+			err = fmt.Errorf("failed to replicate data to %v: %v", nodes[i-1].addr, res.err)
+		case i == 0:
+			hash = res.hash
+		case res.hash != hash:
+			err = fmt.Errorf("replication node %v stored data as %v instead of %v", nodes[i-1].addr, res.hash, hash)
+		default:
+			addrs = append(addrs, nodes[i-1].addr)
+		}
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return hash, int(size), addrs, nil
+}
+
+// verifyBucketReplication checks the replicas of all objects in a bucket
+func (x *xObjects) verifyBucketReplication(ctx context.Context, bucket string, repair bool) (*VerifyBucketReplicationResponse, error) {
+	config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	hashes, unlock, err := x.ledgerStore.GetObjectHashes(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	unlock()
+	resp := &VerifyBucketReplicationResponse{
+		Bucket: bucket,
+		Factor: config.GetReplicationFactor(),
+	}
+	for _, name := range names {
+		u, err := x.verifyObjectReplication(ctx, bucket, name, config.GetReplicationFactor(), repair)
+		if err == ErrLedgerObjectDoesNotExist {
+			continue // removed while verifying
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp.Checked++
+		if u != nil {
+			resp.Objects = append(resp.Objects, u)
+		}
+	}
+	return resp, nil
+}
+
+// verifyObjectReplication reads the data of an object from its replication nodes,
+// and returns nil if it is stored on enough nodes
+func (x *xObjects) verifyObjectReplication(ctx context.Context, bucket, object string, factor int64, repair bool) (*UnderReplicatedObject, error) {
+	obj, err := x.ledgerStore.Object(ctx, bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrLedgerObjectDoesNotExist
+	}
+	if obj.Erasure != nil {
+		return nil, nil
+	}
+	u := &UnderReplicatedObject{Object: object}
+	exclude := make(map[string]bool)
+	for _, addr := range obj.GetReplicaNodes() {
+		exclude[addr] = true
+		if x.readReplica(ctx, addr, obj.GetDataHash()) != nil {
+			u.LostNodes = append(u.LostNodes, addr)
+			continue
+		}
+		u.ReplicaNodes = append(u.ReplicaNodes, addr)
+	}
+	u.Missing = factor - 1 - int64(len(u.ReplicaNodes))
+	if u.Missing <= 0 {
+		return nil, nil
+	}
+	if !repair {
+		return u, nil
+	}
+	// try all other nodes in order, so a failing node is skipped
+	var nodes []replicaNode
+	if x.replicas != nil {
+		nodes = x.replicas.pick(bucket+"/"+object, len(x.replicas.nodes), exclude)
+	}
+	var (
+		replicaNodes = append([]string{}, u.ReplicaNodes...)
+		stored       int64
+		lastErr      = errReplicationNotConfigured
+	)
+	for _, node := range nodes {
+		if stored == u.Missing {
+			break
+		}
+		if err := x.copyToReplica(ctx, node, obj.GetDataHash()); err != nil {
+			lastErr = err
+			continue
+		}
+		replicaNodes = append(replicaNodes, node.addr)
+		stored++
+	}
+	if stored > 0 {
+		if err := x.updateReplicaNodes(ctx, bucket, object, obj.GetDataHash(), replicaNodes); err != nil {
+			u.Error = err.Error()
+			return u, nil
+		}
+	}
+	if stored < u.Missing {
+		u.Error = lastErr.Error()
+		return u, nil
+	}
+	u.Repaired = true
+	return u, nil
+}
+
+// readReplica reads all data of hash from a replication node
+func (x *xObjects) readReplica(ctx context.Context, addr, hash string) error {
+	var client pb.FileAPIClient
+	if x.replicas != nil {
+		client = x.replicas.client(addr)
+	}
+	if client == nil {
+		return fmt.Errorf("replication node %v is not configured", addr)
+	}
+	_, err := ipfsFileDownload(ctx, client, ioutil.Discard, hash, 0, 0)
+	return err
+}
+
+// copyToReplica copies data from the main node, or the cold cluster if the data was moved there, to a replication node
+func (x *xObjects) copyToReplica(ctx context.Context, node replicaNode, hash string) error {
+	fileClient, _, err := x.dataClients(hash)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := ipfsFileDownload(ctx, fileClient, pw, hash, 0, 0)
+		_ = pw.CloseWithError(err)
+	}()
+	got, _, err := ipfsFileUpload(ctx, node.client, pr)
+	if err != nil {
+		return fmt.Errorf("failed to replicate data to %v: %v", node.addr, err)
+	}
+	if got != hash {
+		return fmt.Errorf("replication node %v stored data as %v instead of %v", node.addr, got, hash)
+	}
+	return nil
+}
+
+// updateReplicaNodes records the replication nodes of an object, unless its data changed
+func (x *xObjects) updateReplicaNodes(ctx context.Context, bucket, object, dataHash string, nodes []string) error {
+	defer x.ledgerStore.locker.write(bucket)()
+	obj, err := x.ledgerStore.object(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	if obj == nil || obj.GetDataHash() != dataHash {
+		return errors.New("object was modified while it was replicated")
+	}
+	obj.ReplicaNodes = nodes
+	obj.ObjectInfo.StorageClass = replicationStorageClass(int64(len(nodes)) + 1)
+	return x.ledgerStore.putObject(ctx, bucket, object, obj)
+}
+
+// scrubReplicationLoop verifies and repairs the replicas of all buckets every interval until the gateway is shut down
+func (x *xObjects) scrubReplicationLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			x.scrubReplication(x.ctx)
+		}
+	}
+}
+
+// scrubReplication verifies and repairs the replicas of all buckets with a replication factor
+func (x *xObjects) scrubReplication(ctx context.Context) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		log.Printf("failed to scrub replicas: %v", err)
+		return
+	}
+	for _, bucket := range names {
+		config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+		if err != nil || config.GetReplicationFactor() <= 1 {
+			continue
+		}
+		resp, err := x.verifyBucketReplication(ctx, bucket, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("bucket-name: %s, failed to scrub replicas: %v", bucket, err)
+			}
+			continue
+		}
+		for _, u := range resp.Objects {
+			if !u.Repaired {
+				log.Printf("bucket-name: %s, object-name: %s, under-replicated: %v missing, %s", bucket, u.Object, u.Missing, u.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// deadFileClient simulates a TemporalX node that can not be read from or written to
+type deadFileClient struct {
+	lostFileClient
+}
+
+func (deadFileClient) UploadFile(ctx context.Context, opts ...grpc.CallOption) (pb.FileAPI_UploadFileClient, error) {
+	return nil, errors.New("node lost")
+}
+
+func TestS3X_Replication_Badger(t *testing.T) {
+	testS3XReplication(t, DSTypeBadger)
+}
+func TestS3X_Replication_Crdt(t *testing.T) {
+	testS3XReplication(t, DSTypeCrdt)
+}
+func testS3XReplication(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("replicated data")
+	// stored before the bucket has a replication factor
+	if _, err := gateway.PutObject(ctx, testBucket1, "old", getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketReplication(ctx, &SetBucketReplicationRequest{Bucket: testBucket1, Factor: 2}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without replication nodes, but got %v", err)
+	}
+	addrs := []string{"node0", "node1", "node2"}
+	rs, err := newReplicaStore(addrs, []pb.FileAPIClient{gateway.fileClient, gateway.fileClient, gateway.fileClient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.replicas = rs
+	if _, err := gateway.SetBucketReplication(ctx, &SetBucketReplicationRequest{Bucket: testBucket1, Factor: 5}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for more replicas than nodes, but got %v", err)
+	}
+	resp, err := gateway.SetBucketReplication(ctx, &SetBucketReplicationRequest{Bucket: testBucket1, Factor: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStorageClass() != "REPLICATED_3" {
+		t.Fatalf("expected storage class REPLICATED_3, but got %q", resp.GetStorageClass())
+	}
+
+	t.Run("PutObject", func(t *testing.T) {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(obj.GetReplicaNodes()) != 2 {
+			t.Fatalf("expected 2 replica nodes, but got %v", obj.GetReplicaNodes())
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.StorageClass != "REPLICATED_3" {
+			t.Fatalf("expected storage class REPLICATED_3, but got %q", info.StorageClass)
+		}
+	})
+	t.Run("Verify", func(t *testing.T) {
+		resp, err := gateway.VerifyBucketReplication(ctx, &VerifyBucketReplicationRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetChecked() != 2 || len(resp.GetObjects()) != 1 || resp.GetObjects()[0].GetObject() != "old" {
+			t.Fatalf("expected only the old object to be under replicated, but got %v", resp)
+		}
+		if u := resp.GetObjects()[0]; u.GetMissing() != 2 || u.GetRepaired() {
+			t.Fatalf("expected 2 missing replicas without repair, but got %v", u)
+		}
+	})
+	t.Run("Repair-Lost-Node", func(t *testing.T) {
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lost := obj.GetReplicaNodes()[0]
+		for i := range rs.nodes {
+			if rs.nodes[i].addr == lost {
+				rs.nodes[i].client = deadFileClient{}
+			}
+		}
+		resp, err := gateway.VerifyBucketReplication(ctx, &VerifyBucketReplicationRequest{Bucket: testBucket1, Repair: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetObjects()) != 2 {
+			t.Fatalf("expected 2 under replicated objects, but got %v", resp)
+		}
+		for _, u := range resp.GetObjects() {
+			if !u.GetRepaired() {
+				t.Fatalf("expected %v to be repaired, but got %v", u.GetObject(), u.GetError())
+			}
+		}
+		obj, err = gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, addr := range obj.GetReplicaNodes() {
+			if addr == lost {
+				t.Fatalf("expected lost node %v to be replaced, but got %v", lost, obj.GetReplicaNodes())
+			}
+		}
+		resp, err = gateway.VerifyBucketReplication(ctx, &VerifyBucketReplicationRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.GetObjects()) != 0 {
+			t.Fatalf("expected no under replicated objects after repair, but got %v", resp)
+		}
+	})
+}
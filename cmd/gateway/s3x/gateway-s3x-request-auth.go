@@ -0,0 +1,62 @@
+package s3x
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+The S3 handlers verify the signature of every request, and allow the date of a signed request to be 15 minutes
+away from the server time, accept signature version 2, and accept any region in the credential scope when the
+server has no region. Gateways that serve known SDKs can tighten each of these. A shorter clock skew limits how
+long a captured request can be replayed, requiring signature version 4 drops the weaker HMAC-SHA1 signatures,
+and a region makes requests signed for another region fail with AuthorizationHeaderMalformed, instead of being
+accepted. Requests that list buckets or get the location of a bucket are still accepted from any region, since
+clients send them to discover the region.
+
+Requests that fail signature verification are counted by the S3 error code returned to the client in the
+s3_auth_failures_total metric, so a client with a skewed clock, the wrong region, or the wrong secret key can be
+told apart from the server side.
+*/
+
+// maxClockSkew is the largest allowed clock skew of signed requests
+const maxClockSkew = time.Hour
+
+// requestAuth hardens the verification of signed requests
+type requestAuth struct {
+	// maxClockSkew is the allowed clock skew of signed requests, the default of the S3 handlers if 0
+	maxClockSkew time.Duration
+	// requireV4 denies requests signed with signature version 2
+	requireV4 bool
+	// region is the region signed requests must be scoped to, the server region if empty
+	region string
+}
+
+// newRequestAuth returns the verification of signed requests, the defaults of the S3 handlers are kept for zero values
+func newRequestAuth(clockSkew time.Duration, requireV4 bool, region string) (requestAuth, error) {
+	if clockSkew < 0 || clockSkew > maxClockSkew {
+		return requestAuth{}, fmt.Errorf("clock skew must be between 0 and %v, got %v", maxClockSkew, clockSkew)
+	}
+	if region != strings.TrimSpace(region) || strings.Contains(region, "/") {
+		return requestAuth{}, fmt.Errorf("invalid region %q", region)
+	}
+	return requestAuth{maxClockSkew: clockSkew, requireV4: requireV4, region: region}, nil
+}
+
+// MaxClockSkew returns the allowed difference between the date of a signed request and the server time
+func (x *xObjects) MaxClockSkew() time.Duration {
+	return x.requestAuth.maxClockSkew
+}
+
+// RequireSignatureV4 returns true if requests signed with signature version 2 are denied
+func (x *xObjects) RequireSignatureV4() bool {
+	return x.requestAuth.requireV4
+}
+
+// AuthRegion returns the region signed requests must be scoped to
+func (x *xObjects) AuthRegion() string {
+	return x.requestAuth.region
+}
@@ -0,0 +1,41 @@
+package s3x
+
+import (
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+var _ minio.RequestAuthConfigurer = (*xObjects)(nil)
+
+func TestS3X_RequestAuth(t *testing.T) {
+	for _, tt := range []struct {
+		clockSkew time.Duration
+		region    string
+		err       bool
+	}{
+		{0, "", false},
+		{time.Minute, "us-east-1", false},
+		{maxClockSkew, "", false},
+		{-time.Second, "", true},
+		{maxClockSkew + time.Second, "", true},
+		{0, " us-east-1", true},
+		{0, "us-east-1/s3", true},
+	} {
+		if _, err := newRequestAuth(tt.clockSkew, false, tt.region); (err != nil) != tt.err {
+			t.Fatalf("unexpected error %v for clock skew %v and region %q", err, tt.clockSkew, tt.region)
+		}
+	}
+	x := &xObjects{}
+	if x.MaxClockSkew() != 0 || x.RequireSignatureV4() || x.AuthRegion() != "" {
+		t.Fatal("expected the defaults of the S3 handlers")
+	}
+	var err error
+	if x.requestAuth, err = newRequestAuth(time.Minute, true, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+	if x.MaxClockSkew() != time.Minute || !x.RequireSignatureV4() || x.AuthRegion() != "us-east-1" {
+		t.Fatalf("unexpected request auth %+v", x.requestAuth)
+	}
+}
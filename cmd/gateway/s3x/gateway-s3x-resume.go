@@ -0,0 +1,245 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A file upload or download can take minutes, and the connection to TemporalX can drop while it runs, because
+TemporalX restarted, drains its connections with a GOAWAY, or the network failed. gRPC reconnects the connection by
+itself, but the streams of the dropped connection fail with the Unavailable code, which used to fail the whole S3
+request. File streams are resumed instead: the stream is opened again on the reconnected connection, waiting until
+it is ready, and continues where it failed, up to ResumeAttempts times per stream.
+
+* Downloads request the file again and skip the bytes that were already received, so the reader of the stream
+  gets every byte once. DownloadRequest can request a range, but TemporalX may not support it, so the skipped
+  bytes are transferred again.
+* Uploads can only be resumed by sending all of their data again, so the stream keeps a copy of the blobs it sent,
+  up to maxResumableUploadSize bytes. Chunked uploads and small objects are resumed, while larger uploads stop
+  keeping their blobs once they exceed the limit, and fail like before.
+
+Streams that exceeded their idle timeout also fail with Unavailable, and are resumed the same way, since a
+connection that silently stopped delivering data is a dropped connection too. A resumed stream gets a new idle
+timeout, so every attempt is bounded, and streams are not resumed once their context ended. The resumer is an
+interceptor of the connections to TemporalX like the call deadlines, and runs before them. Unary calls are not
+resumed, they fail with SlowDown and are retried by S3 clients.
+*/
+
+const (
+	// defaultResumeAttempts is how often a file stream is resumed by default
+	defaultResumeAttempts = 3
+	// maxResumableUploadSize is the largest upload that is resumed, uploads keep a copy of their data up to it
+	maxResumableUploadSize = 16 * 1024 * 1024
+	// resumeBackoff is how long the first resume of a stream waits, doubled with each attempt
+	resumeBackoff = 100 * time.Millisecond
+)
+
+// resumableStreams are the TemporalX streams that are resumed
+var resumableStreams = map[string]bool{
+	"/pb.FileAPI/UploadFile":   true,
+	"/pb.FileAPI/DownloadFile": true,
+}
+
+// streamResumesTotal counts the resumed TemporalX streams
+var streamResumesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "s3x",
+	Subsystem: "temporalx",
+	Name:      "stream_resumes_total",
+	Help:      "Total number of attempts to resume TemporalX streams after the connection dropped by method",
+}, []string{"method"})
+
+func init() {
+	prometheus.MustRegister(streamResumesTotal)
+}
+
+// streamResumer resumes file streams that failed because the connection to TemporalX dropped
+type streamResumer struct {
+	// attempts is how often a stream is resumed, streams are not resumed if 0
+	attempts int
+}
+
+// newStreamResumer returns a streamResumer that resumes a stream attempts times, or never if 0
+func newStreamResumer(attempts int) (streamResumer, error) {
+	if attempts < 0 {
+		return streamResumer{}, fmt.Errorf("resume attempts can not be negative, got %v", attempts)
+	}
+	return streamResumer{attempts: attempts}, nil
+}
+
+// dialOptions returns the interceptor that resumes the streams of a connection, it must be chained before the
+// call deadlines
+func (r streamResumer) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{grpc.WithChainStreamInterceptor(r.stream)}
+}
+
+// stream opens a stream, which is resumed if it is a file stream
+func (r streamResumer) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if r.attempts == 0 || !resumableStreams[method] {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	s := &resumingStream{
+		ctx:         ctx,
+		desc:        desc,
+		cc:          cc,
+		method:      method,
+		streamer:    streamer,
+		opts:        opts,
+		maxAttempts: r.attempts,
+		replayable:  true,
+	}
+	if err := s.open(opts); err != nil {
+		if err = s.resume(err); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// resumingStream is a file stream that is opened again when it fails with Unavailable
+type resumingStream struct {
+	grpc.ClientStream
+	ctx         context.Context
+	desc        *grpc.StreamDesc
+	cc          *grpc.ClientConn
+	method      string
+	streamer    grpc.Streamer
+	opts        []grpc.CallOption
+	maxAttempts int
+
+	attempts int
+	// sent are the messages sent on the stream, which are sent again when it is resumed
+	sent       []interface{}
+	sentSize   int
+	closedSend bool
+	// replayable is unset once the sent messages exceeded maxResumableUploadSize and are not kept anymore
+	replayable bool
+	// sendFailed is set once a send failed, the error of the stream is returned by RecvMsg
+	sendFailed bool
+	// received is the number of downloaded bytes, and skip the bytes that are received again after a resume
+	received int64
+	skip     int64
+}
+
+// open opens the stream, and sends the messages that were sent before
+func (s *resumingStream) open(opts []grpc.CallOption) error {
+	cs, err := s.streamer(s.ctx, s.desc, s.cc, s.method, opts...)
+	if err != nil {
+		return err
+	}
+	s.ClientStream, s.sendFailed, s.skip = cs, false, s.received
+	for _, m := range s.sent {
+		if err := cs.SendMsg(m); err == io.EOF {
+			s.sendFailed = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if s.closedSend {
+		return cs.CloseSend()
+	}
+	return nil
+}
+
+// resume opens the stream again after it failed with err, until it is opened or can not be resumed
+func (s *resumingStream) resume(err error) error {
+	backoff := resumeBackoff
+	for status.Code(err) == codes.Unavailable && s.replayable && s.attempts < s.maxAttempts && s.ctx.Err() == nil {
+		s.attempts++
+		streamResumesTotal.WithLabelValues(s.method).Inc()
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return err
+		}
+		backoff *= 2
+		// wait for the connection to reconnect, instead of failing while it is not ready
+		if err = s.open(append(s.opts[:len(s.opts):len(s.opts)], grpc.WaitForReady(true))); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// record keeps a copy of a sent message, until the sent messages exceed maxResumableUploadSize
+func (s *resumingStream) record(m interface{}) {
+	if !s.replayable {
+		return
+	}
+	if req, ok := m.(*pb.UploadRequest); ok {
+		content := req.GetBlob().GetContent()
+		if s.sentSize += len(content); s.sentSize > maxResumableUploadSize {
+			s.sent, s.replayable = nil, false
+			return
+		}
+		// the sender reuses the buffer of the blob
+		m = &pb.UploadRequest{Blob: &pb.Blob{Content: append([]byte(nil), content...)}, Options: req.GetOptions()}
+	}
+	s.sent = append(s.sent, m)
+}
+
+func (s *resumingStream) SendMsg(m interface{}) error {
+	s.record(m)
+	if !s.sendFailed {
+		// a failed send returns io.EOF, and the error of the stream is returned by RecvMsg
+		if err := s.ClientStream.SendMsg(m); err != io.EOF {
+			return err
+		}
+		s.sendFailed = true
+	}
+	if !s.replayable {
+		return io.EOF
+	}
+	// the kept messages are sent again once RecvMsg resumed the stream
+	return nil
+}
+
+func (s *resumingStream) CloseSend() error {
+	s.closedSend = true
+	if s.sendFailed {
+		return nil
+	}
+	return s.ClientStream.CloseSend()
+}
+
+func (s *resumingStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.ClientStream.RecvMsg(m)
+		if err == io.EOF {
+			return err
+		}
+		if err != nil {
+			if err = s.resume(err); err != nil {
+				return err
+			}
+			continue
+		}
+		resp, ok := m.(*pb.DownloadResponse)
+		if !ok || resp.GetBlob() == nil {
+			return nil
+		}
+		// skip the bytes that were received before the stream was resumed
+		content := resp.Blob.Content
+		if s.skip >= int64(len(content)) {
+			s.skip -= int64(len(content))
+			if len(content) > 0 {
+				continue
+			}
+		} else {
+			resp.Blob.Content, s.skip = content[s.skip:], 0
+		}
+		s.received += int64(len(resp.Blob.Content))
+		return nil
+	}
+}
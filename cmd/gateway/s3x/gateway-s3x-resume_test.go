@@ -0,0 +1,171 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// droppingFileServer serves file streams that fail with Unavailable after dropAfter messages, drops times
+type droppingFileServer struct {
+	data      []byte
+	blockSize int
+	dropAfter int
+	drops     int
+	// uploaded is the data received by the last upload stream
+	uploaded []byte
+}
+
+// stream is a terminal interceptor that opens a stream of the server instead of calling TemporalX
+func (f *droppingFileServer) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	f.uploaded = nil
+	return &droppingStream{f: f, ctx: ctx}, nil
+}
+
+// drop returns whether the stream drops after it transferred n messages
+func (f *droppingFileServer) drop(n int) bool {
+	if n == f.dropAfter && f.drops > 0 {
+		f.drops--
+		return true
+	}
+	return false
+}
+
+type droppingStream struct {
+	grpc.ClientStream
+	f        *droppingFileServer
+	ctx      context.Context
+	messages int
+	offset   int
+	failed   bool
+}
+
+func (s *droppingStream) Context() context.Context { return s.ctx }
+
+func (s *droppingStream) CloseSend() error { return nil }
+
+func (s *droppingStream) SendMsg(m interface{}) error {
+	req, ok := m.(*pb.UploadRequest)
+	if !ok {
+		return nil
+	}
+	if s.failed || s.f.drop(s.messages) {
+		s.failed = true
+		return io.EOF
+	}
+	s.messages++
+	s.f.uploaded = append(s.f.uploaded, req.GetBlob().GetContent()...)
+	return nil
+}
+
+func (s *droppingStream) RecvMsg(m interface{}) error {
+	if s.failed {
+		return status.Error(codes.Unavailable, "connection dropped")
+	}
+	switch resp := m.(type) {
+	case *pb.PutResponse:
+		resp.Hash = "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+		return nil
+	case *pb.DownloadResponse:
+		if s.f.drop(s.messages) {
+			s.failed = true
+			return status.Error(codes.Unavailable, "connection dropped")
+		}
+		if s.offset == len(s.f.data) {
+			return io.EOF
+		}
+		end := s.offset + s.f.blockSize
+		if end > len(s.f.data) {
+			end = len(s.f.data)
+		}
+		resp.Blob = &pb.Blob{Content: s.f.data[s.offset:end]}
+		s.offset = end
+		s.messages++
+		return nil
+	}
+	return nil
+}
+
+func TestStreamResumer(t *testing.T) {
+	ctx := context.Background()
+	if _, err := newStreamResumer(-1); err == nil {
+		t.Fatal("expected negative resume attempts to be rejected")
+	}
+	// files returns a file client of a server resumed attempts times
+	files := func(t *testing.T, f *droppingFileServer, attempts int) pb.FileAPIClient {
+		t.Helper()
+		resumer, err := newStreamResumer(attempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := grpc.Dial("127.0.0.1:1", append(resumer.dialOptions(),
+			grpc.WithInsecure(), grpc.WithChainStreamInterceptor(f.stream))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return pb.NewFileAPIClient(conn)
+	}
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	download := func(client pb.FileAPIClient, offset, length int64) ([]byte, error) {
+		var buf bytes.Buffer
+		_, err := ipfsFileDownload(ctx, client, &buf, "hash", offset, length)
+		return buf.Bytes(), err
+	}
+	t.Run("Download", func(t *testing.T) {
+		f := &droppingFileServer{data: data, blockSize: 64, dropAfter: 3, drops: 2}
+		got, err := download(files(t, f, defaultResumeAttempts), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("expected the resumed download to return %v bytes once, but got %v", len(data), len(got))
+		}
+	})
+	t.Run("Download-Range", func(t *testing.T) {
+		f := &droppingFileServer{data: data, blockSize: 64, dropAfter: 5, drops: 1}
+		got, err := download(files(t, f, defaultResumeAttempts), 100, 500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data[100:600]) {
+			t.Fatalf("expected the resumed range, but got %v bytes", len(got))
+		}
+	})
+	t.Run("Download-Attempts", func(t *testing.T) {
+		f := &droppingFileServer{data: data, blockSize: 64, dropAfter: 3, drops: 3}
+		if _, err := download(files(t, f, 2), 0, 0); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable once the attempts were used, but got %v", err)
+		}
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		f := &droppingFileServer{data: data, blockSize: 64, dropAfter: 3, drops: 1}
+		if _, err := download(files(t, f, 0), 0, 0); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, but got %v", err)
+		}
+	})
+	t.Run("Upload", func(t *testing.T) {
+		f := &droppingFileServer{dropAfter: 2, drops: 1}
+		// each reader is sent in its own message
+		r := io.MultiReader(bytes.NewReader(data[:300]), bytes.NewReader(data[300:600]), bytes.NewReader(data[600:]))
+		if _, _, err := ipfsFileUpload(ctx, files(t, f, defaultResumeAttempts), r); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.uploaded, data) {
+			t.Fatalf("expected the resumed upload to send %v bytes, but sent %v", len(data), len(f.uploaded))
+		}
+	})
+	t.Run("Upload-Too-Large", func(t *testing.T) {
+		f := &droppingFileServer{dropAfter: maxResumableUploadSize/chunkSize + 1, drops: 1}
+		data := bytes.Repeat([]byte("u"), maxResumableUploadSize+2*chunkSize)
+		if _, _, err := ipfsFileUpload(ctx, files(t, f, defaultResumeAttempts), bytes.NewReader(data)); err == nil {
+			t.Fatal("expected uploads larger than the resumable size to fail")
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/* Design Notes
+---------------
+
+Under load, a few large uploads and downloads can hold every TemporalX connection and ledger read, so a HEAD or
+a listing waits behind them although it only reads a ledger entry. The scheduler admits at most
+MaxConcurrentRequests S3 operations at a time, and at most MaxBulkRequests of them transfer object data, so the
+other slots are kept for interactive operations. Operations that find no free slot wait in a queue of their
+priority, and a freed slot is given to the oldest waiting interactive operation first, so interactive clients
+stay responsive while the bulk transfers proceed as slots are freed.
+
+Interactive operations are object and bucket info, existence checks, and listings of buckets, objects, and
+parts. Bulk operations are PutObject, PutObjectPart, GetObject, and GetObjectNInfo, whose slot is held until the
+returned reader is closed, since the data is streamed after it returns. Copies read their source with
+GetObjectNInfo, which already holds a bulk slot, so CopyObject is not scheduled, otherwise copies could hold
+every bulk slot while waiting for another. Other operations, such as deletions and bucket settings, are not
+scheduled.
+
+Waiting counts toward the request timeout, and a request that is canceled or times out while it waits leaves
+the queue. The scheduler is disabled if MaxConcurrentRequests is 0.
+*/
+
+// request priorities
+const (
+	priorityInteractive = "interactive"
+	priorityBulk        = "bulk"
+)
+
+var (
+	schedulerQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "scheduler",
+		Name:      "queued_requests",
+		Help:      "Number of S3 operations waiting for a slot by priority",
+	}, []string{"priority"})
+	schedulerRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "scheduler",
+		Name:      "running_requests",
+		Help:      "Number of S3 operations holding a slot by priority",
+	}, []string{"priority"})
+)
+
+func init() {
+	prometheus.MustRegister(schedulerQueued, schedulerRunning)
+}
+
+// requestScheduler admits S3 operations by priority, nil if scheduling is disabled
+type requestScheduler struct {
+	slots     int // the operations running at the same time
+	bulkSlots int // the bulk operations running at the same time
+
+	mu          sync.Mutex
+	running     int
+	bulkRunning int
+	interactive []chan struct{} // the waiting interactive operations, oldest first
+	bulk        []chan struct{} // the waiting bulk operations, oldest first
+}
+
+// newRequestScheduler returns a scheduler of slots operations, at most bulkSlots of them bulk, 3/4 of the slots
+// if 0, or nil if slots is 0
+func newRequestScheduler(slots, bulkSlots int) (*requestScheduler, error) {
+	switch {
+	case slots < 0 || bulkSlots < 0:
+		return nil, fmt.Errorf("scheduler slots can not be negative, got %v and %v bulk slots", slots, bulkSlots)
+	case slots == 0:
+		return nil, nil
+	case bulkSlots > slots:
+		return nil, fmt.Errorf("bulk slots must be at most %v, got %v", slots, bulkSlots)
+	case bulkSlots == 0:
+		if bulkSlots = slots * 3 / 4; bulkSlots == 0 {
+			bulkSlots = 1
+		}
+	}
+	return &requestScheduler{slots: slots, bulkSlots: bulkSlots}, nil
+}
+
+// admit waits for a slot of an operation of priority, release must be called once the operation ended
+func (s *requestScheduler) admit(ctx context.Context, priority string) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	if s.canRun(priority) && len(s.queue(priority)) == 0 {
+		s.start(priority)
+		s.mu.Unlock()
+		return s.releaser(priority), nil
+	}
+	admitted := make(chan struct{})
+	s.setQueue(priority, append(s.queue(priority), admitted))
+	schedulerQueued.WithLabelValues(priority).Inc()
+	s.mu.Unlock()
+	select {
+	case <-admitted:
+		return s.releaser(priority), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-admitted:
+			// admitted while the context ended, the slot is given to the next operation
+			s.finish(priority)
+		default:
+			s.remove(priority, admitted)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// queue returns the waiting operations of priority
+func (s *requestScheduler) queue(priority string) []chan struct{} {
+	if priority == priorityBulk {
+		return s.bulk
+	}
+	return s.interactive
+}
+
+// setQueue sets the waiting operations of priority
+func (s *requestScheduler) setQueue(priority string, q []chan struct{}) {
+	if priority == priorityBulk {
+		s.bulk = q
+	} else {
+		s.interactive = q
+	}
+}
+
+// remove removes a waiting operation that left the queue
+func (s *requestScheduler) remove(priority string, admitted chan struct{}) {
+	q := s.queue(priority)
+	for i, c := range q {
+		if c == admitted {
+			s.setQueue(priority, append(q[:i:i], q[i+1:]...))
+			schedulerQueued.WithLabelValues(priority).Dec()
+			return
+		}
+	}
+}
+
+// canRun returns whether an operation of priority can take a slot
+func (s *requestScheduler) canRun(priority string) bool {
+	if s.running >= s.slots {
+		return false
+	}
+	return priority != priorityBulk || s.bulkRunning < s.bulkSlots
+}
+
+// start takes a slot for an operation of priority
+func (s *requestScheduler) start(priority string) {
+	s.running++
+	if priority == priorityBulk {
+		s.bulkRunning++
+	}
+	schedulerRunning.WithLabelValues(priority).Inc()
+}
+
+// finish frees the slot of an operation of priority, and gives the free slots to the waiting operations
+func (s *requestScheduler) finish(priority string) {
+	s.running--
+	if priority == priorityBulk {
+		s.bulkRunning--
+	}
+	schedulerRunning.WithLabelValues(priority).Dec()
+	for _, p := range []string{priorityInteractive, priorityBulk} {
+		for q := s.queue(p); len(q) > 0 && s.canRun(p); q = s.queue(p) {
+			s.setQueue(p, q[1:])
+			schedulerQueued.WithLabelValues(p).Dec()
+			s.start(p)
+			close(q[0])
+		}
+	}
+}
+
+// releaser returns the release function of an admitted operation, which frees its slot once
+func (s *requestScheduler) releaser(priority string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.finish(priority)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestRequestScheduler(t *testing.T) {
+	ctx := context.Background()
+	admit := func(s *requestScheduler, priority string) func() {
+		t.Helper()
+		finish, err := s.admit(ctx, priority)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return finish
+	}
+	// waiting admits an operation in the background, and returns a channel of its release function
+	waiting := func(s *requestScheduler, priority string) <-chan func() {
+		admitted := make(chan func(), 1)
+		go func() {
+			finish, err := s.admit(ctx, priority)
+			if err != nil {
+				t.Error(err)
+			}
+			admitted <- finish
+		}()
+		// wait for the operation to be queued
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			s.mu.Lock()
+			queued := len(s.queue(priority))
+			s.mu.Unlock()
+			if queued > 0 {
+				break
+			}
+		}
+		return admitted
+	}
+	assertWaiting := func(admitted <-chan func()) {
+		t.Helper()
+		select {
+		case <-admitted:
+			t.Fatal("expected the operation to wait")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	received := func(admitted <-chan func()) func() {
+		t.Helper()
+		select {
+		case finish := <-admitted:
+			return finish
+		case <-time.After(time.Second):
+			t.Fatal("expected the operation to be admitted")
+		}
+		return nil
+	}
+
+	t.Run("Config", func(t *testing.T) {
+		if s, err := newRequestScheduler(0, 0); err != nil || s != nil {
+			t.Fatalf("expected scheduling to be disabled, but got %v, %v", s, err)
+		}
+		if s, err := newRequestScheduler(8, 0); err != nil || s.bulkSlots != 6 {
+			t.Fatalf("expected 6 bulk slots, but got %+v, %v", s, err)
+		}
+		if _, err := newRequestScheduler(2, 3); err == nil {
+			t.Fatal("expected more bulk slots than slots to be rejected")
+		}
+		var disabled *requestScheduler
+		admit(disabled, priorityBulk)()
+	})
+	t.Run("Interactive-First", func(t *testing.T) {
+		s, err := newRequestScheduler(2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bulk1, bulk2 := admit(s, priorityBulk), admit(s, priorityBulk)
+		bulk3 := waiting(s, priorityBulk)
+		interactive := waiting(s, priorityInteractive)
+		assertWaiting(bulk3)
+		assertWaiting(interactive)
+		// the interactive operation waited less, but is admitted first
+		bulk1()
+		finishInteractive := received(interactive)
+		assertWaiting(bulk3)
+		bulk2()
+		received(bulk3)()
+		finishInteractive()
+		if s.running != 0 || s.bulkRunning != 0 {
+			t.Fatalf("expected no running operations, but got %v", s.running)
+		}
+	})
+	t.Run("Bulk-Limit", func(t *testing.T) {
+		s, err := newRequestScheduler(2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bulk := admit(s, priorityBulk)
+		// the other slot is kept for interactive operations
+		waitingBulk := waiting(s, priorityBulk)
+		assertWaiting(waitingBulk)
+		admit(s, priorityInteractive)()
+		bulk()
+		received(waitingBulk)()
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		s, err := newRequestScheduler(1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finish := admit(s, priorityBulk)
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.admit(waitCtx, priorityInteractive); err != context.DeadlineExceeded {
+			t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+		}
+		if len(s.interactive) != 0 {
+			t.Fatal("expected the canceled operation to leave the queue")
+		}
+		finish()
+		// the release function frees the slot once
+		finish()
+		if s.running != 0 {
+			t.Fatalf("expected no running operations, but got %v", s.running)
+		}
+	})
+}
+
+func TestS3X_RequestScheduler(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("scheduled")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if gateway.scheduler, err = newRequestScheduler(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { gateway.scheduler = nil }()
+	// the download holds the only slot until its reader is closed
+	gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, nil, 0, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := gateway.GetObjectInfo(waitCtx, testBucket1, testObject1, minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected the operation to wait for the download")
+	}
+	if err := gr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// failed downloads release their slot
+	if _, err := gateway.GetObjectNInfo(ctx, testBucket1, "missing", nil, nil, 0, minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	if _, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 10); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// userMetadataPrefix is the prefix of user metadata headers
+	userMetadataPrefix = "x-amz-meta-"
+	// defaultSearchResults is the number of search results returned if the request does not limit them
+	defaultSearchResults = 1000
+)
+
+// isUserMetadataKey returns true for user metadata and object tagging keys
+func isUserMetadataKey(k string) bool {
+	k = strings.ToLower(k)
+	return strings.HasPrefix(k, userMetadataPrefix) || k == strings.ToLower(xhttp.AmzObjectTagging)
+}
+
+// objectTags returns the tags of an object
+func objectTags(info *ObjectInfo) map[string]string {
+	tags := make(map[string]string)
+	values, err := url.ParseQuery(userDefinedValue(info.UserDefined, xhttp.AmzObjectTagging))
+	if err != nil {
+		return tags
+	}
+	for k := range values {
+		tags[k] = values.Get(k)
+	}
+	return tags
+}
+
+// objectMetadata returns the user metadata of an object with lower case keys without the user metadata prefix
+func objectMetadata(info *ObjectInfo) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range info.UserDefined {
+		if k = strings.ToLower(k); strings.HasPrefix(k, userMetadataPrefix) {
+			metadata[strings.TrimPrefix(k, userMetadataPrefix)] = v
+		}
+	}
+	return metadata
+}
+
+// searchMatcher returns a function that returns true for objects matching all conditions of req
+func searchMatcher(req *SearchObjectsRequest) func(*ObjectInfo) bool {
+	return func(info *ObjectInfo) bool {
+		if info.Name <= req.GetStartAfter() || !strings.Contains(info.Name, req.GetKeyContains()) {
+			return false
+		}
+		if info.Size_ < req.GetMinSize() || (req.GetMaxSize() > 0 && info.Size_ > req.GetMaxSize()) {
+			return false
+		}
+		modified := info.ModTime.Unix()
+		if req.GetModifiedAfter() != 0 && modified < req.GetModifiedAfter() {
+			return false
+		}
+		if req.GetModifiedBefore() != 0 && modified >= req.GetModifiedBefore() {
+			return false
+		}
+		if len(req.GetTags()) > 0 {
+			tags := objectTags(info)
+			for k, v := range req.GetTags() {
+				if got, ok := tags[k]; !ok || got != v {
+					return false
+				}
+			}
+		}
+		if len(req.GetMetadata()) > 0 {
+			metadata := objectMetadata(info)
+			for k, v := range req.GetMetadata() {
+				if got, ok := metadata[strings.ToLower(k)]; !ok || got != v {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// SearchObjects finds objects in a bucket by name, tags, user metadata, size, and modification time
+func (x *xObjects) SearchObjects(ctx context.Context, req *SearchObjectsRequest) (*SearchObjectsResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetMinSize() < 0 || req.GetMaxSize() < 0 || req.GetMaxResults() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "sizes and max results can not be negative")
+	}
+	infos, err := x.ledgerStore.SearchObjects(ctx, req.GetBucket(), searchMatcher(req))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	max := req.GetMaxResults()
+	if max == 0 {
+		max = defaultSearchResults
+	}
+	resp := &SearchObjectsResponse{Bucket: req.GetBucket()}
+	if int64(len(infos)) > max {
+		infos = infos[:max]
+		resp.IsTruncated = true
+		resp.NextStartAfter = infos[len(infos)-1].Name
+	}
+	for _, info := range infos {
+		r := &SearchResult{
+			Object:      info.Name,
+			Size_:       info.Size_,
+			Etag:        s3ETag(info.Etag),
+			ContentType: info.ContentType,
+			Tags:        objectTags(info),
+			Metadata:    objectMetadata(info),
+		}
+		if !info.ModTime.IsZero() {
+			r.Modified = info.ModTime.Unix()
+		}
+		resp.Objects = append(resp.Objects, r)
+	}
+	log.Printf("bucket-name: %s, search-results: %v", req.GetBucket(), len(resp.Objects))
+	return resp, nil
+}
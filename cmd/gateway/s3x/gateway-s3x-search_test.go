@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Search_Badger(t *testing.T) {
+	testS3XSearch(t, DSTypeBadger)
+}
+func TestS3X_Search_Crdt(t *testing.T) {
+	testS3XSearch(t, DSTypeCrdt)
+}
+func testS3XSearch(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	objects := []struct {
+		name string
+		data string
+		meta map[string]string
+	}{
+		{"logs/a.log", "a", map[string]string{"X-Amz-Meta-Owner": "alice", "X-Amz-Tagging": "env=prod&team=infra"}},
+		{"logs/b.log", "bbbb", map[string]string{"X-Amz-Meta-Owner": "bob", "X-Amz-Tagging": "env=dev"}},
+		{"images/c.png", "cccccccc", map[string]string{"X-Amz-Meta-Owner": "alice"}},
+	}
+	for _, o := range objects {
+		if _, err := gateway.PutObject(ctx, testBucket1, o.name, getTestPutObjectReader(t, []byte(o.data)), minio.ObjectOptions{UserDefined: o.meta}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(t *testing.T, req *SearchObjectsRequest) []string {
+		req.Bucket = testBucket1
+		resp, err := gateway.SearchObjects(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, r := range resp.GetObjects() {
+			names = append(names, r.GetObject())
+		}
+		return names
+	}
+	tests := []struct {
+		name string
+		req  *SearchObjectsRequest
+		want []string
+	}{
+		{"All", &SearchObjectsRequest{}, []string{"images/c.png", "logs/a.log", "logs/b.log"}},
+		{"Key", &SearchObjectsRequest{KeyContains: "logs/"}, []string{"logs/a.log", "logs/b.log"}},
+		{"Tag", &SearchObjectsRequest{Tags: map[string]string{"env": "prod"}}, []string{"logs/a.log"}},
+		{"Tags", &SearchObjectsRequest{Tags: map[string]string{"env": "prod", "team": "web"}}, []string{}},
+		{"Metadata", &SearchObjectsRequest{Metadata: map[string]string{"OWNER": "alice"}}, []string{"images/c.png", "logs/a.log"}},
+		{"Size", &SearchObjectsRequest{MinSize: 2, MaxSize: 4}, []string{"logs/b.log"}},
+		{"Modified", &SearchObjectsRequest{ModifiedAfter: time.Now().Add(time.Hour).Unix()}, []string{}},
+		{"Combined", &SearchObjectsRequest{KeyContains: ".log", Metadata: map[string]string{"owner": "bob"}}, []string{"logs/b.log"}},
+		{"Page", &SearchObjectsRequest{StartAfter: "images/c.png", MaxResults: 1}, []string{"logs/a.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(t, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+	t.Run("Result", func(t *testing.T) {
+		resp, err := gateway.SearchObjects(ctx, &SearchObjectsRequest{Bucket: testBucket1, MaxResults: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.GetIsTruncated() || resp.GetNextStartAfter() != "images/c.png" {
+			t.Fatalf("expected a truncated search, but got %v", resp)
+		}
+		resp, err = gateway.SearchObjects(ctx, &SearchObjectsRequest{Bucket: testBucket1, Tags: map[string]string{"team": "infra"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := resp.GetObjects()[0]
+		if r.GetSize_() != 1 || r.GetMetadata()["owner"] != "alice" || r.GetTags()["env"] != "prod" {
+			t.Fatalf("unexpected search result %v", r)
+		}
+	})
+	t.Run("Index-Updates", func(t *testing.T) {
+		if _, err := gateway.RenameObject(ctx, &RenameObjectRequest{Bucket: testBucket1, Object: "logs/b.log", NewObject: "old/b.log"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, "images/c.png"); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"logs/a.log", "old/b.log"}
+		if got := search(t, &SearchObjectsRequest{}); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+	})
+	t.Run("Rebuild", func(t *testing.T) {
+		// buckets written before the index existed have no index
+		if err := gateway.ledgerStore.deleteIndex(testBucket1); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"logs/a.log", "old/b.log"}
+		if got := search(t, &SearchObjectsRequest{}); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+	})
+}
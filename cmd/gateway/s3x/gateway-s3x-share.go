@@ -0,0 +1,281 @@
+package s3x
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	miniogo "github.com/minio/minio-go/v6"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// sharePathPrefix is the http path under which share link tokens are served
+	sharePathPrefix = "/share/"
+	// defaultShareExpiry is how long share links are valid if no expiry is requested
+	defaultShareExpiry = time.Hour
+	// maxShareExpiry is the longest valid share link, which is also the limit of S3 presigned urls
+	maxShareExpiry = 7 * 24 * time.Hour
+)
+
+// shareToken is the signed payload of a token share link
+type shareToken struct {
+	Bucket             string `json:"b"`
+	Object             string `json:"o"`
+	Expires            int64  `json:"e"`
+	ContentDisposition string `json:"d,omitempty"`
+	ContentType        string `json:"t,omitempty"`
+	CacheControl       string `json:"c,omitempty"`
+}
+
+// newTokenKey returns a key used to sign tokens for the given purpose, it is derived from the gateway secret key
+// so tokens stay valid across restarts, or is random if the gateway has no secret key.
+func newTokenKey(creds auth.Credentials, purpose string) ([]byte, error) {
+	if creds.SecretKey != "" {
+		sum := sha256.Sum256([]byte(purpose + ":" + creds.SecretKey))
+		return sum[:], nil
+	}
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CreateShareLink creates a time-limited download link for an object, either as a token url served by
+// the http api, or as an S3 presigned url signed with the gateway credentials.
+func (x *xObjects) CreateShareLink(ctx context.Context, req *ShareLinkRequest) (*ShareLinkResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	expiry := defaultShareExpiry
+	if req.GetExpiresSeconds() != 0 {
+		expiry = time.Duration(req.GetExpiresSeconds()) * time.Second
+	}
+	if expiry <= 0 || expiry > maxShareExpiry {
+		return nil, status.Errorf(codes.InvalidArgument, "expiry must be between 1 second and %v", maxShareExpiry)
+	}
+	// the link grants what the caller could do with its own credentials
+	if err := callerAllowed(ctx, policy.GetObjectAction, req.GetBucket(), req.GetObject()); err != nil {
+		return nil, err
+	}
+	if _, err := x.ledgerStore.ObjectInfo(ctx, req.GetBucket(), req.GetObject()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	expires := x.clock.Now().Add(expiry)
+	var link string
+	if req.GetPresigned() {
+		u, err := x.presignShareLink(req, expiry)
+		if err != nil {
+			return nil, err
+		}
+		link = u
+	} else {
+		token, err := x.signShareToken(shareToken{
+			Bucket:             req.GetBucket(),
+			Object:             req.GetObject(),
+			Expires:            expires.Unix(),
+			ContentDisposition: req.GetContentDisposition(),
+			ContentType:        req.GetContentType(),
+			CacheControl:       req.GetCacheControl(),
+		})
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		link = strings.TrimSuffix(req.GetEndpoint(), "/") + sharePathPrefix + token
+	}
+	return &ShareLinkResponse{
+		Url:     link,
+		Expires: expires.Unix(),
+	}, nil
+}
+
+// presignShareLink returns an S3 presigned GET url, which is validated by the S3 api of the gateway
+func (x *xObjects) presignShareLink(req *ShareLinkRequest, expiry time.Duration) (string, error) {
+	if x.creds.AccessKey == "" || x.creds.SecretKey == "" {
+		return "", status.Error(codes.FailedPrecondition, "gateway has no credentials to presign with")
+	}
+	endpoint, err := url.Parse(req.GetEndpoint())
+	if err != nil || endpoint.Host == "" {
+		return "", status.Error(codes.InvalidArgument, "endpoint must be an absolute url for presigned links")
+	}
+	// the url is signed for the region the S3 api checks, a server without a region accepts any
+	region := minio.AuthRegion()
+	if region == "" {
+		region = "us-east-1"
+	}
+	client, err := miniogo.NewWithRegion(endpoint.Host, x.creds.AccessKey, x.creds.SecretKey, endpoint.Scheme == "https", region)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	params := url.Values{}
+	for k, v := range map[string]string{
+		"response-content-disposition": req.GetContentDisposition(),
+		"response-content-type":        req.GetContentType(),
+		"response-cache-control":       req.GetCacheControl(),
+	} {
+		if v != "" {
+			params.Set(k, v)
+		}
+	}
+	u, err := client.PresignedGetObject(req.GetBucket(), req.GetObject(), expiry, params)
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+	return u.String(), nil
+}
+
+// signToken encodes and signs a token payload as base64(payload).base64(signature)
+func signToken(key []byte, v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyToken decodes the payload of a token into v, and returns false if its signature is invalid
+func verifyToken(key []byte, token string, v interface{}) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+// signShareToken encodes and signs a share link token
+func (x *xObjects) signShareToken(t shareToken) (string, error) {
+	return signToken(x.shareKey, t)
+}
+
+// verifyShareToken returns the payload of a token if its signature is valid, expiry is not checked
+func (x *xObjects) verifyShareToken(token string) (*shareToken, bool) {
+	t := &shareToken{}
+	if !verifyToken(x.shareKey, token, t) {
+		return nil, false
+	}
+	return t, true
+}
+
+// ServeShareLink serves GET and HEAD requests for /share/<token> with the object the token was created for,
+// until the token expires. Range and conditional requests are supported.
+func (x *xObjects) ServeShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	t, ok := x.verifyShareToken(strings.TrimPrefix(r.URL.Path, sharePathPrefix))
+	if !ok {
+		http.Error(w, "invalid share link", http.StatusForbidden)
+		return
+	}
+	if x.clock.Now().Unix() > t.Expires {
+		http.Error(w, "share link expired", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+	cw := &countingResponseWriter{ResponseWriter: w}
+	defer func() { x.meter.transfer(ctx, t.Bucket, 0, cw.n) }()
+	w = cw
+	obj, err := x.ledgerStore.Object(ctx, t.Bucket, t.Object)
+	if err == ErrLedgerBucketDoesNotExist || err == ErrLedgerObjectDoesNotExist {
+		http.Error(w, "object does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	info := obj.GetObjectInfo()
+	header := http.Header{}
+	if info.GetContentType() != "" {
+		header.Set("Content-Type", info.GetContentType())
+	}
+	// the overrides are part of the signed token, so they can not be changed by the link holder
+	for h, v := range map[string]string{
+		"Content-Disposition": t.ContentDisposition,
+		"Content-Type":        t.ContentType,
+		"Cache-Control":       t.CacheControl,
+	} {
+		if v != "" {
+			header.Set(h, v)
+		}
+	}
+	// the S3 ETag, the data hash would give access to the data through the /ipfs/ path after the link expired
+	header.Set("Etag", `"`+s3ETag(info.GetEtag())+`"`)
+	if x.publicGateway.offloadsObject(obj) {
+		x.publicGateway.serve(w, r, obj.GetDataHash(), t.Object, header)
+		return
+	}
+	for h := range header {
+		w.Header().Set(h, header.Get(h))
+	}
+	size := info.GetSize_()
+	c := info.GetCompression()
+	if c != "" {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if obj.Erasure != nil || c != "" && !acceptsEncoding(r.Header, c) {
+		// decompressed and erasure coded data can not be seeked, so ranges are not supported
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		if r.Method == http.MethodHead {
+			return
+		}
+		// the resolved object is read, so the data matches the headers even if the object was replaced
+		config, err := x.ledgerStore.GetBucketConfig(ctx, t.Bucket)
+		if err != nil {
+			return
+		}
+		_ = x.readObject(ctx, t.Bucket, t.Object, obj, 0, 0, x.bandwidth.writer(ctx, t.Bucket, config, w), minio.ObjectOptions{})
+		return
+	}
+	if c != "" {
+		w.Header().Set("Content-Encoding", c)
+		size = info.GetCompressedSize()
+	}
+	fileClient, _, err := x.dataClients(obj.GetDataHash())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rs := &ipfsReadSeeker{
+		ctx:        ctx,
+		fileClient: fileClient,
+		hash:       obj.GetDataHash(),
+		size:       size,
+	}
+	defer rs.Close()
+	http.ServeContent(w, r, t.Object, info.GetModTime(), rs)
+}
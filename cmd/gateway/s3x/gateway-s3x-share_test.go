@@ -0,0 +1,227 @@
+package s3x
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_Share_Badger(t *testing.T) {
+	testS3XShare(t, DSTypeBadger)
+}
+func TestS3X_Share_Crdt(t *testing.T) {
+	testS3XShare(t, DSTypeCrdt)
+}
+func testS3XShare(t *testing.T, dsType DSType) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	const data = "shared data"
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("CreateShareLink", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			req      *ShareLinkRequest
+			wantCode codes.Code
+		}{
+			{"Fail-Empty-Object", &ShareLinkRequest{Bucket: testBucket1}, codes.InvalidArgument},
+			{"Fail-Expiry", &ShareLinkRequest{Bucket: testBucket1, Object: testObject1, ExpiresSeconds: 8 * 24 * 60 * 60}, codes.InvalidArgument},
+			{"Fail-No-Object", &ShareLinkRequest{Bucket: testBucket1, Object: "nosuchobject"}, codes.NotFound},
+			{"Fail-No-Credentials", &ShareLinkRequest{Bucket: testBucket1, Object: testObject1, Presigned: true, Endpoint: "http://localhost:9000"}, codes.FailedPrecondition},
+			{"Success-Token", &ShareLinkRequest{Bucket: testBucket1, Object: testObject1}, codes.OK},
+		}
+		denied := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.PutObjectAction}})
+		if _, err := gateway.CreateShareLink(denied, &ShareLinkRequest{Bucket: testBucket1, Object: testObject1}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected a caller without GetObject to be denied, but got %v", err)
+		}
+		if _, err := gateway.CreateShareLink(context.Background(), &ShareLinkRequest{Bucket: testBucket1, Object: testObject1}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected an unauthenticated call to fail, but got %v", err)
+		}
+		allowed := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.GetObjectAction}})
+		if _, err := gateway.CreateShareLink(allowed, &ShareLinkRequest{Bucket: testBucket1, Object: testObject1}); err != nil {
+			t.Fatalf("expected a caller with GetObject to be allowed, but got %v", err)
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := gateway.CreateShareLink(ctx, tt.req); status.Code(err) != tt.wantCode {
+					t.Fatalf("CreateShareLink() err %v, want code %v", err, tt.wantCode)
+				}
+			})
+		}
+	})
+	t.Run("ServeShareLink", func(t *testing.T) {
+		resp, err := gateway.CreateShareLink(ctx, &ShareLinkRequest{
+			Bucket:             testBucket1,
+			Object:             testObject1,
+			ContentDisposition: `attachment; filename="data.txt"`,
+			ContentType:        "application/octet-stream",
+			CacheControl:       "no-store",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expired, err := gateway.signShareToken(shareToken{Bucket: testBucket1, Object: testObject1, Expires: gateway.clock.Now().Add(-time.Minute).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name       string
+			path       string
+			wantStatus int
+			wantBody   string
+		}{
+			{"Success", resp.GetUrl(), http.StatusOK, data},
+			{"Fail-Tampered", resp.GetUrl() + "x", http.StatusForbidden, ""},
+			{"Fail-Expired", sharePathPrefix + expired, http.StatusForbidden, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				gateway.ServeShareLink(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("expected status %v, but got %v: %s", tt.wantStatus, rec.Code, rec.Body.String())
+				}
+				if tt.wantBody == "" {
+					return
+				}
+				if rec.Body.String() != tt.wantBody {
+					t.Fatalf("expected body %q, but got %q", tt.wantBody, rec.Body.String())
+				}
+				if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="data.txt"` {
+					t.Fatalf("unexpected Content-Disposition %q", cd)
+				}
+				if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+					t.Fatalf("unexpected Content-Type %q", ct)
+				}
+				if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+					t.Fatalf("unexpected Cache-Control %q", cc)
+				}
+			})
+		}
+	})
+	t.Run("IfRange", func(t *testing.T) {
+		resp, err := gateway.CreateShareLink(ctx, &ShareLinkRequest{Bucket: testBucket1, Object: testObject1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		gateway.ServeShareLink(rec, httptest.NewRequest(http.MethodGet, resp.GetUrl(), nil))
+		etag := rec.Header().Get("Etag")
+		tests := []struct {
+			name       string
+			ifRange    string
+			wantStatus int
+			wantBody   string
+		}{
+			{"Unchanged", etag, http.StatusPartialContent, data[6:]},
+			{"Changed", `"changed"`, http.StatusOK, data},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, resp.GetUrl(), nil)
+				req.Header.Set("Range", "bytes=6-")
+				req.Header.Set("If-Range", tt.ifRange)
+				rec := httptest.NewRecorder()
+				gateway.ServeShareLink(rec, req)
+				if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+					t.Fatalf("expected %v %q, but got %v %q", tt.wantStatus, tt.wantBody, rec.Code, rec.Body.String())
+				}
+			})
+		}
+	})
+	t.Run("Presigned", func(t *testing.T) {
+		gateway.creds = auth.Credentials{AccessKey: "minio", SecretKey: "miniostorage"}
+		resp, err := gateway.CreateShareLink(ctx, &ShareLinkRequest{
+			Bucket:             testBucket1,
+			Object:             testObject1,
+			Presigned:          true,
+			Endpoint:           "https://s3x.example.com",
+			ContentDisposition: "attachment",
+			ContentType:        "text/plain",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(resp.GetUrl())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Host != "s3x.example.com" || !strings.HasPrefix(u.Path, "/"+testBucket1+"/") {
+			t.Fatalf("unexpected presigned url %v", u)
+		}
+		q := u.Query()
+		if q.Get("X-Amz-Signature") == "" || q.Get("response-content-disposition") != "attachment" || q.Get("response-content-type") != "text/plain" {
+			t.Fatalf("expected a signed url with response header overrides, but got %v", u)
+		}
+		if q.Get("response-cache-control") != "" {
+			t.Fatalf("expected no cache control override, but got %v", u)
+		}
+	})
+}
+
+func TestS3X_ShareLinkClock(t *testing.T) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	ls, _, _ := newFaultyLedger(t)
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	x := &xObjects{ledgerStore: ls, clock: fixedClock(now), shareKey: []byte("share key")}
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	obj := testLedgerObject(testBucket1, testObject1, "data")
+	obj.ObjectInfo.Etag = "8d777f385d3dfec8815d20f7496026dc"
+	// compressed objects are answered without reading their data for HEAD requests
+	obj.ObjectInfo.Compression = "gzip"
+	if err := ls.PutObject(ctx, testBucket1, testObject1, obj); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := x.CreateShareLink(ctx, &ShareLinkRequest{Bucket: testBucket1, Object: testObject1, ExpiresSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Minute).Unix(); resp.GetExpires() != want {
+		t.Fatalf("expected the link to expire a minute after the time of the gateway clock, %d, but got %d", want, resp.GetExpires())
+	}
+	// the link returns the S3 ETag of the object, not the data hash that can be read through the /ipfs/ path
+	rec := httptest.NewRecorder()
+	x.ServeShareLink(rec, httptest.NewRequest(http.MethodHead, resp.GetUrl(), nil))
+	if etag := rec.Header().Get("Etag"); etag != `"`+s3ETag(obj.ObjectInfo.Etag)+`"` {
+		t.Fatalf("expected the S3 ETag of the object, but got %q", etag)
+	}
+	// the object is removed, so a link that did not expire is answered with not found
+	if _, err := ls.RemoveObjects(ctx, testBucket1, testObject1); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		now  time.Time
+		code int
+	}{
+		{now.Add(30 * time.Second), http.StatusNotFound},
+		{now.Add(2 * time.Minute), http.StatusForbidden},
+	} {
+		x.clock = fixedClock(test.now)
+		rec := httptest.NewRecorder()
+		x.ServeShareLink(rec, httptest.NewRequest(http.MethodGet, resp.GetUrl(), nil))
+		if rec.Code != test.code {
+			t.Fatalf("expected status %d at %v, but got %d: %s", test.code, test.now, rec.Code, rec.Body.String())
+		}
+	}
+}
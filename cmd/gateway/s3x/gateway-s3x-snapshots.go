@@ -0,0 +1,216 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// snapshotCheckInterval is how often buckets are checked for due scheduled snapshots
+	snapshotCheckInterval = time.Minute
+
+	snapshotHourly = "hourly"
+	snapshotDaily  = "daily"
+	snapshotWeekly = "weekly"
+)
+
+// snapshotSchedules are the snapshot schedules and their periods, periods start at multiples of their duration
+// since the zero time in UTC, so daily snapshots are taken after midnight, and weekly snapshots on mondays.
+var snapshotSchedules = []struct {
+	name   string
+	period time.Duration
+}{
+	{snapshotHourly, time.Hour},
+	{snapshotDaily, 24 * time.Hour},
+	{snapshotWeekly, 7 * 24 * time.Hour},
+}
+
+// snapshotRetention returns the number of snapshots of each schedule kept by a policy
+func snapshotRetention(p *SnapshotPolicy) map[string]int64 {
+	return map[string]int64{
+		snapshotHourly: p.GetHourly(),
+		snapshotDaily:  p.GetDaily(),
+		snapshotWeekly: p.GetWeekly(),
+	}
+}
+
+// dueSnapshots returns the schedules of a policy that have no snapshot in their current period
+func dueSnapshots(p *SnapshotPolicy, snapshots []*Snapshot, now time.Time) []string {
+	keep := snapshotRetention(p)
+	var due []string
+	for _, s := range snapshotSchedules {
+		if keep[s.name] <= 0 {
+			continue
+		}
+		start := now.UTC().Truncate(s.period)
+		taken := false
+		for _, snap := range snapshots {
+			if snap.Schedule == s.name && !snap.Created.Before(start) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			due = append(due, s.name)
+		}
+	}
+	return due
+}
+
+// snapshotInfo returns the api representation of a snapshot
+func snapshotInfo(s *Snapshot) *SnapshotInfo {
+	return &SnapshotInfo{
+		Id:       s.Id,
+		Bucket:   s.Bucket,
+		Hash:     s.BucketHash,
+		Created:  s.Created.Unix(),
+		Schedule: s.Schedule,
+		Objects:  s.Objects,
+	}
+}
+
+// SetSnapshotPolicy configures how many hourly, daily, and weekly snapshots of a bucket are taken and kept,
+// existing scheduled snapshots beyond the new retention counts are pruned.
+func (x *xObjects) SetSnapshotPolicy(ctx context.Context, req *SetSnapshotPolicyRequest) (*SetSnapshotPolicyResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	p := req.GetPolicy()
+	if p.GetHourly() < 0 || p.GetDaily() < 0 || p.GetWeekly() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "snapshot retention counts can not be negative")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.SnapshotPolicy = &p
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	pruned, unreferenced, err := x.ledgerStore.PruneSnapshots(ctx, req.GetBucket(), snapshotRetention(&p))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, snapshot-policy: %v, snapshots-pruned: %v", req.GetBucket(), p.String(), len(pruned))
+	resp := &SetSnapshotPolicyResponse{
+		Bucket:       req.GetBucket(),
+		Policy:       p,
+		Unreferenced: unreferenced,
+	}
+	for _, s := range pruned {
+		resp.Pruned = append(resp.Pruned, s.Id)
+	}
+	return resp, nil
+}
+
+// CreateSnapshot records the current hash of a bucket, the snapshot is kept until it is removed by its bucket policy,
+// which never removes snapshots created on request.
+func (x *xObjects) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*SnapshotInfo, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	s, err := x.ledgerStore.CreateSnapshot(ctx, req.GetBucket(), "", x.clock.Now())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, snapshot-id: %s, bucket-hash: %s", req.GetBucket(), s.Id, s.BucketHash)
+	return snapshotInfo(s), nil
+}
+
+// ListSnapshots returns the snapshots of a bucket, oldest first
+func (x *xObjects) ListSnapshots(ctx context.Context, req *ListSnapshotsRequest) (*ListSnapshotsResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	snapshots, err := x.ledgerStore.ListSnapshots(req.GetBucket())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp := &ListSnapshotsResponse{Bucket: req.GetBucket()}
+	for _, s := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, snapshotInfo(s))
+	}
+	return resp, nil
+}
+
+// RestoreSnapshot creates a new bucket with the objects of a snapshot without copying data,
+// the snapshot of a deleted bucket can be restored with the same bucket name.
+func (x *xObjects) RestoreSnapshot(ctx context.Context, req *RestoreSnapshotRequest) (*RestoreSnapshotResponse, error) {
+	if req.GetBucket() == "" || req.GetNewBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot id is empty")
+	}
+	if err := x.names.checkBucketName(req.GetNewBucket()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	b := &Bucket{BucketInfo: BucketInfo{Created: x.clock.Now().UTC()}}
+	hash, n, err := x.ledgerStore.RestoreSnapshot(ctx, req.GetBucket(), req.GetId(), req.GetNewBucket(), b)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, snapshot-id: %s, restored-to: %s, bucket-hash: %s", req.GetBucket(), req.GetId(), req.GetNewBucket(), hash)
+	return &RestoreSnapshotResponse{
+		Bucket:  req.GetNewBucket(),
+		Hash:    hash,
+		Objects: int64(n),
+	}, nil
+}
+
+// snapshotLoop takes the due scheduled snapshots of all buckets at every interval until the gateway shuts down
+func (x *xObjects) snapshotLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			x.takeScheduledSnapshots(x.ctx, x.clock.Now())
+		}
+	}
+}
+
+// takeScheduledSnapshots takes the due snapshots of all buckets with a snapshot policy,
+// and prunes the snapshots beyond the policy retention.
+func (x *xObjects) takeScheduledSnapshots(ctx context.Context, now time.Time) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		log.Printf("failed to take scheduled snapshots: %v", err)
+		return
+	}
+	for _, bucket := range names {
+		config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+		if err != nil || config.GetSnapshotPolicy() == nil {
+			continue
+		}
+		snapshots, err := x.ledgerStore.ListSnapshots(bucket)
+		if err != nil {
+			log.Printf("bucket-name: %s, failed to list snapshots: %v", bucket, err)
+			continue
+		}
+		due := dueSnapshots(config.GetSnapshotPolicy(), snapshots, now)
+		if len(due) == 0 {
+			continue
+		}
+		for _, schedule := range due {
+			s, err := x.ledgerStore.CreateSnapshot(ctx, bucket, schedule, now)
+			if err != nil {
+				log.Printf("bucket-name: %s, failed to take %s snapshot: %v", bucket, schedule, err)
+				continue
+			}
+			log.Printf("bucket-name: %s, snapshot-id: %s, snapshot-schedule: %s", bucket, s.Id, schedule)
+		}
+		pruned, unreferenced, err := x.ledgerStore.PruneSnapshots(ctx, bucket, snapshotRetention(config.GetSnapshotPolicy()))
+		if err != nil {
+			log.Printf("bucket-name: %s, failed to prune snapshots: %v", bucket, err)
+			continue
+		}
+		if len(pruned) > 0 {
+			log.Printf("bucket-name: %s, snapshots-pruned: %v, unreferenced-data: %v", bucket, len(pruned), unreferenced)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package s3x
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Snapshots_Badger(t *testing.T) {
+	testS3XSnapshots(t, DSTypeBadger)
+}
+func TestS3X_Snapshots_Crdt(t *testing.T) {
+	testS3XSnapshots(t, DSTypeCrdt)
+}
+func testS3XSnapshots(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("snapshot data")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataHash := obj.GetDataHash()
+	schedules := func(t *testing.T) []string {
+		resp, err := gateway.ListSnapshots(ctx, &ListSnapshotsRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, s := range resp.GetSnapshots() {
+			names = append(names, s.GetSchedule())
+		}
+		return names
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := gateway.SetSnapshotPolicy(ctx, &SetSnapshotPolicyRequest{Bucket: testBucket1, Policy: SnapshotPolicy{Hourly: -1}}); err == nil {
+			t.Fatal("expected an error for a negative retention")
+		}
+		if _, err := gateway.RestoreSnapshot(ctx, &RestoreSnapshotRequest{Bucket: testBucket1, Id: "missing", NewBucket: testBucket2}); err == nil {
+			t.Fatal("expected an error restoring a missing snapshot")
+		}
+	})
+	t.Run("Scheduled", func(t *testing.T) {
+		if _, err := gateway.SetSnapshotPolicy(ctx, &SetSnapshotPolicyRequest{Bucket: testBucket1, Policy: SnapshotPolicy{Hourly: 2, Daily: 1}}); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2020, 4, 1, 10, 30, 0, 0, time.UTC)
+		gateway.takeScheduledSnapshots(ctx, now)
+		gateway.takeScheduledSnapshots(ctx, now.Add(10*time.Minute)) // nothing is due in the same hour
+		if got, want := schedules(t), []string{snapshotDaily, snapshotHourly}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+		gateway.takeScheduledSnapshots(ctx, now.Add(time.Hour))
+		gateway.takeScheduledSnapshots(ctx, now.Add(2*time.Hour))
+		// the oldest hourly snapshot is pruned
+		if got, want := schedules(t), []string{snapshotDaily, snapshotHourly, snapshotHourly}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+		if n, err := gateway.ledgerStore.DataRefCount(dataHash); err != nil || n != 4 {
+			t.Fatalf("expected 4 references to the object data, but got %v, %v", n, err)
+		}
+	})
+	var manual *SnapshotInfo
+	t.Run("Restore", func(t *testing.T) {
+		manual, err = gateway.CreateSnapshot(ctx, &CreateSnapshotRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := gateway.RestoreSnapshot(ctx, &RestoreSnapshotRequest{Bucket: testBucket1, Id: manual.GetId(), NewBucket: testBucket2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetObjects() != 1 {
+			t.Fatalf("expected 1 restored object, but got %v", resp.GetObjects())
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket2, testObject1, minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket2, testObject1); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Prune", func(t *testing.T) {
+		resp, err := gateway.SetSnapshotPolicy(ctx, &SetSnapshotPolicyRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// snapshots created on request are kept, and still reference the data
+		if len(resp.GetPruned()) != 3 || len(resp.GetUnreferenced()) != 0 {
+			t.Fatalf("expected 3 pruned snapshots without unreferenced data, but got %v", resp)
+		}
+		if got, want := schedules(t), []string{""}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, but got %v", want, got)
+		}
+		unreferenced, err := gateway.ledgerStore.deleteSnapshot(ctx, &Snapshot{Id: manual.GetId(), Bucket: testBucket1, BucketHash: manual.GetHash()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{dataHash}; !reflect.DeepEqual(unreferenced, want) {
+			t.Fatalf("expected unreferenced data %v, but got %v", want, unreferenced)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package s3x
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+/* Design Notes
+---------------
+
+Uploads are streamed to TemporalX as they are received, so the gateway reads the body of an upload as fast as
+TemporalX stores it, and holds the gRPC messages of every upload in progress in memory while it waits. With a
+slow node and many concurrent uploads, that is more memory than small edge deployments have.
+
+If UploadSpoolThreshold is set, the bodies of PutObject and PutObjectPart of at least that many bytes, or of an
+unknown size, are first written to a temporary file in UploadSpoolDir at the speed of the client, and then
+uploaded to TemporalX from the file. The body is verified while it is spooled, so an upload with a wrong
+Content-MD5 or size fails before any data reaches TemporalX. Copies read their data from TemporalX and are not
+spooled.
+
+Spool files are removed when their upload ended, whether it succeeded or not. Spool files left by a gateway that
+stopped during an upload are removed when the gateway starts, so the spool directory must not be shared by
+several gateways.
+*/
+
+// spoolFilePattern is the name pattern of spool files, they are removed when the gateway starts
+const spoolFilePattern = "s3x-spool-*"
+
+// uploadSpool spools the bodies of large uploads to temporary files, nil if spooling is disabled
+type uploadSpool struct {
+	dir       string // the directory of spool files
+	threshold int64  // the minimum size of spooled uploads
+}
+
+// newUploadSpool returns a spool of uploads of at least threshold bytes in dir, the temporary directory if empty,
+// and removes the spool files left in it
+func newUploadSpool(dir string, threshold int64) (*uploadSpool, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	leftover, err := filepath.Glob(filepath.Join(dir, spoolFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range leftover {
+		if err := os.Remove(name); err != nil {
+			return nil, err
+		}
+		log.Printf("removed spool file %v of an interrupted upload", name)
+	}
+	return &uploadSpool{dir: dir, threshold: threshold}, nil
+}
+
+// spool returns the data of r, written to a spool file first if its size is at least the threshold or unknown,
+// remove must be called once the returned reader is no longer used
+func (s *uploadSpool) spool(r io.Reader, size int64) (data io.Reader, remove func(), err error) {
+	if s == nil || (size >= 0 && size < s.threshold) {
+		return r, func() {}, nil
+	}
+	f, err := ioutil.TempFile(s.dir, spoolFilePattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	remove = func() {
+		_ = f.Close()
+		if err := os.Remove(f.Name()); err != nil {
+			log.Printf("failed to remove spool file %v: %v", f.Name(), err)
+		}
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		remove()
+		return nil, nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		remove()
+		return nil, nil, err
+	}
+	return f, remove, nil
+}
@@ -0,0 +1,151 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+)
+
+func TestUploadSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3x-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	leftover := filepath.Join(dir, "s3x-spool-1")
+	if err := ioutil.WriteFile(leftover, []byte("interrupted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newUploadSpool(dir, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("expected the leftover spool file to be removed, but got %v", err)
+	}
+	for _, tt := range []struct {
+		name    string
+		data    string
+		size    int64
+		spooled bool
+	}{
+		{"Small", "small", 5, false},
+		{"Large", "large upload", 12, true},
+		{"Unknown-Size", "small", -1, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, remove, err := s.spool(strings.NewReader(tt.data), tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := data.(*os.File); ok != tt.spooled {
+				t.Fatalf("expected spooled %v, but got %T", tt.spooled, data)
+			}
+			got, err := ioutil.ReadAll(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Fatalf("expected %q, but got %q", tt.data, got)
+			}
+			remove()
+			if files, _ := filepath.Glob(filepath.Join(dir, spoolFilePattern)); len(files) != 0 {
+				t.Fatalf("expected the spool file to be removed, but got %v", files)
+			}
+		})
+	}
+	var disabled *uploadSpool
+	if data, _, err := disabled.spool(strings.NewReader("data"), -1); err != nil {
+		t.Fatal(err)
+	} else if _, ok := data.(*os.File); ok {
+		t.Fatal("expected a disabled spool to not spool uploads")
+	}
+}
+
+func TestS3X_UploadSpool(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "s3x-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if gateway.spool, err = newUploadSpool(dir, 8); err != nil {
+		t.Fatal(err)
+	}
+	assertSpoolEmpty := func() {
+		t.Helper()
+		if files, _ := filepath.Glob(filepath.Join(dir, spoolFilePattern)); len(files) != 0 {
+			t.Fatalf("expected no spool files, but got %v", files)
+		}
+	}
+	read := func(object string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gateway.GetObject(ctx, testBucket1, object, 0, 0, &buf, "", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	data := "a spooled upload"
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertSpoolEmpty()
+	if got := read(testObject1); got != data {
+		t.Fatalf("expected %q, but got %q", data, got)
+	}
+
+	// an upload that fails verification is rejected before it is stored
+	sum := md5.Sum([]byte("other data"))
+	r, err := hash.NewReader(strings.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:]), "", int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, "corrupted", minio.NewPutObjReader(r, nil, nil), minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	assertSpoolEmpty()
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, "corrupted", minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected the object to not exist")
+	}
+
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, "multipart", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := gateway.PutObjectPart(ctx, testBucket1, "multipart", uploadID, 1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSpoolEmpty()
+	if want := md5.Sum([]byte(data)); part.ETag != hex.EncodeToString(want[:]) {
+		t.Fatalf("expected the md5 of the part as ETag, but got %v", part.ETag)
+	}
+	if _, err := gateway.CompleteMultipartUpload(ctx, testBucket1, "multipart", uploadID, []minio.CompletePart{
+		{PartNumber: 1, ETag: part.ETag},
+	}, minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("multipart"); got != data {
+		t.Fatalf("expected %q, but got %q", data, got)
+	}
+}
@@ -0,0 +1,230 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A standby gateway keeps an empty ledger, and ships the ledger root of a primary gateway every standby interval
+by asking the primary to save its ledger root over the extension api. A shipped root is only kept once its block
+can be loaded through the TemporalX node of the standby, and its hash is stored in the ledger datastore of the
+standby, so a restarted standby can still be promoted if the primary is gone. Shipping does not depend on the
+ledger root interval of the primary, the primary saves a new root for every shipment, so at most one standby
+interval of changes to the primary is lost when the standby takes over.
+
+Buckets can not be created on a standby, so its ledger stays empty, every other mutation needs a bucket, and
+all reads find nothing. PromoteStandby stops shipping, recovers the ledger from the last shipped root, and
+then allows buckets to be created. A promotion that fails can be repeated, it resumes the recovery of the same
+root. A promoted gateway must be restarted without the standby primary, a standby refuses to start with a
+ledger that has buckets.
+*/
+
+// dsStandbyKey holds the LedgerRootInfo of the last ledger root shipped to a standby gateway
+var dsStandbyKey = datastore.NewKey("w")
+
+// defaultStandbyInterval is how often a standby gateway ships the ledger root of its primary by default
+const defaultStandbyInterval = time.Minute
+
+var (
+	// errNotStandby is returned when a gateway that is not a standby is promoted
+	errNotStandby = errors.New("the gateway is not a standby")
+	// errNoShippedRoot is returned when a standby is promoted before a ledger root was shipped
+	errNoShippedRoot = errors.New("no ledger root was shipped from the primary")
+	// errStandbyPromoted is returned when a ledger root is shipped to a standby that is being promoted
+	errStandbyPromoted = errors.New("the standby was promoted")
+)
+
+// isStandby returns true while the ledger is the ledger of a standby that was not promoted
+func (ls *ledgerStore) isStandby() bool {
+	return atomic.LoadInt32(&ls.standby) == 1
+}
+
+// setStandby sets whether the ledger is the ledger of a standby
+func (ls *ledgerStore) setStandby(standby bool) {
+	var v int32
+	if standby {
+		v = 1
+	}
+	atomic.StoreInt32(&ls.standby, v)
+}
+
+// hasBuckets returns true if the ledger has at least one bucket
+func (ls *ledgerStore) hasBuckets() (bool, error) {
+	found := false
+	err := ls.forEachEntry(query.Query{Prefix: dsBucketKey.String(), KeysOnly: true, Limit: 1}, func(query.Entry) error {
+		found = true
+		return nil
+	})
+	return found, err
+}
+
+// ledgerStandby ships the ledger roots of a primary gateway to a standby gateway
+type ledgerStandby struct {
+	addr    string
+	primary ExtensionAPIClient
+
+	promote sync.Mutex // serializes promotions
+
+	mu       sync.Mutex
+	promoted bool // set once a promotion started, roots are no longer shipped
+	root     *LedgerRootInfo
+	shipped  time.Time
+	err      error
+	recovery *RecoverLedgerResponse
+}
+
+// newLedgerStandby returns the standby of the primary gateway with the info grpc endpoint addr,
+// the ledger must be empty, and becomes the ledger of the standby
+func newLedgerStandby(ls *ledgerStore, addr string) (*ledgerStandby, error) {
+	has, err := ls.hasBuckets()
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return nil, errors.New("the ledger of a standby must be empty, start a promoted standby without a primary")
+	}
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	s := &ledgerStandby{addr: addr, primary: NewExtensionAPIClient(conn)}
+	data, err := ls.ds.Get(dsStandbyKey)
+	switch err {
+	case nil:
+		s.root = &LedgerRootInfo{}
+		if err := s.root.Unmarshal(data); err != nil {
+			return nil, err
+		}
+	case datastore.ErrNotFound:
+	default:
+		return nil, err
+	}
+	ls.setStandby(true)
+	return s, nil
+}
+
+// shipLedgerRoot saves the ledger root of the primary, and keeps it as the root to recover on promotion
+func (x *xObjects) shipLedgerRoot(ctx context.Context) error {
+	s := x.standby
+	info, err := s.primary.SaveLedgerRoot(ctx, &SaveLedgerRootRequest{})
+	if err == nil {
+		// the root must be reachable by this gateway to be recovered
+		err = ipfsUnmarshal(ctx, x.dagClient, info.GetHash(), &LedgerRoot{})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.promoted {
+		return errStandbyPromoted
+	}
+	if err == nil {
+		var data []byte
+		if data, err = info.Marshal(); err == nil {
+			err = x.ledgerStore.ds.Put(dsStandbyKey, data)
+		}
+	}
+	s.err = err
+	if err != nil {
+		return err
+	}
+	s.root = info
+	s.shipped = x.clock.Now()
+	return nil
+}
+
+// standbyLoop ships the ledger root of the primary every interval until the standby is promoted,
+// or the gateway is shut down
+func (x *xObjects) standbyLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+			err := x.shipLedgerRoot(x.ctx)
+			if err == errStandbyPromoted {
+				return
+			}
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to ship the ledger root from %s: %v", x.standby.addr, err)
+			}
+		}
+	}
+}
+
+// standbyStatus returns the status of the standby
+func (x *xObjects) standbyStatus() *StandbyStatus {
+	s := x.standby
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &StandbyStatus{
+		Primary:  s.addr,
+		Standby:  x.ledgerStore.isStandby(),
+		Root:     s.root,
+		Recovery: s.recovery,
+	}
+	if !s.shipped.IsZero() {
+		st.Shipped = s.shipped.Unix()
+	}
+	if s.err != nil {
+		st.Error = s.err.Error()
+	}
+	return st
+}
+
+// GetStandbyStatus returns the last ledger root shipped from the primary gateway to this standby gateway
+func (x *xObjects) GetStandbyStatus(ctx context.Context, req *StandbyStatusRequest) (*StandbyStatus, error) {
+	if x.standby == nil {
+		return &StandbyStatus{}, nil
+	}
+	return x.standbyStatus(), nil
+}
+
+// PromoteStandby stops shipping ledger roots, and recovers the ledger of this standby gateway from the last
+// shipped root, so it takes over from the primary gateway
+func (x *xObjects) PromoteStandby(ctx context.Context, req *PromoteStandbyRequest) (*StandbyStatus, error) {
+	s := x.standby
+	if s == nil {
+		return nil, status.Error(codes.FailedPrecondition, errNotStandby.Error())
+	}
+	s.promote.Lock()
+	defer s.promote.Unlock()
+	if !x.ledgerStore.isStandby() {
+		return nil, status.Error(codes.FailedPrecondition, errNotStandby.Error())
+	}
+	s.mu.Lock()
+	info := s.root
+	if info == nil {
+		s.mu.Unlock()
+		return nil, status.Error(codes.FailedPrecondition, errNoShippedRoot.Error())
+	}
+	s.promoted = true
+	s.mu.Unlock()
+	root := &LedgerRoot{}
+	if err := ipfsUnmarshal(ctx, x.dagClient, info.GetHash(), root); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	recovery, err := x.ledgerStore.Recover(ctx, root)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	x.ledgerStore.setStandby(false)
+	s.mu.Lock()
+	s.recovery = recovery
+	s.mu.Unlock()
+	log.Printf("promoted-standby: %s, buckets: %v", info.GetHash(), recovery.Buckets)
+	return x.standbyStatus(), nil
+}
@@ -0,0 +1,96 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// primaryClient calls the extension api of a primary gateway in process
+type primaryClient struct {
+	ExtensionAPIClient
+	x *xObjects
+}
+
+func (c primaryClient) SaveLedgerRoot(ctx context.Context, in *SaveLedgerRootRequest, opts ...grpc.CallOption) (*LedgerRootInfo, error) {
+	return c.x.SaveLedgerRoot(ctx, in)
+}
+
+func TestS3X_Standby(t *testing.T) {
+	ctx := context.Background()
+	primaryLedger, _, dag := newFaultyLedger(t)
+	primary := &xObjects{ledgerStore: primaryLedger, dagClient: dag, clock: testClock{}}
+	if _, err := primaryLedger.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := primaryLedger.PutObject(ctx, testBucket1, "a", testLedgerObject(testBucket1, "a", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	ls, _, _ := newFaultyLedger(t)
+	ls.dag = dag // the gateways share the IPFS network
+	standby := &ledgerStandby{addr: "primary", primary: primaryClient{x: primary}}
+	ls.setStandby(true)
+	x := &xObjects{ledgerStore: ls, dagClient: dag, clock: fixedClock(time.Unix(1, 0)), standby: standby}
+	if _, err := ls.CreateBucket(ctx, testBucket2, &Bucket{}); err != ErrLedgerStandby {
+		t.Fatalf("expected %v, but got %v", ErrLedgerStandby, err)
+	}
+	if _, err := x.PromoteStandby(ctx, &PromoteStandbyRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v before a root was shipped, but got %v", codes.FailedPrecondition, err)
+	}
+	if err := x.shipLedgerRoot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st, err := x.GetStandbyStatus(ctx, &StandbyStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Standby || st.Primary != "primary" || st.Root.GetBuckets() != 1 || st.Shipped == 0 || st.Error != "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	shipped, err := primaryLedger.GetBucketHash(testBucket1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// changes after the last shipment are lost on promotion
+	if err := primaryLedger.PutObject(ctx, testBucket1, "b", testLedgerObject(testBucket1, "b", "b")); err != nil {
+		t.Fatal(err)
+	}
+	// a restarted standby still has the shipped root
+	if has, err := ls.hasBuckets(); err != nil || has {
+		t.Fatalf("expected the standby ledger to be empty, but got %v, %v", has, err)
+	}
+	restarted, err := newLedgerStandby(ls, "primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.root.GetHash() != st.Root.GetHash() {
+		t.Fatalf("expected the shipped root %v, but got %v", st.Root.GetHash(), restarted.root.GetHash())
+	}
+	st, err = x.PromoteStandby(ctx, &PromoteStandbyRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Standby || st.Recovery.GetBuckets() != 1 || st.Recovery.GetObjects() != 1 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	if h, err := ls.GetBucketHash(testBucket1); err != nil || h != shipped {
+		t.Fatalf("expected the shipped bucket %v, but got %v, %v", shipped, h, err)
+	}
+	if _, err := ls.CreateBucket(ctx, testBucket2, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.shipLedgerRoot(ctx); err != errStandbyPromoted {
+		t.Fatalf("expected %v, but got %v", errStandbyPromoted, err)
+	}
+	if _, err := x.PromoteStandby(ctx, &PromoteStandbyRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+	if _, err := newLedgerStandby(ls, "primary"); err == nil {
+		t.Fatal("expected a standby with buckets not to start")
+	}
+}
@@ -0,0 +1,162 @@
+package s3x
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+/* Design Notes
+---------------
+
+TemporalX does not report the storage of its node, so StorageInfo reports the data stored by the gateway as
+accounted by the ledger: the objects of every bucket, the trashed objects and noncurrent versions that keep
+their data until they are purged, and the parts of multipart uploads in progress. The total and available
+bytes are reported against the configured capacity, and are omitted if the capacity is not checked. The data
+usage admin api reports the number and size of the current objects of every bucket from the same accounting,
+instead of the data usage crawler of MinIO, which does not run for gateways. Data shared by several objects is
+counted once for each of them, and snapshots and data waiting for garbage collection are not counted.
+
+StorageInfo is called for every health check and metrics scrape, while the trash and versions of every bucket
+are decoded from TemporalX, so the usage is cached for storageUsageTTL, and measured again whenever capacity is
+sampled. TemporalX is reported offline while the usage can not be measured, with the last usage measured.
+*/
+
+// storageUsageTTL is how long the storage usage reported by StorageInfo and DataUsageInfo is cached
+const storageUsageTTL = time.Minute
+
+// bucketUsage is the number and size of the objects a bucket stores on TemporalX
+type bucketUsage struct {
+	objects, objectBytes int64 // current objects
+	retainedUsage
+}
+
+// storageUsage is the data stored by the gateway on TemporalX, as accounted by the ledger
+type storageUsage struct {
+	buckets        map[string]bucketUsage
+	multipartBytes int64     // the parts of multipart uploads in progress
+	updated        time.Time // when the usage was measured
+}
+
+// used returns the size of all data counted in the usage
+func (u storageUsage) used() int64 {
+	used := u.multipartBytes
+	for _, b := range u.buckets {
+		used += b.objectBytes + b.trashedBytes + b.versionBytes
+	}
+	return used
+}
+
+// storageUsageCache holds the last storage usage measured
+type storageUsageCache struct {
+	mu       sync.Mutex
+	usage    storageUsage // the last usage measured successfully
+	err      error        // the error of the last measurement
+	measured time.Time    // when the usage was last measured, zero if never
+}
+
+// get returns the cached usage and the error of its last measurement, measured again if it is older than ttl
+func (c *storageUsageCache) get(ttl time.Duration, measure func() (storageUsage, error)) (storageUsage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.measured.IsZero() || time.Since(c.measured) >= ttl {
+		usage, err := measure()
+		c.measured, c.err = time.Now(), err
+		if err == nil {
+			c.usage = usage
+		}
+	}
+	return c.usage, c.err
+}
+
+// measureStorageUsage returns the number and size of the objects, trashed objects, noncurrent versions,
+// and multipart parts stored by the gateway
+func (x *xObjects) measureStorageUsage(ctx context.Context) (storageUsage, error) {
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		return storageUsage{}, err
+	}
+	usage := storageUsage{
+		buckets: make(map[string]bucketUsage, len(names)),
+		updated: x.clock.Now().UTC(),
+	}
+	for _, bucket := range names {
+		infos, err := x.ledgerStore.SearchObjects(ctx, bucket, func(*ObjectInfo) bool { return true })
+		if err == ErrLedgerBucketDoesNotExist {
+			continue // removed since the names were listed
+		}
+		if err != nil {
+			return storageUsage{}, err
+		}
+		var b bucketUsage
+		for _, info := range infos {
+			b.objects++
+			b.objectBytes += info.GetSize_()
+		}
+		b.retainedUsage, err = x.ledgerStore.RetainedUsage(ctx, bucket)
+		if err == ErrLedgerBucketDoesNotExist {
+			continue
+		}
+		if err != nil {
+			return storageUsage{}, err
+		}
+		usage.buckets[bucket] = b
+	}
+	uploads, err := x.ledgerStore.ListMultipartUploads(ctx)
+	if err != nil {
+		return storageUsage{}, err
+	}
+	for _, m := range uploads {
+		for _, p := range m.ObjectParts {
+			usage.multipartBytes += p.GetSize_()
+		}
+	}
+	return usage, nil
+}
+
+// storageUsage returns the cached storage usage, measured again if it is older than ttl
+func (x *xObjects) storageUsage(ctx context.Context, ttl time.Duration) (storageUsage, error) {
+	return x.usage.get(ttl, func() (storageUsage, error) {
+		return x.measureStorageUsage(ctx)
+	})
+}
+
+// StorageInfo reports the data stored by the gateway on TemporalX, and the configured capacity if it is checked
+func (x *xObjects) StorageInfo(ctx context.Context, local bool) (si minio.StorageInfo) {
+	si.Backend.Type = minio.BackendGateway
+	usage, err := x.storageUsage(ctx, storageUsageTTL)
+	si.Backend.GatewayOnline = err == nil
+	used := usage.used()
+	si.Used = []uint64{uint64(used)}
+	si.MountPaths = []string{x.xAddr}
+	if x.capacity != nil {
+		available := x.capacity.capacity - used
+		if available < 0 {
+			available = 0
+		}
+		si.Total = []uint64{uint64(x.capacity.capacity)}
+		si.Available = []uint64{uint64(available)}
+	}
+	return si
+}
+
+// DataUsageInfo reports the number and size of the current objects of every bucket
+func (x *xObjects) DataUsageInfo(ctx context.Context) (minio.DataUsageInfo, error) {
+	usage, err := x.storageUsage(ctx, storageUsageTTL)
+	if err != nil {
+		return minio.DataUsageInfo{}, err
+	}
+	info := minio.DataUsageInfo{
+		LastUpdate:   usage.updated,
+		BucketsCount: uint64(len(usage.buckets)),
+		BucketsSizes: make(map[string]uint64, len(usage.buckets)),
+	}
+	for bucket, b := range usage.buckets {
+		info.ObjectsCount += uint64(b.objects)
+		info.ObjectsTotalSize += uint64(b.objectBytes)
+		info.BucketsSizes[bucket] = uint64(b.objectBytes)
+	}
+	return info, nil
+}
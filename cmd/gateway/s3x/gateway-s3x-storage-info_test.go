@@ -0,0 +1,89 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_StorageInfo(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, bucket := range []string{testBucket1, testBucket2} {
+		if err := gateway.MakeBucketWithLocation(ctx, bucket, minio.BucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gateway.SetBucketTrash(ctx, &SetBucketTrashRequest{Bucket: testBucket1, RetentionDays: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+		Bucket:     testBucket2,
+		Versioning: VersioningConfig{Enabled: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(bucket, object, data string) {
+		t.Helper()
+		if _, err := gateway.PutObject(ctx, bucket, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(testBucket1, testObject1, "hello")
+	put(testBucket1, "trashed", "trash")
+	if err := gateway.DeleteObject(ctx, testBucket1, "trashed"); err != nil {
+		t.Fatal(err)
+	}
+	put(testBucket2, testObject1, "version")
+	put(testBucket2, testObject1, "current")
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket2, "multipart", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObjectPart(ctx, testBucket2, "multipart", uploadID, 1, getTestPutObjectReader(t, []byte("part")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the trashed object, the noncurrent version, and the part are stored
+	si := gateway.StorageInfo(ctx, false)
+	if !si.Backend.GatewayOnline {
+		t.Fatal("expected TemporalX to be online")
+	}
+	if len(si.Used) != 1 || si.Used[0] != 5+5+7+7+4 {
+		t.Fatalf("unexpected used bytes %v", si.Used)
+	}
+	if len(si.Total) != 0 || len(si.Available) != 0 {
+		t.Fatalf("expected no total without a capacity, but got %v", si.Total)
+	}
+	// the usage is cached
+	put(testBucket1, "cached", "cached")
+	if si := gateway.StorageInfo(ctx, false); si.Used[0] != 28 {
+		t.Fatalf("expected the cached usage, but got %v", si.Used)
+	}
+	gateway.capacity = &capacityChecker{capacity: 100}
+	if err := gateway.sampleCapacity(ctx); err != nil {
+		t.Fatal(err)
+	}
+	si = gateway.StorageInfo(ctx, false)
+	if si.Used[0] != 34 || si.Total[0] != 100 || si.Available[0] != 66 {
+		t.Fatalf("unexpected storage info %+v", si)
+	}
+
+	// data usage only counts the current objects
+	usage, err := gateway.DataUsageInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.BucketsCount != 2 || usage.ObjectsCount != 3 || usage.ObjectsTotalSize != 18 {
+		t.Fatalf("unexpected data usage %+v", usage)
+	}
+	if usage.BucketsSizes[testBucket1] != 11 || usage.BucketsSizes[testBucket2] != 7 {
+		t.Fatalf("unexpected bucket sizes %v", usage.BucketsSizes)
+	}
+}
@@ -0,0 +1,220 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/* Design Notes
+---------------
+
+Each download returned by GetObjectNInfo holds a scheduler slot and a deadline until its reader is closed, and a
+download read through a pipe, or decompressed, runs a goroutine that writes the data of the object. A reader that
+is never closed, or a goroutine that does not exit after its reader was closed, leaks the pipe, the goroutine, and
+the gRPC stream to TemporalX, which used to accumulate without a trace when clients disconnected.
+
+The streamTracker tracks every download from GetObjectNInfo until its reader was closed and its goroutines exited.
+The number of open downloads and of their goroutines are published as gauges, and the lifetime of each download
+when it is closed as a histogram. The watchdog checks the tracked downloads every streamWatchdogInterval, publishes
+the age of the oldest one, and logs the bucket, object, and request id of each download that is still open after
+the configured maximum age, or whose goroutines still run streamGoroutineGrace after it was closed. Each leaked
+download is logged and counted once, and stays tracked, so it is still counted by the gauges until it is released.
+*/
+
+const (
+	// streamWatchdogInterval is how often the watchdog checks the open downloads
+	streamWatchdogInterval = time.Minute
+	// streamGoroutineGrace is how long the goroutines of a download may run after its reader was closed
+	streamGoroutineGrace = time.Minute
+	// defaultStreamMaxAge is how long a download may stay open before it is logged by default
+	defaultStreamMaxAge = time.Hour
+)
+
+var (
+	getStreamsOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "get",
+		Name:      "streams_open",
+		Help:      "Number of downloads whose reader was not closed, or whose goroutines are still running",
+	})
+	getStreamGoroutines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "get",
+		Name:      "stream_goroutines",
+		Help:      "Number of running goroutines that write the data of downloads",
+	})
+	getStreamOldestAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "s3x",
+		Subsystem: "get",
+		Name:      "stream_oldest_age_seconds",
+		Help:      "Age of the oldest open download in seconds, as of the last watchdog check",
+	})
+	getStreamDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "s3x",
+		Subsystem: "get",
+		Name:      "stream_duration_seconds",
+		Help:      "Time from opening to closing the reader of downloads in seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	})
+	getStreamsLeakedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "s3x",
+		Subsystem: "get",
+		Name:      "streams_leaked_total",
+		Help:      "Total number of downloads logged by the watchdog, by whether the reader was not closed or the goroutines did not exit",
+	}, []string{"reason"})
+)
+
+func init() {
+	prometheus.MustRegister(getStreamsOpen, getStreamGoroutines, getStreamOldestAge, getStreamDuration, getStreamsLeakedTotal)
+}
+
+const (
+	// leakNotClosed is the reason of downloads whose reader was not closed
+	leakNotClosed = "not_closed"
+	// leakGoroutines is the reason of downloads whose goroutines did not exit after the reader was closed
+	leakGoroutines = "goroutines"
+)
+
+// streamTracker tracks the downloads of GetObjectNInfo until they are released
+type streamTracker struct {
+	clock Clock
+	// maxAge is how long a download may stay open before it is logged, never if 0
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	streams map[*trackedStream]struct{}
+}
+
+// trackedStream is a download tracked from GetObjectNInfo until its reader was closed and its goroutines exited
+type trackedStream struct {
+	t                         *streamTracker
+	bucket, object, requestID string
+	opened                    time.Time
+
+	// the fields below are guarded by the mutex of the tracker
+	closed     bool
+	closedAt   time.Time
+	goroutines int
+	reported   bool
+}
+
+// newStreamTracker returns a tracker of downloads that logs the downloads that are open for longer than maxAge
+func newStreamTracker(clock Clock, maxAge time.Duration) *streamTracker {
+	return &streamTracker{clock: clock, maxAge: maxAge, streams: make(map[*trackedStream]struct{})}
+}
+
+// open starts tracking a download of an object for the request of ctx
+func (t *streamTracker) open(ctx context.Context, bucket, object string) *trackedStream {
+	if t == nil {
+		return nil
+	}
+	s := &trackedStream{t: t, bucket: bucket, object: object, opened: t.clock.Now()}
+	if info := logger.GetReqInfo(ctx); info != nil {
+		s.requestID = info.RequestID
+	}
+	t.mu.Lock()
+	t.streams[s] = struct{}{}
+	t.mu.Unlock()
+	getStreamsOpen.Inc()
+	return s
+}
+
+// close records that the reader of the download was closed, the download is released once its goroutines exited
+func (s *trackedStream) close() {
+	if s == nil {
+		return
+	}
+	now := s.t.clock.Now()
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed, s.closedAt = true, now
+	getStreamDuration.Observe(now.Sub(s.opened).Seconds())
+	s.releaseLocked()
+}
+
+// goroutineStarted records a goroutine of the download, which must call the returned function when it exits
+func (s *trackedStream) goroutineStarted() (exited func()) {
+	if s == nil {
+		return func() {}
+	}
+	s.t.mu.Lock()
+	s.goroutines++
+	s.t.mu.Unlock()
+	getStreamGoroutines.Inc()
+	return func() {
+		getStreamGoroutines.Dec()
+		s.t.mu.Lock()
+		defer s.t.mu.Unlock()
+		s.goroutines--
+		s.releaseLocked()
+	}
+}
+
+// releaseLocked stops tracking the download if its reader was closed and its goroutines exited
+func (s *trackedStream) releaseLocked() {
+	if !s.closed || s.goroutines > 0 {
+		return
+	}
+	if _, ok := s.t.streams[s]; ok {
+		delete(s.t.streams, s)
+		getStreamsOpen.Dec()
+	}
+}
+
+// check logs the downloads that leaked at now, and publishes the age of the oldest open download.
+// It returns the number of downloads that were logged.
+func (t *streamTracker) check(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var (
+		oldest time.Duration
+		leaked int
+	)
+	for s := range t.streams {
+		age := now.Sub(s.opened)
+		if age > oldest {
+			oldest = age
+		}
+		if s.reported {
+			continue
+		}
+		var reason string
+		switch {
+		case !s.closed && t.maxAge > 0 && age > t.maxAge:
+			reason = leakNotClosed
+		case s.closed && now.Sub(s.closedAt) > streamGoroutineGrace:
+			reason = leakGoroutines
+		default:
+			continue
+		}
+		s.reported = true
+		leaked++
+		getStreamsLeakedTotal.WithLabelValues(reason).Inc()
+		log.Printf("get-stream: leaked, bucket-name: %s, object-name: %s, request-id: %s, age: %v, goroutines: %v, reason: %s",
+			s.bucket, s.object, s.requestID, age.Round(time.Second), s.goroutines, reason)
+	}
+	getStreamOldestAge.Set(oldest.Seconds())
+	return leaked
+}
+
+// streamWatchdogLoop checks the open downloads every interval, until the gateway is shut down
+func (x *xObjects) streamWatchdogLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		x.streams.check(x.clock.Now())
+	}
+}
@@ -0,0 +1,113 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+)
+
+// tracked returns the number of downloads tracked by t
+func (t *streamTracker) tracked() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.streams)
+}
+
+func TestStreamTracker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newStreamTracker(fixedClock(start), time.Hour)
+	ctx := logger.SetReqInfo(context.Background(), &logger.ReqInfo{RequestID: "request"})
+
+	abandoned := tracker.open(ctx, testBucket1, "abandoned")
+	if abandoned.requestID != "request" {
+		t.Fatalf("expected the request id of the context, got %q", abandoned.requestID)
+	}
+	closed := tracker.open(ctx, testBucket1, "closed")
+	stuck := tracker.open(ctx, testBucket1, "stuck")
+	exited := stuck.goroutineStarted()
+	closed.goroutineStarted()()
+	closed.close()
+	closed.close()
+	tracker.clock = fixedClock(start.Add(time.Minute))
+	stuck.close()
+	if n := tracker.tracked(); n != 2 {
+		t.Fatalf("expected the abandoned and the stuck download to be tracked, got %v", n)
+	}
+
+	// nothing leaked before the grace period and the max age passed
+	if n := tracker.check(start.Add(time.Minute)); n != 0 {
+		t.Fatalf("expected no leaks, got %v", n)
+	}
+	if n := tracker.check(start.Add(2*time.Minute + time.Second)); n != 1 {
+		t.Fatalf("expected the stuck download to leak, got %v", n)
+	}
+	if n := tracker.check(start.Add(time.Hour + time.Second)); n != 1 {
+		t.Fatalf("expected the abandoned download to leak, got %v", n)
+	}
+	// leaks are logged once
+	if n := tracker.check(start.Add(2 * time.Hour)); n != 0 {
+		t.Fatalf("expected no new leaks, got %v", n)
+	}
+
+	exited()
+	abandoned.close()
+	if n := tracker.tracked(); n != 0 {
+		t.Fatalf("expected all downloads to be released, got %v", n)
+	}
+
+	// the reader age is not checked without a max age
+	tracker = newStreamTracker(fixedClock(start), 0)
+	tracker.open(ctx, testBucket1, testObject1)
+	if n := tracker.check(start.Add(24 * time.Hour)); n != 0 {
+		t.Fatalf("expected no leaks without a max age, got %v", n)
+	}
+
+	// a nil download is not tracked
+	var untracked *trackedStream
+	untracked.goroutineStarted()()
+	untracked.close()
+}
+
+func TestS3X_StreamTracker(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("s"), 1024*1024)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, data), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a partial read through the pipe starts the goroutine of the download
+	gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, nil, 0, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gr.Read(make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	if n := gateway.streams.tracked(); n != 1 {
+		t.Fatalf("expected the download to be tracked, got %v", n)
+	}
+	if err := gr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the goroutine exits once the pipe was closed
+	deadline := time.Now().Add(10 * time.Second)
+	for gateway.streams.tracked() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the download to be released after it was closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
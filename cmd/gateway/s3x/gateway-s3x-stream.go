@@ -0,0 +1,106 @@
+package s3x
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+/* Design Notes
+---------------
+
+GetObjectNInfo returns an objectStream instead of the read end of a pipe. The S3 handlers copy the object to the
+response with io.Copy, which calls WriteTo, so the gRPC download writes its blobs to the response writer directly,
+on the goroutine of the request, without handing each blob over a pipe to be copied again. Readers that call Read
+instead, such as object copies, get the data through a pipe as before.
+
+Both ways write through a buffer of GetBufferSize bytes, so the small writes of the gRPC stream and of
+decompression reach the response in large writes. The buffer is flushed when the object was written, and is
+allocated for each download, so its size is limited to maxGetBufferSize.
+*/
+
+// maxGetBufferSize is the largest write buffer of downloads, each download allocates its own buffer
+const maxGetBufferSize = 64 * 1024 * 1024
+
+// objectStream streams the data written by write to a writer with WriteTo, or through a pipe to Read
+type objectStream struct {
+	write   func(w io.Writer) error
+	bufSize int
+	// tracked tracks the goroutine of the pipe, untracked if nil
+	tracked *trackedStream
+
+	once sync.Once
+	pr   *io.PipeReader
+	// n is the number of streamed bytes
+	n int64
+}
+
+// newObjectStream returns a stream of the data written by write, buffered by bufSize bytes, unbuffered if 0
+func newObjectStream(write func(w io.Writer) error, bufSize int) *objectStream {
+	return &objectStream{write: write, bufSize: bufSize}
+}
+
+// Read reads the data through a pipe, started by the first read
+func (s *objectStream) Read(p []byte) (int, error) {
+	s.once.Do(func() {
+		pr, pw := io.Pipe()
+		s.pr = pr
+		exited := s.tracked.goroutineStarted()
+		go func() {
+			defer exited()
+			_ = pw.CloseWithError(s.writeBuffered(pw))
+		}()
+	})
+	n, err := s.pr.Read(p)
+	s.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes the data to w directly, unless it is already read through the pipe
+func (s *objectStream) WriteTo(w io.Writer) (int64, error) {
+	direct := false
+	s.once.Do(func() { direct = true })
+	if !direct {
+		// only the reader of the stream, so the copy does not call WriteTo again
+		return io.Copy(w, struct{ io.Reader }{s})
+	}
+	cw := &countingWriter{w: w}
+	err := s.writeBuffered(cw)
+	s.n += cw.n
+	return cw.n, err
+}
+
+// Close stops the download if the data is read through the pipe, and prevents it from starting otherwise
+func (s *objectStream) Close() error {
+	s.once.Do(func() {
+		s.pr, _ = io.Pipe()
+	})
+	if s.pr != nil {
+		return s.pr.Close()
+	}
+	return nil
+}
+
+// writeBuffered writes the data to w through the write buffer
+func (s *objectStream) writeBuffered(w io.Writer) error {
+	if s.bufSize <= 0 {
+		return s.write(w)
+	}
+	bw := bufio.NewWriterSize(w, s.bufSize)
+	if err := s.write(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,165 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+// smallWritesWriter counts the writes to it
+type smallWritesWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *smallWritesWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestObjectStream(t *testing.T) {
+	data := []byte("0123456789")
+	// write writes the data a byte at a time, as the blobs of a download
+	write := func(w io.Writer) error {
+		for i := range data {
+			if _, err := w.Write(data[i : i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	t.Run("WriteTo", func(t *testing.T) {
+		for _, tt := range []struct {
+			bufSize, wantWrites int
+		}{{0, len(data)}, {4, 3}, {1024, 1}} {
+			s := newObjectStream(write, tt.bufSize)
+			var w smallWritesWriter
+			n, err := io.Copy(&w, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(data)) || s.n != n || w.String() != string(data) || w.writes != tt.wantWrites {
+				t.Fatalf("buffer %v: expected %q in %v writes, but got %q in %v writes", tt.bufSize, data, tt.wantWrites, w.String(), w.writes)
+			}
+		}
+	})
+	t.Run("Read", func(t *testing.T) {
+		s := newObjectStream(write, 4)
+		got, err := ioutil.ReadAll(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) || s.n != int64(len(data)) {
+			t.Fatalf("expected %q, but got %q", data, got)
+		}
+	})
+	t.Run("Read-Then-WriteTo", func(t *testing.T) {
+		s := newObjectStream(write, 0)
+		p := make([]byte, 3)
+		if _, err := io.ReadFull(s, p); err != nil {
+			t.Fatal(err)
+		}
+		var rest bytes.Buffer
+		if _, err := io.Copy(&rest, s); err != nil {
+			t.Fatal(err)
+		}
+		if string(p)+rest.String() != string(data) {
+			t.Fatalf("expected %q, but got %q", data, string(p)+rest.String())
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		failed := errors.New("download failed")
+		s := newObjectStream(func(w io.Writer) error { return failed }, 4)
+		if _, err := io.Copy(ioutil.Discard, s); err != failed {
+			t.Fatalf("expected %v, but got %v", failed, err)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		started := false
+		s := newObjectStream(func(w io.Writer) error {
+			started = true
+			return nil
+		}, 0)
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Read(make([]byte, 1)); err != io.ErrClosedPipe || started {
+			t.Fatalf("expected a closed stream, but got %v", err)
+		}
+	})
+}
+
+// patternReader repeats a pattern, so large objects are uploaded without holding them in memory
+type patternReader struct {
+	pattern []byte
+	off     int
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.pattern[r.off]
+		r.off = (r.off + 1) % len(r.pattern)
+	}
+	return len(p), nil
+}
+
+// BenchmarkS3X_GetObject measures the download throughput of an object of S3X_BENCH_GET_SIZE bytes, 1 GiB by
+// default, streamed directly to the writer of io.Copy or read through a pipe, with several write buffer sizes.
+func BenchmarkS3X_GetObject(b *testing.B) {
+	size := int64(1 << 30)
+	if v := os.Getenv("S3X_BENCH_GET_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = n
+	}
+	ctx := context.Background()
+	gateway := newTestGateway(b, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		b.Fatal(err)
+	}
+	data := io.LimitReader(&patternReader{pattern: []byte("s3x download benchmark ")}, size)
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, minio.NewPutObjReader(getTestHashReader(b, data, size), nil, nil), minio.ObjectOptions{}); err != nil {
+		b.Fatal(err)
+	}
+	for _, bufSize := range []int{0, 32 * 1024, 1024 * 1024} {
+		for _, mode := range []string{"direct", "pipe"} {
+			b.Run(fmt.Sprintf("%s/buffer=%d", mode, bufSize), func(b *testing.B) {
+				gateway.getBufferSize = bufSize
+				b.SetBytes(size)
+				for i := 0; i < b.N; i++ {
+					gr, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, nil, 0, minio.ObjectOptions{})
+					if err != nil {
+						b.Fatal(err)
+					}
+					var src io.Reader = gr
+					if mode == "pipe" {
+						src = struct{ io.Reader }{gr}
+					}
+					n, err := io.Copy(ioutil.Discard, src)
+					gr.Close()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if n != size {
+						b.Fatalf("expected %v bytes, but got %v", size, n)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package s3x
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/* Design Notes
+---------------
+
+S3 requests are given a deadline by the class of their operation, so operators can allow uploads to run for
+hours while listings fail after seconds, instead of relying on the timeouts of the HTTP server, which do not
+know the operation of a request. The deadline is set on the context of the request, which is passed to the
+ledger and to every TemporalX call, so an operation that exceeds it is canceled where it is, and fails with
+OperationTimedOut. Operations that call other operations keep the earliest deadline.
+
+Downloads stream the object after GetObjectNInfo returns, so their deadline also covers reading the object,
+and is only released once the reader is closed. A timeout of 0 disables the deadline of its class.
+*/
+
+// operationClass is a class of S3 operations that share a request timeout
+type operationClass int
+
+const (
+	// opRead reads objects and bucket settings
+	opRead operationClass = iota
+	// opWrite uploads, copies, and deletes objects, and changes buckets and their settings
+	opWrite
+	// opList lists buckets, objects, multipart uploads, and parts
+	opList
+)
+
+// requestTimeouts are the timeouts of S3 requests by operation class
+type requestTimeouts struct {
+	read  time.Duration
+	write time.Duration
+	list  time.Duration
+}
+
+// newRequestTimeouts returns the requestTimeouts of the gateway configuration
+func newRequestTimeouts(read, write, list time.Duration) (requestTimeouts, error) {
+	if read < 0 || write < 0 || list < 0 {
+		return requestTimeouts{}, fmt.Errorf("request timeouts must not be negative")
+	}
+	return requestTimeouts{read: read, write: write, list: list}, nil
+}
+
+// timeout returns the timeout of an operation class, 0 if it has none
+func (t requestTimeouts) timeout(class operationClass) time.Duration {
+	switch class {
+	case opRead:
+		return t.read
+	case opWrite:
+		return t.write
+	case opList:
+		return t.list
+	}
+	return 0
+}
+
+// apply returns a context with the deadline of an operation class, the cancel function must always be called
+func (t requestTimeouts) apply(ctx context.Context, class operationClass) (context.Context, context.CancelFunc) {
+	timeout := t.timeout(class)
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,67 @@
+package s3x
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_RequestTimeouts(t *testing.T) {
+	if _, err := newRequestTimeouts(time.Second, -time.Second, 0); err == nil {
+		t.Fatal("expected negative timeouts to be rejected")
+	}
+	timeouts, err := newRequestTimeouts(time.Second, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for class, want := range map[operationClass]time.Duration{opRead: time.Second, opWrite: time.Hour, opList: 0} {
+		ctx, cancel := timeouts.apply(context.Background(), class)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if ok != (want > 0) || ok && time.Until(deadline) > want {
+			t.Fatalf("expected a timeout of %v for class %v, but got %v, %v", want, class, deadline, ok)
+		}
+	}
+}
+
+func TestS3X_RequestTimeoutsGateway(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte("hello")), minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gateway.timeouts = requestTimeouts{read: time.Nanosecond}
+	if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err != (minio.OperationTimedOut{}) {
+		t.Fatalf("expected %v, but got %v", minio.OperationTimedOut{}, err)
+	}
+	// other classes are not affected
+	if _, err := gateway.ListObjects(ctx, testBucket1, "", "", "", 10); err != nil {
+		t.Fatal(err)
+	}
+	// the deadline of a download lasts until its reader is closed
+	gateway.timeouts = requestTimeouts{read: time.Minute}
+	r, err := gateway.GetObjectNInfo(ctx, testBucket1, testObject1, nil, http.Header{}, minio.LockType(0), minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("expected hello, but got %s", data)
+	}
+}
@@ -0,0 +1,227 @@
+package s3x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Buckets can declare after how many days the data of their objects is moved from the main TemporalX node to a
+cheaper cold TemporalX cluster. The data is copied to the cold cluster, recorded as cold in the ledger, and then
+removed from the main node, the ledger entry of the object stays the same except for its "COLD" storage class.
+Since objects that share data share where it is stored, the data of younger objects that share it moves with
+the first object that is transitioned, and reads of all of them go to the cold cluster.
+
+Replicated and erasure coded objects are already spread across nodes, so they are not transitioned.
+*/
+
+const (
+	// coldStorageClass is the storage class of objects whose data was moved to the cold cluster
+	coldStorageClass = "COLD"
+	// defaultTransitionInterval is how often objects are transitioned to the cold cluster by default
+	defaultTransitionInterval = time.Hour
+)
+
+// errColdNotConfigured is returned when data was moved to the cold cluster, or a bucket transitions objects,
+// without a cold cluster endpoint
+var errColdNotConfigured = errors.New("no cold cluster endpoint is configured")
+
+// coldStore is the TemporalX cluster transitioned object data is moved to
+type coldStore struct {
+	addr       string
+	fileClient pb.FileAPIClient
+	dagClient  pb.NodeAPIClient
+}
+
+// SetBucketTransition sets after how many days the data of objects of a bucket is moved to the cold cluster,
+// 0 disables transitions, objects that were already transitioned stay in the cold cluster.
+func (x *xObjects) SetBucketTransition(ctx context.Context, req *SetBucketTransitionRequest) (*SetBucketTransitionResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetDays() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "days can not be negative")
+	}
+	if req.GetDays() > 0 && x.cold == nil {
+		return nil, status.Error(codes.FailedPrecondition, errColdNotConfigured.Error())
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.TransitionDays = req.GetDays()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, transition-days: %v", req.GetBucket(), req.GetDays())
+	return &SetBucketTransitionResponse{
+		Bucket:       req.GetBucket(),
+		Days:         req.GetDays(),
+		StorageClass: coldStorageClass,
+	}, nil
+}
+
+// dataClients returns the clients of the TemporalX node that stores the data of hash,
+// the cold cluster if the data was moved there, otherwise the main node
+func (x *xObjects) dataClients(hash string) (pb.FileAPIClient, pb.NodeAPIClient, error) {
+	if hash == "" {
+		return x.fileClient, x.dagClient, nil
+	}
+	c, ok, err := x.ledgerStore.ColdData(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return x.fileClient, x.dagClient, nil
+	}
+	if x.cold == nil {
+		return nil, nil, fmt.Errorf("data %v was moved to %v: %w", hash, c.Node, errColdNotConfigured)
+	}
+	return x.cold.fileClient, x.cold.dagClient, nil
+}
+
+// transitionLoop moves the data of objects to the cold cluster every interval until the gateway is shut down
+func (x *xObjects) transitionLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := x.transitionObjects(x.ctx, now)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to transition objects: %v", err)
+			}
+			if n > 0 {
+				log.Printf("transitioned %v objects", n)
+			}
+		}
+	}
+}
+
+// transitionObjects moves the data of the objects of all buckets with transition days that were last modified
+// at least that many days before now to the cold cluster, and returns the number of transitioned objects
+func (x *xObjects) transitionObjects(ctx context.Context, now time.Time) (int, error) {
+	if x.cold == nil {
+		return 0, nil
+	}
+	names, err := x.ledgerStore.GetBucketNames()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, bucket := range names {
+		config, err := x.ledgerStore.GetBucketConfig(ctx, bucket)
+		if err != nil || config.GetTransitionDays() <= 0 {
+			continue
+		}
+		n, err := x.transitionBucket(ctx, bucket, now.AddDate(0, 0, -int(config.GetTransitionDays())))
+		count += n
+		if err != nil {
+			if ctx.Err() != nil {
+				return count, err
+			}
+			log.Printf("bucket-name: %s, failed to transition objects: %v", bucket, err)
+		}
+	}
+	return count, nil
+}
+
+// transitionBucket moves the data of the objects of a bucket last modified before cutoff to the cold cluster
+func (x *xObjects) transitionBucket(ctx context.Context, bucket string, cutoff time.Time) (int, error) {
+	hashes, unlock, err := x.ledgerStore.GetObjectHashes(ctx, bucket)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	unlock()
+	var count int
+	for _, name := range names {
+		obj, err := x.ledgerStore.Object(ctx, bucket, name)
+		if err != nil {
+			return count, err
+		}
+		if obj == nil || obj.GetDataHash() == "" || len(obj.GetReplicaNodes()) > 0 ||
+			obj.ObjectInfo.StorageClass == coldStorageClass || !obj.ObjectInfo.ModTime.Before(cutoff) {
+			continue // removed while transitioning, erasure coded, replicated, already cold, or too young
+		}
+		if err := x.moveToCold(ctx, obj.GetDataHash()); err != nil {
+			return count, err
+		}
+		if err := x.markObjectCold(ctx, bucket, name, obj.GetDataHash()); err != nil {
+			log.Printf("bucket-name: %s, object-name: %s, failed to transition: %v", bucket, name, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// moveToCold copies data to the cold cluster, records it as cold, and removes it from the main node,
+// data that is already cold is not moved again
+func (x *xObjects) moveToCold(ctx context.Context, hash string) error {
+	if _, ok, err := x.ledgerStore.ColdData(hash); err != nil || ok {
+		return err
+	}
+	if err := x.copyToCold(ctx, hash); err != nil {
+		return err
+	}
+	recorded, err := x.ledgerStore.PutColdData(hash, &ColdData{
+		Node:         x.cold.addr,
+		Transitioned: x.clock.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		// the data was collected while it was copied
+		return removeBlock(ctx, x.cold.dagClient, hash)
+	}
+	return removeBlock(ctx, x.dagClient, hash)
+}
+
+// copyToCold copies data from the main node to the cold cluster
+func (x *xObjects) copyToCold(ctx context.Context, hash string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := ipfsFileDownload(ctx, x.fileClient, pw, hash, 0, 0)
+		_ = pw.CloseWithError(err)
+	}()
+	got, _, err := ipfsFileUpload(ctx, x.cold.fileClient, pr)
+	if err != nil {
+		return fmt.Errorf("failed to copy data to cold cluster %v: %v", x.cold.addr, err)
+	}
+	if got != hash {
+		return fmt.Errorf("cold cluster %v stored data as %v instead of %v", x.cold.addr, got, hash)
+	}
+	return nil
+}
+
+// markObjectCold sets the storage class of an object to the cold storage class, unless its data changed
+func (x *xObjects) markObjectCold(ctx context.Context, bucket, object, dataHash string) error {
+	defer x.ledgerStore.locker.write(bucket)()
+	obj, err := x.ledgerStore.object(ctx, bucket, object)
+	if err != nil {
+		return err
+	}
+	if obj == nil || obj.GetDataHash() != dataHash {
+		return errors.New("object was modified while it was transitioned")
+	}
+	obj.ObjectInfo.StorageClass = coldStorageClass
+	return x.ledgerStore.putObject(ctx, bucket, object, obj)
+}
@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/RTradeLtd/TxPB/v3/go"
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingFileClient counts the downloads from a TemporalX node
+type countingFileClient struct {
+	pb.FileAPIClient
+	downloads int32
+}
+
+func (c *countingFileClient) DownloadFile(ctx context.Context, in *pb.DownloadRequest, opts ...grpc.CallOption) (pb.FileAPI_DownloadFileClient, error) {
+	atomic.AddInt32(&c.downloads, 1)
+	return c.FileAPIClient.DownloadFile(ctx, in, opts...)
+}
+
+func TestS3X_Transition(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.SetBucketTransition(ctx, &SetBucketTransitionRequest{Bucket: testBucket1, Days: 30}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v without a cold cluster, but got %v", codes.FailedPrecondition, err)
+	}
+	cold := &countingFileClient{FileAPIClient: gateway.fileClient}
+	gateway.cold = &coldStore{addr: "cold", fileClient: cold, dagClient: gateway.dagClient}
+	if _, err := gateway.SetBucketTransition(ctx, &SetBucketTransitionRequest{Bucket: testBucket1, Days: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+	}
+	resp, err := gateway.SetBucketTransition(ctx, &SetBucketTransitionRequest{Bucket: testBucket1, Days: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StorageClass != coldStorageClass {
+		t.Fatalf("unexpected storage class %v", resp.StorageClass)
+	}
+
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	put := func(object, data string, modTime time.Time) {
+		t.Helper()
+		gateway.clock = fixedClock(modTime)
+		if _, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("old", "transitioned data", created)
+	put("young", "hot data", created.AddDate(0, 0, 20))
+	n, err := gateway.transitionObjects(ctx, created.AddDate(0, 0, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 transitioned object, but got %v", n)
+	}
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, "old", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.StorageClass != coldStorageClass {
+		t.Fatalf("expected storage class %v, but got %v", coldStorageClass, info.StorageClass)
+	}
+	hash, _, err := gateway.ledgerStore.GetObjectDataHash(ctx, testBucket1, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok, err := gateway.ledgerStore.ColdData(hash); err != nil || !ok || c.Node != "cold" {
+		t.Fatalf("expected the data to be recorded as cold, but got %v, %v, %v", c, ok, err)
+	}
+	// the test node is also the cold cluster, so the data removed from the main node is restored from the network
+	if resp, err := gateway.dagClient.Blockstore(ctx, &pb.BlockstoreRequest{RequestType: pb.BSREQTYPE_BS_HAS, Cids: []string{hash}}); err != nil || len(resp.GetBlocks()) != 0 {
+		t.Fatalf("expected the data to be removed from the main node, but got %v", err)
+	}
+	if _, err := gateway.dagClient.Persist(ctx, &pb.PersistRequest{Cids: []string{hash}}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gateway.GetObject(ctx, testBucket1, "old", 0, info.Size, &buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "transitioned data" || atomic.LoadInt32(&cold.downloads) == 0 {
+		t.Fatalf("expected the data to be read from the cold cluster, but got %q from %v downloads", buf.String(), cold.downloads)
+	}
+
+	// transitioned objects are not moved again, younger objects are moved once they are old enough
+	if n, err = gateway.transitionObjects(ctx, created.AddDate(0, 0, 51)); err != nil || n != 1 {
+		t.Fatalf("expected the young object to be transitioned, but got %v, %v", n, err)
+	}
+
+	// the cold record is removed with the data
+	if err := gateway.DeleteObject(ctx, testBucket1, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.collectGarbage(ctx, created.AddDate(1, 0, 0), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := gateway.ledgerStore.ColdData(hash); err != nil || ok {
+		t.Fatalf("expected the cold record to be removed, but got %v, %v", ok, err)
+	}
+}
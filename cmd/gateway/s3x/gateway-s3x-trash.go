@@ -0,0 +1,103 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trashPurgeInterval is how often expired objects are permanently removed from bucket trashes
+const trashPurgeInterval = time.Hour
+
+// SetBucketTrash sets how many days deleted objects of a bucket are kept in its trash,
+// a retention of 0 disables the trash, objects that are already trashed are kept until the trash is enabled again.
+func (x *xObjects) SetBucketTrash(ctx context.Context, req *SetBucketTrashRequest) (*SetBucketTrashResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetRetentionDays() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "retention days can not be negative")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.TrashRetentionDays = req.GetRetentionDays()
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, trash-retention-days: %v", req.GetBucket(), req.GetRetentionDays())
+	return &SetBucketTrashResponse{
+		Bucket:        req.GetBucket(),
+		RetentionDays: req.GetRetentionDays(),
+	}, nil
+}
+
+// ListTrash lists the deleted objects of a bucket that can still be restored
+func (x *xObjects) ListTrash(ctx context.Context, req *ListTrashRequest) (*ListTrashResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	names, entries, retention, err := x.ledgerStore.ListTrash(ctx, req.GetBucket(), req.GetPrefix())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	objects := make([]*TrashedObject, 0, len(names))
+	for _, name := range names {
+		d := entries[name]
+		t := &TrashedObject{
+			Object:  name,
+			Hash:    d.ObjectHash,
+			Deleted: d.Deleted.Unix(),
+		}
+		if retention > 0 {
+			t.Expires = d.Deleted.Add(retention).Unix()
+		}
+		objects = append(objects, t)
+	}
+	return &ListTrashResponse{
+		Bucket:  req.GetBucket(),
+		Objects: objects,
+	}, nil
+}
+
+// RestoreObject moves a deleted object out of the trash of its bucket
+func (x *xObjects) RestoreObject(ctx context.Context, req *RestoreObjectRequest) (*RestoreObjectResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	hash, err := x.ledgerStore.RestoreObject(ctx, req.GetBucket(), req.GetObject(), req.GetOverwrite())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, restored-from-trash", req.GetBucket(), req.GetObject())
+	return &RestoreObjectResponse{
+		Bucket: req.GetBucket(),
+		Object: req.GetObject(),
+		Hash:   hash,
+	}, nil
+}
+
+// purgeTrashLoop permanently removes expired trashed objects every interval until the gateway is shut down
+func (x *xObjects) purgeTrashLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := x.ledgerStore.PurgeTrash(x.ctx, now)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to purge trash: %v", err)
+			}
+			if n > 0 {
+				log.Printf("purged %v objects from trash", n)
+			}
+		}
+	}
+}
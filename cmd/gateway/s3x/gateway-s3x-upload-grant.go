@@ -0,0 +1,234 @@
+package s3x
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/RTradeLtd/s3x/pkg/hash"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+Upload grants let third parties, such as the users of an app that ingests their content, upload one object without
+any credentials of the gateway. CreateUploadGrant records the grant under dsUploadGrantKey, and returns a url on
+the http api with a token that carries the id of the grant, signed with a key derived from the gateway secret key.
+The uploader sends PUT <url>/<rest of the key> with a Content-Length of at most the maximum size of the grant,
+and the object is stored as the prefix of the grant followed by the rest of the key.
+
+A grant is single-use: it is claimed in the ledger under the lock of its id before the upload starts, so concurrent
+uploads with the same grant fail, and it is released again if the upload fails, so the uploader can retry until
+the grant expires. The token alone grants nothing, a grant that was revoked or used is rejected even with a valid
+token. Used grants are kept as the record of who uploaded what with which grant. Uploads can overwrite objects
+under the prefix, so grants of different parties should not share a prefix. Uploads are stored as sent, so grants
+are refused for buckets with a default encryption, and so are uploads once a default encryption was set.
+*/
+
+const (
+	// uploadPathPrefix is the http path under which upload grant tokens are served
+	uploadPathPrefix = "/upload/"
+	// defaultUploadGrantExpiry is how long upload grants are valid if no expiry is requested
+	defaultUploadGrantExpiry = time.Hour
+	// maxUploadGrantExpiry is the longest valid upload grant
+	maxUploadGrantExpiry = 7 * 24 * time.Hour
+)
+
+// uploadGrantToken is the signed payload of an upload grant url
+type uploadGrantToken struct {
+	ID string `json:"i"`
+}
+
+// CreateUploadGrant mints a single-use grant that uploads one object of at most maxSize bytes under a prefix
+func (x *xObjects) CreateUploadGrant(ctx context.Context, req *CreateUploadGrantRequest) (*UploadGrant, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetMaxSize() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "max size must be positive")
+	}
+	expiry := defaultUploadGrantExpiry
+	if req.GetExpiresSeconds() != 0 {
+		expiry = time.Duration(req.GetExpiresSeconds()) * time.Second
+	}
+	if expiry <= 0 || expiry > maxUploadGrantExpiry {
+		return nil, status.Errorf(codes.InvalidArgument, "expiry must be between 1 second and %v", maxUploadGrantExpiry)
+	}
+	// the grant allows uploads of any object under the prefix, so the caller must be allowed all of them
+	if err := callerAllowed(ctx, policy.PutObjectAction, req.GetBucket(), req.GetPrefix()+"*"); err != nil {
+		return nil, err
+	}
+	// uploads with grants are stored as sent, so they would bypass the default encryption of the bucket
+	if err := x.assertNoDefaultEncryption(ctx, req.GetBucket(), "uploaded with grants"); err != nil {
+		return nil, err
+	}
+	now := x.clock.Now()
+	g := &UploadGrant{
+		Id:      ksuid.New().String(),
+		Bucket:  req.GetBucket(),
+		Prefix:  req.GetPrefix(),
+		MaxSize: req.GetMaxSize(),
+		Created: now.Unix(),
+		Expires: now.Add(expiry).Unix(),
+	}
+	if err := x.ledgerStore.PutUploadGrant(g); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	token, err := signToken(x.uploadKey, uploadGrantToken{ID: g.GetId()})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("bucket-name: %s, upload-grant: %s, prefix: %s, max-size: %v", g.GetBucket(), g.GetId(), g.GetPrefix(), g.GetMaxSize())
+	resp := *g
+	resp.Url = strings.TrimSuffix(req.GetEndpoint(), "/") + uploadPathPrefix + token
+	return &resp, nil
+}
+
+// GetUploadGrant returns an upload grant, and the object uploaded with it
+func (x *xObjects) GetUploadGrant(ctx context.Context, req *GetUploadGrantRequest) (*UploadGrant, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload grant id is empty")
+	}
+	g, err := x.ledgerStore.GetUploadGrant(req.GetId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return g, nil
+}
+
+// RevokeUploadGrant deletes an upload grant that was not used, so its url can no longer upload
+func (x *xObjects) RevokeUploadGrant(ctx context.Context, req *RevokeUploadGrantRequest) (*UploadGrant, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload grant id is empty")
+	}
+	g, err := x.ledgerStore.RevokeUploadGrant(req.GetId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, upload-grant: %s, revoked", g.GetBucket(), g.GetId())
+	return g, nil
+}
+
+// ServeUploadGrant serves PUT requests for /upload/<token>/<rest of the key>, and stores the body as the object
+// under the prefix of the grant of the token, once, until the grant expires.
+func (x *xObjects) ServeUploadGrant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, uploadPathPrefix)
+	token, rest := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		token, rest = path[:i], path[i+1:]
+	}
+	t := &uploadGrantToken{}
+	if !verifyToken(x.uploadKey, token, t) {
+		http.Error(w, "invalid upload grant", http.StatusForbidden)
+		return
+	}
+	g, err := x.ledgerStore.GetUploadGrant(t.ID)
+	if err == ErrUploadGrantDoesNotExist {
+		http.Error(w, "upload grant was revoked", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := x.clock.Now()
+	if now.Unix() > g.GetExpires() {
+		http.Error(w, "upload grant expired", http.StatusForbidden)
+		return
+	}
+	object := g.GetPrefix() + rest
+	if object == "" {
+		http.Error(w, "object name is empty", http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength < 0 {
+		http.Error(w, "content length is required", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength > g.GetMaxSize() {
+		http.Error(w, "object is larger than the upload grant allows", http.StatusRequestEntityTooLarge)
+		return
+	}
+	md5Hex := ""
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sum, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			http.Error(w, "invalid Content-MD5", http.StatusBadRequest)
+			return
+		}
+		md5Hex = hex.EncodeToString(sum)
+	}
+	data, err := hash.NewReader(r.Body, r.ContentLength, md5Hex, "", r.ContentLength, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the default encryption can be set after the grant was created
+	config, err := x.ledgerStore.GetBucketConfig(r.Context(), g.GetBucket())
+	if err == ErrLedgerBucketDoesNotExist {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if config.GetEncryption() != nil {
+		http.Error(w, "bucket encrypts new objects by default, uploads with grants are refused", http.StatusForbidden)
+		return
+	}
+	if _, err := x.ledgerStore.ClaimUploadGrant(g.GetId(), object, now); err != nil {
+		if err == ErrUploadGrantUsed {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	userDefined := map[string]string{}
+	if v := r.Header.Get("Content-Type"); v != "" {
+		userDefined["content-type"] = v
+	}
+	info, err := x.PutObject(r.Context(), g.GetBucket(), object, minio.NewPutObjReader(data, nil, nil), minio.ObjectOptions{
+		UserDefined: userDefined,
+	})
+	if err != nil {
+		if rerr := x.ledgerStore.ReleaseUploadGrant(g.GetId()); rerr != nil {
+			log.Printf("bucket-name: %s, upload-grant: %s, failed to release: %v", g.GetBucket(), g.GetId(), rerr)
+		}
+		http.Error(w, err.Error(), uploadErrStatus(err))
+		return
+	}
+	log.Printf("bucket-name: %s, object-name: %s, upload-grant: %s, size: %v", g.GetBucket(), object, g.GetId(), info.Size)
+	w.Header().Set("ETag", `"`+info.ETag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// uploadErrStatus returns the http status of an error of an upload with a grant
+func uploadErrStatus(err error) int {
+	switch err.(type) {
+	case minio.BucketNotFound:
+		return http.StatusNotFound
+	case minio.ObjectNameInvalid, minio.ObjectNameTooLong, hash.BadDigest, minio.IncompleteBody:
+		return http.StatusBadRequest
+	case minio.PrefixAccessDenied:
+		return http.StatusForbidden
+	case minio.ObjectTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
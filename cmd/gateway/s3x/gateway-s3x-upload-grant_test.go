@@ -0,0 +1,174 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_UploadGrant(t *testing.T) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gateway.clock = fixedClock(start)
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(gateway.ServeUploadGrant))
+	defer server.Close()
+	put := func(url string, data []byte) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "image/png")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	grant, err := gateway.CreateUploadGrant(ctx, &CreateUploadGrantRequest{
+		Bucket:   testBucket1,
+		Prefix:   "ugc/user1/",
+		MaxSize:  8,
+		Endpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grant.Expires != start.Add(defaultUploadGrantExpiry).Unix() {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []*CreateUploadGrantRequest{
+			{MaxSize: 1},
+			{Bucket: testBucket1},
+			{Bucket: testBucket1, MaxSize: 1, ExpiresSeconds: -1},
+			{Bucket: testBucket1, MaxSize: 1, ExpiresSeconds: int64(maxUploadGrantExpiry/time.Second) + 1},
+		} {
+			if _, err := gateway.CreateUploadGrant(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+			}
+		}
+		if _, err := gateway.CreateUploadGrant(ctx, &CreateUploadGrantRequest{Bucket: testBucket2, MaxSize: 1}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+		denied := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.GetObjectAction}})
+		if _, err := gateway.CreateUploadGrant(denied, &CreateUploadGrantRequest{Bucket: testBucket1, MaxSize: 1}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("expected code %v, but got %v", codes.PermissionDenied, err)
+		}
+		allowed := withExtensionCaller(context.Background(), testCaller{allowed: map[string]policy.Action{testBucket1: policy.PutObjectAction}})
+		if _, err := gateway.CreateUploadGrant(allowed, &CreateUploadGrantRequest{Bucket: testBucket1, Prefix: "ugc/", MaxSize: 1}); err != nil {
+			t.Fatalf("expected a caller allowed to put objects to create a grant, but got %v", err)
+		}
+		if _, err := gateway.GetUploadGrant(ctx, &GetUploadGrantRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+		if code := put(server.URL+uploadPathPrefix+"invalid/photo.png", []byte("data")); code != http.StatusForbidden {
+			t.Fatalf("expected status %v for an invalid token, but got %v", http.StatusForbidden, code)
+		}
+		if code := put(grant.Url+"/photo.png", []byte("too large")); code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status %v, but got %v", http.StatusRequestEntityTooLarge, code)
+		}
+	})
+	t.Run("Upload", func(t *testing.T) {
+		if code := put(grant.Url+"/photo.png", []byte("png data")); code != http.StatusOK {
+			t.Fatalf("expected status %v, but got %v", http.StatusOK, code)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, "ugc/user1/photo.png", minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != 8 || info.ContentType != "image/png" {
+			t.Fatalf("unexpected object %+v", info)
+		}
+		// grants are single-use
+		if code := put(grant.Url+"/other.png", []byte("png data")); code != http.StatusForbidden {
+			t.Fatalf("expected status %v, but got %v", http.StatusForbidden, code)
+		}
+		used, err := gateway.GetUploadGrant(ctx, &GetUploadGrantRequest{Id: grant.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used.Object != "ugc/user1/photo.png" || used.Used != start.Unix() || used.Url != "" {
+			t.Fatalf("unexpected grant %+v", used)
+		}
+		if _, err := gateway.RevokeUploadGrant(ctx, &RevokeUploadGrantRequest{Id: grant.Id}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+		}
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		revoked, err := gateway.CreateUploadGrant(ctx, &CreateUploadGrantRequest{Bucket: testBucket1, MaxSize: 8, Endpoint: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.RevokeUploadGrant(ctx, &RevokeUploadGrantRequest{Id: revoked.Id}); err != nil {
+			t.Fatal(err)
+		}
+		if code := put(revoked.Url+"/photo.png", []byte("data")); code != http.StatusForbidden {
+			t.Fatalf("expected status %v, but got %v", http.StatusForbidden, code)
+		}
+	})
+	t.Run("Expired", func(t *testing.T) {
+		expired, err := gateway.CreateUploadGrant(ctx, &CreateUploadGrantRequest{Bucket: testBucket1, MaxSize: 8, ExpiresSeconds: 60, Endpoint: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gateway.clock = fixedClock(start.Add(2 * time.Minute))
+		if code := put(expired.Url+"/photo.png", []byte("data")); code != http.StatusForbidden {
+			t.Fatalf("expected status %v, but got %v", http.StatusForbidden, code)
+		}
+	})
+}
+
+func TestS3X_UploadGrantEncryption(t *testing.T) {
+	ctx := withExtensionCaller(context.Background(), testCaller{owner: true})
+	ls, _, _ := newFaultyLedger(t)
+	x := &xObjects{ledgerStore: ls, clock: testClock{}, uploadKey: []byte("upload key")}
+	if _, err := ls.CreateBucket(ctx, testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	grant, err := x.CreateUploadGrant(ctx, &CreateUploadGrantRequest{Bucket: testBucket1, MaxSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.UpdateBucketConfig(ctx, testBucket1, func(c *BucketConfig) error {
+		c.Encryption = &EncryptionConfig{Algorithm: "AES256"}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.CreateUploadGrant(ctx, &CreateUploadGrantRequest{Bucket: testBucket1, MaxSize: 8}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected a grant for a bucket with a default encryption to be refused, but got %v", err)
+	}
+	// the grant was created before the default encryption was set
+	rec := httptest.NewRecorder()
+	x.ServeUploadGrant(rec, httptest.NewRequest(http.MethodPut, grant.GetUrl()+"object", bytes.NewReader([]byte("data"))))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected the upload to be refused, but got %d: %s", rec.Code, rec.Body.String())
+	}
+	g, err := ls.GetUploadGrant(grant.GetId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.GetUsed() != 0 {
+		t.Fatalf("expected the refused upload to leave the grant unused, but got %+v", g)
+	}
+}
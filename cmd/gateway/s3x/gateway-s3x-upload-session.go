@@ -0,0 +1,190 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"log"
+	"sort"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Design Notes
+---------------
+
+A single PUT that is interrupted has to be sent again from the start, which rarely succeeds over flaky
+mobile or edge connections. An upload session lets a client send an object in chunks, and ask the gateway
+which offset to continue from after a lost connection. Sessions are multipart uploads marked resumable, so
+chunks are stored on TemporalX like parts, and are linked into the object DAG on completion without copying
+data. Sessions are listed and aborted with the multipart sessions api, and the S3 api can not add parts.
+
+A chunk is only accepted at the offset the session received so far. The offset is checked before the chunk
+is uploaded to fail fast, and again under the lock of the session, so a chunk that was sent twice, after its
+response was lost, is rejected with FailedPrecondition and the client asks for the offset again. The data of
+a rejected chunk is released for garbage collection. The ETag of the object is computed like the ETag of a
+multipart upload, from the md5 of each chunk.
+*/
+
+// uploadSession returns the api representation of an upload session
+func uploadSession(m *MultipartUpload) *UploadSession {
+	offset, chunks := uploadSessionOffset(m)
+	return &UploadSession{
+		UploadID:  m.GetId(),
+		Bucket:    m.GetObjectInfo().GetBucket(),
+		Object:    m.GetObjectInfo().GetName(),
+		Offset:    offset,
+		Chunks:    chunks,
+		Initiated: m.GetObjectInfo().GetModTime().Unix(),
+	}
+}
+
+// CreateUploadSession starts an upload of a single object that can be resumed after the connection is lost
+func (x *xObjects) CreateUploadSession(ctx context.Context, req *CreateUploadSessionRequest) (*UploadSession, error) {
+	bucket, object := req.GetBucket(), req.GetObject()
+	if bucket == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if object == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	if err := x.names.checkObjectName(object); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if err := x.limits.checkMetadata(req.GetMetadata()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	// checksums are only stored when the handlers verified them
+	opts := minio.ObjectOptions{UserDefined: withoutChecksums(req.GetMetadata())}
+	info := newObjectInfo(bucket, object, 0, opts, x.clock.Now())
+	m := &MultipartUpload{ObjectInfo: &info, Id: ksuid.New().String(), Resumable: true}
+	if err := x.ledgerStore.NewUploadSession(m.Id, m.ObjectInfo); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, upload-id: %s, created-upload-session", bucket, object, m.Id)
+	return uploadSession(m), nil
+}
+
+// GetUploadSession returns the offset an upload session continues from
+func (x *xObjects) GetUploadSession(ctx context.Context, req *GetUploadSessionRequest) (*UploadSession, error) {
+	if req.GetUploadID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload id is empty")
+	}
+	m, unlock, err := x.ledgerStore.GetUploadSession(req.GetUploadID())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	defer unlock()
+	return uploadSession(m), nil
+}
+
+// PutUploadChunk uploads data to TemporalX, and adds it to an upload session at the offset it continues from
+func (x *xObjects) PutUploadChunk(ctx context.Context, req *UploadChunkRequest) (*UploadSession, error) {
+	if req.GetUploadID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload id is empty")
+	}
+	if len(req.GetData()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "data is empty")
+	}
+	session, err := x.GetUploadSession(ctx, &GetUploadSessionRequest{UploadID: req.GetUploadID()})
+	if err != nil {
+		return nil, err
+	}
+	if req.GetOffset() != session.Offset {
+		return nil, toGrpcErr(ErrUploadOffsetMismatch)
+	}
+	if err := x.limits.checkPart(int(session.Chunks+1), int64(len(req.GetData()))); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	if err := x.limits.checkObjectSize(session.Offset + int64(len(req.GetData()))); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	release, err := x.capacity.reserve(int64(len(req.GetData())))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	var stored int64
+	defer func() { release(stored) }()
+	// upload before claiming the session lock, as this is the slow part
+	hash, size, err := ipfsFileUpload(ctx, x.fileClient, bytes.NewReader(req.GetData()))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	md5Hash := md5.Sum(req.GetData())
+	pi := minio.PartInfo{
+		LastModified: x.clock.Now().UTC(),
+		ETag:         hex.EncodeToString(md5Hash[:]),
+		Size:         int64(size),
+		ActualSize:   int64(size),
+	}
+	chunks, err := x.ledgerStore.PutUploadChunk(req.GetUploadID(), req.GetOffset(), pi, hash)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	stored = pi.Size
+	session.Offset += pi.Size
+	session.Chunks = chunks
+	return session, nil
+}
+
+// CompleteUploadSession links the chunks of an upload session into the data of its object, and stores the object
+func (x *xObjects) CompleteUploadSession(ctx context.Context, req *CompleteUploadSessionRequest) (*CompleteUploadSessionResponse, error) {
+	if req.GetUploadID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "upload id is empty")
+	}
+	m, unlock, err := x.ledgerStore.GetUploadSession(req.GetUploadID())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	defer unlock()
+	if len(m.ObjectParts) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "upload session has no data")
+	}
+	bucket, object := m.GetObjectInfo().GetBucket(), m.GetObjectInfo().GetName()
+	parts := make([]ObjectPartInfo, 0, len(m.ObjectParts))
+	for _, p := range m.ObjectParts {
+		parts = append(parts, p)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	hashes := make([]string, 0, len(parts))
+	sizes := make([]uint64, 0, len(parts))
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		hashes = append(hashes, p.GetDataHash())
+		sizes = append(sizes, uint64(p.GetSize_()))
+		completed = append(completed, minio.CompletePart{PartNumber: int(p.GetNumber()), ETag: p.GetEtag()})
+	}
+	dataHash, totalSize, err := x.saveFileLinks(ctx, hashes, sizes)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	info := *m.ObjectInfo
+	info.Size_ = int64(totalSize)
+	info.ModTime = x.clock.Now().UTC()
+	info.Etag = minio.ComputeCompleteMultipartMD5(completed)
+	info.Parts = parts
+	if err := x.ledgerStore.PutObject(ctx, bucket, object, &Object{DataHash: dataHash, ObjectInfo: info}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	// the chunks are linked from the completed object, so their data is not released
+	if err := x.ledgerStore.DeleteMultipartID(req.GetUploadID()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	hash, err := x.ledgerStore.GetObjectHash(ctx, bucket, object)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, upload-id: %s, file-hash: %s", bucket, object, req.GetUploadID(), dataHash)
+	return &CompleteUploadSessionResponse{
+		Bucket:   bucket,
+		Object:   object,
+		Hash:     hash,
+		DataHash: dataHash,
+		Size_:    int64(totalSize),
+		Etag:     info.Etag,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package s3x
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_UploadSession(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.CreateUploadSession(ctx, &CreateUploadSessionRequest{Bucket: testBucket2, Object: testObject1}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+	}
+	session, err := gateway.CreateUploadSession(ctx, &CreateUploadSessionRequest{
+		Bucket:   testBucket1,
+		Object:   testObject1,
+		Metadata: map[string]string{"content-type": "text/plain"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.CompleteUploadSession(ctx, &CompleteUploadSessionRequest{UploadID: session.UploadID}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+	chunk := &UploadChunkRequest{UploadID: session.UploadID, Data: []byte("hello ")}
+	if _, err := gateway.PutUploadChunk(ctx, chunk); err != nil {
+		t.Fatal(err)
+	}
+	// a chunk sent again after its response was lost is rejected
+	if _, err := gateway.PutUploadChunk(ctx, chunk); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+	}
+	session, err = gateway.GetUploadSession(ctx, &GetUploadSessionRequest{UploadID: session.UploadID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Offset != 6 || session.Chunks != 1 {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	// the S3 api can not add parts to a session
+	if _, err := gateway.PutObjectPart(ctx, testBucket1, testObject1, session.UploadID, 2, getTestPutObjectReader(t, []byte("part")), minio.ObjectOptions{}); err == nil {
+		t.Fatal("expected a part of a session to be rejected")
+	}
+	session, err = gateway.PutUploadChunk(ctx, &UploadChunkRequest{UploadID: session.UploadID, Offset: session.Offset, Data: []byte("world")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Offset != 11 || session.Chunks != 2 {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	sessions, err := gateway.ListMultipartSessions(ctx, &ListMultipartSessionsRequest{Bucket: testBucket1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.GetSessions()) != 1 || sessions.GetSessions()[0].Bytes != 11 {
+		t.Fatalf("expected the session to be listed with the multipart uploads, but got %v", sessions.GetSessions())
+	}
+	resp, err := gateway.CompleteUploadSession(ctx, &CompleteUploadSessionRequest{UploadID: session.UploadID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Size_ != 11 || resp.Hash == "" || resp.DataHash == "" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := gateway.GetObject(ctx, testBucket1, testObject1, 0, 0, buf, "", minio.ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "hello world" {
+		t.Fatalf("expected hello world, but got %s", buf.String())
+	}
+	info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "text/plain" || info.ETag != resp.Etag {
+		t.Fatalf("unexpected object info %+v", info)
+	}
+	if _, err := gateway.GetUploadSession(ctx, &GetUploadSessionRequest{UploadID: session.UploadID}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+	}
+	// multipart uploads of the S3 api are not sessions
+	uploadID, err := gateway.NewMultipartUpload(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gateway.GetUploadSession(ctx, &GetUploadSessionRequest{UploadID: uploadID}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+	}
+}
+
+func TestS3X_UploadSessionLedger(t *testing.T) {
+	ls, _, _ := newFaultyLedger(t)
+	if _, err := ls.CreateBucket(context.Background(), testBucket1, &Bucket{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.NewUploadSession("session", &ObjectInfo{Bucket: testBucket1, Name: testObject1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ls.PutUploadChunk("session", 0, minio.PartInfo{Size: 4, ActualSize: 4}, "data-a"); err != nil {
+		t.Fatal(err)
+	}
+	// a rejected chunk is released, unless the session has the same data
+	if _, err := ls.PutUploadChunk("session", 0, minio.PartInfo{Size: 4, ActualSize: 4}, "data-a"); err != ErrUploadOffsetMismatch {
+		t.Fatalf("expected %v, but got %v", ErrUploadOffsetMismatch, err)
+	}
+	if _, err := ls.PutUploadChunk("session", 2, minio.PartInfo{Size: 4, ActualSize: 4}, "data-b"); err != ErrUploadOffsetMismatch {
+		t.Fatalf("expected %v, but got %v", ErrUploadOffsetMismatch, err)
+	}
+	garbage, err := ls.GarbageData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := garbage["data-a"]; ok || len(garbage) != 1 {
+		t.Fatalf("expected only data-b to be released, but got %v", garbage)
+	}
+	chunks, err := ls.PutUploadChunk("session", 4, minio.PartInfo{Size: 4, ActualSize: 4}, "data-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks != 2 {
+		t.Fatalf("expected 2 chunks, but got %v", chunks)
+	}
+}
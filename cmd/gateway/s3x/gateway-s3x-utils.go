@@ -1,9 +1,19 @@
 package s3x
 
 import (
+	"regexp"
+	"strings"
+
 	minio "github.com/RTradeLtd/s3x/cmd"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 )
 
+// multipartETag matches the ETags of completed multipart uploads
+var multipartETag = regexp.MustCompile(`^[0-9a-f]{32}-[0-9]+$`)
+
+// dataMD5ETag matches the ETags that are the md5 of the object data
+var dataMD5ETag = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
 /* Design Notes
 ---------------
 
@@ -17,12 +27,91 @@ func getMinioObjectInfo(o *ObjectInfo) minio.ObjectInfo {
 		return minio.ObjectInfo{}
 	}
 	return minio.ObjectInfo{
-		Bucket:      o.Bucket,
-		Name:        o.Name,
-		ETag:        minio.ToS3ETag(o.Etag),
-		Size:        o.Size_,
-		ModTime:     o.ModTime,
-		ContentType: o.ContentType,
-		UserDefined: o.UserDefined,
+		Bucket:          o.Bucket,
+		Name:            o.Name,
+		ETag:            s3ETag(o.Etag),
+		Size:            o.Size_,
+		ModTime:         o.ModTime,
+		ContentType:     o.ContentType,
+		ContentEncoding: o.ContentEncoding,
+		StorageClass:    o.StorageClass,
+		UserDefined:     o.UserDefined,
+		UserTags:        userDefinedValue(o.UserDefined, xhttp.AmzObjectTagging),
+		Parts:           getMinioObjectParts(o.Parts),
+	}
+}
+
+// getMinioObjectParts returns the parts of a completed multipart upload, the parts of encrypted
+// multipart objects are needed to decrypt them
+func getMinioObjectParts(parts []ObjectPartInfo) []minio.ObjectPartInfo {
+	if len(parts) == 0 {
+		return nil
+	}
+	mparts := make([]minio.ObjectPartInfo, 0, len(parts))
+	for _, p := range parts {
+		mparts = append(mparts, minio.ObjectPartInfo{
+			Number:     int(p.GetNumber()),
+			ETag:       partETag(p),
+			Size:       p.GetSize_(),
+			ActualSize: p.GetActualSize(),
+		})
+	}
+	return mparts
+}
+
+// s3ETag returns the S3 ETag of a stored object ETag, composite "<md5>-<part count>" ETags
+// of multipart uploads are returned as is, other ETags are marked as not being an md5 checksum
+func s3ETag(etag string) string {
+	if multipartETag.MatchString(etag) {
+		return etag
+	}
+	return minio.ToS3ETag(etag)
+}
+
+// md5ETag returns a stored object ETag if it is the md5 of the object data, or an empty string
+func md5ETag(etag string) string {
+	if dataMD5ETag.MatchString(etag) {
+		return etag
+	}
+	return ""
+}
+
+// partETag returns the S3 ETag of a multipart part, parts recorded before their md5 was kept
+// use the ETag of their data hash
+func partETag(p ObjectPartInfo) string {
+	if p.GetEtag() != "" {
+		return p.GetEtag()
+	}
+	return minio.ToS3ETag(p.GetDataHash())
+}
+
+// isChecksumMetadataKey returns true for the x-amz-checksum- keys the handlers store the verified checksum of an upload under
+func isChecksumMetadataKey(k string) bool {
+	return strings.HasPrefix(strings.ToLower(k), xhttp.AmzChecksumPrefix)
+}
+
+// partChecksum returns the checksum the handlers verified for a part of an upload with a checksum algorithm,
+// empty if the upload has none
+func partChecksum(userDefined map[string]string) string {
+	for k, v := range userDefined {
+		if isChecksumMetadataKey(k) && !strings.EqualFold(k, xhttp.AmzChecksumAlgorithm) {
+			return v
+		}
+	}
+	return ""
+}
+
+// withoutChecksums returns user defined metadata without checksums, for objects whose data no longer matches them
+func withoutChecksums(userDefined map[string]string) map[string]string {
+	var kept map[string]string
+	for k, v := range userDefined {
+		if isChecksumMetadataKey(k) {
+			continue
+		}
+		if kept == nil {
+			kept = make(map[string]string, len(userDefined))
+		}
+		kept[k] = v
 	}
+	return kept
 }
@@ -0,0 +1,116 @@
+package s3x
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// versionPruneInterval is how often noncurrent versions older than the retention of their bucket are removed
+const versionPruneInterval = time.Hour
+
+// SetBucketVersioning configures whether replaced and deleted objects of a bucket are kept as noncurrent versions,
+// and how many versions of each object and for how many days they are kept, 0 keeps versions without limit.
+// Existing versions beyond the new retention are pruned.
+func (x *xObjects) SetBucketVersioning(ctx context.Context, req *SetBucketVersioningRequest) (*SetBucketVersioningResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	v := req.GetVersioning()
+	if v.GetKeepVersions() < 0 || v.GetKeepDays() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "version retention can not be negative")
+	}
+	if err := x.ledgerStore.UpdateBucketConfig(ctx, req.GetBucket(), func(c *BucketConfig) error {
+		c.Versioning = &v
+		return nil
+	}); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	pruned, unreferenced, err := x.ledgerStore.PruneVersions(ctx, req.GetBucket(), x.clock.Now())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, versioning: %v, versions-pruned: %v", req.GetBucket(), v.String(), pruned)
+	return &SetBucketVersioningResponse{
+		Bucket:       req.GetBucket(),
+		Versioning:   v,
+		Pruned:       int64(pruned),
+		Unreferenced: unreferenced,
+	}, nil
+}
+
+// ListObjectVersions lists the noncurrent versions of the objects of a bucket, newest first for each object
+func (x *xObjects) ListObjectVersions(ctx context.Context, req *ListObjectVersionsRequest) (*ListObjectVersionsResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	names, versions, err := x.ledgerStore.ListObjectVersions(ctx, req.GetBucket(), req.GetPrefix())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp := &ListObjectVersionsResponse{Bucket: req.GetBucket()}
+	for _, name := range names {
+		vs := versions[name].Versions
+		for i := len(vs) - 1; i >= 0; i-- {
+			obj, err := ipfsObject(ctx, x.dagClient, vs[i].ObjectHash)
+			if err != nil {
+				return nil, toGrpcErr(err)
+			}
+			resp.Versions = append(resp.Versions, &ObjectVersionInfo{
+				Object:     name,
+				VersionId:  vs[i].VersionId,
+				Size_:      obj.ObjectInfo.Size_,
+				Etag:       s3ETag(obj.ObjectInfo.Etag),
+				Noncurrent: vs[i].Noncurrent.Unix(),
+			})
+		}
+	}
+	return resp, nil
+}
+
+// RestoreObjectVersion makes a noncurrent version the current object, the replaced object is kept as a version
+func (x *xObjects) RestoreObjectVersion(ctx context.Context, req *RestoreObjectVersionRequest) (*RestoreObjectVersionResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetObject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "object name is empty")
+	}
+	if req.GetVersionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "version id is empty")
+	}
+	hash, unreferenced, err := x.ledgerStore.RestoreObjectVersion(ctx, req.GetBucket(), req.GetObject(), req.GetVersionId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, object-name: %s, version-id: %s, restored-version, unreferenced-data: %v",
+		req.GetBucket(), req.GetObject(), req.GetVersionId(), unreferenced)
+	return &RestoreObjectVersionResponse{
+		Bucket: req.GetBucket(),
+		Object: req.GetObject(),
+		Hash:   hash,
+	}, nil
+}
+
+// pruneVersionsLoop removes expired noncurrent versions every interval until the gateway is shut down
+func (x *xObjects) pruneVersionsLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, unreferenced, err := x.ledgerStore.PruneAllVersions(x.ctx, now)
+			if err != nil && x.ctx.Err() == nil {
+				log.Printf("failed to prune versions: %v", err)
+			}
+			if n > 0 {
+				log.Printf("pruned %v versions, unreferenced-data: %v", n, unreferenced)
+			}
+		}
+	}
+}
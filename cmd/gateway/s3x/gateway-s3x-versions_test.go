@@ -0,0 +1,139 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+)
+
+func TestS3X_Versions_Badger(t *testing.T) {
+	testS3XVersions(t, DSTypeBadger)
+}
+func TestS3X_Versions_Crdt(t *testing.T) {
+	testS3XVersions(t, DSTypeCrdt)
+}
+func testS3XVersions(t *testing.T, dsType DSType) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, dsType)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(t *testing.T, data string) string {
+		if _, err := gateway.PutObject(ctx, testBucket1, testObject1, getTestPutObjectReader(t, []byte(data)), minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := gateway.ledgerStore.Object(ctx, testBucket1, testObject1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.GetDataHash()
+	}
+	versions := func(t *testing.T) []*ObjectVersionInfo {
+		resp, err := gateway.ListObjectVersions(ctx, &ListObjectVersionsRequest{Bucket: testBucket1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetVersions()
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+			Bucket: testBucket1, Versioning: VersioningConfig{Enabled: true, KeepVersions: -1},
+		}); err == nil {
+			t.Fatal("expected an error for a negative retention")
+		}
+		if _, err := gateway.RestoreObjectVersion(ctx, &RestoreObjectVersionRequest{Bucket: testBucket1, Object: testObject1, VersionId: "missing"}); err == nil {
+			t.Fatal("expected an error restoring a missing version")
+		}
+	})
+	if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+		Bucket: testBucket1, Versioning: VersioningConfig{Enabled: true, KeepVersions: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	first := put(t, "version 1")
+	t.Run("KeepVersions", func(t *testing.T) {
+		put(t, "version 2")
+		put(t, "version 3")
+		put(t, "version 4")
+		vs := versions(t)
+		if len(vs) != 2 {
+			t.Fatalf("expected 2 versions, but got %v", len(vs))
+		}
+		// the first version is pruned, so its data is no longer referenced
+		if n, err := gateway.ledgerStore.DataRefCount(first); err != nil || n != 0 {
+			t.Fatalf("expected no references to the first version data, but got %v, %v", n, err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := gateway.DeleteObject(ctx, testBucket1, testObject1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{}); err == nil {
+			t.Fatal("expected the deleted object to be missing")
+		}
+		if len(versions(t)) != 2 {
+			t.Fatal("expected the deleted object to be kept as a version")
+		}
+	})
+	t.Run("Restore", func(t *testing.T) {
+		latest := versions(t)[0]
+		if _, err := gateway.RestoreObjectVersion(ctx, &RestoreObjectVersionRequest{
+			Bucket: testBucket1, Object: testObject1, VersionId: latest.GetVersionId(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := gateway.GetObjectInfo(ctx, testBucket1, testObject1, minio.ObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ETag != latest.GetEtag() {
+			t.Fatalf("expected etag %v, but got %v", latest.GetEtag(), info.ETag)
+		}
+		if len(versions(t)) != 1 {
+			t.Fatal("expected the restored version to become the current object")
+		}
+	})
+	t.Run("DeleteObjectVersions", func(t *testing.T) {
+		put(t, "version 5")
+		version := versions(t)[0]
+		errs, err := gateway.DeleteObjectVersions(ctx, testBucket1, []minio.ObjectIdentifier{
+			{ObjectName: testObject1, VersionID: version.GetVersionId()},
+			{ObjectName: testObject1, VersionID: "missing"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs[0] != nil {
+			t.Fatal("expected the version to be deleted, but got: ", errs[0])
+		}
+		if _, ok := errs[1].(minio.VersionNotFound); !ok {
+			t.Fatal("expected err VersionNotFound, but got: ", errs[1])
+		}
+		if len(versions(t)) != 1 {
+			t.Fatal("expected the deleted version to be removed")
+		}
+	})
+	t.Run("KeepDays", func(t *testing.T) {
+		put(t, "version 6")
+		if _, err := gateway.SetBucketVersioning(ctx, &SetBucketVersioningRequest{
+			Bucket: testBucket1, Versioning: VersioningConfig{Enabled: true, KeepDays: 1},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := gateway.ledgerStore.PruneAllVersions(ctx, gateway.clock.Now().Add(48*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 || len(versions(t)) != 0 {
+			t.Fatalf("expected 2 pruned versions and none left, but got %v, %v", n, len(versions(t)))
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package s3x
+
+import (
+	"context"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetBucketWORM sets for how many days after they were written objects of a bucket can not be overwritten or
+// deleted, the retention of a bucket in WORM mode can only be extended
+func (x *xObjects) SetBucketWORM(ctx context.Context, req *SetBucketWORMRequest) (*SetBucketWORMResponse, error) {
+	if req.GetBucket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "bucket name is empty")
+	}
+	if req.GetDays() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "days must be positive")
+	}
+	if err := x.ledgerStore.SetWORMRetention(ctx, req.GetBucket(), req.GetDays()); err != nil {
+		return nil, toGrpcErr(err)
+	}
+	log.Printf("bucket-name: %s, worm-retention-days: %v", req.GetBucket(), req.GetDays())
+	return &SetBucketWORMResponse{
+		Bucket: req.GetBucket(),
+		Days:   req.GetDays(),
+	}, nil
+}
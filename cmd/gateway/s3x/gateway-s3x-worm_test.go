@@ -0,0 +1,131 @@
+package s3x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3X_WORM(t *testing.T) {
+	ctx := context.Background()
+	gateway := newTestGateway(t, DSTypeBadger)
+	defer func() {
+		if err := gateway.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow := func(now time.Time) {
+		gateway.clock = fixedClock(now)
+		gateway.ledgerStore.clock = fixedClock(now)
+	}
+	setNow(start)
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(object string) error {
+		t.Helper()
+		_, err := gateway.PutObject(ctx, testBucket1, object, getTestPutObjectReader(t, []byte("worm "+object)), minio.ObjectOptions{})
+		return err
+	}
+	if err := put("unretained"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Config", func(t *testing.T) {
+		for _, req := range []*SetBucketWORMRequest{{Days: 1}, {Bucket: testBucket1}, {Bucket: testBucket1, Days: -1}} {
+			if _, err := gateway.SetBucketWORM(ctx, req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected code %v, but got %v", codes.InvalidArgument, err)
+			}
+		}
+		if _, err := gateway.SetBucketWORM(ctx, &SetBucketWORMRequest{Bucket: testBucket2, Days: 1}); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected code %v, but got %v", codes.NotFound, err)
+		}
+		if _, err := gateway.SetBucketWORM(ctx, &SetBucketWORMRequest{Bucket: testBucket1, Days: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gateway.SetBucketWORM(ctx, &SetBucketWORMRequest{Bucket: testBucket1, Days: 1}); status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected code %v, but got %v", codes.FailedPrecondition, err)
+		}
+		config, err := gateway.ledgerStore.GetBucketConfig(ctx, testBucket1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.WormRetentionDays != 2 {
+			t.Fatalf("expected a retention of 2 days, but got %v", config.WormRetentionDays)
+		}
+	})
+	if _, err := gateway.SetBucketObjectTTL(ctx, &SetBucketObjectTTLRequest{Bucket: testBucket1, Days: 1}); err != nil {
+		t.Fatal(err)
+	}
+	setNow(start.Add(72 * time.Hour))
+	if err := put("retained"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Retained", func(t *testing.T) {
+		setNow(start.Add(96 * time.Hour))
+		denied := minio.PrefixAccessDenied{Bucket: testBucket1, Object: "retained"}
+		if err := put("retained"); err != denied {
+			t.Fatalf("expected %v, but got %v", denied, err)
+		}
+		if err := gateway.DeleteObject(ctx, testBucket1, "retained"); err != denied {
+			t.Fatalf("expected %v, but got %v", denied, err)
+		}
+		if _, err := gateway.ledgerStore.RenameObject(ctx, testBucket1, "retained", "renamed", false); err != ErrLedgerObjectRetained {
+			t.Fatalf("expected %v, but got %v", ErrLedgerObjectRetained, err)
+		}
+		if _, err := gateway.ledgerStore.RenameObject(ctx, testBucket1, "unretained", "retained", true); err != ErrLedgerObjectRetained {
+			t.Fatalf("expected %v, but got %v", ErrLedgerObjectRetained, err)
+		}
+		// a rejected batch removes none of its objects
+		if _, err := gateway.ledgerStore.RemoveObjects(ctx, testBucket1, "unretained", "retained"); err != ErrLedgerObjectRetained {
+			t.Fatalf("expected %v, but got %v", ErrLedgerObjectRetained, err)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, "unretained", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteBucket(ctx, testBucket1); err != (minio.BucketNotEmpty{Bucket: testBucket1}) {
+			t.Fatalf("expected the bucket to not be deleted, but got %v", err)
+		}
+	})
+	t.Run("Expiry", func(t *testing.T) {
+		// both objects are past their ttl, only the object past its retention expires
+		n, err := gateway.ledgerStore.ExpireObjects(ctx, start.Add(96*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("expected 1 expired object, but got %v", n)
+		}
+		if _, err := gateway.GetObjectInfo(ctx, testBucket1, "retained", minio.ObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("BulkDelete", func(t *testing.T) {
+		r, err := gateway.ledgerStore.BulkRemoveObjects(ctx, testBucket1, nil, "", start.Add(96*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.removed != 0 || len(r.locked) != 1 || r.locked[0] != "retained" {
+			t.Fatalf("unexpected removal %+v", r)
+		}
+	})
+	t.Run("Released", func(t *testing.T) {
+		setNow(start.Add(120 * time.Hour))
+		if err := put("retained"); err != nil {
+			t.Fatal(err)
+		}
+		setNow(start.Add(168 * time.Hour))
+		if err := gateway.DeleteObject(ctx, testBucket1, "retained"); err != nil {
+			t.Fatal(err)
+		}
+		if err := gateway.DeleteBucket(ctx, testBucket1); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
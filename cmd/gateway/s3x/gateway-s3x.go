@@ -4,13 +4,18 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
 	badger "github.com/RTradeLtd/go-ds-badger/v2"
 	minio "github.com/RTradeLtd/s3x/cmd"
 	"github.com/RTradeLtd/s3x/pkg/auth"
+	"github.com/dustin/go-humanize"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/ipfs/go-datastore"
 	crdt "github.com/ipfs/go-ds-crdt"
@@ -33,6 +38,8 @@ const (
 	DSTypeBadger = DSType("badger")
 	//DSTypeCrdt is a crdt backed backed datastore
 	DSTypeCrdt = DSType("crdt")
+	//DSTypeRemote is the datastore served by the host of a cluster
+	DSTypeRemote = DSType("remote")
 )
 
 // TEMX implements a MinIO gateway on top of TemporalX
@@ -44,11 +51,168 @@ type TEMX struct {
 	CrdtTopic string
 	XAddr     string
 	Insecure  bool // whether or not we have an insecure connection to TemporalX
+	// ErasureXAddrs are TemporalX endpoints to erasure code object data across, disabled if empty
+	ErasureXAddrs []string
+	// ErasureParityShards is the number of ErasureXAddrs nodes that can be lost
+	ErasureParityShards int
+	// ReplicaXAddrs are TemporalX endpoints that buckets with a replication factor copy object data to
+	ReplicaXAddrs []string
+	// ReplicationScrubInterval is how often the replicas of all buckets are verified and repaired, disabled if 0
+	ReplicationScrubInterval time.Duration
+	// ColdXAddr is the TemporalX endpoint of the cold cluster buckets with transition days move object data to,
+	// transitions are disabled if empty
+	ColdXAddr string
+	// TransitionInterval is how often the data of objects is moved to the cold cluster
+	TransitionInterval time.Duration
+	// PinCheckInterval is how often the pins of the data of all objects are verified and repaired, disabled if 0
+	PinCheckInterval time.Duration
+	// PinQueueWorkers is how many queued uploads are pinned at the same time, 4 if 0
+	PinQueueWorkers int
+	// MeteringSink is where usage records are written, metering is disabled if empty
+	MeteringSink string
+	// MeteringInterval is how often usage records are written to the MeteringSink
+	MeteringInterval time.Duration
+	// NameValidation is how strictly bucket names and object keys are validated, strict if empty
+	NameValidation NameValidation
+	// MaxObjectSize is the maximum object size in bytes, 5 TiB if 0
+	MaxObjectSize int64
+	// MaxPartSize is the maximum multipart part size in bytes, 5 GiB if 0
+	MaxPartSize int64
+	// MaxPartCount is the maximum number of parts of a multipart upload, 10000 if 0
+	MaxPartCount int
+	// MaxMetadataSize is the maximum size of user defined metadata in bytes, 2 KiB if 0
+	MaxMetadataSize int
+	// BlockPublicAccess blocks all public access to all buckets, regardless of their public access block
+	BlockPublicAccess bool
+	// DenyByDefault only allows the S3 requests of the root credential that are allowed by bucket policies, and
+	// denies its admin requests, except for requests with the BreakGlassKey, which can only be set with DenyByDefault
+	DenyByDefault bool
+	BreakGlassKey string
+	// MaxClockSkew is the allowed difference between the date of a signed request and the server time, 15 minutes
+	// if 0, RequireSignatureV4 denies requests signed with signature version 2, and AuthRegion is the region
+	// signed requests must be scoped to, any region if empty and the server has no region
+	MaxClockSkew       time.Duration
+	RequireSignatureV4 bool
+	AuthRegion         string
+	// DebugErrors returns the chain of errors of failed requests to clients that ask for it
+	DebugErrors bool
+	// Profiling exposes the runtime profiles of the gateway to admin requests
+	Profiling bool
+	// HeadersConfig is the json file of the response headers set on, and the request headers required by,
+	// requests to buckets, none if empty
+	HeadersConfig string
+	// Region is the region of the gateway, such as the region of its TemporalX cluster, RegionEndpoints are the
+	// endpoints of the gateways of other regions as a comma separated list of region=url, requests to buckets
+	// labeled with those regions are redirected to their gateways
+	Region          string
+	RegionEndpoints string
+	// Faults are the rules of the faults injected into TemporalX and the ledger datastore, drawn from a random
+	// source seeded with FaultsSeed, or with the time if 0, only supported by builds with the faults build tag
+	Faults     string
+	FaultsSeed int64
+	// PublicGatewayURL is a public IPFS gateway that serves the data of share links and the /ipfs/ path of at least
+	// PublicGatewayMinSize bytes, by redirecting clients to it or proxying it as set by PublicGatewayMode,
+	// disabled if empty
+	PublicGatewayURL     string
+	PublicGatewayMode    string
+	PublicGatewayMinSize int64
+	// GetBufferSize is the size of the write buffer of downloads in bytes, unbuffered if 0
+	GetBufferSize int64
+	// GetStreamMaxAge is how long a download may stay open before the watchdog logs it as leaked,
+	// defaultStreamMaxAge if 0, if negative only downloads whose goroutines outlive their reader are logged
+	GetStreamMaxAge time.Duration
+	// UploadSpoolThreshold is the minimum size in bytes of uploads written to a spool file in UploadSpoolDir, the
+	// temporary directory if empty, before they are stored in TemporalX, spooling is disabled if 0
+	UploadSpoolThreshold int64
+	UploadSpoolDir       string
+	// MultipartConcurrency is how many chunks of the parts of a multipart upload are pushed to TemporalX at the
+	// same time, 8 if 0
+	MultipartConcurrency int
+	// CallTimeout is the deadline of unary TemporalX calls, 30 seconds if 0, PinCallTimeout of the calls that pin
+	// data, 5 minutes if 0, and StreamIdleTimeout how long file uploads and downloads wait for TemporalX to accept
+	// or return a message, a minute if 0
+	CallTimeout       time.Duration
+	PinCallTimeout    time.Duration
+	StreamIdleTimeout time.Duration
+	// ResumeAttempts is how often a file upload or download is resumed after its connection to TemporalX dropped,
+	// streams are not resumed if 0
+	ResumeAttempts int
+	// MaxConcurrentRequests is how many S3 operations run at the same time, scheduling is disabled if 0, and
+	// MaxBulkRequests how many of them transfer object data, 3/4 of MaxConcurrentRequests if 0
+	MaxConcurrentRequests int
+	MaxBulkRequests       int
+	// IngressBytesPerSecond and EgressBytesPerSecond are the bandwidth of all uploads and downloads of the gateway,
+	// in addition to the bandwidth of each bucket, unlimited if 0
+	IngressBytesPerSecond int64
+	EgressBytesPerSecond  int64
+	// Capacity is the number of bytes TemporalX can store for the gateway, uploads are not checked if 0
+	Capacity int64
+	// CapacityInterval is how often the size of the data stored by the gateway is sampled
+	CapacityInterval time.Duration
+	// GCInterval is how often unreferenced data is removed from TemporalX, disabled if 0
+	GCInterval time.Duration
+	// GCGrace is how long data stays unreferenced before it is removed, 24 hours if 0
+	GCGrace time.Duration
+	// ReadTimeout, WriteTimeout, and ListTimeout are the timeouts of S3 requests that read, write, and list,
+	// requests of a class have no timeout if 0
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	ListTimeout  time.Duration
+	// CompactionInterval is how often the ledger datastore is compacted, disabled if 0
+	CompactionInterval time.Duration
+	// DSErrorBudget is the share of ledger datastore operations that may fail or take at least DSSlowOp before the
+	// gateway reports itself not ready, defaultDSErrorBudget and defaultDSSlowOp if 0
+	DSErrorBudget float64
+	DSSlowOp      time.Duration
+	// Consistency is how ledger commits are made durable, strict syncs every commit to disk before a write returns,
+	// relaxed syncs commits every second for throughput, strict if empty
+	Consistency string
+	// LedgerRootInterval is how often the ledger root is saved to IPFS, disabled if 0
+	LedgerRootInterval time.Duration
+	// DirectoryInterval is how often the directory views of changed buckets are rebuilt, disabled if 0,
+	// views are always rebuilt on request if their bucket changed
+	DirectoryInterval time.Duration
+	// DNSLinkWebhook is a DNS provider webhook the changed DNSLink records of buckets are posted to, disabled if empty
+	DNSLinkWebhook string
+	// DNSLinkInterval is how often changed DNSLink records are posted to the DNSLinkWebhook
+	DNSLinkInterval time.Duration
+	// StandbyPrimary is the info grpc endpoint of the primary gateway of a standby gateway,
+	// the gateway is not a standby if empty
+	StandbyPrimary string
+	// StandbyInterval is how often a standby gateway ships the ledger root of its primary
+	StandbyInterval time.Duration
+	// LedgerBatchSize is the maximum number of ledger entries held by listings, deletions, and garbage collection,
+	// and the maximum number of objects listed per page, 1000 if 0
+	LedgerBatchSize int
+	// LedgerNameKeys are the keys the object names of buckets are encrypted with in the ledger datastore, as a
+	// comma separated list of bucket=key, the names of other buckets are only encoded, a bucket with encrypted
+	// names can only be opened with the same key
+	LedgerNameKeys string
+	// ClusterAddr is the info grpc endpoint of the host of the cluster of a gateway with the remote datastore type
+	ClusterAddr string
+	// ClusterServe serves the badger datastore and the lock table of the gateway to the other gateways of its cluster
+	ClusterServe bool
+	// ClusterLockEndpoints are etcd endpoints the locks of a cluster are held in, instead of the lock table of its host,
+	// all gateways of the cluster must use the same endpoints
+	ClusterLockEndpoints []string
+	// Clock provides the timestamps of buckets, objects, and ledger entries, the system time if nil
+	Clock Clock
 }
 
+// Clock returns the current time, so embedders and tests can control the timestamps of the gateway
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a Clock of the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
 // infoAPIServer provides access to the InfoAPI
 // allowing retrieval of the corresponding ipfs cids
-// for our various buckets and objects
+// for our various buckets and objects, as well
+// as the s3x specific ExtensionAPI
 type infoAPIServer struct {
 	InfoAPIServer
 	httpServer *http.Server
@@ -60,15 +224,105 @@ type infoAPIServer struct {
 type xObjects struct {
 	minio.GatewayUnsupported
 	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup // tracks background routines such as the trash purge
 	dagClient  pb.NodeAPIClient
 	fileClient pb.FileAPIClient
 
 	// ledgerStore is responsible for updating our internal ledger state
 	ledgerStore *ledgerStore
 
+	// erasure erasure codes object data across several TemporalX nodes if configured
+	erasure *erasureStore
+
+	// replicas copies object data to other TemporalX nodes for buckets with a replication factor
+	replicas *replicaStore
+
+	// cold is the cluster transitioned object data is moved to, nil if it is not configured
+	cold *coldStore
+
 	infoAPI *infoAPIServer
 
 	listener net.Listener
+
+	// creds are the credentials of the gateway, used to presign share links
+	creds auth.Credentials
+	// shareKey signs share link tokens
+	shareKey []byte
+	// eventsKey signs event stream tokens
+	eventsKey []byte
+	// listingKey signs the continuation tokens of listing sessions
+	listingKey []byte
+	// uploadKey signs the tokens of upload grant urls
+	uploadKey []byte
+	// proxyKey authenticates the calls the http endpoint forwards to the grpc endpoint
+	proxyKey string
+
+	// meter counts usage for billing if metering is enabled
+	meter *usageMeter
+	// usageSink is where the usage records of meter are written
+	usageSink usageSink
+
+	// clock provides the timestamps of buckets and objects
+	clock Clock
+	// names validates new bucket names and object keys
+	names NameValidation
+	// limits are the maximum sizes of uploads
+	limits uploadLimits
+	// timeouts are the timeouts of S3 requests by operation class
+	timeouts requestTimeouts
+	// capacity admits uploads while the stored data fits the capacity of TemporalX, nil if it is not checked
+	capacity *capacityChecker
+	// gcGrace is how long data stays unreferenced before it is collected
+	gcGrace time.Duration
+	// getBufferSize is the size of the write buffer of downloads, unbuffered if 0
+	getBufferSize int
+	// streams tracks the downloads until their reader was closed and their goroutines exited
+	streams *streamTracker
+	// spool spools large uploads to disk, nil if spooling is disabled
+	spool *uploadSpool
+	// partBudgets limit the chunks of the parts of each multipart upload that are pushed at the same time
+	partBudgets *partBudgets
+	// scheduler admits S3 operations by priority, nil if scheduling is disabled
+	scheduler *requestScheduler
+	// bandwidth throttles the uploads and downloads of buckets and of the gateway
+	bandwidth *bandwidthLimiter
+	// pinWorkers is how many queued uploads are pinned at the same time
+	pinWorkers int
+	// pinWake wakes the pin queue when data was queued
+	pinWake chan struct{}
+	// usage caches the data stored by the gateway as accounted by the ledger
+	usage storageUsageCache
+	// xAddr is the TemporalX endpoint, reported as the mount path of the stored data
+	xAddr string
+	// dsType is the type of the ledger datastore
+	dsType DSType
+	// compactor compacts the ledger datastore
+	compactor *datastoreCompactor
+	// standby ships the ledger roots of the primary gateway, nil if the gateway was not started as a standby
+	standby *ledgerStandby
+	// publicGateway serves large downloads through a public IPFS gateway, nil if it is not configured
+	publicGateway *publicGateway
+	// dnslinkWebhook posts the changed DNSLink records of buckets, nil if it is not configured
+	dnslinkWebhook *dnslinkWebhook
+	// copies tracks the progress of copies that store new data
+	copies copyTracker
+	// imports tracks the imports from S3 compatible services running on this gateway
+	imports importTracker
+	// blockPublicAccess blocks all public access to all buckets
+	blockPublicAccess bool
+	// denyByDefault denies the requests of the root credential that are not allowed by bucket policies
+	denyByDefault ownerRestriction
+	// requestAuth hardens the verification of signed requests
+	requestAuth requestAuth
+	// debugErrors returns the chain of errors of failed requests to clients that ask for it
+	debugErrors bool
+	// profiling exposes the runtime profiles of the gateway to admin requests
+	profiling bool
+	// headers are the header rules of buckets, nil if there are none
+	headers *bucketHeaders
+	// regions routes the requests to buckets to the gateways of their regions, nil if the gateway has no region
+	regions *bucketRegions
 }
 
 func init() {
@@ -81,12 +335,12 @@ func init() {
 			cli.StringFlag{
 				Name:  "info.http.endpoint",
 				Usage: "the endpoint to serve the info http api on",
-				Value: "0.0.0.0:8889",
+				Value: "127.0.0.1:8889",
 			},
 			cli.StringFlag{
 				Name:  "info.grpc.endpoint",
 				Usage: "the endpoint to serve the info grpc api on",
-				Value: "0.0.0.0:8888",
+				Value: "127.0.0.1:8888",
 			},
 			cli.StringFlag{
 				Name:  "ds.path",
@@ -95,7 +349,7 @@ func init() {
 			},
 			cli.StringFlag{
 				Name:  "ds.type",
-				Usage: "the type backend to store ledger data in, supported values are [badger, crdt]",
+				Usage: "the type backend to store ledger data in, supported values are [badger, crdt, remote]",
 				Value: "badger",
 			},
 			cli.StringFlag{
@@ -112,6 +366,298 @@ func init() {
 				Name:  "temporalx.insecure",
 				Usage: "initiate an insecure connection to the temporalx endpoint",
 			},
+			cli.StringFlag{
+				Name:  "erasure.endpoints",
+				Usage: "comma separated temporalx endpoints to erasure code object data across, one shard is stored on each",
+			},
+			cli.IntFlag{
+				Name:  "erasure.parity",
+				Usage: "the number of erasure.endpoints that can be lost without losing object data",
+				Value: 1,
+			},
+			cli.StringFlag{
+				Name:  "replication.endpoints",
+				Usage: "comma separated temporalx endpoints that buckets with a replication factor copy object data to",
+			},
+			cli.DurationFlag{
+				Name:  "replication.scrub.interval",
+				Usage: "how often the replicas of all objects are verified and repaired, 0 disables scrubbing",
+				Value: 24 * time.Hour,
+			},
+			cli.StringFlag{
+				Name:  "cold.endpoint",
+				Usage: "the temporalx endpoint of a cold cluster that buckets with transition days move object data to",
+			},
+			cli.DurationFlag{
+				Name:  "transition.interval",
+				Usage: "how often the data of objects is moved to the cold cluster",
+				Value: defaultTransitionInterval,
+			},
+			cli.DurationFlag{
+				Name:  "pin.check.interval",
+				Usage: "how often the pins of the data of all objects are verified on TemporalX and lost pins repaired, 0 disables the checks",
+			},
+			cli.IntFlag{
+				Name:  "pin.queue.workers",
+				Usage: "how many uploads with asynchronous pinning are pinned at the same time",
+				Value: defaultPinQueueWorkers,
+			},
+			cli.StringFlag{
+				Name:  "metering.sink",
+				Usage: "where usage records are written: file:<path>, an http(s) webhook url, or bucket://<bucket>/<prefix>, disabled if empty",
+			},
+			cli.DurationFlag{
+				Name:  "metering.interval",
+				Usage: "how often usage records are written to the metering.sink",
+				Value: time.Hour,
+			},
+			cli.StringFlag{
+				Name:  "names.validation",
+				Usage: "how strictly bucket names and object keys are validated: strict (DNS-compatible bucket names, no control characters in keys) or relaxed",
+				Value: string(NameValidationStrict),
+			},
+			cli.StringFlag{
+				Name:  "limits.object.size",
+				Usage: "the maximum object size, such as 5TiB or 100MB",
+				Value: "5TiB",
+			},
+			cli.StringFlag{
+				Name:  "limits.part.size",
+				Usage: "the maximum multipart part size, such as 5GiB or 64MB",
+				Value: "5GiB",
+			},
+			cli.IntFlag{
+				Name:  "limits.part.count",
+				Usage: "the maximum number of parts of a multipart upload, at most 10000",
+				Value: defaultMaxPartCount,
+			},
+			cli.IntFlag{
+				Name:  "limits.metadata.size",
+				Usage: "the maximum size of user defined metadata in bytes",
+				Value: defaultMaxMetadataSize,
+			},
+			cli.StringFlag{
+				Name:  "capacity.max",
+				Usage: "the storage capacity of TemporalX for the gateway, such as 10TiB, uploads that would exceed it are rejected, empty disables the check",
+			},
+			cli.DurationFlag{
+				Name:  "capacity.interval",
+				Usage: "how often the size of the data stored by the gateway is sampled for capacity checks",
+				Value: defaultCapacityInterval,
+			},
+			cli.DurationFlag{
+				Name:  "gc.interval",
+				Usage: "how often unreferenced object data is removed from TemporalX, 0 disables scheduled garbage collection",
+			},
+			cli.DurationFlag{
+				Name:  "gc.grace",
+				Usage: "how long object data stays unreferenced before it is removed by garbage collection",
+				Value: defaultGCGrace,
+			},
+			cli.DurationFlag{
+				Name:  "timeout.read",
+				Usage: "the timeout of S3 requests that read objects and bucket settings, including the download, 0 disables it",
+			},
+			cli.DurationFlag{
+				Name:  "timeout.write",
+				Usage: "the timeout of S3 requests that upload, copy, and delete objects, and change buckets, 0 disables it",
+			},
+			cli.DurationFlag{
+				Name:  "timeout.list",
+				Usage: "the timeout of S3 requests that list buckets, objects, and multipart parts, 0 disables it",
+			},
+			cli.BoolFlag{
+				Name:  "public-access.block",
+				Usage: "block all public access granted by ACLs and bucket policies to all buckets",
+			},
+			cli.BoolFlag{
+				Name:  "deny-by-default",
+				Usage: "only allow the S3 requests of the root credential that are allowed by bucket policies, and deny its admin requests",
+			},
+			cli.StringFlag{
+				Name:  "break-glass.key",
+				Usage: "a secret of at least 16 characters that exempts requests of the root credential sent with it in the x-s3x-break-glass header from deny-by-default",
+			},
+			cli.DurationFlag{
+				Name:  "auth.max-clock-skew",
+				Usage: "the allowed difference between the date of a signed request and the server time, at most 1h, 0 keeps the default of 15m",
+			},
+			cli.BoolFlag{
+				Name:  "auth.require-v4",
+				Usage: "deny requests signed with signature version 2",
+			},
+			cli.StringFlag{
+				Name:  "auth.region",
+				Usage: "the region signed requests must be scoped to, empty accepts the server region",
+			},
+			cli.BoolFlag{
+				Name:  "debug.errors",
+				Usage: "return the chain of errors of failed requests that send the x-s3x-debug-errors header in the x-s3x-error-details response header",
+			},
+			cli.BoolFlag{
+				Name:  "profiling",
+				Usage: "expose the CPU, block, heap, and goroutine profiles of the gateway to admin requests",
+			},
+			cli.StringFlag{
+				Name:  "headers.config",
+				Usage: "a json file of response headers to set on, and request headers to require of, requests to buckets, reloaded on SIGHUP",
+			},
+			cli.StringFlag{
+				Name:  "region.name",
+				Usage: "the region of the gateway, new buckets are labeled with it, set MINIO_REGION_NAME to the same region",
+			},
+			cli.StringFlag{
+				Name:  "region.endpoints",
+				Usage: "the gateways of other regions, such as eu-west-1=https://eu.s3x.example.com, requests to buckets of those regions are redirected to them",
+			},
+			cli.StringFlag{
+				Name:  "faults",
+				Usage: "inject faults into TemporalX and the ledger datastore for testing, such as temporalx:error=0.05,latency=50ms;datastore.put:error=0.01, requires a build with the faults build tag",
+			},
+			cli.Int64Flag{
+				Name:  "faults.seed",
+				Usage: "the seed of the random source of injected faults, so a run can be repeated, 0 uses the time",
+			},
+			cli.StringFlag{
+				Name:  "public-gateway.url",
+				Usage: "a public IPFS gateway, such as https://ipfs.io, that serves large share link and /ipfs/ downloads, empty disables it",
+			},
+			cli.StringFlag{
+				Name:  "public-gateway.mode",
+				Usage: "whether clients are redirected to the public gateway (redirect) or its responses are proxied (proxy)",
+				Value: publicGatewayRedirect,
+			},
+			cli.StringFlag{
+				Name:  "get.buffer.size",
+				Usage: "the size of the write buffer of downloads, such as 4MiB, 0 disables buffering",
+				Value: "1MiB",
+			},
+			cli.DurationFlag{
+				Name:  "get.stream.max-age",
+				Usage: "log downloads whose reader is still open after this duration, or whose goroutines still run a minute after it was closed, a negative duration only logs the latter",
+				Value: defaultStreamMaxAge,
+			},
+			cli.StringFlag{
+				Name:  "upload.spool.threshold",
+				Usage: "the minimum size of uploads written to a spool file before they are stored in TemporalX, such as 64MiB, empty disables spooling",
+			},
+			cli.StringFlag{
+				Name:  "upload.spool.dir",
+				Usage: "the directory of upload spool files, the temporary directory if empty",
+			},
+			cli.IntFlag{
+				Name:  "multipart.concurrency",
+				Usage: "how many chunks of the parts of a multipart upload are pushed to TemporalX at the same time",
+				Value: defaultMultipartConcurrency,
+			},
+			cli.DurationFlag{
+				Name:  "temporalx.timeout.call",
+				Usage: "the deadline of TemporalX calls, calls that exceed it fail S3 requests with SlowDown",
+				Value: defaultCallTimeout,
+			},
+			cli.DurationFlag{
+				Name:  "temporalx.timeout.pin",
+				Usage: "the deadline of TemporalX calls that pin data",
+				Value: defaultPinCallTimeout,
+			},
+			cli.DurationFlag{
+				Name:  "temporalx.timeout.stream-idle",
+				Usage: "how long TemporalX file uploads and downloads wait for TemporalX to accept or return a message",
+				Value: defaultStreamIdleTimeout,
+			},
+			cli.IntFlag{
+				Name:  "temporalx.resume.attempts",
+				Usage: "how often a TemporalX file upload or download is resumed after the connection dropped, 0 disables resuming",
+				Value: defaultResumeAttempts,
+			},
+			cli.IntFlag{
+				Name:  "scheduler.max-requests",
+				Usage: "how many S3 operations run at the same time, interactive operations are admitted before bulk transfers, 0 disables scheduling",
+			},
+			cli.IntFlag{
+				Name:  "scheduler.max-bulk",
+				Usage: "how many of the scheduled S3 operations transfer object data, 3/4 of scheduler.max-requests if 0",
+			},
+			cli.StringFlag{
+				Name:  "bandwidth.ingress",
+				Usage: "the bandwidth of all uploads per second, such as 100MiB, empty is unlimited",
+			},
+			cli.StringFlag{
+				Name:  "bandwidth.egress",
+				Usage: "the bandwidth of all downloads per second, such as 100MiB, empty is unlimited",
+			},
+			cli.StringFlag{
+				Name:  "public-gateway.min-size",
+				Usage: "the minimum size of downloads served through the public gateway, such as 100MiB",
+				Value: "100MiB",
+			},
+			cli.DurationFlag{
+				Name:  "ds.compaction.interval",
+				Usage: "how often the disk space of deleted ledger entries is reclaimed, 0 disables scheduled compactions",
+				Value: defaultCompactionInterval,
+			},
+			cli.Float64Flag{
+				Name:  "ds.error-budget",
+				Usage: "the share of ledger datastore operations in the last 5 minutes that may fail or be slow before the gateway reports itself not ready",
+				Value: defaultDSErrorBudget,
+			},
+			cli.DurationFlag{
+				Name:  "ds.slow-op",
+				Usage: "the duration of ledger datastore operations that are counted against the error budget as slow",
+				Value: defaultDSSlowOp,
+			},
+			cli.StringFlag{
+				Name:  "consistency",
+				Usage: "strict syncs the ledger to disk before writes return, relaxed syncs it every second for throughput, reads see every write that returned with both, supported values are [strict, relaxed]",
+				Value: consistencyStrict,
+			},
+			cli.DurationFlag{
+				Name:  "ledger.root.interval",
+				Usage: "how often the ledger root is saved to IPFS, so the ledger can be recovered from its hash, 0 disables it",
+			},
+			cli.DurationFlag{
+				Name:  "directory.interval",
+				Usage: "how often the UnixFS directory views of changed buckets are rebuilt, 0 only rebuilds them on request",
+			},
+			cli.StringFlag{
+				Name:  "dnslink.webhook",
+				Usage: "an http(s) url the changed _dnslink TXT records of buckets with a dnslink domain are posted to as json, empty disables it",
+			},
+			cli.DurationFlag{
+				Name:  "dnslink.interval",
+				Usage: "how often changed dnslink records are posted to the dnslink webhook",
+				Value: defaultDNSLinkInterval,
+			},
+			cli.StringFlag{
+				Name:  "standby.primary",
+				Usage: "the info grpc endpoint of a primary gateway, starts this gateway as its standby with an empty ledger",
+			},
+			cli.DurationFlag{
+				Name:  "standby.interval",
+				Usage: "how often a standby gateway ships the ledger root of its primary",
+				Value: defaultStandbyInterval,
+			},
+			cli.IntFlag{
+				Name:  "ledger.batch.size",
+				Usage: "the maximum number of ledger entries held in memory by listings, deletions, and garbage collection",
+				Value: defaultLedgerBatchSize,
+			},
+			cli.StringFlag{
+				Name:  "ledger.names.keys",
+				Usage: "comma separated bucket=key secrets of at least 16 characters the object names of buckets are encrypted with in the ledger datastore, such as confidential=<key>, the ledger can not be opened without them",
+			},
+			cli.StringFlag{
+				Name:  "cluster.addr",
+				Usage: "the info grpc endpoint of the gateway hosting the ledger of the cluster, used with the remote datastore type",
+			},
+			cli.BoolFlag{
+				Name:  "cluster.serve",
+				Usage: "serve the ledger datastore and locks to the other gateways of the cluster, requires the badger datastore type",
+			},
+			cli.StringFlag{
+				Name:  "cluster.locks",
+				Usage: "comma separated etcd endpoints to hold the locks of the cluster in instead of the gateway serving the cluster, set on every gateway of the cluster",
+			},
 		},
 	}); err != nil {
 		panic(err)
@@ -119,6 +665,45 @@ func init() {
 }
 
 func temxGatewayMain(ctx *cli.Context) {
+	maxObjectSize, err := humanize.ParseBytes(ctx.String("limits.object.size"))
+	if err != nil {
+		log.Fatalf("invalid limits.object.size: %v", err)
+	}
+	maxPartSize, err := humanize.ParseBytes(ctx.String("limits.part.size"))
+	if err != nil {
+		log.Fatalf("invalid limits.part.size: %v", err)
+	}
+	var capacity uint64
+	if ctx.String("capacity.max") != "" {
+		if capacity, err = humanize.ParseBytes(ctx.String("capacity.max")); err != nil {
+			log.Fatalf("invalid capacity.max: %v", err)
+		}
+	}
+	publicGatewayMinSize, err := humanize.ParseBytes(ctx.String("public-gateway.min-size"))
+	if err != nil {
+		log.Fatalf("invalid public-gateway.min-size: %v", err)
+	}
+	getBufferSize, err := humanize.ParseBytes(ctx.String("get.buffer.size"))
+	if err != nil {
+		log.Fatalf("invalid get.buffer.size: %v", err)
+	}
+	var uploadSpoolThreshold uint64
+	if ctx.String("upload.spool.threshold") != "" {
+		if uploadSpoolThreshold, err = humanize.ParseBytes(ctx.String("upload.spool.threshold")); err != nil {
+			log.Fatalf("invalid upload.spool.threshold: %v", err)
+		}
+	}
+	var ingress, egress uint64
+	if ctx.String("bandwidth.ingress") != "" {
+		if ingress, err = humanize.ParseBytes(ctx.String("bandwidth.ingress")); err != nil {
+			log.Fatalf("invalid bandwidth.ingress: %v", err)
+		}
+	}
+	if ctx.String("bandwidth.egress") != "" {
+		if egress, err = humanize.ParseBytes(ctx.String("bandwidth.egress")); err != nil {
+			log.Fatalf("invalid bandwidth.egress: %v", err)
+		}
+	}
 	minio.StartGateway(ctx, &TEMX{
 		HTTPAddr:  ctx.String("info.http.endpoint"),
 		GRPCAddr:  ctx.String("info.grpc.endpoint"),
@@ -127,33 +712,169 @@ func temxGatewayMain(ctx *cli.Context) {
 		CrdtTopic: ctx.String("ds.topic"),
 		XAddr:     ctx.String("temporalx.endpoint"),
 		Insecure:  ctx.Bool("temporalx.insecure"),
+
+		CallTimeout:       ctx.Duration("temporalx.timeout.call"),
+		PinCallTimeout:    ctx.Duration("temporalx.timeout.pin"),
+		StreamIdleTimeout: ctx.Duration("temporalx.timeout.stream-idle"),
+		ResumeAttempts:    ctx.Int("temporalx.resume.attempts"),
+
+		ErasureXAddrs:       splitEndpoints(ctx.String("erasure.endpoints")),
+		ErasureParityShards: ctx.Int("erasure.parity"),
+
+		ReplicaXAddrs:            splitEndpoints(ctx.String("replication.endpoints")),
+		ReplicationScrubInterval: ctx.Duration("replication.scrub.interval"),
+
+		ColdXAddr:          ctx.String("cold.endpoint"),
+		TransitionInterval: ctx.Duration("transition.interval"),
+
+		PinCheckInterval: ctx.Duration("pin.check.interval"),
+		PinQueueWorkers:  ctx.Int("pin.queue.workers"),
+
+		MeteringSink:     ctx.String("metering.sink"),
+		MeteringInterval: ctx.Duration("metering.interval"),
+
+		NameValidation: NameValidation(ctx.String("names.validation")),
+
+		MaxObjectSize:   int64(maxObjectSize),
+		MaxPartSize:     int64(maxPartSize),
+		MaxPartCount:    ctx.Int("limits.part.count"),
+		MaxMetadataSize: ctx.Int("limits.metadata.size"),
+
+		Capacity:         int64(capacity),
+		CapacityInterval: ctx.Duration("capacity.interval"),
+
+		GCInterval: ctx.Duration("gc.interval"),
+		GCGrace:    ctx.Duration("gc.grace"),
+
+		ReadTimeout:  ctx.Duration("timeout.read"),
+		WriteTimeout: ctx.Duration("timeout.write"),
+		ListTimeout:  ctx.Duration("timeout.list"),
+
+		BlockPublicAccess: ctx.Bool("public-access.block"),
+		DenyByDefault:     ctx.Bool("deny-by-default"),
+		BreakGlassKey:     ctx.String("break-glass.key"),
+
+		MaxClockSkew:       ctx.Duration("auth.max-clock-skew"),
+		RequireSignatureV4: ctx.Bool("auth.require-v4"),
+		AuthRegion:         ctx.String("auth.region"),
+
+		DebugErrors:   ctx.Bool("debug.errors"),
+		Profiling:     ctx.Bool("profiling"),
+		HeadersConfig: ctx.String("headers.config"),
+		Faults:        ctx.String("faults"),
+		FaultsSeed:    ctx.Int64("faults.seed"),
+
+		Region:          ctx.String("region.name"),
+		RegionEndpoints: ctx.String("region.endpoints"),
+
+		PublicGatewayURL:     ctx.String("public-gateway.url"),
+		PublicGatewayMode:    ctx.String("public-gateway.mode"),
+		PublicGatewayMinSize: int64(publicGatewayMinSize),
+
+		GetBufferSize:   int64(getBufferSize),
+		GetStreamMaxAge: ctx.Duration("get.stream.max-age"),
+
+		UploadSpoolThreshold: int64(uploadSpoolThreshold),
+		UploadSpoolDir:       ctx.String("upload.spool.dir"),
+		MultipartConcurrency: ctx.Int("multipart.concurrency"),
+
+		MaxConcurrentRequests: ctx.Int("scheduler.max-requests"),
+		MaxBulkRequests:       ctx.Int("scheduler.max-bulk"),
+
+		IngressBytesPerSecond: int64(ingress),
+		EgressBytesPerSecond:  int64(egress),
+
+		CompactionInterval: ctx.Duration("ds.compaction.interval"),
+		DSErrorBudget:      ctx.Float64("ds.error-budget"),
+		DSSlowOp:           ctx.Duration("ds.slow-op"),
+		Consistency:        ctx.String("consistency"),
+		LedgerRootInterval: ctx.Duration("ledger.root.interval"),
+		DirectoryInterval:  ctx.Duration("directory.interval"),
+		DNSLinkWebhook:     ctx.String("dnslink.webhook"),
+		DNSLinkInterval:    ctx.Duration("dnslink.interval"),
+		LedgerBatchSize:    ctx.Int("ledger.batch.size"),
+		LedgerNameKeys:     ctx.String("ledger.names.keys"),
+
+		ClusterAddr:          ctx.String("cluster.addr"),
+		ClusterServe:         ctx.Bool("cluster.serve"),
+		ClusterLockEndpoints: splitEndpoints(ctx.String("cluster.locks")),
+
+		StandbyPrimary:  ctx.String("standby.primary"),
+		StandbyInterval: ctx.Duration("standby.interval"),
 	})
 }
 
 // newLedgerStore returns an instance of ledgerStore
-func (g *TEMX) newLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient) (*ledgerStore, error) {
+func (g *TEMX) newLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient, creds auth.Credentials, faults *faultInjector) (*ledgerStore, error) {
 	switch g.DSType {
 	case DSTypeBadger:
-		return g.newBadgerLedgerStore(dag)
+		return g.newBadgerLedgerStore(dag, faults)
 	case DSTypeCrdt:
-		return g.newCrdtLedgerStore(ctx, dag, pub)
+		return g.newCrdtLedgerStore(ctx, dag, pub, faults)
+	case DSTypeRemote:
+		return g.newRemoteLedgerStore(dag, creds, faults)
 	}
 	return nil, fmt.Errorf(`data store type "%v" not supported`, g.DSType)
 }
 
+// newRemoteLedgerStore returns an instance of ledgerStore that uses the datastore and locks of the host of a cluster
+func (g *TEMX) newRemoteLedgerStore(dag pb.NodeAPIClient, creds auth.Credentials, faults *faultInjector) (*ledgerStore, error) {
+	switch {
+	case g.ClusterAddr == "":
+		return nil, fmt.Errorf("the remote datastore type requires a cluster address")
+	case g.ClusterServe:
+		return nil, fmt.Errorf("a gateway with the remote datastore type can not serve a cluster")
+	case g.StandbyPrimary != "":
+		return nil, fmt.Errorf("a gateway with the remote datastore type can not be a standby")
+	}
+	ds, err := dialCluster(g.ClusterAddr, newClusterKey(creds))
+	if err != nil {
+		return nil, err
+	}
+	ls, err := newLedgerStore(faults.datastore(ds), dag)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.ClusterLockEndpoints) == 0 {
+		ls.joinCluster(&remoteLocks{client: ds.client})
+		return ls, nil
+	}
+	locks, err := newEtcdLocks(g.ClusterLockEndpoints)
+	if err != nil {
+		_ = ls.Close() // the etcd error is more relevant
+		return nil, err
+	}
+	ls.cleanup = append(ls.cleanup, locks.Close)
+	ls.joinCluster(locks)
+	return ls, nil
+}
+
 // newBadgerLedgerStore returns an instance of ledgerStore that uses badgerv2
-func (g *TEMX) newBadgerLedgerStore(dag pb.NodeAPIClient) (*ledgerStore, error) {
-	opts := badger.DefaultOptions
-	ds, err := badger.NewDatastore(g.DSPath, &opts)
+func (g *TEMX) newBadgerLedgerStore(dag pb.NodeAPIClient, faults *faultInjector) (*ledgerStore, error) {
+	ds, err := badger.NewDatastore(g.DSPath, badgerOptions(g.Consistency))
+	if err != nil {
+		return nil, err
+	}
+	ls, err := newLedgerStore(faults.datastore(ds), dag)
 	if err != nil {
 		return nil, err
 	}
-	return newLedgerStore(ds, dag)
+	ls.backend = ds
+	return ls, nil
+}
+
+// badgerOptions returns the options of badger datastores, the gateway schedules their garbage collection,
+// and syncs them to disk itself with the relaxed consistency
+func badgerOptions(consistency string) *badger.Options {
+	opts := badger.DefaultOptions
+	opts.GcInterval = 0
+	opts.SyncWrites = consistency != consistencyRelaxed
+	return &opts
 }
 
 // newCrdtLedgerStore returns an instance of ledgerStore that uses crdt and backed by badgerv2
-func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient) (*ledgerStore, error) {
-	store, err := badger.NewDatastore(g.DSPath, &badger.DefaultOptions)
+func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub pb.PubSubAPIClient, faults *faultInjector) (*ledgerStore, error) {
+	store, err := badger.NewDatastore(g.DSPath, badgerOptions(g.Consistency))
 	if err != nil {
 		return nil, err
 	}
@@ -177,19 +898,75 @@ func (g *TEMX) newCrdtLedgerStore(ctx context.Context, dag pb.NodeAPIClient, pub
 	if err != nil {
 		return nil, err
 	}
-	ls, err := newLedgerStore(crdtds, dag)
+	ls, err := newLedgerStore(faults.datastore(crdtds), dag)
 	if err != nil {
 		return nil, err
 	}
+	ls.backend = store
+	// other replicas write to the datastore, so cached buckets are checked like in a cluster
+	ls.existence.disabled = true
+	ls.shared = true
 	ls.cleanup = append(ls.cleanup, cleanup)
 	cleanup = nil //disable defer cleanup
 	return ls, nil
 }
 
+// splitEndpoints splits a comma separated list of endpoints
+func splitEndpoints(s string) []string {
+	var endpoints []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
 // returns an instance of xObjects
-func (g *TEMX) getXObjects(creds auth.Credentials) (*xObjects, error) {
-	ctx := context.TODO()
-	var dialOpts []grpc.DialOption
+func (g *TEMX) getXObjects(creds auth.Credentials) (_ *xObjects, err error) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+	names, err := newNameValidation(string(g.NameValidation))
+	if err != nil {
+		return nil, err
+	}
+	limits, err := newUploadLimits(g.MaxObjectSize, g.MaxPartSize, g.MaxPartCount, g.MaxMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := newRequestTimeouts(g.ReadTimeout, g.WriteTimeout, g.ListTimeout)
+	if err != nil {
+		return nil, err
+	}
+	deadlines, err := newCallDeadlines(g.CallTimeout, g.PinCallTimeout, g.StreamIdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	resumer, err := newStreamResumer(g.ResumeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	denyByDefault, err := newOwnerRestriction(g.DenyByDefault, g.BreakGlassKey)
+	if err != nil {
+		return nil, err
+	}
+	requestAuth, err := newRequestAuth(g.MaxClockSkew, g.RequireSignatureV4, g.AuthRegion)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := newFaultInjector(g.Faults, g.FaultsSeed)
+	if err != nil {
+		return nil, err
+	}
+	// streams are resumed before the call deadlines, so every attempt gets its own deadline
+	dialOpts := append(resumer.dialOptions(), deadlines.dialOptions()...)
+	dialOpts = append(dialOpts, correlationDialOptions()...)
+	// faults are injected last, so the interceptors above handle them like failures of TemporalX
+	dialOpts = append(dialOpts, faults.dialOptions()...)
 	if g.Insecure {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
 	} else {
@@ -209,34 +986,298 @@ func (g *TEMX) getXObjects(creds auth.Credentials) (*xObjects, error) {
 	dag := pb.NewNodeAPIClient(conn)
 	pub := pb.NewPubSubAPIClient(conn)
 	// instantiate our internal ledger
-	ledger, err := g.newLedgerStore(ctx, dag, pub)
+	if g.ClusterServe && g.DSType != DSTypeBadger {
+		return nil, fmt.Errorf("only the badger datastore type can serve a cluster, got %v", g.DSType)
+	}
+	if len(g.ClusterLockEndpoints) > 0 && !g.ClusterServe && g.DSType != DSTypeRemote {
+		return nil, fmt.Errorf("cluster lock endpoints are only used by gateways of a cluster")
+	}
+	if err := checkConsistency(g.Consistency, g.DSType); err != nil {
+		return nil, err
+	}
+	ledger, err := g.newLedgerStore(ctx, dag, pub, creds, faults)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			_ = ledger.Close() // the startup error is more relevant than a close error
+		}
+	}()
+	if err = ledger.dsHealth.setBudget(g.DSErrorBudget, g.DSSlowOp); err != nil {
+		return nil, err
+	}
+	clock := g.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	ledger.clock = clock
+	var locks *clusterLocks
+	switch {
+	case g.ClusterServe && len(g.ClusterLockEndpoints) > 0:
+		etcd, err := newEtcdLocks(g.ClusterLockEndpoints)
+		if err != nil {
+			return nil, err
+		}
+		ledger.cleanup = append(ledger.cleanup, etcd.Close)
+		ledger.joinCluster(etcd)
+	case g.ClusterServe:
+		locks = newClusterLocks()
+		ledger.joinCluster(locks)
+	}
+	if g.LedgerBatchSize > 0 {
+		ledger.batchSize = g.LedgerBatchSize
+	}
+	hctx, hcancel := context.WithTimeout(ctx, startupHealthTimeout)
+	err = ledger.checkHealth(hctx)
+	hcancel()
+	if err != nil {
+		return nil, fmt.Errorf("startup health check failed: %w", err)
+	}
+	objectNames, err := newObjectNames(g.LedgerNameKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := ledger.setObjectNames(objectNames); err != nil {
+		return nil, err
+	}
+	// connect to the TemporalX nodes used for erasure coding
+	var erasure *erasureStore
+	if len(g.ErasureXAddrs) > 0 {
+		clients := make([]pb.FileAPIClient, 0, len(g.ErasureXAddrs))
+		for _, addr := range g.ErasureXAddrs {
+			econn, err := grpc.Dial(addr, dialOpts...)
+			if err != nil {
+				return nil, err
+			}
+			clients = append(clients, pb.NewFileAPIClient(econn))
+		}
+		if erasure, err = newErasureStore(g.ErasureXAddrs, clients, g.ErasureParityShards); err != nil {
+			return nil, err
+		}
+	}
+	// connect to the TemporalX nodes used for replication
+	var replicas *replicaStore
+	if len(g.ReplicaXAddrs) > 0 {
+		clients := make([]pb.FileAPIClient, 0, len(g.ReplicaXAddrs))
+		for _, addr := range g.ReplicaXAddrs {
+			rconn, err := grpc.Dial(addr, dialOpts...)
+			if err != nil {
+				return nil, err
+			}
+			clients = append(clients, pb.NewFileAPIClient(rconn))
+		}
+		if replicas, err = newReplicaStore(g.ReplicaXAddrs, clients); err != nil {
+			return nil, err
+		}
+	}
+	// connect to the cold cluster
+	var cold *coldStore
+	if g.ColdXAddr != "" {
+		if g.TransitionInterval <= 0 {
+			return nil, fmt.Errorf("transition interval must be positive, got %v", g.TransitionInterval)
+		}
+		cconn, err := grpc.Dial(g.ColdXAddr, dialOpts...)
+		if err != nil {
+			return nil, err
+		}
+		cold = &coldStore{
+			addr:       g.ColdXAddr,
+			fileClient: pb.NewFileAPIClient(cconn),
+			dagClient:  pb.NewNodeAPIClient(cconn),
+		}
+	}
 	// create a grpc listener
 	listener, err := net.Listen("tcp", g.GRPCAddr)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			_ = listener.Close()
+		}
+	}()
+	shareKey, err := newTokenKey(creds, "s3x share link")
+	if err != nil {
+		return nil, err
+	}
+	eventsKey, err := newTokenKey(creds, "s3x event stream")
+	if err != nil {
+		return nil, err
+	}
+	listingKey, err := newTokenKey(creds, "s3x listing session")
+	if err != nil {
+		return nil, err
+	}
+	uploadKey, err := newTokenKey(creds, "s3x upload grant")
+	if err != nil {
+		return nil, err
+	}
+	proxyKey, err := newProxyKey()
+	if err != nil {
+		return nil, err
+	}
 	// instantiate initial xObjects type
 	// responsible for bridging S3 -> TemporalX (IPFS)
 	xobj := &xObjects{
 		ctx:         ctx,
+		cancel:      cancel,
 		dagClient:   dag,
 		fileClient:  pb.NewFileAPIClient(conn),
 		ledgerStore: ledger,
-		infoAPI: &infoAPIServer{
-			httpMux:    runtime.NewServeMux(),
-			grpcServer: grpc.NewServer(),
-		},
-		listener: listener,
+		erasure:     erasure,
+		replicas:    replicas,
+		cold:        cold,
+		infoAPI:     &infoAPIServer{},
+		listener:    listener,
+		creds:       creds,
+		shareKey:    shareKey,
+		eventsKey:   eventsKey,
+		proxyKey:    proxyKey,
+		clock:       clock,
+		names:       names,
+		limits:      limits,
+		timeouts:    timeouts,
+		dsType:      g.DSType,
+		xAddr:       g.XAddr,
+		compactor:   newDatastoreCompactor(ledger.backend, g.DSPath, g.CompactionInterval),
+		pinWake:     make(chan struct{}, 1),
+
+		listingKey:        listingKey,
+		uploadKey:         uploadKey,
+		blockPublicAccess: g.BlockPublicAccess,
+		denyByDefault:     denyByDefault,
+		requestAuth:       requestAuth,
+		debugErrors:       g.DebugErrors,
+		profiling:         g.Profiling,
+	}
+	if g.HeadersConfig != "" {
+		if xobj.headers, err = loadBucketHeaders(g.HeadersConfig); err != nil {
+			return nil, err
+		}
+	}
+	if xobj.regions, err = newBucketRegions(g.Region, g.RegionEndpoints); err != nil {
+		return nil, err
+	}
+	if g.Capacity > 0 {
+		if g.CapacityInterval <= 0 {
+			return nil, fmt.Errorf("capacity interval must be positive, got %v", g.CapacityInterval)
+		}
+		xobj.capacity = &capacityChecker{capacity: g.Capacity}
+	}
+	switch {
+	case g.GCGrace < 0:
+		return nil, fmt.Errorf("gc grace can not be negative, got %v", g.GCGrace)
+	case g.GCGrace == 0:
+		xobj.gcGrace = defaultGCGrace
+	default:
+		xobj.gcGrace = g.GCGrace
+	}
+	switch {
+	case g.PinQueueWorkers < 0:
+		return nil, fmt.Errorf("pin queue workers can not be negative, got %v", g.PinQueueWorkers)
+	case g.PinQueueWorkers == 0:
+		xobj.pinWorkers = defaultPinQueueWorkers
+	default:
+		xobj.pinWorkers = g.PinQueueWorkers
+	}
+	if g.GetBufferSize < 0 || g.GetBufferSize > maxGetBufferSize {
+		return nil, fmt.Errorf("get buffer size must be between 0 and %v, got %v", maxGetBufferSize, g.GetBufferSize)
 	}
+	xobj.getBufferSize = int(g.GetBufferSize)
+	switch {
+	case g.GetStreamMaxAge < 0:
+		xobj.streams = newStreamTracker(clock, 0)
+	case g.GetStreamMaxAge == 0:
+		xobj.streams = newStreamTracker(clock, defaultStreamMaxAge)
+	default:
+		xobj.streams = newStreamTracker(clock, g.GetStreamMaxAge)
+	}
+	if g.IngressBytesPerSecond < 0 || g.EgressBytesPerSecond < 0 {
+		return nil, fmt.Errorf("bandwidth can not be negative, got ingress %v and egress %v", g.IngressBytesPerSecond, g.EgressBytesPerSecond)
+	}
+	xobj.bandwidth = newBandwidthLimiter(g.IngressBytesPerSecond, g.EgressBytesPerSecond)
+	if xobj.scheduler, err = newRequestScheduler(g.MaxConcurrentRequests, g.MaxBulkRequests); err != nil {
+		return nil, err
+	}
+	switch {
+	case g.MultipartConcurrency < 0:
+		return nil, fmt.Errorf("multipart concurrency can not be negative, got %v", g.MultipartConcurrency)
+	case g.MultipartConcurrency == 0:
+		xobj.partBudgets = newPartBudgets(defaultMultipartConcurrency)
+	default:
+		xobj.partBudgets = newPartBudgets(g.MultipartConcurrency)
+	}
+	switch {
+	case g.UploadSpoolThreshold < 0:
+		return nil, fmt.Errorf("upload spool threshold can not be negative, got %v", g.UploadSpoolThreshold)
+	case g.UploadSpoolThreshold > 0:
+		if xobj.spool, err = newUploadSpool(g.UploadSpoolDir, g.UploadSpoolThreshold); err != nil {
+			return nil, fmt.Errorf("failed to open upload spool directory: %v", err)
+		}
+	}
+	if g.PublicGatewayURL != "" {
+		if xobj.publicGateway, err = newPublicGateway(g.PublicGatewayURL, g.PublicGatewayMode, g.PublicGatewayMinSize); err != nil {
+			return nil, err
+		}
+	}
+	if g.DNSLinkWebhook != "" {
+		if g.DNSLinkInterval <= 0 {
+			return nil, fmt.Errorf("dnslink interval must be positive, got %v", g.DNSLinkInterval)
+		}
+		if xobj.dnslinkWebhook, err = newDNSLinkWebhook(g.DNSLinkWebhook); err != nil {
+			return nil, err
+		}
+	}
+	if g.StandbyPrimary != "" {
+		if g.StandbyInterval <= 0 {
+			return nil, fmt.Errorf("standby interval must be positive, got %v", g.StandbyInterval)
+		}
+		if xobj.standby, err = newLedgerStandby(ledger, g.StandbyPrimary); err != nil {
+			return nil, err
+		}
+	}
+	if g.MeteringSink != "" {
+		if g.MeteringInterval <= 0 {
+			return nil, fmt.Errorf("metering interval must be positive, got %v", g.MeteringInterval)
+		}
+		if xobj.usageSink, err = xobj.newUsageSink(g.MeteringSink); err != nil {
+			return nil, err
+		}
+		xobj.meter = newUsageMeter(clock.Now().UTC())
+	}
+	// the apis are authenticated with the S3 credentials of the gateway, see gateway-s3x-extension-auth.go
+	xobj.infoAPI.httpMux = runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(forwardedHeaderMatcher),
+		runtime.WithMetadata(xobj.forwardedCredentials),
+	)
+	xobj.infoAPI.grpcServer = grpc.NewServer(
+		grpc.MaxRecvMsgSize(clusterMaxMessageSize),
+		grpc.ChainUnaryInterceptor(xobj.unaryAuth),
+		grpc.ChainStreamInterceptor(xobj.streamAuth),
+	)
+	// serve the grpc-gateway apis, and file data by cid on the same http endpoint
+	mux := http.NewServeMux()
+	mux.Handle("/", xobj.authenticateHTTP(false, xobj.infoAPI.httpMux))
+	mux.HandleFunc(ipfsPathPrefix, xobj.ServeIPFSPath)
+	mux.HandleFunc(sharePathPrefix, xobj.ServeShareLink)
+	mux.HandleFunc(uploadPathPrefix, xobj.ServeUploadGrant)
+	mux.HandleFunc(eventsPathPrefix, xobj.ServeEvents)
+	mux.Handle(ledgerDumpPath, xobj.authenticateHTTP(true, http.HandlerFunc(xobj.ServeLedgerDump)))
 	xobj.infoAPI.httpServer = &http.Server{
 		Addr:    g.HTTPAddr,
-		Handler: xobj.infoAPI.httpMux,
+		Handler: mux,
 	}
 	// register the grpc server
 	RegisterInfoAPIServer(xobj.infoAPI.grpcServer, xobj)
+	RegisterExtensionAPIServer(xobj.infoAPI.grpcServer, xobj)
+	if g.ClusterServe {
+		RegisterLedgerDatastoreAPIServer(xobj.infoAPI.grpcServer, &clusterServer{
+			ds:    ledger.backend.(datastore.Batching),
+			locks: locks,
+			key:   newClusterKey(creds),
+		})
+	}
 	// register the grpc-gateway http endpoint
 	if err := RegisterInfoAPIHandlerFromEndpoint(
 		xobj.ctx,
@@ -246,6 +1287,14 @@ func (g *TEMX) getXObjects(creds auth.Credentials) (*xObjects, error) {
 	); err != nil {
 		return nil, err
 	}
+	if err := RegisterExtensionAPIHandlerFromEndpoint(
+		xobj.ctx,
+		xobj.infoAPI.httpMux,
+		g.GRPCAddr,
+		[]grpc.DialOption{grpc.WithInsecure()},
+	); err != nil {
+		return nil, err
+	}
 	return xobj, nil
 }
 
@@ -261,9 +1310,143 @@ func (g *TEMX) NewGatewayLayer(creds auth.Credentials) (minio.ObjectLayer, error
 	go func() {
 		_ = xobj.infoAPI.httpServer.ListenAndServe()
 	}()
+	if g.DSType != DSTypeRemote {
+		// the host of a cluster maintains the shared ledger
+		g.startMaintenanceLoops(xobj)
+	}
+	if xobj.capacity != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.capacityLoop(g.CapacityInterval)
+		}()
+	}
+	if xobj.standby != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.standbyLoop(g.StandbyInterval)
+		}()
+	}
+	if g.Consistency == consistencyRelaxed && xobj.ledgerStore.backend != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.ledgerSyncLoop(consistencySyncInterval)
+		}()
+	}
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.streamWatchdogLoop(streamWatchdogInterval)
+	}()
+	if xobj.headers != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.headersReloadLoop()
+		}()
+	}
+	if xobj.meter != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.meteringLoop(xobj.usageSink, g.MeteringInterval)
+		}()
+	}
 	return xobj, nil
 }
 
+// startMaintenanceLoops starts the loops that maintain the ledger and the stored data
+func (g *TEMX) startMaintenanceLoops(xobj *xObjects) {
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.purgeTrashLoop(trashPurgeInterval)
+	}()
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.expireObjectsLoop(objectExpiryInterval)
+	}()
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.snapshotLoop(snapshotCheckInterval)
+	}()
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.pruneVersionsLoop(versionPruneInterval)
+	}()
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.pruneIdempotencyLoop(idempotencyPruneInterval)
+	}()
+	if xobj.replicas != nil && g.ReplicationScrubInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.scrubReplicationLoop(g.ReplicationScrubInterval)
+		}()
+	}
+	if xobj.cold != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.transitionLoop(g.TransitionInterval)
+		}()
+	}
+	xobj.wg.Add(1)
+	go func() {
+		defer xobj.wg.Done()
+		xobj.pinQueueLoop(pinQueueInterval)
+	}()
+	if g.PinCheckInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.pinCheckLoop(g.PinCheckInterval)
+		}()
+	}
+	if g.CompactionInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.compactionLoop(g.CompactionInterval)
+		}()
+	}
+	if g.LedgerRootInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.ledgerRootLoop(g.LedgerRootInterval)
+		}()
+	}
+	if g.GCInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.gcLoop(g.GCInterval)
+		}()
+	}
+	if g.DirectoryInterval > 0 {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.directoryLoop(g.DirectoryInterval)
+		}()
+	}
+	if xobj.dnslinkWebhook != nil {
+		xobj.wg.Add(1)
+		go func() {
+			defer xobj.wg.Done()
+			xobj.dnslinkLoop(g.DNSLinkInterval)
+		}()
+	}
+}
+
 // Name returns the name of the TemporalX gateway backend
 func (g *TEMX) Name() string {
 	return temxBackend
@@ -276,18 +1459,13 @@ func (g *TEMX) Production() bool {
 
 // Shutdown is used to shutdown our xObjects service layer
 func (x *xObjects) Shutdown(ctx context.Context) error {
+	x.cancel()
+	x.wg.Wait()
 	x.infoAPI.grpcServer.Stop()
 	x.infoAPI.httpServer.Close()
 	return x.ledgerStore.Close()
 }
 
-// StorageInfo is not relevant to TemporalX backend.
-func (x *xObjects) StorageInfo(ctx context.Context, local bool) (si minio.StorageInfo) {
-	si.Backend.Type = minio.BackendGateway
-	//si.Backend.GatewayOnline = minio.IsBackendOnline(ctx, x.httpClient, "https://docsx.temporal.cloud")
-	return si
-}
-
 // IsCompressionSupported returns whether compression is applicable for this layer.
 func (x *xObjects) IsCompressionSupported() bool {
 	return false
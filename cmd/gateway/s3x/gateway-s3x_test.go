@@ -4,6 +4,8 @@ import (
 	"context"
 	"reflect"
 	"testing"
+
+	minio "github.com/RTradeLtd/s3x/cmd"
 )
 
 func TestS3X_xObjects_GetHash_Badger(t *testing.T) {
@@ -32,7 +34,7 @@ func testS3XxObjectsGetHash(t *testing.T, dsType DSType) {
 			t.Fatal(err)
 		}
 	}()
-	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, "us-east-1"); err != nil {
+	if err := gateway.MakeBucketWithLocation(ctx, testBucket1, minio.BucketOptions{Location: "us-east-1"}); err != nil {
 		t.Fatal(err)
 	}
 	testPutObject(t, gateway)
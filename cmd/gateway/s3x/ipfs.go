@@ -6,8 +6,11 @@ import (
 	"io"
 
 	pb "github.com/RTradeLtd/TxPB/v3/go"
+	proto "github.com/gogo/protobuf/proto"
 	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/go-merkledag"
+	unixfs_pb "github.com/ipfs/go-unixfs/pb"
 	"github.com/pkg/errors"
 )
 
@@ -45,12 +48,15 @@ func ipfsObject(ctx context.Context, dag pb.NodeAPIClient, h string) (*Object, e
 	return obj, nil
 }
 
-// ipfsBucket returns a bucket from IPFS using its hash
+// ipfsBucket returns a bucket from IPFS using its hash, with the objects of its shards
 func ipfsBucket(ctx context.Context, dag pb.NodeAPIClient, h string) (*Bucket, error) {
 	b := &Bucket{}
 	if err := ipfsUnmarshal(ctx, dag, h, b); err != nil {
 		return nil, err
 	}
+	if err := loadShards(ctx, dag, b); err != nil {
+		return nil, err
+	}
 	return b, nil
 }
 
@@ -72,6 +78,9 @@ func ipfsSaveBytes(ctx context.Context, dag pb.NodeAPIClient, data []byte) (stri
 	if err != nil {
 		return "", errors.Wrap(err, "dag client error in ipfsSaveBytes")
 	}
+	if len(resp.GetHashes()) == 0 {
+		return "", errors.New("dag client returned no hash in ipfsSaveBytes")
+	}
 	return resp.GetHashes()[0], nil
 }
 
@@ -95,6 +104,72 @@ func ipfsSaveProtoNode(ctx context.Context, dag pb.NodeAPIClient, node *merkleda
 	return resp.GetHashes()[0], nil
 }
 
+// ipfsSaveFileLinks saves a unixfs file node that concatenates the data of the given hashes in order,
+// sizes must contain the data size of each hash. It returns the hash of the new node and the total size.
+func ipfsSaveFileLinks(ctx context.Context, dag pb.NodeAPIClient, hashes []string, sizes []uint64) (string, uint64, error) {
+	if len(hashes) != len(sizes) {
+		return "", 0, errors.New("number of hashes and sizes must match")
+	}
+	totalSize := uint64(0)
+	links := make([]*ipld.Link, 0, len(hashes))
+	for i, h := range hashes {
+		c, err := cid.Decode(h)
+		if err != nil {
+			return "", 0, fmt.Errorf("link %v hash is not cid, %v", i, err)
+		}
+		totalSize += sizes[i]
+		links = append(links, &ipld.Link{
+			Size: sizes[i],
+			Cid:  c,
+		})
+	}
+	protoNode := &merkledag.ProtoNode{}
+	protoNode.SetCidBuilder(merkledag.V1CidPrefix())
+	protoNode.SetLinks(links)
+	data, err := proto.Marshal(&unixfs_pb.Data{
+		Type:       unixfs_pb.Data_File.Enum(),
+		Filesize:   &totalSize,
+		Blocksizes: sizes,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	protoNode.SetData(data)
+	h, err := ipfsSaveProtoNode(ctx, dag, protoNode)
+	return h, totalSize, err
+}
+
+// ipfsFileSize returns the size of the file data of a raw block or a unixfs file node
+func ipfsFileSize(ctx context.Context, dag pb.NodeAPIClient, h string) (int64, error) {
+	c, err := cid.Decode(h)
+	if err != nil {
+		return 0, err
+	}
+	data, err := ipfsBytes(ctx, dag, h)
+	if err != nil {
+		return 0, err
+	}
+	switch c.Type() {
+	case cid.Raw:
+		return int64(len(data)), nil
+	case cid.DagProtobuf:
+		node, err := merkledag.DecodeProtobuf(data)
+		if err != nil {
+			return 0, err
+		}
+		fsData := &unixfs_pb.Data{}
+		if err := proto.Unmarshal(node.Data(), fsData); err != nil {
+			return 0, err
+		}
+		if fsData.GetType() != unixfs_pb.Data_File && fsData.GetType() != unixfs_pb.Data_Raw {
+			return 0, fmt.Errorf("%v is not a file", h)
+		}
+		return int64(fsData.GetFilesize()), nil
+	default:
+		return 0, fmt.Errorf("%v has unsupported codec %v", h, c.Type())
+	}
+}
+
 const chunkSize = 4*1024*1024 - 1024 //1KB less than 4MB for a good safety buffer
 
 func ipfsFileUpload(ctx context.Context, fileClient pb.FileAPIClient, r io.Reader) (string, int, error) {
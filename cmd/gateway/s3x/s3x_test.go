@@ -12,9 +12,10 @@ import (
 	"github.com/RTradeLtd/s3x/pkg/auth"
 )
 
-func init() {
-	isTest = true
-}
+// testClock returns the zero time, so buckets and objects have consistent hashes for testing
+type testClock struct{}
+
+func (testClock) Now() time.Time { return time.Time{} }
 
 type testGateway struct {
 	*xObjects
@@ -53,7 +54,7 @@ var (
 
 // newTestGateway returns a testGateway that implements minio.ObjectLayer.
 // testGateway also removes all data save on disk when shutdown
-func newTestGateway(t *testing.T, dsType DSType) *testGateway {
+func newTestGateway(t testing.TB, dsType DSType) *testGateway {
 	pathOnce.Do(func() {
 		testPath, testPathErr = ioutil.TempDir("", "s3x-test")
 	})
@@ -77,6 +78,7 @@ func newTestGateway(t *testing.T, dsType DSType) *testGateway {
 		CrdtTopic: testPath + time.Now().String(), //make sure the topic is unique
 		XAddr:     xaddr,
 		Insecure:  true,
+		Clock:     testClock{},
 	}
 	g, err := temx.NewGatewayLayer(auth.Credentials{})
 	if err != nil {
@@ -19,6 +19,7 @@ package cmd
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -371,14 +372,14 @@ func (h timeValidityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// All our internal APIs are sensitive towards Date
 			// header, for all requests where Date header is not
 			// present we will reject such clients.
-			writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(errCode), r.URL, guessIsBrowserReq(r))
+			writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(globalAuthFailureStats.record(errCode)), r.URL, guessIsBrowserReq(r))
 			return
 		}
-		// Verify if the request date header is shifted by less than globalMaxSkewTime parameter in the past
+		// Verify if the request date header is shifted by less than maxSkewTime() in the past
 		// or in the future, reject request otherwise.
-		curTime := UTCNow()
-		if curTime.Sub(amzDate) > globalMaxSkewTime || amzDate.Sub(curTime) > globalMaxSkewTime {
-			writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(ErrRequestTimeTooSkewed), r.URL, guessIsBrowserReq(r))
+		curTime, skew := UTCNow(), maxSkewTime()
+		if curTime.Sub(amzDate) > skew || amzDate.Sub(curTime) > skew {
+			writeErrorResponse(context.Background(), w, errorCodes.ToAPIErr(globalAuthFailureStats.record(ErrRequestTimeTooSkewed)), r.URL, guessIsBrowserReq(r))
 			return
 		}
 	}
@@ -729,7 +730,7 @@ func setBucketForwardingHandler(h http.Handler) http.Handler {
 	return bucketForwardingHandler{fwd, h}
 }
 
-// customHeaderHandler sets x-amz-request-id header.
+// customHeaderHandler sets x-amz-request-id and x-amz-id-2 headers.
 // Previously, this value was set right before a response was sent to
 // the client. So, logger and Error response XML were not using this
 // value. This is set here so that this header can be logged as
@@ -743,8 +744,10 @@ func addCustomHeaders(h http.Handler) http.Handler {
 }
 
 func (s customHeaderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Set custom headers such as x-amz-request-id for each request.
-	w.Header().Set(xhttp.AmzRequestID, mustGetRequestID(UTCNow()))
+	// Set custom headers such as x-amz-request-id and x-amz-id-2 for each request.
+	requestID := mustGetRequestID(UTCNow())
+	w.Header().Set(xhttp.AmzRequestID, requestID)
+	w.Header().Set(xhttp.AmzRequestHostID, getRequestHostID(requestID))
 	s.handler.ServeHTTP(logger.NewResponseWriter(w), r)
 }
 
@@ -797,3 +800,86 @@ func (h sseTLSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	h.handler.ServeHTTP(w, r)
 }
+
+func setBucketHeadersHandler(h http.Handler) http.Handler { return bucketHeadersHandler{h} }
+
+// bucketHeadersHandler sets the response headers configured for buckets, and denies the requests to
+// buckets that do not send the request headers required by the configuration of the object layer.
+type bucketHeadersHandler struct{ handler http.Handler }
+
+func (h bucketHeadersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	configurer, ok := unwrapObjectLayer(newObjectLayerFn()).(BucketHeadersConfigurer)
+	if !ok || guessIsRPCReq(r) || guessIsBrowserReq(r) || guessIsHealthCheckReq(r) || guessIsMetricsReq(r) || isAdminReq(r) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	bucket, object := request2BucketObjectName(r)
+	if bucket == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	// headers set by the request handlers, such as the Cache-Control of objects, replace the configured headers
+	if _, ok := configurer.BucketHeaders().Apply(bucket, object, r.Header, w.Header()); !ok {
+		if r.Method == http.MethodHead {
+			writeErrorResponseHeadersOnly(w, errorCodes.ToAPIErr(ErrMissingRequiredHeader))
+		} else {
+			writeErrorResponse(r.Context(), w, errorCodes.ToAPIErr(ErrMissingRequiredHeader), r.URL, guessIsBrowserReq(r))
+		}
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// setBucketRegionHandler redirects the requests to buckets of other regions to the gateways of their regions.
+func setBucketRegionHandler(h http.Handler) http.Handler { return bucketRegionHandler{h} }
+
+// bucketRegionHandler sets the region of buckets on the responses to their requests, and redirects the requests
+// to buckets whose region is served by another gateway with PermanentRedirect, like AWS does for requests sent
+// to the endpoint of another region. GetBucketLocation is served for the buckets of all regions.
+type bucketRegionHandler struct{ handler http.Handler }
+
+func (h bucketRegionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router, ok := unwrapObjectLayer(newObjectLayerFn()).(BucketRegionRouter)
+	if !ok || guessIsRPCReq(r) || guessIsBrowserReq(r) || guessIsHealthCheckReq(r) || guessIsMetricsReq(r) || isAdminReq(r) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	bucket, _ := request2BucketObjectName(r)
+	if bucket == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	// errors are returned by the handlers of the request
+	region, endpoint, err := router.BucketRegion(r.Context(), bucket)
+	if err != nil || region == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set(xhttp.AmzBucketRegion, region)
+	if _, location := r.URL.Query()["location"]; endpoint == "" || location && r.Method == http.MethodGet {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	// virtual host style requests are redirected in path style, the domains of the gateways can differ
+	resource, err := getResource(r.URL.Path, r.Host, globalDomainNames)
+	if err != nil {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	u.Path, u.RawQuery = resource, r.URL.RawQuery
+	w.Header().Set(xhttp.Location, u.String())
+	apiErr := errorCodes.ToAPIErr(ErrPermanentRedirect)
+	if r.Method == http.MethodHead {
+		writeErrorResponseHeadersOnly(w, apiErr)
+		return
+	}
+	errorResponse := getAPIErrorResponse(r.Context(), apiErr, r.URL.Path,
+		w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID))
+	errorResponse.BucketName, errorResponse.Region, errorResponse.Endpoint = bucket, region, u.Host
+	writeResponse(w, apiErr.HTTPStatusCode, encodeResponse(errorResponse), mimeXML)
+}
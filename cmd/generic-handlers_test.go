@@ -17,13 +17,19 @@
 package cmd
 
 import (
+	"context"
+	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/RTradeLtd/s3x/cmd/crypto"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	bucketheaders "github.com/RTradeLtd/s3x/pkg/bucket/headers"
 )
 
 // Tests getRedirectLocation function for all its criteria.
@@ -245,3 +251,169 @@ func TestSSETLSHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestCustomHeaderHandler(t *testing.T) {
+	var reqInfo *logger.ReqInfo
+	var errHandler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		ctx := newContext(r, w, "TestAPI")
+		reqInfo = logger.GetReqInfo(ctx)
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrAccessDenied), r.URL, false)
+	}
+	h := addCustomHeaders(errHandler)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+		h.ServeHTTP(w, r)
+
+		requestID, hostID := w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID)
+		if requestID == "" || hostID == "" {
+			t.Fatalf("Test %d: Expected request id and host id headers, but got %q and %q", i, requestID, hostID)
+		}
+		if _, ok := seen[requestID]; ok {
+			t.Fatalf("Test %d: Request id %s was returned twice", i, requestID)
+		}
+		seen[requestID] = struct{}{}
+		if reqInfo.RequestID != requestID || reqInfo.RequestHostID != hostID {
+			t.Fatalf("Test %d: Expected request info with %s and %s, but got %s and %s",
+				i, requestID, hostID, reqInfo.RequestID, reqInfo.RequestHostID)
+		}
+		var errResp APIErrorResponse
+		if err := xml.NewDecoder(w.Body).Decode(&errResp); err != nil {
+			t.Fatal(err)
+		}
+		if errResp.RequestID != requestID || errResp.HostID != hostID {
+			t.Fatalf("Test %d: Expected error response with %s and %s, but got %s and %s",
+				i, requestID, hostID, errResp.RequestID, errResp.HostID)
+		}
+	}
+}
+
+// headersObjects configures the headers of requests to buckets
+type headersObjects struct {
+	ObjectLayer
+	config *bucketheaders.Config
+}
+
+func (o headersObjects) BucketHeaders() *bucketheaders.Config {
+	return o.config
+}
+
+func TestBucketHeadersHandler(t *testing.T) {
+	config, err := bucketheaders.ParseConfig(strings.NewReader(`{"rules": [
+		{"bucket": "*", "responseHeaders": {"X-Frame-Options": "DENY"}},
+		{"bucket": "bucket", "prefix": "private/", "requiredRequestHeaders": [{"name": "X-Tenant"}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setObjectLayer := func(o ObjectLayer) {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = o
+		globalObjLayerMutex.Unlock()
+	}
+	setObjectLayer(headersObjects{config: config})
+	defer setObjectLayer(nil)
+
+	var okHandler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	h := setBucketHeadersHandler(okHandler)
+	testCases := []struct {
+		method, path string
+		header       http.Header
+		code         int
+		frameOptions string
+	}{
+		{http.MethodGet, "/bucket/object", nil, http.StatusOK, "DENY"},
+		{http.MethodGet, "/bucket/private/object", nil, http.StatusForbidden, "DENY"},
+		{http.MethodHead, "/bucket/private/object", nil, http.StatusForbidden, "DENY"},
+		{http.MethodGet, "/bucket/private/object", http.Header{"X-Tenant": {"a"}}, http.StatusOK, "DENY"},
+		// requests that are not for a bucket are not configured
+		{http.MethodGet, "/", nil, http.StatusOK, ""},
+		{http.MethodGet, healthCheckPathPrefix + healthCheckLivenessPath, nil, http.StatusOK, ""},
+	}
+	for i, testCase := range testCases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(testCase.method, testCase.path, nil)
+		for name, values := range testCase.header {
+			r.Header[name] = values
+		}
+		h.ServeHTTP(w, r)
+		if w.Code != testCase.code || w.Header().Get("X-Frame-Options") != testCase.frameOptions {
+			t.Errorf("Test %d: expected %d with %q, got %d with %q", i+1, testCase.code, testCase.frameOptions,
+				w.Code, w.Header().Get("X-Frame-Options"))
+		}
+	}
+}
+
+// regionObjects labels buckets with regions, and serves the buckets of region
+type regionObjects struct {
+	ObjectLayer
+	region    string
+	labels    map[string]string
+	endpoints map[string]string
+}
+
+func (o regionObjects) BucketRegion(ctx context.Context, bucket string) (string, string, error) {
+	region := o.labels[bucket]
+	if region == o.region {
+		return region, "", nil
+	}
+	return region, o.endpoints[region], nil
+}
+
+func TestBucketRegionHandler(t *testing.T) {
+	setObjectLayer := func(o ObjectLayer) {
+		globalObjLayerMutex.Lock()
+		globalObjectAPI = o
+		globalObjLayerMutex.Unlock()
+	}
+	// the optional interfaces of gateways are asserted through their locker
+	setObjectLayer(NewGatewayLayerWithLocker(regionObjects{
+		region:    "us-east-1",
+		labels:    map[string]string{"local": "us-east-1", "remote": "eu-west-1", "unknown": "ap-east-1"},
+		endpoints: map[string]string{"eu-west-1": "https://eu.example.com"},
+	}))
+	defer setObjectLayer(nil)
+
+	var okHandler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	h := setBucketRegionHandler(okHandler)
+	testCases := []struct {
+		method, path string
+		code         int
+		region       string
+		location     string
+	}{
+		{http.MethodGet, "/local/object", http.StatusOK, "us-east-1", ""},
+		{http.MethodGet, "/remote/object?versionId=1", http.StatusMovedPermanently, "eu-west-1", "https://eu.example.com/remote/object?versionId=1"},
+		{http.MethodHead, "/remote", http.StatusMovedPermanently, "eu-west-1", "https://eu.example.com/remote"},
+		// the location of buckets is served for all regions
+		{http.MethodGet, "/remote?location", http.StatusOK, "eu-west-1", ""},
+		// buckets of regions without a gateway are served here
+		{http.MethodGet, "/unknown/object", http.StatusOK, "ap-east-1", ""},
+		{http.MethodGet, "/missing/object", http.StatusOK, "", ""},
+		{http.MethodGet, "/", http.StatusOK, "", ""},
+	}
+	for i, testCase := range testCases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(testCase.method, testCase.path, nil)
+		h.ServeHTTP(w, r)
+		if w.Code != testCase.code || w.Header().Get(xhttp.AmzBucketRegion) != testCase.region || w.Header().Get(xhttp.Location) != testCase.location {
+			t.Errorf("Test %d: expected %d with region %q at %q, got %d with region %q at %q", i+1, testCase.code,
+				testCase.region, testCase.location, w.Code, w.Header().Get(xhttp.AmzBucketRegion), w.Header().Get(xhttp.Location))
+		}
+		if testCase.method == http.MethodGet && testCase.code == http.StatusMovedPermanently {
+			var resp APIErrorResponse
+			if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != "PermanentRedirect" || resp.Endpoint != "eu.example.com" || resp.Region != "eu-west-1" {
+				t.Errorf("Test %d: unexpected error response %+v", i+1, resp)
+			}
+		}
+	}
+}
@@ -188,6 +188,12 @@ var (
 	// Global HTTP request statisitics
 	globalHTTPStats = newHTTPStats()
 
+	// Global request metrics of the bucket metrics configurations
+	globalBucketMetricsSys = NewBucketMetricsSys()
+
+	// Global counters of requests that failed authentication
+	globalAuthFailureStats = newAuthFailureStats()
+
 	// Time when the server is started
 	globalBootTime = UTCNow()
 
@@ -77,6 +77,8 @@ var supportedHeaders = []string{
 	xhttp.AmzStorageClass,
 	xhttp.AmzObjectTagging,
 	"expires",
+	xhttp.S3xPin,
+	xhttp.S3xIdempotencyKey,
 	// Add more supported headers here.
 }
 
@@ -368,8 +370,10 @@ func collectAPIStats(api string, f http.HandlerFunc) http.HandlerFunc {
 
 		apiStatsWriter := &recordAPIStats{ResponseWriter: w, TTFB: tBefore, isS3Request: isS3Request}
 
+		apiBody := &recordAPIBody{ReadCloser: r.Body}
 		if isS3Request {
 			globalHTTPStats.currentS3Requests.Inc(api)
+			r.Body = apiBody
 		}
 
 		// Execute the request
@@ -390,6 +394,15 @@ func collectAPIStats(api string, f http.HandlerFunc) http.HandlerFunc {
 
 		// Update http statistics
 		globalHTTPStats.updateStats(api, r, apiStatsWriter, durationSecs)
+
+		// Update the request metrics of the bucket metrics configurations
+		if isS3Request {
+			statusCode := apiStatsWriter.respStatusCode
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+			globalBucketMetricsSys.Record(api, r, statusCode, apiBody.bytesRead, apiStatsWriter.bytesWritten)
+		}
 	}
 }
 
@@ -410,14 +423,23 @@ func getResource(path string, host string, domains []string) (string, error) {
 			return "", err
 		}
 	}
+	// A request to a domain itself is path style, else the bucket
+	// is the name below the longest domain the host ends with.
+	var bucket string
 	for _, domain := range domains {
-		if !strings.HasSuffix(host, "."+domain) {
-			continue
+		if host == domain {
+			return path, nil
 		}
-		bucket := strings.TrimSuffix(host, "."+domain)
-		return SlashSeparator + pathJoin(bucket, path), nil
+		if strings.HasSuffix(host, "."+domain) {
+			if name := strings.TrimSuffix(host, "."+domain); bucket == "" || len(name) < len(bucket) {
+				bucket = name
+			}
+		}
+	}
+	if bucket == "" {
+		return path, nil
 	}
-	return path, nil
+	return SlashSeparator + pathJoin(bucket, path), nil
 }
 
 var regexVersion = regexp.MustCompile(`(\w\d+)`)
@@ -221,6 +221,9 @@ func TestGetResource(t *testing.T) {
 		{"/a/b/c", "test.mydomain.com", []string{"mydomain.com"}, "/test/a/b/c"},
 		{"/a/b/c", "test.mydomain.com", []string{"notmydomain.com"}, "/a/b/c"},
 		{"/a/b/c", "test.mydomain.com", nil, "/a/b/c"},
+		{"/a/b/c", "test.s3.mydomain.com", []string{"mydomain.com", "s3.mydomain.com"}, "/test/a/b/c"},
+		{"/a/b/c", "s3.mydomain.com:9000", []string{"mydomain.com", "s3.mydomain.com"}, "/a/b/c"},
+		{"/a/b/c", "test.other.com", []string{"mydomain.com", "other.com"}, "/test/a/b/c"},
 	}
 	for i, test := range testCases {
 		gotResource, err := getResource(test.p, test.host, test.domains)
@@ -28,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/cmd/logger"
 	"github.com/RTradeLtd/s3x/pkg/handlers"
 	trace "github.com/RTradeLtd/s3x/pkg/trace"
@@ -102,6 +103,7 @@ func Trace(f http.HandlerFunc, logBody bool, w http.ResponseWriter, r *http.Requ
 	// Setup a http request body recorder
 	reqHeaders := r.Header.Clone()
 	reqHeaders.Set("Host", r.Host)
+	reqHeaders.Del(xhttp.S3xBreakGlass)
 	if len(r.TransferEncoding) == 0 {
 		reqHeaders.Set("Content-Length", strconv.Itoa(int(r.ContentLength)))
 	}
@@ -146,6 +148,8 @@ func Trace(f http.HandlerFunc, logBody bool, w http.ResponseWriter, r *http.Requ
 		rs.StatusCode = http.StatusOK
 	}
 
+	t.RequestID = rs.Headers.Get(xhttp.AmzRequestID)
+	t.RequestHostID = rs.Headers.Get(xhttp.AmzRequestHostID)
 	t.ReqInfo = rq
 	t.RespInfo = rs
 
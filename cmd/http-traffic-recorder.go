@@ -68,6 +68,7 @@ type recordAPIStats struct {
 	firstByteRead  bool
 	respStatusCode int
 	isS3Request    bool
+	bytesWritten   int64
 }
 
 // Calls the underlying WriteHeader.
@@ -82,10 +83,25 @@ func (r *recordAPIStats) Write(p []byte) (n int, err error) {
 		r.TTFB = UTCNow()
 		r.firstByteRead = true
 	}
-	return r.ResponseWriter.Write(p)
+	n, err = r.ResponseWriter.Write(p)
+	r.bytesWritten += int64(n)
+	return n, err
 }
 
 // Calls the underlying Flush.
 func (r *recordAPIStats) Flush() {
 	r.ResponseWriter.(http.Flusher).Flush()
 }
+
+// Records the bytes read from the request body of an API call.
+type recordAPIBody struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+// Records the bytes read.
+func (r *recordAPIBody) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.bytesRead += int64(n)
+	return n, err
+}
@@ -52,6 +52,7 @@ const (
 	IfUnmodifiedSince = "If-Unmodified-Since"
 	IfMatch           = "If-Match"
 	IfNoneMatch       = "If-None-Match"
+	IfRange           = "If-Range"
 
 	// S3 storage class
 	AmzStorageClass = "x-amz-storage-class"
@@ -80,6 +81,14 @@ const (
 	// Multipart parts count
 	AmzMpPartsCount = "x-amz-mp-parts-count"
 
+	// S3 object checksums
+	AmzChecksumPrefix    = "x-amz-checksum-"
+	AmzChecksumAlgorithm = "X-Amz-Checksum-Algorithm"
+	AmzChecksumType      = "X-Amz-Checksum-Type"
+	AmzObjectAttributes  = "X-Amz-Object-Attributes"
+	AmzMaxParts          = "X-Amz-Max-Parts"
+	AmzPartNumberMarker  = "X-Amz-Part-Number-Marker"
+
 	// Dummy putBucketACL
 	AmzACL = "x-amz-acl"
 
@@ -101,9 +110,31 @@ const (
 	// Response request id.
 	AmzRequestID = "x-amz-request-id"
 
+	// Response extended request id, identifies the node that served the request.
+	AmzRequestHostID = "x-amz-id-2"
+
+	// W3C trace context of the request, https://www.w3.org/TR/trace-context/
+	TraceParent = "traceparent"
+
 	// Deployment id.
 	MinioDeploymentID = "x-minio-deployment-id"
 
 	// Server-Status
 	MinIOServerStatus = "x-minio-server-status"
+
+	// S3xPin selects how the s3x gateway pins the data of an uploaded object
+	S3xPin = "x-s3x-pin"
+
+	// S3xIdempotencyKey identifies the retries of an upload to the s3x gateway, so they are only stored once
+	S3xIdempotencyKey = "x-s3x-idempotency-key"
+
+	// S3xBreakGlass carries the emergency key that exempts a request of the root credential from deny-by-default
+	S3xBreakGlass = "x-s3x-break-glass"
+
+	// S3xDebugErrors requests the details of the error of a failed request in the S3xErrorDetails response header
+	S3xDebugErrors  = "x-s3x-debug-errors"
+	S3xErrorDetails = "x-s3x-error-details"
+
+	// S3xBucketHash is the CID of the current root of a bucket stored on IPFS, returned by HeadBucket
+	S3xBucketHash = "x-s3x-bucket-hash"
 )
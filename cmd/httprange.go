@@ -25,6 +25,8 @@ import (
 
 const (
 	byteRangePrefix = "bytes="
+	// Maximum number of ranges of a multi-range request.
+	maxByteRanges = 64
 )
 
 // HTTPRangeSpec represents a range specification as supported by S3 GET
@@ -51,6 +53,10 @@ func (h *HTTPRangeSpec) GetLength(resourceSize int64) (rangeLength int64, err er
 	case h == nil:
 		rangeLength = resourceSize
 
+	case h.IsSuffixLength && resourceSize == 0:
+		// No suffix of an empty resource can be satisfied.
+		return 0, errInvalidRange
+
 	case h.IsSuffixLength:
 		specifiedLen := -h.Start
 		rangeLength = specifiedLen
@@ -162,6 +168,26 @@ func parseRequestRangeSpec(rangeString string) (hrange *HTTPRangeSpec, err error
 	}
 }
 
+// Parse a HTTP range header value with one or more comma separated
+// ranges, eg. "bytes=0-9,20-", into a HTTPRangeSpec for each range.
+func parseRequestRangeSpecs(rangeString string) (hranges []*HTTPRangeSpec, err error) {
+	if !strings.HasPrefix(rangeString, byteRangePrefix) {
+		return nil, fmt.Errorf("'%s' does not start with '%s'", rangeString, byteRangePrefix)
+	}
+	rangeStrings := strings.Split(strings.TrimPrefix(rangeString, byteRangePrefix), ",")
+	if len(rangeStrings) > maxByteRanges {
+		return nil, fmt.Errorf("'%s' has more than %d ranges", rangeString, maxByteRanges)
+	}
+	for _, s := range rangeStrings {
+		hrange, err := parseRequestRangeSpec(byteRangePrefix + strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		hranges = append(hranges, hrange)
+	}
+	return hranges, nil
+}
+
 // String returns stringified representation of range for a particular resource size.
 func (h *HTTPRangeSpec) String(resourceSize int64) string {
 	if h == nil {
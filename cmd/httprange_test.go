@@ -17,6 +17,7 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -100,3 +101,44 @@ func TestHTTPRequestRangeSpec(t *testing.T) {
 		t.Errorf("Case %d: Expected errInvalidRange but: %v %v %d %d %v", i, rs, err1, o, l, err2)
 	}
 }
+
+func TestHTTPRequestRangeSpecEmptyResource(t *testing.T) {
+	rs, err := parseRequestRangeSpec("bytes=-5")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, _, err = rs.GetOffsetLength(0); err != errInvalidRange {
+		t.Errorf("Expected errInvalidRange for a suffix of an empty resource but got %v", err)
+	}
+}
+
+func TestHTTPRequestRangeSpecs(t *testing.T) {
+	resourceSize := int64(10)
+	rangeSpecs, err := parseRequestRangeSpecs("bytes=0-1, 5-,-2")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expected := []struct{ offset, length int64 }{{0, 2}, {5, 5}, {8, 2}}
+	if len(rangeSpecs) != len(expected) {
+		t.Fatalf("Expected %d ranges but got %d", len(expected), len(rangeSpecs))
+	}
+	for i, rs := range rangeSpecs {
+		o, l, err := rs.GetOffsetLength(resourceSize)
+		if err != nil {
+			t.Errorf("Case %d: unexpected err: %v", i, err)
+		}
+		if o != expected[i].offset || l != expected[i].length {
+			t.Errorf("Case %d: got bad offset/length: %d,%d expected: %d,%d",
+				i, o, l, expected[i].offset, expected[i].length)
+		}
+	}
+
+	if _, err = parseRequestRangeSpecs("bytes=0-1,5-3"); err != errInvalidRange {
+		t.Errorf("Expected errInvalidRange but got %v", err)
+	}
+	for i, urs := range []string{"bytes=0-1,", "bytes=0-1,aa", "0-1,2-3", "bytes=" + strings.Repeat("0-1,", maxByteRanges) + "0-1"} {
+		if rs, err := parseRequestRangeSpecs(urs); err == nil || err == errInvalidRange {
+			t.Errorf("Case %d: Expected a parse error but got %v %v", i, rs, err)
+		}
+	}
+}
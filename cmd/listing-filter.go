@@ -0,0 +1,109 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The query parameters of the s3x extension that filters object listings.
+const (
+	listingFilterMinSize       = "x-s3x-min-size"
+	listingFilterMaxSize       = "x-s3x-max-size"
+	listingFilterModifiedAfter = "x-s3x-modified-after"
+	listingFilterTag           = "x-s3x-tag"
+)
+
+// ListingFilter - the conditions objects of a filtered listing match,
+// the object layer only lists the objects that match all of them.
+type ListingFilter struct {
+	// MinSize is the minimum size of the listed objects
+	MinSize int64
+	// MaxSize is the maximum size of the listed objects, unbounded if 0
+	MaxSize int64
+	// ModifiedAfter only lists the objects modified after it, if it is set
+	ModifiedAfter time.Time
+	// Tags are the tags the listed objects have, with the same values
+	Tags map[string]string
+}
+
+type listingFilterKey struct{}
+
+// WithListingFilter - returns a context whose object listings are filtered by f.
+func WithListingFilter(ctx context.Context, f *ListingFilter) context.Context {
+	return context.WithValue(ctx, listingFilterKey{}, f)
+}
+
+// GetListingFilter - returns the filter of the listing of the request of ctx,
+// nil if the listing is not filtered.
+func GetListingFilter(ctx context.Context) *ListingFilter {
+	f, _ := ctx.Value(listingFilterKey{}).(*ListingFilter)
+	return f
+}
+
+// getListingFilter - returns the listing filter of the query parameters of a
+// listing, nil if it has none. Tags are given as key=value, and can be
+// repeated.
+func getListingFilter(values url.Values) (*ListingFilter, APIErrorCode) {
+	var f ListingFilter
+	filtered := false
+	for _, p := range []struct {
+		name string
+		size *int64
+	}{{listingFilterMinSize, &f.MinSize}, {listingFilterMaxSize, &f.MaxSize}} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size < 0 {
+			return nil, ErrInvalidListingFilter
+		}
+		*p.size = size
+		filtered = true
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return nil, ErrInvalidListingFilter
+	}
+	if v := values.Get(listingFilterModifiedAfter); v != "" {
+		modified, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, ErrInvalidListingFilter
+		}
+		f.ModifiedAfter = modified
+		filtered = true
+	}
+	for _, tag := range values[listingFilterTag] {
+		i := strings.Index(tag, "=")
+		if i <= 0 {
+			return nil, ErrInvalidListingFilter
+		}
+		if f.Tags == nil {
+			f.Tags = make(map[string]string)
+		}
+		f.Tags[tag[:i]] = tag[i+1:]
+		filtered = true
+	}
+	if !filtered {
+		return nil, ErrNone
+	}
+	return &f, ErrNone
+}
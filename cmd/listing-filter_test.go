@@ -0,0 +1,68 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetListingFilter(t *testing.T) {
+	modified := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		query      string
+		wantFilter *ListingFilter
+		wantErr    APIErrorCode
+	}{
+		{"prefix=a", nil, ErrNone},
+		{"x-s3x-min-size=10&x-s3x-max-size=20", &ListingFilter{MinSize: 10, MaxSize: 20}, ErrNone},
+		{"x-s3x-min-size=10", &ListingFilter{MinSize: 10}, ErrNone},
+		{"x-s3x-modified-after=2020-03-01T12:00:00Z", &ListingFilter{ModifiedAfter: modified}, ErrNone},
+		{"x-s3x-tag=team%3Dstorage&x-s3x-tag=tier%3D", &ListingFilter{Tags: map[string]string{"team": "storage", "tier": ""}}, ErrNone},
+		{"x-s3x-min-size=-1", nil, ErrInvalidListingFilter},
+		{"x-s3x-max-size=big", nil, ErrInvalidListingFilter},
+		{"x-s3x-min-size=20&x-s3x-max-size=10", nil, ErrInvalidListingFilter},
+		{"x-s3x-modified-after=yesterday", nil, ErrInvalidListingFilter},
+		{"x-s3x-tag=team", nil, ErrInvalidListingFilter},
+		{"x-s3x-tag=%3Dstorage", nil, ErrInvalidListingFilter},
+	}
+	for i, testCase := range testCases {
+		values, err := url.ParseQuery(testCase.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter, errCode := getListingFilter(values)
+		if errCode != testCase.wantErr {
+			t.Errorf("Test %d: expected error code %v, got %v", i+1, testCase.wantErr, errCode)
+		}
+		if !reflect.DeepEqual(filter, testCase.wantFilter) {
+			t.Errorf("Test %d: expected filter %+v, got %+v", i+1, testCase.wantFilter, filter)
+		}
+	}
+
+	ctx := context.Background()
+	if GetListingFilter(ctx) != nil {
+		t.Fatal("expected no listing filter")
+	}
+	filter := &ListingFilter{MinSize: 1}
+	if got := GetListingFilter(WithListingFilter(ctx, filter)); got != filter {
+		t.Fatalf("expected listing filter %+v, got %+v", filter, got)
+	}
+}
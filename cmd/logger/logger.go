@@ -345,14 +345,15 @@ func logIf(ctx context.Context, err error, errKind ...interface{}) {
 		req.DeploymentID = globalDeploymentID
 	}
 	entry := log.Entry{
-		DeploymentID: req.DeploymentID,
-		Level:        ErrorLvl.String(),
-		LogKind:      logKind,
-		RemoteHost:   req.RemoteHost,
-		Host:         req.Host,
-		RequestID:    req.RequestID,
-		UserAgent:    req.UserAgent,
-		Time:         time.Now().UTC().Format(time.RFC3339Nano),
+		DeploymentID:  req.DeploymentID,
+		Level:         ErrorLvl.String(),
+		LogKind:       logKind,
+		RemoteHost:    req.RemoteHost,
+		Host:          req.Host,
+		RequestID:     req.RequestID,
+		RequestHostID: req.RequestHostID,
+		UserAgent:     req.UserAgent,
+		Time:          time.Now().UTC().Format(time.RFC3339Nano),
 		API: &log.API{
 			Name: API,
 			Args: &log.Args{
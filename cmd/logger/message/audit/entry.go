@@ -42,13 +42,14 @@ type Entry struct {
 		TimeToFirstByte string `json:"timeToFirstByte,omitempty"`
 		TimeToResponse  string `json:"timeToResponse,omitempty"`
 	} `json:"api"`
-	RemoteHost string                 `json:"remotehost,omitempty"`
-	RequestID  string                 `json:"requestID,omitempty"`
-	UserAgent  string                 `json:"userAgent,omitempty"`
-	ReqClaims  map[string]interface{} `json:"requestClaims,omitempty"`
-	ReqQuery   map[string]string      `json:"requestQuery,omitempty"`
-	ReqHeader  map[string]string      `json:"requestHeader,omitempty"`
-	RespHeader map[string]string      `json:"responseHeader,omitempty"`
+	RemoteHost    string                 `json:"remotehost,omitempty"`
+	RequestID     string                 `json:"requestID,omitempty"`
+	RequestHostID string                 `json:"requestHostID,omitempty"`
+	UserAgent     string                 `json:"userAgent,omitempty"`
+	ReqClaims     map[string]interface{} `json:"requestClaims,omitempty"`
+	ReqQuery      map[string]string      `json:"requestQuery,omitempty"`
+	ReqHeader     map[string]string      `json:"requestHeader,omitempty"`
+	RespHeader    map[string]string      `json:"responseHeader,omitempty"`
 }
 
 // ToEntry - constructs an audit entry object.
@@ -61,6 +62,7 @@ func ToEntry(w http.ResponseWriter, r *http.Request, reqClaims map[string]interf
 	for k, v := range r.Header {
 		reqHeader[k] = strings.Join(v, ",")
 	}
+	delete(reqHeader, http.CanonicalHeaderKey(xhttp.S3xBreakGlass))
 	respHeader := make(map[string]string)
 	for k, v := range w.Header() {
 		respHeader[k] = strings.Join(v, ",")
@@ -68,16 +70,17 @@ func ToEntry(w http.ResponseWriter, r *http.Request, reqClaims map[string]interf
 	respHeader[xhttp.ETag] = strings.Trim(respHeader[xhttp.ETag], `"`)
 
 	entry := Entry{
-		Version:      Version,
-		DeploymentID: deploymentID,
-		RemoteHost:   handlers.GetSourceIP(r),
-		RequestID:    w.Header().Get(xhttp.AmzRequestID),
-		UserAgent:    r.UserAgent(),
-		Time:         time.Now().UTC().Format(time.RFC3339Nano),
-		ReqQuery:     reqQuery,
-		ReqHeader:    reqHeader,
-		ReqClaims:    reqClaims,
-		RespHeader:   respHeader,
+		Version:       Version,
+		DeploymentID:  deploymentID,
+		RemoteHost:    handlers.GetSourceIP(r),
+		RequestID:     w.Header().Get(xhttp.AmzRequestID),
+		RequestHostID: w.Header().Get(xhttp.AmzRequestHostID),
+		UserAgent:     r.UserAgent(),
+		Time:          time.Now().UTC().Format(time.RFC3339Nano),
+		ReqQuery:      reqQuery,
+		ReqHeader:     reqHeader,
+		ReqClaims:     reqClaims,
+		RespHeader:    respHeader,
 	}
 
 	return entry
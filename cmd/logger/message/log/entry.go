@@ -38,15 +38,16 @@ type API struct {
 
 // Entry - defines fields and values of each log entry.
 type Entry struct {
-	DeploymentID string `json:"deploymentid,omitempty"`
-	Level        string `json:"level"`
-	LogKind      string `json:"errKind"`
-	Time         string `json:"time"`
-	API          *API   `json:"api,omitempty"`
-	RemoteHost   string `json:"remotehost,omitempty"`
-	Host         string `json:"host,omitempty"`
-	RequestID    string `json:"requestID,omitempty"`
-	UserAgent    string `json:"userAgent,omitempty"`
-	Message      string `json:"message,omitempty"`
-	Trace        *Trace `json:"error,omitempty"`
+	DeploymentID  string `json:"deploymentid,omitempty"`
+	Level         string `json:"level"`
+	LogKind       string `json:"errKind"`
+	Time          string `json:"time"`
+	API           *API   `json:"api,omitempty"`
+	RemoteHost    string `json:"remotehost,omitempty"`
+	Host          string `json:"host,omitempty"`
+	RequestID     string `json:"requestID,omitempty"`
+	RequestHostID string `json:"requestHostID,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	Message       string `json:"message,omitempty"`
+	Trace         *Trace `json:"error,omitempty"`
 }
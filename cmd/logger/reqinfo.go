@@ -35,15 +35,17 @@ type KeyVal struct {
 
 // ReqInfo stores the request info.
 type ReqInfo struct {
-	RemoteHost   string   // Client Host/IP
-	Host         string   // Node Host/IP
-	UserAgent    string   // User Agent
-	DeploymentID string   // x-minio-deployment-id
-	RequestID    string   // x-amz-request-id
-	API          string   // API name - GetObject PutObject NewMultipartUpload etc.
-	BucketName   string   // Bucket name
-	ObjectName   string   // Object name
-	tags         []KeyVal // Any additional info not accommodated by above fields
+	RemoteHost    string   // Client Host/IP
+	Host          string   // Node Host/IP
+	UserAgent     string   // User Agent
+	DeploymentID  string   // x-minio-deployment-id
+	RequestID     string   // x-amz-request-id
+	RequestHostID string   // x-amz-id-2
+	API           string   // API name - GetObject PutObject NewMultipartUpload etc.
+	BucketName    string   // Bucket name
+	ObjectName    string   // Object name
+	AccessKey     string   // Access key of the request credentials
+	tags          []KeyVal // Any additional info not accommodated by above fields
 	sync.RWMutex
 }
 
@@ -86,6 +86,11 @@ func (c *Target) Send(e interface{}, logKind string) error {
 		requestID = "\nRequestID: " + entry.RequestID
 	}
 
+	var requestHostID string
+	if entry.RequestHostID != "" {
+		requestHostID = "\nRequestHostID: " + entry.RequestHostID
+	}
+
 	var remoteHost string
 	if entry.RemoteHost != "" {
 		remoteHost = "\nRemoteHost: " + entry.RemoteHost
@@ -106,8 +111,8 @@ func (c *Target) Send(e interface{}, logKind string) error {
 	}
 
 	var msg = color.FgRed(color.Bold(entry.Trace.Message))
-	var output = fmt.Sprintf("\n%s\n%s%s%s%s%s%s\nError: %s%s\n%s",
-		apiString, timeString, deploymentID, requestID, remoteHost, host, userAgent,
+	var output = fmt.Sprintf("\n%s\n%s%s%s%s%s%s%s\nError: %s%s\n%s",
+		apiString, timeString, deploymentID, requestID, requestHostID, remoteHost, host, userAgent,
 		msg, tagString, strings.Join(trace, "\n"))
 
 	console.Println(output)
@@ -116,6 +116,7 @@ func newApp(name string) *cli.App {
 	// Register all commands.
 	registerCommand(serverCmd)
 	registerCommand(gatewayCmd)
+	registerCommand(benchCmd)
 
 	// Set up app.
 	cli.HelpFlag = cli.BoolFlag{
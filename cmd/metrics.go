@@ -311,6 +311,12 @@ func metricsHandler() http.Handler {
 	err = registry.Register(newMinioCollector())
 	logger.LogIf(context.Background(), err)
 
+	err = registry.Register(globalBucketMetricsSys)
+	logger.LogIf(context.Background(), err)
+
+	err = registry.Register(globalAuthFailureStats)
+	logger.LogIf(context.Background(), err)
+
 	gatherers := prometheus.Gatherers{
 		prometheus.DefaultGatherer,
 		registry,
@@ -121,6 +121,10 @@ type BucketInfo struct {
 
 	// Date and time when the bucket was created.
 	Created time.Time
+
+	// Hash is the CID of the current root of the bucket,
+	// only set by object layers that store buckets on IPFS.
+	Hash string
 }
 
 // ObjectInfo - represents object metadata.
@@ -302,6 +306,16 @@ type ListObjectsInfo struct {
 	Prefixes []string
 }
 
+// ListBucketsInfo - container for a page of a bucket listing.
+type ListBucketsInfo struct {
+	// List of buckets info for this request.
+	Buckets []BucketInfo
+
+	// When the listing is truncated, the token to pass as continuation
+	// token to list the next page, empty for the last page.
+	NextContinuationToken string
+}
+
 // ListObjectsV2Info - container for list objects version 2.
 type ListObjectsV2Info struct {
 	// Indicates whether the returned list objects response is truncated. A
@@ -343,6 +357,10 @@ type PartInfo struct {
 
 	// Decompressed Size.
 	ActualSize int64
+
+	// Base64 checksum of the part with the checksum algorithm of its
+	// upload, empty if the upload has none.
+	Checksum string
 }
 
 // MultipartInfo - represents metadata in progress multipart upload.
@@ -368,6 +386,9 @@ type CompletePart struct {
 
 	// Entity tag returned when the part was uploaded.
 	ETag string
+
+	// Checksum of the part sent by the client, optional.
+	Checksums
 }
 
 // CompletedParts - is a collection satisfying sort.Interface.
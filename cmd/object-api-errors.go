@@ -186,6 +186,17 @@ func (e ObjectNotFound) Error() string {
 	return "Object not found: " + e.Bucket + "#" + e.Object
 }
 
+// VersionNotFound object version does not exist.
+type VersionNotFound struct {
+	Bucket    string
+	Object    string
+	VersionID string
+}
+
+func (e VersionNotFound) Error() string {
+	return "Version not found: " + e.Bucket + "#" + e.Object + " (" + e.VersionID + ")"
+}
+
 // ObjectAlreadyExists object already exists.
 type ObjectAlreadyExists GenericError
 
@@ -269,6 +280,23 @@ func (e BucketSSEConfigNotFound) Error() string {
 	return "No bucket encryption found for bucket: " + e.Bucket
 }
 
+// PublicAccessBlockNotFound - no public access block configuration found
+type PublicAccessBlockNotFound GenericError
+
+func (e PublicAccessBlockNotFound) Error() string {
+	return "No public access block configuration found for bucket: " + e.Bucket
+}
+
+// BucketMetricsConfigNotFound - no bucket metrics configuration found with the id
+type BucketMetricsConfigNotFound struct {
+	Bucket string
+	ID     string
+}
+
+func (e BucketMetricsConfigNotFound) Error() string {
+	return "No metrics configuration " + e.ID + " found for bucket: " + e.Bucket
+}
+
 /// Bucket related errors.
 
 // BucketNameInvalid - bucketname provided is invalid.
@@ -321,6 +349,17 @@ func (e IncompleteBody) Error() string {
 	return e.Bucket + "#" + e.Object + "has incomplete body"
 }
 
+// ChecksumMismatch - the x-amz-checksum you specified did not match what we received.
+type ChecksumMismatch struct {
+	Algorithm  string
+	Expected   string
+	Calculated string
+}
+
+func (e ChecksumMismatch) Error() string {
+	return "Bad " + e.Algorithm + " checksum: Expected " + e.Expected + " is not valid with what we calculated " + e.Calculated
+}
+
 // InvalidRange - invalid range typed error.
 type InvalidRange struct {
 	OffsetBegin  int64
@@ -406,6 +445,31 @@ func (e PartTooBig) Error() string {
 	return "Part size bigger than the allowed limit"
 }
 
+// InvalidPartNumber error returned when the part number is out of range.
+type InvalidPartNumber struct {
+	PartNumber int
+}
+
+func (e InvalidPartNumber) Error() string {
+	return fmt.Sprintf("Part number %d out of range", e.PartNumber)
+}
+
+// MetadataTooLarge error returned when the user defined metadata exceeds the allowed size.
+type MetadataTooLarge struct{}
+
+func (e MetadataTooLarge) Error() string {
+	return "Metadata size bigger than the allowed limit"
+}
+
+// InvalidContinuationToken error returned when a listing can not be continued from a continuation token
+type InvalidContinuationToken struct {
+	Token string
+}
+
+func (e InvalidContinuationToken) Error() string {
+	return "Invalid continuation token " + e.Token
+}
+
 // InvalidETag error returned when the etag has changed on disk
 type InvalidETag struct{}
 
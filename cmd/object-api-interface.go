@@ -20,11 +20,15 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"time"
 
 	bucketsse "github.com/RTradeLtd/s3x/pkg/bucket/encryption"
+	bucketheaders "github.com/RTradeLtd/s3x/pkg/bucket/headers"
 	"github.com/RTradeLtd/s3x/pkg/bucket/lifecycle"
+	bucketmetrics "github.com/RTradeLtd/s3x/pkg/bucket/metrics"
 	"github.com/RTradeLtd/s3x/pkg/bucket/object/tagging"
 	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/RTradeLtd/s3x/pkg/bucket/publicaccess"
 	"github.com/RTradeLtd/s3x/pkg/madmin"
 	"github.com/minio/minio-go/v6/pkg/encrypt"
 )
@@ -42,6 +46,29 @@ type ObjectOptions struct {
 	CheckCopyPrecondFn   CheckCopyPreconditionFn
 }
 
+// BucketOptions represents the options of a bucket set when it is created
+type BucketOptions struct {
+	Location string
+	// LockEnabled enables object lock for the bucket
+	LockEnabled bool
+	// VersioningEnabled enables versioning for the bucket
+	VersioningEnabled bool
+	// SSEConfig is the default encryption of new objects in the bucket, nil if not set
+	SSEConfig *bucketsse.BucketSSEConfig
+}
+
+// ListBucketsOptions represents the options of a paginated bucket listing
+type ListBucketsOptions struct {
+	// Prefix only lists the buckets with names starting with it
+	Prefix string
+	// ContinuationToken is the NextContinuationToken of the previous page, empty for the first page
+	ContinuationToken string
+	// MaxBuckets is the maximum number of buckets of the page, all buckets are listed if 0
+	MaxBuckets int
+	// SortByCreated sorts buckets by creation time, oldest first, instead of by name
+	SortByCreated bool
+}
+
 // LockType represents required locking for ObjectLayer operations
 type LockType int
 
@@ -62,7 +89,7 @@ type ObjectLayer interface {
 	StorageInfo(ctx context.Context, local bool) StorageInfo // local queries only local disks
 
 	// Bucket operations.
-	MakeBucketWithLocation(ctx context.Context, bucket string, location string) error
+	MakeBucketWithLocation(ctx context.Context, bucket string, opts BucketOptions) error
 	GetBucketInfo(ctx context.Context, bucket string) (bucketInfo BucketInfo, err error)
 	ListBuckets(ctx context.Context) (buckets []BucketInfo, err error)
 	DeleteBucket(ctx context.Context, bucket string) error
@@ -139,3 +166,100 @@ type ObjectLayer interface {
 	GetObjectTag(context.Context, string, string) (tagging.Tagging, error)
 	DeleteObjectTag(context.Context, string, string) error
 }
+
+// unwrapObjectLayer - returns the gateway layer of an object layer wrapped with a locker.
+// Gateways are served wrapped with a GatewayLocker, which only implements ObjectLayer, so the
+// optional interfaces below must be asserted on the unwrapped layer, not on the object layer.
+func unwrapObjectLayer(objAPI ObjectLayer) ObjectLayer {
+	if l, ok := objAPI.(*GatewayLocker); ok {
+		return l.ObjectLayer
+	}
+	return objAPI
+}
+
+// BucketExistenceChecker is implemented by object layers that can check whether a bucket exists
+// without loading its information, HeadBucket uses it instead of GetBucketInfo.
+type BucketExistenceChecker interface {
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+}
+
+// BucketHasher is implemented by object layers that store buckets on IPFS, HeadBucket uses it
+// after BucketExists, if it is implemented, to return the CID of the current root of the bucket.
+type BucketHasher interface {
+	BucketHash(ctx context.Context, bucket string) (string, error)
+}
+
+// DataUsageReporter is implemented by object layers that account for the data they store themselves,
+// the data usage admin APIs use it instead of the usage saved by the data usage crawler.
+type DataUsageReporter interface {
+	DataUsageInfo(ctx context.Context) (DataUsageInfo, error)
+}
+
+// BucketPageLister is implemented by object layers that can list buckets in pages
+// without loading the information of all buckets. Continuation tokens have the
+// format of the tokens of PaginateBuckets, so a page can also end at any bucket.
+type BucketPageLister interface {
+	ListBucketsPage(ctx context.Context, opts ListBucketsOptions) (ListBucketsInfo, error)
+}
+
+// ObjectVersionDeleter is implemented by object layers that keep noncurrent object versions.
+// DeleteObjectVersions permanently removes the given versions, and returns an error for each of them.
+type ObjectVersionDeleter interface {
+	DeleteObjectVersions(ctx context.Context, bucket string, objects []ObjectIdentifier) ([]error, error)
+}
+
+// PublicAccessBlocker is implemented by object layers that can block public access to buckets.
+// PublicAccessBlock returns the public access blocked for a bucket, the combination of the
+// configuration of the bucket and the public access blocked for all buckets.
+type PublicAccessBlocker interface {
+	SetPublicAccessBlock(ctx context.Context, bucket string, config *publicaccess.Config) error
+	GetPublicAccessBlock(ctx context.Context, bucket string) (*publicaccess.Config, error)
+	DeletePublicAccessBlock(ctx context.Context, bucket string) error
+	PublicAccessBlock(ctx context.Context, bucket string) (publicaccess.Config, error)
+}
+
+// OwnerRestricter is implemented by object layers that can deny requests of the root credential by default.
+// DenyOwnerByDefault returns true if requests of the root credential are only allowed by bucket policies,
+// and IsBreakGlassKey returns true for the emergency key that exempts a request of the root credential.
+type OwnerRestricter interface {
+	DenyOwnerByDefault() bool
+	IsBreakGlassKey(key string) bool
+}
+
+// RequestAuthConfigurer is implemented by object layers that harden the verification of signed requests.
+// MaxClockSkew returns the allowed difference between the date of a signed request and the server time, 0 for
+// the default, RequireSignatureV4 returns true if requests signed with signature version 2 are denied, and
+// AuthRegion returns the region that signed requests must be scoped to, empty for the server region.
+type RequestAuthConfigurer interface {
+	MaxClockSkew() time.Duration
+	RequireSignatureV4() bool
+	AuthRegion() string
+}
+
+// ProfilingEnabler is implemented by object layers that can expose the runtime profiles of the server
+// to admin requests. ProfilingEnabled returns true if the profiles are exposed.
+type ProfilingEnabler interface {
+	ProfilingEnabled() bool
+}
+
+// BucketHeadersConfigurer is implemented by object layers that configure the headers of requests to buckets.
+// BucketHeaders returns the rules of the response headers set on, and the request headers required by,
+// requests to buckets, nil if there are none.
+type BucketHeadersConfigurer interface {
+	BucketHeaders() *bucketheaders.Config
+}
+
+// BucketRegionRouter is implemented by object layers of gateways that serve the buckets of a region.
+// BucketRegion returns the region label of a bucket, empty if the bucket does not exist, and the endpoint
+// of the gateway of the region if requests to the bucket are served by another gateway.
+type BucketRegionRouter interface {
+	BucketRegion(ctx context.Context, bucket string) (region, endpoint string, err error)
+}
+
+// BucketMetricsConfigurer is implemented by object layers that keep the metrics configurations of buckets.
+// SetBucketMetricsConfig adds or replaces the configuration with the id of config.
+type BucketMetricsConfigurer interface {
+	SetBucketMetricsConfig(ctx context.Context, bucket string, config *bucketmetrics.Config) error
+	DeleteBucketMetricsConfig(ctx context.Context, bucket, id string) error
+	ListBucketMetricsConfigs(ctx context.Context, bucket string) ([]bucketmetrics.Config, error)
+}
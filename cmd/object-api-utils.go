@@ -725,6 +725,21 @@ func (g *GetObjectReader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// WriteTo - to implement WriterTo interface, so io.Copy writes the
+// object directly to w if the underlying reader can stream to a writer,
+// otherwise the object is copied through a buffer as with Read.
+func (g *GetObjectReader) WriteTo(w io.Writer) (n int64, err error) {
+	if wt, ok := g.pReader.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	} else {
+		n, err = io.Copy(w, g.pReader)
+	}
+	// The object was read to its end or failed, as when Read
+	// returns an error.
+	g.Close()
+	return n, err
+}
+
 //SealMD5CurrFn seals md5sum with object encryption key and returns sealed
 // md5sum
 type SealMD5CurrFn func([]byte) []byte
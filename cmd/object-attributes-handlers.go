@@ -0,0 +1,252 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RTradeLtd/s3x/cmd/crypto"
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+	"github.com/RTradeLtd/s3x/cmd/logger"
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+	"github.com/gorilla/mux"
+)
+
+// The attributes that can be requested with GetObjectAttributes.
+const (
+	objectAttributeETag         = "ETag"
+	objectAttributeChecksum     = "Checksum"
+	objectAttributeObjectParts  = "ObjectParts"
+	objectAttributeStorageClass = "StorageClass"
+	objectAttributeObjectSize   = "ObjectSize"
+)
+
+// ObjectAttributesChecksum - the checksum of an object, only the algorithm
+// sent when the object was uploaded is set.
+type ObjectAttributesChecksum struct {
+	ChecksumCRC32     string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumCRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	ChecksumSHA1      string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// ObjectAttributesPart - a part of a multipart object.
+type ObjectAttributesPart struct {
+	PartNumber int   `xml:"PartNumber"`
+	Size       int64 `xml:"Size"`
+}
+
+// ObjectAttributesParts - the parts of a multipart object.
+type ObjectAttributesParts struct {
+	IsTruncated          bool                   `xml:"IsTruncated"`
+	MaxParts             int                    `xml:"MaxParts"`
+	NextPartNumberMarker int                    `xml:"NextPartNumberMarker"`
+	PartNumberMarker     int                    `xml:"PartNumberMarker"`
+	Parts                []ObjectAttributesPart `xml:"Part"`
+	PartsCount           int                    `xml:"PartsCount"`
+}
+
+// GetObjectAttributesResponse - format for the GetObjectAttributes response.
+type GetObjectAttributesResponse struct {
+	XMLName      xml.Name                  `xml:"http://s3.amazonaws.com/doc/2006-03-01/ GetObjectAttributesResponse" json:"-"`
+	ETag         string                    `xml:"ETag,omitempty"`
+	Checksum     *ObjectAttributesChecksum `xml:"Checksum,omitempty"`
+	ObjectParts  *ObjectAttributesParts    `xml:"ObjectParts,omitempty"`
+	StorageClass string                    `xml:"StorageClass,omitempty"`
+	ObjectSize   *int64                    `xml:"ObjectSize,omitempty"`
+}
+
+// parseObjectAttributes returns the attributes requested in the
+// X-Amz-Object-Attributes header.
+func parseObjectAttributes(h http.Header) (map[string]bool, APIErrorCode) {
+	attributes := make(map[string]bool)
+	for _, value := range h[http.CanonicalHeaderKey(xhttp.AmzObjectAttributes)] {
+		for _, attribute := range strings.Split(value, ",") {
+			switch attribute = strings.TrimSpace(attribute); attribute {
+			case objectAttributeETag, objectAttributeChecksum, objectAttributeObjectParts,
+				objectAttributeStorageClass, objectAttributeObjectSize:
+				attributes[attribute] = true
+			default:
+				return nil, ErrInvalidObjectAttributes
+			}
+		}
+	}
+	if len(attributes) == 0 {
+		return nil, ErrInvalidObjectAttributes
+	}
+	return attributes, ErrNone
+}
+
+// objectAttributesChecksum returns the checksum stored with an object,
+// nil if none was stored.
+func objectAttributesChecksum(userDefined map[string]string) *ObjectAttributesChecksum {
+	checksums := objectChecksums(userDefined)
+	if len(checksums) == 0 {
+		return nil
+	}
+	return &ObjectAttributesChecksum{
+		ChecksumCRC32:     checksums["crc32"],
+		ChecksumCRC32C:    checksums["crc32c"],
+		ChecksumCRC64NVME: checksums["crc64nvme"],
+		ChecksumSHA1:      checksums["sha1"],
+		ChecksumSHA256:    checksums["sha256"],
+	}
+}
+
+// objectAttributesParts returns a page of the parts of a multipart object,
+// nil for objects that were not uploaded in parts.
+func objectAttributesParts(objInfo ObjectInfo, partNumberMarker, maxParts int) *ObjectAttributesParts {
+	if !strings.Contains(objInfo.ETag, "-") || len(objInfo.Parts) == 0 {
+		return nil
+	}
+	parts := &ObjectAttributesParts{
+		MaxParts:         maxParts,
+		PartNumberMarker: partNumberMarker,
+		PartsCount:       len(objInfo.Parts),
+	}
+	for _, p := range objInfo.Parts {
+		if p.Number <= partNumberMarker {
+			continue
+		}
+		if len(parts.Parts) == maxParts {
+			parts.IsTruncated = true
+			break
+		}
+		parts.Parts = append(parts.Parts, ObjectAttributesPart{PartNumber: p.Number, Size: p.Size})
+		parts.NextPartNumberMarker = p.Number
+	}
+	return parts
+}
+
+// GetObjectAttributesHandler - GET Object?attributes
+// ----------
+// This implementation of the GET operation returns the requested
+// attributes of an object without its data, such as its ETag, the
+// checksum sent when it was uploaded, and its parts.
+func (api objectAPIHandlers) GetObjectAttributesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r, w, "GetObjectAttributes")
+
+	defer logger.AuditLog(w, r, "GetObjectAttributes", mustGetClaimsFromToken(r))
+
+	objectAPI := api.ObjectAPI()
+	if objectAPI == nil {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrServerNotInitialized), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	if !api.EncryptionEnabled() && crypto.IsRequested(r.Header) {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrBadRequest), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	object, err := url.PathUnescape(vars["object"])
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if vid := r.URL.Query().Get("versionId"); vid != "" && vid != "null" {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrNoSuchVersion), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	if s3Error := checkRequestAuthType(ctx, r, policy.GetObjectAction, bucket, object); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	attributes, s3Error := parseObjectAttributes(r.Header)
+	if s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	maxParts, partNumberMarker := maxPartsList, 0
+	if v := r.Header.Get(xhttp.AmzMaxParts); v != "" {
+		if maxParts, err = strconv.Atoi(v); err != nil || maxParts < 0 {
+			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidMaxParts), r.URL, guessIsBrowserReq(r))
+			return
+		}
+	}
+	if v := r.Header.Get(xhttp.AmzPartNumberMarker); v != "" {
+		if partNumberMarker, err = strconv.Atoi(v); err != nil || partNumberMarker < 0 {
+			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrInvalidPartNumberMarker), r.URL, guessIsBrowserReq(r))
+			return
+		}
+	}
+
+	getObjectInfo := objectAPI.GetObjectInfo
+	if api.CacheAPI() != nil {
+		getObjectInfo = api.CacheAPI().GetObjectInfo
+	}
+
+	opts, err := getOpts(ctx, r, bucket, object)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	objInfo, err := getObjectInfo(ctx, bucket, object, opts)
+	if err != nil {
+		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
+	size := objInfo.Size
+	if objectAPI.IsEncryptionSupported() {
+		if _, err = DecryptObjectInfo(&objInfo, r.Header); err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		if crypto.IsEncrypted(objInfo.UserDefined) {
+			if size, err = objInfo.DecryptedSize(); err != nil {
+				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+				return
+			}
+		}
+	}
+	if objInfo.IsCompressed() {
+		size = objInfo.GetActualSize()
+	}
+
+	response := GetObjectAttributesResponse{}
+	if attributes[objectAttributeETag] {
+		response.ETag = objInfo.ETag
+	}
+	if attributes[objectAttributeChecksum] {
+		response.Checksum = objectAttributesChecksum(objInfo.UserDefined)
+	}
+	if attributes[objectAttributeObjectParts] {
+		response.ObjectParts = objectAttributesParts(objInfo, partNumberMarker, maxParts)
+	}
+	if attributes[objectAttributeStorageClass] {
+		response.StorageClass = objInfo.StorageClass
+		if response.StorageClass == "" {
+			response.StorageClass = globalMinioDefaultStorageClass
+		}
+	}
+	if attributes[objectAttributeObjectSize] {
+		response.ObjectSize = &size
+	}
+
+	w.Header().Set(xhttp.LastModified, objInfo.ModTime.UTC().Format(http.TimeFormat))
+	writeSuccessResponseXML(w, encodeResponse(response))
+}
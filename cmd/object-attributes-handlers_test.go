@@ -0,0 +1,83 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests parsing the X-Amz-Object-Attributes header.
+func TestParseObjectAttributes(t *testing.T) {
+	testCases := []struct {
+		values     []string
+		attributes int
+		errCode    APIErrorCode
+	}{
+		{errCode: ErrInvalidObjectAttributes},
+		{values: []string{"ETag, Checksum"}, attributes: 2},
+		{values: []string{"ObjectSize", "StorageClass,ObjectParts"}, attributes: 3},
+		{values: []string{"ETag,Size"}, errCode: ErrInvalidObjectAttributes},
+	}
+	for i, testCase := range testCases {
+		attributes, errCode := parseObjectAttributes(http.Header{"X-Amz-Object-Attributes": testCase.values})
+		if errCode != testCase.errCode || len(attributes) != testCase.attributes {
+			t.Errorf("Test %d: expected %d attributes and %v, got %v and %v", i+1, testCase.attributes, testCase.errCode, attributes, errCode)
+		}
+	}
+}
+
+// Tests paging the parts of a multipart object.
+func TestObjectAttributesParts(t *testing.T) {
+	objInfo := ObjectInfo{
+		ETag:  "d41d8cd98f00b204e9800998ecf8427e-3",
+		Parts: []ObjectPartInfo{{Number: 1, Size: 5}, {Number: 2, Size: 5}, {Number: 3, Size: 1}},
+	}
+	parts := objectAttributesParts(objInfo, 1, 1)
+	if parts.PartsCount != 3 || !parts.IsTruncated || parts.NextPartNumberMarker != 2 ||
+		len(parts.Parts) != 1 || parts.Parts[0].PartNumber != 2 {
+		t.Fatalf("unexpected parts %+v", parts)
+	}
+	if parts = objectAttributesParts(objInfo, 2, 10); parts.IsTruncated || len(parts.Parts) != 1 {
+		t.Fatalf("unexpected parts %+v", parts)
+	}
+	// objects that were not uploaded in parts have no parts
+	objInfo.ETag = "d41d8cd98f00b204e9800998ecf8427e"
+	if parts = objectAttributesParts(objInfo, 0, 10); parts != nil {
+		t.Fatalf("unexpected parts %+v", parts)
+	}
+}
+
+// Tests that checksums are only returned for the whole object.
+func TestSetObjectHeadersChecksum(t *testing.T) {
+	objInfo := ObjectInfo{Size: 10, UserDefined: map[string]string{"x-amz-checksum-crc32": "y/Q5Jg=="}}
+	w := httptest.NewRecorder()
+	if err := setObjectHeaders(w, objInfo, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("X-Amz-Checksum-Crc32") != "y/Q5Jg==" {
+		t.Fatalf("expected the checksum header, got %v", w.Header())
+	}
+	w = httptest.NewRecorder()
+	if err := setObjectHeaders(w, objInfo, &HTTPRangeSpec{Start: 0, End: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("X-Amz-Checksum-Crc32") != "" {
+		t.Fatalf("expected no checksum header for a range, got %v", w.Header())
+	}
+}
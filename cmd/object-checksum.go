@@ -0,0 +1,210 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"strings"
+
+	xhttp "github.com/RTradeLtd/s3x/cmd/http"
+)
+
+var (
+	// crc32CTable is the table of the CRC-32C checksum used by S3.
+	crc32CTable = crc32.MakeTable(crc32.Castagnoli)
+	// crc64NVMETable is the table of the CRC-64/NVME checksum used by S3.
+	crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+)
+
+// checksumAlgorithms are the S3 checksum algorithms by the suffix of
+// their x-amz-checksum- header.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"crc32c":    func() hash.Hash { return crc32.New(crc32CTable) },
+	"crc64nvme": func() hash.Hash { return crc64.New(crc64NVMETable) },
+	"sha1":      sha1.New,
+	"sha256":    sha256.New,
+}
+
+// objectChecksum is a base64 encoded checksum of the data of an object.
+type objectChecksum struct {
+	Algorithm string
+	Value     string
+}
+
+// key returns the metadata key the checksum is stored under, which is
+// also the header it is returned in.
+func (c objectChecksum) key() string {
+	return xhttp.AmzChecksumPrefix + c.Algorithm
+}
+
+// extractChecksum returns the checksum sent in an x-amz-checksum- header,
+// nil if none was sent. Only one checksum may be sent, and it must be the
+// base64 encoding of a checksum of its algorithm.
+func extractChecksum(h http.Header) (*objectChecksum, APIErrorCode) {
+	var checksum *objectChecksum
+	for algorithm, newHash := range checksumAlgorithms {
+		value := h.Get(xhttp.AmzChecksumPrefix + algorithm)
+		if value == "" {
+			continue
+		}
+		if checksum != nil {
+			return nil, ErrInvalidChecksum
+		}
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != newHash().Size() {
+			return nil, ErrInvalidChecksum
+		}
+		checksum = &objectChecksum{Algorithm: algorithm, Value: value}
+	}
+	return checksum, ErrNone
+}
+
+// extractChecksumAlgorithm returns the checksum algorithm selected for the
+// parts of a multipart upload in the x-amz-checksum-algorithm header, empty
+// if none was selected. Only composite checksums of the part checksums are
+// computed for the completed object.
+func extractChecksumAlgorithm(h http.Header) (string, APIErrorCode) {
+	value := h.Get(xhttp.AmzChecksumAlgorithm)
+	if value == "" {
+		return "", ErrNone
+	}
+	algorithm := strings.ToLower(value)
+	if _, ok := checksumAlgorithms[algorithm]; !ok {
+		return "", ErrInvalidChecksumAlgorithm
+	}
+	if checksumType := h.Get(xhttp.AmzChecksumType); checksumType != "" && !strings.EqualFold(checksumType, "COMPOSITE") {
+		return "", ErrInvalidChecksumType
+	}
+	return algorithm, ErrNone
+}
+
+// getCompleteMultipartChecksum returns the composite checksum of a
+// multipart object, the checksum of the decoded checksums of its parts
+// followed by the number of parts, like the multipart ETag.
+func getCompleteMultipartChecksum(algorithm string, partChecksums []string) (string, error) {
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	h := newHash()
+	for _, checksum := range partChecksums {
+		sum, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil {
+			return "", err
+		}
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(partChecksums)), nil
+}
+
+// Checksums - the checksums of an object or part in XML requests and
+// responses, only the algorithm it was uploaded with is set.
+type Checksums struct {
+	ChecksumCRC32     string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumCRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	ChecksumSHA1      string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// field returns the field of the checksum of an algorithm, nil for
+// unknown algorithms.
+func (c *Checksums) field(algorithm string) *string {
+	switch algorithm {
+	case "crc32":
+		return &c.ChecksumCRC32
+	case "crc32c":
+		return &c.ChecksumCRC32C
+	case "crc64nvme":
+		return &c.ChecksumCRC64NVME
+	case "sha1":
+		return &c.ChecksumSHA1
+	case "sha256":
+		return &c.ChecksumSHA256
+	}
+	return nil
+}
+
+// Get returns the checksum of an algorithm, empty if it is not set.
+func (c Checksums) Get(algorithm string) string {
+	if f := c.field(algorithm); f != nil {
+		return *f
+	}
+	return ""
+}
+
+// set sets the checksum of an algorithm, unknown algorithms are ignored.
+func (c *Checksums) set(algorithm, value string) {
+	if f := c.field(algorithm); f != nil {
+		*f = value
+	}
+}
+
+// objectChecksums returns the checksums stored in the metadata of an
+// object, by algorithm.
+func objectChecksums(userDefined map[string]string) map[string]string {
+	checksums := make(map[string]string)
+	for k, v := range userDefined {
+		if isChecksumKey(k) {
+			checksums[strings.ToLower(k)[len(xhttp.AmzChecksumPrefix):]] = v
+		}
+	}
+	return checksums
+}
+
+// isChecksumKey returns true if a metadata key holds a checksum of the
+// data of an object.
+func isChecksumKey(k string) bool {
+	k = strings.ToLower(k)
+	if !strings.HasPrefix(k, xhttp.AmzChecksumPrefix) {
+		return false
+	}
+	_, ok := checksumAlgorithms[k[len(xhttp.AmzChecksumPrefix):]]
+	return ok
+}
+
+// checksumReader verifies the checksum of the data read from the
+// underlying reader, a mismatch is returned instead of io.EOF.
+type checksumReader struct {
+	r        io.Reader
+	h        hash.Hash
+	checksum objectChecksum
+}
+
+func newChecksumReader(r io.Reader, checksum objectChecksum) *checksumReader {
+	return &checksumReader{r: r, h: checksumAlgorithms[checksum.Algorithm](), checksum: checksum}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	if err == io.EOF {
+		if calculated := base64.StdEncoding.EncodeToString(c.h.Sum(nil)); calculated != c.checksum.Value {
+			return n, ChecksumMismatch{Algorithm: c.checksum.Algorithm, Expected: c.checksum.Value, Calculated: calculated}
+		}
+	}
+	return n, err
+}
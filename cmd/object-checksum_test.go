@@ -0,0 +1,150 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Tests the check values of the checksum algorithms.
+func TestChecksumAlgorithms(t *testing.T) {
+	testCases := map[string]uint64{
+		"crc32":     0xcbf43926,
+		"crc32c":    0xe3069283,
+		"crc64nvme": 0xae8b14860a799888,
+	}
+	for algorithm, check := range testCases {
+		h := checksumAlgorithms[algorithm]()
+		h.Write([]byte("123456789"))
+		sum := h.Sum(nil)
+		var got uint64
+		if len(sum) == 4 {
+			got = uint64(binary.BigEndian.Uint32(sum))
+		} else {
+			got = binary.BigEndian.Uint64(sum)
+		}
+		if got != check {
+			t.Errorf("%s: expected %x, got %x", algorithm, check, got)
+		}
+	}
+}
+
+// Tests validating the x-amz-checksum- headers of a request.
+func TestExtractChecksum(t *testing.T) {
+	crc32 := base64.StdEncoding.EncodeToString([]byte{0xcb, 0xf4, 0x39, 0x26})
+	testCases := []struct {
+		header   http.Header
+		checksum *objectChecksum
+		errCode  APIErrorCode
+	}{
+		{header: http.Header{}},
+		{
+			header:   http.Header{"X-Amz-Checksum-Crc32": []string{crc32}},
+			checksum: &objectChecksum{Algorithm: "crc32", Value: crc32},
+		},
+		{header: http.Header{"X-Amz-Checksum-Crc32": []string{"not base64"}}, errCode: ErrInvalidChecksum},
+		{header: http.Header{"X-Amz-Checksum-Sha256": []string{crc32}}, errCode: ErrInvalidChecksum},
+		{
+			header:  http.Header{"X-Amz-Checksum-Crc32": []string{crc32}, "X-Amz-Checksum-Crc32c": []string{crc32}},
+			errCode: ErrInvalidChecksum,
+		},
+	}
+	for i, testCase := range testCases {
+		checksum, errCode := extractChecksum(testCase.header)
+		if errCode != testCase.errCode {
+			t.Errorf("Test %d: expected error code %v, got %v", i+1, testCase.errCode, errCode)
+		}
+		if (checksum == nil) != (testCase.checksum == nil) || checksum != nil && *checksum != *testCase.checksum {
+			t.Errorf("Test %d: expected checksum %v, got %v", i+1, testCase.checksum, checksum)
+		}
+	}
+}
+
+// Tests verifying the checksum of uploaded data.
+func TestChecksumReader(t *testing.T) {
+	checksum := objectChecksum{Algorithm: "crc32", Value: base64.StdEncoding.EncodeToString([]byte{0xcb, 0xf4, 0x39, 0x26})}
+	if _, err := ioutil.ReadAll(newChecksumReader(strings.NewReader("123456789"), checksum)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ioutil.ReadAll(newChecksumReader(strings.NewReader("12345678"), checksum))
+	if _, ok := err.(ChecksumMismatch); !ok {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if toAPIErrorCode(GlobalContext, err) != ErrBadChecksum {
+		t.Fatalf("expected %v, got %v", ErrBadChecksum, toAPIErrorCode(GlobalContext, err))
+	}
+	checksums := objectChecksums(map[string]string{"X-Amz-Checksum-Crc32": checksum.Value, "x-amz-meta-a": "b"})
+	if len(checksums) != 1 || checksums["crc32"] != checksum.Value {
+		t.Fatalf("unexpected checksums %v", checksums)
+	}
+}
+
+// Tests the checksum algorithm of multipart uploads and the composite
+// checksum of the completed object.
+func TestMultipartChecksum(t *testing.T) {
+	testCases := []struct {
+		header    http.Header
+		algorithm string
+		errCode   APIErrorCode
+	}{
+		{header: http.Header{}},
+		{header: http.Header{"X-Amz-Checksum-Algorithm": []string{"CRC32"}}, algorithm: "crc32"},
+		{
+			header:    http.Header{"X-Amz-Checksum-Algorithm": []string{"SHA256"}, "X-Amz-Checksum-Type": []string{"COMPOSITE"}},
+			algorithm: "sha256",
+		},
+		{header: http.Header{"X-Amz-Checksum-Algorithm": []string{"MD5"}}, errCode: ErrInvalidChecksumAlgorithm},
+		{
+			header:  http.Header{"X-Amz-Checksum-Algorithm": []string{"CRC32"}, "X-Amz-Checksum-Type": []string{"FULL_OBJECT"}},
+			errCode: ErrInvalidChecksumType,
+		},
+	}
+	for i, testCase := range testCases {
+		algorithm, errCode := extractChecksumAlgorithm(testCase.header)
+		if errCode != testCase.errCode || algorithm != testCase.algorithm {
+			t.Errorf("Test %d: expected %q and error code %v, got %q and %v", i+1, testCase.algorithm, testCase.errCode, algorithm, errCode)
+		}
+	}
+
+	// the crc32 of the crc32 checksums of two parts of "123456789"
+	part := base64.StdEncoding.EncodeToString([]byte{0xcb, 0xf4, 0x39, 0x26})
+	checksum, err := ComputeCompleteMultipartChecksum("crc32", []string{part, part})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != "cCOsTw==-2" {
+		t.Fatalf("expected checksum cCOsTw==-2, got %s", checksum)
+	}
+	if _, err := ComputeCompleteMultipartChecksum("md5", []string{part}); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+
+	var complete CompleteMultipartUpload
+	body := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>a</ETag><ChecksumCRC32>` + part + `</ChecksumCRC32></Part></CompleteMultipartUpload>`
+	if err := xml.Unmarshal([]byte(body), &complete); err != nil {
+		t.Fatal(err)
+	}
+	if len(complete.Parts) != 1 || complete.Parts[0].Get("crc32") != part || complete.Parts[0].Get("sha256") != "" {
+		t.Fatalf("unexpected parts %+v", complete.Parts)
+	}
+}
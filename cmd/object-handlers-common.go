@@ -18,8 +18,13 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/RTradeLtd/s3x/cmd/crypto"
@@ -232,6 +237,83 @@ func ifModifiedSince(objTime time.Time, givenTime time.Time) bool {
 	return objTime.After(givenTime.Add(1 * time.Second))
 }
 
+// isIfRangeMatch returns true if the If-Range value matches the object, so a range request
+// can continue a previous download. The value is either a strong ETag, or the HTTP date of
+// the last modification, weak ETags never match.
+func isIfRangeMatch(objInfo ObjectInfo, ifRange string) bool {
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	if strings.HasPrefix(ifRange, "\"") {
+		return objInfo.ETag != "" && isETagEqual(objInfo.ETag, ifRange)
+	}
+	givenTime, err := time.Parse(http.TimeFormat, ifRange)
+	if err != nil {
+		return false
+	}
+	return objInfo.ModTime.UTC().Truncate(time.Second).Equal(givenTime)
+}
+
+// getSatisfiableRanges returns the ranges of a multi-range request that can be
+// satisfied for an object of the given size, unsatisfiable ranges are skipped
+// as allowed by RFC 7233. Returns errInvalidRange if no range can be satisfied.
+func getSatisfiableRanges(ranges []*HTTPRangeSpec, size int64) ([]*HTTPRangeSpec, error) {
+	var satisfiable []*HTTPRangeSpec
+	for _, rs := range ranges {
+		if _, _, err := rs.GetOffsetLength(size); err == nil {
+			satisfiable = append(satisfiable, rs)
+		}
+	}
+	if len(satisfiable) == 0 {
+		return nil, errInvalidRange
+	}
+	return satisfiable, nil
+}
+
+// writeByteRanges writes the ranges of an object as a 206 multipart/byteranges
+// response, with the Content-Type and Content-Range of each range in its part.
+// The object headers must already be set. Each range is read with getRange, which
+// returns an error if the object changed since objInfo was read, so the response is
+// aborted instead of mixing the data of different versions of the object.
+func writeByteRanges(w http.ResponseWriter, objInfo ObjectInfo, ranges []*HTTPRangeSpec,
+	getRange func(rs *HTTPRangeSpec) (*GetObjectReader, error)) error {
+	totalObjectSize, err := getTotalObjectSize(objInfo)
+	if err != nil {
+		return err
+	}
+	contentType := w.Header().Get(xhttp.ContentType)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	mw := multipart.NewWriter(w)
+	w.Header().Del(xhttp.ContentLength)
+	w.Header().Del(xhttp.ContentRange)
+	w.Header().Set(xhttp.ContentType, "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+	for _, rs := range ranges {
+		start, length, err := rs.GetOffsetLength(totalObjectSize)
+		if err != nil {
+			return err
+		}
+		gr, err := getRange(rs)
+		if err != nil {
+			return err
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			xhttp.ContentType:  {contentType},
+			xhttp.ContentRange: {fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, totalObjectSize)},
+		})
+		if err == nil {
+			_, err = io.Copy(part, gr)
+		}
+		gr.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
 // canonicalizeETag returns ETag with leading and trailing double-quotes removed,
 // if any present
 func canonicalizeETag(etag string) string {
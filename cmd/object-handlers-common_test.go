@@ -17,7 +17,14 @@
 package cmd
 
 import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // Tests - canonicalizeETag()
@@ -51,3 +58,88 @@ func TestCanonicalizeETag(t *testing.T) {
 		}
 	}
 }
+
+// Tests - isIfRangeMatch()
+func TestIsIfRangeMatch(t *testing.T) {
+	modTime := time.Date(2020, 3, 1, 10, 30, 15, 500, time.UTC)
+	objInfo := ObjectInfo{ETag: "abc", ModTime: modTime}
+	testCases := []struct {
+		ifRange  string
+		expected bool
+	}{
+		{"\"abc\"", true},
+		{"\"abd\"", false},
+		{"W/\"abc\"", false},
+		{modTime.Format(http.TimeFormat), true},
+		{modTime.Add(time.Second).Format(http.TimeFormat), false},
+		{modTime.Add(-time.Second).Format(http.TimeFormat), false},
+		{"invalid", false},
+	}
+	for i, test := range testCases {
+		if got := isIfRangeMatch(objInfo, test.ifRange); got != test.expected {
+			t.Errorf("Test %d: expected %v for %q, got %v", i+1, test.expected, test.ifRange, got)
+		}
+	}
+}
+
+// Tests - getSatisfiableRanges() and writeByteRanges()
+func TestWriteByteRanges(t *testing.T) {
+	data := []byte("0123456789")
+	objInfo := ObjectInfo{ETag: "abc", Size: int64(len(data)), ContentType: "text/plain"}
+	ranges, err := parseRequestRangeSpecs("bytes=0-1,20-,-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges, err = getSatisfiableRanges(ranges, objInfo.Size); err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 {
+		t.Fatalf("expected the unsatisfiable range to be skipped, got %d ranges", len(ranges))
+	}
+	if _, err = getSatisfiableRanges(ranges, 0); err != errInvalidRange {
+		t.Fatalf("expected errInvalidRange, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", objInfo.ContentType)
+	rec.Header().Set("Content-Length", "10")
+	err = writeByteRanges(rec, objInfo, ranges, func(rs *HTTPRangeSpec) (*GetObjectReader, error) {
+		off, length, err := rs.GetOffsetLength(objInfo.Size)
+		if err != nil {
+			return nil, err
+		}
+		return NewGetObjectReaderFromReader(bytes.NewReader(data[off:off+length]), objInfo, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Length") != "" {
+		t.Fatalf("expected status 206 without a content length, got %d %q", rec.Code, rec.Header().Get("Content-Length"))
+	}
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %q %v", mediaType, err)
+	}
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-1/10", "01"},
+		{"bytes 7-9/10", "789"},
+	}
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	for i, test := range expected {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i+1, err)
+		}
+		if part.Header.Get("Content-Range") != test.contentRange || part.Header.Get("Content-Type") != objInfo.ContentType || string(body) != test.body {
+			t.Errorf("Test %d: expected %q %q, got %q %q %q", i+1, test.contentRange, test.body,
+				part.Header.Get("Content-Range"), part.Header.Get("Content-Type"), body)
+		}
+	}
+	if _, err = mr.NextPart(); err == nil {
+		t.Error("expected no more parts")
+	}
+}
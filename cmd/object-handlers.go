@@ -82,6 +82,16 @@ func setHeadGetRespHeaders(w http.ResponseWriter, reqParams url.Values) {
 	}
 }
 
+// hasHeadGetRespParams - returns true if any response header overrides are requested.
+func hasHeadGetRespParams(reqParams url.Values) bool {
+	for k := range reqParams {
+		if _, ok := supportedHeadGetReqParams[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // SelectObjectContentHandler - GET Object?select
 // ----------
 // This implementation of the GET operation retrieves object content based
@@ -181,7 +191,7 @@ func (api objectAPIHandlers) SelectObjectContentHandler(w http.ResponseWriter, r
 				Key:        object,
 				Resource:   r.URL.Path,
 				RequestID:  w.Header().Get(xhttp.AmzRequestID),
-				HostID:     globalDeploymentID,
+				HostID:     w.Header().Get(xhttp.AmzRequestHostID),
 			})
 			writeResponse(w, serr.HTTPStatusCode(), encodedErrorResponse, mimeXML)
 		} else {
@@ -228,7 +238,7 @@ func (api objectAPIHandlers) SelectObjectContentHandler(w http.ResponseWriter, r
 				Key:        object,
 				Resource:   r.URL.Path,
 				RequestID:  w.Header().Get(xhttp.AmzRequestID),
-				HostID:     globalDeploymentID,
+				HostID:     w.Header().Get(xhttp.AmzRequestHostID),
 			})
 			writeResponse(w, serr.HTTPStatusCode(), encodedErrorResponse, mimeXML)
 		} else {
@@ -332,16 +342,24 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Response header overrides are only allowed for signed requests.
+	if getRequestAuthType(r) == authTypeAnonymous && hasHeadGetRespParams(r.URL.Query()) {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrAnonymousResponseHeaders), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	getObjectNInfo := objectAPI.GetObjectNInfo
 	if api.CacheAPI() != nil {
 		getObjectNInfo = api.CacheAPI().GetObjectNInfo
 	}
 
-	// Get request range.
+	// Get request range, requests with several ranges are
+	// answered with a multipart/byteranges response.
 	var rs *HTTPRangeSpec
+	var ranges []*HTTPRangeSpec
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader != "" {
-		if rs, err = parseRequestRangeSpec(rangeHeader); err != nil {
+		if ranges, err = parseRequestRangeSpecs(rangeHeader); err != nil {
 			// Handle only errInvalidRange. Ignore other
 			// parse error and treat it as regular Get
 			// request like Amazon S3.
@@ -352,15 +370,48 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 
 			logger.LogIf(ctx, err, logger.Application)
 		}
+		if len(ranges) == 1 {
+			rs = ranges[0]
+		}
 	}
 
-	gr, err := getObjectNInfo(ctx, bucket, object, rs, r.Header, readLock, opts)
+	// Only the info of the object of a multi-range request is read, the ranges
+	// are opened one at a time after the preconditions were checked.
+	var gr *GetObjectReader
+	var objInfo ObjectInfo
+	if len(ranges) > 1 {
+		getObjectInfo := objectAPI.GetObjectInfo
+		if api.CacheAPI() != nil {
+			getObjectInfo = api.CacheAPI().GetObjectInfo
+		}
+		objInfo, err = getObjectInfo(ctx, bucket, object, opts)
+	} else if gr, err = getObjectNInfo(ctx, bucket, object, rs, r.Header, readLock, opts); err == nil {
+		objInfo = gr.ObjInfo
+	}
 	if err != nil {
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
-	defer gr.Close()
-	objInfo := gr.ObjInfo
+
+	// If-Range : Return the range only if the object did not change since the client
+	// started downloading it, otherwise return the whole object.
+	if ifRange := r.Header.Get(xhttp.IfRange); len(ranges) > 0 && ifRange != "" && !isIfRangeMatch(objInfo, ifRange) {
+		ranges = nil
+		if gr != nil {
+			gr.Close()
+		}
+		rs = nil
+		gr, err = getObjectNInfo(ctx, bucket, object, nil, r.Header, readLock, opts)
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		objInfo = gr.ObjInfo
+	}
+	if gr != nil {
+		defer gr.Close()
+	}
+	storedETag := objInfo.ETag
 
 	// filter object lock metadata if permission does not permit
 	getRetPerms := checkRequestAuthType(ctx, r, policy.GetObjectRetentionAction, bucket, object)
@@ -395,6 +446,31 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	if len(ranges) > 1 {
+		// Unsatisfiable ranges of a multi-range request are skipped.
+		var totalObjectSize int64
+		if totalObjectSize, err = getTotalObjectSize(objInfo); err == nil {
+			ranges, err = getSatisfiableRanges(ranges, totalObjectSize)
+		}
+		if err != nil {
+			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			return
+		}
+		rs = ranges[0]
+		if len(ranges) == 1 {
+			// A single satisfiable range is answered like a single-range request.
+			if gr, err = getObjectNInfo(ctx, bucket, object, rs, r.Header, readLock, opts); err == nil && gr.ObjInfo.ETag != storedETag {
+				gr.Close()
+				err = errObjectChangedDuringRead
+			}
+			if err != nil {
+				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+				return
+			}
+			defer gr.Close()
+		}
+	}
+
 	if err = setObjectHeaders(w, objInfo, rs); err != nil {
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
@@ -402,25 +478,44 @@ func (api objectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 
 	setHeadGetRespHeaders(w, r.URL.Query())
 
-	statusCodeWritten := false
-	httpWriter := ioutil.WriteOnClose(w)
-	if rs != nil {
-		statusCodeWritten = true
-		w.WriteHeader(http.StatusPartialContent)
-	}
-	// Write object content to response body
-	if _, err = io.Copy(httpWriter, gr); err != nil {
-		if !httpWriter.HasWritten() && !statusCodeWritten { // write error response only if no data or headers has been written to client yet
-			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+	if len(ranges) > 1 {
+		// Each range is opened separately, and closed before the next one is opened.
+		if err = writeByteRanges(w, objInfo, ranges, func(rs *HTTPRangeSpec) (*GetObjectReader, error) {
+			rgr, err := getObjectNInfo(ctx, bucket, object, rs, r.Header, readLock, opts)
+			if err != nil {
+				return nil, err
+			}
+			if rgr.ObjInfo.ETag != storedETag {
+				rgr.Close()
+				return nil, errObjectChangedDuringRead
+			}
+			return rgr, nil
+		}); err != nil {
+			// The status code was already written.
+			logger.LogIf(ctx, err)
+			return
 		}
-		return
-	}
-
-	if err = httpWriter.Close(); err != nil {
-		if !httpWriter.HasWritten() && !statusCodeWritten { // write error response only if no data or headers has been written to client yet
-			writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+	} else {
+		statusCodeWritten := false
+		httpWriter := ioutil.WriteOnClose(w)
+		if rs != nil {
+			statusCodeWritten = true
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		// Write object content to response body
+		if _, err = io.Copy(httpWriter, gr); err != nil {
+			if !httpWriter.HasWritten() && !statusCodeWritten { // write error response only if no data or headers has been written to client yet
+				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+			}
 			return
 		}
+
+		if err = httpWriter.Close(); err != nil {
+			if !httpWriter.HasWritten() && !statusCodeWritten { // write error response only if no data or headers has been written to client yet
+				writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
+				return
+			}
+		}
 	}
 
 	// Notify object accessed via a GET request.
@@ -511,6 +606,12 @@ func (api objectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Response header overrides are only allowed for signed requests.
+	if getRequestAuthType(r) == authTypeAnonymous && hasHeadGetRespParams(r.URL.Query()) {
+		writeErrorResponseHeadersOnly(w, errorCodes.ToAPIErr(ErrAnonymousResponseHeaders))
+		return
+	}
+
 	// Get request range.
 	var rs *HTTPRangeSpec
 	rangeHeader := r.Header.Get("Range")
@@ -726,6 +827,12 @@ func (api objectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objectAPI, dstBucket, r); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	// TODO: Reject requests where body/payload is present, for now we don't even read it.
 
 	// Read escaped copy source path to check for parameters.
@@ -1257,6 +1364,12 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objectAPI, bucket, r); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	switch rAuthType {
 	case authTypeStreamingSigned:
 		// Initialize stream signature verifier.
@@ -1273,7 +1386,7 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 
 	case authTypePresigned, authTypeSigned:
-		if s3Err = reqSignatureV4Verify(r, globalServerRegion, serviceS3); s3Err != ErrNone {
+		if s3Err = reqSignatureV4Verify(r, authRegion(), serviceS3); s3Err != ErrNone {
 			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Err), r.URL, guessIsBrowserReq(r))
 			return
 		}
@@ -1282,6 +1395,18 @@ func (api objectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	// Verify the checksum sent by the client while the object is stored,
+	// and store it so it is returned with the object.
+	checksum, s3Err := extractChecksum(r.Header)
+	if s3Err != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	if checksum != nil {
+		reader = newChecksumReader(reader, *checksum)
+		metadata[checksum.key()] = checksum.Value
+	}
+
 	// Check if bucket encryption is enabled
 	_, encEnabled := globalBucketSSEConfigSys.Get(bucket)
 	// This request header needs to be set prior to setting ObjectOptions
@@ -1455,6 +1580,13 @@ func (api objectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
 		return
 	}
+
+	// Reject ACLs that grant public access if public ACLs are blocked.
+	if s3Error := checkPublicACL(ctx, objectAPI, bucket, r); s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+
 	// Check if bucket encryption is enabled
 	_, encEnabled := globalBucketSSEConfigSys.Get(bucket)
 	// This request header needs to be set prior to setting ObjectOptions
@@ -1528,6 +1660,17 @@ func (api objectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		metadata[ReservedMetadataPrefix+"compression"] = compressionAlgorithmV2
 	}
 
+	// Store the checksum algorithm of the parts with the upload, so the
+	// parts are verified and the object checksum computed on completion.
+	checksumAlgorithm, s3Err := extractChecksumAlgorithm(r.Header)
+	if s3Err != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Err), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	if checksumAlgorithm != "" {
+		metadata[strings.ToLower(xhttp.AmzChecksumAlgorithm)] = checksumAlgorithm
+	}
+
 	opts, err = putOpts(ctx, r, bucket, object, metadata)
 	if err != nil {
 		writeErrorResponseHeadersOnly(w, toAPIError(ctx, err))
@@ -1541,6 +1684,11 @@ func (api objectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		return
 	}
 
+	if checksumAlgorithm != "" {
+		w.Header().Set(xhttp.AmzChecksumAlgorithm, strings.ToUpper(checksumAlgorithm))
+		w.Header().Set(xhttp.AmzChecksumType, "COMPOSITE")
+	}
+
 	response := generateInitiateMultipartUploadResponse(bucket, object, uploadID)
 	encodedSuccessResponse := encodeResponse(response)
 
@@ -1982,7 +2130,7 @@ func (api objectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 			return
 		}
 	case authTypePresigned, authTypeSigned:
-		if s3Error = reqSignatureV4Verify(r, globalServerRegion, serviceS3); s3Error != ErrNone {
+		if s3Error = reqSignatureV4Verify(r, authRegion(), serviceS3); s3Error != ErrNone {
 			writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
 			return
 		}
@@ -2009,6 +2157,23 @@ func (api objectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
+	// Verify the checksum sent by the client while the part is stored. Parts
+	// of uploads with a checksum algorithm must send a checksum of it, which
+	// is stored with the part for the checksum of the completed object.
+	checksum, s3Error := extractChecksum(r.Header)
+	if s3Error != ErrNone {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(s3Error), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	checksumAlgorithm := li.UserDefined[strings.ToLower(xhttp.AmzChecksumAlgorithm)]
+	if checksumAlgorithm != "" && (checksum == nil || checksum.Algorithm != checksumAlgorithm) {
+		writeErrorResponse(ctx, w, errorCodes.ToAPIErr(ErrMissingPartChecksum), r.URL, guessIsBrowserReq(r))
+		return
+	}
+	if checksum != nil {
+		reader = newChecksumReader(reader, *checksum)
+	}
+
 	// Read compression metadata preserved in the init multipart for the decision.
 	_, compressPart := li.UserDefined[ReservedMetadataPrefix+"compression"]
 
@@ -2113,6 +2278,13 @@ func (api objectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 		}
 	}
 
+	if checksumAlgorithm != "" {
+		if opts.UserDefined == nil {
+			opts.UserDefined = make(map[string]string)
+		}
+		opts.UserDefined[checksum.key()] = checksum.Value
+	}
+
 	putObjectPart := objectAPI.PutObjectPart
 
 	partInfo, err := putObjectPart(ctx, bucket, object, uploadID, partID, pReader, opts)
@@ -2121,6 +2293,9 @@ func (api objectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 		writeErrorResponse(ctx, w, toAPIError(ctx, err), r.URL, guessIsBrowserReq(r))
 		return
 	}
+	if checksum != nil {
+		w.Header().Set(checksum.key(), checksum.Value)
+	}
 
 	etag := partInfo.ETag
 	if isEncrypted {
@@ -2453,7 +2628,7 @@ func (api objectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 
 		// Generate error response.
 		errorResponse := getAPIErrorResponse(ctx, err, reqURL.Path,
-			w.Header().Get(xhttp.AmzRequestID), globalDeploymentID)
+			w.Header().Get(xhttp.AmzRequestID), w.Header().Get(xhttp.AmzRequestHostID))
 		encodedErrorResponse, _ := xml.Marshal(errorResponse)
 		setCommonHeaders(w)
 		w.Header().Set(xhttp.ContentType, string(mimeXML))
@@ -2479,7 +2654,7 @@ func (api objectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 	// Get object location.
 	location := getObjectLocation(r, globalDomainNames, bucket, object)
 	// Generate complete multipart response.
-	response := generateCompleteMultpartUploadResponse(bucket, object, location, objInfo.ETag)
+	response := generateCompleteMultpartUploadResponse(bucket, object, location, objInfo.ETag, objInfo.UserDefined)
 	var encodedSuccessResponse []byte
 	if !headerWritten {
 		encodedSuccessResponse = encodeResponse(response)
@@ -35,6 +35,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/RTradeLtd/s3x/cmd/crypto"
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
@@ -201,6 +202,29 @@ func testAPIHeadObjectHandler(obj ObjectLayer, instanceType, bucketName string,
 	ExecObjectLayerAPINilTest(t, nilBucket, nilObject, instanceType, apiRouter, nilReq)
 }
 
+// Tests detection of response header overrides, which are rejected for anonymous requests.
+func TestHasHeadGetRespParams(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected bool
+	}{
+		{"", false},
+		{"versionId=null", false},
+		{"response-content-type=text%2Fplain", true},
+		{"response-cache-control=no-store&partNumber=1", true},
+		{"response-unknown=1", false},
+	}
+	for i, testCase := range testCases {
+		values, err := url.ParseQuery(testCase.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasHeadGetRespParams(values); got != testCase.expected {
+			t.Errorf("Test %d: expected %v, got %v", i+1, testCase.expected, got)
+		}
+	}
+}
+
 func TestAPIHeadObjectHandlerWithEncryption(t *testing.T) {
 	globalPolicySys = NewPolicySys()
 	defer func() { globalPolicySys = nil }()
@@ -2659,7 +2683,7 @@ func testAPICompleteMultipartHandler(obj ObjectLayer, instanceType, bucketName s
 	s3MD5 := getCompleteMultipartMD5(inputParts[3].parts)
 
 	// generating the response body content for the success case.
-	successResponse := generateCompleteMultpartUploadResponse(bucketName, objectName, getGetObjectURL("", bucketName, objectName), s3MD5)
+	successResponse := generateCompleteMultpartUploadResponse(bucketName, objectName, getGetObjectURL("", bucketName, objectName), s3MD5, nil)
 	encodedSuccessResponse := encodeResponse(successResponse)
 
 	ctx := context.Background()
@@ -3930,3 +3954,134 @@ func testAPIListObjectPartsHandler(obj ObjectLayer, instanceType, bucketName str
 	// `ExecObjectLayerAPINilTest` sets the Object Layer to `nil` and calls the handler.
 	ExecObjectLayerAPINilTest(t, nilBucket, nilObject, instanceType, apiRouter, nilReq)
 }
+
+// rangeRecorderObjects records the ranges of the objects it opens
+type rangeRecorderObjects struct {
+	ObjectLayer
+	mu     sync.Mutex
+	ranges []*HTTPRangeSpec
+}
+
+func (o *rangeRecorderObjects) GetObjectNInfo(ctx context.Context, bucket, object string, rs *HTTPRangeSpec, h http.Header, lockType LockType, opts ObjectOptions) (*GetObjectReader, error) {
+	o.mu.Lock()
+	o.ranges = append(o.ranges, rs)
+	o.mu.Unlock()
+	return o.ObjectLayer.GetObjectNInfo(ctx, bucket, object, rs, h, lockType, opts)
+}
+
+func TestGetObjectMultiRangeHandler(t *testing.T) {
+	recorder := &rangeRecorderObjects{}
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		recorder.ObjectLayer = objLayer
+		return recorder
+	})
+	defer tb.TearDown()
+	ctx := context.Background()
+	if err := tb.objLayer.MakeBucketWithLocation(ctx, "bucket", BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("0123456789")
+	if _, err := tb.objLayer.PutObject(ctx, "bucket", "object", mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cred := globalActiveCred
+	testCases := []struct {
+		rangeHeader string
+		opened      int
+		contentType string
+		body        string
+	}{
+		{"bytes=0-1,7-9", 2, "multipart/byteranges", ""},
+		// a single satisfiable range is answered like a single-range request
+		{"bytes=0-1,20-", 1, "", "01"},
+	}
+	for i, test := range testCases {
+		recorder.ranges = nil
+		req, err := newTestSignedRequestV4(http.MethodGet, getGetObjectURL("", "bucket", "object"), 0, nil,
+			cred.AccessKey, cred.SecretKey, map[string]string{"Range": test.rangeHeader})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		tb.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("Test %d: expected status %d, but got %d: %s", i+1, http.StatusPartialContent, rec.Code, rec.Body.String())
+		}
+		if test.contentType != "" && !strings.HasPrefix(rec.Header().Get("Content-Type"), test.contentType) {
+			t.Errorf("Test %d: expected %s, but got %s", i+1, test.contentType, rec.Header().Get("Content-Type"))
+		}
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("Test %d: expected %q, but got %q", i+1, test.body, rec.Body.String())
+		}
+		// the info of the object is read without opening it
+		if len(recorder.ranges) != test.opened {
+			t.Errorf("Test %d: expected the object to be opened %d times, but it was opened %d times", i+1, test.opened, len(recorder.ranges))
+		}
+		for _, rs := range recorder.ranges {
+			if rs == nil {
+				t.Errorf("Test %d: expected only the ranges of the object to be opened, but the whole object was opened", i+1)
+			}
+		}
+	}
+}
+
+// bulkSlotObjects holds one slot while an object is open, like a gateway serving one bulk transfer at a time
+type bulkSlotObjects struct {
+	ObjectLayer
+	slot chan struct{}
+}
+
+func (o bulkSlotObjects) GetObjectNInfo(ctx context.Context, bucket, object string, rs *HTTPRangeSpec, h http.Header, lockType LockType, opts ObjectOptions) (*GetObjectReader, error) {
+	select {
+	case o.slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	gr, err := o.ObjectLayer.GetObjectNInfo(ctx, bucket, object, rs, h, lockType, opts)
+	if err != nil {
+		<-o.slot
+		return nil, err
+	}
+	return NewGetObjectReaderFromReader(gr, gr.ObjInfo, nil, func() {
+		gr.Close()
+		<-o.slot
+	})
+}
+
+func TestGetObjectMultiRangeBulkSlotHandler(t *testing.T) {
+	objects := bulkSlotObjects{slot: make(chan struct{}, 1)}
+	tb := prepareGatewayTestBed(t, func(objLayer ObjectLayer) ObjectLayer {
+		objects.ObjectLayer = objLayer
+		return objects
+	})
+	defer tb.TearDown()
+	ctx := context.Background()
+	if err := tb.objLayer.MakeBucketWithLocation(ctx, "bucket", BucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("0123456789")
+	if _, err := tb.objLayer.PutObject(ctx, "bucket", "object", mustGetPutObjReader(t, bytes.NewReader(data), int64(len(data)), "", ""), ObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cred := globalActiveCred
+	req, err := newTestSignedRequestV4(http.MethodGet, getGetObjectURL("", "bucket", "object"), 0, nil,
+		cred.AccessKey, cred.SecretKey, map[string]string{"Range": "bytes=0-1,4-5,7-9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	tb.router.ServeHTTP(rec, req.WithContext(ctx))
+	if ctx.Err() != nil {
+		t.Fatal("expected the ranges to be read one at a time with a single bulk slot, but the request waited for a slot it held")
+	}
+	if rec.Code != http.StatusPartialContent || !strings.HasPrefix(rec.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Fatalf("expected a multipart/byteranges response, but got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if len(objects.slot) != 0 {
+		t.Fatal("expected the bulk slot to be released")
+	}
+}
@@ -73,6 +73,11 @@ func (sys *PolicySys) IsAllowed(args policy.Args) bool {
 		if objAPI != nil {
 			config, err := objAPI.GetBucketPolicy(context.Background(), args.BucketName)
 			if err == nil {
+				// Bucket policies do not grant access to anyone but the owner
+				// if public buckets are restricted.
+				if publicAccessBlock(context.Background(), objAPI, args.BucketName).RestrictPublicBuckets {
+					return args.IsOwner
+				}
 				return config.IsAllowed(args)
 			}
 		}
@@ -152,7 +157,7 @@ func getConditionValues(request *http.Request, locationConstraint string, userna
 		return "Anonymous"
 	}()
 	args := map[string][]string{
-		"CurrenTime":      {currTime.Format(event.AMZTimeFormat)},
+		"CurrentTime":     {currTime.Format(event.AMZTimeFormat)},
 		"EpochTime":       {fmt.Sprintf("%d", currTime.Unix())},
 		"principaltype":   {principalType},
 		"SecureTransport": {fmt.Sprintf("%t", request.TLS != nil)},
@@ -39,7 +39,7 @@ func registerDistXLRouters(router *mux.Router, endpointZones EndpointZones) {
 
 // List of some generic handlers which are applied for all incoming requests.
 var globalHandlers = []HandlerFunc{
-	// set x-amz-request-id header.
+	// set x-amz-request-id and x-amz-id-2 headers.
 	addCustomHeaders,
 	// set HTTP security headers such as Content-Security-Policy.
 	addSecurityHeaders,
@@ -68,6 +68,11 @@ var globalHandlers = []HandlerFunc{
 	// Validates all incoming URL resources, for invalid/unsupported
 	// resources client receives a HTTP error.
 	setIgnoreResourcesHandler,
+	// Redirects requests to buckets of other regions to the gateways of their regions.
+	setBucketRegionHandler,
+	// Sets the response headers configured for buckets, and denies requests
+	// without the request headers required by the configuration.
+	setBucketHeadersHandler,
 	// Auth handler verifies incoming authorization headers and
 	// routes them accordingly. Client receives a HTTP error for
 	// invalid/unsupported signatures.
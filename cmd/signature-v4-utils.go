@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	xhttp "github.com/RTradeLtd/s3x/cmd/http"
 	"github.com/RTradeLtd/s3x/cmd/logger"
@@ -119,6 +120,30 @@ func isValidRegion(reqRegion string, confRegion string) bool {
 	return reqRegion == confRegion
 }
 
+// maxSkewTime - returns the maximum allowed time difference between the date of a
+// signed request and the server date, as configured by the object layer if it does.
+func maxSkewTime() time.Duration {
+	if c, ok := unwrapObjectLayer(newObjectLayerFn()).(RequestAuthConfigurer); ok && c.MaxClockSkew() > 0 {
+		return c.MaxClockSkew()
+	}
+	return globalMaxSkewTime
+}
+
+// authRegion - returns the region that signed requests must be scoped to, the region
+// configured by the object layer if it does, or the server region.
+func authRegion() string {
+	if c, ok := unwrapObjectLayer(newObjectLayerFn()).(RequestAuthConfigurer); ok && c.AuthRegion() != "" {
+		return c.AuthRegion()
+	}
+	return globalServerRegion
+}
+
+// isSignatureV2Allowed - returns false if the object layer denies requests signed with signature version 2.
+func isSignatureV2Allowed() bool {
+	c, ok := unwrapObjectLayer(newObjectLayerFn()).(RequestAuthConfigurer)
+	return !ok || !c.RequireSignatureV4()
+}
+
 // check if the access key is valid and recognized, additionally
 // also returns if the access key is owner/admin.
 func checkKeyValid(accessKey string) (auth.Credentials, bool, APIErrorCode) {
@@ -151,9 +151,12 @@ func getSignature(signingKey []byte, stringToSign string) string {
 func doesPolicySignatureMatch(formValues http.Header) APIErrorCode {
 	// For SignV2 - Signature field will be valid
 	if _, ok := formValues["Signature"]; ok {
-		return doesPolicySignatureV2Match(formValues)
+		if !isSignatureV2Allowed() {
+			return globalAuthFailureStats.record(ErrSignatureVersionNotSupported)
+		}
+		return globalAuthFailureStats.record(doesPolicySignatureV2Match(formValues))
 	}
-	return doesPolicySignatureV4Match(formValues)
+	return globalAuthFailureStats.record(doesPolicySignatureV4Match(formValues))
 }
 
 // compareSignatureV4 returns true if and only if both signatures
@@ -170,7 +173,7 @@ func compareSignatureV4(sig1, sig2 string) bool {
 // returns ErrNone if the signature matches.
 func doesPolicySignatureV4Match(formValues http.Header) APIErrorCode {
 	// Server region.
-	region := globalServerRegion
+	region := authRegion()
 
 	// Parse credential tag.
 	credHeader, err := parseCredentialHeader("Credential="+formValues.Get(xhttp.AmzCredential), region, serviceS3)
@@ -222,9 +225,9 @@ func doesPresignedSignatureMatch(hashedPayload string, r *http.Request, region s
 		return errCode
 	}
 
-	// If the host which signed the request is slightly ahead in time (by less than globalMaxSkewTime) the
+	// If the host which signed the request is slightly ahead in time (by less than maxSkewTime()) the
 	// request should still be allowed.
-	if pSignValues.Date.After(UTCNow().Add(globalMaxSkewTime)) {
+	if pSignValues.Date.After(UTCNow().Add(maxSkewTime())) {
 		return ErrRequestNotReadyYet
 	}
 
@@ -73,7 +73,7 @@ func calculateSeedSignature(r *http.Request) (cred auth.Credentials, signature s
 	v4Auth := req.Header.Get(xhttp.Authorization)
 
 	// Parse signature version '4' header.
-	signV4Values, errCode := parseSignV4(v4Auth, globalServerRegion, serviceS3)
+	signV4Values, errCode := parseSignV4(v4Auth, authRegion(), serviceS3)
 	if errCode != ErrNone {
 		return cred, "", "", time.Time{}, errCode
 	}
@@ -156,7 +156,7 @@ var errMalformedEncoding = errors.New("malformed chunked encoding")
 func newSignV4ChunkedReader(req *http.Request) (io.ReadCloser, APIErrorCode) {
 	cred, seedSignature, region, seedDate, errCode := calculateSeedSignature(req)
 	if errCode != ErrNone {
-		return nil, errCode
+		return nil, globalAuthFailureStats.record(errCode)
 	}
 
 	return &s3ChunkedReader{
@@ -117,12 +117,12 @@ func checkAssumeRoleAuth(ctx context.Context, r *http.Request) (user auth.Creden
 	default:
 		return user, ErrSTSAccessDenied
 	case authTypeSigned:
-		s3Err := isReqAuthenticated(ctx, r, globalServerRegion, serviceSTS)
+		s3Err := isReqAuthenticated(ctx, r, authRegion(), serviceSTS)
 		if STSErrorCode(s3Err) != ErrSTSNone {
 			return user, STSErrorCode(s3Err)
 		}
 		var owner bool
-		user, owner, s3Err = getReqAccessKeyV4(r, globalServerRegion, serviceSTS)
+		user, owner, s3Err = getReqAccessKeyV4(r, authRegion(), serviceSTS)
 		if STSErrorCode(s3Err) != ErrSTSNone {
 			return user, STSErrorCode(s3Err)
 		}
@@ -1651,7 +1651,7 @@ func initAPIHandlerTest(obj ObjectLayer, endpoints []string) (string, http.Handl
 	bucketName := getRandomBucketName()
 
 	// Create bucket.
-	err := obj.MakeBucketWithLocation(context.Background(), bucketName, "")
+	err := obj.MakeBucketWithLocation(context.Background(), bucketName, BucketOptions{})
 	if err != nil {
 		// failed to create newbucket, return err.
 		return "", nil, err
@@ -2420,7 +2420,7 @@ func uploadTestObject(t *testing.T, apiRouter http.Handler, creds auth.Credentia
 			if etag == "" {
 				t.Fatalf("Unexpected empty etag")
 			}
-			cp = append(cp, CompletePart{partID, etag[1 : len(etag)-1]})
+			cp = append(cp, CompletePart{PartNumber: partID, ETag: etag[1 : len(etag)-1]})
 		}
 
 		// Call CompleteMultipart API
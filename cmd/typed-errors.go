@@ -57,6 +57,9 @@ var errInvalidBucketName = errors.New("The specified bucket is not valid")
 // errInvalidRange - returned when given range value is not valid.
 var errInvalidRange = errors.New("Invalid range")
 
+// errObjectChangedDuringRead - object was overwritten while its ranges were read.
+var errObjectChangedDuringRead = errors.New("Object changed while its ranges were read")
+
 // errInvalidRangeSource - returned when given range value exceeds
 // the source object size.
 var errInvalidRangeSource = errors.New("Range specified exceeds source object size")
@@ -379,6 +379,7 @@ var globalProfilerMu sync.Mutex
 func dumpRequest(r *http.Request) string {
 	header := r.Header.Clone()
 	header.Set("Host", r.Host)
+	header.Del(xhttp.S3xBreakGlass)
 	// Replace all '%' to '%%' so that printer format parser
 	// to ignore URL encoded values.
 	rawURI := strings.Replace(r.RequestURI, "%", "%%", -1)
@@ -542,16 +543,25 @@ func newContext(r *http.Request, w http.ResponseWriter, api string) context.Cont
 		object = prefix
 	}
 	reqInfo := &logger.ReqInfo{
-		DeploymentID: globalDeploymentID,
-		RequestID:    w.Header().Get(xhttp.AmzRequestID),
-		RemoteHost:   handlers.GetSourceIP(r),
-		Host:         getHostName(r),
-		UserAgent:    r.UserAgent(),
-		API:          api,
-		BucketName:   bucket,
-		ObjectName:   object,
-	}
-	return logger.SetReqInfo(r.Context(), reqInfo)
+		DeploymentID:  globalDeploymentID,
+		RequestID:     w.Header().Get(xhttp.AmzRequestID),
+		RequestHostID: w.Header().Get(xhttp.AmzRequestHostID),
+		RemoteHost:    handlers.GetSourceIP(r),
+		Host:          getHostName(r),
+		UserAgent:     r.UserAgent(),
+		API:           api,
+		BucketName:    bucket,
+		ObjectName:    object,
+		AccessKey:     getReqAccessCred(r, globalServerRegion).AccessKey,
+	}
+	if traceParent := r.Header.Get(xhttp.TraceParent); traceParent != "" {
+		reqInfo.AppendTags(xhttp.TraceParent, traceParent)
+	}
+	ctx := logger.SetReqInfo(r.Context(), reqInfo)
+	if r.Header.Get(xhttp.S3xDebugErrors) != "" {
+		ctx = withErrorDetails(ctx, w.Header())
+	}
+	return ctx
 }
 
 // Used for registering with rest handlers (have a look at registerStorageRESTHandlers for usage example)
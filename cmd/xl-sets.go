@@ -473,14 +473,14 @@ func (s *xlSets) Shutdown(ctx context.Context) error {
 // MakeBucketLocation - creates a new bucket across all sets simultaneously
 // even if one of the sets fail to create buckets, we proceed to undo a
 // successful operation.
-func (s *xlSets) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (s *xlSets) MakeBucketWithLocation(ctx context.Context, bucket string, opts BucketOptions) error {
 	g := errgroup.WithNErrs(len(s.sets))
 
 	// Create buckets in parallel across all sets.
 	for index := range s.sets {
 		index := index
 		g.Go(func() error {
-			return s.sets[index].MakeBucketWithLocation(ctx, bucket, location)
+			return s.sets[index].MakeBucketWithLocation(ctx, bucket, opts)
 		}, index)
 	}
 
@@ -684,7 +684,7 @@ func undoDeleteBucketSets(bucket string, sets []*xlObjects, errs []error) {
 		index := index
 		g.Go(func() error {
 			if errs[index] == nil {
-				return sets[index].MakeBucketWithLocation(context.Background(), bucket, "")
+				return sets[index].MakeBucketWithLocation(context.Background(), bucket, BucketOptions{})
 			}
 			return nil
 		}, index)
@@ -1607,7 +1607,7 @@ func (s *xlSets) ListBucketsHeal(ctx context.Context) ([]BucketInfo, error) {
 			return nil, err
 		}
 		for _, currBucket := range buckets {
-			healBuckets[currBucket.Name] = BucketInfo(currBucket)
+			healBuckets[currBucket.Name] = BucketInfo{Name: currBucket.Name, Created: currBucket.Created}
 		}
 	}
 	for _, bucketInfo := range healBuckets {
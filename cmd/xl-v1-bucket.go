@@ -38,7 +38,7 @@ var bucketMetadataOpIgnoredErrs = append(bucketOpIgnoredErrs, errVolumeNotFound)
 /// Bucket operations
 
 // MakeBucket - make a bucket.
-func (xl xlObjects) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (xl xlObjects) MakeBucketWithLocation(ctx context.Context, bucket string, opts BucketOptions) error {
 	// Verify if bucket is valid.
 	if err := s3utils.CheckValidBucketNameStrict(bucket); err != nil {
 		return BucketNameInvalid{Bucket: bucket}
@@ -121,7 +121,7 @@ func (xl xlObjects) getBucketInfo(ctx context.Context, bucketName string) (bucke
 		}
 		volInfo, serr := disk.StatVol(bucketName)
 		if serr == nil {
-			return BucketInfo(volInfo), nil
+			return BucketInfo{Name: volInfo.Name, Created: volInfo.Created}, nil
 		}
 		err = serr
 		// For any reason disk went offline continue and pick the next one.
@@ -167,7 +167,7 @@ func (xl xlObjects) listBuckets(ctx context.Context) (bucketsInfo []BucketInfo,
 				if isReservedOrInvalidBucket(volInfo.Name, true) {
 					continue
 				}
-				bucketsInfo = append(bucketsInfo, BucketInfo(volInfo))
+				bucketsInfo = append(bucketsInfo, BucketInfo{Name: volInfo.Name, Created: volInfo.Created})
 			}
 			// For buckets info empty, loop once again to check
 			// if we have, can happen if disks were down.
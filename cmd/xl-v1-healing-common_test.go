@@ -178,7 +178,7 @@ func TestListOnlineDisks(t *testing.T) {
 		obj.DeleteObject(context.Background(), bucket, object)
 		obj.DeleteBucket(context.Background(), bucket)
 
-		err = obj.MakeBucketWithLocation(context.Background(), "bucket", "")
+		err = obj.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 		if err != nil {
 			t.Fatalf("Failed to make a bucket %v", err)
 		}
@@ -272,7 +272,7 @@ func TestDisksWithAllParts(t *testing.T) {
 	z := obj.(*xlZones)
 	xl := z.zones[0].sets[0]
 	xlDisks := xl.getDisks()
-	err = obj.MakeBucketWithLocation(ctx, "bucket", "")
+	err = obj.MakeBucketWithLocation(ctx, "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatalf("Failed to make a bucket %v", err)
 	}
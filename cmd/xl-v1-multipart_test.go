@@ -40,7 +40,7 @@ func TestXLCleanupStaleMultipartUploads(t *testing.T) {
 	objectName := "object"
 	var opts ObjectOptions
 
-	obj.MakeBucketWithLocation(context.Background(), bucketName, "")
+	obj.MakeBucketWithLocation(context.Background(), bucketName, BucketOptions{})
 	uploadID, err := obj.NewMultipartUpload(context.Background(), bucketName, objectName, opts)
 	if err != nil {
 		t.Fatal("Unexpected err: ", err)
@@ -46,7 +46,7 @@ func TestRepeatPutObjectPart(t *testing.T) {
 	// cleaning up of temporary test directories
 	defer removeRoots(disks)
 
-	err = objLayer.MakeBucketWithLocation(context.Background(), "bucket1", "")
+	err = objLayer.MakeBucketWithLocation(context.Background(), "bucket1", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,7 +90,7 @@ func TestXLDeleteObjectBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = xl.MakeBucketWithLocation(context.Background(), "bucket", "")
+	err = xl.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,7 +145,7 @@ func TestXLDeleteObjectsXLSet(t *testing.T) {
 		{bucketName, "obj_4"},
 	}
 
-	err := xlSets.MakeBucketWithLocation(context.Background(), bucketName, "")
+	err := xlSets.MakeBucketWithLocation(context.Background(), bucketName, BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -201,7 +201,7 @@ func TestXLDeleteObjectDiskNotFound(t *testing.T) {
 	xl := z.zones[0].sets[0]
 
 	// Create "bucket"
-	err = obj.MakeBucketWithLocation(context.Background(), "bucket", "")
+	err = obj.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -265,7 +265,7 @@ func TestGetObjectNoQuorum(t *testing.T) {
 	xl := z.zones[0].sets[0]
 
 	// Create "bucket"
-	err = obj.MakeBucketWithLocation(context.Background(), "bucket", "")
+	err = obj.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -324,7 +324,7 @@ func TestPutObjectNoQuorum(t *testing.T) {
 	xl := z.zones[0].sets[0]
 
 	// Create "bucket"
-	err = obj.MakeBucketWithLocation(context.Background(), "bucket", "")
+	err = obj.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -381,7 +381,7 @@ func TestHealing(t *testing.T) {
 	xl := z.zones[0].sets[0]
 
 	// Create "bucket"
-	err = obj.MakeBucketWithLocation(context.Background(), "bucket", "")
+	err = obj.MakeBucketWithLocation(context.Background(), "bucket", BucketOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -492,7 +492,7 @@ func testObjectQuorumFromMeta(obj ObjectLayer, instanceType string, dirs []strin
 	xl := z.zones[0].sets[0]
 	xlDisks := xl.getDisks()
 
-	err := obj.MakeBucketWithLocation(context.Background(), bucket, globalMinioDefaultRegion)
+	err := obj.MakeBucketWithLocation(context.Background(), bucket, BucketOptions{Location: globalMinioDefaultRegion})
 	if err != nil {
 		t.Fatalf("Failed to make a bucket %v", err)
 	}
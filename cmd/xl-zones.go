@@ -50,7 +50,7 @@ func (z *xlZones) quickHealBuckets(ctx context.Context) {
 		return
 	}
 	for _, bucket := range bucketsInfo {
-		z.MakeBucketWithLocation(ctx, bucket.Name, "")
+		z.MakeBucketWithLocation(ctx, bucket.Name, BucketOptions{})
 	}
 }
 
@@ -338,9 +338,9 @@ func undoMakeBucketZones(bucket string, zones []*xlSets, errs []error) {
 // MakeBucketWithLocation - creates a new bucket across all zones simultaneously
 // even if one of the sets fail to create buckets, we proceed all the successful
 // operations.
-func (z *xlZones) MakeBucketWithLocation(ctx context.Context, bucket, location string) error {
+func (z *xlZones) MakeBucketWithLocation(ctx context.Context, bucket string, opts BucketOptions) error {
 	if z.SingleZone() {
-		return z.zones[0].MakeBucketWithLocation(ctx, bucket, location)
+		return z.zones[0].MakeBucketWithLocation(ctx, bucket, opts)
 	}
 
 	g := errgroup.WithNErrs(len(z.zones))
@@ -349,7 +349,7 @@ func (z *xlZones) MakeBucketWithLocation(ctx context.Context, bucket, location s
 	for index := range z.zones {
 		index := index
 		g.Go(func() error {
-			return z.zones[index].MakeBucketWithLocation(ctx, bucket, location)
+			return z.zones[index].MakeBucketWithLocation(ctx, bucket, opts)
 		}, index)
 	}
 
@@ -1242,7 +1242,7 @@ func undoDeleteBucketZones(bucket string, zones []*xlSets, errs []error) {
 		index := index
 		g.Go(func() error {
 			if errs[index] == nil {
-				return zones[index].MakeBucketWithLocation(context.Background(), bucket, "")
+				return zones[index].MakeBucketWithLocation(context.Background(), bucket, BucketOptions{})
 			}
 			return nil
 		}, index)
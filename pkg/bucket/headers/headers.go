@@ -0,0 +1,178 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// AllBuckets is the bucket of rules that apply to the requests to all buckets
+const AllBuckets = "*"
+
+var errNoBucket = errors.New("Header rule must have a bucket, or * for all buckets")
+
+// reservedHeaders are the headers of the S3 protocol that rules can not set on responses,
+// the headers with the prefix x-amz- are reserved as well.
+var reservedHeaders = map[string]bool{
+	"Accept-Ranges":     true,
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Content-Range":     true,
+	"Content-Type":      true,
+	"Date":              true,
+	"Etag":              true,
+	"Host":              true,
+	"Last-Modified":     true,
+	"Location":          true,
+	"Server":            true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Vary":              true,
+}
+
+// Requirement - a header that requests must have, with one of Values if any are set
+type Requirement struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values,omitempty"`
+}
+
+// Satisfied - returns true if the header of the requirement has one of its values in h
+func (r Requirement) Satisfied(h http.Header) bool {
+	values, ok := h[r.Name]
+	if !ok {
+		return false
+	}
+	if len(r.Values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		for _, want := range r.Values {
+			if v == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rule - the headers of the requests to the objects with a prefix in a bucket
+type Rule struct {
+	// Bucket is the name of the bucket, or * for all buckets
+	Bucket string `json:"bucket"`
+	// Prefix selects the objects of the requests, all requests to the bucket if it is empty
+	Prefix string `json:"prefix,omitempty"`
+	// ResponseHeaders are set on the responses to the requests
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	// RequiredRequestHeaders must be sent by the requests, or they are denied
+	RequiredRequestHeaders []Requirement `json:"requiredRequestHeaders,omitempty"`
+}
+
+// Match - returns true if the rule applies to a request for an object of a bucket
+func (r Rule) Match(bucket, object string) bool {
+	return (r.Bucket == AllBuckets || r.Bucket == bucket) && strings.HasPrefix(object, r.Prefix)
+}
+
+// Validate - validates the rule, and canonicalizes the names of its headers
+func (r *Rule) Validate() error {
+	if r.Bucket == "" {
+		return errNoBucket
+	}
+	if r.Prefix != "" && r.Bucket == AllBuckets {
+		return fmt.Errorf("Header rule of all buckets can not have a prefix, got %q", r.Prefix)
+	}
+	if len(r.ResponseHeaders) == 0 && len(r.RequiredRequestHeaders) == 0 {
+		return fmt.Errorf("Header rule of bucket %q has no response or required request headers", r.Bucket)
+	}
+	response := make(map[string]string, len(r.ResponseHeaders))
+	for name, value := range r.ResponseHeaders {
+		canonical := http.CanonicalHeaderKey(name)
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("Invalid response header %q: %q", name, value)
+		}
+		if reservedHeaders[canonical] || strings.HasPrefix(canonical, "X-Amz-") {
+			return fmt.Errorf("Response header %q is reserved by the S3 protocol", name)
+		}
+		if _, ok := response[canonical]; ok {
+			return fmt.Errorf("Response header %q is repeated", name)
+		}
+		response[canonical] = value
+	}
+	r.ResponseHeaders = response
+	for i, req := range r.RequiredRequestHeaders {
+		if !httpguts.ValidHeaderFieldName(req.Name) {
+			return fmt.Errorf("Invalid required request header %q", req.Name)
+		}
+		r.RequiredRequestHeaders[i].Name = http.CanonicalHeaderKey(req.Name)
+	}
+	return nil
+}
+
+// Config - the header rules of buckets, the rules of all buckets apply before the rules of a bucket,
+// so the response headers of a bucket replace those of all buckets, and the required request headers
+// of all rules that apply must be sent
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParseConfig - parses and validates a header configuration in json
+func ParseConfig(reader io.Reader) (*Config, error) {
+	var config Config
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	for i := range config.Rules {
+		if err := config.Rules[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+// Apply - sets the response headers of the rules that apply to a request for an object of a bucket
+// on response, and returns the first required request header that the request did not send, if any
+func (c *Config) Apply(bucket, object string, request, response http.Header) (missing string, ok bool) {
+	if c == nil {
+		return "", true
+	}
+	for _, all := range []bool{true, false} {
+		for _, rule := range c.Rules {
+			if (rule.Bucket == AllBuckets) != all || !rule.Match(bucket, object) {
+				continue
+			}
+			for name, value := range rule.ResponseHeaders {
+				response.Set(name, value)
+			}
+			for _, req := range rule.RequiredRequestHeaders {
+				if !req.Satisfied(request) {
+					return req.Name, false
+				}
+			}
+		}
+	}
+	return "", true
+}
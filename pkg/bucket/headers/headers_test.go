@@ -0,0 +1,95 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{"rules": [
+	{"bucket": "*", "responseHeaders": {"strict-transport-security": "max-age=31536000", "X-Frame-Options": "DENY"}},
+	{"bucket": "assets", "responseHeaders": {"x-frame-options": "SAMEORIGIN", "Access-Control-Expose-Headers": "ETag"}},
+	{"bucket": "assets", "prefix": "private/", "requiredRequestHeaders": [{"name": "x-tenant", "values": ["a", "b"]}, {"name": "x-trace"}]}
+]}`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Rules[0].ResponseHeaders["Strict-Transport-Security"]; got != "max-age=31536000" {
+		t.Fatalf("expected canonical header names, got %v", config.Rules[0].ResponseHeaders)
+	}
+	if got := config.Rules[2].RequiredRequestHeaders[0].Name; got != "X-Tenant" {
+		t.Fatalf("expected a canonical required header name, got %v", got)
+	}
+	for _, invalid := range []string{
+		`{"rules": [{"responseHeaders": {"X-Frame-Options": "DENY"}}]}`,
+		`{"rules": [{"bucket": "*", "prefix": "a/", "responseHeaders": {"X-Frame-Options": "DENY"}}]}`,
+		`{"rules": [{"bucket": "assets"}]}`,
+		`{"rules": [{"bucket": "assets", "responseHeaders": {"Content-Type": "text/html"}}]}`,
+		`{"rules": [{"bucket": "assets", "responseHeaders": {"x-amz-request-id": "1"}}]}`,
+		`{"rules": [{"bucket": "assets", "responseHeaders": {"X-Bad Name": "1"}}]}`,
+		`{"rules": [{"bucket": "assets", "responseHeaders": {"X-A": "1", "x-a": "2"}}]}`,
+		`{"rules": [{"bucket": "assets", "requiredRequestHeaders": [{"name": "bad name"}]}]}`,
+		`{"rules": [{"bucket": "assets", "unknown": true}]}`,
+	} {
+		if _, err := ParseConfig(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		bucket, object string
+		request        http.Header
+		frameOptions   string
+		expose         string
+		missing        string
+	}{
+		{"other", "a", http.Header{}, "DENY", "", ""},
+		// the rules of a bucket replace the headers of all buckets
+		{"assets", "a", http.Header{}, "SAMEORIGIN", "ETag", ""},
+		{"assets", "private/a", http.Header{}, "SAMEORIGIN", "ETag", "X-Tenant"},
+		{"assets", "private/a", http.Header{"X-Tenant": {"c"}}, "SAMEORIGIN", "ETag", "X-Tenant"},
+		{"assets", "private/a", http.Header{"X-Tenant": {"b"}}, "SAMEORIGIN", "ETag", "X-Trace"},
+		{"assets", "private/a", http.Header{"X-Tenant": {"b"}, "X-Trace": {""}}, "SAMEORIGIN", "ETag", ""},
+	}
+	for i, testCase := range testCases {
+		response := http.Header{}
+		missing, ok := config.Apply(testCase.bucket, testCase.object, testCase.request, response)
+		if missing != testCase.missing || ok != (testCase.missing == "") {
+			t.Errorf("Test %d: expected missing header %q, got %q %v", i+1, testCase.missing, missing, ok)
+		}
+		if response.Get("X-Frame-Options") != testCase.frameOptions || response.Get("Access-Control-Expose-Headers") != testCase.expose {
+			t.Errorf("Test %d: unexpected response headers %v", i+1, response)
+		}
+		if response.Get("Strict-Transport-Security") == "" {
+			t.Errorf("Test %d: expected the headers of all buckets, got %v", i+1, response)
+		}
+	}
+	if _, ok := (*Config)(nil).Apply("assets", "a", http.Header{}, http.Header{}); !ok {
+		t.Fatal("expected no configuration to allow all requests")
+	}
+}
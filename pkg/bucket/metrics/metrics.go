@@ -0,0 +1,130 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	xmlNS = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	// MaxConfigs is the maximum number of metrics configurations of a bucket
+	MaxConfigs = 1000
+
+	// maxIDLength is the maximum length of the id of a metrics configuration
+	maxIDLength = 64
+)
+
+var (
+	errInvalidID        = errors.New("Metrics configuration id must be 1 to 64 characters")
+	errIDMismatch       = errors.New("Metrics configuration id does not match the id of the request")
+	errUnsupportedTag   = errors.New("Metrics configuration tag filters are not supported")
+	errUnsupportedPoint = errors.New("Metrics configuration access point filters are not supported")
+)
+
+// Tag - a tag of a metrics configuration filter
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// And - a conjunction of metrics configuration filters
+type And struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag,omitempty"`
+}
+
+// Filter - selects the objects of the requests counted by a metrics configuration,
+// all requests to the bucket are counted if the filter is empty
+type Filter struct {
+	Prefix         string `xml:"Prefix,omitempty"`
+	Tag            *Tag   `xml:"Tag,omitempty"`
+	AccessPointArn string `xml:"AccessPointArn,omitempty"`
+	And            *And   `xml:"And,omitempty"`
+}
+
+// Config - represents a metrics configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_MetricsConfiguration.html
+type Config struct {
+	XMLNS   string   `xml:"xmlns,attr,omitempty"`
+	XMLName xml.Name `xml:"MetricsConfiguration"`
+	ID      string   `xml:"Id"`
+	Filter  *Filter  `xml:"Filter,omitempty"`
+}
+
+// ListConfigsResult - the metrics configurations of a bucket
+type ListConfigsResult struct {
+	XMLNS       string   `xml:"xmlns,attr,omitempty"`
+	XMLName     xml.Name `xml:"ListMetricsConfigurationsResult"`
+	Configs     []Config `xml:"MetricsConfiguration"`
+	IsTruncated bool     `xml:"IsTruncated"`
+}
+
+// NewListConfigsResult - returns the list result of the metrics configurations of a bucket
+func NewListConfigsResult(configs []Config) ListConfigsResult {
+	for i := range configs {
+		configs[i].XMLNS = ""
+	}
+	return ListConfigsResult{XMLNS: xmlNS, Configs: configs}
+}
+
+// ParseConfig - Decodes given XML to a metrics configuration with the id of the request
+func ParseConfig(r io.Reader, id string) (*Config, error) {
+	var config Config
+	if err := xml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.ID == "" || len(config.ID) > maxIDLength {
+		return nil, errInvalidID
+	}
+	if config.ID != id {
+		return nil, errIDMismatch
+	}
+	if f := config.Filter; f != nil {
+		if f.Tag != nil || (f.And != nil && len(f.And.Tags) != 0) {
+			return nil, errUnsupportedTag
+		}
+		if f.AccessPointArn != "" {
+			return nil, errUnsupportedPoint
+		}
+	}
+	if config.XMLNS == "" {
+		config.XMLNS = xmlNS
+	}
+	return &config, nil
+}
+
+// Prefix - returns the object prefix of the requests counted by the configuration
+func (c Config) Prefix() string {
+	if c.Filter == nil {
+		return ""
+	}
+	if c.Filter.And != nil {
+		return c.Filter.And.Prefix
+	}
+	return c.Filter.Prefix
+}
+
+// Match - returns true if requests to the object are counted by the configuration,
+// requests to the bucket itself are only counted by configurations without a prefix
+func (c Config) Match(object string) bool {
+	return strings.HasPrefix(object, c.Prefix())
+}
@@ -0,0 +1,97 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// TestParseConfig performs basic sanity tests on ParseConfig
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		inputXML       string
+		id             string
+		expectedPrefix string
+		expectedErr    error
+	}{
+		// Entire bucket
+		{`<MetricsConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Id>EntireBucket</Id></MetricsConfiguration>`, "EntireBucket", "", nil},
+		// Prefix filter
+		{`<MetricsConfiguration><Id>docs</Id><Filter><Prefix>documents/</Prefix></Filter></MetricsConfiguration>`, "docs", "documents/", nil},
+		{`<MetricsConfiguration><Id>docs</Id><Filter><And><Prefix>documents/</Prefix></And></Filter></MetricsConfiguration>`, "docs", "documents/", nil},
+		// Invalid configurations
+		{`<MetricsConfiguration><Id></Id></MetricsConfiguration>`, "", "", errInvalidID},
+		{`<MetricsConfiguration><Id>` + strings.Repeat("a", 65) + `</Id></MetricsConfiguration>`, strings.Repeat("a", 65), "", errInvalidID},
+		{`<MetricsConfiguration><Id>docs</Id></MetricsConfiguration>`, "other", "", errIDMismatch},
+		{`<MetricsConfiguration><Id>docs</Id><Filter><Tag><Key>a</Key><Value>b</Value></Tag></Filter></MetricsConfiguration>`, "docs", "", errUnsupportedTag},
+		{`<MetricsConfiguration><Id>docs</Id><Filter><And><Prefix>p</Prefix><Tag><Key>a</Key><Value>b</Value></Tag></And></Filter></MetricsConfiguration>`, "docs", "", errUnsupportedTag},
+		{`<MetricsConfiguration><Id>docs</Id><Filter><AccessPointArn>arn</AccessPointArn></Filter></MetricsConfiguration>`, "docs", "", errUnsupportedPoint},
+	}
+	for i, tc := range testCases {
+		config, err := ParseConfig(strings.NewReader(tc.inputXML), tc.id)
+		if err != tc.expectedErr {
+			t.Fatalf("Test case %d: Expected error %v but got %v", i+1, tc.expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if config.XMLNS != xmlNS {
+			t.Fatalf("Test case %d: Expected namespace %s but got %s", i+1, xmlNS, config.XMLNS)
+		}
+		if config.Prefix() != tc.expectedPrefix {
+			t.Fatalf("Test case %d: Expected prefix %s but got %s", i+1, tc.expectedPrefix, config.Prefix())
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entire := Config{ID: "all"}
+	docs := Config{ID: "docs", Filter: &Filter{Prefix: "documents/"}}
+	testCases := []struct {
+		config   Config
+		object   string
+		expected bool
+	}{
+		{entire, "", true},
+		{entire, "documents/a.txt", true},
+		{docs, "documents/a.txt", true},
+		{docs, "images/a.png", false},
+		{docs, "", false},
+	}
+	for i, tc := range testCases {
+		if match := tc.config.Match(tc.object); match != tc.expected {
+			t.Fatalf("Test case %d: Expected %v but got %v", i+1, tc.expected, match)
+		}
+	}
+}
+
+func TestNewListConfigsResult(t *testing.T) {
+	result := NewListConfigsResult([]Config{{XMLNS: xmlNS, ID: "all"}, {XMLNS: xmlNS, ID: "docs", Filter: &Filter{Prefix: "documents/"}}})
+	data, err := xml.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<ListMetricsConfigurationsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<MetricsConfiguration><Id>all</Id></MetricsConfiguration>` +
+		`<MetricsConfiguration><Id>docs</Id><Filter><Prefix>documents/</Prefix></Filter></MetricsConfiguration>` +
+		`<IsTruncated>false</IsTruncated></ListMetricsConfigurationsResult>`
+	if string(data) != expected {
+		t.Fatalf("Expected %s but got %s", expected, data)
+	}
+}
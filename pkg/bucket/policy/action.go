@@ -118,6 +118,10 @@ const (
 	PutBucketEncryptionAction = "s3:PutEncryptionConfiguration"
 	// GetBucketEncryptionAction - GetBucketEncryption REST API action
 	GetBucketEncryptionAction = "s3:GetEncryptionConfiguration"
+	// PutBucketMetricsConfigurationAction - PutBucketMetricsConfiguration and DeleteBucketMetricsConfiguration REST API action
+	PutBucketMetricsConfigurationAction = "s3:PutMetricsConfiguration"
+	// GetBucketMetricsConfigurationAction - GetBucketMetricsConfiguration and ListBucketMetricsConfigurations REST API action
+	GetBucketMetricsConfigurationAction = "s3:GetMetricsConfiguration"
 )
 
 // List of all supported object actions.
@@ -179,6 +183,8 @@ var supportedActions = map[Action]struct{}{
 	DeleteObjectTaggingAction:              {},
 	PutBucketEncryptionAction:              {},
 	GetBucketEncryptionAction:              {},
+	PutBucketMetricsConfigurationAction:    {},
+	GetBucketMetricsConfigurationAction:    {},
 }
 
 // IsValid - checks if action is valid or not.
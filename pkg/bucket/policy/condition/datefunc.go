@@ -0,0 +1,149 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package condition
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// dateFunc - Date condition function. It compares the time by Key in given
+// values with a time, Key must be AWSCurrentTime or AWSEpochTime.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_elements_condition_operators.html#Conditions_Date
+// For example,
+//   - if name = DateLessThan and value = 2020-01-01T00:00:00Z, at evaluate()
+//     it returns whether the time in value map for AWSCurrentTime is
+//     before 2020-01-01T00:00:00Z.
+type dateFunc struct {
+	n     name
+	k     Key
+	value time.Time
+}
+
+// evaluate() - evaluates to check whether the time in values map for Key
+// compares with the value of this function. A missing or invalid time
+// never matches.
+func (f dateFunc) evaluate(values map[string][]string) bool {
+	requestValue, ok := values[http.CanonicalHeaderKey(f.k.Name())]
+	if !ok {
+		requestValue = values[f.k.Name()]
+	}
+	if len(requestValue) == 0 {
+		return false
+	}
+
+	t, err := parseDate(requestValue[0])
+	if err != nil {
+		return false
+	}
+
+	switch f.n {
+	case dateEquals:
+		return t.Equal(f.value)
+	case dateNotEquals:
+		return !t.Equal(f.value)
+	case dateLessThan:
+		return t.Before(f.value)
+	case dateLessThanEquals:
+		return !t.After(f.value)
+	case dateGreaterThan:
+		return t.After(f.value)
+	case dateGreaterThanEquals:
+		return !t.Before(f.value)
+	}
+
+	return false
+}
+
+// key() - returns condition key which is used by this condition function.
+func (f dateFunc) key() Key {
+	return f.k
+}
+
+// name() - returns the Date condition name of this function.
+func (f dateFunc) name() name {
+	return f.n
+}
+
+func (f dateFunc) String() string {
+	return fmt.Sprintf("%v:%v:%v", f.n, f.k, f.value.Format(time.RFC3339))
+}
+
+// toMap - returns map representation of this function.
+func (f dateFunc) toMap() map[Key]ValueSet {
+	if !f.k.IsValid() {
+		return nil
+	}
+
+	return map[Key]ValueSet{
+		f.k: NewValueSet(NewStringValue(f.value.Format(time.RFC3339))),
+	}
+}
+
+// parseDate - parses a RFC3339 time or a number of seconds since the epoch.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	seconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value %v must be a RFC3339 time or epoch seconds", s)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// newDateFunc - returns the constructor of Date functions of the given condition name.
+func newDateFunc(n name) func(Key, ValueSet) (Function, error) {
+	return func(key Key, values ValueSet) (Function, error) {
+		if key != AWSCurrentTime && key != AWSEpochTime {
+			return nil, fmt.Errorf("only %v and %v keys are allowed for %v condition", AWSCurrentTime, AWSEpochTime, n)
+		}
+
+		if len(values) != 1 {
+			return nil, fmt.Errorf("only one value is allowed for %v condition", n)
+		}
+
+		var value time.Time
+		for v := range values {
+			switch v.GetType() {
+			case reflect.Int:
+				i, err := v.GetInt()
+				if err != nil {
+					return nil, err
+				}
+				value = time.Unix(int64(i), 0).UTC()
+			case reflect.String:
+				s, err := v.GetString()
+				if err != nil {
+					return nil, err
+				}
+				if value, err = parseDate(s); err != nil {
+					return nil, fmt.Errorf("%v for %v condition", err, n)
+				}
+			default:
+				return nil, fmt.Errorf("value must be a time for %v condition", n)
+			}
+		}
+
+		return &dateFunc{n, key, value.UTC()}, nil
+	}
+}
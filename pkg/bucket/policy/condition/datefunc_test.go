@@ -0,0 +1,136 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package condition
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDateFuncEvaluate(t *testing.T) {
+	value := NewValueSet(NewStringValue("2020-01-01T00:00:00Z"))
+	newFunc := func(n name, key Key) Function {
+		f, err := newDateFunc(n)(key, value)
+		if err != nil {
+			t.Fatalf("unexpected error. %v\n", err)
+		}
+		return f
+	}
+
+	before := map[string][]string{"CurrentTime": {"2019-12-31T23:59:59Z"}}
+	equal := map[string][]string{"CurrentTime": {"2020-01-01T00:00:00Z"}}
+	after := map[string][]string{"CurrentTime": {"2020-01-01T00:00:01Z"}}
+
+	testCases := []struct {
+		function       Function
+		values         map[string][]string
+		expectedResult bool
+	}{
+		{newFunc(dateEquals, AWSCurrentTime), equal, true},
+		{newFunc(dateEquals, AWSCurrentTime), after, false},
+		{newFunc(dateNotEquals, AWSCurrentTime), equal, false},
+		{newFunc(dateNotEquals, AWSCurrentTime), before, true},
+		{newFunc(dateLessThan, AWSCurrentTime), before, true},
+		{newFunc(dateLessThan, AWSCurrentTime), equal, false},
+		{newFunc(dateLessThanEquals, AWSCurrentTime), equal, true},
+		{newFunc(dateLessThanEquals, AWSCurrentTime), after, false},
+		{newFunc(dateGreaterThan, AWSCurrentTime), after, true},
+		{newFunc(dateGreaterThan, AWSCurrentTime), equal, false},
+		{newFunc(dateGreaterThanEquals, AWSCurrentTime), equal, true},
+		{newFunc(dateGreaterThanEquals, AWSCurrentTime), before, false},
+		{newFunc(dateLessThan, AWSEpochTime), map[string][]string{"EpochTime": {"1577836799"}}, true},
+		{newFunc(dateLessThan, AWSEpochTime), map[string][]string{"EpochTime": {"1577836800"}}, false},
+		// Missing or invalid time never matches.
+		{newFunc(dateNotEquals, AWSCurrentTime), map[string][]string{}, false},
+		{newFunc(dateNotEquals, AWSCurrentTime), map[string][]string{"CurrentTime": {"yesterday"}}, false},
+	}
+
+	for i, testCase := range testCases {
+		result := testCase.function.evaluate(testCase.values)
+
+		if result != testCase.expectedResult {
+			t.Errorf("case %v: expected: %v, got: %v\n", i+1, testCase.expectedResult, result)
+		}
+	}
+}
+
+func TestDateFuncToMap(t *testing.T) {
+	case1Function, err := newDateFunc(dateLessThan)(AWSCurrentTime, NewValueSet(NewStringValue("2020-01-01T01:00:00+01:00")))
+	if err != nil {
+		t.Fatalf("unexpected error. %v\n", err)
+	}
+
+	case2Function, err := newDateFunc(dateGreaterThan)(AWSEpochTime, NewValueSet(NewIntValue(1577836800)))
+	if err != nil {
+		t.Fatalf("unexpected error. %v\n", err)
+	}
+
+	testCases := []struct {
+		f              Function
+		expectedResult map[Key]ValueSet
+	}{
+		{case1Function, map[Key]ValueSet{AWSCurrentTime: NewValueSet(NewStringValue("2020-01-01T00:00:00Z"))}},
+		{case2Function, map[Key]ValueSet{AWSEpochTime: NewValueSet(NewStringValue("2020-01-01T00:00:00Z"))}},
+	}
+
+	for i, testCase := range testCases {
+		result := testCase.f.toMap()
+
+		if !reflect.DeepEqual(result, testCase.expectedResult) {
+			t.Fatalf("case %v: result: expected: %v, got: %v\n", i+1, testCase.expectedResult, result)
+		}
+	}
+}
+
+func TestNewDateFunc(t *testing.T) {
+	value := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		key            Key
+		values         ValueSet
+		expectedResult Function
+		expectErr      bool
+	}{
+		{AWSCurrentTime, NewValueSet(NewStringValue("2020-01-01T00:00:00Z")), &dateFunc{dateLessThan, AWSCurrentTime, value}, false},
+		{AWSCurrentTime, NewValueSet(NewStringValue("1577836800")), &dateFunc{dateLessThan, AWSCurrentTime, value}, false},
+		{AWSEpochTime, NewValueSet(NewIntValue(1577836800)), &dateFunc{dateLessThan, AWSEpochTime, value}, false},
+		// Unsupported key error.
+		{AWSSourceIP, NewValueSet(NewStringValue("2020-01-01T00:00:00Z")), nil, true},
+		// Multiple values error.
+		{AWSCurrentTime, NewValueSet(NewStringValue("2020-01-01T00:00:00Z"), NewStringValue("2021-01-01T00:00:00Z")), nil, true},
+		// Invalid time string error.
+		{AWSCurrentTime, NewValueSet(NewStringValue("yesterday")), nil, true},
+		// Invalid value error.
+		{AWSCurrentTime, NewValueSet(NewBoolValue(true)), nil, true},
+	}
+
+	for i, testCase := range testCases {
+		result, err := newDateFunc(dateLessThan)(testCase.key, testCase.values)
+		expectErr := (err != nil)
+
+		if expectErr != testCase.expectErr {
+			t.Fatalf("case %v: error: expected: %v, got: %v\n", i+1, testCase.expectErr, expectErr)
+		}
+
+		if !testCase.expectErr {
+			if !reflect.DeepEqual(result, testCase.expectedResult) {
+				t.Fatalf("case %v: result: expected: %v, got: %v\n", i+1, testCase.expectedResult, result)
+			}
+		}
+	}
+}
@@ -106,6 +106,12 @@ var conditionFuncMap = map[name]func(Key, ValueSet) (Function, error){
 	notIPAddress:              newNotIPAddressFunc,
 	null:                      newNullFunc,
 	boolean:                   newBooleanFunc,
+	dateEquals:                newDateFunc(dateEquals),
+	dateNotEquals:             newDateFunc(dateNotEquals),
+	dateLessThan:              newDateFunc(dateLessThan),
+	dateLessThanEquals:        newDateFunc(dateLessThanEquals),
+	dateGreaterThan:           newDateFunc(dateGreaterThan),
+	dateGreaterThanEquals:     newDateFunc(dateGreaterThanEquals),
 	// Add new conditions here.
 }
 
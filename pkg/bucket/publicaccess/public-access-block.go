@@ -0,0 +1,117 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package publicaccess
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+const xmlNS = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Config - represents the public access block configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PublicAccessBlockConfiguration.html
+type Config struct {
+	XMLNS   string   `xml:"xmlns,attr,omitempty"`
+	XMLName xml.Name `xml:"PublicAccessBlockConfiguration"`
+	// BlockPublicAcls rejects requests that grant public access with an ACL
+	BlockPublicAcls bool `xml:"BlockPublicAcls"`
+	// IgnorePublicAcls ignores ACLs that grant public access
+	IgnorePublicAcls bool `xml:"IgnorePublicAcls"`
+	// BlockPublicPolicy rejects bucket policies that grant public access
+	BlockPublicPolicy bool `xml:"BlockPublicPolicy"`
+	// RestrictPublicBuckets denies anonymous access granted by bucket policies
+	RestrictPublicBuckets bool `xml:"RestrictPublicBuckets"`
+}
+
+// BlockAll - returns a configuration that blocks all public access
+func BlockAll() Config {
+	return Config{
+		BlockPublicAcls:       true,
+		IgnorePublicAcls:      true,
+		BlockPublicPolicy:     true,
+		RestrictPublicBuckets: true,
+	}
+}
+
+// ParseConfig - Decodes given XML to a public access block configuration
+func ParseConfig(r io.Reader) (*Config, error) {
+	var config Config
+	if err := xml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.XMLNS == "" {
+		config.XMLNS = xmlNS
+	}
+	return &config, nil
+}
+
+// Merge - returns a configuration that blocks the public access blocked by either configuration
+func (c Config) Merge(o Config) Config {
+	return Config{
+		XMLNS:                 c.XMLNS,
+		BlockPublicAcls:       c.BlockPublicAcls || o.BlockPublicAcls,
+		IgnorePublicAcls:      c.IgnorePublicAcls || o.IgnorePublicAcls,
+		BlockPublicPolicy:     c.BlockPublicPolicy || o.BlockPublicPolicy,
+		RestrictPublicBuckets: c.RestrictPublicBuckets || o.RestrictPublicBuckets,
+	}
+}
+
+// IsPublicPolicy - returns true if the policy allows any action to everyone
+func IsPublicPolicy(p *policy.Policy) bool {
+	if p == nil {
+		return false
+	}
+	for _, statement := range p.Statements {
+		if statement.Effect == policy.Allow && statement.Principal.AWS.Contains("*") {
+			return true
+		}
+	}
+	return false
+}
+
+// publicGrantees are the grantees of ACL grant headers that grant public access
+var publicGrantees = []string{
+	"http://acs.amazonaws.com/groups/global/AllUsers",
+	"http://acs.amazonaws.com/groups/global/AuthenticatedUsers",
+}
+
+// IsPublicACL - returns true if the canned ACL or grant headers of a request grant public access
+func IsPublicACL(h http.Header) bool {
+	switch h.Get("x-amz-acl") {
+	case "public-read", "public-read-write", "authenticated-read":
+		return true
+	}
+	for _, header := range []string{
+		"x-amz-grant-read",
+		"x-amz-grant-write",
+		"x-amz-grant-read-acp",
+		"x-amz-grant-write-acp",
+		"x-amz-grant-full-control",
+	} {
+		for _, grantee := range publicGrantees {
+			if strings.Contains(h.Get(header), grantee) {
+				return true
+			}
+		}
+	}
+	return false
+}
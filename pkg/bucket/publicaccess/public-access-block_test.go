@@ -0,0 +1,131 @@
+/*
+ * MinIO Cloud Storage, (C) 2020 MinIO, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package publicaccess
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/s3x/pkg/bucket/policy"
+)
+
+// TestParseConfig performs basic sanity tests on ParseConfig
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		inputXML       string
+		expectedConfig Config
+		shouldPass     bool
+	}{
+		{
+			inputXML: `<PublicAccessBlockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+			<BlockPublicAcls>true</BlockPublicAcls>
+			<RestrictPublicBuckets>true</RestrictPublicBuckets>
+			</PublicAccessBlockConfiguration>`,
+			expectedConfig: Config{BlockPublicAcls: true, RestrictPublicBuckets: true},
+			shouldPass:     true,
+		},
+		{
+			inputXML:       `<PublicAccessBlockConfiguration><BlockPublicPolicy>true</BlockPublicPolicy></PublicAccessBlockConfiguration>`,
+			expectedConfig: Config{BlockPublicPolicy: true},
+			shouldPass:     true,
+		},
+		{
+			inputXML:   `<PublicAccessBlockConfiguration><BlockPublicPolicy>yes</BlockPublicPolicy></PublicAccessBlockConfiguration>`,
+			shouldPass: false,
+		},
+		{
+			inputXML:   `<ServerSideEncryptionConfiguration></ServerSideEncryptionConfiguration>`,
+			shouldPass: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		config, err := ParseConfig(bytes.NewReader([]byte(tc.inputXML)))
+		if tc.shouldPass && err != nil {
+			t.Fatalf("Test case %d: Expected to succeed but got %s", i+1, err)
+		}
+		if !tc.shouldPass {
+			if err == nil {
+				t.Fatalf("Test case %d: Expected to fail but succeeded", i+1)
+			}
+			continue
+		}
+		if config.XMLNS != xmlNS {
+			t.Fatalf("Test case %d: Expected namespace %s but got %s", i+1, xmlNS, config.XMLNS)
+		}
+		config.XMLNS, config.XMLName = "", tc.expectedConfig.XMLName
+		if *config != tc.expectedConfig {
+			t.Fatalf("Test case %d: Expected %+v but got %+v", i+1, tc.expectedConfig, *config)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	merged := Config{BlockPublicAcls: true}.Merge(Config{RestrictPublicBuckets: true})
+	expected := Config{BlockPublicAcls: true, RestrictPublicBuckets: true}
+	if merged != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, merged)
+	}
+	if all := (Config{}).Merge(BlockAll()); all != BlockAll() {
+		t.Fatalf("Expected %+v but got %+v", BlockAll(), all)
+	}
+}
+
+func TestIsPublicPolicy(t *testing.T) {
+	testCases := []struct {
+		policy   string
+		expected bool
+	}{
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::mybucket/*"]}]}`, true},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":["s3:ListBucket"],"Resource":["arn:aws:s3:::mybucket"]}]}`, true},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":{"AWS":["*"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::mybucket/*"]}]}`, false},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::123456789012:root"]},"Action":["s3:GetObject"],"Resource":["arn:aws:s3:::mybucket/*"]}]}`, false},
+	}
+	for i, tc := range testCases {
+		p, err := policy.ParseConfig(strings.NewReader(tc.policy), "mybucket")
+		if err != nil {
+			t.Fatalf("Test case %d: %v", i+1, err)
+		}
+		if public := IsPublicPolicy(p); public != tc.expected {
+			t.Fatalf("Test case %d: Expected %v but got %v", i+1, tc.expected, public)
+		}
+	}
+	if IsPublicPolicy(nil) {
+		t.Fatal("Expected a missing policy not to be public")
+	}
+}
+
+func TestIsPublicACL(t *testing.T) {
+	testCases := []struct {
+		header   http.Header
+		expected bool
+	}{
+		{http.Header{}, false},
+		{http.Header{"X-Amz-Acl": []string{"private"}}, false},
+		{http.Header{"X-Amz-Acl": []string{"public-read"}}, true},
+		{http.Header{"X-Amz-Acl": []string{"authenticated-read"}}, true},
+		{http.Header{"X-Amz-Grant-Read": []string{`id="1234"`}}, false},
+		{http.Header{"X-Amz-Grant-Read": []string{`uri="http://acs.amazonaws.com/groups/global/AllUsers"`}}, true},
+	}
+	for i, tc := range testCases {
+		if public := IsPublicACL(tc.header); public != tc.expected {
+			t.Fatalf("Test case %d: Expected %v but got %v", i+1, tc.expected, public)
+		}
+	}
+}
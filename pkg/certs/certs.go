@@ -18,6 +18,7 @@ package certs
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"os"
 	"path/filepath"
 	"sync"
@@ -38,6 +39,10 @@ type Certs struct {
 	// points to the latest certificate.
 	cert tls.Certificate
 
+	// certificates served to clients that request a
+	// server name they are valid for.
+	sni []*Certs
+
 	// internal param to track for events, also
 	// used to close the watcher.
 	e chan notify.EventInfo
@@ -59,7 +64,7 @@ func New(certFile, keyFile string, loadCert LoadX509KeyPairFunc) (*Certs, error)
 	c := &Certs{
 		certFile: certFile,
 		keyFile:  keyFile,
-		loadCert: loadCert,
+		loadCert: withLeaf(loadCert),
 		// Make the channel buffered to ensure no event is dropped. Notify will drop
 		// an event if the receiver is not able to keep up the sending pace.
 		e: make(chan notify.EventInfo, 1),
@@ -78,6 +83,33 @@ func New(certFile, keyFile string, loadCert LoadX509KeyPairFunc) (*Certs, error)
 	return c, nil
 }
 
+// withLeaf returns a loader that parses the leaf of the certificates
+// loaded by loadCert, to match them against the requested server name.
+func withLeaf(loadCert LoadX509KeyPairFunc) LoadX509KeyPairFunc {
+	return func(certFile, keyFile string) (tls.Certificate, error) {
+		cert, err := loadCert(certFile, keyFile)
+		if err != nil || cert.Leaf != nil || len(cert.Certificate) == 0 {
+			return cert, err
+		}
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		return cert, err
+	}
+}
+
+// AddCertificate adds a certificate and key pair that is served to clients
+// that request a server name it is valid for, such as the domain of virtual
+// host style requests. The pair is watched for changes like the default pair.
+func (c *Certs) AddCertificate(certFile, keyFile string) error {
+	sni, err := New(certFile, keyFile, c.loadCert)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	c.sni = append(c.sni, sni)
+	c.Unlock()
+	return nil
+}
+
 func checkSymlink(file string) (bool, error) {
 	st, err := os.Lstat(file)
 	if err != nil {
@@ -174,9 +206,36 @@ type GetCertificateFunc func(hello *tls.ClientHelloInfo) (*tls.Certificate, erro
 
 // GetCertificate returns the loaded certificate for use by
 // the TLSConfig fields GetCertificate field in a http.Server.
+// Clients that request a server name get the added certificate
+// for exactly that name, or else the first added certificate
+// valid for it, such as a wildcard certificate, or else the
+// default certificate.
 func (c *Certs) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	c.RLock()
 	defer c.RUnlock()
+	if hello == nil || hello.ServerName == "" || len(c.sni) == 0 {
+		return &c.cert, nil
+	}
+	var valid *tls.Certificate
+	for _, sni := range c.sni {
+		sni.RLock()
+		cert := sni.cert
+		sni.RUnlock()
+		if cert.Leaf == nil || cert.Leaf.VerifyHostname(hello.ServerName) != nil {
+			continue
+		}
+		for _, name := range cert.Leaf.DNSNames {
+			if name == hello.ServerName {
+				return &cert, nil
+			}
+		}
+		if valid == nil {
+			valid = &cert
+		}
+	}
+	if valid != nil {
+		return valid, nil
+	}
 	return &c.cert, nil
 }
 
@@ -185,5 +244,8 @@ func (c *Certs) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, er
 func (c *Certs) Stop() {
 	if c != nil {
 		notify.Stop(c.e)
+		for _, sni := range c.sni {
+			sni.Stop()
+		}
 	}
 }
@@ -17,9 +17,18 @@
 package certs_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -122,3 +131,91 @@ func TestStop(t *testing.T) {
 		t.Error("certificate shouldn't match, but matched")
 	}
 }
+
+// writeTestCert writes a self signed certificate valid for names to dir.
+func writeTestCert(t *testing.T, dir string, names ...string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, names[0]+".crt"), filepath.Join(dir, names[0]+".key")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestAddCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := certs.New("server.crt", "server.key", tls.LoadX509KeyPair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	for _, names := range [][]string{
+		{"*.example.com", "example.com"},
+		{"assets.example.com"},
+		{"*.s3.example.org"},
+	} {
+		if err = c.AddCertificate(writeTestCert(t, dir, names...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = c.AddCertificate("server.crt", "server2.key"); err == nil {
+		t.Fatal("expected a mismatched pair to fail")
+	}
+
+	defaultCert, err := c.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testCase := range []struct {
+		serverName string
+		commonName string
+	}{
+		{"bucket.example.com", "*.example.com"},
+		{"example.com", "*.example.com"},
+		// a certificate for exactly the name is preferred over a wildcard
+		{"assets.example.com", "assets.example.com"},
+		{"bucket.s3.example.org", "*.s3.example.org"},
+		{"s3.example.org", ""},
+		{"other.net", ""},
+	} {
+		gcert, err := c.GetCertificate(&tls.ClientHelloInfo{ServerName: testCase.serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if testCase.commonName == "" {
+			if !reflect.DeepEqual(gcert.Certificate, defaultCert.Certificate) {
+				t.Errorf("%s: expected the default certificate", testCase.serverName)
+			}
+			continue
+		}
+		if gcert.Leaf == nil || gcert.Leaf.Subject.CommonName != testCase.commonName {
+			t.Errorf("%s: expected the certificate of %s, got %+v", testCase.serverName, testCase.commonName, gcert.Leaf)
+		}
+	}
+}
@@ -129,6 +129,12 @@ const (
 	// GetBucketEncryptionAction - GetBucketEncryption REST API action
 	GetBucketEncryptionAction = "s3:GetEncryptionConfiguration"
 
+	// PutBucketMetricsConfigurationAction - PutBucketMetricsConfiguration and DeleteBucketMetricsConfiguration REST API action
+	PutBucketMetricsConfigurationAction = "s3:PutMetricsConfiguration"
+
+	// GetBucketMetricsConfigurationAction - GetBucketMetricsConfiguration and ListBucketMetricsConfigurations REST API action
+	GetBucketMetricsConfigurationAction = "s3:GetMetricsConfiguration"
+
 	// AllActions - all API actions
 	AllActions = "s3:*"
 )
@@ -169,6 +175,8 @@ var supportedActions = map[Action]struct{}{
 	DeleteObjectTaggingAction:              {},
 	PutBucketEncryptionAction:              {},
 	GetBucketEncryptionAction:              {},
+	PutBucketMetricsConfigurationAction:    {},
+	GetBucketMetricsConfigurationAction:    {},
 }
 
 // List of all supported object actions.
@@ -25,6 +25,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // ProfilerType represents the profiler type
@@ -105,3 +107,49 @@ func (adm *AdminClient) DownloadProfilingData() (io.ReadCloser, error) {
 
 	return resp.Body, nil
 }
+
+// Profile makes an admin call to capture one profile of the server, CPU and block profiles are
+// sampled for duration, or 30 seconds if it is 0, heap profiles and goroutine dumps are snapshots.
+func (adm *AdminClient) Profile(profiler ProfilerType, duration time.Duration) (io.ReadCloser, error) {
+	v := profileDurationValues(duration)
+	v.Set("profilerType", string(profiler))
+	return adm.downloadProfile(adminAPIPrefix+"/profiling/profile", v)
+}
+
+// ProfileBundle makes an admin call to capture a zip of the CPU and block profiles of the server,
+// sampled for duration, or 30 seconds if it is 0, with its heap profile and goroutine dump.
+func (adm *AdminClient) ProfileBundle(duration time.Duration) (io.ReadCloser, error) {
+	return adm.downloadProfile(adminAPIPrefix+"/profiling/bundle", profileDurationValues(duration))
+}
+
+// profileDurationValues returns the query values of the duration of a profile
+func profileDurationValues(duration time.Duration) url.Values {
+	v := url.Values{}
+	if duration > 0 {
+		v.Set("seconds", strconv.Itoa(int(duration/time.Second)))
+	}
+	return v
+}
+
+// downloadProfile returns the body of a profile download
+func (adm *AdminClient) downloadProfile(path string, v url.Values) (io.ReadCloser, error) {
+	resp, err := adm.executeMethod("GET", requestData{
+		relPath:     path,
+		queryValues: v,
+	})
+	if err != nil {
+		closeResponse(resp)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer closeResponse(resp)
+		return nil, httpRespToErrorResponse(resp)
+	}
+
+	if resp.Body == nil {
+		return nil, errors.New("body is nil")
+	}
+
+	return resp.Body, nil
+}
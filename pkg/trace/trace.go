@@ -24,11 +24,13 @@ import (
 // Info - represents a trace record, additionally
 // also reports errors if any while listening on trace.
 type Info struct {
-	NodeName  string       `json:"nodename"`
-	FuncName  string       `json:"funcname"`
-	ReqInfo   RequestInfo  `json:"request"`
-	RespInfo  ResponseInfo `json:"response"`
-	CallStats CallStats    `json:"stats"`
+	NodeName      string       `json:"nodename"`
+	FuncName      string       `json:"funcname"`
+	RequestID     string       `json:"requestid,omitempty"`
+	RequestHostID string       `json:"requesthostid,omitempty"`
+	ReqInfo       RequestInfo  `json:"request"`
+	RespInfo      ResponseInfo `json:"response"`
+	CallStats     CallStats    `json:"stats"`
 }
 
 // CallStats records request stats